@@ -0,0 +1,227 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+	"github.com/weaveworks/common/mtime"
+
+	"github.com/weaveworks/scope/app/auth"
+	"github.com/weaveworks/scope/render/detailed"
+)
+
+const (
+	maxAnnotationsPerOrg    = 10000
+	maxAnnotationBodyLength = 16 * 1024
+)
+
+// annotations are merged into the details of nodes. It is nil (and
+// annotations disabled) by default.
+var (
+	annotations      *Annotations
+	annotationsOrgID func(context.Context) (string, error)
+)
+
+// EnableAnnotations turns on merging the annotations of the org of
+// requests, as told by orgID, into the details of nodes.
+func EnableAnnotations(a *Annotations, orgID func(context.Context) (string, error)) {
+	annotations, annotationsOrgID = a, orgID
+}
+
+// Annotation is a note of users on the nodes of a topology, e.g. the team
+// owning them, a runbook or "known noisy". It applies to the node with
+// the ID Node, to the nodes labelled Label, or to the nodes with the
+// stable ID StableID: labels, such as the names of containers or hosts,
+// and stable IDs, such as compose services, survive restarts where IDs
+// don't.
+type Annotation struct {
+	ID       string    `json:"id"`
+	Topology string    `json:"topology"`
+	Node     string    `json:"node,omitempty"`
+	Label    string    `json:"label,omitempty"`
+	StableID string    `json:"stableId,omitempty"`
+	Text     string    `json:"text"`
+	Link     string    `json:"link,omitempty"`
+	Author   string    `json:"author"`
+	Created  time.Time `json:"created"`
+}
+
+func (a Annotation) validate() error {
+	selectors := 0
+	for _, selector := range []string{a.Node, a.Label, a.StableID} {
+		if selector != "" {
+			selectors++
+		}
+	}
+	if selectors != 1 {
+		return fmt.Errorf("annotations need one of a node, a label or a stable ID")
+	}
+	if a.Text == "" {
+		return fmt.Errorf("annotations need a text")
+	}
+	if _, ok := topologyRegistry.get(a.Topology); !ok {
+		return fmt.Errorf("unknown topology %q", a.Topology)
+	}
+	return nil
+}
+
+func (a Annotation) applies(topologyID string, n detailed.BasicNodeSummary) bool {
+	return a.Topology == topologyID && ((a.Node != "" && a.Node == n.ID) ||
+		(a.Label != "" && a.Label == n.Label) ||
+		(a.StableID != "" && a.StableID == n.StableID))
+}
+
+type annotationsByCreation []Annotation
+
+func (a annotationsByCreation) Len() int      { return len(a) }
+func (a annotationsByCreation) Swap(i, j int) { a[i], a[j] = a[j], a[i] }
+func (a annotationsByCreation) Less(i, j int) bool {
+	if !a[i].Created.Equal(a[j].Created) {
+		return a[i].Created.Before(a[j].Created)
+	}
+	return a[i].ID < a[j].ID
+}
+
+// Annotations stores the annotations of every org, persisting them to a
+// file if given one.
+type Annotations struct {
+	store *orgStore
+}
+
+// NewAnnotations makes a new Annotations, loading any annotations
+// previously saved at path. With no path, annotations are kept in memory
+// only.
+func NewAnnotations(path string) (*Annotations, error) {
+	store, err := newOrgStore(path, maxAnnotationsPerOrg, errAnnotationNotFound, errTooManyAnnotations, func(buf []byte) (interface{}, error) {
+		var annotation Annotation
+		err := json.Unmarshal(buf, &annotation)
+		return annotation, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Annotations{store: store}, nil
+}
+
+// List returns the annotations of an org, oldest first, optionally only
+// those of a topology.
+func (a *Annotations) List(org, topologyID string) []Annotation {
+	result := []Annotation{}
+	a.store.each(org, func(record interface{}) {
+		if annotation := record.(Annotation); topologyID == "" || annotation.Topology == topologyID {
+			result = append(result, annotation)
+		}
+	})
+	sort.Sort(annotationsByCreation(result))
+	return result
+}
+
+// ForNode returns the annotations of an org applying to a rendered node.
+func (a *Annotations) ForNode(org, topologyID string, n detailed.BasicNodeSummary) []Annotation {
+	var result []Annotation
+	a.store.each(org, func(record interface{}) {
+		if annotation := record.(Annotation); annotation.applies(topologyID, n) {
+			result = append(result, annotation)
+		}
+	})
+	sort.Sort(annotationsByCreation(result))
+	return result
+}
+
+// Add saves a new annotation of the user. Annotations are validated when
+// decoded.
+func (a *Annotations) Add(org string, annotation Annotation, user auth.Identity) (Annotation, error) {
+	record, err := a.store.add(org, nil, func(id string) interface{} {
+		annotation.ID, annotation.Author, annotation.Created = id, user.Subject, mtime.Now().UTC()
+		return annotation
+	})
+	if err != nil {
+		return Annotation{}, err
+	}
+	return record.(Annotation), nil
+}
+
+// Errors of the changes to annotations
+var (
+	errAnnotationNotFound = fmt.Errorf("no such annotation")
+	errNotAuthor          = fmt.Errorf("only the author of an annotation can delete it")
+	errTooManyAnnotations = fmt.Errorf("at most %d annotations can be saved", maxAnnotationsPerOrg)
+)
+
+// Delete deletes an annotation of the user; admins may delete any.
+func (a *Annotations) Delete(org, id string, user auth.Identity) error {
+	return a.store.delete(org, id, func(record interface{}) error {
+		if record.(Annotation).Author != user.Subject && user.Role != auth.Admin {
+			return errNotAuthor
+		}
+		return nil
+	})
+}
+
+// nodeAnnotations returns the annotations of the org of a request
+// applying to a rendered node, if annotations are enabled.
+func nodeAnnotations(ctx context.Context, topologyID string, n detailed.BasicNodeSummary) []Annotation {
+	if annotations == nil {
+		return nil
+	}
+	org, err := annotationsOrgID(ctx)
+	if err != nil {
+		return nil
+	}
+	return annotations.ForNode(org, topologyID, n)
+}
+
+// RegisterAnnotationRoutes registers the routes to list, add and delete
+// annotations. Annotations are kept apart by the org the request comes
+// from, as told by orgID.
+func RegisterAnnotationRoutes(router *mux.Router, a *Annotations, orgID func(context.Context) (string, error)) {
+	router.Methods("GET").Path("/api/annotations").
+		HandlerFunc(requestContextDecorator(handleOrgRequest(orgID, func(w http.ResponseWriter, r *http.Request, org string, user auth.Identity) {
+			respondWith(w, http.StatusOK, a.List(org, r.FormValue("topology")))
+		})))
+	router.Methods("POST").Path("/api/annotations").
+		HandlerFunc(requestContextDecorator(handleOrgRequest(orgID, func(w http.ResponseWriter, r *http.Request, org string, user auth.Identity) {
+			var annotation Annotation
+			if err := codec.NewDecoder(io.LimitReader(r.Body, maxAnnotationBodyLength), &codec.JsonHandle{}).Decode(&annotation); err != nil {
+				respondWith(w, http.StatusBadRequest, err)
+				return
+			}
+			if err := annotation.validate(); err != nil {
+				respondWith(w, http.StatusBadRequest, err)
+				return
+			}
+			annotation, err := a.Add(org, annotation, user)
+			if err != nil {
+				respondWith(w, annotationErrorStatus(err), err)
+				return
+			}
+			respondWith(w, http.StatusCreated, annotation)
+		})))
+	router.Methods("DELETE").Path("/api/annotations/{id}").
+		HandlerFunc(requestContextDecorator(handleOrgRequest(orgID, func(w http.ResponseWriter, r *http.Request, org string, user auth.Identity) {
+			if err := a.Delete(org, mux.Vars(r)["id"], user); err != nil {
+				respondWith(w, annotationErrorStatus(err), err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})))
+}
+
+func annotationErrorStatus(err error) int {
+	switch err {
+	case errAnnotationNotFound:
+		return http.StatusNotFound
+	case errNotAuthor:
+		return http.StatusForbidden
+	case errTooManyAnnotations:
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,107 @@
+package app_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/app/auth"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+func TestAnnotations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "annotations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	annotations, err := app.NewAnnotations(filepath.Join(dir, "annotations.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	orgID := func(context.Context) (string, error) { return "org1", nil }
+	app.EnableAnnotations(annotations, orgID)
+	defer app.EnableAnnotations(nil, nil)
+
+	tokens, err := auth.ParseTokens(strings.NewReader("alice-token read-only alice\nbob-token read-only bob\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := mux.NewRouter().SkipClean(true)
+	app.RegisterTopologyRoutes(router, app.StaticCollector(fixture.Report), map[string]bool{})
+	app.RegisterAnnotationRoutes(router, annotations, orgID)
+	ts := httptest.NewServer(auth.NewAuthenticator(auth.Config{Tokens: tokens}).Wrap(router))
+	defer ts.Close()
+
+	for _, invalid := range []app.Annotation{
+		{Topology: "processes", Text: "no node"},
+		{Topology: "processes", Node: fixture.ServerProcessNodeID},
+		{Topology: "nope", Node: fixture.ServerProcessNodeID, Text: "unknown topology"},
+		{Topology: "processes", Node: fixture.ServerProcessNodeID, StableID: "systemd/server/apache.service", Text: "two selectors"},
+	} {
+		if code, _ := viewRequest(t, ts, "alice-token", "POST", "/api/annotations", invalid); code != http.StatusBadRequest {
+			t.Errorf("expected %+v to be rejected, got %d", invalid, code)
+		}
+	}
+
+	var added []app.Annotation
+	for _, annotation := range []app.Annotation{
+		{Topology: "processes", Node: fixture.ServerProcessNodeID, Text: "owned by team-web", Link: "https://runbooks/apache"},
+		{Topology: "processes", Label: "apache", Text: "known noisy"},
+		{Topology: "processes", Node: fixture.ClientProcess1NodeID, Text: "someone else"},
+	} {
+		code, body := viewRequest(t, ts, "alice-token", "POST", "/api/annotations", annotation)
+		if code != http.StatusCreated {
+			t.Fatalf("expected the annotation to be added, got %d %s", code, body)
+		}
+		var a app.Annotation
+		if err := json.Unmarshal(body, &a); err != nil {
+			t.Fatal(err)
+		}
+		added = append(added, a)
+	}
+
+	// Annotations are merged into the details of the nodes they apply to
+	code, body := viewRequest(t, ts, "bob-token", "GET", "/api/topology/processes/"+fixture.ServerProcessNodeID, nil)
+	if code != http.StatusOK {
+		t.Fatalf("expected the node, got %d", code)
+	}
+	var node app.APINode
+	if err := codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&node); err != nil {
+		t.Fatal(err)
+	}
+	if len(node.Annotations) != 2 || node.Annotations[0].Text != "owned by team-web" || node.Annotations[1].Text != "known noisy" ||
+		node.Annotations[0].Author != "alice" {
+		t.Errorf("unexpected annotations %+v", node.Annotations)
+	}
+
+	// Only their authors delete them
+	if code, _ := viewRequest(t, ts, "bob-token", "DELETE", "/api/annotations/"+added[1].ID, nil); code != http.StatusForbidden {
+		t.Errorf("expected bob not to delete the annotation of alice, got %d", code)
+	}
+	if code, _ := viewRequest(t, ts, "alice-token", "DELETE", "/api/annotations/"+added[1].ID, nil); code != http.StatusNoContent {
+		t.Errorf("expected alice to delete her annotation, got %d", code)
+	}
+
+	// Annotations persist
+	reloaded, err := app.NewAnnotations(filepath.Join(dir, "annotations.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list := reloaded.List("org1", "processes"); len(list) != 2 || list[0].ID != added[0].ID || list[1].ID != added[2].ID {
+		t.Errorf("expected the remaining annotations to persist, got %+v", list)
+	}
+	if list := reloaded.List("org2", ""); len(list) != 0 {
+		t.Errorf("expected other orgs to have no annotations, got %+v", list)
+	}
+}
@@ -0,0 +1,152 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"context"
+	"github.com/gorilla/mux"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/report"
+)
+
+// Defaults of the bandwidth tests. The probes bound the duration and
+// rate of the tests they run.
+const (
+	defaultBandwidthTestDuration = 5 * time.Second
+	defaultBandwidthTestRate     = 100 // Mb/s
+)
+
+// APIBandwidthTest is returned by the /api/bandwidth-test handler.
+type APIBandwidthTest struct {
+	From   string      `json:"from"`
+	To     string      `json:"to"`
+	Result interface{} `json:"result"`
+}
+
+// RegisterBandwidthTestRoute registers the route to test the throughput
+// between two hosts.
+func RegisterBandwidthTestRoute(router *mux.Router, rep Reporter, cr ControlRouter) {
+	router.
+		Methods("POST").
+		Name("api_bandwidth_test").
+		Path("/api/bandwidth-test").
+		HandlerFunc(requestContextDecorator(handleBandwidthTest(rep, cr)))
+}
+
+// handleBandwidthTest has the probe of the host `to` receive from the
+// probe of the host `from`, both identified by node ID, for a time-boxed
+// and rate-capped throughput test. It blocks for the duration of the
+// test.
+func handleBandwidthTest(rep Reporter, cr ControlRouter) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		var (
+			from     = r.FormValue("from")
+			to       = r.FormValue("to")
+			duration = defaultBandwidthTestDuration
+			rate     = defaultBandwidthTestRate
+			err      error
+		)
+		if from == "" || to == "" || from == to {
+			respondWith(w, http.StatusBadRequest, fmt.Errorf("from and to must be two different hosts"))
+			return
+		}
+		if d := r.FormValue("duration"); d != "" {
+			if duration, err = time.ParseDuration(d); err != nil {
+				respondWith(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		if v := r.FormValue("rate"); v != "" {
+			if rate, err = strconv.Atoi(v); err != nil {
+				respondWith(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+
+		rpt, err := rep.Report(ctx, time.Now())
+		if err != nil {
+			respondWith(w, http.StatusInternalServerError, err)
+			return
+		}
+		fromProbe, ok := hostProbeID(rpt, from)
+		if !ok {
+			respondWith(w, http.StatusNotFound, fmt.Errorf("no probe for host %s", from))
+			return
+		}
+		toProbe, ok := hostProbeID(rpt, to)
+		if !ok {
+			respondWith(w, http.StatusNotFound, fmt.Errorf("no probe for host %s", to))
+			return
+		}
+		toHostID, _ := report.ParseHostNodeID(to)
+		address, ok := hostAddresses(rpt)[toHostID]
+		if !ok {
+			respondWith(w, http.StatusNotFound, fmt.Errorf("no address for host %s", to))
+			return
+		}
+
+		// The token keeps others from connecting to the server in the
+		// place of the client
+		token := newID()
+		server, err := handleControlRequest(ctx, cr, toProbe, xfer.Request{
+			NodeID:  to,
+			Control: host.BandwidthTestServer,
+			ControlArgs: map[string]string{
+				host.BandwidthTestAddress:  address,
+				host.BandwidthTestToken:    token,
+				host.BandwidthTestDuration: duration.String(),
+			},
+		})
+		if err != nil {
+			respondWith(w, http.StatusBadRequest, err)
+			return
+		}
+		port, ok := server.Value.(string)
+		if !ok {
+			respondWith(w, http.StatusInternalServerError, fmt.Errorf("unexpected response %v", server.Value))
+			return
+		}
+		client, err := handleControlRequest(ctx, cr, fromProbe, xfer.Request{
+			NodeID:  from,
+			Control: host.BandwidthTestClient,
+			ControlArgs: map[string]string{
+				host.BandwidthTestAddress:  address,
+				host.BandwidthTestPort:     port,
+				host.BandwidthTestToken:    token,
+				host.BandwidthTestDuration: duration.String(),
+				host.BandwidthTestRate:     strconv.Itoa(rate),
+			},
+		})
+		if err != nil {
+			respondWith(w, http.StatusBadRequest, err)
+			return
+		}
+		respondWith(w, http.StatusOK, APIBandwidthTest{From: from, To: to, Result: client.Value})
+	}
+}
+
+func hostProbeID(rpt report.Report, hostNodeID string) (string, bool) {
+	node, ok := rpt.Host.Nodes[hostNodeID]
+	if !ok {
+		return "", false
+	}
+	return node.Latest.Lookup(report.ControlProbeID)
+}
+
+// handleControlRequest sends the request to the probe, turning errors of
+// the response into errors.
+func handleControlRequest(ctx context.Context, cr ControlRouter, probeID string, req xfer.Request) (xfer.Response, error) {
+	res, err := cr.Handle(ctx, probeID, req)
+	if err != nil {
+		return res, err
+	}
+	if res.Error != "" {
+		return res, fmt.Errorf("%s: %s", req.Control, res.Error)
+	}
+	return res, nil
+}
@@ -0,0 +1,101 @@
+package app_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/probe/controls"
+	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/report"
+)
+
+// localAddress returns a non-loopback IPv4 address of this host, as the
+// app doesn't test loopback interfaces.
+func localAddress(t *testing.T) string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil && !ipNet.IP.IsLoopback() {
+			return ipNet.String()
+		}
+	}
+	t.Skip("no non-loopback address")
+	return ""
+}
+
+func TestBandwidthTest(t *testing.T) {
+	ctx := context.Background()
+	address := localAddress(t)
+	rpt := report.MakeReport()
+	for _, hostID := range []string{"hostA", "hostB"} {
+		hostNodeID := report.MakeHostNodeID(hostID)
+		rpt.Host.AddNode(report.MakeNodeWith(hostNodeID, map[string]string{report.ControlProbeID: "probe-" + hostID}))
+		rpt.NetworkInterface.AddNode(report.MakeNodeWith(report.MakeNetworkInterfaceNodeID(hostID, "eth0"), map[string]string{
+			host.InterfaceType: host.PhysicalInterface,
+		}).WithParent(report.Host, hostNodeID).WithSet(host.InterfaceAddresses, report.MakeStringSet(address)))
+	}
+	collector := app.NewCollector(time.Minute)
+	collector.Add(ctx, rpt, nil)
+
+	// Both hosts are tested by the same probe, locally
+	registry := controls.NewDefaultHandlerRegistry()
+	reporter := host.NewReporter("hostA", "hostA", "probe-hostA", "", nil, registry)
+	defer reporter.Stop()
+	cr := app.NewLocalControlRouter()
+	for _, probeID := range []string{"probe-hostA", "probe-hostB"} {
+		if _, err := cr.Register(ctx, probeID, registry.HandleControlRequest); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	router := mux.NewRouter()
+	app.RegisterBandwidthTestRoute(router, collector, cr)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.PostForm(server.URL+"/api/bandwidth-test", map[string][]string{
+		"from":     {report.MakeHostNodeID("hostA")},
+		"to":       {report.MakeHostNodeID("hostB")},
+		"duration": {"200ms"},
+		"rate":     {"8"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.StatusCode)
+	}
+	var result struct {
+		Result host.BandwidthTestResult `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		t.Fatal(err)
+	}
+	// 8 Mb/s for 200ms is at most 200kB, sent in 32kB chunks
+	if result.Result.Bytes <= 0 || result.Result.Bytes > 200*1000 {
+		t.Errorf("expected at most 200kB to be received, got %+v", result.Result)
+	}
+
+	resp, err = http.PostForm(server.URL+"/api/bandwidth-test", map[string][]string{
+		"from": {report.MakeHostNodeID("hostA")},
+		"to":   {report.MakeHostNodeID("unknown")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown host, got %d", resp.StatusCode)
+	}
+}
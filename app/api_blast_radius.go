@@ -0,0 +1,63 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"context"
+	"github.com/gorilla/mux"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/render/detailed"
+)
+
+// APIBlastRadius is returned by the /api/topology/{name}/{id}/blast-radius handler.
+type APIBlastRadius struct {
+	ID        string `json:"id"`
+	Direction string `json:"direction"`
+	detailed.Impact
+}
+
+// The set of nodes transitively connected to a node.
+func handleBlastRadius(ctx context.Context, renderer render.Renderer, transformer render.Transformer, rc detailed.RenderContext, w http.ResponseWriter, r *http.Request) {
+	var (
+		vars      = mux.Vars(r)
+		nodeID    = vars["id"]
+		direction = r.Form.Get("direction")
+		depth     = 0
+		minWeight = 0
+		err       error
+	)
+	switch direction {
+	case "":
+		direction = detailed.Downstream
+	case detailed.Downstream, detailed.Upstream:
+	default:
+		respondWith(w, http.StatusBadRequest, fmt.Errorf("invalid direction '%s'", direction))
+		return
+	}
+	if d := r.Form.Get("depth"); d != "" {
+		if depth, err = strconv.Atoi(d); err != nil || depth < 0 {
+			respondWith(w, http.StatusBadRequest, fmt.Errorf("invalid depth '%s'", d))
+			return
+		}
+	}
+	if mw := r.Form.Get("min_weight"); mw != "" {
+		if minWeight, err = strconv.Atoi(mw); err != nil || minWeight < 0 {
+			respondWith(w, http.StatusBadRequest, fmt.Errorf("invalid min_weight '%s'", mw))
+			return
+		}
+	}
+
+	nodes := render.Render(rc.Report, renderer, transformer)
+	if _, ok := nodes.Nodes[nodeID]; !ok {
+		http.NotFound(w, r)
+		return
+	}
+	respondWith(w, http.StatusOK, APIBlastRadius{
+		ID:        nodeID,
+		Direction: direction,
+		Impact:    detailed.BlastRadius(rc.Report, nodes.Nodes, nodeID, direction, depth, minWeight),
+	})
+}
@@ -0,0 +1,32 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+
+	"context"
+	"github.com/gorilla/mux"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/render/detailed"
+)
+
+// APICompliance is returned by the /api/topology/{name}/compliance handler.
+type APICompliance struct {
+	Topology string `json:"topology"`
+	Compliance
+}
+
+// Observed edges of a topology checked against the policies.
+func handleCompliance(ctx context.Context, renderer render.Renderer, transformer render.Transformer, rc detailed.RenderContext, w http.ResponseWriter, r *http.Request) {
+	if policies == nil {
+		respondWith(w, http.StatusNotFound, fmt.Errorf("no policies configured"))
+		return
+	}
+	topologyID := mux.Vars(r)["topology"]
+	nodes := render.Render(rc.Report, renderer, transformer).Nodes
+	respondWith(w, http.StatusOK, APICompliance{
+		Topology:   topologyID,
+		Compliance: policies.Check(topologyID, detailed.Summaries(rc, nodes), detailed.EdgeWeights(nodes)),
+	})
+}
@@ -0,0 +1,22 @@
+package app
+
+import (
+	"net/http"
+	"time"
+
+	"context"
+
+	"github.com/weaveworks/scope/render/detailed"
+)
+
+// Externally-exposed ports handler
+func makeExposureHandler(rep Reporter) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		rpt, err := rep.Report(ctx, time.Now())
+		if err != nil {
+			respondWith(w, http.StatusInternalServerError, err)
+			return
+		}
+		respondWith(w, http.StatusOK, detailed.ExposedPorts(rpt))
+	}
+}
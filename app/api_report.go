@@ -1,27 +1,69 @@
 package app
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"context"
+	log "github.com/sirupsen/logrus"
+	"github.com/ugorji/go/codec"
 
 	"github.com/weaveworks/scope/probe/host"
 	"github.com/weaveworks/scope/report"
 )
 
-// Raw report handler
+// Raw report handler. The report at a past time can be requested with
+// the timestamp query parameter, and only some of its topologies with
+// the topology parameter, a comma-separated list of topology names. The
+// report is encoded as msgpack when the format parameter, or the Accept
+// header, asks for it, and as JSON otherwise.
 func makeRawReportHandler(rep Reporter) CtxHandlerFunc {
 	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
-		report, err := rep.Report(ctx, time.Now())
+		rpt, err := rep.Report(ctx, deserializeTimestamp(r.FormValue("timestamp")))
 		if err != nil {
 			respondWith(w, http.StatusInternalServerError, err)
 			return
 		}
-		respondWith(w, http.StatusOK, report)
+		if topologies := r.FormValue("topology"); topologies != "" {
+			if rpt, err = filterTopologies(rpt, strings.Split(topologies, ",")); err != nil {
+				respondWith(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		if r.FormValue("format") == "msgpack" || strings.HasPrefix(r.Header.Get("Accept"), "application/msgpack") {
+			w.Header().Set("Content-Type", "application/msgpack")
+			w.Header().Add("Cache-Control", "no-cache")
+			if err := codec.NewEncoder(w, &codec.MsgpackHandle{}).Encode(rpt); err != nil {
+				log.Errorf("Error encoding report: %v", err)
+			}
+			return
+		}
+		respondWith(w, http.StatusOK, rpt)
 	}
 }
 
+// filterTopologies returns the report with only the named topologies.
+func filterTopologies(rpt report.Report, names []string) (report.Report, error) {
+	keep := map[string]bool{}
+	for _, name := range names {
+		if _, ok := rpt.Topology(name); !ok {
+			return rpt, fmt.Errorf("unknown topology %q", name)
+		}
+		keep[name] = true
+	}
+	// The topologies are replaced rather than modified, as the report
+	// may be shared
+	empty := report.MakeReport()
+	rpt.WalkNamedTopologies(func(name string, t *report.Topology) {
+		if !keep[name] {
+			*t, _ = empty.Topology(name)
+		}
+	})
+	return rpt, nil
+}
+
 type probeDesc struct {
 	ID       string    `json:"id"`
 	Hostname string    `json:"hostname"`
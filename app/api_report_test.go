@@ -8,6 +8,7 @@ import (
 	"github.com/ugorji/go/codec"
 
 	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/render/detailed"
 	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/test/fixture"
 )
@@ -33,3 +34,43 @@ func TestAPIReport(t *testing.T) {
 		t.Fatalf("JSON parse error: %s", err)
 	}
 }
+
+func TestAPIReportTopologies(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+
+	is400(t, ts, "/api/report?topology=nope")
+
+	var r map[string]interface{}
+	body := getRawJSON(t, ts, "/api/report?topology=host,process")
+	if err := codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&r); err != nil {
+		t.Fatalf("JSON parse error: %s", err)
+	}
+	nodes := func(topology string) int {
+		t, _ := r[topology].(map[string]interface{})
+		nodes, _ := t["nodes"].(map[string]interface{})
+		return len(nodes)
+	}
+	if nodes("Host") == 0 || nodes("Process") == 0 {
+		t.Errorf("expected the host and process nodes, got %d and %d", nodes("Host"), nodes("Process"))
+	}
+	if n := nodes("Endpoint"); n != 0 {
+		t.Errorf("expected no endpoint nodes, got %d", n)
+	}
+}
+
+func TestAPIExposure(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+
+	body := getRawJSON(t, ts, "/api/exposure")
+	var ports []detailed.ExposedPort
+	decoder := codec.NewDecoderBytes(body, &codec.JsonHandle{})
+	if err := decoder.Decode(&ports); err != nil {
+		t.Fatalf("JSON parse error: %s", err)
+	}
+	// The fixture has no listening sockets
+	if len(ports) != 0 {
+		t.Errorf("expected no exposed ports, got %v", ports)
+	}
+}
@@ -27,14 +27,21 @@ const (
 	containersID           = "containers"
 	containersByHostnameID = "containers-by-hostname"
 	containersByImageID    = "containers-by-image"
+	containersByComposeID  = "containers-by-compose-project"
+	dockerNetworksID       = "docker-networks"
 	podsID                 = "pods"
 	kubeControllersID      = "kube-controllers"
 	servicesID             = "services"
 	hostsID                = "hosts"
 	weaveID                = "weave"
+	interfacesID           = "interfaces"
+	networkDevicesID       = "network-devices"
+	customResourcesID      = "custom-resources"
+	hostsByClusterID       = "hosts-by-cluster"
 	ecsTasksID             = "ecs-tasks"
 	ecsServicesID          = "ecs-services"
 	swarmServicesID        = "swarm-services"
+	serviceMapID           = "service-map"
 )
 
 var (
@@ -72,6 +79,37 @@ func namespaceFilters(namespaces []string, noneLabel string) APITopologyOptionGr
 func updateFilters(rpt report.Report, topologies []APITopologyDesc) []APITopologyDesc {
 	topologies = updateKubeFilters(rpt, topologies)
 	topologies = updateSwarmFilters(rpt, topologies)
+	topologies = updateClusterFilters(rpt, topologies)
+	return topologies
+}
+
+// updateClusterFilters adds a cluster selector to all topologies when
+// the report comes from more than one cluster.
+func updateClusterFilters(rpt report.Report, topologies []APITopologyDesc) []APITopologyDesc {
+	clusters := map[string]struct{}{}
+	for _, n := range rpt.Host.Nodes {
+		if cluster, ok := n.Latest.Lookup(kubernetes.Cluster); ok {
+			clusters[cluster] = struct{}{}
+		}
+	}
+	if len(clusters) < 2 {
+		return topologies
+	}
+	names := []string{}
+	for cluster := range clusters {
+		names = append(names, cluster)
+	}
+	sort.Strings(names)
+	options := APITopologyOptionGroup{ID: "cluster", Default: "", SelectType: "union", NoneLabel: "All Clusters"}
+	for _, cluster := range names {
+		options.Options = append(options.Options, APITopologyOption{
+			Value: cluster, Label: cluster, filter: render.IsCluster(cluster), filterPseudo: false,
+		})
+	}
+	topologies = append([]APITopologyDesc{}, topologies...) // Make a copy so we can make changes safely
+	for i, t := range topologies {
+		topologies[i] = mergeTopologyFilters(t, []APITopologyOptionGroup{options})
+	}
 	return topologies
 }
 
@@ -117,7 +155,7 @@ func updateKubeFilters(rpt report.Report, topologies []APITopologyDesc) []APITop
 	sort.Strings(ns)
 	topologies = append([]APITopologyDesc{}, topologies...) // Make a copy so we can make changes safely
 	for i, t := range topologies {
-		if t.id == containersID || t.id == podsID || t.id == servicesID || t.id == kubeControllersID {
+		if t.id == containersID || t.id == podsID || t.id == servicesID || t.id == kubeControllersID || t.id == customResourcesID {
 			topologies[i] = mergeTopologyFilters(t, []APITopologyOptionGroup{
 				namespaceFilters(ns, "All Namespaces"),
 			})
@@ -213,10 +251,10 @@ func MakeRegistry() *Registry {
 		},
 		APITopologyDesc{
 			id:       containersID,
-			renderer: render.ContainerWithImageNameRenderer,
+			renderer: render.ContainerWithVolumesRenderer,
 			Name:     "Containers",
 			Rank:     2,
-			Options:  containerFilters,
+			Options:  append(containerFilters, storageFilter),
 		},
 		APITopologyDesc{
 			id:       containersByHostnameID,
@@ -232,6 +270,21 @@ func MakeRegistry() *Registry {
 			Name:     "by image",
 			Options:  containerFilters,
 		},
+		APITopologyDesc{
+			id:          containersByComposeID,
+			parent:      containersID,
+			renderer:    render.ComposeProjectRenderer,
+			Name:        "by compose project",
+			Options:     containerFilters,
+			HideIfEmpty: true,
+		},
+		APITopologyDesc{
+			id:          dockerNetworksID,
+			parent:      containersID,
+			renderer:    render.DockerNetworkRenderer,
+			Name:        "by network",
+			HideIfEmpty: true,
+		},
 		APITopologyDesc{
 			id:          podsID,
 			renderer:    render.PodRenderer,
@@ -256,6 +309,13 @@ func MakeRegistry() *Registry {
 			Options:     []APITopologyOptionGroup{unmanagedFilter},
 			HideIfEmpty: true,
 		},
+		APITopologyDesc{
+			id:          customResourcesID,
+			parent:      podsID,
+			renderer:    render.CustomResourceRenderer,
+			Name:        "Custom resources",
+			HideIfEmpty: true,
+		},
 		APITopologyDesc{
 			id:          ecsTasksID,
 			renderer:    render.ECSTaskRenderer,
@@ -280,6 +340,14 @@ func MakeRegistry() *Registry {
 			Options:     []APITopologyOptionGroup{unmanagedFilter},
 			HideIfEmpty: true,
 		},
+		APITopologyDesc{
+			id:          serviceMapID,
+			renderer:    render.ServiceMapRenderer,
+			Name:        "Service map",
+			Rank:        3,
+			Options:     []APITopologyOptionGroup{unmanagedFilter},
+			HideIfEmpty: true,
+		},
 		APITopologyDesc{
 			id:       hostsID,
 			renderer: render.HostRenderer,
@@ -292,6 +360,26 @@ func MakeRegistry() *Registry {
 			renderer: render.WeaveRenderer,
 			Name:     "Weave Net",
 		},
+		APITopologyDesc{
+			id:          hostsByClusterID,
+			parent:      hostsID,
+			renderer:    render.HostClusterRenderer,
+			Name:        "by cluster",
+			HideIfEmpty: true,
+		},
+		APITopologyDesc{
+			id:       interfacesID,
+			parent:   hostsID,
+			renderer: render.NetworkInterfaceRenderer,
+			Name:     "Network interfaces",
+		},
+		APITopologyDesc{
+			id:          networkDevicesID,
+			parent:      hostsID,
+			renderer:    render.NetworkDeviceRenderer,
+			Name:        "Network devices",
+			HideIfEmpty: true,
+		},
 	)
 
 	return registry
@@ -302,6 +390,13 @@ type APITopologyDesc struct {
 	id       string
 	parent   string
 	renderer render.Renderer
+	// pipeline is set for the views of pipelines, which can be replaced
+	pipeline bool
+	// plugin is set for the views of renderer plugins
+	plugin bool
+	// metadataTemplates are added to the topologies of the report the
+	// view is rendered from, by topology
+	metadataTemplates map[string]report.MetadataTemplates
 
 	Name        string                   `json:"name"`
 	Rank        int                      `json:"rank"`
@@ -410,7 +505,7 @@ func AddContainerFilters(newFilters ...APITopologyOption) {
 func (r *Registry) AddContainerFilters(newFilters ...APITopologyOption) {
 	r.Lock()
 	defer r.Unlock()
-	for _, key := range []string{containersID, containersByHostnameID, containersByImageID} {
+	for _, key := range []string{containersID, containersByHostnameID, containersByImageID, containersByComposeID} {
 		for i := range r.items[key].Options {
 			if r.items[key].Options[i].ID == systemGroupID {
 				r.items[key].Options[i].Options = append(r.items[key].Options[i].Options, newFilters...)
@@ -424,6 +519,11 @@ func (r *Registry) AddContainerFilters(newFilters ...APITopologyOption) {
 func (r *Registry) Add(ts ...APITopologyDesc) {
 	r.Lock()
 	defer r.Unlock()
+	r.add(ts...)
+}
+
+// add inserts topologyDescs, with the lock held.
+func (r *Registry) add(ts ...APITopologyDesc) {
 	for _, t := range ts {
 		t.URL = apiTopologyURL + t.id
 		t.renderer = render.Memoise(t.renderer)
@@ -438,6 +538,20 @@ func (r *Registry) Add(ts ...APITopologyDesc) {
 	}
 }
 
+// withMetadataTemplates adds the templates of the view to the report it
+// is rendered from. The topologies of the report given are not changed.
+func (t APITopologyDesc) withMetadataTemplates(rpt report.Report) report.Report {
+	if len(t.metadataTemplates) == 0 {
+		return rpt
+	}
+	rpt.WalkNamedTopologies(func(name string, topology *report.Topology) {
+		if templates, ok := t.metadataTemplates[name]; ok {
+			topology.MetadataTemplates = topology.MetadataTemplates.Merge(templates)
+		}
+	})
+	return rpt
+}
+
 func (r *Registry) get(name string) (APITopologyDesc, bool) {
 	r.RLock()
 	defer r.RUnlock()
@@ -540,6 +654,27 @@ func (r *Registry) RendererForTopology(topologyID string, values url.Values, rpt
 	return topology.renderer, render.FilterUnconnectedPseudo, nil
 }
 
+// renderOptions returns the values of the options of the topology
+// RendererForTopology renders it with, given those of a request: the
+// defaults of those not given, or none at all if the request has no
+// values.
+func (r *Registry) renderOptions(topologyID string, values url.Values, rpt report.Report) url.Values {
+	topology, ok := r.get(topologyID)
+	if !ok || len(values) == 0 {
+		return nil
+	}
+	topology = updateFilters(rpt, []APITopologyDesc{topology})[0]
+	result := url.Values{}
+	for _, group := range topology.Options {
+		value := group.Default
+		if vs := values[group.ID]; len(vs) > 0 {
+			value = vs[0]
+		}
+		result.Set(group.ID, value)
+	}
+	return result
+}
+
 type reporterHandler func(context.Context, Reporter, http.ResponseWriter, *http.Request)
 
 func captureReporter(rep Reporter, f reporterHandler) CtxHandlerFunc {
@@ -554,7 +689,8 @@ func (r *Registry) captureRenderer(rep Reporter, f rendererHandler) CtxHandlerFu
 			topologyID = mux.Vars(req)["topology"]
 			timestamp  = deserializeTimestamp(req.URL.Query().Get("timestamp"))
 		)
-		if _, ok := r.get(topologyID); !ok {
+		desc, ok := r.get(topologyID)
+		if !ok {
 			http.NotFound(w, req)
 			return
 		}
@@ -563,6 +699,7 @@ func (r *Registry) captureRenderer(rep Reporter, f rendererHandler) CtxHandlerFu
 			respondWith(w, http.StatusInternalServerError, err)
 			return
 		}
+		rpt = desc.withMetadataTemplates(rpt)
 		req.ParseForm()
 		renderer, filter, err := r.RendererForTopology(topologyID, req.Form, rpt)
 		if err != nil {
@@ -0,0 +1,23 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/render"
+)
+
+// The views of the render package must be those the app serves, so that
+// programs rendering reports themselves see what users do.
+func TestRenderViewsRegistered(t *testing.T) {
+	registry := MakeRegistry()
+	for _, id := range render.ViewIDs() {
+		if _, ok := registry.get(id); !ok {
+			t.Errorf("expected view %s in the registry", id)
+		}
+	}
+	registry.walk(func(desc APITopologyDesc) {
+		if _, ok := render.View(desc.id); !ok {
+			t.Errorf("expected topology %s in the views of the render package", desc.id)
+		}
+	})
+}
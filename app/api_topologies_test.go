@@ -41,7 +41,7 @@ func TestAPITopology(t *testing.T) {
 	if err := decoder.Decode(&topologies); err != nil {
 		t.Fatalf("JSON parse error: %s", err)
 	}
-	equals(t, 6, len(topologies))
+	equals(t, 7, len(topologies))
 
 	for _, topology := range topologies {
 		is200(t, ts, topology.URL)
@@ -128,6 +128,36 @@ func TestRendererForTopologyWithFiltering(t *testing.T) {
 	}
 }
 
+func TestRendererForTopologyClusterFilter(t *testing.T) {
+	input := fixture.Report.Copy()
+	for id, cluster := range map[string]string{fixture.ClientHostNodeID: "east", fixture.ServerHostNodeID: "west"} {
+		input.Host.Nodes[id] = input.Host.Nodes[id].WithLatests(map[string]string{kubernetes.Cluster: cluster})
+	}
+
+	topologyRegistry := app.MakeRegistry()
+	renderer, filter, err := topologyRegistry.RendererForTopology("hosts", url.Values{"cluster": []string{"east"}}, input)
+	if err != nil {
+		t.Fatalf("Topology Registry Report error: %s", err)
+	}
+	have := render.Render(input, renderer, filter).Nodes
+	if _, ok := have[fixture.ClientHostNodeID]; !ok {
+		t.Errorf("Expected the host of the selected cluster, got %v", have)
+	}
+	if _, ok := have[fixture.ServerHostNodeID]; ok {
+		t.Errorf("Expected no host of other clusters, got %v", have)
+	}
+
+	// With a single cluster, there's nothing to select
+	delete(input.Host.Nodes, fixture.ServerHostNodeID)
+	renderer, filter, err = topologyRegistry.RendererForTopology("hosts", url.Values{"cluster": []string{"west"}}, input)
+	if err != nil {
+		t.Fatalf("Topology Registry Report error: %s", err)
+	}
+	if have := render.Render(input, renderer, filter).Nodes; len(have) == 0 {
+		t.Errorf("Expected the cluster option to be ignored with a single cluster")
+	}
+}
+
 func TestRendererForTopologyNoFiltering(t *testing.T) {
 	ts := topologyServer()
 	defer ts.Close()
@@ -201,7 +231,7 @@ func TestAPITopologyAddsKubernetes(t *testing.T) {
 	if err := decoder.Decode(&topologies); err != nil {
 		t.Fatalf("JSON parse error: %s", err)
 	}
-	equals(t, 6, len(topologies))
+	equals(t, 7, len(topologies))
 
 	// Enable the kubernetes topologies
 	rpt := report.MakeReport()
@@ -235,7 +265,7 @@ func TestAPITopologyAddsKubernetes(t *testing.T) {
 	if err := decoder.Decode(&topologies); err != nil {
 		t.Fatalf("JSON parse error: %s", err)
 	}
-	equals(t, 6, len(topologies))
+	equals(t, 7, len(topologies))
 
 	found := false
 	for _, topology := range topologies {
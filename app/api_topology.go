@@ -1,13 +1,19 @@
 package app
 
 import (
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"context"
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/weaveworks/scope/common/geoip"
+	"github.com/weaveworks/scope/common/iprange"
 	"github.com/weaveworks/scope/common/xfer"
 	"github.com/weaveworks/scope/render"
 	"github.com/weaveworks/scope/render/detailed"
@@ -16,34 +22,243 @@ import (
 
 const (
 	websocketLoop = 1 * time.Second
+	// Viewports reach at most this many hops from their focus
+	maxViewportHops = 10
 )
 
 // APITopology is returned by the /api/topology/{name} handler.
 type APITopology struct {
-	Nodes detailed.NodeSummaries `json:"nodes"`
+	Nodes     detailed.NodeSummaries `json:"nodes"`
+	Anomalies []EdgeAnomaly          `json:"anomalies,omitempty"`
+	// Edges are what is known of the edges touching the nodes, by edge
+	// ID: the connections failed, opened and closed over them, their
+	// latencies, the requests, queries and countries of their traffic
+	// and, in the service map, their traffic whichever replicas carried
+	// it
+	Edges detailed.EdgesAttributes `json:"edges,omitempty"`
+	// RequestVolumes are the requests each service of the service map
+	// received
+	RequestVolumes map[string]int `json:"requestVolumes,omitempty"`
+	// Deployments are those of the hour up to the time of the topology
+	Deployments []Deployment `json:"deployments,omitempty"`
+	// TotalNodes is the count of nodes of every page, and NextCursor the
+	// cursor of the next page, when paginated
+	TotalNodes int    `json:"totalNodes,omitempty"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	// Layout is where to draw the nodes, when asked for with layout=true
+	// and laid out in the app
+	Layout map[string]detailed.Position `json:"layout,omitempty"`
+	// Errors are why topologies of the report are missing or partial, by
+	// topology, e.g. as a probe failed to report containers
+	Errors map[string]string `json:"errors,omitempty"`
 }
 
 // APINode is returned by the /api/topology/{name}/{id} handler.
 type APINode struct {
-	Node detailed.Node `json:"node"`
+	Node        detailed.Node `json:"node"`
+	Annotations []Annotation  `json:"annotations,omitempty"`
+}
+
+// asns names the autonomous systems of external addresses in the details
+// of internet nodes. It is nil (and names disabled) by default.
+var asns *iprange.Table
+
+// EnableASNs turns on naming the autonomous systems of external
+// addresses, from the given table.
+func EnableASNs(t *iprange.Table) {
+	asns = t
+}
+
+// geoIP locates external addresses in the details of internet nodes and
+// in the topology API, flagging the connections to countries not
+// expected. It is nil (and locations disabled) by default.
+var (
+	geoIP             geoip.DBs
+	expectedCountries map[string]bool
+)
+
+// EnableGeoIP turns on locating external addresses with the given
+// databases. If any countries are given, connections to others are
+// flagged as unexpected.
+func EnableGeoIP(dbs geoip.DBs, countries []string) {
+	geoIP = dbs
+	expectedCountries = map[string]bool{}
+	for _, country := range countries {
+		expectedCountries[strings.ToUpper(strings.TrimSpace(country))] = true
+	}
 }
 
 // RenderContextForReporter creates the rendering context for the given reporter.
 func RenderContextForReporter(rep Reporter, r report.Report) detailed.RenderContext {
-	rc := detailed.RenderContext{Report: r}
+	rc := detailed.RenderContext{Report: r, ASNs: asns, GeoIP: geoIP}
 	if wrep, ok := rep.(WebReporter); ok {
 		rc.MetricsGraphURL = wrep.MetricsGraphURL
 	}
 	return rc
 }
 
+// parseViewport parses the node a view is focused on, if any, and how
+// many hops of its neighbourhood to render, one by default.
+func parseViewport(values url.Values) (render.Viewport, error) {
+	viewport := render.Viewport{Focus: values.Get("focus"), Hops: 1}
+	if h := values.Get("hops"); h != "" {
+		hops, err := strconv.Atoi(h)
+		if err != nil || hops < 0 || hops > maxViewportHops {
+			return viewport, fmt.Errorf("invalid hops '%s', must be 0..%d", h, maxViewportHops)
+		}
+		viewport.Hops = hops
+	}
+	return viewport, nil
+}
+
 type rendererHandler func(context.Context, render.Renderer, render.Transformer, detailed.RenderContext, http.ResponseWriter, *http.Request)
 
 // Full topology.
 func handleTopology(ctx context.Context, renderer render.Renderer, transformer render.Transformer, rc detailed.RenderContext, w http.ResponseWriter, r *http.Request) {
-	respondWith(w, http.StatusOK, APITopology{
-		Nodes: detailed.Summaries(rc, render.Render(rc.Report, renderer, transformer).Nodes),
-	})
+	topologyID := mux.Vars(r)["topology"]
+	fields, err := detailed.ParseFields(r.FormValue("fields"))
+	if err != nil {
+		respondWith(w, http.StatusBadRequest, err)
+		return
+	}
+	viewport, err := parseViewport(r.Form)
+	if err != nil {
+		respondWith(w, http.StatusBadRequest, err)
+		return
+	}
+	limit := 0
+	if l := r.FormValue("limit"); l != "" {
+		if limit, err = strconv.Atoi(l); err != nil || limit < 0 {
+			respondWith(w, http.StatusBadRequest, fmt.Errorf("invalid limit '%s'", l))
+			return
+		}
+	}
+	nodes := render.Render(rc.Report, renderer, render.Transformers{transformer, viewport}).Nodes
+	if viewport.Focus != "" {
+		if _, ok := nodes[viewport.Focus]; !ok {
+			http.NotFound(w, r)
+			return
+		}
+	}
+	// A page is of the nodes, and the edges touching them
+	var (
+		topology  = APITopology{Errors: rc.Report.Errors}
+		page      = nodes
+		edgeNodes = nodes
+		paged     = limit > 0 || r.FormValue("cursor") != ""
+	)
+	if paged {
+		topology.TotalNodes = len(nodes)
+		if page, topology.NextCursor, err = detailed.Page(nodes, r.FormValue("cursor"), limit); err != nil {
+			respondWith(w, http.StatusBadRequest, err)
+			return
+		}
+		edgeNodes = detailed.PageEdgeNodes(nodes, page)
+	}
+	topology.Nodes = detailed.Summaries(rc, page)
+	topology.Edges = topologyEdges(rc, topologyID, edgeNodes)
+	if topologyID == serviceMapID {
+		topology.RequestVolumes = detailed.RequestVolumes(detailed.ServiceEdges(edgeNodes))
+	}
+	if edgeBaselines != nil {
+		// Baselines are of the view rendered with the options of the
+		// request, updated from the present only, and of every edge,
+		// whichever are paged
+		options := topologyRegistry.renderOptions(topologyID, r.Form, rc.Report)
+		if r.FormValue("timestamp") == "" {
+			edgeBaselines.Watch(topologyID, options)
+		}
+		topology.Anomalies = edgeBaselines.Anomalies(topologyID, options, renderedEdgeMetrics(nodes))
+		if paged {
+			topology.Anomalies = anomaliesTouching(topology.Anomalies, page)
+		}
+		// Anomalies are suppressed by the windows at the time of the
+		// report, not of the request
+		timestamp := deserializeTimestamp(r.FormValue("timestamp"))
+		if underMaintenance := maintenanceMatcher(ctx, topologyID, timestamp, timestamp); underMaintenance != nil {
+			summaries := topology.Nodes
+			if paged {
+				summaries = detailed.Summaries(rc, edgeNodes)
+			}
+			topology.Anomalies = suppressAnomalies(topology.Anomalies, summaries, underMaintenance)
+		}
+	}
+	if deployments != nil {
+		timestamp := deserializeTimestamp(r.FormValue("timestamp"))
+		topology.Deployments = deploymentsBetween(ctx, timestamp.Add(-deploymentLookback), timestamp)
+	}
+	if layouts != nil && r.FormValue("layout") == "true" {
+		// Nodes are laid out in the whole view, even if only a page of
+		// them is returned
+		if positions := layouts.Layout(topologyID, nodes); positions != nil {
+			topology.Layout = make(map[string]detailed.Position, len(topology.Nodes))
+			for id := range topology.Nodes {
+				topology.Layout[id] = positions[id]
+			}
+		}
+	}
+	topology.Nodes = fields.Apply(topology.Nodes)
+	respondWith(w, http.StatusOK, topology)
+}
+
+// topologyEdges gathers what is known of the edges between the rendered
+// nodes, by edge ID, or returns nil if nothing is.
+func topologyEdges(rc detailed.RenderContext, topologyID string, ns report.Nodes) detailed.EdgesAttributes {
+	edges := detailed.EdgesAttributes{}
+	for _, e := range detailed.FailedEdges(ns) {
+		e := e
+		edges.Of(e.Edge).Failed = &e
+	}
+	for _, e := range detailed.LatencyEdges(ns) {
+		e := e
+		edges.Of(e.Edge).Latency = &e
+	}
+	for _, e := range detailed.ChurnEdges(ns) {
+		e := e
+		edges.Of(e.Edge).Churn = &e
+	}
+	for _, e := range detailed.ConnectionLatencyEdges(ns) {
+		e := e
+		edges.Of(e.Edge).ConnectionLatency = &e
+	}
+	for _, e := range detailed.RequestEdges(ns) {
+		e := e
+		edges.Of(e.Edge).Requests = &e
+	}
+	for _, e := range detailed.DatabaseEdges(ns) {
+		e := e
+		edges.Of(e.Edge).Database = &e
+	}
+	if geoIP != nil {
+		for _, e := range detailed.CountryEdges(rc, ns, expectedCountries) {
+			attributes := edges.Of(e.Edge)
+			attributes.Countries = append(attributes.Countries, e)
+		}
+	}
+	if topologyID == serviceMapID {
+		for _, e := range detailed.ServiceEdges(ns) {
+			e := e
+			edges.Of(e.Edge).Service = &e
+		}
+	}
+	if len(edges) == 0 {
+		return nil
+	}
+	return edges
+}
+
+// anomaliesTouching returns the anomalies of the edges from or to the
+// nodes.
+func anomaliesTouching(anomalies []EdgeAnomaly, nodes report.Nodes) []EdgeAnomaly {
+	var result []EdgeAnomaly
+	for _, a := range anomalies {
+		_, src := nodes[a.Source]
+		_, dst := nodes[a.Target]
+		if src || dst {
+			result = append(result, a)
+		}
+	}
+	return result
 }
 
 // Individual nodes.
@@ -61,8 +276,13 @@ func handleNode(ctx context.Context, renderer render.Renderer, transformer rende
 	nodes := renderer.Render(rc.Report)
 	node, ok := nodes.Nodes[nodeID]
 	if !ok {
-		http.NotFound(w, r)
-		return
+		// Nodes which restarted, e.g. processes with a new PID, have
+		// another ID at other times, but the same stable ID
+		if node, ok = nodeByStableID(nodes.Nodes, r.FormValue("stableId")); !ok {
+			http.NotFound(w, r)
+			return
+		}
+		nodeID = node.ID
 	}
 	nodes = transformer.Transform(nodes)
 	if filteredNode, ok := nodes.Nodes[nodeID]; ok {
@@ -71,7 +291,29 @@ func handleNode(ctx context.Context, renderer render.Renderer, transformer rende
 		nodes.Nodes[nodeID] = node
 		nodes.Filtered--
 	}
-	respondWith(w, http.StatusOK, APINode{Node: detailed.MakeNode(topologyID, rc, nodes.Nodes, node)})
+	details := detailed.MakeNode(topologyID, rc, nodes.Nodes, node)
+	if catalog != nil {
+		if o, ok := catalog.Owner(node, details.Label); ok {
+			details.Metadata = append(o.metadataRows(), details.Metadata...)
+		}
+	}
+	respondWith(w, http.StatusOK, APINode{
+		Node:        details,
+		Annotations: nodeAnnotations(ctx, topologyID, details.BasicNodeSummary),
+	})
+}
+
+// nodeByStableID returns the node with a stable ID, if any.
+func nodeByStableID(nodes report.Nodes, stableID string) (report.Node, bool) {
+	if stableID == "" {
+		return report.Node{}, false
+	}
+	for _, n := range nodes {
+		if id, ok := n.Latest.Lookup(report.StableID); ok && id == stableID {
+			return n, true
+		}
+	}
+	return report.Node{}, false
 }
 
 // Websocket for the full topology.
@@ -93,6 +335,16 @@ func handleWebsocket(
 			return
 		}
 	}
+	fields, err := detailed.ParseFields(r.Form.Get("fields"))
+	if err != nil {
+		respondWith(w, http.StatusBadRequest, err)
+		return
+	}
+	viewport, err := parseViewport(r.Form)
+	if err != nil {
+		respondWith(w, http.StatusBadRequest, err)
+		return
+	}
 
 	conn, err := xfer.Upgrade(w, r, nil)
 	if err != nil {
@@ -115,16 +367,17 @@ func handleWebsocket(
 	}(conn)
 
 	var (
-		previousTopo     detailed.NodeSummaries
 		tick             = time.Tick(loop)
 		wait             = make(chan struct{}, 1)
 		topologyID       = mux.Vars(r)["topology"]
+		writer           = newDiffWriter(conn, topologyID, websocketMaxStaleness)
 		startReportingAt = deserializeTimestamp(r.Form.Get("timestamp"))
 		channelOpenedAt  = time.Now()
 	)
 
 	rep.WaitOn(ctx, wait)
 	defer rep.UnWait(ctx, wait)
+	defer writer.Stop()
 
 	for {
 		// We measure how much time has passed since the channel was opened
@@ -140,18 +393,21 @@ func handleWebsocket(
 			log.Errorf("Error generating report: %v", err)
 			return
 		}
+		if desc, ok := topologyRegistry.get(topologyID); ok {
+			re = desc.withMetadataTemplates(re)
+		}
 		renderer, filter, err := topologyRegistry.RendererForTopology(topologyID, r.Form, re)
 		if err != nil {
 			log.Errorf("Error generating report: %v", err)
 			return
 		}
-		newTopo := detailed.Summaries(RenderContextForReporter(rep, re), render.Render(re, renderer, filter).Nodes)
-		diff := detailed.TopoDiff(previousTopo, newTopo)
-		previousTopo = newTopo
+		newTopo := fields.Apply(detailed.Summaries(RenderContextForReporter(rep, re), render.Render(re, renderer, render.Transformers{filter, viewport}).Nodes))
 
-		if err := conn.WriteJSON(diff); err != nil {
+		// The diff is written by the writer, coalesced with those
+		// following if the client is slow to read them
+		if err := writer.Write(newTopo); err != nil {
 			if !xfer.IsExpectedWSCloseError(err) {
-				log.Errorf("cannot serialize topology diff: %s", err)
+				log.Errorf("cannot write topology diff: %s", err)
 			}
 			return
 		}
@@ -159,6 +415,7 @@ func handleWebsocket(
 		select {
 		case <-wait:
 		case <-tick:
+		case <-writer.Done():
 		case <-quit:
 			return
 		}
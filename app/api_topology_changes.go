@@ -0,0 +1,142 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"context"
+	"github.com/gorilla/mux"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/render/detailed"
+)
+
+const defaultMetricShiftThreshold = 0.5
+
+// APITopologyChanges is returned by the /api/topology/{name}/changes handler.
+type APITopologyChanges struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	detailed.Changes
+	// Suppressed is the number of changes to nodes under maintenance
+	// left out
+	Suppressed int `json:"suppressed,omitempty"`
+	// Deployments are those from..to, which may explain the changes
+	Deployments []Deployment `json:"deployments,omitempty"`
+}
+
+// parseTimestamp parses an optional ISO8601 query param, defaulting to now.
+func parseTimestamp(value string) (time.Time, error) {
+	if value == "" {
+		return time.Now(), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp '%s': %v", value, err)
+	}
+	return t, nil
+}
+
+// changesQuery is the query of the changes to a topology, and of the
+// comparisons of a topology, between two points in time.
+type changesQuery struct {
+	from, to           time.Time
+	threshold          float64
+	includeMaintenance bool
+	override           bool // of the query limits
+	// values are the render options, without ours
+	values url.Values
+}
+
+// parseChangesQuery parses the from, to, threshold and maintenance
+// params. To defaults to now, and from is required.
+func parseChangesQuery(req *http.Request) (changesQuery, error) {
+	var q changesQuery
+	if err := req.ParseForm(); err != nil {
+		return q, err
+	}
+	if req.Form.Get("from") == "" {
+		return q, fmt.Errorf("missing 'from' timestamp")
+	}
+	var err error
+	if q.from, err = parseTimestamp(req.Form.Get("from")); err != nil {
+		return q, err
+	}
+	if q.to, err = parseTimestamp(req.Form.Get("to")); err != nil {
+		return q, err
+	}
+	q.threshold = defaultMetricShiftThreshold
+	if t := req.Form.Get("threshold"); t != "" {
+		if q.threshold, err = strconv.ParseFloat(t, 64); err != nil || q.threshold < 0 {
+			return q, fmt.Errorf("invalid threshold '%s'", t)
+		}
+	}
+
+	// The render options must not be interpreted as a timestamp
+	// or threshold, so drop ours before selecting the renderer.
+	q.values = req.Form
+	q.values.Del("from")
+	q.values.Del("to")
+	q.values.Del("threshold")
+	q.includeMaintenance = q.values.Get("maintenance") == "include"
+	q.values.Del("maintenance")
+	q.override = q.values.Get("override") == "true"
+	q.values.Del("override")
+	return q, nil
+}
+
+// summariesAt renders the summaries of a topology at a point in time.
+func (r *Registry) summariesAt(ctx context.Context, rep Reporter, topologyID string, values url.Values, timestamp time.Time) (detailed.NodeSummaries, error) {
+	rpt, err := rep.Report(ctx, timestamp)
+	if err != nil {
+		return nil, err
+	}
+	renderer, filter, err := r.RendererForTopology(topologyID, values, rpt)
+	if err != nil {
+		return nil, err
+	}
+	return detailed.Summaries(RenderContextForReporter(rep, rpt), render.Render(rpt, renderer, filter).Nodes), nil
+}
+
+// Changes to a topology between two points in time.
+func (r *Registry) makeTopologyChangesHandler(rep Reporter) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+		topologyID := mux.Vars(req)["topology"]
+		if _, ok := r.get(topologyID); !ok {
+			http.NotFound(w, req)
+			return
+		}
+		q, err := parseChangesQuery(req)
+		if err != nil {
+			respondWith(w, http.StatusBadRequest, err)
+			return
+		}
+		if code, err := checkQueryCost(ctx, req, []time.Time{q.from, q.to}, q.override); err != nil {
+			respondWith(w, code, err)
+			return
+		}
+		before, err := r.summariesAt(ctx, rep, topologyID, q.values, q.from)
+		if err != nil {
+			respondWith(w, http.StatusInternalServerError, err)
+			return
+		}
+		after, err := r.summariesAt(ctx, rep, topologyID, q.values, q.to)
+		if err != nil {
+			respondWith(w, http.StatusInternalServerError, err)
+			return
+		}
+		result := APITopologyChanges{
+			From:        q.from,
+			To:          q.to,
+			Changes:     detailed.CompareSummaries(before, after, q.threshold),
+			Deployments: deploymentsBetween(ctx, q.from, q.to),
+		}
+		if underMaintenance := maintenanceMatcher(ctx, topologyID, q.from, q.to); underMaintenance != nil && !q.includeMaintenance {
+			result.Changes, result.Suppressed = suppressChanges(result.Changes, before, after, underMaintenance)
+		}
+		respondWith(w, http.StatusOK, result)
+	}
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"net/http"
+	"time"
+
+	"context"
+	"github.com/gorilla/mux"
+
+	"github.com/weaveworks/scope/render/detailed"
+)
+
+// APITopologyComparison is returned by the /api/topology/{name}/compare
+// handler.
+type APITopologyComparison struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	detailed.Comparison
+	// Suppressed is the number of changes to nodes under maintenance
+	// left out
+	Suppressed int `json:"suppressed,omitempty"`
+	// Deployments are those from..to, e.g. the one compared before and
+	// after
+	Deployments []Deployment `json:"deployments,omitempty"`
+}
+
+// A topology at two points in time, as a single graph with the nodes and
+// edges added, removed and changed between them marked, e.g. to show a
+// topology before and after a deploy. To defaults to now.
+func (r *Registry) makeTopologyComparisonHandler(rep Reporter) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, req *http.Request) {
+		topologyID := mux.Vars(req)["topology"]
+		if _, ok := r.get(topologyID); !ok {
+			http.NotFound(w, req)
+			return
+		}
+		q, err := parseChangesQuery(req)
+		if err != nil {
+			respondWith(w, http.StatusBadRequest, err)
+			return
+		}
+		if code, err := checkQueryCost(ctx, req, []time.Time{q.from, q.to}, q.override); err != nil {
+			respondWith(w, code, err)
+			return
+		}
+		before, err := r.summariesAt(ctx, rep, topologyID, q.values, q.from)
+		if err != nil {
+			respondWith(w, http.StatusInternalServerError, err)
+			return
+		}
+		after, err := r.summariesAt(ctx, rep, topologyID, q.values, q.to)
+		if err != nil {
+			respondWith(w, http.StatusInternalServerError, err)
+			return
+		}
+		var (
+			changes    = detailed.CompareSummaries(before, after, q.threshold)
+			suppressed = 0
+		)
+		if underMaintenance := maintenanceMatcher(ctx, topologyID, q.from, q.to); underMaintenance != nil && !q.includeMaintenance {
+			changes, suppressed = suppressChanges(changes, before, after, underMaintenance)
+		}
+		respondWith(w, http.StatusOK, APITopologyComparison{
+			From:        q.from,
+			To:          q.to,
+			Comparison:  detailed.CompareChanges(before, after, changes),
+			Suppressed:  suppressed,
+			Deployments: deploymentsBetween(ctx, q.from, q.to),
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package app
+
+import (
+	"net/http"
+
+	"context"
+	"github.com/gorilla/mux"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/render/detailed"
+)
+
+// APITopologyStats is returned by the /api/topology/{name}/stats handler.
+type APITopologyStats struct {
+	topologyStats
+	// Categories are the counts of the nodes matching each filter option,
+	// by option group and then value, whatever the options picked
+	Categories map[string]map[string]int `json:"categories"`
+	Traffic    detailed.Traffic          `json:"traffic"`
+}
+
+// Summary statistics of a topology, for the badges and headers of
+// clients which don't show the whole topology.
+func handleTopologyStats(ctx context.Context, renderer render.Renderer, transformer render.Transformer, rc detailed.RenderContext, w http.ResponseWriter, r *http.Request) {
+	desc, ok := topologyRegistry.get(mux.Vars(r)["topology"])
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	desc = updateFilters(rc.Report, []APITopologyDesc{desc})[0]
+
+	var (
+		unfiltered = renderer.Render(rc.Report).Nodes
+		nodes      = transformer.Transform(render.Nodes{Nodes: unfiltered})
+		stats      = APITopologyStats{Categories: map[string]map[string]int{}}
+	)
+	for _, n := range nodes.Nodes {
+		stats.NodeCount++
+		if n.Topology != render.Pseudo {
+			stats.NonpseudoNodeCount++
+		}
+		stats.EdgeCount += len(n.Adjacency)
+	}
+	stats.FilteredNodes = nodes.Filtered
+	for _, group := range desc.Options {
+		counts := map[string]int{}
+		for _, option := range group.Options {
+			counts[option.Value] = 0
+			for _, n := range unfiltered {
+				if n.Topology != render.Pseudo && (option.filter == nil || option.filter(n)) {
+					counts[option.Value]++
+				}
+			}
+		}
+		stats.Categories[group.ID] = counts
+	}
+	stats.Traffic = detailed.TotalTraffic(nodes.Nodes)
+	respondWith(w, http.StatusOK, stats)
+}
@@ -2,15 +2,19 @@ package app_test
 
 import (
 	"fmt"
+	"net/http/httptest"
 	"net/url"
 	"testing"
 
+	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
 	"github.com/ugorji/go/codec"
 
 	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/probe/host"
 	"github.com/weaveworks/scope/render/detailed"
 	"github.com/weaveworks/scope/render/expected"
+	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/test/fixture"
 )
 
@@ -84,6 +88,27 @@ func TestAPITopologyProcesses(t *testing.T) {
 	}
 }
 
+func TestAPITopologyNodeByStableID(t *testing.T) {
+	rpt := fixture.Report.Copy()
+	rpt.Process.Nodes[fixture.ServerProcessNodeID] = rpt.Process.Nodes[fixture.ServerProcessNodeID].WithLatest(report.StableID, fixture.Now, "systemd/apache")
+	router := mux.NewRouter()
+	app.RegisterTopologyRoutes(router, app.StaticCollector(rpt), map[string]bool{})
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	// The process restarted with another PID since
+	restarted := url.QueryEscape(report.MakeProcessNodeID(fixture.ServerHostID, "1"))
+	is404(t, ts, "/api/topology/processes/"+restarted)
+	is404(t, ts, "/api/topology/processes/"+restarted+"?stableId=systemd/nginx")
+	body := getRawJSON(t, ts, "/api/topology/processes/"+restarted+"?stableId=systemd/apache")
+	var node app.APINode
+	if err := codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&node); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, fixture.ServerProcessNodeID, node.Node.ID)
+	equals(t, "systemd/apache", node.Node.StableID)
+}
+
 func TestAPITopologyHosts(t *testing.T) {
 	ts := topologyServer()
 	defer ts.Close()
@@ -117,7 +142,217 @@ func TestAPITopologyHosts(t *testing.T) {
 	}
 }
 
+func TestAPITopologyChanges(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+	is404(t, ts, "/api/topology/foobar/changes?from=2017-01-01T00:00:00Z")
+	is400(t, ts, "/api/topology/processes/changes")
+	is400(t, ts, "/api/topology/processes/changes?from=yesterday")
+
+	// The static collector always returns the same report, so nothing changes
+	body := getRawJSON(t, ts, "/api/topology/processes/changes?from=2017-01-01T00:00:00Z")
+	var changes app.APITopologyChanges
+	decoder := codec.NewDecoderBytes(body, &codec.JsonHandle{})
+	if err := decoder.Decode(&changes); err != nil {
+		t.Fatalf("JSON parse error: %s", err)
+	}
+	equals(t, 0, len(changes.Added))
+	equals(t, 0, len(changes.Removed))
+	equals(t, 0, len(changes.AddedEdges))
+	equals(t, 0, len(changes.RemovedEdges))
+}
+
+func TestAPITopologyComparison(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+	is404(t, ts, "/api/topology/foobar/compare?from=2017-01-01T00:00:00Z")
+	is400(t, ts, "/api/topology/processes/compare")
+	is400(t, ts, "/api/topology/processes/compare?from=2017-01-01T00:00:00Z&threshold=-1")
+
+	// The static collector always returns the same report, so everything
+	// is unchanged
+	body := getRawJSON(t, ts, "/api/topology/processes/compare?from=2017-01-01T00:00:00Z")
+	var comparison app.APITopologyComparison
+	decoder := codec.NewDecoderBytes(body, &codec.JsonHandle{})
+	if err := decoder.Decode(&comparison); err != nil {
+		t.Fatalf("JSON parse error: %s", err)
+	}
+	if len(comparison.Nodes) == 0 {
+		t.Fatal("expected nodes")
+	}
+	for _, n := range comparison.Nodes {
+		equals(t, detailed.Unchanged, n.Status)
+	}
+	for _, e := range comparison.Edges {
+		equals(t, detailed.Unchanged, e.Status)
+	}
+}
+
+func TestAPITopologyServiceMap(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+
+	body := getRawJSON(t, ts, "/api/topology/service-map")
+	var topology app.APITopology
+	decoder := codec.NewDecoderBytes(body, &codec.JsonHandle{})
+	if err := decoder.Decode(&topology); err != nil {
+		t.Fatalf("JSON parse error: %s", err)
+	}
+	if _, ok := topology.Nodes[fixture.ServiceNodeID]; !ok {
+		t.Errorf("expected the service in the service map, have %v", topology.Nodes)
+	}
+	edge := detailed.Edge{Source: fixture.ServiceNodeID, Target: fixture.ServiceNodeID}
+	if e, ok := topology.Edges[edge.ID()]; !ok || e.Service == nil || e.Service.Weight <= 0 {
+		t.Errorf("expected the connections between the replicas of the service, have %v", topology.Edges)
+	}
+}
+
+func TestAPITopologyFields(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+	is400(t, ts, "/api/topology/hosts?fields=nonesuch")
+
+	body := getRawJSON(t, ts, "/api/topology/hosts?fields=label,adjacency,metrics:"+host.CPUUsage)
+	var topology app.APITopology
+	decoder := codec.NewDecoderBytes(body, &codec.JsonHandle{})
+	if err := decoder.Decode(&topology); err != nil {
+		t.Fatalf("JSON parse error: %s", err)
+	}
+	node, ok := topology.Nodes[fixture.ClientHostNodeID]
+	if !ok {
+		t.Fatalf("expected the client host, have %v", topology.Nodes)
+	}
+	if node.Label == "" || node.Rank != "" || len(node.Metadata) != 0 || len(node.Parents) != 0 {
+		t.Errorf("expected only the fields asked for, have %v", node)
+	}
+	for _, m := range node.Metrics {
+		if m.ID != host.CPUUsage {
+			t.Errorf("expected only the metric asked for, have %v", node.Metrics)
+		}
+	}
+}
+
+func TestAPITopologyPagination(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+	is400(t, ts, "/api/topology/processes?limit=-1")
+	is400(t, ts, "/api/topology/processes?cursor=not+a+cursor")
+
+	seen := map[string]bool{}
+	path := "/api/topology/processes?limit=1"
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatal("expected the pages to end")
+		}
+		body := getRawJSON(t, ts, path)
+		var topology app.APITopology
+		decoder := codec.NewDecoderBytes(body, &codec.JsonHandle{})
+		if err := decoder.Decode(&topology); err != nil {
+			t.Fatalf("JSON parse error: %s", err)
+		}
+		if len(topology.Nodes) != 1 {
+			t.Fatalf("expected a node per page, have %v", topology.Nodes)
+		}
+		for id := range topology.Nodes {
+			if seen[id] {
+				t.Errorf("expected %s once", id)
+			}
+			seen[id] = true
+		}
+		if topology.NextCursor == "" {
+			if len(seen) != topology.TotalNodes {
+				t.Errorf("expected %d nodes, have %d", topology.TotalNodes, len(seen))
+			}
+			break
+		}
+		path = "/api/topology/processes?limit=1&cursor=" + topology.NextCursor
+	}
+}
+
+func TestAPITopologyViewport(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+	is400(t, ts, "/api/topology/processes?focus=x&hops=100")
+	is404(t, ts, "/api/topology/processes?focus=nonesuch")
+
+	body := getRawJSON(t, ts, "/api/topology/processes?hops=0&focus="+url.QueryEscape(fixture.ServerProcessNodeID))
+	var topology app.APITopology
+	decoder := codec.NewDecoderBytes(body, &codec.JsonHandle{})
+	if err := decoder.Decode(&topology); err != nil {
+		t.Fatalf("JSON parse error: %s", err)
+	}
+	if _, ok := topology.Nodes[fixture.ServerProcessNodeID]; !ok || len(topology.Nodes) != 1 {
+		t.Errorf("expected only the node in focus, have %v", topology.Nodes)
+	}
+
+	body = getRawJSON(t, ts, "/api/topology/processes?focus="+url.QueryEscape(fixture.ServerProcessNodeID))
+	if err := codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&topology); err != nil {
+		t.Fatalf("JSON parse error: %s", err)
+	}
+	if _, ok := topology.Nodes[fixture.ClientProcess1NodeID]; !ok {
+		t.Errorf("expected the clients of the node in focus, have %v", topology.Nodes)
+	}
+}
+
+func TestAPITopologyStats(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+	is404(t, ts, "/api/topology/nonesuch/stats")
+
+	body := getRawJSON(t, ts, "/api/topology/containers/stats")
+	var stats app.APITopologyStats
+	decoder := codec.NewDecoderBytes(body, &codec.JsonHandle{})
+	if err := decoder.Decode(&stats); err != nil {
+		t.Fatalf("JSON parse error: %s", err)
+	}
+	if stats.NodeCount == 0 || stats.EdgeCount == 0 {
+		t.Errorf("expected nodes and edges, have %v", stats)
+	}
+	if running := stats.Categories["stopped"]["running"]; running == 0 || running > stats.NodeCount {
+		t.Errorf("expected the count of running containers, have %v", stats.Categories)
+	}
+	if stats.Traffic.Connections == 0 {
+		t.Errorf("expected the connections between containers, have %v", stats.Traffic)
+	}
+}
+
+func TestAPITopologyBlastRadius(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+	is404(t, ts, "/api/topology/processes/foobar/blast-radius")
+	is400(t, ts, "/api/topology/processes/"+url.QueryEscape(fixture.ServerProcessNodeID)+"/blast-radius?direction=sideways")
+
+	body := getRawJSON(t, ts, "/api/topology/processes/"+url.QueryEscape(fixture.ServerProcessNodeID)+"/blast-radius?direction=upstream")
+	var impact app.APIBlastRadius
+	decoder := codec.NewDecoderBytes(body, &codec.JsonHandle{})
+	if err := decoder.Decode(&impact); err != nil {
+		t.Fatalf("JSON parse error: %s", err)
+	}
+	equals(t, fixture.ServerProcessNodeID, impact.ID)
+	equals(t, "upstream", impact.Direction)
+	equals(t, 3, len(impact.Nodes))
+}
+
 // Basic websocket test
+func TestAPITopologyErrors(t *testing.T) {
+	rpt := fixture.Report.Copy()
+	rpt.AddError(report.Container, "host foo: Docker: connection refused")
+	router := mux.NewRouter().SkipClean(true)
+	app.RegisterTopologyRoutes(router, app.StaticCollector(rpt), nil)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	body := getRawJSON(t, ts, "/api/topology/containers")
+	var topology app.APITopology
+	if err := codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&topology); err != nil {
+		t.Fatal(err)
+	}
+	equals(t, map[string]string{report.Container: "host foo: Docker: connection refused"}, topology.Errors)
+	if len(topology.Nodes) == 0 {
+		t.Error("expected the containers reported in spite of the errors")
+	}
+}
+
 func TestAPITopologyWebsocket(t *testing.T) {
 	ts := topologyServer()
 	defer ts.Close()
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/render/detailed"
+)
+
+// The routes of v1 of the API are the unversioned ones, e.g.
+// /api/topology, also served under /api/v1. Those of v2 are under
+// /api/v2, and are those of v1 but for the routes v2 changes.
+const (
+	defaultPageLimit = 500
+	maxPageLimit     = 5000
+)
+
+// APIV1Sunset is when the routes of v1 which v2 changes are due to be
+// removed, as advertised in their Sunset headers.
+var APIV1Sunset = time.Date(2027, time.June, 30, 0, 0, 0, 0, time.UTC)
+
+var apiVersionRe = regexp.MustCompile(`^/api/v[12]`)
+
+// v2Routes are the routes v2 changes, and those of v1 they supersede.
+var v2Routes = []struct {
+	method, v1, v2 string
+}{
+	{"GET", "/api/topology/{topology}", "/api/v2/topology/{topology}"},
+}
+
+// APIVersions wraps the handler of the app to serve the versions of the
+// API: it routes the requests of /api/v1, and those of /api/v2 which v2
+// doesn't change, to the unversioned routes, and flags the routes of v1
+// which v2 changes as deprecated. It has to wrap the authenticator, which
+// checks the unversioned paths.
+func APIVersions(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path + "/"
+		switch {
+		case strings.HasPrefix(path, "/api/v2/"):
+			for _, route := range v2Routes {
+				if _, ok := matchURL(r, route.v2); ok && r.Method == route.method {
+					h.ServeHTTP(w, r)
+					return
+				}
+			}
+			if err := unversion(r); err != nil {
+				respondWith(w, http.StatusBadRequest, err)
+				return
+			}
+			h.ServeHTTP(w, r)
+			return
+		case strings.HasPrefix(path, "/api/v1/"):
+			if err := unversion(r); err != nil {
+				respondWith(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		for _, route := range v2Routes {
+			if _, ok := matchURL(r, route.v1); ok && r.Method == route.method {
+				w.Header().Set("Deprecation", "true")
+				w.Header().Set("Sunset", APIV1Sunset.Format(http.TimeFormat))
+				w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", "/api/v2"+strings.TrimPrefix(r.URL.EscapedPath(), "/api")))
+				break
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// unversion rewrites the path of a request of a version of the API to the
+// unversioned route, as middleware.PathRewrite does.
+func unversion(r *http.Request) error {
+	r.RequestURI = apiVersionRe.ReplaceAllString(r.RequestURI, "/api")
+	r.URL.RawPath = apiVersionRe.ReplaceAllString(r.URL.EscapedPath(), "/api")
+	path, err := url.PathUnescape(r.URL.RawPath)
+	if err != nil {
+		return err
+	}
+	r.URL.Path = path
+	return nil
+}
+
+// handleTopologyV2 renders a view as v1 does, but a page of it at a time,
+// of up to 500 nodes by default.
+func handleTopologyV2(ctx context.Context, renderer render.Renderer, transformer render.Transformer, rc detailed.RenderContext, w http.ResponseWriter, r *http.Request) {
+	limit := defaultPageLimit
+	if l := r.FormValue("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n < 0 || n > maxPageLimit {
+			respondWith(w, http.StatusBadRequest, fmt.Errorf("invalid limit '%s', of up to %d nodes", l, maxPageLimit))
+			return
+		}
+		if n > 0 {
+			limit = n
+		}
+	}
+	r.Form.Set("limit", strconv.Itoa(limit))
+	handleTopology(ctx, renderer, transformer, rc, w, r)
+}
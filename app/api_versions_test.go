@@ -0,0 +1,165 @@
+package app_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/app/auth"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+func versionedServer(t *testing.T) *httptest.Server {
+	tokens, err := auth.ParseTokens(strings.NewReader("reader read-only\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := mux.NewRouter().SkipClean(true)
+	app.RegisterTopologyRoutes(router, app.StaticCollector(fixture.Report), nil)
+	app.RegisterPipeRoutes(router, nil)
+	return httptest.NewServer(app.APIVersions(auth.NewAuthenticator(auth.Config{Tokens: tokens}).Wrap(router)))
+}
+
+func versionedGet(t *testing.T, ts *httptest.Server, path string) (*http.Response, []byte) {
+	req, err := http.NewRequest("GET", ts.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer reader")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var body bytes.Buffer
+	body.ReadFrom(res.Body)
+	return res, body.Bytes()
+}
+
+// sameJSON tells whether two bodies encode the same values, whatever
+// the order of the keys of their maps.
+func sameJSON(t *testing.T, a, b []byte) bool {
+	var va, vb interface{}
+	if err := codec.NewDecoderBytes(a, &codec.JsonHandle{}).Decode(&va); err != nil {
+		t.Fatal(err)
+	}
+	if err := codec.NewDecoderBytes(b, &codec.JsonHandle{}).Decode(&vb); err != nil {
+		t.Fatal(err)
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// TestAPIVersionsUnchanged checks the routes v2 doesn't change are the
+// same in every version.
+func TestAPIVersionsUnchanged(t *testing.T) {
+	ts := versionedServer(t)
+	defer ts.Close()
+	for _, path := range []string{
+		"/topology",
+		"/topology/processes/" + url.PathEscape(fixture.ServerProcessNodeID),
+		"/topology/processes/stats",
+	} {
+		res, want := versionedGet(t, ts, "/api"+path)
+		if res.StatusCode != http.StatusOK {
+			t.Fatalf("%s: want 200, have %d", path, res.StatusCode)
+		}
+		for _, version := range []string{"/api/v1", "/api/v2"} {
+			res, body := versionedGet(t, ts, version+path)
+			if res.StatusCode != http.StatusOK || !sameJSON(t, body, want) {
+				t.Errorf("%s%s: want %s, have %d %s", version, path, want, res.StatusCode, body)
+			}
+			if res.Header.Get("Deprecation") != "" {
+				t.Errorf("%s%s: expected no deprecation", version, path)
+			}
+		}
+	}
+}
+
+func TestAPIVersionsTopology(t *testing.T) {
+	ts := versionedServer(t)
+	defer ts.Close()
+
+	decode := func(body []byte) app.APITopology {
+		var topology app.APITopology
+		if err := codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&topology); err != nil {
+			t.Fatal(err)
+		}
+		return topology
+	}
+
+	_, v1 := versionedGet(t, ts, "/api/topology/processes")
+	for _, path := range []string{"/api/topology/processes", "/api/v1/topology/processes"} {
+		res, body := versionedGet(t, ts, path)
+		if !sameJSON(t, body, v1) {
+			t.Errorf("%s: want %s, have %s", path, v1, body)
+		}
+		if res.Header.Get("Deprecation") != "true" || res.Header.Get("Sunset") == "" {
+			t.Errorf("%s: expected deprecation, have %v", path, res.Header)
+		}
+		if want := "</api/v2/topology/processes>; rel=\"successor-version\""; res.Header.Get("Link") != want {
+			t.Errorf("%s: want %q, have %q", path, want, res.Header.Get("Link"))
+		}
+	}
+	all := decode(v1)
+	if all.NextCursor != "" || all.TotalNodes != 0 {
+		t.Errorf("expected v1 to be unpaginated, have %s", v1)
+	}
+
+	// v2 is paginated by default
+	res, body := versionedGet(t, ts, "/api/v2/topology/processes")
+	if res.StatusCode != http.StatusOK || res.Header.Get("Deprecation") != "" {
+		t.Fatalf("want 200, without deprecation, have %d %v", res.StatusCode, res.Header)
+	}
+	if topology := decode(body); topology.TotalNodes != len(all.Nodes) || len(topology.Nodes) != len(all.Nodes) {
+		t.Errorf("expected a single page of %d nodes, have %s", len(all.Nodes), body)
+	}
+
+	seen := map[string]bool{}
+	path := "/api/v2/topology/processes?limit=1"
+	for pages := 0; ; pages++ {
+		if pages > len(all.Nodes) {
+			t.Fatal("expected the pages to end")
+		}
+		_, body := versionedGet(t, ts, path)
+		topology := decode(body)
+		for id, node := range topology.Nodes {
+			if want, ok := all.Nodes[id]; !ok || want.Label != node.Label {
+				t.Errorf("%s: want %v, have %v", id, want, node)
+			}
+			seen[id] = true
+		}
+		if topology.NextCursor == "" {
+			break
+		}
+		path = "/api/v2/topology/processes?limit=1&cursor=" + topology.NextCursor
+	}
+	if len(seen) != len(all.Nodes) {
+		t.Errorf("expected every node paged through, have %v", seen)
+	}
+
+	for _, limit := range []string{"-1", "5001", "x"} {
+		if res, _ := versionedGet(t, ts, "/api/v2/topology/processes?limit="+limit); res.StatusCode != http.StatusBadRequest {
+			t.Errorf("limit %s: want 400, have %d", limit, res.StatusCode)
+		}
+	}
+}
+
+// TestAPIVersionsPermissions checks the permissions of the routes of v1
+// and v2 are those of the unversioned routes.
+func TestAPIVersionsPermissions(t *testing.T) {
+	ts := versionedServer(t)
+	defer ts.Close()
+	for _, path := range []string{"/api/pipe/p/check", "/api/v1/pipe/p/check", "/api/v2/pipe/p/check"} {
+		if res, _ := versionedGet(t, ts, path); res.StatusCode != http.StatusForbidden {
+			t.Errorf("%s: want 403, have %d", path, res.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/securecookie"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/weaveworks/scope/common/xfer"
+)
+
+// Paths of the login handlers of the Authenticator
+const (
+	LoginPath    = "/auth/login"
+	CallbackPath = "/auth/callback"
+	LogoutPath   = "/auth/logout"
+	WhoAmIPath   = "/auth/whoami"
+)
+
+const (
+	sessionCookie = "scope_session"
+	stateCookie   = "scope_oidc_state"
+	stateTTL      = 10 * time.Minute
+)
+
+// Identity is who made a request.
+type Identity struct {
+	Subject string `json:"subject"`
+	Role    Role   `json:"-"`
+	// Method is how the identity was established: token, oidc or
+	// session
+	Method string `json:"method"`
+}
+
+// MarshalJSON shows the name of the role.
+func (i Identity) MarshalJSON() ([]byte, error) {
+	type identity Identity
+	return json.Marshal(struct {
+		identity
+		Role string `json:"role"`
+	}{identity(i), i.Role.String()})
+}
+
+type contextKey struct{}
+
+// FromContext returns the identity of the request of a context.
+func FromContext(ctx context.Context) (Identity, bool) {
+	identity, ok := ctx.Value(contextKey{}).(Identity)
+	return identity, ok
+}
+
+// Config configures an Authenticator.
+type Config struct {
+	// Tokens are the machine tokens accepted as bearer tokens, or in
+	// the Scope-Probe authorization of probes
+	Tokens *Tokens
+	// OIDC logs users in, if set; raw ID tokens are accepted as bearer
+	// tokens too
+	OIDC *OIDC
+	// SessionKey signs and encrypts the session cookies. Sessions don't
+	// survive restarts of the app without one.
+	SessionKey string
+	// SessionTTL bounds the lifetime of sessions, which otherwise last
+	// as long as the ID tokens they were opened with
+	SessionTTL time.Duration
+}
+
+// Authenticator authenticates the requests to the app, by machine token,
+// OIDC ID token or session cookie, checking that the role of the
+// identity allows the request.
+type Authenticator struct {
+	config  Config
+	cookies *securecookie.SecureCookie
+}
+
+type session struct {
+	Subject string
+	Role    Role
+	Expires time.Time
+}
+
+type loginState struct {
+	State    string
+	Nonce    string
+	Redirect string
+}
+
+// NewAuthenticator makes a new Authenticator.
+func NewAuthenticator(config Config) *Authenticator {
+	var hashKey, blockKey []byte
+	if config.SessionKey != "" {
+		h := sha256.Sum256([]byte("hash:" + config.SessionKey))
+		b := sha256.Sum256([]byte("block:" + config.SessionKey))
+		hashKey, blockKey = h[:], b[:]
+	} else {
+		hashKey, blockKey = securecookie.GenerateRandomKey(32), securecookie.GenerateRandomKey(32)
+	}
+	return &Authenticator{
+		config:  config,
+		cookies: securecookie.New(hashKey, blockKey),
+	}
+}
+
+// Wrap implements middleware.Interface, serving the login handlers and
+// rejecting the requests which aren't allowed.
+func (a *Authenticator) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case LoginPath:
+			a.login(w, r)
+			return
+		case CallbackPath:
+			a.callback(w, r)
+			return
+		case LogoutPath:
+			setCookie(w, r, &http.Cookie{Name: sessionCookie, Path: "/", MaxAge: -1})
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+
+		identity, ok := a.authenticate(r)
+		if !ok {
+			if a.config.OIDC != nil && r.Method == "GET" && strings.Contains(r.Header.Get("Accept"), "text/html") {
+				http.Redirect(w, r, LoginPath+"?redirect="+r.URL.RequestURI(), http.StatusFound)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer realm="scope"`)
+			http.Error(w, "authentication required", http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path == WhoAmIPath {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(identity)
+			return
+		}
+		// Browsers send session cookies with the requests of other sites
+		// too, which mustn't change anything
+		if identity.Method == "session" && !safeMethod(r.Method) && !xfer.SameOrigin(r) {
+			log.Infof("auth: denied cross-site %s %s to %s", r.Method, r.URL.Path, identity.Subject)
+			http.Error(w, "cross-site request forbidden", http.StatusForbidden)
+			return
+		}
+		if permission := RequiredPermission(r); !identity.Role.Allows(permission) {
+			log.Infof("auth: denied %s %s to %s (%v)", r.Method, r.URL.Path, identity.Subject, identity.Role)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, identity)))
+	})
+}
+
+// authenticate returns the identity of a request, from its authorization
+// header or its session cookie.
+func (a *Authenticator) authenticate(r *http.Request) (Identity, bool) {
+	if header := r.Header.Get("Authorization"); header != "" {
+		var token string
+		switch {
+		case strings.HasPrefix(header, "Bearer "):
+			token = strings.TrimPrefix(header, "Bearer ")
+		case strings.HasPrefix(header, "Scope-Probe token="):
+			token = strings.TrimPrefix(header, "Scope-Probe token=")
+		default:
+			return Identity{}, false
+		}
+		if identity, ok := a.config.Tokens.lookup(token); ok {
+			return identity, true
+		}
+		if a.config.OIDC != nil && strings.Count(token, ".") == 2 {
+			identity, _, err := a.config.OIDC.verify(r.Context(), token, "")
+			if err != nil {
+				log.Debugf("auth: invalid ID token: %v", err)
+				return Identity{}, false
+			}
+			return identity, identity.Role != NoRole
+		}
+		return Identity{}, false
+	}
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return Identity{}, false
+	}
+	var s session
+	if err := a.cookies.Decode(sessionCookie, cookie.Value, &s); err != nil || time.Now().After(s.Expires) {
+		return Identity{}, false
+	}
+	return Identity{Subject: s.Subject, Role: s.Role, Method: "session"}, true
+}
+
+// login sends the user to the provider, remembering where to get back to
+// and the state and nonce binding the callback to this browser.
+func (a *Authenticator) login(w http.ResponseWriter, r *http.Request) {
+	if a.config.OIDC == nil {
+		http.NotFound(w, r)
+		return
+	}
+	state := loginState{State: randomString(), Nonce: randomString(), Redirect: r.FormValue("redirect")}
+	if !strings.HasPrefix(state.Redirect, "/") || strings.HasPrefix(state.Redirect, "//") {
+		state.Redirect = "/"
+	}
+	encoded, err := a.cookies.Encode(stateCookie, state)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	setCookie(w, r, &http.Cookie{Name: stateCookie, Value: encoded, Path: CallbackPath, MaxAge: int(stateTTL.Seconds())})
+	http.Redirect(w, r, a.config.OIDC.authCodeURL(state.State, state.Nonce), http.StatusFound)
+}
+
+// callback opens a session for the user the provider logged in.
+func (a *Authenticator) callback(w http.ResponseWriter, r *http.Request) {
+	if a.config.OIDC == nil {
+		http.NotFound(w, r)
+		return
+	}
+	cookie, err := r.Cookie(stateCookie)
+	if err != nil {
+		http.Error(w, "no login in progress", http.StatusBadRequest)
+		return
+	}
+	var state loginState
+	if err := a.cookies.Decode(stateCookie, cookie.Value, &state); err != nil || state.State != r.FormValue("state") {
+		http.Error(w, "invalid login state", http.StatusBadRequest)
+		return
+	}
+	if e := r.FormValue("error"); e != "" {
+		http.Error(w, "login failed: "+e, http.StatusUnauthorized)
+		return
+	}
+	identity, expires, err := a.config.OIDC.exchange(r.Context(), r.FormValue("code"), state.Nonce)
+	if err != nil {
+		log.Warnf("auth: login failed: %v", err)
+		http.Error(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+	if identity.Role == NoRole {
+		http.Error(w, "no role for "+identity.Subject, http.StatusForbidden)
+		return
+	}
+	if a.config.SessionTTL > 0 {
+		if limit := time.Now().Add(a.config.SessionTTL); limit.Before(expires) {
+			expires = limit
+		}
+	}
+	encoded, err := a.cookies.Encode(sessionCookie, session{Subject: identity.Subject, Role: identity.Role, Expires: expires})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	setCookie(w, r, &http.Cookie{Name: stateCookie, Path: CallbackPath, MaxAge: -1})
+	setCookie(w, r, &http.Cookie{Name: sessionCookie, Value: encoded, Path: "/", Expires: expires})
+	log.Infof("auth: %s logged in (%v)", identity.Subject, identity.Role)
+	http.Redirect(w, r, state.Redirect, http.StatusFound)
+}
+
+// setCookie sets a cookie kept from scripts, sent over HTTPS only if the
+// request came over it, to the app or to a proxy in front of it, and
+// only with top-level navigations from other sites (SameSite=Lax, which
+// net/http only has from Go 1.11).
+func setCookie(w http.ResponseWriter, r *http.Request, cookie *http.Cookie) {
+	cookie.HttpOnly = true
+	cookie.Secure = r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https"
+	w.Header().Add("Set-Cookie", cookie.String()+"; SameSite=Lax")
+}
+
+// safeMethod tells whether requests of a method only read.
+func safeMethod(method string) bool {
+	return method == "GET" || method == "HEAD"
+}
+
+func randomString() string {
+	return hex.EncodeToString(securecookie.GenerateRandomKey(16))
+}
@@ -0,0 +1,305 @@
+package auth_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+
+	"github.com/weaveworks/scope/app/auth"
+)
+
+const testTokens = `
+# CLI and automation
+reader-token read-only ci
+operator-token controls
+probe-token probe probes
+deploy-token deployer pipeline
+`
+
+func TestParseTokens(t *testing.T) {
+	tokens, err := auth.ParseTokens(strings.NewReader(testTokens))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tokens.Len() != 4 {
+		t.Errorf("expected 4 tokens, got %d", tokens.Len())
+	}
+	for _, invalid := range []string{"token", "token superuser", "token admin name extra"} {
+		if _, err := auth.ParseTokens(strings.NewReader(invalid)); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestParseRoleMap(t *testing.T) {
+	roles, err := auth.ParseRoleMap("scope-admins=admin, ops = controls,*=read-only")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roles) != 3 || roles["scope-admins"] != auth.Admin || roles["ops"] != auth.Controls || roles["*"] != auth.ReadOnly {
+		t.Errorf("unexpected roles: %v", roles)
+	}
+	for _, invalid := range []string{"ops", "ops=root"} {
+		if _, err := auth.ParseRoleMap(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestRequiredPermission(t *testing.T) {
+	for _, c := range []struct {
+		method, path string
+		want         auth.Permission
+	}{
+		{"GET", "/api/topology/containers", auth.Read},
+		{"GET", "/", auth.Read},
+		{"POST", "/api/report", auth.Publish},
+		{"GET", "/api/control/ws", auth.Publish},
+		{"GET", "/api/pipe/p1/probe", auth.Publish},
+		{"DELETE", "/api/pipe/p1", auth.ClosePipe},
+		{"GET", "/api/pipe/p1", auth.Control},
+		{"POST", "/api/control/probe1/node1/docker_stop_container", auth.Control},
+		{"POST", "/api/bandwidth-test", auth.Control},
+		{"POST", "/api/maintenance-windows", auth.Control},
+		{"GET", "/api/maintenance-windows", auth.Read},
+		{"POST", "/api/deployments", auth.Deploy},
+		{"GET", "/api/deployments", auth.Read},
+		{"GET", "/debug/pprof/heap", auth.Administer},
+		{"GET", "/metrics", auth.Administer},
+		{"GET", "/api/admin/merge-audit", auth.Administer},
+		{"PUT", "/api/settings", auth.Administer},
+		{"DELETE", "/api/annotations/a1", auth.Read},
+		{"PUT", "/api/views/v1", auth.Read},
+	} {
+		r := httptest.NewRequest(c.method, c.path, nil)
+		if have := auth.RequiredPermission(r); have != c.want {
+			t.Errorf("%s %s: want %v, have %v", c.method, c.path, c.want, have)
+		}
+	}
+}
+
+var ok = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if _, found := auth.FromContext(r.Context()); !found {
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+})
+
+func do(h http.Handler, method, path string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, nil)
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func bearer(token string) http.Header {
+	return http.Header{"Authorization": {"Bearer " + token}}
+}
+
+func TestMachineTokens(t *testing.T) {
+	tokens, err := auth.ParseTokens(strings.NewReader(testTokens))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := auth.NewAuthenticator(auth.Config{Tokens: tokens}).Wrap(ok)
+	probe := http.Header{"Authorization": {"Scope-Probe token=probe-token"}}
+	for _, c := range []struct {
+		method, path string
+		header       http.Header
+		want         int
+	}{
+		{"GET", "/api/topology", nil, http.StatusUnauthorized},
+		{"GET", "/api/topology", bearer("wrong"), http.StatusUnauthorized},
+		{"GET", "/api/topology", bearer("reader-token"), http.StatusOK},
+		{"POST", "/api/control/p/n/c", bearer("reader-token"), http.StatusForbidden},
+		{"POST", "/api/control/p/n/c", bearer("operator-token"), http.StatusOK},
+		{"GET", "/debug/pprof/heap", bearer("operator-token"), http.StatusForbidden},
+		{"POST", "/api/report", probe, http.StatusOK},
+		{"DELETE", "/api/pipe/p1", probe, http.StatusOK},
+		{"POST", "/api/control/p/n/c", probe, http.StatusForbidden},
+		{"POST", "/api/deployments", bearer("deploy-token"), http.StatusOK},
+		{"POST", "/api/deployments", bearer("operator-token"), http.StatusOK},
+		{"POST", "/api/deployments", bearer("reader-token"), http.StatusForbidden},
+		{"POST", "/api/control/p/n/c", bearer("deploy-token"), http.StatusForbidden},
+	} {
+		if w := do(h, c.method, c.path, c.header); w.Code != c.want {
+			t.Errorf("%s %s %v: want %d, have %d", c.method, c.path, c.header, c.want, w.Code)
+		}
+	}
+
+	w := do(h, "GET", auth.WhoAmIPath, bearer("reader-token"))
+	var identity map[string]string
+	if err := json.NewDecoder(w.Body).Decode(&identity); err != nil {
+		t.Fatal(err)
+	}
+	if identity["subject"] != "ci" || identity["role"] != "read-only" || identity["method"] != "token" {
+		t.Errorf("unexpected identity: %v", identity)
+	}
+}
+
+// provider is a fake OpenID Connect provider, issuing ID tokens with the
+// given groups for any code.
+type provider struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	groups []string
+	nonce  string
+}
+
+func newProvider(t *testing.T) *provider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &provider{key: key, groups: []string{"ops", "everyone"}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kid": "k1",
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "the-code" {
+			http.Error(w, "bad code", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     p.idToken(t, "client", p.nonce),
+		})
+	})
+	p.Server = httptest.NewServer(mux)
+	return p
+}
+
+func (p *provider) idToken(t *testing.T, audience, nonce string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":    p.URL,
+		"aud":    audience,
+		"sub":    "1234",
+		"email":  "alice@example.com",
+		"exp":    time.Now().Add(time.Hour).Unix(),
+		"nonce":  nonce,
+		"groups": p.groups,
+	})
+	token.Header["kid"] = "k1"
+	signed, err := token.SignedString(p.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestOIDCLogin(t *testing.T) {
+	p := newProvider(t)
+	defer p.Close()
+	oidc, err := auth.NewOIDC(context.Background(), auth.OIDCConfig{
+		Issuer:      p.URL,
+		ClientID:    "client",
+		RedirectURL: "http://scope/auth/callback",
+		RoleClaim:   "groups",
+		Roles:       map[string]auth.Role{"ops": auth.Controls, "*": auth.ReadOnly},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := auth.NewAuthenticator(auth.Config{OIDC: oidc, SessionKey: "secret"}).Wrap(ok)
+
+	// Browsers are sent to log in
+	w := do(h, "GET", "/api/topology", http.Header{"Accept": {"text/html"}})
+	if w.Code != http.StatusFound || w.Header().Get("Location") != auth.LoginPath+"?redirect=/api/topology" {
+		t.Fatalf("expected a redirection to the login, got %d %v", w.Code, w.Header())
+	}
+
+	// The login goes to the provider
+	w = do(h, "GET", auth.LoginPath+"?redirect=/containers", nil)
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil || !strings.HasPrefix(location.String(), p.URL+"/authorize") {
+		t.Fatalf("expected a redirection to the provider, got %d %v", w.Code, w.Header())
+	}
+	state, stateCookie := location.Query().Get("state"), w.Result().Cookies()[0]
+	p.nonce = location.Query().Get("nonce")
+
+	// The provider comes back with a code, opening a session
+	cookie := http.Header{"Cookie": {stateCookie.Name + "=" + stateCookie.Value}}
+	if w = do(h, "GET", auth.CallbackPath+"?code=the-code&state=wrong", cookie); w.Code != http.StatusBadRequest {
+		t.Errorf("expected a mismatching state to be rejected, got %d", w.Code)
+	}
+	w = do(h, "GET", auth.CallbackPath+"?code=the-code&state="+state, cookie)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/containers" {
+		t.Fatalf("expected a redirection back, got %d %v", w.Code, w.Header())
+	}
+	var session *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Value != "" {
+			session = c
+		}
+	}
+	if session == nil {
+		t.Fatal("expected a session cookie")
+	}
+	for _, c := range w.Header()["Set-Cookie"] {
+		if !strings.Contains(c, "; HttpOnly") || !strings.HasSuffix(c, "; SameSite=Lax") {
+			t.Errorf("expected cookies to be HttpOnly and SameSite=Lax, got %s", c)
+		}
+	}
+	cookie = http.Header{"Cookie": {session.Name + "=" + session.Value}}
+	if w = do(h, "POST", "/api/control/p/n/c", cookie); w.Code != http.StatusOK {
+		t.Errorf("expected ops to have the controls role, got %d", w.Code)
+	}
+
+	// Other sites can't act with the session
+	cookie.Set("Origin", "http://example.com")
+	if w = do(h, "POST", "/api/control/p/n/c", cookie); w.Code != http.StatusOK {
+		t.Errorf("expected requests of the app's own pages to be allowed, got %d", w.Code)
+	}
+	cookie.Set("Origin", "http://evil.com")
+	if w = do(h, "POST", "/api/control/p/n/c", cookie); w.Code != http.StatusForbidden {
+		t.Errorf("expected cross-site requests to be forbidden, got %d", w.Code)
+	}
+	if w = do(h, "GET", "/api/topology", cookie); w.Code != http.StatusOK {
+		t.Errorf("expected cross-site reads to be allowed, got %d", w.Code)
+	}
+	cookie.Del("Origin")
+	if w = do(h, "GET", "/debug/pprof/heap", cookie); w.Code != http.StatusForbidden {
+		t.Errorf("expected ops not to be admins, got %d", w.Code)
+	}
+
+	// ID tokens are accepted as bearer tokens too
+	p.groups = nil
+	if w = do(h, "GET", "/api/topology", bearer(p.idToken(t, "client", ""))); w.Code != http.StatusOK {
+		t.Errorf("expected the ID token to be accepted, got %d", w.Code)
+	}
+	if w = do(h, "POST", "/api/control/p/n/c", bearer(p.idToken(t, "client", ""))); w.Code != http.StatusForbidden {
+		t.Errorf("expected users without groups to be read-only, got %d", w.Code)
+	}
+	if w = do(h, "GET", "/api/topology", bearer(p.idToken(t, "other-client", ""))); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected the ID token of another client to be rejected, got %d", w.Code)
+	}
+}
@@ -0,0 +1,264 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"golang.org/x/oauth2"
+)
+
+// How often the keys of the provider may be fetched again, when tokens
+// are signed with unknown keys.
+const keysRefreshInterval = time.Minute
+
+// OIDCConfig configures the login of users with an OpenID Connect
+// provider.
+type OIDCConfig struct {
+	// Issuer is the URL of the provider, where its discovery document
+	// is found under /.well-known/openid-configuration
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the URL of the /auth/callback handler of the app,
+	// as registered with the provider
+	RedirectURL string
+	// RoleClaim is the claim of ID tokens holding the roles, or groups,
+	// of users: a string or a list of strings
+	RoleClaim string
+	// Roles maps the values of the role claim to roles; the most
+	// privileged role of a user wins
+	Roles map[string]Role
+	// Scopes are requested on top of openid
+	Scopes []string
+}
+
+// OIDC logs users in with an OpenID Connect provider, verifying their ID
+// tokens with the keys of the provider.
+type OIDC struct {
+	config OIDCConfig
+	oauth2 oauth2.Config
+	client *http.Client
+
+	jwksURL string
+	mtx     sync.Mutex
+	keys    map[string]*rsa.PublicKey
+	fetched time.Time
+}
+
+type discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// NewOIDC discovers the endpoints and keys of the provider.
+func NewOIDC(ctx context.Context, config OIDCConfig) (*OIDC, error) {
+	o := &OIDC{config: config, client: http.DefaultClient}
+	var d discovery
+	if err := o.getJSON(ctx, strings.TrimSuffix(config.Issuer, "/")+"/.well-known/openid-configuration", &d); err != nil {
+		return nil, fmt.Errorf("discovering %s: %v", config.Issuer, err)
+	}
+	if d.Issuer != config.Issuer {
+		return nil, fmt.Errorf("provider issuer %q doesn't match %q", d.Issuer, config.Issuer)
+	}
+	o.jwksURL = d.JWKSURI
+	o.oauth2 = oauth2.Config{
+		ClientID:     config.ClientID,
+		ClientSecret: config.ClientSecret,
+		RedirectURL:  config.RedirectURL,
+		Endpoint:     oauth2.Endpoint{AuthURL: d.AuthorizationEndpoint, TokenURL: d.TokenEndpoint},
+		Scopes:       append([]string{"openid"}, config.Scopes...),
+	}
+	if err := o.refreshKeys(ctx); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (o *OIDC) getJSON(ctx context.Context, url string, v interface{}) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := o.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+type jwks struct {
+	Keys []struct {
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// refreshKeys fetches the RSA keys of the provider.
+func (o *OIDC) refreshKeys(ctx context.Context) error {
+	var set jwks
+	if err := o.getJSON(ctx, o.jwksURL, &set); err != nil {
+		return fmt.Errorf("fetching keys: %v", err)
+	}
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return fmt.Errorf("key %s: %v", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return fmt.Errorf("key %s: %v", k.Kid, err)
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	o.mtx.Lock()
+	o.keys, o.fetched = keys, time.Now()
+	o.mtx.Unlock()
+	return nil
+}
+
+func (o *OIDC) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	o.mtx.Lock()
+	key, ok := o.keys[kid]
+	stale := time.Since(o.fetched) > keysRefreshInterval
+	o.mtx.Unlock()
+	if ok {
+		return key, nil
+	}
+	if !stale {
+		return nil, fmt.Errorf("unknown key %q", kid)
+	}
+	// The provider may have rotated its keys
+	if err := o.refreshKeys(ctx); err != nil {
+		return nil, err
+	}
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+	if key, ok := o.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+// authCodeURL is where users are sent to log in.
+func (o *OIDC) authCodeURL(state, nonce string) string {
+	return o.oauth2.AuthCodeURL(state, oauth2.SetAuthURLParam("nonce", nonce))
+}
+
+// exchange redeems the code of a login for the identity of the user.
+func (o *OIDC) exchange(ctx context.Context, code, nonce string) (Identity, time.Time, error) {
+	token, err := o.oauth2.Exchange(ctx, code)
+	if err != nil {
+		return Identity{}, time.Time{}, err
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return Identity{}, time.Time{}, fmt.Errorf("no ID token")
+	}
+	return o.verify(ctx, rawIDToken, nonce)
+}
+
+// verify checks the signature, issuer, audience, expiry and, if given,
+// nonce of an ID token, returning the identity of the user and when the
+// token expires.
+func (o *OIDC) verify(ctx context.Context, rawIDToken, nonce string) (Identity, time.Time, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, func(t *jwt.Token) (interface{}, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+		}
+		kid, _ := t.Header["kid"].(string)
+		return o.key(ctx, kid)
+	})
+	if err != nil {
+		return Identity{}, time.Time{}, err
+	}
+	if !claims.VerifyIssuer(o.config.Issuer, true) {
+		return Identity{}, time.Time{}, fmt.Errorf("unexpected issuer %v", claims["iss"])
+	}
+	if !verifyAudience(claims["aud"], o.config.ClientID) {
+		return Identity{}, time.Time{}, fmt.Errorf("unexpected audience %v", claims["aud"])
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return Identity{}, time.Time{}, fmt.Errorf("no expiry")
+	}
+	if nonce != "" && claims["nonce"] != nonce {
+		return Identity{}, time.Time{}, fmt.Errorf("unexpected nonce")
+	}
+	subject, _ := claims["email"].(string)
+	if subject == "" {
+		subject, _ = claims["sub"].(string)
+	}
+	identity := Identity{Subject: subject, Role: o.role(claims[o.config.RoleClaim]), Method: "oidc"}
+	return identity, time.Unix(int64(exp), 0), nil
+}
+
+// verifyAudience checks the audience of a token, a string or a list of
+// strings, which the jwt-go claims only handle the former of.
+func verifyAudience(aud interface{}, clientID string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == clientID
+	case []interface{}:
+		for _, a := range aud {
+			if a == clientID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// role maps the values of the role claim to the most privileged role.
+func (o *OIDC) role(claim interface{}) Role {
+	var values []string
+	switch claim := claim.(type) {
+	case string:
+		values = []string{claim}
+	case []interface{}:
+		for _, v := range claim {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	role := NoRole
+	for _, v := range values {
+		if r, ok := o.config.Roles[v]; ok && privilege(r) > privilege(role) {
+			role = r
+		}
+	}
+	if role == NoRole {
+		role = o.config.Roles["*"]
+	}
+	return role
+}
+
+// privilege orders roles for mapping claims; users are never probes.
+func privilege(r Role) int {
+	if r == Probe {
+		return -1
+	}
+	return int(r)
+}
@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Role is what an authenticated user or machine may do.
+type Role int
+
+// Roles, by increasing privileges; Probe is apart, only allowed to read,
+// publish reports and serve controls and pipes, and so is Deployer, e.g.
+// of CI pipelines, only allowed to read and record deployments.
+const (
+	NoRole Role = iota
+	ReadOnly
+	Controls
+	Admin
+	Probe
+	Deployer
+)
+
+var roleNames = map[Role]string{
+	NoRole:   "none",
+	ReadOnly: "read-only",
+	Controls: "controls",
+	Admin:    "admin",
+	Probe:    "probe",
+	Deployer: "deployer",
+}
+
+func (r Role) String() string {
+	if name, ok := roleNames[r]; ok {
+		return name
+	}
+	return fmt.Sprintf("role(%d)", int(r))
+}
+
+// ParseRole parses the name of a role.
+func ParseRole(s string) (Role, error) {
+	for role, name := range roleNames {
+		if role != NoRole && name == s {
+			return role, nil
+		}
+	}
+	return NoRole, fmt.Errorf("unknown role %q, expected read-only, controls, admin, probe or deployer", s)
+}
+
+// ParseRoleMap parses a comma-separated list of claim=role pairs, mapping
+// the values of a role claim to roles, e.g.
+// "scope-admins=admin,scope-ops=controls,*=read-only". The claim "*"
+// gives the role of the users with none of the other claims.
+func ParseRoleMap(s string) (map[string]Role, error) {
+	roles := map[string]Role{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("invalid role mapping %q, expected claim=role", pair)
+		}
+		role, err := ParseRole(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, err
+		}
+		roles[strings.TrimSpace(kv[0])] = role
+	}
+	return roles, nil
+}
+
+// Permission is what a request needs.
+type Permission int
+
+// Permissions of requests
+const (
+	Read Permission = iota
+	Control
+	Administer
+	Publish
+	// ClosePipe is needed by either end of a pipe
+	ClosePipe
+	// Deploy is needed to record deployments
+	Deploy
+)
+
+// Allows tells whether the role grants the permission. Admins are
+// granted every permission.
+func (r Role) Allows(p Permission) bool {
+	switch r {
+	case Admin:
+		return true
+	case Probe:
+		return p == Read || p == Publish || p == ClosePipe
+	case Deployer:
+		return p == Read || p == Deploy
+	case Controls:
+		return p == Read || p == Control || p == ClosePipe || p == Deploy
+	case ReadOnly:
+		return p == Read
+	}
+	return false
+}
+
+// RequiredPermission is the permission a request to the app needs:
+//   - probes publish reports, and serve controls and pipes;
+//   - controls, pipes and bandwidth tests act on the hosts, though
+//     either end may close a pipe, and maintenance windows silence them;
+//   - deployments are recorded by deployers, or users with controls;
+//   - saved views and annotations are the users' own, checked by their
+//     handlers;
+//   - debugging, administration and metrics endpoints, and any other
+//     change, are for admins;
+//   - reading the rest is for everybody.
+func RequiredPermission(r *http.Request) Permission {
+	var (
+		path = r.URL.Path
+		read = r.Method == "GET" || r.Method == "HEAD"
+	)
+	switch {
+	case path == "/api/report" && r.Method == "POST",
+		path == "/api/control/ws",
+		strings.HasPrefix(path, "/api/pipe/") && strings.HasSuffix(path, "/probe"):
+		return Publish
+	case strings.HasPrefix(path, "/api/pipe/") && (r.Method == "DELETE" || r.Method == "POST"):
+		return ClosePipe
+	case strings.HasPrefix(path, "/api/control/"),
+		strings.HasPrefix(path, "/api/pipe/"),
+		path == "/api/bandwidth-test",
+		strings.HasPrefix(path, "/api/maintenance-windows") && !read:
+		return Control
+	case strings.HasPrefix(path, "/api/deployments") && !read:
+		return Deploy
+	case path == "/api/views" || strings.HasPrefix(path, "/api/views/"),
+		path == "/api/annotations" || strings.HasPrefix(path, "/api/annotations/"):
+		return Read
+	case strings.HasPrefix(path, "/debug/"),
+		strings.HasPrefix(path, "/api/admin/"),
+		path == "/metrics",
+		!read:
+		return Administer
+	}
+	return Read
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// machineToken is a static bearer token, for the CLI, automation and
+// probes.
+type machineToken struct {
+	token string
+	name  string
+	role  Role
+}
+
+// Tokens are the machine tokens accepted by the app. They are written
+// one per line, as "<token> <role> [<name>]"; blank lines and lines
+// starting with '#' are ignored.
+type Tokens struct {
+	mtx    sync.RWMutex
+	tokens []machineToken
+}
+
+// LoadTokens reads machine tokens from the file at path.
+func LoadTokens(path string) (*Tokens, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseTokens(f)
+}
+
+// ParseTokens reads machine tokens from r.
+func ParseTokens(r io.Reader) (*Tokens, error) {
+	var (
+		tokens  = &Tokens{}
+		scanner = bufio.NewScanner(r)
+		line    = 0
+	)
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("line %d: expected <token> <role> [<name>]", line)
+		}
+		role, err := ParseRole(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		name := fmt.Sprintf("token-%d", line)
+		if len(fields) == 3 {
+			name = fields[2]
+		}
+		tokens.tokens = append(tokens.tokens, machineToken{token: fields[0], name: name, role: role})
+	}
+	return tokens, scanner.Err()
+}
+
+// Len is the number of tokens.
+func (t *Tokens) Len() int {
+	if t == nil {
+		return 0
+	}
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	return len(t.tokens)
+}
+
+// Replace replaces the tokens with those of other, e.g. reloaded from
+// their file, revoking the tokens not in it.
+func (t *Tokens) Replace(other *Tokens) {
+	other.mtx.RLock()
+	tokens := other.tokens
+	other.mtx.RUnlock()
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	t.tokens = tokens
+}
+
+// lookup returns the identity of a token. Every token is compared in
+// constant time, so that timings don't tell how close a guess is.
+func (t *Tokens) lookup(token string) (Identity, bool) {
+	if t == nil {
+		return Identity{}, false
+	}
+	var (
+		found Identity
+		ok    bool
+	)
+	t.mtx.RLock()
+	defer t.mtx.RUnlock()
+	for _, m := range t.tokens {
+		if subtle.ConstantTimeCompare([]byte(m.token), []byte(token)) == 1 {
+			found, ok = Identity{Subject: m.name, Role: m.role, Method: "token"}, true
+		}
+	}
+	return found, ok
+}
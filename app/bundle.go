@@ -0,0 +1,226 @@
+package app
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/ugorji/go/codec"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/report"
+)
+
+// BundleConfig selects the reports exported in a bundle.
+type BundleConfig struct {
+	// AppURL is the address of the app to export reports from
+	AppURL string
+	// From and To delimit the time range of the reports
+	From, To time.Time
+	// Step is the time between successive reports
+	Step time.Duration
+	// Token, if any, is sent as a bearer token, to apps requiring
+	// authentication
+	Token string
+}
+
+// ExportBundle writes the reports of an app over a time range to w, as
+// a gzipped tarball of JSON reports named after their timestamp. Apps
+// without historic reports can only export their current report.
+func ExportBundle(client *http.Client, cfg BundleConfig, w io.Writer) error {
+	base, err := url.Parse(cfg.AppURL)
+	if err != nil {
+		return err
+	}
+	var details struct {
+		Capabilities map[string]bool `json:"capabilities"`
+	}
+	if err := getJSON(client, base.ResolveReference(&url.URL{Path: "api"}).String(), cfg.Token, &details); err != nil {
+		return err
+	}
+	timestamps := []time.Time{cfg.To}
+	if details.Capabilities[xfer.HistoricReportsCapability] {
+		timestamps = nil
+		for t := cfg.From; !t.After(cfg.To); t = t.Add(cfg.Step) {
+			timestamps = append(timestamps, t)
+		}
+	} else {
+		log.Warnf("App at %s has no historic reports; only exporting its current report", cfg.AppURL)
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, t := range timestamps {
+		u := base.ResolveReference(&url.URL{Path: "api/report", RawQuery: url.Values{
+			"timestamp": {t.Format(time.RFC3339)},
+		}.Encode()})
+		buf, err := get(client, u.String(), cfg.Token)
+		if err != nil {
+			return err
+		}
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    strconv.FormatInt(t.UnixNano(), 10) + ".json",
+			Mode:    0644,
+			Size:    int64(len(buf)),
+			ModTime: t,
+		}); err != nil {
+			return err
+		}
+		if _, err := tw.Write(buf); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func get(client *http.Client, url, token string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s: %s", url, resp.Status, buf)
+	}
+	return buf, nil
+}
+
+func getJSON(client *http.Client, url, token string, v interface{}) error {
+	buf, err := get(client, url, token)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(buf, v)
+}
+
+// NewBundleCollector reads the reports of a bundle written by
+// ExportBundle, and serves each at the time it was exported, so that the
+// app travels through the time range of the bundle. Later times get the
+// last report of the bundle.
+func NewBundleCollector(path string, window time.Duration) (Collector, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var reports bundleReports
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		timestamp, err := timestampFromFilepath(hdr.Name)
+		if err != nil {
+			return nil, err
+		}
+		rpt := report.MakeReport()
+		if err := rpt.ReadBinary(tr, false, &codec.JsonHandle{}); err != nil {
+			return nil, fmt.Errorf("%s: %v", hdr.Name, err)
+		}
+		reports = append(reports, bundleReport{timestamp, rpt.Upgrade()})
+	}
+	if len(reports) == 0 {
+		return nil, fmt.Errorf("%s: no reports", path)
+	}
+	sort.Sort(reports)
+	return &bundleCollector{reports: reports, window: window}, nil
+}
+
+type bundleReport struct {
+	timestamp time.Time
+	report    report.Report
+}
+
+type bundleReports []bundleReport
+
+func (r bundleReports) Len() int           { return len(r) }
+func (r bundleReports) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+func (r bundleReports) Less(i, j int) bool { return r[i].timestamp.Before(r[j].timestamp) }
+
+// bundleCollector serves the reports of a bundle, by timestamp. Every
+// report of a bundle is already merged over the window before it.
+type bundleCollector struct {
+	reports bundleReports
+	window  time.Duration
+}
+
+// at returns the last report exported at or before timestamp, or after
+// the end of the bundle the last one.
+func (c *bundleCollector) at(timestamp time.Time) (bundleReport, bool) {
+	i := sort.Search(len(c.reports), func(i int) bool { return c.reports[i].timestamp.After(timestamp) })
+	if i == 0 {
+		return bundleReport{}, false
+	}
+	return c.reports[i-1], true
+}
+
+// Report returns the report of the bundle at timestamp. It implements
+// Reporter.
+func (c *bundleCollector) Report(_ context.Context, timestamp time.Time) (report.Report, error) {
+	if r, ok := c.at(timestamp); ok {
+		return r.report, nil
+	}
+	return report.MakeReport(), nil
+}
+
+// HasReports indicates whether the bundle has a report between
+// timestamp-app.window and timestamp, or timestamp is after its end.
+func (c *bundleCollector) HasReports(_ context.Context, timestamp time.Time) (bool, error) {
+	r, ok := c.at(timestamp)
+	last := c.reports[len(c.reports)-1]
+	return ok && (r.timestamp.Equal(last.timestamp) || timestamp.Sub(r.timestamp) < c.window), nil
+}
+
+// HasHistoricReports is true, so that the UI can travel through the time
+// range of the bundle.
+func (c *bundleCollector) HasHistoricReports() bool {
+	return true
+}
+
+// Add drops reports: the reports of a bundle are all there are. It
+// implements Adder.
+func (c *bundleCollector) Add(context.Context, report.Report, []byte) error { return nil }
+
+// WaitOn does nothing, as no report is ever received. It implements
+// Reporter.
+func (c *bundleCollector) WaitOn(context.Context, chan struct{}) {}
+
+// UnWait does nothing, as no report is ever received. It implements
+// Reporter.
+func (c *bundleCollector) UnWait(context.Context, chan struct{}) {}
@@ -0,0 +1,151 @@
+package app_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/common/xfer"
+)
+
+func TestExportBundle(t *testing.T) {
+	for _, historic := range []bool{true, false} {
+		var requested []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer secret" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			switch r.URL.Path {
+			case "/api":
+				w.Write([]byte(`{"capabilities":{"` + xfer.HistoricReportsCapability + `":` + strconv.FormatBool(historic) + `}}`))
+			case "/api/report":
+				requested = append(requested, r.FormValue("timestamp"))
+				w.Write([]byte(`{"ID":"` + r.FormValue("timestamp") + `"}`))
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+
+		from := time.Unix(1500000000, 0).UTC()
+		buf := &bytes.Buffer{}
+		err := app.ExportBundle(http.DefaultClient, app.BundleConfig{
+			AppURL: server.URL,
+			From:   from,
+			To:     from.Add(time.Minute),
+			Step:   15 * time.Second,
+			Token:  "secret",
+		}, buf)
+		server.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := 5
+		if !historic {
+			want = 1
+		}
+		if len(requested) != want {
+			t.Fatalf("historic=%v: expected %d reports, requested %v", historic, want, requested)
+		}
+
+		gz, err := gzip.NewReader(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr := tar.NewReader(gz)
+		for i := 0; ; i++ {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				if i != want {
+					t.Errorf("historic=%v: expected %d entries, got %d", historic, want, i)
+				}
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			ts, err := time.Parse(time.RFC3339, requested[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if name := strconv.FormatInt(ts.UnixNano(), 10) + ".json"; hdr.Name != name {
+				t.Errorf("expected entry %s, got %s", name, hdr.Name)
+			}
+			content, _ := ioutil.ReadAll(tr)
+			if string(content) != `{"ID":"`+requested[i]+`"}` {
+				t.Errorf("unexpected content of %s: %s", hdr.Name, content)
+			}
+		}
+	}
+}
+
+func TestBundleCollector(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api":
+			w.Write([]byte(`{"capabilities":{"` + xfer.HistoricReportsCapability + `":true}}`))
+		case "/api/report":
+			w.Write([]byte(`{"ID":"` + r.FormValue("timestamp") + `"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	f, err := ioutil.TempFile("", "scope-bundle")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	from := time.Unix(1500000000, 0).UTC()
+	err = app.ExportBundle(http.DefaultClient, app.BundleConfig{
+		AppURL: server.URL,
+		From:   from,
+		To:     from.Add(time.Minute),
+		Step:   15 * time.Second,
+	}, f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := app.NewBundleCollector(f.Name(), 15*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.HasHistoricReports() {
+		t.Error("expected historic reports")
+	}
+	ctx := context.Background()
+	for _, tc := range []struct {
+		at         time.Time
+		want       string
+		hasReports bool
+	}{
+		{from.Add(-time.Second), "", false},
+		{from, from.Format(time.RFC3339), true},
+		{from.Add(20 * time.Second), from.Add(15 * time.Second).Format(time.RFC3339), true},
+		{from.Add(time.Hour), from.Add(time.Minute).Format(time.RFC3339), true},
+	} {
+		rpt, err := c.Report(ctx, tc.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tc.want != "" && rpt.ID != tc.want {
+			t.Errorf("at %v: expected report %s, got %s", tc.at, tc.want, rpt.ID)
+		}
+		if has, _ := c.HasReports(ctx, tc.at); has != tc.hasReports {
+			t.Errorf("at %v: expected HasReports %v, got %v", tc.at, tc.hasReports, has)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Package client is a client of the API of the Scope app, for automation
+// and tools to list, render and stream the views, get the details of
+// nodes, and invoke their controls.
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/ugorji/go/codec"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/render/detailed"
+)
+
+const defaultTimeout = 30 * time.Second
+
+// Config configures a Client.
+type Config struct {
+	// URL of the app, e.g. http://localhost:4040
+	URL string
+	// Token, if any, is sent as a bearer token, for apps authenticating
+	// their users
+	Token string
+	// HTTPClient, if any, is used instead of a client timing out after 30s
+	HTTPClient *http.Client
+}
+
+// Client is a client of the API of an app. It is safe for concurrent use.
+type Client struct {
+	target url.URL
+	token  string
+	client *http.Client
+	dialer websocket.Dialer
+}
+
+// Error is the error of a request the app didn't respond to with a 2xx.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// New makes a Client of the app at the URL of the config.
+func New(cfg Config) (*Client, error) {
+	target, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q of app URL %q", target.Scheme, cfg.URL)
+	}
+	target.Path = strings.TrimSuffix(target.Path, "/")
+	httpClient := cfg.HTTPClient
+	if httpClient == nil {
+		httpClient = cleanhttp.DefaultClient()
+		httpClient.Timeout = defaultTimeout
+	}
+	return &Client{
+		target: *target,
+		token:  cfg.Token,
+		client: httpClient,
+		dialer: websocket.Dialer{HandshakeTimeout: defaultTimeout},
+	}, nil
+}
+
+// ListTopologies lists the views of the app, with their options and
+// sub-views.
+func (c *Client) ListTopologies(ctx context.Context) ([]app.APITopologyDesc, error) {
+	var topologies []app.APITopologyDesc
+	err := c.Query(ctx, "/api/topology", nil, &topologies)
+	return topologies, err
+}
+
+// GetView renders a view, with the given options, e.g. of its filters, or
+// a timestamp.
+func (c *Client) GetView(ctx context.Context, topologyID string, options url.Values) (app.APITopology, error) {
+	var topology app.APITopology
+	err := c.Query(ctx, "/api/topology/"+url.PathEscape(topologyID), options, &topology)
+	return topology, err
+}
+
+// GetNode gets the details of a node of a view.
+func (c *Client) GetNode(ctx context.Context, topologyID, nodeID string, options url.Values) (app.APINode, error) {
+	var node app.APINode
+	err := c.Query(ctx, "/api/topology/"+url.PathEscape(topologyID)+"/"+url.PathEscape(nodeID), options, &node)
+	return node, err
+}
+
+// StreamView streams the changes of a rendered view, calling f with each
+// of them, the first adding every node, until the context is done, f
+// fails or the connection is lost.
+func (c *Client) StreamView(ctx context.Context, topologyID string, options url.Values, f func(detailed.Diff) error) error {
+	target := c.target
+	if target.Scheme == "https" {
+		target.Scheme = "wss"
+	} else {
+		target.Scheme = "ws"
+	}
+	conn, resp, err := xfer.DialWS(&c.dialer, target.String()+"/api/topology/"+url.PathEscape(topologyID)+"/ws"+encode(options), c.headers())
+	if err != nil {
+		if resp != nil {
+			return responseError(resp)
+		}
+		return err
+	}
+	defer conn.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	for {
+		var diff detailed.Diff
+		if err := conn.ReadJSON(&diff); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		if err := f(diff); err != nil {
+			return err
+		}
+	}
+}
+
+// InvokeControl invokes a control of a node, through the probe it is
+// from, and returns the probe's response.
+func (c *Client) InvokeControl(ctx context.Context, probeID, nodeID, control string, args map[string]string) (xfer.Response, error) {
+	var body bytes.Buffer
+	if len(args) > 0 {
+		if err := codec.NewEncoder(&body, &codec.JsonHandle{}).Encode(args); err != nil {
+			return xfer.Response{}, err
+		}
+	}
+	path := "/api/control/" + url.PathEscape(probeID) + "/" + url.PathEscape(nodeID) + "/" + url.PathEscape(control)
+	var response xfer.Response
+	err := c.do(ctx, "POST", path, nil, &body, &response)
+	return response, err
+}
+
+// Query gets any other resource of the API, such as the changes of a view
+// over a time range, decoding the response into v.
+func (c *Client) Query(ctx context.Context, path string, params url.Values, v interface{}) error {
+	return c.do(ctx, "GET", path, params, nil, v)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, params url.Values, body io.Reader, v interface{}) error {
+	req, err := http.NewRequest(method, c.target.String()+path+encode(params), body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header = c.headers()
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return responseError(resp)
+	}
+	if v == nil {
+		return nil
+	}
+	return codec.NewDecoder(resp.Body, &codec.JsonHandle{}).Decode(v)
+}
+
+func (c *Client) headers() http.Header {
+	headers := http.Header{}
+	if c.token != "" {
+		headers.Set("Authorization", "Bearer "+c.token)
+	}
+	return headers
+}
+
+// responseError reads the error of a response, which the app encodes as
+// a JSON string.
+func responseError(resp *http.Response) error {
+	buf, _ := ioutil.ReadAll(resp.Body)
+	var message string
+	if err := codec.NewDecoderBytes(buf, &codec.JsonHandle{}).Decode(&message); err != nil {
+		message = strings.TrimSpace(string(buf))
+	}
+	return &Error{StatusCode: resp.StatusCode, Message: message}
+}
+
+func encode(params url.Values) string {
+	if len(params) == 0 {
+		return ""
+	}
+	return "?" + params.Encode()
+}
@@ -0,0 +1,116 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/app/auth"
+	"github.com/weaveworks/scope/app/client"
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+type echoControlRouter struct{}
+
+func (echoControlRouter) Handle(_ context.Context, probeID string, req xfer.Request) (xfer.Response, error) {
+	return xfer.Response{Value: probeID + " " + req.NodeID + " " + req.Control + " " + req.ControlArgs["arg"]}, nil
+}
+
+func (echoControlRouter) Register(context.Context, string, xfer.ControlHandlerFunc) (int64, error) {
+	return 0, nil
+}
+
+func (echoControlRouter) Deregister(context.Context, string, int64) error { return nil }
+
+func server(t *testing.T) *httptest.Server {
+	tokens, err := auth.ParseTokens(strings.NewReader("secret admin\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := mux.NewRouter().SkipClean(true)
+	app.RegisterTopologyRoutes(router, app.StaticCollector(fixture.Report), nil)
+	app.RegisterControlRoutes(router, echoControlRouter{})
+	return httptest.NewServer(auth.NewAuthenticator(auth.Config{Tokens: tokens}).Wrap(router))
+}
+
+func isStatus(err error, code int) bool {
+	clientErr, ok := err.(*client.Error)
+	return ok && clientErr.StatusCode == code
+}
+
+func TestClient(t *testing.T) {
+	ts := server(t)
+	defer ts.Close()
+	c, err := client.New(client.Config{URL: ts.URL, Token: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	topologies, err := c.ListTopologies(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(topologies) == 0 {
+		t.Error("expected topologies")
+	}
+
+	view, err := c.GetView(ctx, "processes", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := view.Nodes[fixture.ServerProcessNodeID]; !ok {
+		t.Errorf("expected the server process, have %v", view.Nodes)
+	}
+
+	node, err := c.GetNode(ctx, "processes", fixture.ServerProcessNodeID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Node.Label != fixture.ServerName {
+		t.Errorf("want %q, have %q", fixture.ServerName, node.Node.Label)
+	}
+
+	if _, err := c.GetNode(ctx, "processes", "nope", nil); !isStatus(err, http.StatusNotFound) {
+		t.Errorf("expected a 404, have %v", err)
+	}
+
+	response, err := c.InvokeControl(ctx, "probe", fixture.ServerProcessNodeID, "ctrl", map[string]string{"arg": "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "probe " + fixture.ServerProcessNodeID + " ctrl x"; response.Value != want {
+		t.Errorf("want %q, have %q", want, response.Value)
+	}
+
+	stop := errors.New("stop")
+	err = c.StreamView(ctx, "processes", nil, func(diff detailed.Diff) error {
+		if len(diff.Add) != len(view.Nodes) {
+			t.Errorf("expected every node to be added first, have %d", len(diff.Add))
+		}
+		return stop
+	})
+	if err != stop {
+		t.Errorf("expected the stream to stop, have %v", err)
+	}
+}
+
+func TestClientUnauthorized(t *testing.T) {
+	ts := server(t)
+	defer ts.Close()
+	c, err := client.New(client.Config{URL: ts.URL, Token: "wrong"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ListTopologies(context.Background()); !isStatus(err, http.StatusUnauthorized) {
+		t.Errorf("expected a 401, have %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package app
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/report"
+)
+
+// ClockSkewThreshold is how far off the app's the clocks of probes can
+// be before their hosts are flagged.
+var ClockSkewThreshold = 2 * time.Second
+
+// minClockSkewCorrection is the offset below which reports aren't
+// corrected: estimates are as rough as the round trips they come from,
+// and correcting a report costs re-encoding it.
+const minClockSkewCorrection = time.Second
+
+// ClockSkew is the key of the skew of the clocks of the flagged hosts.
+const ClockSkew = "host_clock_skew"
+
+var clockSkewTemplates = report.MetadataTemplates{
+	ClockSkew: {ID: ClockSkew, Label: "Clock skew", From: report.FromLatest, Priority: 17},
+}
+
+var skewedReports = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "scope",
+	Name:      "reports_clock_skewed_total",
+	Help:      "Total count of reports from probes whose clocks are off by more than the threshold.",
+})
+
+func init() {
+	prometheus.MustRegister(skewedReports)
+}
+
+// correctClockSkew shifts the timestamps of a report by the offset of
+// the app's clock from the probe's the probe estimated, if more than a
+// second, flagging the hosts of the report if the offset exceeds the
+// threshold. It returns whether the report changed.
+func correctClockSkew(r *http.Request, rpt *report.Report) bool {
+	offset, err := time.ParseDuration(r.Header.Get(xfer.ScopeProbeClockOffsetHeader))
+	if err != nil || (offset > -minClockSkewCorrection && offset < minClockSkewCorrection) {
+		return false
+	}
+	rpt.ShiftTimestamps(offset)
+	if offset > -ClockSkewThreshold && offset < ClockSkewThreshold {
+		return true
+	}
+	skewedReports.Inc()
+	skew := (-offset).String()
+	for id, node := range rpt.Host.Nodes {
+		rpt.Host.Nodes[id] = node.WithLatest(ClockSkew, rpt.Timestamp, skew)
+	}
+	rpt.Host = rpt.Host.WithMetadataTemplates(clockSkewTemplates)
+	return true
+}
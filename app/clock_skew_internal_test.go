@@ -0,0 +1,47 @@
+package app
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestCorrectClockSkew(t *testing.T) {
+	now := time.Now()
+	for _, c := range []struct {
+		offset  string
+		changed bool
+		flagged string
+	}{
+		{"", false, ""},
+		{"500ms", false, ""},
+		{"-900ms", false, ""},
+		{"1500ms", true, ""},
+		{"-1m", true, "1m0s"},
+	} {
+		rpt := report.MakeReport()
+		rpt.Timestamp = now
+		rpt.Host.AddNode(report.MakeNode("host").WithLatest("foo", now, "bar"))
+		r := httptest.NewRequest("POST", "/api/report", nil)
+		if c.offset != "" {
+			r.Header.Set(xfer.ScopeProbeClockOffsetHeader, c.offset)
+		}
+
+		if have := correctClockSkew(r, &rpt); have != c.changed {
+			t.Errorf("%q: want changed %v, have %v", c.offset, c.changed, have)
+		}
+		offset, _ := time.ParseDuration(c.offset)
+		if !c.changed {
+			offset = 0
+		}
+		if want := now.Add(offset); !rpt.Timestamp.Equal(want) {
+			t.Errorf("%q: want timestamp %v, have %v", c.offset, want, rpt.Timestamp)
+		}
+		if have, _ := rpt.Host.Nodes["host"].Latest.Lookup(ClockSkew); have != c.flagged {
+			t.Errorf("%q: want skew %q, have %q", c.offset, c.flagged, have)
+		}
+	}
+}
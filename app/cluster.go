@@ -0,0 +1,281 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/report"
+)
+
+var clusterReportsFetched = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "scope",
+	Name:      "cluster_reports_fetched_total",
+	Help:      "Total count of the reports fetched from other replicas of the app, by result.",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(clusterReportsFetched)
+}
+
+// ClusterConfig configures the sharing of reports between the replicas
+// of an app.
+type ClusterConfig struct {
+	// Peers are the base URLs of the other replicas
+	Peers []string
+	// Fanout is how many peers are gossiped with every interval; 0 is
+	// all of them.
+	Fanout int
+	// Interval between rounds of gossip
+	Interval time.Duration
+	// Token is sent to peers as a bearer token, if set
+	Token string
+}
+
+// Cluster is a Collector sharing the reports it receives with the other
+// replicas of the app, for a load balancer to split probes across
+// replicas while any of them answers with the complete picture. Every
+// interval, it gossips with some of its peers: it gets the digest of the
+// reports they hold, received from probes or learnt from other peers,
+// and fetches those it misses. Reports spread to every replica within a
+// few rounds, and are forgotten after the window from when they were
+// published, as every replica tells, so that none gets them back.
+type Cluster struct {
+	Collector
+	cfg    ClusterConfig
+	window time.Duration
+	client *http.Client
+
+	mtx     sync.Mutex
+	reports map[string]*clusterReport // by report ID
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+type clusterReport struct {
+	buf      []byte    // gzip'd msgpack
+	received time.Time // when published, or received by the first replica
+}
+
+// ClusterDigestEntry is a report held by a replica, and when it was
+// published, or received from its probe if it doesn't tell.
+type ClusterDigestEntry struct {
+	ID       string    `json:"id"`
+	Received time.Time `json:"received"`
+}
+
+// NewCluster makes a new Cluster of the reports added to the collector,
+// kept for the window, and starts gossiping with the peers.
+func NewCluster(collector Collector, window time.Duration, cfg ClusterConfig) *Cluster {
+	c := &Cluster{
+		Collector: collector,
+		cfg:       cfg,
+		window:    window,
+		client:    &http.Client{Timeout: cfg.Interval},
+		reports:   map[string]*clusterReport{},
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go c.loop()
+	return c
+}
+
+// Stop stops gossiping.
+func (c *Cluster) Stop() {
+	close(c.quit)
+	<-c.done
+}
+
+func (c *Cluster) loop() {
+	defer close(c.done)
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.gossip()
+		case <-c.quit:
+			return
+		}
+	}
+}
+
+// Add implements Adder, keeping the report for the peers.
+func (c *Cluster) Add(ctx context.Context, rpt report.Report, buf []byte) error {
+	if err := c.Collector.Add(ctx, rpt, buf); err != nil {
+		return err
+	}
+	if buf == nil {
+		encoded, err := rpt.WriteBinary()
+		if err != nil {
+			return err
+		}
+		buf = encoded.Bytes()
+	}
+	c.keep(rpt.ID, buf, reportTimestamp(rpt, mtime.Now()))
+	return nil
+}
+
+// keep holds the report, received at the time, for the window after it,
+// forgetting those older.
+func (c *Cluster) keep(id string, buf []byte, received time.Time) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	now := mtime.Now()
+	if now.Sub(received) <= c.window {
+		c.reports[id] = &clusterReport{buf: buf, received: received}
+	}
+	for id, r := range c.reports {
+		if now.Sub(r.received) > c.window {
+			delete(c.reports, id)
+		}
+	}
+}
+
+// digest returns the reports held, of the window.
+func (c *Cluster) digest(now time.Time) []ClusterDigestEntry {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	entries := make([]ClusterDigestEntry, 0, len(c.reports))
+	for id, r := range c.reports {
+		if now.Sub(r.received) <= c.window {
+			entries = append(entries, ClusterDigestEntry{ID: id, Received: r.received})
+		}
+	}
+	return entries
+}
+
+// missing returns the reports of the digest of a peer which aren't held,
+// but for those older than the window, which were or would have been
+// forgotten.
+func (c *Cluster) missing(digest []ClusterDigestEntry, now time.Time) []ClusterDigestEntry {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	var entries []ClusterDigestEntry
+	for _, entry := range digest {
+		if _, ok := c.reports[entry.ID]; !ok && now.Sub(entry.Received) <= c.window {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func (c *Cluster) lookup(id string) ([]byte, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	r, ok := c.reports[id]
+	if !ok {
+		return nil, false
+	}
+	return r.buf, true
+}
+
+// gossip fetches the reports missed of some of the peers.
+func (c *Cluster) gossip() {
+	peers := append([]string{}, c.cfg.Peers...)
+	rand.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+	if c.cfg.Fanout > 0 && len(peers) > c.cfg.Fanout {
+		peers = peers[:c.cfg.Fanout]
+	}
+	for _, peer := range peers {
+		if err := c.sync(peer); err != nil {
+			log.Warnf("Error gossiping with %s: %v", peer, err)
+		}
+	}
+}
+
+func (c *Cluster) sync(peer string) error {
+	var digest []ClusterDigestEntry
+	if err := c.get(peer, "/api/cluster/digest", func(body []byte) error {
+		return json.Unmarshal(body, &digest)
+	}); err != nil {
+		return err
+	}
+	for _, entry := range c.missing(digest, mtime.Now()) {
+		entry := entry
+		err := c.get(peer, "/api/cluster/reports/"+url.PathEscape(entry.ID), func(body []byte) error {
+			rpt, err := report.MakeFromBytes(body)
+			if err != nil {
+				return err
+			}
+			// Reports not telling when they were published are taken to
+			// have been when the first replica received them, rather
+			// than now
+			if rpt.Timestamp.IsZero() {
+				rpt.Timestamp = entry.Received
+				encoded, err := rpt.WriteBinary()
+				if err != nil {
+					return err
+				}
+				body = encoded.Bytes()
+			}
+			// Straight to the collector, as the report was already seen
+			// by the replica it was published to
+			if err := c.Collector.Add(context.Background(), *rpt, body); err != nil {
+				return err
+			}
+			c.keep(entry.ID, body, entry.Received)
+			return nil
+		})
+		if err != nil {
+			clusterReportsFetched.WithLabelValues("error").Inc()
+			return err
+		}
+		clusterReportsFetched.WithLabelValues("fetched").Inc()
+	}
+	return nil
+}
+
+func (c *Cluster) get(peer, path string, f func([]byte) error) error {
+	req, err := http.NewRequest("GET", strings.TrimSuffix(peer, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	if c.cfg.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.cfg.Token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", path, resp.Status)
+	}
+	return f(body)
+}
+
+// RegisterClusterRoutes registers the routes peers gossip with.
+func RegisterClusterRoutes(router *mux.Router, c *Cluster) {
+	router.Methods("GET").Path("/api/cluster/digest").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respondWith(w, http.StatusOK, c.digest(mtime.Now()))
+	})
+	router.Methods("GET").Path("/api/cluster/reports/{id}").HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf, ok := c.lookup(mux.Vars(r)["id"])
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		// Not served with Content-Encoding: gzip, which the client of
+		// the peer would decompress
+		w.Header().Set("Content-Type", "application/msgpack")
+		w.Write(buf)
+	})
+}
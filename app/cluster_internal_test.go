@@ -0,0 +1,110 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestClusterDigest(t *testing.T) {
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	// Gossiping only when asked to
+	cluster := NewCluster(NewCollector(time.Minute), time.Minute, ClusterConfig{Interval: time.Hour})
+	defer cluster.Stop()
+	for _, id := range []string{"a", "b"} {
+		rpt := report.MakeReport()
+		rpt.ID = id
+		if err := cluster.Add(context.Background(), rpt, []byte(id)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	router := mux.NewRouter()
+	RegisterClusterRoutes(router, cluster)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/api/cluster/digest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var digest []ClusterDigestEntry
+	err = json.NewDecoder(resp.Body).Decode(&digest)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Slice(digest, func(i, j int) bool { return digest[i].ID < digest[j].ID })
+	if len(digest) != 2 || digest[0].ID != "a" || digest[1].ID != "b" || !digest[0].Received.Equal(now) {
+		t.Errorf("want a and b received now, have %v", digest)
+	}
+	peerDigest := []ClusterDigestEntry{
+		{ID: "a", Received: now},
+		{ID: "c", Received: now},
+		{ID: "d", Received: now.Add(-2 * time.Minute)}, // expired
+	}
+	if want, have := peerDigest[1:2], cluster.missing(peerDigest, now); !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	if resp, err := http.Get(server.URL + "/api/cluster/reports/c"); err != nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected reports not held not to be found, got %v, %v", resp, err)
+	}
+
+	// Reports are forgotten after the window
+	if have := cluster.digest(now.Add(2 * time.Minute)); len(have) != 0 {
+		t.Errorf("expected the reports to be forgotten, have %v", have)
+	}
+}
+
+func TestClusterDoesNotRefetchExpiredReports(t *testing.T) {
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	// b has a report published before a, which is yet to gossip
+	a := NewCluster(NewCollector(time.Minute), time.Minute, ClusterConfig{Interval: time.Hour})
+	defer a.Stop()
+	b := NewCluster(NewCollector(time.Minute), time.Minute, ClusterConfig{Interval: time.Hour})
+	defer b.Stop()
+	rpt := report.MakeReport()
+	rpt.ID = "old"
+	if err := b.Add(context.Background(), rpt, nil); err != nil {
+		t.Fatal(err)
+	}
+	router := mux.NewRouter()
+	RegisterClusterRoutes(router, b)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	mtime.NowForce(now.Add(30 * time.Second))
+	if err := a.sync(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if have := a.digest(mtime.Now()); len(have) != 1 || !have[0].Received.Equal(now) {
+		t.Errorf("expected the report kept as received by b, have %v", have)
+	}
+
+	// After the window of its publication, a forgets it, and doesn't
+	// fetch it back from b
+	mtime.NowForce(now.Add(61 * time.Second))
+	a.keep("other", nil, mtime.Now())
+	if err := a.sync(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if have := a.digest(mtime.Now()); len(have) != 1 || have[0].ID != "other" {
+		t.Errorf("expected the expired report not to be fetched again, have %v", have)
+	}
+}
@@ -66,8 +66,12 @@ type collector struct {
 	reports    []report.Report
 	timestamps []time.Time
 	window     time.Duration
+	ttls       TopologyTTLs
+	retention  time.Duration // the longest of the window and the TTLs
 	cached     *report.Report
+	cachedTill time.Time // when a report of the cached one expires
 	merger     Merger
+	audit      bool // keep the reports apart, to audit their merge
 	waitableCondition
 }
 
@@ -102,25 +106,93 @@ func (wc *waitableCondition) Broadcast() {
 
 // NewCollector returns a collector ready for use.
 func NewCollector(window time.Duration) Collector {
+	return NewCollectorWithTTLs(window, nil)
+}
+
+// NewCollectorWithTTLs returns a collector whose nodes of the topologies
+// of ttls age out after their TTL, rather than after the window, e.g.
+// endpoints after seconds and hosts after minutes.
+func NewCollectorWithTTLs(window time.Duration, ttls TopologyTTLs) Collector {
 	return &collector{
-		window: window,
+		window:    window,
+		ttls:      ttls,
+		retention: ttls.retention(window),
 		waitableCondition: waitableCondition{
 			waiters: map[chan struct{}]struct{}{},
 		},
-		merger: NewFastMerger(),
+		merger: NewJoiningMerger(),
+	}
+}
+
+// TopologyTTLs are how long the nodes of topologies live after the last
+// report of them, by topology.
+type TopologyTTLs map[string]time.Duration
+
+// ParseTopologyTTLs parses comma-separated topology=duration pairs, e.g.
+// "endpoint=10s,host=5m".
+func ParseTopologyTTLs(s string) (TopologyTTLs, error) {
+	ttls := TopologyTTLs{}
+	if s == "" {
+		return ttls, nil
+	}
+	rpt := report.MakeReport()
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid topology TTL %q, must be topology=duration", pair)
+		}
+		topology := strings.TrimSpace(parts[0])
+		if _, ok := rpt.Topology(topology); !ok {
+			return nil, fmt.Errorf("unknown topology %q", topology)
+		}
+		ttl, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("invalid TTL %q of topology %s", parts[1], topology)
+		}
+		ttls[topology] = ttl
 	}
+	return ttls, nil
+}
+
+// retention is the longest of the window and the TTLs.
+func (t TopologyTTLs) retention(window time.Duration) time.Duration {
+	retention := window
+	for _, ttl := range t {
+		if ttl > retention {
+			retention = ttl
+		}
+	}
+	return retention
+}
+
+func (t TopologyTTLs) ttl(topology string, window time.Duration) time.Duration {
+	if ttl, ok := t[topology]; ok {
+		return ttl
+	}
+	return window
+}
+
+// SetTopologyTTLs replaces the TTLs of the topologies of the collector.
+// Reports already dropped, being older than the previous retention, are
+// not recovered.
+func (c *collector) SetTopologyTTLs(ttls TopologyTTLs) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.ttls = ttls
+	c.retention = ttls.retention(c.window)
+	c.cached = nil
 }
 
 // Add adds a report to the collector's internal state. It implements Adder.
 func (c *collector) Add(_ context.Context, rpt report.Report, _ []byte) error {
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
-	c.reports = append(c.reports, rpt)
-	c.timestamps = append(c.timestamps, mtime.Now())
+	c.insert(rpt, reportTimestamp(rpt, mtime.Now()))
 
+	// Backfilled reports older than the window are dropped right away
 	c.clean()
 	c.cached = nil
-	if rpt.Shortcut {
+	if rpt.Shortcut && !rpt.Backfill {
 		c.Broadcast()
 	}
 	return nil
@@ -132,13 +204,10 @@ func (c *collector) Report(_ context.Context, timestamp time.Time) (report.Repor
 	c.mtx.Lock()
 	defer c.mtx.Unlock()
 
-	// If the oldest report is still within range,
-	// and there is a cached report, return that.
-	if c.cached != nil && len(c.reports) > 0 {
-		oldest := timestamp.Add(-c.window)
-		if c.timestamps[0].After(oldest) {
-			return *c.cached, nil
-		}
+	// If no report expired since the cached report was merged, return
+	// that.
+	if c.cached != nil && len(c.reports) > 0 && timestamp.Before(c.cachedTill) {
+		return *c.cached, nil
 	}
 
 	c.clean()
@@ -148,11 +217,58 @@ func (c *collector) Report(_ context.Context, timestamp time.Time) (report.Repor
 		c.reports[i] = c.reports[i].Upgrade()
 	}
 
-	rpt := c.merger.Merge(c.reports)
+	rpt := c.merger.Merge(c.expire(timestamp))
 	c.cached = &rpt
 	return rpt, nil
 }
 
+// expire returns the reports without the nodes of the topologies whose
+// TTL they are older than, and notes when the next of them expires.
+// Reports older than the window only keep their topologies.
+func (c *collector) expire(timestamp time.Time) []report.Report {
+	c.cachedTill = time.Time{}
+	till := func(t time.Time) {
+		if t.After(timestamp) && (c.cachedTill.IsZero() || t.Before(c.cachedTill)) {
+			c.cachedTill = t
+		}
+	}
+	if len(c.ttls) == 0 {
+		if len(c.timestamps) > 0 {
+			till(c.timestamps[0].Add(c.window))
+		}
+		return c.reports
+	}
+	result := make([]report.Report, 0, len(c.reports))
+	for i, rpt := range c.reports {
+		age := timestamp.Sub(c.timestamps[i])
+		till(c.timestamps[i].Add(c.window))
+		kept := rpt
+		if age >= c.window {
+			kept = report.MakeReport()
+		}
+		var expired []string
+		kept.WalkPairedTopologies(&rpt, func(k, t *report.Topology) {
+			*k = *t
+		})
+		kept.WalkNamedTopologies(func(name string, t *report.Topology) {
+			ttl := c.ttls.ttl(name, c.window)
+			till(c.timestamps[i].Add(ttl))
+			if age >= ttl && len(t.Nodes) > 0 {
+				// kept is a copy, but shares the nodes of the report
+				t.Nodes = report.Nodes{}
+				expired = append(expired, name)
+			}
+		})
+		if len(expired) > 0 || age >= c.window {
+			// The ID of the merged report is of those merged, so reports
+			// expiring alike must be the same
+			kept.ID = fmt.Sprintf("%s-%t-%s", rpt.ID, age >= c.window, strings.Join(expired, ","))
+		}
+		result = append(result, kept)
+	}
+	return result
+}
+
 // HasReports indicates whether the collector contains reports between
 // timestamp-app.window and timestamp.
 func (c *collector) HasReports(ctx context.Context, timestamp time.Time) (bool, error) {
@@ -172,12 +288,38 @@ func (c *collector) HasHistoricReports() bool {
 	return false
 }
 
-// remove reports older than the app.window
+// reportTimestamp is when the report was published, if the probe says
+// so, or else when it arrived. Reports published after they arrived are
+// from probes with clocks ahead of the app's, so they're taken to have
+// been published as they arrived.
+func reportTimestamp(rpt report.Report, arrived time.Time) time.Time {
+	if rpt.Timestamp.IsZero() || rpt.Timestamp.After(arrived) {
+		return arrived
+	}
+	return rpt.Timestamp
+}
+
+// insert adds the report in timestamp order, so that reports arriving
+// out of order are merged as if they had arrived in order.
+func (c *collector) insert(rpt report.Report, timestamp time.Time) {
+	i := len(c.timestamps)
+	for i > 0 && c.timestamps[i-1].After(timestamp) {
+		i--
+	}
+	c.reports = append(c.reports, report.Report{})
+	copy(c.reports[i+1:], c.reports[i:])
+	c.reports[i] = rpt
+	c.timestamps = append(c.timestamps, time.Time{})
+	copy(c.timestamps[i+1:], c.timestamps[i:])
+	c.timestamps[i] = timestamp
+}
+
+// remove reports older than the app.window, or the longest TTL
 func (c *collector) clean() {
 	var (
 		cleanedReports    = make([]report.Report, 0, len(c.reports))
 		cleanedTimestamps = make([]time.Time, 0, len(c.timestamps))
-		oldest            = mtime.Now().Add(-c.window)
+		oldest            = mtime.Now().Add(-c.retention)
 	)
 	for i, r := range c.reports {
 		if c.timestamps[i].After(oldest) {
@@ -197,7 +339,7 @@ func (c *collector) clean() {
 // 2, 5, 6, 7], the result contains merged reports with
 // timestamps/content of [0:{0,1,2}, 5:{5,6,7}].
 func (c *collector) quantise() {
-	if len(c.reports) == 0 {
+	if len(c.reports) == 0 || c.audit {
 		return
 	}
 	var (
@@ -0,0 +1,203 @@
+package app_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/report"
+)
+
+// The simulation drives a collector through seeded interleavings of
+// probes publishing, late and backfilled reports among them, the UI
+// querying and waiting on shortcut reports, and the clock moving on so
+// that reports age out, one step at a time. After each query it checks
+// that no report was merged twice, that none of the window was lost, and
+// that none older than the window was kept. A failure is reproduced by
+// its seed.
+const (
+	simWindow  = 15 * time.Second
+	simProbes  = 3
+	simSteps   = 500
+	simSeeds   = 20
+	simMaxLag  = 2 * time.Second
+	simCopies  = "copies"
+	simQuantum = 3 * time.Second // reportQuantisationInterval
+)
+
+type simReport struct {
+	id        string
+	timestamp time.Time
+}
+
+type simulation struct {
+	t         *testing.T
+	seed      int64
+	rand      *rand.Rand
+	collector app.Collector
+	now       time.Time
+	published []simReport
+	waiter    chan struct{}
+	waiting   bool
+	trace     []string
+}
+
+func TestCollectorSimulation(t *testing.T) {
+	defer mtime.NowReset()
+	for _, audit := range []bool{false, true} {
+		for seed := int64(0); seed < simSeeds; seed++ {
+			c := app.NewCollector(simWindow)
+			if audit {
+				c.(interface {
+					EnableMergeAudit()
+				}).EnableMergeAudit()
+			}
+			s := &simulation{
+				t:         t,
+				seed:      seed,
+				rand:      rand.New(rand.NewSource(seed)),
+				collector: c,
+				now:       time.Unix(1500000000, 0),
+				waiter:    make(chan struct{}, 1),
+			}
+			s.run()
+			if t.Failed() {
+				return
+			}
+		}
+	}
+}
+
+func (s *simulation) run() {
+	mtime.NowForce(s.now)
+	for step := 0; step < simSteps && !s.t.Failed(); step++ {
+		switch n := s.rand.Intn(10); {
+		case n < 5:
+			s.publish(fmt.Sprintf("probe-%d", s.rand.Intn(simProbes)))
+		case n < 7:
+			s.query()
+		case n < 9:
+			s.tick()
+		default:
+			s.wait()
+		}
+	}
+}
+
+// publish adds a report of the probe, published as it arrives, a little
+// before, or, if backfilled, up to twice the window before.
+func (s *simulation) publish(probe string) {
+	var (
+		id  = fmt.Sprintf("report-%d", len(s.published))
+		rpt = report.MakeReport()
+		lag = time.Duration(s.rand.Int63n(int64(simMaxLag)))
+	)
+	rpt.Host.AddNode(report.MakeNode(report.MakeHostNodeID(probe)))
+	marker := report.MakeNode(id)
+	marker.Counters = marker.Counters.Add(simCopies, 1)
+	rpt.Process.AddNode(marker)
+	switch n := s.rand.Intn(10); {
+	case n == 0:
+		rpt.Backfill = true
+		lag = time.Duration(s.rand.Int63n(int64(2 * simWindow)))
+	case n < 3:
+		rpt.Shortcut = true
+	}
+	rpt.Timestamp = s.now.Add(-lag)
+	s.record("%s publishes %s at -%s (shortcut %t, backfill %t)", probe, id, lag, rpt.Shortcut, rpt.Backfill)
+
+	if err := s.collector.Add(context.Background(), rpt, nil); err != nil {
+		s.fail("publishing: %v", err)
+		return
+	}
+	s.published = append(s.published, simReport{id: id, timestamp: rpt.Timestamp})
+
+	notified := false
+	select {
+	case <-s.waiter:
+		notified = true
+	default:
+	}
+	if want := s.waiting && rpt.Shortcut && !rpt.Backfill; want != notified {
+		s.fail("want waiter notified %t, was %t", want, notified)
+	}
+}
+
+// query merges the report of now, and checks each report published is
+// merged once if of the window, or not at all if older. Reports are
+// quantised with those of the reportQuantisationInterval from the first
+// of them, which may be late, so may age out early: by up to two
+// intervals in these interleavings.
+func (s *simulation) query() {
+	s.record("query")
+	rpt, err := s.collector.Report(context.Background(), s.now)
+	if err != nil {
+		s.fail("querying: %v", err)
+		return
+	}
+	anyPresent, anyLive := false, false
+	for _, p := range s.published {
+		copies := 0
+		if n, ok := rpt.Process.Nodes[p.id]; ok {
+			copies, _ = n.Counters.Lookup(simCopies)
+		}
+		age := s.now.Sub(p.timestamp)
+		switch {
+		case copies > 1:
+			s.fail("%s merged %d times", p.id, copies)
+		case age >= simWindow && copies != 0:
+			s.fail("%s, %s old, outlived the window", p.id, age)
+		case age < simWindow-2*simQuantum && copies != 1:
+			s.fail("%s, %s old, lost", p.id, age)
+		}
+		anyPresent = anyPresent || age < simWindow-2*simQuantum
+		anyLive = anyLive || age < simWindow
+	}
+	if len(rpt.Process.Nodes) > len(s.published) {
+		s.fail("merged %d reports, of %d published", len(rpt.Process.Nodes), len(s.published))
+	}
+
+	has, err := s.collector.HasReports(context.Background(), s.now)
+	if err != nil {
+		s.fail("checking for reports: %v", err)
+	} else if (anyPresent && !has) || (!anyLive && has) {
+		s.fail("has reports %t, with reports of the window %t", has, anyPresent)
+	}
+}
+
+// tick moves the clock on, by up to a fifth of the window, so that
+// reports age out.
+func (s *simulation) tick() {
+	d := time.Duration(s.rand.Int63n(int64(simWindow / 5)))
+	s.record("tick %s", d)
+	s.now = s.now.Add(d)
+	mtime.NowForce(s.now)
+}
+
+// wait starts or stops waiting on shortcut reports.
+func (s *simulation) wait() {
+	s.waiting = !s.waiting
+	s.record("waiting %t", s.waiting)
+	if s.waiting {
+		s.collector.WaitOn(context.Background(), s.waiter)
+	} else {
+		s.collector.UnWait(context.Background(), s.waiter)
+	}
+}
+
+func (s *simulation) record(format string, args ...interface{}) {
+	s.trace = append(s.trace, fmt.Sprintf(format, args...))
+}
+
+func (s *simulation) fail(format string, args ...interface{}) {
+	trace := s.trace
+	if len(trace) > 20 {
+		trace = trace[len(trace)-20:]
+	}
+	s.t.Errorf("seed %d, step %d: %s; after:\n\t%s", s.seed, len(s.trace), fmt.Sprintf(format, args...), strings.Join(trace, "\n\t"))
+}
@@ -112,6 +112,53 @@ func TestCollectorExpire(t *testing.T) {
 	}
 }
 
+func TestCollectorTopologyTTLs(t *testing.T) {
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	ctx := context.Background()
+	ttls, err := app.ParseTopologyTTLs("endpoint=5s, host=1m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := app.NewCollectorWithTTLs(10*time.Second, ttls)
+
+	r := report.MakeReport()
+	r.Endpoint.AddNode(report.MakeNode("foo"))
+	r.Process.AddNode(report.MakeNode("bar"))
+	r.Host.AddNode(report.MakeNode("baz"))
+	c.Add(ctx, r, nil)
+
+	for _, tc := range []struct {
+		after                       time.Duration
+		endpoints, processes, hosts int
+	}{
+		{0, 1, 1, 1},
+		{6 * time.Second, 0, 1, 1},
+		{30 * time.Second, 0, 0, 1},
+		{61 * time.Second, 0, 0, 0},
+	} {
+		mtime.NowForce(now.Add(tc.after))
+		have, err := c.Report(ctx, mtime.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(have.Endpoint.Nodes) != tc.endpoints || len(have.Process.Nodes) != tc.processes || len(have.Host.Nodes) != tc.hosts {
+			t.Errorf("after %v: want %d endpoints, %d processes and %d hosts, have %d, %d and %d", tc.after,
+				tc.endpoints, tc.processes, tc.hosts, len(have.Endpoint.Nodes), len(have.Process.Nodes), len(have.Host.Nodes))
+		}
+	}
+}
+
+func TestParseTopologyTTLs(t *testing.T) {
+	for _, s := range []string{"endpoint", "nonesuch=1s", "host=forever", "host=-1s"} {
+		if _, err := app.ParseTopologyTTLs(s); err == nil {
+			t.Errorf("expected %q to be invalid", s)
+		}
+	}
+}
+
 func TestCollectorWait(t *testing.T) {
 	ctx := context.Background()
 	window := time.Millisecond
@@ -130,3 +177,41 @@ func TestCollectorWait(t *testing.T) {
 		t.Fatal("Didn't unblock")
 	}
 }
+
+func TestCollectorOrdersByPublication(t *testing.T) {
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	ctx := context.Background()
+	window := 10 * time.Second
+	c := app.NewCollector(window)
+
+	// r1 was published before r2, but arrives after it
+	r1 := report.MakeReport()
+	r1.Endpoint.AddNode(report.MakeNode("foo"))
+	r1.Timestamp = now.Add(-window + time.Second)
+	r2 := report.MakeReport()
+	r2.Endpoint.AddNode(report.MakeNode("bar"))
+	r2.Timestamp = now
+	c.Add(ctx, r2, nil)
+	c.Add(ctx, r1, nil)
+
+	have, err := c.Report(ctx, mtime.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(have.Endpoint.Nodes) != 2 {
+		t.Errorf("want both reports, have %v", have.Endpoint.Nodes)
+	}
+
+	// r1 expires a window after it was published, not after it arrived
+	mtime.NowForce(now.Add(time.Second))
+	have, err = c.Report(ctx, mtime.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := have.Endpoint.Nodes["foo"]; ok || len(have.Endpoint.Nodes) != 1 {
+		t.Errorf("want only r2, have %v", have.Endpoint.Nodes)
+	}
+}
@@ -0,0 +1,203 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+	"github.com/weaveworks/common/mtime"
+
+	"github.com/weaveworks/scope/app/auth"
+)
+
+const (
+	maxDeploymentsPerOrg    = 10000
+	maxDeploymentBodyLength = 16 * 1024
+	// Deployments this old are forgotten
+	deploymentExpiry = 30 * 24 * time.Hour
+	// How far before the time of a topology its deployments go back
+	deploymentLookback = time.Hour
+)
+
+// deployments are returned with topologies and their changes. It is nil
+// (and deployments disabled) by default.
+var (
+	deployments      *Deployments
+	deploymentsOrgID func(context.Context) (string, error)
+)
+
+// EnableDeployments turns on returning the deployments of the org of
+// requests, as told by orgID, with topologies and their changes.
+func EnableDeployments(d *Deployments, orgID func(context.Context) (string, error)) {
+	deployments, deploymentsOrgID = d, orgID
+}
+
+// Deployment is a marker of a version of a service being deployed, as
+// registered by CI, so that topology changes can be correlated with
+// deploys. It happened at Time, or when registered if not given.
+type Deployment struct {
+	ID      string    `json:"id"`
+	Service string    `json:"service"`
+	Version string    `json:"version"`
+	Time    time.Time `json:"time"`
+	Link    string    `json:"link,omitempty"`
+	Author  string    `json:"author"`
+}
+
+func (d Deployment) validate() error {
+	if d.Service == "" || d.Version == "" {
+		return fmt.Errorf("deployments need a service and a version")
+	}
+	return nil
+}
+
+type deploymentsByTime []Deployment
+
+func (d deploymentsByTime) Len() int      { return len(d) }
+func (d deploymentsByTime) Swap(i, j int) { d[i], d[j] = d[j], d[i] }
+func (d deploymentsByTime) Less(i, j int) bool {
+	if !d[i].Time.Equal(d[j].Time) {
+		return d[i].Time.Before(d[j].Time)
+	}
+	return d[i].ID < d[j].ID
+}
+
+// Deployments stores the deployments of every org, persisting them to a
+// file if given one.
+type Deployments struct {
+	store *orgStore
+}
+
+// NewDeployments makes a new Deployments, loading any deployments
+// previously saved at path. With no path, deployments are kept in memory
+// only.
+func NewDeployments(path string) (*Deployments, error) {
+	store, err := newOrgStore(path, maxDeploymentsPerOrg, errDeploymentNotFound, errTooManyDeployments, func(buf []byte) (interface{}, error) {
+		var deployment Deployment
+		err := json.Unmarshal(buf, &deployment)
+		return deployment, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Deployments{store: store}, nil
+}
+
+// Between returns the deployments of an org from..to, by time,
+// optionally only those of a service.
+func (d *Deployments) Between(org, service string, from, to time.Time) []Deployment {
+	result := []Deployment{}
+	d.store.each(org, func(record interface{}) {
+		deployment := record.(Deployment)
+		if (service == "" || deployment.Service == service) && !deployment.Time.Before(from) && !deployment.Time.After(to) {
+			result = append(result, deployment)
+		}
+	})
+	sort.Sort(deploymentsByTime(result))
+	return result
+}
+
+// Add saves a new deployment of the user, forgetting the deployments of
+// long ago. Deployments are validated when decoded.
+func (d *Deployments) Add(org string, deployment Deployment, user auth.Identity) (Deployment, error) {
+	now := mtime.Now()
+	record, err := d.store.add(org, func(record interface{}) bool {
+		return now.Sub(record.(Deployment).Time) > deploymentExpiry
+	}, func(id string) interface{} {
+		deployment.ID, deployment.Author = id, user.Subject
+		if deployment.Time.IsZero() {
+			deployment.Time = now.UTC()
+		}
+		return deployment
+	})
+	if err != nil {
+		return Deployment{}, err
+	}
+	return record.(Deployment), nil
+}
+
+// Errors of the changes to deployments
+var (
+	errDeploymentNotFound = fmt.Errorf("no such deployment")
+	errTooManyDeployments = fmt.Errorf("at most %d deployments can be saved", maxDeploymentsPerOrg)
+)
+
+// Delete deletes a deployment, e.g. one registered by mistake.
+func (d *Deployments) Delete(org, id string) error {
+	return d.store.delete(org, id, nil)
+}
+
+// deploymentsBetween returns the deployments of the org of a request
+// from..to, if deployments are enabled.
+func deploymentsBetween(ctx context.Context, from, to time.Time) []Deployment {
+	if deployments == nil {
+		return nil
+	}
+	org, err := deploymentsOrgID(ctx)
+	if err != nil {
+		return nil
+	}
+	return deployments.Between(org, "", from, to)
+}
+
+// RegisterDeploymentRoutes registers the routes to list, add and delete
+// deployments. Deployments are listed from..to, an hour up to now by
+// default, optionally only those of a service. They are kept apart by
+// the org the request comes from, as told by orgID.
+func RegisterDeploymentRoutes(router *mux.Router, d *Deployments, orgID func(context.Context) (string, error)) {
+	router.Methods("GET").Path("/api/deployments").
+		HandlerFunc(requestContextDecorator(handleOrgRequest(orgID, func(w http.ResponseWriter, r *http.Request, org string, user auth.Identity) {
+			to, err := parseTimestamp(r.FormValue("to"))
+			if err != nil {
+				respondWith(w, http.StatusBadRequest, err)
+				return
+			}
+			from := to.Add(-deploymentLookback)
+			if r.FormValue("from") != "" {
+				if from, err = parseTimestamp(r.FormValue("from")); err != nil {
+					respondWith(w, http.StatusBadRequest, err)
+					return
+				}
+			}
+			respondWith(w, http.StatusOK, d.Between(org, r.FormValue("service"), from, to))
+		})))
+	router.Methods("POST").Path("/api/deployments").
+		HandlerFunc(requestContextDecorator(handleOrgRequest(orgID, func(w http.ResponseWriter, r *http.Request, org string, user auth.Identity) {
+			var deployment Deployment
+			if err := codec.NewDecoder(io.LimitReader(r.Body, maxDeploymentBodyLength), &codec.JsonHandle{}).Decode(&deployment); err != nil {
+				respondWith(w, http.StatusBadRequest, err)
+				return
+			}
+			if err := deployment.validate(); err != nil {
+				respondWith(w, http.StatusBadRequest, err)
+				return
+			}
+			deployment, err := d.Add(org, deployment, user)
+			if err == errTooManyDeployments {
+				respondWith(w, http.StatusBadRequest, err)
+				return
+			} else if err != nil {
+				respondWith(w, http.StatusInternalServerError, err)
+				return
+			}
+			respondWith(w, http.StatusCreated, deployment)
+		})))
+	router.Methods("DELETE").Path("/api/deployments/{id}").
+		HandlerFunc(requestContextDecorator(handleOrgRequest(orgID, func(w http.ResponseWriter, r *http.Request, org string, user auth.Identity) {
+			err := d.Delete(org, mux.Vars(r)["id"])
+			if err == errDeploymentNotFound {
+				respondWith(w, http.StatusNotFound, err)
+				return
+			} else if err != nil {
+				respondWith(w, http.StatusInternalServerError, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})))
+}
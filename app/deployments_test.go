@@ -0,0 +1,122 @@
+package app_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/app/auth"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+func TestDeployments(t *testing.T) {
+	dir, err := ioutil.TempDir("", "deployments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "deployments.json")
+	deployments, err := app.NewDeployments(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orgID := func(context.Context) (string, error) { return "org1", nil }
+	app.EnableDeployments(deployments, orgID)
+	defer app.EnableDeployments(nil, nil)
+
+	tokens, err := auth.ParseTokens(strings.NewReader("reader-token read-only\nci-token controls ci\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deployed := time.Date(2017, 1, 1, 12, 0, 0, 0, time.UTC)
+	router := mux.NewRouter().SkipClean(true)
+	app.RegisterTopologyRoutes(router, deployCollector{app.StaticCollector(fixture.Report), deployed}, map[string]bool{})
+	app.RegisterDeploymentRoutes(router, deployments, orgID)
+	ts := httptest.NewServer(auth.NewAuthenticator(auth.Config{Tokens: tokens}).Wrap(router))
+	defer ts.Close()
+
+	deployment := app.Deployment{Service: "apache", Version: "2.4.1", Time: deployed}
+	if code, _ := viewRequest(t, ts, "reader-token", "POST", "/api/deployments", deployment); code != http.StatusForbidden {
+		t.Errorf("expected readers not to register deployments, got %d", code)
+	}
+	if code, _ := viewRequest(t, ts, "ci-token", "POST", "/api/deployments", app.Deployment{Service: "apache"}); code != http.StatusBadRequest {
+		t.Errorf("expected deployments without a version to be rejected, got %d", code)
+	}
+	code, body := viewRequest(t, ts, "ci-token", "POST", "/api/deployments", deployment)
+	if code != http.StatusCreated {
+		t.Fatalf("expected the deployment to be registered, got %d %s", code, body)
+	}
+	var added app.Deployment
+	if err := json.Unmarshal(body, &added); err != nil {
+		t.Fatal(err)
+	}
+	if added.Author != "ci" {
+		t.Errorf("expected the deployment to be registered by ci, got %q", added.Author)
+	}
+
+	// Deployments are returned with the changes they may explain
+	code, body = viewRequest(t, ts, "reader-token", "GET", "/api/topology/processes/changes?from=2017-01-01T11:00:00Z&to=2017-01-01T13:00:00Z", nil)
+	if code != http.StatusOK {
+		t.Fatalf("expected the changes, got %d %s", code, body)
+	}
+	var changes app.APITopologyChanges
+	if err := codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&changes); err != nil {
+		t.Fatal(err)
+	}
+	if len(changes.Deployments) != 1 || changes.Deployments[0].ID != added.ID {
+		t.Errorf("expected the deployment with the changes, got %v", changes.Deployments)
+	}
+
+	// and with the topology at a time shortly after
+	code, body = viewRequest(t, ts, "reader-token", "GET", "/api/topology/processes?timestamp=2017-01-01T12:30:00Z", nil)
+	if code != http.StatusOK {
+		t.Fatalf("expected the topology, got %d %s", code, body)
+	}
+	var topology app.APITopology
+	if err := codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&topology); err != nil {
+		t.Fatal(err)
+	}
+	if len(topology.Deployments) != 1 {
+		t.Errorf("expected the deployment with the topology, got %v", topology.Deployments)
+	}
+
+	for query, want := range map[string]int{
+		"?from=2017-01-01T11:00:00Z&to=2017-01-01T13:00:00Z":               1,
+		"?from=2017-01-01T11:00:00Z&to=2017-01-01T13:00:00Z&service=mysql": 0,
+		"?from=2017-01-01T12:30:00Z&to=2017-01-01T13:00:00Z":               0,
+		"?to=2017-01-01T12:30:00Z":                                         1,
+	} {
+		code, body := viewRequest(t, ts, "reader-token", "GET", "/api/deployments"+query, nil)
+		var listed []app.Deployment
+		if code != http.StatusOK || json.Unmarshal(body, &listed) != nil || len(listed) != want {
+			t.Errorf("%s: expected %d deployments, got %d %s", query, want, code, body)
+		}
+	}
+
+	// Deployments outlive restarts
+	reloaded, err := app.NewDeployments(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := reloaded.Between("org1", "", deployed, deployed); len(have) != 1 {
+		t.Errorf("expected the deployment to be saved, got %v", have)
+	}
+
+	if code, _ := viewRequest(t, ts, "ci-token", "DELETE", "/api/deployments/"+added.ID, nil); code != http.StatusNoContent {
+		t.Errorf("expected the deployment to be deleted, got %d", code)
+	}
+	if code, _ := viewRequest(t, ts, "ci-token", "DELETE", "/api/deployments/"+added.ID, nil); code != http.StatusNotFound {
+		t.Errorf("expected the deployment to be gone, got %d", code)
+	}
+}
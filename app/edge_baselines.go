@@ -0,0 +1,348 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"net/url"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/weaveworks/common/mtime"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+)
+
+const (
+	// Weight given to each new observation in the moving averages
+	baselineAlpha = 0.1
+	// Number of observations needed before an edge can be flagged
+	baselineMinObservations = 10
+	// Lower bound on the standard deviation used to flag edges
+	baselineMinStdDev = 1.0
+	// Edges not observed, and views not requested, for this long are
+	// forgotten
+	baselineExpiry = 24 * time.Hour
+)
+
+// Metrics of the edges baselined
+const (
+	ConnectionsMetric = "connections"
+	BytesMetric       = "bytes"
+)
+
+// EdgeMetrics are the values of edges, by the metric baselined.
+type EdgeMetrics map[string]map[detailed.Edge]int
+
+// renderedEdgeMetrics are the connections between the rendered nodes,
+// and the bytes sent over the edges over which any were.
+func renderedEdgeMetrics(ns report.Nodes) EdgeMetrics {
+	return EdgeMetrics{
+		ConnectionsMetric: detailed.EdgeWeights(ns),
+		BytesMetric:       detailed.EdgeBytes(ns),
+	}
+}
+
+// edgeBaselines is used by the topology handlers to flag anomalous
+// edges. It is nil (and anomaly detection disabled) by default.
+var edgeBaselines *EdgeBaselines
+
+// EnableEdgeBaselines turns on anomaly flagging of edges in the
+// topology API, using the given tracker.
+func EnableEdgeBaselines(b *EdgeBaselines) {
+	edgeBaselines = b
+}
+
+// EdgeAnomaly describes an edge whose weight, connections or bytes
+// sent, deviates from its historical baseline.
+type EdgeAnomaly struct {
+	detailed.WeightedEdge
+	Metric string  `json:"metric"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+	// Sigmas is how many standard deviations Weight is from Mean;
+	// negative when there is less traffic than usual.
+	Sigmas float64 `json:"sigmas"`
+}
+
+type edgeBaseline struct {
+	// Topology is the key of the view of the edge: its topology, and
+	// the options it is rendered with, if any
+	Topology string `json:"topology"`
+	// Metric is that baselined, the connections if empty
+	Metric       string    `json:"metric,omitempty"`
+	Source       string    `json:"source"`
+	Target       string    `json:"target"`
+	Mean         float64   `json:"mean"`
+	Variance     float64   `json:"variance"`
+	Observations int       `json:"observations"`
+	LastSeen     time.Time `json:"lastSeen"`
+}
+
+func (b *edgeBaseline) observe(value float64) {
+	if b.Observations == 0 {
+		b.Mean = value
+	} else {
+		// Exponentially weighted moving average and variance
+		diff := value - b.Mean
+		incr := baselineAlpha * diff
+		b.Mean += incr
+		b.Variance = (1 - baselineAlpha) * (b.Variance + diff*incr)
+	}
+	b.Observations++
+}
+
+type edgeKey struct {
+	view   string
+	metric string
+	detailed.Edge
+}
+
+// baselineView is a view of a topology, rendered with the options of
+// its requests, as last requested.
+type baselineView struct {
+	topologyID string
+	options    url.Values
+	requested  time.Time
+}
+
+// viewKey is the key of the view of a topology rendered with the
+// options.
+func viewKey(topologyID string, options url.Values) string {
+	if len(options) == 0 {
+		return topologyID
+	}
+	return topologyID + "?" + options.Encode()
+}
+
+// EdgeBaselines tracks the moving average and variance of the
+// connections, and bytes sent, of every rendered edge, per view of a topology, and flags edges whose
+// current weight deviates from it. The baselines of the views requested
+// live are updated every interval, from the reports of the present,
+// rather than as they are requested. Baselines are optionally persisted
+// to a file, so they survive restarts.
+type EdgeBaselines struct {
+	mtx      sync.Mutex
+	path     string
+	sigmas   float64
+	interval time.Duration
+	edges    map[edgeKey]*edgeBaseline
+	views    map[string]*baselineView
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+// NewEdgeBaselines makes a new EdgeBaselines, loading any baselines
+// previously saved at path. Baselines are updated every interval, once
+// started, and edges are flagged once they deviate by more than sigmas
+// standard deviations.
+func NewEdgeBaselines(path string, sigmas float64, interval time.Duration) (*EdgeBaselines, error) {
+	b := &EdgeBaselines{
+		path:     path,
+		sigmas:   sigmas,
+		interval: interval,
+		edges:    map[edgeKey]*edgeBaseline{},
+		views:    map[string]*baselineView{},
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if path == "" {
+		return b, nil
+	}
+	buf, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return b, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var saved []edgeBaseline
+	if err := json.Unmarshal(buf, &saved); err != nil {
+		return nil, err
+	}
+	for i := range saved {
+		e := saved[i]
+		if e.Metric == "" {
+			e.Metric = ConnectionsMetric
+		}
+		b.edges[edgeKey{e.Topology, e.Metric, detailed.Edge{Source: e.Source, Target: e.Target}}] = &e
+	}
+	return b, nil
+}
+
+// Watch has the baselines of the view of the topology, rendered with
+// the options, updated. Views not requested for a day are forgotten.
+func (b *EdgeBaselines) Watch(topologyID string, options url.Values) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.views[viewKey(topologyID, options)] = &baselineView{
+		topologyID: topologyID,
+		options:    options,
+		requested:  mtime.Now(),
+	}
+}
+
+// Anomalies returns the edges of the view of the topology, rendered
+// with the options, which deviate from their baselines in any metric.
+func (b *EdgeBaselines) Anomalies(topologyID string, options url.Values, metrics EdgeMetrics) []EdgeAnomaly {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	view := viewKey(topologyID, options)
+	anomalies := []EdgeAnomaly{}
+	for metric, weights := range metrics {
+		for edge, weight := range weights {
+			baseline, ok := b.edges[edgeKey{view, metric, edge}]
+			if !ok || baseline.Observations < baselineMinObservations {
+				continue
+			}
+			stddev := math.Sqrt(baseline.Variance)
+			// Edges with a steady weight would otherwise be flagged for
+			// any change at all
+			sigmas := (float64(weight) - baseline.Mean) / math.Max(stddev, baselineMinStdDev)
+			if math.Abs(sigmas) <= b.sigmas {
+				continue
+			}
+			anomalies = append(anomalies, EdgeAnomaly{
+				WeightedEdge: detailed.WeightedEdge{Edge: edge, Weight: weight},
+				Metric:       metric,
+				Mean:         baseline.Mean,
+				StdDev:       stddev,
+				Sigmas:       sigmas,
+			})
+		}
+	}
+	sort.Sort(edgeAnomaliesByID(anomalies))
+	return anomalies
+}
+
+// Fold folds the metrics of the edges of the view of the topology,
+// rendered with the options, into their baselines.
+func (b *EdgeBaselines) Fold(topologyID string, options url.Values, metrics EdgeMetrics) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.update(viewKey(topologyID, options), metrics, mtime.Now())
+	b.expire(mtime.Now())
+}
+
+// Start updates the baselines of the views watched every interval,
+// from the reports of the present of the reporter, until stopped.
+func (b *EdgeBaselines) Start(reporter Reporter) {
+	go b.loop(reporter)
+}
+
+// Stop stops updating the baselines.
+func (b *EdgeBaselines) Stop() {
+	close(b.quit)
+	<-b.done
+}
+
+func (b *EdgeBaselines) loop(reporter Reporter) {
+	defer close(b.done)
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			rpt, err := reporter.Report(context.Background(), mtime.Now())
+			if err != nil {
+				log.Errorf("Error updating edge baselines: %v", err)
+				continue
+			}
+			b.tick(rpt)
+		case <-b.quit:
+			return
+		}
+	}
+}
+
+// tick folds the edges of the views watched, rendered from rpt, into
+// their baselines.
+func (b *EdgeBaselines) tick(rpt report.Report) {
+	now := mtime.Now()
+	b.mtx.Lock()
+	views := make([]baselineView, 0, len(b.views))
+	for key, view := range b.views {
+		if now.Sub(view.requested) > baselineExpiry {
+			delete(b.views, key)
+			continue
+		}
+		views = append(views, *view)
+	}
+	b.mtx.Unlock()
+
+	// Rendered without holding up the requests for anomalies
+	metrics := make([]EdgeMetrics, len(views))
+	for i, view := range views {
+		renderer, transformer, err := topologyRegistry.RendererForTopology(view.topologyID, view.options, rpt)
+		if err != nil {
+			continue
+		}
+		metrics[i] = renderedEdgeMetrics(render.Render(rpt, renderer, transformer).Nodes)
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	for i, view := range views {
+		if metrics[i] != nil {
+			b.update(viewKey(view.topologyID, view.options), metrics[i], now)
+		}
+	}
+	b.expire(now)
+}
+
+func (b *EdgeBaselines) update(view string, metrics EdgeMetrics, now time.Time) {
+	for metric, weights := range metrics {
+		for edge, weight := range weights {
+			key := edgeKey{view, metric, edge}
+			baseline, ok := b.edges[key]
+			if !ok {
+				baseline = &edgeBaseline{Topology: view, Metric: metric, Source: edge.Source, Target: edge.Target}
+				b.edges[key] = baseline
+			}
+			baseline.observe(float64(weight))
+			baseline.LastSeen = now
+		}
+	}
+}
+
+// expire forgets the edges not observed for the expiry, and saves the
+// baselines.
+func (b *EdgeBaselines) expire(now time.Time) {
+	for key, baseline := range b.edges {
+		if now.Sub(baseline.LastSeen) > baselineExpiry {
+			delete(b.edges, key)
+		}
+	}
+	if b.path != "" {
+		if err := b.save(); err != nil {
+			log.Errorf("Error saving edge baselines: %v", err)
+		}
+	}
+}
+
+// save writes the baselines to path.
+func (b *EdgeBaselines) save() error {
+	saved := make([]edgeBaseline, 0, len(b.edges))
+	for _, baseline := range b.edges {
+		saved = append(saved, *baseline)
+	}
+	return saveJSON(b.path, saved)
+}
+
+type edgeAnomaliesByID []EdgeAnomaly
+
+func (e edgeAnomaliesByID) Len() int      { return len(e) }
+func (e edgeAnomaliesByID) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e edgeAnomaliesByID) Less(i, j int) bool {
+	if e[i].Edge != e[j].Edge {
+		return e[i].Source < e[j].Source || (e[i].Source == e[j].Source && e[i].Target < e[j].Target)
+	}
+	return e[i].Metric < e[j].Metric
+}
@@ -0,0 +1,42 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weaveworks/common/mtime"
+
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+func TestEdgeBaselinesTick(t *testing.T) {
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	b, err := NewEdgeBaselines("", 3, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.tick(fixture.Report)
+	if len(b.edges) != 0 {
+		t.Fatalf("expected no baselines of views not watched, have %v", b.edges)
+	}
+
+	b.Watch(processesID, nil)
+	b.tick(fixture.Report)
+	if len(b.edges) == 0 {
+		t.Fatal("expected baselines of the edges of the view watched")
+	}
+	for key := range b.edges {
+		if key.view != processesID {
+			t.Errorf("expected baselines of %s only, have %s", processesID, key.view)
+		}
+	}
+
+	mtime.NowForce(now.Add(baselineExpiry + time.Minute))
+	b.tick(fixture.Report)
+	if len(b.views) != 0 || len(b.edges) != 0 {
+		t.Errorf("expected views not requested for a day forgotten, have %v", b.views)
+	}
+}
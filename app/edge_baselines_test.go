@@ -0,0 +1,86 @@
+package app_test
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/render/detailed"
+)
+
+func connections(edge detailed.Edge, n int) app.EdgeMetrics {
+	return app.EdgeMetrics{app.ConnectionsMetric: {edge: n}}
+}
+
+func TestEdgeBaselines(t *testing.T) {
+	dir, err := ioutil.TempDir("", "baselines")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "baselines.json")
+
+	edge := detailed.Edge{Source: "a", Target: "b"}
+	baselines, err := app.NewEdgeBaselines(path, 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		if anomalies := baselines.Anomalies("hosts", nil, connections(edge, 2)); len(anomalies) != 0 {
+			t.Fatalf("unexpected anomalies while building baseline: %v", anomalies)
+		}
+		baselines.Fold("hosts", nil, connections(edge, 2))
+	}
+
+	// Reload from disk, and check a spike is flagged
+	baselines, err = app.NewEdgeBaselines(path, 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	anomalies := baselines.Anomalies("hosts", nil, connections(edge, 20))
+	if len(anomalies) != 1 {
+		t.Fatalf("expected one anomaly, got %v", anomalies)
+	}
+	equals(t, edge, anomalies[0].Edge)
+	equals(t, 20, anomalies[0].Weight)
+	equals(t, app.ConnectionsMetric, anomalies[0].Metric)
+	assert(t, anomalies[0].Sigmas > 3, "expected sigmas > 3, got %v", anomalies[0].Sigmas)
+
+	// Baselines are per topology, and options it is rendered with
+	equals(t, 0, len(baselines.Anomalies("containers", nil, connections(edge, 20))))
+	equals(t, 0, len(baselines.Anomalies("hosts", url.Values{"stopped": {"running"}}, connections(edge, 20))))
+
+	// Asking for anomalies doesn't fold the weights in
+	equals(t, 1, len(baselines.Anomalies("hosts", nil, connections(edge, 20))))
+}
+
+func TestEdgeBaselinesOfBytes(t *testing.T) {
+	edge := detailed.Edge{Source: "a", Target: "b"}
+	baselines, err := app.NewEdgeBaselines("", 3, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		baselines.Fold("hosts", nil, app.EdgeMetrics{
+			app.ConnectionsMetric: {edge: 2},
+			app.BytesMetric:       {edge: 1000},
+		})
+	}
+
+	// The same connections sending far more bytes than usual are
+	// flagged, for the bytes only
+	anomalies := baselines.Anomalies("hosts", nil, app.EdgeMetrics{
+		app.ConnectionsMetric: {edge: 2},
+		app.BytesMetric:       {edge: 100000},
+	})
+	if len(anomalies) != 1 {
+		t.Fatalf("expected one anomaly, got %v", anomalies)
+	}
+	equals(t, app.BytesMetric, anomalies[0].Metric)
+	equals(t, 100000, anomalies[0].Weight)
+	equals(t, 1000.0, anomalies[0].Mean)
+}
@@ -0,0 +1,239 @@
+package app
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/probe/endpoint"
+	"github.com/weaveworks/scope/report"
+)
+
+var egressReattributed = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "scope",
+	Name:      "egress_connections_reattributed",
+	Help:      "Number of connections from shared egress IPs attributed to their sources in the last report.",
+})
+
+func init() {
+	prometheus.MustRegister(egressReattributed)
+}
+
+// EgressCorrelator is a Collector attributing the connections probed
+// servers see from the shared IPs of NAT gateways to the probed clients
+// behind them. A server sees such connections from the gateway, an
+// endpoint no probe reports, and the client sees them to the server,
+// which doesn't see them from the client: connections to the same server
+// endpoint, unexplained on either side, are paired by when they were
+// first seen, and the endpoint of the gateway is rendered as a copy of
+// the client's, as for connections NATed on probed hosts. Like the
+// ProbeMonitor, it is for single-tenant apps.
+type EgressCorrelator struct {
+	Collector
+	window    time.Duration
+	tolerance time.Duration
+
+	mtx    sync.Mutex
+	probes map[string]*probeFlows // by host node ID
+}
+
+// probeFlows are the connections to and from the endpoints of a probe,
+// as of its last report, with when they were first seen.
+type probeFlows struct {
+	timestamp time.Time
+	inbound   map[string]map[string]time.Time // unprobed clients, by local server endpoint
+	outbound  map[string]map[string]time.Time // local clients, by unprobed server endpoint
+}
+
+// NewEgressCorrelator makes a new EgressCorrelator of the reports added
+// to the collector, pairing connections first seen tolerance apart at
+// most, of the probes heard from in the window.
+func NewEgressCorrelator(collector Collector, window, tolerance time.Duration) *EgressCorrelator {
+	return &EgressCorrelator{
+		Collector: collector,
+		window:    window,
+		tolerance: tolerance,
+		probes:    map[string]*probeFlows{},
+	}
+}
+
+// Add implements Adder, recording the connections of the report.
+func (e *EgressCorrelator) Add(ctx context.Context, rpt report.Report, buf []byte) error {
+	if err := e.Collector.Add(ctx, rpt, buf); err != nil {
+		return err
+	}
+	var hostNodeID string
+	for id := range rpt.Host.Nodes {
+		hostNodeID = id
+		break
+	}
+	if hostNodeID == "" || len(rpt.Endpoint.Nodes) == 0 {
+		return nil
+	}
+	timestamp := rpt.Timestamp
+	if timestamp.IsZero() {
+		timestamp = mtime.Now()
+	}
+
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	previous := e.probes[hostNodeID]
+	if previous == nil {
+		previous = &probeFlows{}
+	} else if timestamp.Before(previous.timestamp) {
+		return nil
+	}
+	flows := &probeFlows{
+		timestamp: timestamp,
+		inbound:   map[string]map[string]time.Time{},
+		outbound:  map[string]map[string]time.Time{},
+	}
+	probed := func(id string) bool {
+		_, ok := rpt.Endpoint.Nodes[id].Latest.Lookup(report.HostNodeID)
+		return ok
+	}
+	for id, n := range rpt.Endpoint.Nodes {
+		local := probed(id)
+		for _, dstID := range n.Adjacency {
+			switch dstLocal := probed(dstID); {
+			case local && !dstLocal:
+				flows.outbound[dstID] = withFirstSeen(flows.outbound[dstID], id, previous.outbound[dstID], timestamp)
+			case !local && dstLocal:
+				flows.inbound[dstID] = withFirstSeen(flows.inbound[dstID], id, previous.inbound[dstID], timestamp)
+			}
+		}
+	}
+	e.probes[hostNodeID] = flows
+	for id, p := range e.probes {
+		if timestamp.Sub(p.timestamp) > e.window {
+			delete(e.probes, id)
+		}
+	}
+	return nil
+}
+
+func withFirstSeen(flows map[string]time.Time, id string, previous map[string]time.Time, timestamp time.Time) map[string]time.Time {
+	if flows == nil {
+		flows = map[string]time.Time{}
+	}
+	if firstSeen, ok := previous[id]; ok {
+		flows[id] = firstSeen
+	} else {
+		flows[id] = timestamp
+	}
+	return flows
+}
+
+// Report implements Reporter, making the endpoints of the gateways of
+// the connections paired copies of the endpoints of their clients.
+func (e *EgressCorrelator) Report(ctx context.Context, timestamp time.Time) (report.Report, error) {
+	rpt, err := e.Collector.Report(ctx, timestamp)
+	if err != nil {
+		return rpt, err
+	}
+	pairs := e.pairs(timestamp)
+	egressReattributed.Set(float64(len(pairs)))
+	if len(pairs) == 0 {
+		return rpt, nil
+	}
+	// The report may be cached by the collector, so it's copied before
+	// changing it
+	rpt.Endpoint = rpt.Endpoint.Copy()
+	for gatewayID, clientID := range pairs {
+		gateway, ok := rpt.Endpoint.Nodes[gatewayID]
+		client, found := rpt.Endpoint.Nodes[clientID]
+		if !ok || !found {
+			continue
+		}
+		copied := client.WithID(gatewayID).WithLatests(map[string]string{endpoint.CopyOf: clientID})
+		copied.Adjacency = gateway.Adjacency
+		rpt.Endpoint.Nodes[gatewayID] = copied
+	}
+	return rpt, nil
+}
+
+type firstSeen struct {
+	id   string
+	seen time.Time
+}
+
+// pairs returns the clients the connections from gateways are paired
+// with, by the endpoints of the gateways.
+func (e *EgressCorrelator) pairs(timestamp time.Time) map[string]string {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	var (
+		inbound  = map[string][]firstSeen{}     // unprobed clients, by server
+		outbound = map[string][]firstSeen{}     // probed clients, by server
+		seen     = map[string]map[string]bool{} // clients seen by servers
+		clients  = map[string]map[string]bool{} // clients seeing servers
+	)
+	for _, p := range e.probes {
+		if timestamp.Sub(p.timestamp) > e.window {
+			continue
+		}
+		for serverID, flows := range p.inbound {
+			for clientID, at := range flows {
+				inbound[serverID] = append(inbound[serverID], firstSeen{clientID, at})
+				addFlow(seen, serverID, clientID)
+			}
+		}
+		for serverID, flows := range p.outbound {
+			for clientID, at := range flows {
+				outbound[serverID] = append(outbound[serverID], firstSeen{clientID, at})
+				addFlow(clients, serverID, clientID)
+			}
+		}
+	}
+
+	result := map[string]string{}
+	for serverID, gateways := range inbound {
+		var unexplainedGateways, unexplainedClients []firstSeen
+		for _, g := range gateways {
+			if !clients[serverID][g.id] {
+				unexplainedGateways = append(unexplainedGateways, g)
+			}
+		}
+		for _, c := range outbound[serverID] {
+			if !seen[serverID][c.id] {
+				unexplainedClients = append(unexplainedClients, c)
+			}
+		}
+		sortFirstSeen(unexplainedGateways)
+		sortFirstSeen(unexplainedClients)
+		for i, j := 0, 0; i < len(unexplainedGateways) && j < len(unexplainedClients); {
+			g, c := unexplainedGateways[i], unexplainedClients[j]
+			switch d := g.seen.Sub(c.seen); {
+			case d > e.tolerance:
+				j++
+			case -d > e.tolerance:
+				i++
+			default:
+				result[g.id] = c.id
+				i++
+				j++
+			}
+		}
+	}
+	return result
+}
+
+func addFlow(flows map[string]map[string]bool, serverID, clientID string) {
+	if flows[serverID] == nil {
+		flows[serverID] = map[string]bool{}
+	}
+	flows[serverID][clientID] = true
+}
+
+func sortFirstSeen(fs []firstSeen) {
+	sort.Slice(fs, func(i, j int) bool {
+		if !fs[i].seen.Equal(fs[j].seen) {
+			return fs[i].seen.Before(fs[j].seen)
+		}
+		return fs[i].id < fs[j].id
+	})
+}
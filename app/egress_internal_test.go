@@ -0,0 +1,85 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/probe/endpoint"
+	"github.com/weaveworks/scope/report"
+)
+
+func egressReport(hostID string, timestamp time.Time, connections ...[2]string) report.Report {
+	var (
+		rpt        = report.MakeReport()
+		hostNodeID = report.MakeHostNodeID(hostID)
+	)
+	rpt.Timestamp = timestamp
+	rpt.Host.AddNode(report.MakeNode(hostNodeID))
+	for _, c := range connections {
+		// The local end of each connection is the first
+		rpt.Endpoint.AddNode(report.MakeNodeWith(c[0], map[string]string{report.HostNodeID: hostNodeID}))
+		rpt.Endpoint.AddNode(report.MakeNode(c[1]))
+	}
+	return rpt
+}
+
+func TestEgressCorrelator(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	var (
+		server   = report.MakeEndpointNodeID("", "", "10.0.0.1", "80")
+		direct   = report.MakeEndpointNodeID("", "", "10.0.1.1", "40000")
+		natted   = report.MakeEndpointNodeID("", "", "10.0.2.1", "40001")
+		gateway  = report.MakeEndpointNodeID("", "", "52.0.0.1", "60001")
+		external = report.MakeEndpointNodeID("", "", "52.0.0.2", "60002")
+	)
+	// The server sees the direct client, the gateway, and an unprobed
+	// client first seen much later
+	serverReport := egressReport("server", now, [2]string{server, direct}, [2]string{server, gateway})
+	for _, id := range []string{direct, gateway} {
+		serverReport.Endpoint.Nodes[id] = serverReport.Endpoint.Nodes[id].WithAdjacent(server)
+	}
+	clientReport := egressReport("client", now, [2]string{direct, server}, [2]string{natted, server})
+	for _, id := range []string{direct, natted} {
+		clientReport.Endpoint.Nodes[id] = clientReport.Endpoint.Nodes[id].WithAdjacent(server)
+	}
+
+	correlator := NewEgressCorrelator(NewCollector(time.Minute), time.Minute, 5*time.Second)
+	for _, rpt := range []report.Report{serverReport, clientReport} {
+		if err := correlator.Add(ctx, rpt, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	later := now.Add(30 * time.Second)
+	mtime.NowForce(later)
+	lateReport := egressReport("server", later, [2]string{server, direct}, [2]string{server, gateway}, [2]string{server, external})
+	for _, id := range []string{direct, gateway, external} {
+		lateReport.Endpoint.Nodes[id] = lateReport.Endpoint.Nodes[id].WithAdjacent(server)
+	}
+	if err := correlator.Add(ctx, lateReport, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	rpt, err := correlator.Report(ctx, later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := rpt.Endpoint.Nodes[gateway]
+	if copyOf, _ := node.Latest.Lookup(endpoint.CopyOf); copyOf != natted {
+		t.Errorf("expected the gateway to be attributed to the client behind it, got %v", node)
+	}
+	if hostNodeID, _ := node.Latest.Lookup(report.HostNodeID); hostNodeID != report.MakeHostNodeID("client") {
+		t.Errorf("expected the gateway to be rendered on the host of the client, got %v", node)
+	}
+	if _, ok := rpt.Endpoint.Nodes[external].Latest.Lookup(endpoint.CopyOf); ok {
+		t.Errorf("expected connections first seen apart not to be paired, got %v", rpt.Endpoint.Nodes[external])
+	}
+	if _, ok := rpt.Endpoint.Nodes[direct].Latest.Lookup(endpoint.CopyOf); ok {
+		t.Errorf("expected connections seen on both ends not to be paired, got %v", rpt.Endpoint.Nodes[direct])
+	}
+}
@@ -0,0 +1,135 @@
+package app
+
+import (
+	"context"
+	"io"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/weaveworks/common/mtime"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// Publisher is something reports can be published to, such as the
+// client of an upstream app.
+type Publisher interface {
+	Publish(r io.Reader, shortcut bool) error
+}
+
+// ForwarderConfig configures the forwarding of reports to an upstream
+// app.
+type ForwarderConfig struct {
+	// Interval between forwarded reports
+	Interval time.Duration
+	// MaxSamples is the number of most recent samples kept of each
+	// metric; 0 keeps them all.
+	MaxSamples int
+	// MaxBytesPerSecond caps the average bandwidth used, by delaying
+	// the next report after a large one; 0 means unlimited.
+	MaxBytesPerSecond int
+}
+
+// Forwarder periodically publishes the merged report of this app to an
+// upstream app, over the protocol used by probes. This allows
+// hierarchical deployments, where an app per cluster or per site
+// collects the reports of its local probes and forwards them to a
+// central app.
+type Forwarder struct {
+	reporter  Reporter
+	publisher Publisher
+	cfg       ForwarderConfig
+	quit      chan struct{}
+	done      chan struct{}
+}
+
+// NewForwarder makes a new Forwarder and starts forwarding the reports
+// of reporter to publisher.
+func NewForwarder(reporter Reporter, publisher Publisher, cfg ForwarderConfig) *Forwarder {
+	f := &Forwarder{
+		reporter:  reporter,
+		publisher: publisher,
+		cfg:       cfg,
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go f.loop()
+	return f
+}
+
+// Stop stops forwarding reports.
+func (f *Forwarder) Stop() {
+	close(f.quit)
+	<-f.done
+}
+
+func (f *Forwarder) loop() {
+	defer close(f.done)
+	timer := time.NewTimer(f.cfg.Interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			size, err := f.forward()
+			if err != nil {
+				log.Errorf("Error forwarding report: %v", err)
+			}
+			timer.Reset(f.delay(size))
+		case <-f.quit:
+			return
+		}
+	}
+}
+
+// delay is how long to wait before forwarding the next report, after
+// one of the given size.
+func (f *Forwarder) delay(size int) time.Duration {
+	delay := f.cfg.Interval
+	if f.cfg.MaxBytesPerSecond > 0 {
+		if d := time.Duration(size) * time.Second / time.Duration(f.cfg.MaxBytesPerSecond); d > delay {
+			delay = d
+		}
+	}
+	return delay
+}
+
+// forward publishes the current report, returning its encoded size.
+func (f *Forwarder) forward() (int, error) {
+	rpt, err := f.reporter.Report(context.Background(), mtime.Now())
+	if err != nil {
+		return 0, err
+	}
+	if f.cfg.MaxSamples > 0 {
+		rpt = Downsample(rpt, f.cfg.MaxSamples)
+	}
+	buf, err := rpt.WriteBinary()
+	if err != nil {
+		return 0, err
+	}
+	size := buf.Len()
+	return size, f.publisher.Publish(buf, false)
+}
+
+// Downsample returns a copy of the report keeping only the maxSamples
+// most recent samples of each metric.
+func Downsample(rpt report.Report, maxSamples int) report.Report {
+	rpt = rpt.Copy()
+	rpt.WalkTopologies(func(t *report.Topology) {
+		for id, n := range t.Nodes {
+			truncated := false
+			metrics := make(report.Metrics, len(n.Metrics))
+			for key, m := range n.Metrics {
+				if len(m.Samples) > maxSamples {
+					m.Samples = m.Samples[len(m.Samples)-maxSamples:]
+					truncated = true
+				}
+				metrics[key] = m
+			}
+			if truncated {
+				n.Metrics = metrics
+				t.Nodes[id] = n
+			}
+		}
+	})
+	return rpt
+}
@@ -0,0 +1,65 @@
+package app_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/report"
+)
+
+type chanPublisher chan io.Reader
+
+func (p chanPublisher) Publish(r io.Reader, _ bool) error {
+	select {
+	case p <- r:
+	default:
+	}
+	return nil
+}
+
+func TestDownsample(t *testing.T) {
+	now := time.Now()
+	samples := []report.Sample{}
+	for i := 0; i < 10; i++ {
+		samples = append(samples, report.Sample{Timestamp: now.Add(time.Duration(i) * time.Second), Value: float64(i)})
+	}
+	rpt := report.MakeReport()
+	rpt.Host.AddNode(report.MakeNode("host1").WithMetrics(report.Metrics{
+		"load1": report.MakeMetric(samples),
+	}))
+
+	downsampled := app.Downsample(rpt, 3)
+	metric := downsampled.Host.Nodes["host1"].Metrics["load1"]
+	if len(metric.Samples) != 3 || metric.Samples[2].Value != 9 {
+		t.Errorf("expected the 3 most recent samples, got %v", metric.Samples)
+	}
+	if len(rpt.Host.Nodes["host1"].Metrics["load1"].Samples) != 10 {
+		t.Error("original report modified")
+	}
+}
+
+func TestForwarder(t *testing.T) {
+	rpt := report.MakeReport()
+	rpt.Host.AddNode(report.MakeNode("host1"))
+	collector := app.NewCollector(time.Minute)
+	collector.Add(context.Background(), rpt, nil)
+
+	published := make(chanPublisher, 1)
+	forwarder := app.NewForwarder(collector, published, app.ForwarderConfig{
+		Interval: 10 * time.Millisecond,
+	})
+	defer forwarder.Stop()
+
+	select {
+	case r := <-published:
+		if buf, err := ioutil.ReadAll(r); err != nil || len(buf) == 0 {
+			t.Errorf("expected an encoded report, got %d bytes (%v)", len(buf), err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no report forwarded")
+	}
+}
@@ -0,0 +1,166 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// IngestionLimits bound the reports the app accepts, to protect it from
+// buggy plugins and hostile probes. Zero means no limit.
+type IngestionLimits struct {
+	// MaxReportBytes bounds the size of reports, decompressed if sent
+	// compressed, and so of the request bodies.
+	MaxReportBytes int64
+	MaxNodes       int // per topology
+	MaxMetadata    int // latest values and sets, each, per node
+	MaxAdjacency   int // per node
+	// Truncate reports exceeding the limits rather than rejecting them
+	Truncate bool
+	// RequireSignatures rejects the reports of probes without a key
+	// pair. The reports of probes with one are always checked.
+	RequireSignatures bool
+}
+
+var ingestionLimits struct {
+	sync.RWMutex
+	IngestionLimits
+}
+
+// EnableIngestionLimits has the report handler enforce the limits. They
+// can be changed at any time, e.g. on reloading the configuration.
+func EnableIngestionLimits(limits IngestionLimits) {
+	ingestionLimits.Lock()
+	defer ingestionLimits.Unlock()
+	ingestionLimits.IngestionLimits = limits
+}
+
+func currentIngestionLimits() IngestionLimits {
+	ingestionLimits.RLock()
+	defer ingestionLimits.RUnlock()
+	return ingestionLimits.IngestionLimits
+}
+
+var truncatedReports = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "scope",
+	Name:      "reports_truncated_total",
+	Help:      "Total count of reports truncated to the ingestion limits, by limit.",
+}, []string{"limit"})
+
+func init() {
+	prometheus.MustRegister(truncatedReports)
+}
+
+// apply checks the report against the limits, returning an error for
+// the first limit it exceeds or, if the limits truncate, truncating it
+// in place. It returns whether it truncated the report.
+func (l IngestionLimits) apply(rpt *report.Report) (bool, error) {
+	var (
+		truncated bool
+		err       error
+	)
+	rpt.WalkNamedTopologies(func(name string, t *report.Topology) {
+		if err != nil {
+			return
+		}
+		if l.MaxNodes > 0 && len(t.Nodes) > l.MaxNodes {
+			if !l.Truncate {
+				err = fmt.Errorf("topology %s has %d nodes, more than the limit of %d", name, len(t.Nodes), l.MaxNodes)
+				return
+			}
+			log.Warnf("Truncating topology %s from %d to %d nodes", name, len(t.Nodes), l.MaxNodes)
+			truncatedReports.WithLabelValues("nodes").Inc()
+			t.Nodes = truncateNodes(t.Nodes, l.MaxNodes)
+			truncated = true
+		}
+		for id, n := range t.Nodes {
+			var nodeTruncated bool
+			if nodeTruncated, err = l.applyNode(name, &n); err != nil {
+				return
+			}
+			if nodeTruncated {
+				t.Nodes[id] = n
+				truncated = true
+			}
+		}
+	})
+	return truncated, err
+}
+
+func (l IngestionLimits) applyNode(topology string, n *report.Node) (truncated bool, err error) {
+	exceeds := func(what string, size, limit int) (bool, error) {
+		if limit <= 0 || size <= limit {
+			return false, nil
+		}
+		if !l.Truncate {
+			return false, fmt.Errorf("node %s of topology %s has %d %s, more than the limit of %d", n.ID, topology, size, what, limit)
+		}
+		truncatedReports.WithLabelValues(what).Inc()
+		truncated = true
+		return true, nil
+	}
+	if truncate, err := exceeds("metadata", len(n.Latest), l.MaxMetadata); err != nil {
+		return false, err
+	} else if truncate {
+		n.Latest = n.Latest[:l.MaxMetadata]
+	}
+	if truncate, err := exceeds("sets", n.Sets.Size(), l.MaxMetadata); err != nil {
+		return false, err
+	} else if truncate {
+		for _, key := range n.Sets.Keys()[l.MaxMetadata:] {
+			n.Sets = n.Sets.Delete(key)
+		}
+	}
+	if truncate, err := exceeds("adjacency", len(n.Adjacency), l.MaxAdjacency); err != nil {
+		return false, err
+	} else if truncate {
+		n.Adjacency = n.Adjacency[:l.MaxAdjacency]
+	}
+	return truncated, nil
+}
+
+// truncateNodes keeps the first max nodes, by ID, so that truncating
+// the same nodes twice keeps the same ones.
+func truncateNodes(nodes report.Nodes, max int) report.Nodes {
+	ids := make([]string, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	result := make(report.Nodes, max)
+	for _, id := range ids[:max] {
+		result[id] = nodes[id]
+	}
+	return result
+}
+
+// errReportTooLarge is returned reading reports decompressing to more
+// than the max report bytes.
+var errReportTooLarge = errors.New("report is larger than the limit")
+
+// maxBytesReader reads up to n bytes, failing with errReportTooLarge on
+// reading more.
+type maxBytesReader struct {
+	r io.Reader
+	n int64
+}
+
+func (m *maxBytesReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > m.n+1 {
+		p = p[:m.n+1]
+	}
+	n, err := m.r.Read(p)
+	if int64(n) > m.n {
+		n, m.n = int(m.n), 0
+		return n, errReportTooLarge
+	}
+	m.n -= int64(n)
+	return n, err
+}
@@ -0,0 +1,55 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/report"
+)
+
+func limitedReport() report.Report {
+	now := time.Now()
+	rpt := report.MakeReport()
+	for _, id := range []string{"a", "b", "c"} {
+		rpt.Endpoint.AddNode(report.MakeNode(id).
+			WithLatest("k1", now, "v").
+			WithLatest("k2", now, "v").
+			WithLatest("k3", now, "v").
+			WithAdjacent("a").WithAdjacent("b").WithAdjacent("c"))
+	}
+	return rpt
+}
+
+func TestIngestionLimits(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		limits IngestionLimits
+		reject bool
+	}{
+		{"no limits", IngestionLimits{}, false},
+		{"within", IngestionLimits{MaxNodes: 3, MaxMetadata: 3, MaxAdjacency: 3}, false},
+		{"nodes", IngestionLimits{MaxNodes: 2}, true},
+		{"metadata", IngestionLimits{MaxMetadata: 2}, true},
+		{"adjacency", IngestionLimits{MaxAdjacency: 2}, true},
+	} {
+		rpt := limitedReport()
+		truncated, err := c.limits.apply(&rpt)
+		if reject := err != nil; reject != c.reject || truncated {
+			t.Errorf("%s: want rejected %v, have %v (truncated %v)", c.name, c.reject, err, truncated)
+		}
+	}
+
+	rpt := limitedReport()
+	truncated, err := IngestionLimits{MaxNodes: 2, MaxMetadata: 1, MaxAdjacency: 1, Truncate: true}.apply(&rpt)
+	if err != nil || !truncated {
+		t.Fatalf("want truncated, have %v, %v", truncated, err)
+	}
+	if len(rpt.Endpoint.Nodes) != 2 {
+		t.Errorf("want 2 nodes, have %v", rpt.Endpoint.Nodes)
+	}
+	for id, n := range rpt.Endpoint.Nodes {
+		if id == "c" || len(n.Latest) != 1 || len(n.Adjacency) != 1 {
+			t.Errorf("node %s not truncated: %v", id, n)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+)
+
+// Modes of the checks of the invariants of merged reports and rendered
+// nodes. They are off by default, but for builds with the debug tag.
+const (
+	InvariantsOff   = ""
+	InvariantsLog   = "log"
+	InvariantsPanic = "panic"
+)
+
+var invariantViolations = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "scope",
+	Name:      "invariant_violations_total",
+	Help:      "Total count of violations of the invariants of merged reports and rendered nodes, by check.",
+}, []string{"check"})
+
+func init() {
+	prometheus.MustRegister(invariantViolations)
+}
+
+// invariantViolated is called with the violations of the invariants of
+// merged reports, if they are checked.
+var invariantViolated func(string, error)
+
+// EnableInvariantChecks checks that the reports merged are valid, and the
+// invariants of the nodes rendered, logging violations loudly, or
+// panicking, so that bugs of the data model surface in staging rather
+// than in production. It must be called before InvariantReporter.
+func EnableInvariantChecks(mode string) error {
+	var violated func(string, error)
+	switch mode {
+	case InvariantsOff:
+	case InvariantsLog:
+		violated = func(check string, err error) {
+			invariantViolations.WithLabelValues(check).Inc()
+			log.Errorf("Invariant of %s violated: %v", check, err)
+		}
+	case InvariantsPanic:
+		violated = func(check string, err error) {
+			invariantViolations.WithLabelValues(check).Inc()
+			panic(fmt.Sprintf("invariant of %s violated: %v", check, err))
+		}
+	default:
+		return fmt.Errorf("invalid mode %q of invariant checks, must be %q or %q", mode, InvariantsLog, InvariantsPanic)
+	}
+	invariantViolated = violated
+	if violated == nil {
+		render.CheckInvariants(nil)
+	} else {
+		render.CheckInvariants(func(err error) { violated("render", err) })
+	}
+	return nil
+}
+
+// InvariantReporter wraps a reporter to check the reports it merges are
+// valid, if invariant checks are enabled.
+func InvariantReporter(r Reporter) Reporter {
+	if invariantViolated == nil {
+		return r
+	}
+	return invariantReporter{r}
+}
+
+type invariantReporter struct {
+	Reporter
+}
+
+func (r invariantReporter) Report(ctx context.Context, timestamp time.Time) (report.Report, error) {
+	rpt, err := r.Reporter.Report(ctx, timestamp)
+	if err == nil {
+		if err := rpt.Validate(); err != nil {
+			invariantViolated("merge", err)
+		}
+	}
+	return rpt, err
+}
@@ -0,0 +1,7 @@
+// +build debug
+
+package app
+
+// DefaultInvariantChecks is the mode of the invariant checks of builds
+// with the debug tag.
+const DefaultInvariantChecks = InvariantsPanic
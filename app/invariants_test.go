@@ -0,0 +1,35 @@
+package app_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestInvariantReporter(t *testing.T) {
+	if err := app.EnableInvariantChecks("loudly"); err == nil {
+		t.Error("expected an invalid mode")
+	}
+
+	rpt := report.MakeReport()
+	rpt.Host.AddNode(report.MakeNode("a;<host>").WithAdjacent("b;<host>"))
+	c := app.StaticCollector(rpt)
+	// Without checks, the invalid report is let through
+	if _, err := app.InvariantReporter(c).Report(context.Background(), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := app.EnableInvariantChecks(app.InvariantsPanic); err != nil {
+		t.Fatal(err)
+	}
+	defer app.EnableInvariantChecks(app.InvariantsOff)
+	defer func() {
+		if recover() == nil {
+			t.Error("expected the invalid report to panic")
+		}
+	}()
+	app.InvariantReporter(c).Report(context.Background(), time.Now())
+}
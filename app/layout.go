@@ -0,0 +1,76 @@
+package app
+
+import (
+	"hash/fnv"
+	"sort"
+	"sync"
+
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+)
+
+// layouts lays out the views clients ask for with layout=true. It is nil
+// (and layouts disabled) by default.
+var layouts *Layouts
+
+// EnableLayout turns on laying out views of up to maxNodes nodes in the
+// app, for clients too thin to lay out big views themselves.
+func EnableLayout(maxNodes int) {
+	layouts = &Layouts{maxNodes: maxNodes, cache: map[string]cachedLayout{}}
+}
+
+// Layouts lays out views, keeping the last layout of each topology for as
+// long as its nodes and edges stay the same, as laying out tens of
+// thousands of nodes takes a while.
+type Layouts struct {
+	maxNodes int
+
+	mtx   sync.Mutex
+	cache map[string]cachedLayout // by topology
+}
+
+type cachedLayout struct {
+	fingerprint uint64
+	positions   map[string]detailed.Position
+}
+
+// Layout returns the layout of the nodes of the topology, or nil if they
+// are too many to lay out.
+func (l *Layouts) Layout(topologyID string, ns report.Nodes) map[string]detailed.Position {
+	if l.maxNodes > 0 && len(ns) > l.maxNodes {
+		return nil
+	}
+	fingerprint := layoutFingerprint(ns)
+	l.mtx.Lock()
+	cached, ok := l.cache[topologyID]
+	l.mtx.Unlock()
+	if ok && cached.fingerprint == fingerprint {
+		return cached.positions
+	}
+
+	positions := detailed.Layout(ns)
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.cache[topologyID] = cachedLayout{fingerprint: fingerprint, positions: positions}
+	return positions
+}
+
+// layoutFingerprint hashes the nodes and edges of a view, which are all
+// its layout depends on.
+func layoutFingerprint(ns report.Nodes) uint64 {
+	ids := make([]string, 0, len(ns))
+	for id := range ns {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	h := fnv.New64a()
+	for _, id := range ids {
+		h.Write([]byte(id))
+		h.Write([]byte{0})
+		for _, dst := range ns[id].Adjacency {
+			h.Write([]byte(dst))
+			h.Write([]byte{1})
+		}
+	}
+	return h.Sum64()
+}
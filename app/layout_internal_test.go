@@ -0,0 +1,35 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/report"
+)
+
+func TestLayouts(t *testing.T) {
+	l := &Layouts{maxNodes: 3, cache: map[string]cachedLayout{}}
+	ns := report.Nodes{
+		"a": report.MakeNode("a").WithAdjacent("b"),
+		"b": report.MakeNode("b"),
+	}
+	first := l.Layout("hosts", ns)
+	if len(first) != 2 {
+		t.Fatalf("expected a position per node, got %v", first)
+	}
+	if cached, ok := l.cache["hosts"]; !ok || cached.fingerprint != layoutFingerprint(ns) {
+		t.Errorf("expected the layout to be cached, got %v", l.cache)
+	}
+
+	// Layouts change with the edges
+	ns["b"] = report.MakeNode("b").WithAdjacent("a")
+	fingerprint := l.cache["hosts"].fingerprint
+	l.Layout("hosts", ns)
+	if l.cache["hosts"].fingerprint == fingerprint {
+		t.Error("expected the layout to be laid out again when the edges change")
+	}
+
+	ns["c"], ns["d"] = report.MakeNode("c"), report.MakeNode("d")
+	if positions := l.Layout("hosts", ns); positions != nil {
+		t.Errorf("expected no layout of views over the limit, got %v", positions)
+	}
+}
@@ -0,0 +1,303 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+	"github.com/weaveworks/common/mtime"
+
+	"github.com/weaveworks/scope/app/auth"
+	"github.com/weaveworks/scope/render/detailed"
+)
+
+const (
+	maxMaintenanceWindowsPerOrg    = 1000
+	maxMaintenanceWindowBodyLength = 16 * 1024
+	// Windows ended for this long are forgotten
+	maintenanceWindowExpiry = 7 * 24 * time.Hour
+)
+
+// maintenance suppresses the anomalies and changes of the nodes under
+// maintenance. It is nil (and suppression disabled) by default.
+var (
+	maintenance      *MaintenanceWindows
+	maintenanceOrgID func(context.Context) (string, error)
+)
+
+// EnableMaintenanceWindows turns on suppressing the anomalies and changes
+// of the nodes in the maintenance windows of the org of requests, as told
+// by orgID.
+func EnableMaintenanceWindows(m *MaintenanceWindows, orgID func(context.Context) (string, error)) {
+	maintenance, maintenanceOrgID = m, orgID
+}
+
+// MaintenanceWindow is a planned period of changes to the nodes of a
+// topology matching Selector, a shell pattern matched against node
+// labels and IDs as in policies. During the window, the anomalies of the
+// edges of those nodes and their changes aren't reported, so that
+// planned deploys page nobody.
+type MaintenanceWindow struct {
+	ID       string    `json:"id"`
+	Topology string    `json:"topology"`
+	Selector string    `json:"selector"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Reason   string    `json:"reason,omitempty"`
+	Author   string    `json:"author"`
+}
+
+func (m MaintenanceWindow) validate() error {
+	if _, err := path.Match(m.Selector, ""); err != nil || m.Selector == "" {
+		return fmt.Errorf("invalid selector %q", m.Selector)
+	}
+	if !m.Start.Before(m.End) {
+		return fmt.Errorf("maintenance windows must end after they start")
+	}
+	if _, ok := topologyRegistry.get(m.Topology); !ok {
+		return fmt.Errorf("unknown topology %q", m.Topology)
+	}
+	return nil
+}
+
+// overlaps tells whether the window overlaps the period from..to.
+func (m MaintenanceWindow) overlaps(from, to time.Time) bool {
+	return !m.End.Before(from) && !m.Start.After(to)
+}
+
+type maintenanceWindowsByStart []MaintenanceWindow
+
+func (m maintenanceWindowsByStart) Len() int      { return len(m) }
+func (m maintenanceWindowsByStart) Swap(i, j int) { m[i], m[j] = m[j], m[i] }
+func (m maintenanceWindowsByStart) Less(i, j int) bool {
+	if !m[i].Start.Equal(m[j].Start) {
+		return m[i].Start.Before(m[j].Start)
+	}
+	return m[i].ID < m[j].ID
+}
+
+// MaintenanceWindows stores the maintenance windows of every org,
+// persisting them to a file if given one.
+type MaintenanceWindows struct {
+	store *orgStore
+}
+
+// NewMaintenanceWindows makes a new MaintenanceWindows, loading any
+// windows previously saved at path. With no path, windows are kept in
+// memory only.
+func NewMaintenanceWindows(path string) (*MaintenanceWindows, error) {
+	store, err := newOrgStore(path, maxMaintenanceWindowsPerOrg, errMaintenanceWindowNotFound, errTooManyMaintenanceWindows, func(buf []byte) (interface{}, error) {
+		var window MaintenanceWindow
+		err := json.Unmarshal(buf, &window)
+		return window, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &MaintenanceWindows{store: store}, nil
+}
+
+// List returns the windows of an org, by start.
+func (m *MaintenanceWindows) List(org string) []MaintenanceWindow {
+	result := []MaintenanceWindow{}
+	m.store.each(org, func(record interface{}) {
+		result = append(result, record.(MaintenanceWindow))
+	})
+	sort.Sort(maintenanceWindowsByStart(result))
+	return result
+}
+
+// Add saves a new window of the user, forgetting the windows ended long
+// ago. Windows are validated when decoded.
+func (m *MaintenanceWindows) Add(org string, window MaintenanceWindow, user auth.Identity) (MaintenanceWindow, error) {
+	now := mtime.Now()
+	record, err := m.store.add(org, func(record interface{}) bool {
+		return now.Sub(record.(MaintenanceWindow).End) > maintenanceWindowExpiry
+	}, func(id string) interface{} {
+		window.ID, window.Author = id, user.Subject
+		return window
+	})
+	if err != nil {
+		return MaintenanceWindow{}, err
+	}
+	return record.(MaintenanceWindow), nil
+}
+
+// Errors of the changes to maintenance windows
+var (
+	errMaintenanceWindowNotFound = fmt.Errorf("no such maintenance window")
+	errTooManyMaintenanceWindows = fmt.Errorf("at most %d maintenance windows can be saved", maxMaintenanceWindowsPerOrg)
+)
+
+// Delete deletes a window, ending the maintenance early; anybody may.
+func (m *MaintenanceWindows) Delete(org, id string) error {
+	return m.store.delete(org, id, nil)
+}
+
+// Matcher returns whether nodes of a topology are under maintenance at
+// some point from..to, or nil if none of the windows of the org apply.
+func (m *MaintenanceWindows) Matcher(org, topologyID string, from, to time.Time) func(detailed.BasicNodeSummary) bool {
+	var selectors []string
+	m.store.each(org, func(record interface{}) {
+		if w := record.(MaintenanceWindow); w.Topology == topologyID && w.overlaps(from, to) {
+			selectors = append(selectors, w.Selector)
+		}
+	})
+	if len(selectors) == 0 {
+		return nil
+	}
+	return func(n detailed.BasicNodeSummary) bool {
+		for _, selector := range selectors {
+			if matchesNode(selector, n) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// maintenanceMatcher returns the matcher of the nodes of the org of a
+// request under maintenance, if any.
+func maintenanceMatcher(ctx context.Context, topologyID string, from, to time.Time) func(detailed.BasicNodeSummary) bool {
+	if maintenance == nil {
+		return nil
+	}
+	org, err := maintenanceOrgID(ctx)
+	if err != nil {
+		return nil
+	}
+	return maintenance.Matcher(org, topologyID, from, to)
+}
+
+// suppressAnomalies drops the anomalies of the edges of nodes under
+// maintenance.
+func suppressAnomalies(anomalies []EdgeAnomaly, nodes detailed.NodeSummaries, underMaintenance func(detailed.BasicNodeSummary) bool) []EdgeAnomaly {
+	var result []EdgeAnomaly
+	for _, a := range anomalies {
+		if !underMaintenance(nodeOrID(nodes, a.Source)) && !underMaintenance(nodeOrID(nodes, a.Target)) {
+			result = append(result, a)
+		}
+	}
+	return result
+}
+
+// suppressChanges drops the changes to nodes under maintenance, returning
+// how many were dropped.
+func suppressChanges(changes detailed.Changes, before, after detailed.NodeSummaries, underMaintenance func(detailed.BasicNodeSummary) bool) (detailed.Changes, int) {
+	var (
+		suppressed = 0
+		result     = detailed.Changes{
+			Added:        []detailed.BasicNodeSummary{},
+			Removed:      []detailed.BasicNodeSummary{},
+			AddedEdges:   []detailed.Edge{},
+			RemovedEdges: []detailed.Edge{},
+			MetricShifts: []detailed.MetricShift{},
+		}
+		node = func(id string) detailed.BasicNodeSummary {
+			if n, ok := after[id]; ok {
+				return n.BasicNodeSummary
+			}
+			return nodeOrID(before, id)
+		}
+		filterNodes = func(nodes []detailed.BasicNodeSummary) []detailed.BasicNodeSummary {
+			kept := []detailed.BasicNodeSummary{}
+			for _, n := range nodes {
+				if underMaintenance(n) {
+					suppressed++
+				} else {
+					kept = append(kept, n)
+				}
+			}
+			return kept
+		}
+		filterEdges = func(edges []detailed.Edge) []detailed.Edge {
+			kept := []detailed.Edge{}
+			for _, e := range edges {
+				if underMaintenance(node(e.Source)) || underMaintenance(node(e.Target)) {
+					suppressed++
+				} else {
+					kept = append(kept, e)
+				}
+			}
+			return kept
+		}
+	)
+	result.Added = filterNodes(changes.Added)
+	result.Removed = filterNodes(changes.Removed)
+	result.AddedEdges = filterEdges(changes.AddedEdges)
+	result.RemovedEdges = filterEdges(changes.RemovedEdges)
+	for _, shift := range changes.MetricShifts {
+		if underMaintenance(node(shift.NodeID)) {
+			suppressed++
+		} else {
+			result.MetricShifts = append(result.MetricShifts, shift)
+		}
+	}
+	for _, restart := range changes.Restarted {
+		if underMaintenance(node(restart.To)) {
+			suppressed++
+		} else {
+			result.Restarted = append(result.Restarted, restart)
+		}
+	}
+	return result, suppressed
+}
+
+// nodeOrID returns the summary of a node, or one with only its ID for
+// nodes gone.
+func nodeOrID(nodes detailed.NodeSummaries, id string) detailed.BasicNodeSummary {
+	if n, ok := nodes[id]; ok {
+		return n.BasicNodeSummary
+	}
+	return detailed.BasicNodeSummary{ID: id}
+}
+
+// RegisterMaintenanceRoutes registers the routes to list, add and delete
+// maintenance windows. Windows are kept apart by the org the request
+// comes from, as told by orgID.
+func RegisterMaintenanceRoutes(router *mux.Router, m *MaintenanceWindows, orgID func(context.Context) (string, error)) {
+	router.Methods("GET").Path("/api/maintenance-windows").
+		HandlerFunc(requestContextDecorator(handleOrgRequest(orgID, func(w http.ResponseWriter, r *http.Request, org string, user auth.Identity) {
+			respondWith(w, http.StatusOK, m.List(org))
+		})))
+	router.Methods("POST").Path("/api/maintenance-windows").
+		HandlerFunc(requestContextDecorator(handleOrgRequest(orgID, func(w http.ResponseWriter, r *http.Request, org string, user auth.Identity) {
+			var window MaintenanceWindow
+			if err := codec.NewDecoder(io.LimitReader(r.Body, maxMaintenanceWindowBodyLength), &codec.JsonHandle{}).Decode(&window); err != nil {
+				respondWith(w, http.StatusBadRequest, err)
+				return
+			}
+			if err := window.validate(); err != nil {
+				respondWith(w, http.StatusBadRequest, err)
+				return
+			}
+			window, err := m.Add(org, window, user)
+			if err == errTooManyMaintenanceWindows {
+				respondWith(w, http.StatusBadRequest, err)
+				return
+			} else if err != nil {
+				respondWith(w, http.StatusInternalServerError, err)
+				return
+			}
+			respondWith(w, http.StatusCreated, window)
+		})))
+	router.Methods("DELETE").Path("/api/maintenance-windows/{id}").
+		HandlerFunc(requestContextDecorator(handleOrgRequest(orgID, func(w http.ResponseWriter, r *http.Request, org string, user auth.Identity) {
+			err := m.Delete(org, mux.Vars(r)["id"])
+			if err == errMaintenanceWindowNotFound {
+				respondWith(w, http.StatusNotFound, err)
+				return
+			} else if err != nil {
+				respondWith(w, http.StatusInternalServerError, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})))
+}
@@ -0,0 +1,150 @@
+package app_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/app/auth"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+// deployCollector has the fixture report appear at deployed.
+type deployCollector struct {
+	app.StaticCollector
+	deployed time.Time
+}
+
+func (c deployCollector) Report(_ context.Context, timestamp time.Time) (report.Report, error) {
+	if timestamp.Before(c.deployed) {
+		return report.MakeReport(), nil
+	}
+	return report.Report(c.StaticCollector), nil
+}
+
+func TestMaintenanceWindows(t *testing.T) {
+	windows, err := app.NewMaintenanceWindows("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	orgID := func(context.Context) (string, error) { return "org1", nil }
+	app.EnableMaintenanceWindows(windows, orgID)
+	defer app.EnableMaintenanceWindows(nil, nil)
+
+	tokens, err := auth.ParseTokens(strings.NewReader("reader-token read-only\noperator-token controls operator\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deployed := time.Date(2017, 1, 1, 12, 0, 0, 0, time.UTC)
+	router := mux.NewRouter().SkipClean(true)
+	app.RegisterTopologyRoutes(router, deployCollector{app.StaticCollector(fixture.Report), deployed}, map[string]bool{})
+	app.RegisterMaintenanceRoutes(router, windows, orgID)
+	ts := httptest.NewServer(auth.NewAuthenticator(auth.Config{Tokens: tokens}).Wrap(router))
+	defer ts.Close()
+
+	changes := func(query string) app.APITopologyChanges {
+		code, body := viewRequest(t, ts, "reader-token", "GET", "/api/topology/processes/changes?from=2017-01-01T11:00:00Z&to=2017-01-01T13:00:00Z"+query, nil)
+		if code != http.StatusOK {
+			t.Fatalf("expected the changes, got %d %s", code, body)
+		}
+		var c app.APITopologyChanges
+		if err := codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&c); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	all := changes("")
+	if len(all.Added) == 0 || all.Suppressed != 0 {
+		t.Fatalf("expected processes to be added, got %+v", all)
+	}
+
+	window := app.MaintenanceWindow{
+		Topology: "processes",
+		Selector: "apache",
+		Start:    deployed.Add(-time.Minute),
+		End:      deployed.Add(time.Minute),
+		Reason:   "apache upgrade",
+	}
+	if code, _ := viewRequest(t, ts, "reader-token", "POST", "/api/maintenance-windows", window); code != http.StatusForbidden {
+		t.Errorf("expected read-only users not to add windows, got %d", code)
+	}
+	for _, invalid := range []app.MaintenanceWindow{
+		{Topology: "processes", Selector: "[", Start: window.Start, End: window.End},
+		{Topology: "processes", Selector: "apache", Start: window.End, End: window.Start},
+	} {
+		if code, _ := viewRequest(t, ts, "operator-token", "POST", "/api/maintenance-windows", invalid); code != http.StatusBadRequest {
+			t.Errorf("expected %+v to be rejected, got %d", invalid, code)
+		}
+	}
+	code, body := viewRequest(t, ts, "operator-token", "POST", "/api/maintenance-windows", window)
+	if code != http.StatusCreated {
+		t.Fatalf("expected the window to be added, got %d %s", code, body)
+	}
+	if err := json.Unmarshal(body, &window); err != nil || window.Author != "operator" {
+		t.Errorf("unexpected window %+v", window)
+	}
+
+	// The changes to apache are suppressed during the deploy
+	count := func(c app.APITopologyChanges) int {
+		return len(c.Added) + len(c.Removed) + len(c.AddedEdges) + len(c.RemovedEdges) + len(c.MetricShifts)
+	}
+	suppressed := changes("")
+	if suppressed.Suppressed == 0 || count(suppressed)+suppressed.Suppressed != count(all) {
+		t.Errorf("expected the changes to apache to be suppressed, got %+v", suppressed)
+	}
+	for _, n := range suppressed.Added {
+		if n.Label == "apache" {
+			t.Errorf("expected apache to be suppressed, got %+v", n)
+		}
+	}
+	if included := changes("&maintenance=include"); included.Suppressed != 0 || len(included.Added) != len(all.Added) {
+		t.Errorf("expected nothing to be suppressed when asked, got %+v", included)
+	}
+	compare := func(query string) app.APITopologyComparison {
+		code, body := viewRequest(t, ts, "reader-token", "GET", "/api/topology/processes/compare?from=2017-01-01T11:00:00Z&to=2017-01-01T13:00:00Z"+query, nil)
+		if code != http.StatusOK {
+			t.Fatalf("expected the comparison, got %d %s", code, body)
+		}
+		var c app.APITopologyComparison
+		if err := codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&c); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	if comparison := compare(""); comparison.Suppressed != suppressed.Suppressed {
+		t.Errorf("expected %d changes to be suppressed, got %d", suppressed.Suppressed, comparison.Suppressed)
+	} else {
+		for _, n := range comparison.Nodes {
+			if n.Label == "apache" && n.Status != detailed.Unchanged {
+				t.Errorf("expected apache to be unchanged, got %+v", n)
+			}
+		}
+	}
+	included := compare("&maintenance=include")
+	if included.Suppressed != 0 {
+		t.Errorf("expected nothing to be suppressed when asked, got %d", included.Suppressed)
+	}
+	for _, n := range included.Nodes {
+		if n.Label == "apache" && n.Status != detailed.Added {
+			t.Errorf("expected apache to be added when asked, got %+v", n)
+		}
+	}
+
+	// Ending the window early brings the changes back
+	if code, _ := viewRequest(t, ts, "operator-token", "DELETE", "/api/maintenance-windows/"+window.ID, nil); code != http.StatusNoContent {
+		t.Errorf("expected the window to be deleted, got %d", code)
+	}
+	if after := changes(""); after.Suppressed != 0 {
+		t.Errorf("expected nothing to be suppressed, got %+v", after)
+	}
+}
@@ -0,0 +1,172 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// Reasons the value of a key of a node won the merge of the reports.
+const (
+	OnlyReportReason = "only report"
+	NewestReason     = "newest"
+	AgreedReason     = "agreed"
+	TieReason        = "tie"
+)
+
+// MergeAuditor is a collector which can tell how it merged the metadata
+// of a node, for debugging probes disagreeing about it.
+type MergeAuditor interface {
+	AuditMerge(ctx context.Context, timestamp time.Time, topology, id string) (MergeAudit, bool, error)
+}
+
+// MergeAudit is how the metadata of a node was merged, from the reports
+// having the node.
+type MergeAudit struct {
+	Topology string        `json:"topology"`
+	ID       string        `json:"id"`
+	Sources  []MergeSource `json:"sources"`
+	Latest   []LatestAudit `json:"latest"`
+}
+
+// MergeSource is a report merged, with the host of the probe which
+// published it, if any.
+type MergeSource struct {
+	Report    string    `json:"report"`
+	Observer  string    `json:"observer,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// LatestAudit is which source, by index, won a key of the metadata of a
+// node, and why: being the only report of the key, having the newest
+// value, agreeing with the others as new, or tying with others which
+// disagree. The candidates are those of every source, if more than one.
+type LatestAudit struct {
+	Key        string            `json:"key"`
+	Value      string            `json:"value"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Winner     int               `json:"winner"`
+	Reason     string            `json:"reason"`
+	Candidates []LatestCandidate `json:"candidates,omitempty"`
+}
+
+// LatestCandidate is the value of a key of a source.
+type LatestCandidate struct {
+	Source    int       `json:"source"`
+	Value     string    `json:"value"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// EnableMergeAudit stops the collector quantising the reports it holds,
+// so that the reports of each probe are kept apart to audit their merge.
+// Merging them is then slower.
+func (c *collector) EnableMergeAudit() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.audit = true
+	c.cached = nil
+}
+
+// AuditMerge tells how the metadata of a node was merged in the report
+// of the timestamp. It implements MergeAuditor.
+func (c *collector) AuditMerge(_ context.Context, timestamp time.Time, topology, id string) (MergeAudit, bool, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if !c.audit {
+		return MergeAudit{}, false, fmt.Errorf("merge audits are disabled")
+	}
+	if _, ok := report.MakeReport().Topology(topology); !ok {
+		return MergeAudit{}, false, fmt.Errorf("unknown topology %q", topology)
+	}
+
+	c.clean()
+	for i := range c.reports {
+		c.reports[i] = c.reports[i].Upgrade()
+	}
+	reports := c.expire(timestamp)
+	audit, ok := auditMerge(reports, c.timestamps, c.merger.Merge(reports), topology, id)
+	return audit, ok, nil
+}
+
+// auditMerge audits the merge of the reports, received at the timestamps,
+// of a node, into the merged report.
+func auditMerge(reports []report.Report, timestamps []time.Time, mergedReport report.Report, topology, id string) (MergeAudit, bool) {
+	var (
+		audit      = MergeAudit{Topology: topology, ID: id}
+		candidates = map[string][]LatestCandidate{}
+	)
+	for i, rpt := range reports {
+		t, _ := rpt.Topology(topology)
+		n, ok := t.Nodes[id]
+		if !ok {
+			continue
+		}
+		source := len(audit.Sources)
+		audit.Sources = append(audit.Sources, MergeSource{Report: rpt.ID, Observer: observer(rpt), Timestamp: timestamps[i]})
+		n.Latest.ForEach(func(key string, timestamp time.Time, value string) {
+			candidates[key] = append(candidates[key], LatestCandidate{Source: source, Value: value, Timestamp: timestamp})
+		})
+	}
+	t, _ := mergedReport.Topology(topology)
+	merged, ok := t.Nodes[id]
+	if !ok || len(audit.Sources) == 0 {
+		return MergeAudit{}, false
+	}
+
+	audit.Latest = make([]LatestAudit, 0, len(candidates))
+	merged.Latest.ForEach(func(key string, timestamp time.Time, value string) {
+		la := LatestAudit{Key: key, Value: value, Timestamp: timestamp, Winner: -1, Reason: OnlyReportReason}
+		cs := candidates[key]
+		newest, agreeing := 0, 0
+		for _, c := range cs {
+			if !c.Timestamp.Equal(timestamp) {
+				continue
+			}
+			newest++
+			if c.Value == value {
+				agreeing++
+				if la.Winner < 0 {
+					la.Winner = c.Source
+				}
+			}
+		}
+		switch {
+		case len(cs) < 2:
+		case newest == 1:
+			la.Reason = NewestReason
+		case agreeing == newest:
+			la.Reason = AgreedReason
+		default:
+			la.Reason = TieReason
+		}
+		if len(cs) > 1 {
+			la.Candidates = cs
+		}
+		audit.Latest = append(audit.Latest, la)
+	})
+	sort.Slice(audit.Latest, func(i, j int) bool { return audit.Latest[i].Key < audit.Latest[j].Key })
+	return audit, true
+}
+
+// RegisterMergeAuditRoute registers the route of the audits of the merges
+// of the metadata of nodes of the report topology and ID of the query.
+func RegisterMergeAuditRoute(router *mux.Router, a MergeAuditor) {
+	router.Methods("GET").Path("/api/admin/merge-audit").HandlerFunc(requestContextDecorator(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		audit, ok, err := a.AuditMerge(ctx, deserializeTimestamp(r.FormValue("timestamp")), r.FormValue("topology"), r.FormValue("id"))
+		if err != nil {
+			respondWith(w, http.StatusBadRequest, err)
+			return
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		respondWith(w, http.StatusOK, audit)
+	}))
+}
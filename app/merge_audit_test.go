@@ -0,0 +1,118 @@
+package app_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+	"github.com/weaveworks/common/mtime"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestMergeAudit(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	c := app.NewCollector(15 * time.Second)
+	if _, _, err := c.(app.MergeAuditor).AuditMerge(ctx, now, report.Container, "c1"); err == nil {
+		t.Error("expected merge audits to be disabled")
+	}
+	c.(interface{ EnableMergeAudit() }).EnableMergeAudit()
+
+	t1, t2 := now.Add(-2*time.Second), now.Add(-time.Second)
+	r1 := report.MakeReport()
+	r1.ID = "r1"
+	r1.Host.AddNode(report.MakeNode("a;<host>"))
+	r1.Container.AddNode(report.MakeNode("c1").
+		WithLatest("name", t1, "old").
+		WithLatest("image", t2, "nginx").
+		WithLatest("state", t2, "running").
+		WithLatest("only", t1, "a"))
+	r2 := report.MakeReport()
+	r2.ID = "r2"
+	r2.Host.AddNode(report.MakeNode("b;<host>"))
+	r2.Container.AddNode(report.MakeNode("c1").
+		WithLatest("name", t2, "new").
+		WithLatest("image", t2, "nginx").
+		WithLatest("state", t2, "stopped"))
+	// Within the quantisation interval, so merged unless audited
+	mtime.NowForce(now.Add(-2 * time.Second))
+	c.Add(ctx, r1, nil)
+	mtime.NowForce(now.Add(-time.Second))
+	c.Add(ctx, r2, nil)
+	mtime.NowForce(now)
+	if _, err := c.Report(ctx, now); err != nil {
+		t.Fatal(err)
+	}
+
+	router := mux.NewRouter()
+	app.RegisterMergeAuditRoute(router, c.(app.MergeAuditor))
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	get := func(topology, id string) (int, app.MergeAudit) {
+		resp, err := http.Get(ts.URL + "/api/admin/merge-audit?" + url.Values{"topology": {topology}, "id": {id}}.Encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		var audit app.MergeAudit
+		if resp.StatusCode == http.StatusOK {
+			if err := codec.NewDecoder(resp.Body, &codec.JsonHandle{}).Decode(&audit); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp.StatusCode, audit
+	}
+
+	if code, _ := get(report.Container, "nope"); code != http.StatusNotFound {
+		t.Errorf("want 404, have %d", code)
+	}
+	if code, _ := get("nope", "c1"); code != http.StatusBadRequest {
+		t.Errorf("want 400, have %d", code)
+	}
+
+	code, audit := get(report.Container, "c1")
+	if code != http.StatusOK {
+		t.Fatalf("want 200, have %d", code)
+	}
+	if len(audit.Sources) != 2 || audit.Sources[0].Report != "r1" || audit.Sources[0].Observer != "a;<host>" || audit.Sources[1].Observer != "b;<host>" {
+		t.Fatalf("expected both reports, have %v", audit.Sources)
+	}
+	want := map[string]struct {
+		value, reason string
+		winner        int
+	}{
+		"image": {"nginx", app.AgreedReason, 0},
+		"name":  {"new", app.NewestReason, 1},
+		"only":  {"a", app.OnlyReportReason, 0},
+	}
+	for _, la := range audit.Latest {
+		if la.Key == "state" {
+			if la.Reason != app.TieReason || len(la.Candidates) != 2 || la.Candidates[la.Winner].Value != la.Value {
+				t.Errorf("expected a tie, have %+v", la)
+			}
+			continue
+		}
+		w, ok := want[la.Key]
+		if !ok {
+			t.Errorf("unexpected key %s", la.Key)
+			continue
+		}
+		if la.Value != w.value || la.Reason != w.reason || la.Winner != w.winner {
+			t.Errorf("%s: want %+v, have %+v", la.Key, w, la)
+		}
+	}
+	if len(audit.Latest) != 4 {
+		t.Errorf("expected every key, have %v", audit.Latest)
+	}
+}
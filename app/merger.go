@@ -2,6 +2,8 @@ package app
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 
 	"github.com/spaolacci/murmur3"
 
@@ -30,3 +32,142 @@ func (fastMerger) Merge(reports []report.Report) report.Report {
 	rpt.ID = fmt.Sprintf("%x", id.Sum64())
 	return rpt
 }
+
+type joiningMerger struct{}
+
+// NewJoiningMerger makes a Merger which, as well as merging reports,
+// joins the connections observed by the probes of both of their ends.
+// The counters of endpoints observed by several probes, e.g. of the bytes
+// they sent, are the highest any of them counted, rather than their sum,
+// and connections each end saw the other way around are kept in the
+// direction of the endpoint which initiated them, if known, or of the
+// client by its ephemeral port. The names of the endpoints are the union
+// of those seen by any probe, as for any merge.
+func NewJoiningMerger() Merger {
+	return joiningMerger{}
+}
+
+func (joiningMerger) Merge(reports []report.Report) report.Report {
+	var (
+		groups = map[string][]report.Report{}
+		keys   = []string{}
+	)
+	for _, r := range reports {
+		key := observer(r)
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], r)
+	}
+	if len(groups) < 2 {
+		return fastMerger{}.Merge(reports)
+	}
+	sort.Strings(keys)
+
+	var (
+		counters = map[string][]report.Counters{} // of the endpoints, by observer
+		edges    = map[string]map[string]int{}    // observers of the edges of the endpoints
+		merged   = make([]report.Report, 0, len(groups))
+	)
+	for i, key := range keys {
+		rpt := fastMerger{}.Merge(groups[key])
+		for id, n := range rpt.Endpoint.Nodes {
+			if n.Counters.Size() > 0 {
+				counters[id] = append(counters[id], n.Counters)
+			}
+			for _, dstID := range n.Adjacency {
+				if edges[id] == nil {
+					edges[id] = map[string]int{}
+				}
+				edges[id][dstID] = i
+			}
+		}
+		merged = append(merged, rpt)
+	}
+	rpt := fastMerger{}.Merge(merged)
+	joinCounters(rpt.Endpoint, counters)
+	joinEdges(rpt.Endpoint, edges)
+
+	id := murmur3.New64()
+	for _, r := range reports {
+		id.Write([]byte(r.ID))
+	}
+	rpt.ID = fmt.Sprintf("%x", id.Sum64())
+	return rpt
+}
+
+// observer is the host of the probe of a report.
+func observer(rpt report.Report) string {
+	key := ""
+	for id := range rpt.Host.Nodes {
+		if key == "" || id < key {
+			key = id
+		}
+	}
+	return key
+}
+
+// joinCounters sets the counters of the endpoints observed by several
+// probes to the highest of each.
+func joinCounters(endpoints report.Topology, counters map[string][]report.Counters) {
+	for id, observed := range counters {
+		if len(observed) < 2 {
+			continue
+		}
+		highest := map[string]int{}
+		for _, c := range observed {
+			c.ForEach(func(key string, value int) {
+				if existing, ok := highest[key]; !ok || value > existing {
+					highest[key] = value
+				}
+			})
+		}
+		joined := report.MakeCounters()
+		for key, value := range highest {
+			joined = joined.Add(key, value)
+		}
+		n := endpoints.Nodes[id]
+		n.Counters = joined
+		endpoints.Nodes[id] = n
+	}
+}
+
+// joinEdges drops one direction of the connections which probes of
+// different ends saw the other way around.
+func joinEdges(endpoints report.Topology, edges map[string]map[string]int) {
+	for srcID, dsts := range edges {
+		for dstID, observer := range dsts {
+			reverseObserver, ok := edges[dstID][srcID]
+			if !ok || reverseObserver == observer || !initiates(endpoints.Nodes[dstID], endpoints.Nodes[srcID]) {
+				continue
+			}
+			src := endpoints.Nodes[srcID]
+			adjacency := report.MakeIDList()
+			for _, id := range src.Adjacency {
+				if id != dstID {
+					adjacency = adjacency.Add(id)
+				}
+			}
+			src.Adjacency = adjacency
+			endpoints.Nodes[srcID] = src
+		}
+	}
+}
+
+// initiates is whether the connection between the endpoints is more
+// likely initiated by a than b.
+func initiates(a, b report.Node) bool {
+	aInitiator, _ := a.Latest.Lookup(report.Initiator)
+	bInitiator, _ := b.Latest.Lookup(report.Initiator)
+	if aInitiator != bInitiator {
+		return aInitiator == "true" || bInitiator == "false"
+	}
+	_, _, aPort, _ := report.ParseEndpointNodeID(a.ID)
+	_, _, bPort, _ := report.ParseEndpointNodeID(b.ID)
+	aNumber, _ := strconv.Atoi(aPort)
+	bNumber, _ := strconv.Atoi(bPort)
+	if aNumber != bNumber {
+		return aNumber > bNumber
+	}
+	return a.ID > b.ID
+}
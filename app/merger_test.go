@@ -27,7 +27,7 @@ func TestMerger(t *testing.T) {
 	want.Endpoint.AddNode(report.MakeNode("bar"))
 	want.Endpoint.AddNode(report.MakeNode("baz"))
 
-	for _, merger := range []app.Merger{app.NewFastMerger()} {
+	for _, merger := range []app.Merger{app.NewFastMerger(), app.NewJoiningMerger()} {
 		// Test the empty list case
 		if have := merger.Merge([]report.Report{}); !reflect.DeepEqual(have, report.MakeReport()) {
 			t.Errorf("Bad merge: %s", test.Diff(have, want))
@@ -44,6 +44,45 @@ func TestMerger(t *testing.T) {
 	}
 }
 
+func TestJoiningMerger(t *testing.T) {
+	var (
+		client  = report.MakeEndpointNodeID("", "", "10.0.0.1", "40000")
+		server  = report.MakeEndpointNodeID("", "", "10.0.0.2", "80")
+		clients = report.MakeReport()
+		servers = report.MakeReport()
+	)
+	clients.Host.AddNode(report.MakeNode(report.MakeHostNodeID("client")))
+	clients.Endpoint.AddNode(report.MakeNode(client).WithAdjacent(server).
+		WithSet(report.ReverseDNSNames, report.MakeStringSet("client.local")))
+	clients.Endpoint.Nodes[client] = withCounters(clients.Endpoint.Nodes[client], 100)
+	clients.Endpoint.AddNode(report.MakeNode(server))
+	// The server doesn't know who initiated the connection, and sees it
+	// the other way around
+	servers.Host.AddNode(report.MakeNode(report.MakeHostNodeID("server")))
+	servers.Endpoint.AddNode(report.MakeNode(server).WithAdjacent(client))
+	servers.Endpoint.AddNode(withCounters(report.MakeNode(client), 120).
+		WithSet(report.ReverseDNSNames, report.MakeStringSet("client.example.com")))
+
+	have := app.NewJoiningMerger().Merge([]report.Report{clients, servers}).Endpoint.Nodes
+	if want := report.MakeIDList(server); !reflect.DeepEqual(want, have[client].Adjacency) {
+		t.Errorf("want %v, have %v", want, have[client].Adjacency)
+	}
+	if len(have[server].Adjacency) != 0 {
+		t.Errorf("expected the reversed connection to be dropped, have %v", have[server].Adjacency)
+	}
+	if sent, _ := have[client].Counters.Lookup("bytes_sent"); sent != 120 {
+		t.Errorf("expected the highest count of the bytes sent, have %d", sent)
+	}
+	if names, _ := have[client].Sets.Lookup(report.ReverseDNSNames); !reflect.DeepEqual(report.MakeStringSet("client.example.com", "client.local"), names) {
+		t.Errorf("expected the names seen by either probe, have %v", names)
+	}
+}
+
+func withCounters(n report.Node, sent int) report.Node {
+	n.Counters = n.Counters.Add("bytes_sent", sent)
+	return n
+}
+
 func BenchmarkFastMerger(b *testing.B) {
 	benchmarkMerger(b, app.NewFastMerger())
 }
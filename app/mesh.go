@@ -0,0 +1,95 @@
+package app
+
+import (
+	"hash/fnv"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/report"
+)
+
+// meshPeersPerProbe is how many hosts each probe measures the latency
+// to. Every host is assigned a different subset, so that the whole mesh
+// gets measured without every probe pinging every other host.
+const meshPeersPerProbe = 3
+
+// MeshPeers returns the hosts the probe should measure the latency to:
+// up to n of the other hosts in the report, each with the address of one
+// of its interfaces. Probes not in the report get no peers.
+func MeshPeers(rpt report.Report, probeID string, n int) []xfer.Peer {
+	if probeID == "" {
+		return nil
+	}
+	self := ""
+	for _, node := range rpt.Host.Nodes {
+		if id, _ := node.Latest.Lookup(report.ControlProbeID); id == probeID {
+			self, _ = report.ParseHostNodeID(node.ID)
+			break
+		}
+	}
+	if self == "" {
+		return nil
+	}
+
+	peers := []xfer.Peer{}
+	for hostID, address := range hostAddresses(rpt) {
+		if hostID != self {
+			peers = append(peers, xfer.Peer{HostID: hostID, Address: address})
+		}
+	}
+	// Rendezvous hashing: each host gets a stable, different subset,
+	// which only changes a little as hosts come and go.
+	score := func(peer xfer.Peer) uint64 {
+		h := fnv.New64a()
+		h.Write([]byte(self + report.EdgeDelim + peer.HostID))
+		return h.Sum64()
+	}
+	sort.Slice(peers, func(i, j int) bool { return score(peers[i]) < score(peers[j]) })
+	if len(peers) > n {
+		peers = peers[:n]
+	}
+	return peers
+}
+
+// hostAddresses returns an IPv4 address of every host with network
+// interfaces in the report, preferring those of physical interfaces.
+func hostAddresses(rpt report.Report) map[string]string {
+	ids := make([]string, 0, len(rpt.NetworkInterface.Nodes))
+	for id := range rpt.NetworkInterface.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	addresses := map[string]string{}
+	physical := map[string]bool{}
+	for _, id := range ids {
+		node := rpt.NetworkInterface.Nodes[id]
+		hostNodeIDs, _ := node.Parents.Lookup(report.Host)
+		if len(hostNodeIDs) != 1 {
+			continue
+		}
+		hostID, ok := report.ParseHostNodeID(hostNodeIDs[0])
+		if !ok || physical[hostID] {
+			continue
+		}
+		kind, _ := node.Latest.Lookup(host.InterfaceType)
+		if _, ok := addresses[hostID]; ok && kind != host.PhysicalInterface {
+			continue
+		}
+		addrs, _ := node.Sets.Lookup(host.InterfaceAddresses)
+		for _, addr := range addrs {
+			if i := strings.IndexByte(addr, '/'); i >= 0 {
+				addr = addr[:i]
+			}
+			if ip := net.ParseIP(addr); ip != nil && ip.To4() != nil && !ip.IsLoopback() {
+				addresses[hostID] = addr
+				physical[hostID] = kind == host.PhysicalInterface
+				break
+			}
+		}
+	}
+	return addresses
+}
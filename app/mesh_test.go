@@ -0,0 +1,44 @@
+package app_test
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestMeshPeers(t *testing.T) {
+	rpt := report.MakeReport()
+	addresses := map[string]string{"hostA": "10.0.0.1", "hostB": "10.0.0.2", "hostC": "10.0.0.3", "hostD": "10.0.0.4"}
+	for hostID, address := range addresses {
+		hostNodeID := report.MakeHostNodeID(hostID)
+		rpt.Host.AddNode(report.MakeNodeWith(hostNodeID, map[string]string{report.ControlProbeID: "probe-" + hostID}))
+		// The physical interface is preferred over the bridge
+		rpt.NetworkInterface.AddNode(report.MakeNodeWith(report.MakeNetworkInterfaceNodeID(hostID, "docker0"), map[string]string{
+			host.InterfaceType: host.VirtualInterface,
+		}).WithParent(report.Host, hostNodeID).WithSet(host.InterfaceAddresses, report.MakeStringSet("172.17.0.1/16")))
+		rpt.NetworkInterface.AddNode(report.MakeNodeWith(report.MakeNetworkInterfaceNodeID(hostID, "eth0"), map[string]string{
+			host.InterfaceType: host.PhysicalInterface,
+		}).WithParent(report.Host, hostNodeID).WithSet(host.InterfaceAddresses, report.MakeStringSet("fe80::1/64", address+"/24")))
+	}
+
+	if peers := app.MeshPeers(rpt, "unknown-probe", 2); len(peers) != 0 {
+		t.Errorf("expected no peers for an unknown probe, got %v", peers)
+	}
+	peers := app.MeshPeers(rpt, "probe-hostA", 2)
+	if len(peers) != 2 {
+		t.Fatalf("expected 2 peers, got %v", peers)
+	}
+	for _, peer := range peers {
+		if peer.HostID == "hostA" {
+			t.Errorf("expected the host of the probe not to be a peer, got %v", peers)
+		}
+		if want := addresses[peer.HostID]; peer.Address != want {
+			t.Errorf("%s: want address %s, have %s", peer.HostID, want, peer.Address)
+		}
+	}
+	if all := app.MeshPeers(rpt, "probe-hostA", 10); len(all) != 3 || all[0] != peers[0] || all[1] != peers[1] {
+		t.Errorf("expected a stable subset of the peers, got %v and %v", peers, all)
+	}
+}
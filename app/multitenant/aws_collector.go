@@ -28,6 +28,10 @@ const (
 	tsField     = "ts"
 	reportField = "report"
 	natsTimeout = 10 * time.Second
+
+	// maxBackfillAge bounds how far back probes may backfill reports,
+	// the older being stored as if captured then
+	maxBackfillAge = 15 * time.Minute
 )
 
 var (
@@ -104,6 +108,17 @@ type AWSCollectorConfig struct {
 	NatsHost       string
 	MemcacheClient *MemcacheClient
 	Window         time.Duration
+	// CompactAfter is how long after the end of an hour its reports are
+	// merged into a snapshot, checking every CompactInterval; 0 never
+	// compacts them.
+	CompactAfter    time.Duration
+	CompactInterval time.Duration
+	// HistoryCacheSize is how many merged reports of past windows are
+	// cached in memory, and HistoryCacheDiskSize how many more are on
+	// disk in HistoryCacheDir, if set; 0 caches none.
+	HistoryCacheSize     int
+	HistoryCacheDir      string
+	HistoryCacheDiskSize int
 }
 
 type awsCollector struct {
@@ -116,6 +131,11 @@ type awsCollector struct {
 	memcache  *MemcacheClient
 	window    time.Duration
 
+	history      *historyCache
+	compactAfter time.Duration
+	compactLock  sync.Mutex
+	uncompacted  map[compactRow]struct{}
+
 	nats        *nats.Conn
 	waitersLock sync.Mutex
 	waiters     map[watchKey]*nats.Subscription
@@ -149,7 +169,14 @@ func NewAWSCollector(config AWSCollectorConfig) (AWSCollector, error) {
 
 	// (window * report rate) * number of hosts per user * number of users
 	reportCacheSize := (int(config.Window.Seconds()) / 3) * 10 * 5
-	return &awsCollector{
+	var history *historyCache
+	if config.HistoryCacheSize > 0 {
+		var err error
+		if history, err = newHistoryCache(config.HistoryCacheSize, config.HistoryCacheDir, config.HistoryCacheDiskSize); err != nil {
+			return nil, err
+		}
+	}
+	c := &awsCollector{
 		db:        dynamodb.New(session.New(config.DynamoDBConfig)),
 		s3:        config.S3Store,
 		userIDer:  config.UserIDer,
@@ -160,7 +187,15 @@ func NewAWSCollector(config AWSCollectorConfig) (AWSCollector, error) {
 		window:    config.Window,
 		nats:      nc,
 		waiters:   map[watchKey]*nats.Subscription{},
-	}, nil
+
+		history:      history,
+		compactAfter: config.CompactAfter,
+		uncompacted:  map[compactRow]struct{}{},
+	}
+	if config.CompactAfter > 0 {
+		go c.compactLoop(config.CompactInterval)
+	}
+	return c, nil
 }
 
 // CreateTables creates the required tables in dynamodb
@@ -340,13 +375,52 @@ func (c *awsCollector) Report(ctx context.Context, timestamp time.Time) (report.
 	if err != nil {
 		return report.MakeReport(), err
 	}
+	// The reports of windows ended before the oldest reports are stored
+	// don't change
+	var historyKey string
+	if c.history != nil && timestamp.Before(time.Now().Add(-c.window)) {
+		historyKey = historyReportKey(reportKeys)
+		if rpt, ok := c.history.get(historyKey); ok {
+			return rpt, nil
+		}
+	}
 	log.Debugf("Fetching %d reports to %v", len(reportKeys), timestamp)
 	reports, err := c.getReports(ctx, reportKeys)
 	if err != nil {
 		return report.MakeReport(), err
 	}
 
-	return c.merger.Merge(reports), nil
+	rpt := c.merger.Merge(reports)
+	if historyKey != "" {
+		c.history.set(historyKey, rpt)
+	}
+	return rpt, nil
+}
+
+// EstimateCost implements app.CostEstimator, counting the reports of the
+// windows ending at the timestamps, but for those cached, and estimating
+// their size from the sizes of the reports seen lately.
+func (c *awsCollector) EstimateCost(ctx context.Context, timestamps []time.Time) (app.QueryCost, error) {
+	var (
+		cost app.QueryCost
+		seen = map[string]struct{}{}
+		now  = time.Now()
+	)
+	for _, timestamp := range timestamps {
+		reportKeys, err := c.getReportKeys(ctx, timestamp)
+		if err != nil {
+			return cost, err
+		}
+		if c.history != nil && timestamp.Before(now.Add(-c.window)) && c.history.has(historyReportKey(reportKeys)) {
+			continue
+		}
+		for _, key := range reportKeys {
+			seen[key] = struct{}{}
+		}
+	}
+	cost.Reports = len(seen)
+	cost.Bytes = int64(cost.Reports) * c.s3.AverageReportSize()
+	return cost, nil
 }
 
 func (c *awsCollector) HasReports(ctx context.Context, timestamp time.Time) (bool, error) {
@@ -359,6 +433,19 @@ func (c *awsCollector) HasHistoricReports() bool {
 }
 
 // calculateDynamoKeys generates the row & column keys for Dynamo.
+// backfillTimestamp is when the report goes in the buckets of: when it
+// arrived but, if backfilled, when it was captured, within the max
+// backfill age.
+func backfillTimestamp(rep report.Report, now time.Time) time.Time {
+	if !rep.Backfill || rep.Timestamp.IsZero() || !rep.Timestamp.Before(now) {
+		return now
+	}
+	if oldest := now.Add(-maxBackfillAge); rep.Timestamp.Before(oldest) {
+		return oldest
+	}
+	return rep.Timestamp
+}
+
 func calculateDynamoKeys(userid string, now time.Time) (string, string) {
 	rowKey := fmt.Sprintf("%s-%s", userid, strconv.FormatInt(now.UnixNano()/time.Hour.Nanoseconds(), 10))
 	colKey := strconv.FormatInt(now.UnixNano(), 10)
@@ -421,8 +508,10 @@ func (c *awsCollector) Add(ctx context.Context, rep report.Report, buf []byte) e
 		return err
 	}
 
+	timestamp := backfillTimestamp(rep, time.Now())
+
 	// first, put the report on s3
-	rowKey, colKey := calculateDynamoKeys(userid, time.Now())
+	rowKey, colKey := calculateDynamoKeys(userid, timestamp)
 	reportKey, err := calculateReportKey(rowKey, colKey)
 	if err != nil {
 		return err
@@ -463,8 +552,11 @@ func (c *awsCollector) Add(ctx context.Context, rep report.Report, buf []byte) e
 	if err != nil {
 		return err
 	}
+	if c.compactAfter > 0 {
+		c.written(compactRow{userid, timestamp.UnixNano() / time.Hour.Nanoseconds()})
+	}
 
-	if rep.Shortcut && c.nats != nil {
+	if rep.Shortcut && !rep.Backfill && c.nats != nil {
 		err := c.nats.Publish(userid, []byte(reportKey))
 		natsRequests.WithLabelValues("Publish", instrument.ErrorCode(err)).Add(1)
 		if err != nil {
@@ -0,0 +1,30 @@
+package multitenant
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/report"
+)
+
+func TestBackfillTimestamp(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	for _, tc := range []struct {
+		backfill bool
+		captured time.Time
+		want     time.Time
+	}{
+		{false, now.Add(-time.Minute), now},
+		{true, now.Add(-time.Minute), now.Add(-time.Minute)},
+		{true, time.Time{}, now},
+		// From probes with clocks ahead, or far behind
+		{true, now.Add(time.Minute), now},
+		{true, now.Add(-24 * time.Hour), now.Add(-maxBackfillAge)},
+	} {
+		rep := report.MakeReport()
+		rep.Backfill, rep.Timestamp = tc.backfill, tc.captured
+		if have := backfillTimestamp(rep, now); !have.Equal(tc.want) {
+			t.Errorf("backfill %t captured %v: want %v, have %v", tc.backfill, tc.captured, tc.want, have)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package multitenant
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/weaveworks/common/instrument"
+)
+
+// dynamoBatchSize is the most items written per BatchWriteItem.
+const dynamoBatchSize = 25
+
+var compactedReports = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "scope",
+	Name:      "compacted_reports_total",
+	Help:      "Total count of reports merged into the snapshots of their window.",
+})
+
+func init() {
+	prometheus.MustRegister(compactedReports)
+}
+
+// compactRow is an hour of the reports of a user.
+type compactRow struct {
+	userid string
+	row    int64
+}
+
+func (r compactRow) key() string {
+	return fmt.Sprintf("%s-%s", r.userid, strconv.FormatInt(r.row, 10))
+}
+
+// start is when the hour of the row starts.
+func (r compactRow) start() time.Time {
+	return time.Unix(0, r.row*time.Hour.Nanoseconds())
+}
+
+// end is when the hour of the row ends.
+func (r compactRow) end() time.Time {
+	return time.Unix(0, (r.row+1)*time.Hour.Nanoseconds())
+}
+
+// compacted reports whether the row is due to have been merged into its
+// snapshots by now.
+func (c *awsCollector) compacted(row compactRow, now time.Time) bool {
+	return c.compactAfter > 0 && now.Sub(row.end()) > c.compactAfter
+}
+
+// compactable returns the rows written to which are due for compaction,
+// forgetting them.
+func (c *awsCollector) compactable(now time.Time) []compactRow {
+	c.compactLock.Lock()
+	defer c.compactLock.Unlock()
+	var rows []compactRow
+	for row := range c.uncompacted {
+		if c.compacted(row, now) {
+			rows = append(rows, row)
+			delete(c.uncompacted, row)
+		}
+	}
+	return rows
+}
+
+func (c *awsCollector) written(row compactRow) {
+	c.compactLock.Lock()
+	defer c.compactLock.Unlock()
+	c.uncompacted[row] = struct{}{}
+}
+
+// compactLoop compacts the rows written to after they're due, merging
+// the reports of each window of each hour into a snapshot, stored in
+// place of them. Time travel queries then fetch a snapshot or two rather
+// than every report of their window. Rows written to before the app
+// started are left as they are.
+func (c *awsCollector) compactLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		for _, row := range c.compactable(time.Now()) {
+			if err := c.compact(context.Background(), row); err != nil {
+				log.Errorf("Error compacting the reports of %s: %v", row.key(), err)
+			}
+		}
+	}
+}
+
+// compactItem is a report, or snapshot, stored in a row.
+type compactItem struct {
+	reportKey string
+	colKey    string
+	ts        int64
+}
+
+// quanta groups the reports stored in a row by the quantum of the row,
+// from its start, their timestamps are in, in order of time.
+func quanta(reportKeys []string, start time.Time, quantum time.Duration) ([][]compactItem, error) {
+	byQuantum := map[int64][]compactItem{}
+	for _, reportKey := range reportKeys {
+		colKey := reportColKey(reportKey)
+		ts, err := strconv.ParseInt(colKey, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("report key %q: %v", reportKey, err)
+		}
+		q := (ts - start.UnixNano()) / quantum.Nanoseconds()
+		byQuantum[q] = append(byQuantum[q], compactItem{reportKey, colKey, ts})
+	}
+	result := make([][]compactItem, 0, len(byQuantum))
+	for _, items := range byQuantum {
+		sort.Slice(items, func(i, j int) bool { return items[i].ts < items[j].ts })
+		result = append(result, items)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i][0].ts < result[j][0].ts })
+	return result, nil
+}
+
+// compact merges the reports of each window of the row into a snapshot,
+// a window at a time. Snapshots are of the reports of a window, so that
+// time travel queries get about their window rather than the hour, and a
+// snapshot compacted before is merged with reports of its window written
+// since, e.g. backfilled.
+func (c *awsCollector) compact(ctx context.Context, row compactRow) error {
+	keys, err := c.reportKeysInRange(ctx, row.userid, row.row, row.start(), row.end())
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+	qs, err := quanta(keys, row.start(), c.window)
+	if err != nil {
+		return err
+	}
+	for _, items := range qs {
+		if len(items) < 2 {
+			continue
+		}
+		if err := c.compactQuantum(ctx, row, items); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compactQuantum stores the merge of the reports of a window at the item
+// of the last of them, so that queries of windows ending before it don't
+// get reports from after them, and then deletes the others. The snapshot
+// is stored under a key of its own, so that no cache has it stale.
+func (c *awsCollector) compactQuantum(ctx context.Context, row compactRow, items []compactItem) error {
+	reportKeys := make([]string, 0, len(items))
+	for _, item := range items {
+		reportKeys = append(reportKeys, item.reportKey)
+	}
+	reports, err := c.getReports(ctx, reportKeys)
+	if err != nil {
+		return err
+	}
+	buf, err := c.merger.Merge(reports).WriteBinary()
+	if err != nil {
+		return err
+	}
+	last := items[len(items)-1]
+	snapshotKey, err := calculateReportKey(row.key(), last.colKey)
+	if err != nil {
+		return err
+	}
+	snapshotKey = fmt.Sprintf("%s-snapshot-%d", snapshotKey, time.Now().UnixNano())
+	if _, err := c.s3.StoreReportBytes(ctx, snapshotKey, buf.Bytes()); err != nil {
+		return err
+	}
+	if _, err := c.putItemInDynamo(row.key(), last.colKey, snapshotKey); err != nil {
+		return err
+	}
+	// Only now that the snapshot is in place are the reports deleted
+	colKeys := make([]string, 0, len(items)-1)
+	for _, item := range items[:len(items)-1] {
+		colKeys = append(colKeys, item.colKey)
+	}
+	if err := c.deleteItemsInDynamo(ctx, row.key(), colKeys); err != nil {
+		return err
+	}
+	if err := c.s3.DeleteReports(ctx, reportKeys); err != nil {
+		return err
+	}
+	compactedReports.Add(float64(len(items)))
+	log.Debugf("Compacted %d reports of %s into %s", len(items), row.key(), snapshotKey)
+	return nil
+}
+
+// reportColKey is the range key of the item of a report, which its key
+// ends with, but for the suffix of snapshots.
+func reportColKey(reportKey string) string {
+	colKey := reportKey[strings.LastIndex(reportKey, "/")+1:]
+	if i := strings.Index(colKey, "-"); i >= 0 {
+		colKey = colKey[:i]
+	}
+	return colKey
+}
+
+func (c *awsCollector) deleteItemsInDynamo(ctx context.Context, rowKey string, colKeys []string) error {
+	requests := make([]*dynamodb.WriteRequest, 0, len(colKeys))
+	for _, colKey := range colKeys {
+		requests = append(requests, &dynamodb.WriteRequest{
+			DeleteRequest: &dynamodb.DeleteRequest{
+				Key: map[string]*dynamodb.AttributeValue{
+					hourField: {S: aws.String(rowKey)},
+					tsField:   {N: aws.String(colKey)},
+				},
+			},
+		})
+	}
+	for len(requests) > 0 {
+		n := len(requests)
+		if n > dynamoBatchSize {
+			n = dynamoBatchSize
+		}
+		var resp *dynamodb.BatchWriteItemOutput
+		err := instrument.TimeRequestHistogram(ctx, "DynamoDB.BatchWriteItem", dynamoRequestDuration, func(_ context.Context) error {
+			var err error
+			resp, err = c.db.BatchWriteItem(&dynamodb.BatchWriteItemInput{
+				RequestItems: map[string][]*dynamodb.WriteRequest{c.tableName: requests[:n]},
+			})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		// Unprocessed deletes are retried with the next batch, after
+		// backing off
+		unprocessed := resp.UnprocessedItems[c.tableName]
+		if len(unprocessed) > 0 {
+			time.Sleep(50 * time.Millisecond)
+		}
+		requests = append(unprocessed, requests[n:]...)
+	}
+	return nil
+}
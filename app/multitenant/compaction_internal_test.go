@@ -0,0 +1,76 @@
+package multitenant
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCompactable(t *testing.T) {
+	c := &awsCollector{compactAfter: 10 * time.Minute, uncompacted: map[compactRow]struct{}{}}
+	now := time.Date(2017, 3, 1, 12, 5, 0, 0, time.UTC)
+	hour := func(t time.Time) int64 { return t.UnixNano() / time.Hour.Nanoseconds() }
+	var (
+		old     = compactRow{"user", hour(now.Add(-2 * time.Hour))}
+		recent  = compactRow{"user", hour(now.Add(-time.Hour))} // ended 5 minutes ago
+		current = compactRow{"user", hour(now)}
+	)
+	for _, row := range []compactRow{old, recent, current} {
+		c.written(row)
+	}
+
+	if want, have := []compactRow{old}, c.compactable(now); !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	if have := c.compactable(now); len(have) != 0 {
+		t.Errorf("expected compacted rows to be forgotten, have %v", have)
+	}
+	if want, have := []compactRow{recent}, c.compactable(now.Add(10*time.Minute)); !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	if c.compacted(current, now) {
+		t.Errorf("expected the current hour not to be compacted")
+	}
+}
+
+func TestQuanta(t *testing.T) {
+	start := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+	key := func(d time.Duration, suffix string) string {
+		return fmt.Sprintf("0123abcd/%d%s", start.Add(d).UnixNano(), suffix)
+	}
+	keys := []string{
+		key(20*time.Second, ""),
+		key(3*time.Second, ""),
+		key(14*time.Second, "-snapshot-42"), // compacted before
+		key(16*time.Second, ""),
+		key(0, ""),
+	}
+	have, err := quanta(keys, start, 15*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var haveKeys [][]string
+	for _, items := range have {
+		var ks []string
+		for _, item := range items {
+			ks = append(ks, item.reportKey)
+		}
+		haveKeys = append(haveKeys, ks)
+	}
+	want := [][]string{
+		{keys[4], keys[1], keys[2]},
+		{keys[3], keys[0]},
+	}
+	if !reflect.DeepEqual(want, haveKeys) {
+		t.Errorf("want %v, have %v", want, haveKeys)
+	}
+	if want, have := strconv.FormatInt(start.Add(14*time.Second).UnixNano(), 10), have[0][2].colKey; want != have {
+		t.Errorf("want the range key of the snapshot %s, have %s", want, have)
+	}
+
+	if _, err := quanta([]string{"0123abcd/nope"}, start, 15*time.Second); err == nil {
+		t.Error("expected keys without a timestamp to fail")
+	}
+}
@@ -0,0 +1,143 @@
+package multitenant
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bluele/gcache"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/weaveworks/scope/report"
+)
+
+const historyExt = ".msgpack.gz"
+
+var historyCacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "scope",
+	Name:      "history_cache_requests_total",
+	Help:      "Total count of merged historic reports requested from the history cache, by tier and result.",
+}, []string{"tier", "result"})
+
+func init() {
+	prometheus.MustRegister(historyCacheRequests)
+}
+
+// historyCache caches the merged reports of past windows, which don't
+// change, so that scrubbing through the same range again doesn't fetch,
+// decode and merge their reports again. Merged reports are kept decoded
+// in memory, and spilt to disk when evicted from memory, if given a
+// directory. It is keyed by the reports merged.
+type historyCache struct {
+	memory gcache.Cache
+	disk   gcache.Cache // of the paths of the reports on disk
+	dir    string
+}
+
+// newHistoryCache makes a history cache of size merged reports in
+// memory, and diskSize on disk in dir, if any.
+func newHistoryCache(size int, dir string, diskSize int) (*historyCache, error) {
+	c := &historyCache{dir: dir}
+	builder := gcache.New(size).LRU()
+	if dir != "" && diskSize > 0 {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, err
+		}
+		// Left over from a previous run, and not tracked
+		if stale, err := filepath.Glob(filepath.Join(dir, "*"+historyExt)); err == nil {
+			for _, path := range stale {
+				os.Remove(path)
+			}
+		}
+		c.disk = gcache.New(diskSize).LRU().EvictedFunc(func(_, path interface{}) {
+			os.Remove(path.(string))
+		}).Build()
+		builder = builder.EvictedFunc(func(key, rpt interface{}) {
+			go c.spill(key.(string), rpt.(report.Report))
+		})
+	}
+	c.memory = builder.Build()
+	return c, nil
+}
+
+// historyReportKey is the key of the merger of the reports of keys.
+func historyReportKey(reportKeys []string) string {
+	sorted := append([]string{}, reportKeys...)
+	sort.Strings(sorted)
+	sum := sha256.Sum256([]byte(strings.Join(sorted, ",")))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *historyCache) get(key string) (report.Report, bool) {
+	if rpt, err := c.memory.Get(key); err == nil {
+		historyCacheRequests.WithLabelValues("memory", "hit").Inc()
+		return rpt.(report.Report), true
+	}
+	historyCacheRequests.WithLabelValues("memory", "miss").Inc()
+	if c.disk == nil {
+		return report.Report{}, false
+	}
+	path, err := c.disk.Get(key)
+	if err != nil {
+		historyCacheRequests.WithLabelValues("disk", "miss").Inc()
+		return report.Report{}, false
+	}
+	buf, err := ioutil.ReadFile(path.(string))
+	if err != nil {
+		historyCacheRequests.WithLabelValues("disk", "miss").Inc()
+		return report.Report{}, false
+	}
+	rpt, err := report.MakeFromBytes(buf)
+	if err != nil {
+		log.Warnf("Error reading cached report %s: %v", path, err)
+		historyCacheRequests.WithLabelValues("disk", "miss").Inc()
+		return report.Report{}, false
+	}
+	historyCacheRequests.WithLabelValues("disk", "hit").Inc()
+	c.memory.Set(key, *rpt)
+	return *rpt, true
+}
+
+// has tells whether the merged report is cached.
+func (c *historyCache) has(key string) bool {
+	if _, err := c.memory.Get(key); err == nil {
+		return true
+	}
+	if c.disk == nil {
+		return false
+	}
+	_, err := c.disk.Get(key)
+	return err == nil
+}
+
+func (c *historyCache) set(key string, rpt report.Report) {
+	c.memory.Set(key, rpt)
+}
+
+// spill writes a report evicted from memory to disk.
+func (c *historyCache) spill(key string, rpt report.Report) {
+	if _, err := c.disk.Get(key); err == nil {
+		return
+	}
+	buf, err := rpt.WriteBinary()
+	if err != nil {
+		log.Warnf("Error spilling cached report %s: %v", key, err)
+		return
+	}
+	path := filepath.Join(c.dir, key+historyExt)
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		log.Warnf("Error spilling cached report %s: %v", key, err)
+		return
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		log.Warnf("Error spilling cached report %s: %v", key, err)
+		return
+	}
+	c.disk.Set(key, path)
+}
@@ -0,0 +1,37 @@
+package multitenant
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/report"
+)
+
+func TestHistoryCache(t *testing.T) {
+	c, err := newHistoryCache(1, "", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := historyReportKey([]string{"b", "a"})
+	if key != historyReportKey([]string{"a", "b"}) {
+		t.Errorf("expected the key of reports not to depend on their order")
+	}
+	if key == historyReportKey([]string{"a"}) {
+		t.Errorf("expected the key of other reports to differ")
+	}
+
+	if _, ok := c.get(key); ok {
+		t.Fatal("expected an empty cache")
+	}
+	rpt := report.MakeReport()
+	rpt.ID = "merged"
+	c.set(key, rpt)
+	if have, ok := c.get(key); !ok || have.ID != rpt.ID {
+		t.Errorf("expected the cached report, have %v", have.ID)
+	}
+
+	// Evicted, with no disk to spill to
+	c.set(historyReportKey([]string{"c"}), report.MakeReport())
+	if _, ok := c.get(key); ok {
+		t.Errorf("expected the oldest report to be evicted")
+	}
+}
@@ -2,6 +2,7 @@ package multitenant
 
 import (
 	"bytes"
+	"sync"
 
 	"context"
 	"github.com/aws/aws-sdk-go/aws"
@@ -22,10 +23,42 @@ var (
 	}, []string{"method", "status_code"})
 )
 
+// s3DeleteBatchSize is the most objects deleted per DeleteObjects.
+const s3DeleteBatchSize = 1000
+
 // S3Store is an S3 client that stores and retrieves Reports.
 type S3Store struct {
 	s3         *s3.S3
 	bucketName string
+	sizes      *reportSizes
+}
+
+// reportSizes is the moving average of the sizes of the reports stored
+// and fetched, to estimate the cost of queries.
+type reportSizes struct {
+	sync.Mutex
+	average float64
+}
+
+// reportSizesWeight is the weight of each new size in the average.
+const reportSizesWeight = 0.05
+
+func (r *reportSizes) observe(size int64) {
+	r.Lock()
+	defer r.Unlock()
+	if r.average == 0 {
+		r.average = float64(size)
+		return
+	}
+	r.average += reportSizesWeight * (float64(size) - r.average)
+}
+
+// AverageReportSize is the moving average of the sizes of the reports
+// stored and fetched, or 0 before any.
+func (store *S3Store) AverageReportSize() int64 {
+	store.sizes.Lock()
+	defer store.sizes.Unlock()
+	return int64(store.sizes.average)
 }
 
 func init() {
@@ -37,6 +70,7 @@ func NewS3Client(config *aws.Config, bucketName string) S3Store {
 	return S3Store{
 		s3:         s3.New(session.New(config)),
 		bucketName: bucketName,
+		sizes:      &reportSizes{},
 	}
 }
 
@@ -83,6 +117,9 @@ func (store *S3Store) fetchReport(ctx context.Context, key string) (*report.Repo
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.ContentLength != nil {
+		store.sizes.observe(*resp.ContentLength)
+	}
 	return report.MakeFromBinary(resp.Body)
 }
 
@@ -96,5 +133,34 @@ func (store *S3Store) StoreReportBytes(ctx context.Context, key string, buf []by
 		})
 		return err
 	})
+	if err == nil {
+		store.sizes.observe(int64(len(buf)))
+	}
 	return len(buf), err
 }
+
+// DeleteReports deletes reports.
+func (store *S3Store) DeleteReports(ctx context.Context, keys []string) error {
+	for len(keys) > 0 {
+		n := len(keys)
+		if n > s3DeleteBatchSize {
+			n = s3DeleteBatchSize
+		}
+		objects := make([]*s3.ObjectIdentifier, 0, n)
+		for _, key := range keys[:n] {
+			objects = append(objects, &s3.ObjectIdentifier{Key: aws.String(key)})
+		}
+		err := instrument.TimeRequestHistogram(ctx, "S3.DeleteObjects", s3RequestDuration, func(_ context.Context) error {
+			_, err := store.s3.DeleteObjects(&s3.DeleteObjectsInput{
+				Bucket: aws.String(store.bucketName),
+				Delete: &s3.Delete{Objects: objects, Quiet: aws.Bool(true)},
+			})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		keys = keys[n:]
+	}
+	return nil
+}
@@ -0,0 +1,342 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"context"
+	"github.com/ghodss/yaml"
+	apiv1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/weaveworks/scope/probe/kubernetes"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+)
+
+const (
+	defaultPolicyStep = 15 * time.Second
+	// Upper bound on the number of reports merged to generate policies
+	maxPolicySamples = 100
+)
+
+// ignoredPodLabels are added by controllers to tell apart the pods of
+// different revisions, so they make poor selectors.
+var ignoredPodLabels = map[string]struct{}{
+	"pod-template-hash":        {},
+	"controller-revision-hash": {},
+	"pod-template-generation":  {},
+}
+
+var invalidPolicyNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+func podLabels(n report.Node) map[string]string {
+	labels := map[string]string{}
+	n.Latest.ForEach(func(key string, _ time.Time, value string) {
+		if !strings.HasPrefix(key, kubernetes.LabelPrefix) {
+			return
+		}
+		label := strings.TrimPrefix(key, kubernetes.LabelPrefix)
+		if _, ok := ignoredPodLabels[label]; !ok {
+			labels[label] = value
+		}
+	})
+	return labels
+}
+
+func labelsKey(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for k, v := range labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+type ingressPeer struct {
+	peer  networkingv1.NetworkPolicyPeer
+	ports map[policyPort]struct{}
+}
+
+type policyPort struct {
+	protocol apiv1.Protocol
+	port     int
+}
+
+type policyPortsByNumber []policyPort
+
+func (p policyPortsByNumber) Len() int      { return len(p) }
+func (p policyPortsByNumber) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p policyPortsByNumber) Less(i, j int) bool {
+	if p[i].port != p[j].port {
+		return p[i].port < p[j].port
+	}
+	return p[i].protocol < p[j].protocol
+}
+
+type podGroup struct {
+	labels map[string]string
+	peers  map[string]*ingressPeer
+}
+
+// NetworkPolicies generates one ingress NetworkPolicy for every set of
+// pods in namespace, allowing exactly the incoming traffic observed
+// between the rendered pods. Pods are selected by their labels, so pods
+// without labels are left out. Traffic from other namespaces and from
+// outside the cluster is allowed by source address.
+func NetworkPolicies(nodes report.Nodes, namespace string) []networkingv1.NetworkPolicy {
+	groups := map[string]*podGroup{}
+	groupOf := map[string]string{}
+	for id, n := range nodes {
+		if n.Topology != report.Pod {
+			continue
+		}
+		if ns, _ := n.Latest.Lookup(kubernetes.Namespace); ns != namespace {
+			continue
+		}
+		labels := podLabels(n)
+		if len(labels) == 0 {
+			continue
+		}
+		key := labelsKey(labels)
+		if _, ok := groups[key]; !ok {
+			groups[key] = &podGroup{labels: labels, peers: map[string]*ingressPeer{}}
+		}
+		groupOf[id] = key
+	}
+
+	for srcID, src := range nodes {
+		for _, dstID := range src.Adjacency {
+			key, ok := groupOf[dstID]
+			if !ok || srcID == dstID {
+				continue
+			}
+			addPeers(groups[key], src, nodes[dstID], groupOf[srcID], groups)
+		}
+	}
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	names := map[string]int{}
+	result := make([]networkingv1.NetworkPolicy, 0, len(groups))
+	for _, key := range keys {
+		result = append(result, groups[key].policy(namespace, names))
+	}
+	return result
+}
+
+// addPeers adds the connections from src to dst as peers of g. srcKey
+// is the group src belongs to, if any.
+func addPeers(g *podGroup, src, dst report.Node, srcKey string, groups map[string]*podGroup) {
+	dstEndpoints := report.MakeIDList()
+	dst.Children.ForEach(func(child report.Node) {
+		if child.Topology == report.Endpoint {
+			dstEndpoints = dstEndpoints.Add(child.ID)
+		}
+	})
+	src.Children.ForEach(func(ep report.Node) {
+		if ep.Topology != report.Endpoint {
+			return
+		}
+		_, srcAddr, _, ok := report.ParseEndpointNodeID(ep.ID)
+		if !ok {
+			return
+		}
+		for _, dstEndpointID := range ep.Adjacency.Intersection(dstEndpoints) {
+			_, _, port, ok := report.ParseEndpointNodeID(dstEndpointID)
+			if !ok {
+				continue
+			}
+			portNum, err := strconv.Atoi(port)
+			if err != nil {
+				continue
+			}
+			var (
+				peerKey string
+				peer    networkingv1.NetworkPolicyPeer
+			)
+			if srcKey != "" {
+				peerKey = "pods:" + srcKey
+				peer.PodSelector = &metav1.LabelSelector{MatchLabels: groups[srcKey].labels}
+			} else {
+				peerKey = "ip:" + srcAddr
+				peer.IPBlock = &networkingv1.IPBlock{CIDR: hostCIDR(srcAddr)}
+			}
+			p, ok := g.peers[peerKey]
+			if !ok {
+				p = &ingressPeer{peer: peer, ports: map[policyPort]struct{}{}}
+				g.peers[peerKey] = p
+			}
+			protocol := apiv1.ProtocolTCP
+			if report.IsUDPEndpointNodeID(dstEndpointID) {
+				protocol = apiv1.ProtocolUDP
+			}
+			p.ports[policyPort{protocol, portNum}] = struct{}{}
+		}
+	})
+}
+
+// hostCIDR is the CIDR of the address alone, of IPv4 (mapped to IPv6 or
+// not) or IPv6.
+func hostCIDR(addr string) string {
+	ip := net.ParseIP(addr)
+	switch {
+	case ip == nil:
+		return addr + "/32"
+	case ip.To4() != nil:
+		return ip.To4().String() + "/32"
+	}
+	return ip.String() + "/128"
+}
+
+// policy builds the NetworkPolicy of the group, naming it uniquely
+// amongst names.
+func (g *podGroup) policy(namespace string, names map[string]int) networkingv1.NetworkPolicy {
+	keys := make([]string, 0, len(g.labels))
+	for k := range g.labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	values := make([]string, 0, len(keys))
+	for _, k := range keys {
+		values = append(values, g.labels[k])
+	}
+	name := strings.Trim(invalidPolicyNameChars.ReplaceAllString(strings.ToLower(strings.Join(values, "-")), "-"), "-")
+	if len(name) > 50 {
+		name = strings.Trim(name[:50], "-")
+	}
+	name = "scope-" + name
+	if n := names[name]; n > 0 {
+		names[name]++
+		name = fmt.Sprintf("%s-%d", name, n)
+	} else {
+		names[name] = 1
+	}
+
+	peerKeys := make([]string, 0, len(g.peers))
+	for k := range g.peers {
+		peerKeys = append(peerKeys, k)
+	}
+	sort.Strings(peerKeys)
+	ingress := []networkingv1.NetworkPolicyIngressRule{}
+	for _, k := range peerKeys {
+		p := g.peers[k]
+		ports := make([]policyPort, 0, len(p.ports))
+		for port := range p.ports {
+			ports = append(ports, port)
+		}
+		sort.Sort(policyPortsByNumber(ports))
+		rule := networkingv1.NetworkPolicyIngressRule{From: []networkingv1.NetworkPolicyPeer{p.peer}}
+		for _, port := range ports {
+			protocol, portValue := port.protocol, intstr.FromInt(port.port)
+			rule.Ports = append(rule.Ports, networkingv1.NetworkPolicyPort{Protocol: &protocol, Port: &portValue})
+		}
+		ingress = append(ingress, rule)
+	}
+
+	return networkingv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "networking.k8s.io/v1",
+			Kind:       "NetworkPolicy",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: g.labels},
+			Ingress:     ingress,
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+}
+
+// NetworkPolicy suggestions for a namespace, as YAML
+func makeNetworkPoliciesHandler(rep Reporter) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			respondWith(w, http.StatusBadRequest, err)
+			return
+		}
+		namespace := r.Form.Get("namespace")
+		if namespace == "" {
+			respondWith(w, http.StatusBadRequest, fmt.Errorf("missing 'namespace'"))
+			return
+		}
+		to, err := parseTimestamp(r.Form.Get("to"))
+		if err != nil {
+			respondWith(w, http.StatusBadRequest, err)
+			return
+		}
+		from := to
+		if f := r.Form.Get("from"); f != "" {
+			if from, err = parseTimestamp(f); err != nil {
+				respondWith(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		step := defaultPolicyStep
+		if s := r.Form.Get("step"); s != "" {
+			if step, err = time.ParseDuration(s); err != nil || step <= 0 {
+				respondWith(w, http.StatusBadRequest, fmt.Errorf("invalid step '%s'", s))
+				return
+			}
+		}
+		if from.After(to) || to.Sub(from)/step >= maxPolicySamples {
+			respondWith(w, http.StatusBadRequest, fmt.Errorf("window must be ordered and span at most %d steps", maxPolicySamples))
+			return
+		}
+		timestamps := []time.Time{to}
+		for t := from; t.Before(to); t = t.Add(step) {
+			timestamps = append(timestamps, t)
+		}
+		if code, err := checkQueryCost(ctx, r, timestamps, r.Form.Get("override") == "true"); err != nil {
+			respondWith(w, code, err)
+			return
+		}
+
+		// Merge the reports across the window, so the policies allow
+		// everything observed during it
+		rpt, err := rep.Report(ctx, to)
+		if err != nil {
+			respondWith(w, http.StatusInternalServerError, err)
+			return
+		}
+		for _, t := range timestamps[1:] {
+			sample, err := rep.Report(ctx, t)
+			if err != nil {
+				respondWith(w, http.StatusInternalServerError, err)
+				return
+			}
+			rpt = rpt.Merge(sample)
+		}
+
+		var buf bytes.Buffer
+		for i, policy := range NetworkPolicies(render.PodRenderer.Render(rpt).Nodes, namespace) {
+			out, err := yaml.Marshal(policy)
+			if err != nil {
+				respondWith(w, http.StatusInternalServerError, err)
+				return
+			}
+			if i > 0 {
+				buf.WriteString("---\n")
+			}
+			buf.Write(out)
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf.Bytes())
+	}
+}
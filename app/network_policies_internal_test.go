@@ -0,0 +1,17 @@
+package app
+
+import (
+	"testing"
+)
+
+func TestHostCIDR(t *testing.T) {
+	for addr, want := range map[string]string{
+		"10.0.0.1":        "10.0.0.1/32",
+		"fd00::1":         "fd00::1/128",
+		"::ffff:10.0.0.1": "10.0.0.1/32",
+	} {
+		if have := hostCIDR(addr); have != want {
+			t.Errorf("%s: want %s, have %s", addr, want, have)
+		}
+	}
+}
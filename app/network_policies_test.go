@@ -0,0 +1,67 @@
+package app_test
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/probe/kubernetes"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+func TestNetworkPolicies(t *testing.T) {
+	rpt := fixture.Report.Copy()
+	for id, app := range map[string]string{fixture.ClientPodNodeID: "client", fixture.ServerPodNodeID: "server"} {
+		rpt.Pod.Nodes[id] = rpt.Pod.Nodes[id].WithLatests(map[string]string{
+			kubernetes.LabelPrefix + "app":               app,
+			kubernetes.LabelPrefix + "pod-template-hash": "1234",
+		})
+	}
+
+	policies := app.NetworkPolicies(render.PodRenderer.Render(rpt).Nodes, fixture.KubernetesNamespace)
+	if len(policies) != 2 {
+		t.Fatalf("expected 2 policies, got %v", policies)
+	}
+	client, server := policies[0], policies[1]
+	if client.Name != "scope-client" || server.Name != "scope-server" {
+		t.Fatalf("unexpected policy names %q, %q", client.Name, server.Name)
+	}
+	if len(client.Spec.Ingress) != 0 {
+		t.Errorf("expected client to allow no ingress, got %v", client.Spec.Ingress)
+	}
+	if labels := server.Spec.PodSelector.MatchLabels; len(labels) != 1 || labels["app"] != "server" {
+		t.Errorf("unexpected selector %v", server.Spec.PodSelector)
+	}
+
+	fromClient, fromInternet := false, false
+	for _, rule := range server.Spec.Ingress {
+		if len(rule.Ports) != 1 || rule.Ports[0].Port.IntValue() != 80 {
+			t.Errorf("expected ingress on port 80 only, got %v", rule.Ports)
+		}
+		for _, peer := range rule.From {
+			if peer.PodSelector != nil && peer.PodSelector.MatchLabels["app"] == "client" {
+				fromClient = true
+			}
+			if peer.IPBlock != nil && peer.IPBlock.CIDR == fixture.RandomClientIP+"/32" {
+				fromInternet = true
+			}
+		}
+	}
+	if !fromClient || !fromInternet {
+		t.Errorf("expected ingress from client pods and the internet, got %v", server.Spec.Ingress)
+	}
+
+	if policies := app.NetworkPolicies(render.PodRenderer.Render(rpt).Nodes, "other"); len(policies) != 0 {
+		t.Errorf("expected no policies for another namespace, got %v", policies)
+	}
+}
+
+func TestAPINetworkPolicies(t *testing.T) {
+	ts := topologyServer()
+	defer ts.Close()
+
+	is400(t, ts, "/api/network-policies")
+	is400(t, ts, "/api/network-policies?namespace=ping&step=-1s")
+	is400(t, ts, "/api/network-policies?namespace=ping&from=2017-01-01T00:00:00Z&to=2016-01-01T00:00:00Z")
+	is200(t, ts, "/api/network-policies?namespace=ping")
+}
@@ -0,0 +1,377 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ugorji/go/codec"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+)
+
+// apiParam is a query parameter of an operation of the API.
+type apiParam struct {
+	name, description string
+}
+
+// apiOperation documents an operation of the API, for the OpenAPI
+// specification. The schemas of the bodies are generated from the Go
+// types of the samples, as encoded by respondWith.
+type apiOperation struct {
+	method, path, summary string
+	query                 []apiParam
+	request               interface{}
+	response              interface{}
+	// status of success, 200 by default
+	status int
+	// contentType of the bodies, when not JSON
+	contentType string
+	// websocket operations upgrade, and then stream messages of response
+	websocket bool
+	// deprecated operations have a successor in v2
+	deprecated bool
+}
+
+var (
+	timestampParam = apiParam{"timestamp", "Time of the report to render, RFC3339, now by default"}
+	fromParam      = apiParam{"from", "Start of the time range, RFC3339"}
+	toParam        = apiParam{"to", "End of the time range, RFC3339, now by default"}
+	fieldsParam    = apiParam{"fields", "Comma-separated fields of the node summaries to return, all by default"}
+	focusParam     = apiParam{"focus", "ID of the node to render the neighbourhood of"}
+	hopsParam      = apiParam{"hops", "Hops of the neighbourhood of the focus to render, 1 by default"}
+	thresholdParam = apiParam{"threshold", "Relative change of metrics below which nodes aren't reported as changed"}
+	overrideParam  = apiParam{"override", "Set to true by admins to bypass the query limits"}
+
+	// reportSchema stands for reports, whose encoding isn't worth
+	// describing field by field
+	reportSchema = map[string]interface{}{"type": "object", "description": "A report, as encoded by the probes"}
+)
+
+// apiOperations are the operations of the API. TestOpenAPIRoutes fails if
+// they don't match the routes registered.
+var apiOperations = []apiOperation{
+	{method: "GET", path: "/api", summary: "Details of the app", response: xfer.Details{}},
+	{method: "GET", path: "/api/openapi.json", summary: "This specification", response: map[string]interface{}{}},
+	{method: "GET", path: "/api/topology", summary: "The views, with their options and counts", response: []APITopologyDesc{}},
+	{method: "GET", path: "/api/topology/{topology}", summary: "Render a view",
+		query: []apiParam{timestampParam, fieldsParam, focusParam, hopsParam,
+			{"limit", "Maximum number of nodes of a page"}, {"cursor", "Cursor of the page to return"}, {"layout", "Set to true to lay out the nodes"}},
+		response: APITopology{}, deprecated: true},
+	{method: "GET", path: "/api/v2/topology/{topology}", summary: "Render a page of a view, of up to 500 nodes by default",
+		query: []apiParam{timestampParam, fieldsParam, focusParam, hopsParam,
+			{"limit", "Maximum number of nodes of a page, up to 5000"}, {"cursor", "Cursor of the page to return"}, {"layout", "Set to true to lay out the nodes"}},
+		response: APITopology{}},
+	{method: "GET", path: "/api/topology/{topology}/ws", summary: "Stream the changes of a view",
+		query:     []apiParam{timestampParam, fieldsParam, focusParam, hopsParam, {"t", "Interval between updates, e.g. 1s"}},
+		websocket: true, response: detailed.Diff{}},
+	{method: "GET", path: "/api/topology/{topology}/changes", summary: "The nodes of a view changed over a time range",
+		query: []apiParam{fromParam, toParam, thresholdParam, overrideParam}, response: APITopologyChanges{}},
+	{method: "GET", path: "/api/topology/{topology}/compare", summary: "A view at two points in time, as a single graph",
+		query: []apiParam{fromParam, toParam, thresholdParam, overrideParam}, response: APITopologyComparison{}},
+	{method: "GET", path: "/api/topology/{topology}/compliance", summary: "The nodes of a view violating the policies", response: APICompliance{}},
+	{method: "GET", path: "/api/topology/{topology}/stats", summary: "Summary statistics of a view", query: []apiParam{timestampParam}, response: APITopologyStats{}},
+	{method: "GET", path: "/api/topology/{topology}/{id}", summary: "The details of a node", query: []apiParam{timestampParam, {"stableId", "Stable ID of the node, to find it by if it has another ID at the timestamp"}}, response: APINode{}},
+	{method: "GET", path: "/api/topology/{topology}/{id}/blast-radius", summary: "The nodes depending on a node, or it depends on",
+		query:    []apiParam{timestampParam, {"direction", "upstream or downstream"}, {"depth", "Maximum hops"}, {"min_weight", "Minimum weight of the edges followed"}},
+		response: APIBlastRadius{}},
+	{method: "GET", path: "/api/report", summary: "The current report", query: []apiParam{timestampParam, {"topology", "Topologies of the report to return, all by default"}}, response: reportSchema},
+	{method: "POST", path: "/api/report", summary: "Publish a report, as probes do", request: reportSchema, contentType: "application/msgpack"},
+	{method: "GET", path: "/api/probes", summary: "The probes reporting", query: []apiParam{{"sparse", "Only tell whether any probe reports"}}, response: []probeDesc{}},
+	{method: "GET", path: "/api/exposure", summary: "The ports exposed to the internet", response: []detailed.ExposedPort{}},
+	{method: "GET", path: "/api/network-policies", summary: "Network policies allowing the traffic seen in a namespace, as YAML",
+		query: []apiParam{{"namespace", "Namespace of the policies"}, fromParam, toParam, {"step", "Interval of the reports sampled, e.g. 1h"}}, contentType: "application/yaml"},
+	{method: "POST", path: "/api/control/{probeID}/{nodeID}/{control}", summary: "Invoke a control of a node", request: map[string]string{}, response: xfer.Response{}},
+	{method: "GET", path: "/api/control/ws", summary: "The control connection of a probe", websocket: true},
+	{method: "POST", path: "/api/bandwidth-test", summary: "Test the throughput between two hosts",
+		query:    []apiParam{{"from", "Host node ID to send from"}, {"to", "Host node ID to send to"}, {"duration", "Duration of the test, e.g. 10s"}, {"rate", "Maximum rate"}},
+		response: APIBandwidthTest{}},
+	{method: "GET", path: "/api/pipe/{pipeID}", summary: "The UI end of a pipe", websocket: true},
+	{method: "GET", path: "/api/pipe/{pipeID}/probe", summary: "The probe end of a pipe", websocket: true},
+	{method: "GET", path: "/api/pipe/{pipeID}/check", summary: "Check a pipe exists", status: http.StatusNoContent},
+	{method: "DELETE", path: "/api/pipe/{pipeID}", summary: "Close a pipe"},
+	{method: "POST", path: "/api/pipe/{pipeID}", summary: "Close a pipe"},
+	{method: "GET", path: "/api/views", summary: "The saved views", response: []View{}},
+	{method: "POST", path: "/api/views", summary: "Save a view", request: View{}, response: View{}, status: http.StatusCreated},
+	{method: "GET", path: "/api/views/{id}", summary: "A saved view", response: View{}},
+	{method: "PUT", path: "/api/views/{id}", summary: "Update a saved view", request: View{}, response: View{}},
+	{method: "DELETE", path: "/api/views/{id}", summary: "Delete a saved view", status: http.StatusNoContent},
+	{method: "GET", path: "/api/annotations", summary: "The annotations", query: []apiParam{{"topology", "View of the annotations, all by default"}}, response: []Annotation{}},
+	{method: "POST", path: "/api/annotations", summary: "Annotate a node", request: Annotation{}, response: Annotation{}, status: http.StatusCreated},
+	{method: "DELETE", path: "/api/annotations/{id}", summary: "Delete an annotation", status: http.StatusNoContent},
+	{method: "GET", path: "/api/maintenance-windows", summary: "The maintenance windows", response: []MaintenanceWindow{}},
+	{method: "POST", path: "/api/maintenance-windows", summary: "Add a maintenance window", request: MaintenanceWindow{}, response: MaintenanceWindow{}, status: http.StatusCreated},
+	{method: "DELETE", path: "/api/maintenance-windows/{id}", summary: "Delete a maintenance window", status: http.StatusNoContent},
+	{method: "GET", path: "/api/deployments", summary: "The deployments over a time range",
+		query: []apiParam{{"service", "Service of the deployments, all by default"}, fromParam, toParam}, response: []Deployment{}},
+	{method: "POST", path: "/api/deployments", summary: "Record a deployment", request: Deployment{}, response: Deployment{}, status: http.StatusCreated},
+	{method: "DELETE", path: "/api/deployments/{id}", summary: "Delete a deployment", status: http.StatusNoContent},
+	{method: "GET", path: "/api/admin/merge-audit", summary: "Which of the reports merged the metadata of a node is from, with -app.merge-audit",
+		query: []apiParam{timestampParam, {"topology", "Topology of the reports of the node, e.g. container"}, {"id", "ID of the node in the reports"}}, response: MergeAudit{}},
+	{method: "POST", path: "/api/admin/reload", summary: "Reload the configuration", response: map[string][]string{}},
+	{method: "GET", path: "/api/cluster/digest", summary: "The reports a peer has, for gossip", response: []ClusterDigestEntry{}},
+	{method: "GET", path: "/api/cluster/reports/{id}", summary: "A report of a peer", contentType: "application/msgpack"},
+	{method: "GET", path: "/api/plugin-renderers", summary: "The renderer plugins", response: []PluginRenderer{}},
+	{method: "POST", path: "/api/plugin-renderers", summary: "Register a renderer plugin", request: PluginRenderer{}, response: PluginRenderer{}, status: http.StatusCreated},
+	{method: "DELETE", path: "/api/plugin-renderers/{name}", summary: "Unregister a renderer plugin", status: http.StatusNoContent},
+}
+
+// wireSchemas are the schemas of the types encoding themselves, as they
+// are encoded rather than as they are declared.
+var wireSchemas = map[reflect.Type]map[string]interface{}{
+	reflect.TypeOf(detailed.ControlInstance{}): objectSchema(map[string]interface{}{
+		"probeId": stringSchema, "nodeId": stringSchema, "id": stringSchema,
+		"human": stringSchema, "icon": stringSchema, "rank": integerSchema,
+	}),
+	reflect.TypeOf(report.MetricRow{}): objectSchema(map[string]interface{}{
+		"id": stringSchema, "label": stringSchema, "format": stringSchema, "group": stringSchema,
+		"value": numberSchema, "valueEmpty": booleanSchema, "priority": numberSchema,
+		"samples": map[string]interface{}{"type": "array", "items": objectSchema(map[string]interface{}{"date": dateTimeSchema, "value": numberSchema})},
+		"min":     numberSchema, "max": numberSchema, "first": stringSchema, "last": stringSchema, "url": stringSchema,
+	}),
+	reflect.TypeOf(xfer.PluginSpecs{}): {"type": "array", "items": map[string]interface{}{"$ref": "#/components/schemas/xfer.PluginSpec"}},
+}
+
+// wireRefs are the named types wireSchemas refer to.
+var wireRefs = []interface{}{xfer.PluginSpec{}}
+
+var (
+	stringSchema   = map[string]interface{}{"type": "string"}
+	integerSchema  = map[string]interface{}{"type": "integer"}
+	numberSchema   = map[string]interface{}{"type": "number"}
+	booleanSchema  = map[string]interface{}{"type": "boolean"}
+	dateTimeSchema = map[string]interface{}{"type": "string", "format": "date-time"}
+
+	selferType   = reflect.TypeOf((*codec.Selfer)(nil)).Elem()
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+	pathParamRe  = regexp.MustCompile(`\{([^}]+)\}`)
+)
+
+func objectSchema(properties map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{"type": "object", "properties": properties}
+}
+
+// schemas generates the schemas of Go types, as components of the
+// specification.
+type schemas struct {
+	components map[string]interface{}
+	// unknown are the types encoding themselves without a wire schema
+	unknown map[string]struct{}
+}
+
+func (s *schemas) name(t reflect.Type) string {
+	pkg := t.PkgPath()
+	return pkg[strings.LastIndex(pkg, "/")+1:] + "." + t.Name()
+}
+
+func (s *schemas) of(t reflect.Type) map[string]interface{} {
+	if schema, ok := wireSchemas[t]; ok {
+		return schema
+	}
+	if t.Kind() != reflect.Ptr && t != timeType && t.Kind() != reflect.Interface && reflect.PtrTo(t).Implements(selferType) {
+		s.unknown[s.name(t)] = struct{}{}
+		return map[string]interface{}{}
+	}
+	switch {
+	case t == timeType:
+		return dateTimeSchema
+	case t == durationType:
+		return map[string]interface{}{"type": "integer", "description": "Nanoseconds"}
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return s.of(t.Elem())
+	case reflect.Bool:
+		return booleanSchema
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return integerSchema
+	case reflect.Float32, reflect.Float64:
+		return numberSchema
+	case reflect.String:
+		return stringSchema
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": s.of(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		name := s.name(t)
+		if _, ok := s.components[name]; !ok {
+			// Set first, for recursive types
+			s.components[name] = map[string]interface{}{}
+			s.components[name] = s.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+func (s *schemas) object(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	s.fields(t, properties)
+	return objectSchema(properties)
+}
+
+// fields adds the fields of a struct, and of those it embeds, as they are
+// encoded in JSON.
+func (s *schemas) fields(t reflect.Type, properties map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			if _, ok := wireSchemas[ft]; !ok {
+				s.fields(ft, properties)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = s.of(f.Type)
+	}
+}
+
+func (s *schemas) content(contentType string, v interface{}) map[string]interface{} {
+	var schema map[string]interface{}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			schema = map[string]interface{}{"type": "object"}
+		} else {
+			schema = v
+		}
+	case nil:
+		schema = map[string]interface{}{"type": "string", "format": "binary"}
+	default:
+		schema = s.of(reflect.TypeOf(v))
+	}
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	return map[string]interface{}{contentType: map[string]interface{}{"schema": schema}}
+}
+
+func (s *schemas) operation(op apiOperation) map[string]interface{} {
+	var parameters []interface{}
+	for _, match := range pathParamRe.FindAllStringSubmatch(op.path, -1) {
+		parameters = append(parameters, map[string]interface{}{
+			"name": match[1], "in": "path", "required": true, "schema": stringSchema,
+		})
+	}
+	for _, p := range op.query {
+		parameters = append(parameters, map[string]interface{}{
+			"name": p.name, "in": "query", "description": p.description, "schema": stringSchema,
+		})
+	}
+
+	status := op.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]interface{}{"description": http.StatusText(status)}
+	switch {
+	case op.websocket:
+		status = http.StatusSwitchingProtocols
+		success = map[string]interface{}{"description": "Upgraded to a WebSocket"}
+		if op.response != nil {
+			success["description"] = "Upgraded to a WebSocket, streaming messages of the schema"
+			success["content"] = s.content("", op.response)
+		}
+	case op.response != nil:
+		success["content"] = s.content("", op.response)
+	case op.contentType != "" && op.request == nil:
+		success["content"] = s.content(op.contentType, nil)
+	}
+
+	result := map[string]interface{}{
+		"summary": op.summary,
+		"responses": map[string]interface{}{
+			fmt.Sprint(status): success,
+			"default": map[string]interface{}{
+				"description": "Error",
+				"content":     map[string]interface{}{"application/json": map[string]interface{}{"schema": stringSchema}},
+			},
+		},
+	}
+	if op.deprecated {
+		result["deprecated"] = true
+	}
+	if len(parameters) > 0 {
+		result["parameters"] = parameters
+	}
+	if op.request != nil {
+		result["requestBody"] = map[string]interface{}{"required": true, "content": s.content(op.contentType, op.request)}
+	}
+	return result
+}
+
+// openAPISpec generates the OpenAPI 3 specification of the API, and the
+// names of the types it couldn't describe.
+func openAPISpec() (map[string]interface{}, []string) {
+	s := &schemas{components: map[string]interface{}{}, unknown: map[string]struct{}{}}
+	for _, v := range wireRefs {
+		s.of(reflect.TypeOf(v))
+	}
+	paths := map[string]interface{}{}
+	for _, op := range apiOperations {
+		item, ok := paths[op.path].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[op.path] = item
+		}
+		item[strings.ToLower(op.method)] = s.operation(op)
+	}
+	unknown := make([]string, 0, len(s.unknown))
+	for name := range s.unknown {
+		unknown = append(unknown, name)
+	}
+	sort.Strings(unknown)
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "Scope",
+			"version":     Version,
+			"description": "The unversioned routes are those of v1, also served under /api/v1. v2 serves the same routes under /api/v2, but for the operations of /api/v2 specified, which supersede the deprecated ones.",
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": s.components},
+	}, unknown
+}
+
+var openAPI struct {
+	sync.Once
+	spec map[string]interface{}
+}
+
+func handleOpenAPI(_ context.Context, w http.ResponseWriter, r *http.Request) {
+	openAPI.Do(func() {
+		openAPI.spec, _ = openAPISpec()
+	})
+	respondWith(w, http.StatusOK, openAPI.spec)
+}
@@ -0,0 +1,99 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+)
+
+func allRoutes() *mux.Router {
+	router := mux.NewRouter().SkipClean(true)
+	RegisterReportPostHandler(nil, router)
+	RegisterControlRoutes(router, nil)
+	RegisterBandwidthTestRoute(router, nil, nil)
+	RegisterPipeRoutes(router, nil)
+	RegisterTopologyRoutes(router, nil, nil)
+	RegisterViewRoutes(router, nil, nil)
+	RegisterAnnotationRoutes(router, nil, nil)
+	RegisterMaintenanceRoutes(router, nil, nil)
+	RegisterDeploymentRoutes(router, nil, nil)
+	RegisterReloadRoute(router, nil)
+	RegisterClusterRoutes(router, nil)
+	RegisterMergeAuditRoute(router, nil)
+	RegisterPluginRendererRoutes(router)
+	return router
+}
+
+// TestOpenAPIRoutes fails when the handlers and the specification drift.
+func TestOpenAPIRoutes(t *testing.T) {
+	router := allRoutes()
+	documented := map[string]bool{}
+	for _, op := range apiOperations {
+		documented[op.method+" "+op.path] = true
+		path := pathParamRe.ReplaceAllString(op.path, "x")
+		var match mux.RouteMatch
+		if !router.Match(httptest.NewRequest(op.method, path, nil), &match) {
+			t.Errorf("%s %s is specified, but not routed", op.method, op.path)
+		}
+	}
+
+	router.Walk(func(route *mux.Route, _ *mux.Router, ancestors []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil || !strings.HasPrefix(path, "/api") {
+			return nil
+		}
+		req := httptest.NewRequest("GET", pathParamRe.ReplaceAllString(path, "x"), nil)
+		for _, method := range []string{"GET", "POST", "PUT", "DELETE"} {
+			req.Method = method
+			if routes(req, append(ancestors, route)) && !documented[method+" "+path] {
+				t.Errorf("%s %s is routed, but not specified", method, path)
+			}
+		}
+		return nil
+	})
+}
+
+// routes tells whether a route, and its ancestors, match a request.
+func routes(req *http.Request, routes []*mux.Route) bool {
+	for _, route := range routes {
+		var match mux.RouteMatch
+		if !route.Match(req, &match) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestOpenAPISchemas(t *testing.T) {
+	_, unknown := openAPISpec()
+	if len(unknown) > 0 {
+		t.Errorf("types encoding themselves need wire schemas: %v", unknown)
+	}
+}
+
+func TestOpenAPIHandler(t *testing.T) {
+	ts := httptest.NewServer(allRoutes())
+	defer ts.Close()
+	resp, err := http.Get(ts.URL + "/api/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var spec struct {
+		OpenAPI string                            `json:"openapi"`
+		Paths   map[string]map[string]interface{} `json:"paths"`
+	}
+	if err := codec.NewDecoder(resp.Body, &codec.JsonHandle{}).Decode(&spec); err != nil {
+		t.Fatal(err)
+	}
+	if spec.OpenAPI != "3.0.3" {
+		t.Errorf("want 3.0.3, have %q", spec.OpenAPI)
+	}
+	if _, ok := spec.Paths["/api/topology/{topology}"]["get"]; !ok {
+		t.Errorf("expected the topology operation, have %v", spec.Paths)
+	}
+}
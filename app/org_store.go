@@ -0,0 +1,152 @@
+package app
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// orgStore keeps the records users save on the app, such as views or
+// annotations, by org and then ID, persisting them to a file if given
+// one. They are kept apart from reports, so they outlive the report
+// window. Every org may only save so many, so that none can fill the
+// store.
+type orgStore struct {
+	path      string
+	maxPerOrg int
+	// errors of records not found, and of orgs at maxPerOrg
+	errNotFound, errTooMany error
+
+	mtx     sync.Mutex
+	records map[string]map[string]interface{}
+}
+
+// newOrgStore makes a new orgStore, loading any records previously saved
+// at path, as decoded by decode. With no path, records are kept in
+// memory only.
+func newOrgStore(path string, maxPerOrg int, errNotFound, errTooMany error, decode func([]byte) (interface{}, error)) (*orgStore, error) {
+	s := &orgStore{
+		path:        path,
+		maxPerOrg:   maxPerOrg,
+		errNotFound: errNotFound,
+		errTooMany:  errTooMany,
+		records:     map[string]map[string]interface{}{},
+	}
+	if path == "" {
+		return s, nil
+	}
+	buf, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var saved map[string]map[string]json.RawMessage
+	if err := json.Unmarshal(buf, &saved); err != nil {
+		return nil, err
+	}
+	for org, records := range saved {
+		s.records[org] = make(map[string]interface{}, len(records))
+		for id, raw := range records {
+			record, err := decode(raw)
+			if err != nil {
+				return nil, err
+			}
+			s.records[org][id] = record
+		}
+	}
+	return s, nil
+}
+
+// each calls f with every record of an org, in no particular order.
+func (s *orgStore) each(org string, f func(record interface{})) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, record := range s.records[org] {
+		f(record)
+	}
+}
+
+// get returns a record of an org.
+func (s *orgStore) get(org, id string) (interface{}, bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	record, ok := s.records[org][id]
+	return record, ok
+}
+
+// add saves the record newRecord makes with a new ID, once the records
+// of the org expired, if any, are forgotten.
+func (s *orgStore) add(org string, expired func(record interface{}) bool, newRecord func(id string) interface{}) (interface{}, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if expired != nil {
+		for id, record := range s.records[org] {
+			if expired(record) {
+				delete(s.records[org], id)
+			}
+		}
+	}
+	if len(s.records[org]) >= s.maxPerOrg {
+		return nil, s.errTooMany
+	}
+	id := newID()
+	record := newRecord(id)
+	if s.records[org] == nil {
+		s.records[org] = map[string]interface{}{}
+	}
+	s.records[org][id] = record
+	return record, s.save()
+}
+
+// update replaces a record of an org with what change makes of it,
+// unless change fails.
+func (s *orgStore) update(org, id string, change func(old interface{}) (interface{}, error)) (interface{}, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	old, ok := s.records[org][id]
+	if !ok {
+		return nil, s.errNotFound
+	}
+	record, err := change(old)
+	if err != nil {
+		return nil, err
+	}
+	s.records[org][id] = record
+	return record, s.save()
+}
+
+// delete deletes a record of an org, unless check, if given, fails.
+func (s *orgStore) delete(org, id string, check func(old interface{}) error) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	old, ok := s.records[org][id]
+	if !ok {
+		return s.errNotFound
+	}
+	if check != nil {
+		if err := check(old); err != nil {
+			return err
+		}
+	}
+	delete(s.records[org], id)
+	return s.save()
+}
+
+// save writes the records to path, if any. Must be called with the lock
+// held.
+func (s *orgStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	return saveJSON(s.path, s.records)
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,270 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/kubernetes"
+	"github.com/weaveworks/scope/report"
+)
+
+// catalog maps the nodes of services to their owners in the details of
+// nodes. It is nil (and ownership disabled) by default.
+var catalog *Catalog
+
+// EnableCatalog turns on showing the owners of nodes, from the given
+// service catalog.
+func EnableCatalog(c *Catalog) {
+	catalog = c
+}
+
+// Annotations of catalog entities giving ownership beyond spec.owner.
+const (
+	OnCallAnnotation = "scope.weave.works/on-call"
+	TierAnnotation   = "scope.weave.works/tier"
+)
+
+// ownershipLabels are the labels of containers and pods naming the
+// catalog entity of the service they run, after Backstage's own
+// backstage.io/kubernetes-id.
+var ownershipLabels = []string{
+	"backstage.io/kubernetes-id",
+	"app.kubernetes.io/name",
+	"app",
+	"com.docker.compose.service",
+}
+
+// Ownership is who owns a service.
+type Ownership struct {
+	Entity string `json:"entity"`
+	Team   string `json:"team"`
+	OnCall string `json:"onCall,omitempty"`
+	Tier   string `json:"tier,omitempty"`
+}
+
+func (o Ownership) metadataRows() []report.MetadataRow {
+	rows := []report.MetadataRow{
+		{ID: "ownership_team", Label: "Owner", Value: o.Team, Priority: 0.1},
+	}
+	if o.OnCall != "" {
+		rows = append(rows, report.MetadataRow{ID: "ownership_on_call", Label: "On-call", Value: o.OnCall, Priority: 0.2})
+	}
+	if o.Tier != "" {
+		rows = append(rows, report.MetadataRow{ID: "ownership_tier", Label: "Tier", Value: o.Tier, Priority: 0.3})
+	}
+	return rows
+}
+
+// catalogEntity is an entity of a Backstage catalog, as found in
+// catalog-info.yaml files and returned by the catalog API.
+type catalogEntity struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name        string            `yaml:"name"`
+		Labels      map[string]string `yaml:"labels"`
+		Annotations map[string]string `yaml:"annotations"`
+	} `yaml:"metadata"`
+	Spec struct {
+		Owner string `yaml:"owner"`
+	} `yaml:"spec"`
+}
+
+func (e catalogEntity) ownership() Ownership {
+	tier := e.Metadata.Annotations[TierAnnotation]
+	if tier == "" {
+		tier = e.Metadata.Labels["tier"]
+	}
+	return Ownership{
+		Entity: e.Metadata.Name,
+		Team:   strings.TrimPrefix(e.Spec.Owner, "group:"),
+		OnCall: e.Metadata.Annotations[OnCallAnnotation],
+		Tier:   tier,
+	}
+}
+
+// Catalog is the ownership of the components of a service catalog, by
+// name. It is loaded from a file, or fetched periodically from a
+// Backstage catalog API.
+type Catalog struct {
+	path    string // of the file loaded, if any
+	mtx     sync.RWMutex
+	byName  map[string]Ownership
+	quit    chan struct{}
+	cancel  context.CancelFunc // of the fetch in flight, if any
+	stopped sync.WaitGroup
+}
+
+// ParseCatalog reads the components of a catalog, as a stream of YAML
+// (or JSON) documents of entities or lists of entities.
+func ParseCatalog(r io.Reader) (map[string]Ownership, error) {
+	var (
+		byName  = map[string]Ownership{}
+		decoder = yaml.NewDecoder(r)
+	)
+	for {
+		var doc interface{}
+		if err := decoder.Decode(&doc); err == io.EOF {
+			return byName, nil
+		} else if err != nil {
+			return nil, err
+		}
+		// Decode again, in the shape of the document
+		buf, err := yaml.Marshal(doc)
+		if err != nil {
+			return nil, err
+		}
+		var entities []catalogEntity
+		if _, ok := doc.([]interface{}); ok {
+			err = yaml.Unmarshal(buf, &entities)
+		} else {
+			entities = make([]catalogEntity, 1)
+			err = yaml.Unmarshal(buf, &entities[0])
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entities {
+			if e.Kind == "Component" && e.Metadata.Name != "" && e.Spec.Owner != "" {
+				byName[e.Metadata.Name] = e.ownership()
+			}
+		}
+	}
+}
+
+// LoadCatalog reads the catalog of the file at path.
+func LoadCatalog(path string) (*Catalog, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	byName, err := ParseCatalog(f)
+	if err != nil {
+		return nil, err
+	}
+	return &Catalog{path: path, byName: byName}, nil
+}
+
+// Prepare implements Reloadable, reading the file of the catalog again.
+// Catalogs fetched from Backstage are refreshed regardless.
+func (c *Catalog) Prepare() (func(), error) {
+	if c.path == "" {
+		return func() {}, nil
+	}
+	reloaded, err := LoadCatalog(c.path)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		c.mtx.Lock()
+		defer c.mtx.Unlock()
+		c.byName = reloaded.byName
+	}, nil
+}
+
+// backstageTimeout bounds each fetch of the Backstage catalog, so one
+// hung doesn't hold up those after it.
+const backstageTimeout = 30 * time.Second
+
+// NewBackstageCatalog fetches the components of the Backstage catalog at
+// baseURL, and then again every interval until stopped.
+func NewBackstageCatalog(baseURL, token string, interval time.Duration) (*Catalog, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Catalog{quit: make(chan struct{}), cancel: cancel}
+	client := &http.Client{Timeout: backstageTimeout}
+	fetch := func() error {
+		byName, err := fetchBackstage(ctx, client, baseURL, token)
+		if err != nil {
+			return err
+		}
+		c.mtx.Lock()
+		c.byName = byName
+		c.mtx.Unlock()
+		return nil
+	}
+	if err := fetch(); err != nil {
+		cancel()
+		return nil, err
+	}
+	c.stopped.Add(1)
+	go func() {
+		defer c.stopped.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := fetch(); err != nil {
+					log.Warnf("Error fetching the service catalog: %v", err)
+				}
+			case <-c.quit:
+				return
+			}
+		}
+	}()
+	return c, nil
+}
+
+func fetchBackstage(ctx context.Context, client *http.Client, baseURL, token string) (map[string]Ownership, error) {
+	req, err := http.NewRequest("GET", strings.TrimSuffix(baseURL, "/")+"/api/catalog/entities?filter=kind=component", nil)
+	if err != nil {
+		return nil, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", req.URL, resp.Status)
+	}
+	return ParseCatalog(resp.Body)
+}
+
+// Stop stops fetching the catalog.
+func (c *Catalog) Stop() {
+	if c.quit != nil {
+		close(c.quit)
+		c.cancel()
+		c.stopped.Wait()
+	}
+}
+
+// Len is the number of components of the catalog.
+func (c *Catalog) Len() int {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return len(c.byName)
+}
+
+// Owner returns the ownership of a rendered node, matching the catalog
+// components by the labels of the node naming them, or else by the label
+// of the node.
+func (c *Catalog) Owner(n report.Node, label string) (Ownership, bool) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	for _, key := range ownershipLabels {
+		for _, prefix := range []string{kubernetes.LabelPrefix, docker.LabelPrefix} {
+			if name, ok := n.Latest.Lookup(prefix + key); ok {
+				if o, ok := c.byName[name]; ok {
+					return o, true
+				}
+			}
+		}
+	}
+	o, ok := c.byName[label]
+	return o, ok
+}
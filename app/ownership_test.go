@@ -0,0 +1,163 @@
+package app_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ugorji/go/codec"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/probe/kubernetes"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+const testCatalog = `
+apiVersion: backstage.io/v1alpha1
+kind: Component
+metadata:
+  name: apache
+  annotations:
+    scope.weave.works/on-call: web-oncall
+  labels:
+    tier: "1"
+spec:
+  type: service
+  owner: group:team-web
+---
+apiVersion: backstage.io/v1alpha1
+kind: Component
+metadata:
+  name: checkout
+spec:
+  owner: team-payments
+---
+apiVersion: backstage.io/v1alpha1
+kind: Group
+metadata:
+  name: team-web
+`
+
+func TestParseCatalog(t *testing.T) {
+	components, err := app.ParseCatalog(strings.NewReader(testCatalog))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]app.Ownership{
+		"apache":   {Entity: "apache", Team: "team-web", OnCall: "web-oncall", Tier: "1"},
+		"checkout": {Entity: "checkout", Team: "team-payments"},
+	}
+	if fmt.Sprint(components) != fmt.Sprint(want) {
+		t.Errorf("want %v, have %v", want, components)
+	}
+
+	// The Backstage API lists entities in JSON
+	components, err = app.ParseCatalog(strings.NewReader(`[{"kind": "Component", "metadata": {"name": "checkout"}, "spec": {"owner": "team-payments"}}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(components) != 1 || components["checkout"].Team != "team-payments" {
+		t.Errorf("unexpected components %v", components)
+	}
+}
+
+func TestCatalogOwner(t *testing.T) {
+	f, err := ioutil.TempFile("", "catalog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(testCatalog)
+	f.Close()
+	catalog, err := app.LoadCatalog(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pod := report.MakeNodeWith("pod1", map[string]string{kubernetes.LabelPrefix + "app.kubernetes.io/name": "checkout"})
+	if o, ok := catalog.Owner(pod, "checkout-5d8f9-abcde"); !ok || o.Team != "team-payments" {
+		t.Errorf("expected the pod to be matched by label, got %v %v", o, ok)
+	}
+	if o, ok := catalog.Owner(report.MakeNode("process1"), "apache"); !ok || o.Team != "team-web" {
+		t.Errorf("expected the process to be matched by name, got %v %v", o, ok)
+	}
+	if _, ok := catalog.Owner(report.MakeNode("process2"), "nginx"); ok {
+		t.Errorf("expected no owner for nginx")
+	}
+}
+
+func TestBackstageCatalog(t *testing.T) {
+	backstage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/catalog/entities" || r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `[{"kind": "Component", "metadata": {"name": "apache"}, "spec": {"owner": "team-web"}}]`)
+	}))
+	defer backstage.Close()
+	catalog, err := app.NewBackstageCatalog(backstage.URL, "secret", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer catalog.Stop()
+	app.EnableCatalog(catalog)
+	defer app.EnableCatalog(nil)
+
+	ts := topologyServer()
+	defer ts.Close()
+	var node app.APINode
+	body := getRawJSON(t, ts, "/api/topology/processes/"+fixture.ServerProcessNodeID)
+	if err := codec.NewDecoderBytes(body, &codec.JsonHandle{}).Decode(&node); err != nil {
+		t.Fatal(err)
+	}
+	if len(node.Node.Metadata) == 0 || node.Node.Metadata[0].Label != "Owner" || node.Node.Metadata[0].Value != "team-web" {
+		t.Errorf("expected the owner in the details of apache, got %v", node.Node.Metadata)
+	}
+}
+
+func TestBackstageCatalogStopsHungFetches(t *testing.T) {
+	var (
+		fetches int32
+		hung    = make(chan struct{}, 1)
+		release = make(chan struct{})
+	)
+	backstage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Hang the fetches after the first, until told not to
+		if atomic.AddInt32(&fetches, 1) > 1 {
+			select {
+			case hung <- struct{}{}:
+			default:
+			}
+			select {
+			case <-r.Context().Done():
+			case <-release:
+			}
+		}
+		fmt.Fprint(w, `[]`)
+	}))
+	defer backstage.Close()
+	defer close(release)
+	catalog, err := app.NewBackstageCatalog(backstage.URL, "", time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-hung
+
+	stopped := make(chan struct{})
+	go func() {
+		catalog.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stopping the catalog waited for the fetch in flight")
+	}
+}
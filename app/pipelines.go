@@ -0,0 +1,302 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+)
+
+// Pipeline is a custom view defined declaratively, rather than in Go: a
+// built-in topology to start from, the steps rendering it, in order, and
+// extra metadata to show for the nodes of the view.
+type Pipeline struct {
+	ID     string `yaml:"id"`
+	Name   string `yaml:"name"`
+	Parent string `yaml:"parent,omitempty"`
+	Rank   int    `yaml:"rank,omitempty"`
+	// Base is the ID of the built-in topology the view starts from
+	Base  string         `yaml:"base"`
+	Steps []PipelineStep `yaml:"steps,omitempty"`
+	// Metadata are the metadata templates to add, by topology
+	Metadata map[string][]PipelineMetadata `yaml:"metadata,omitempty"`
+}
+
+// PipelineStep is a step of a pipeline, exactly one of a filter, a
+// mapping to parents or a grouping.
+type PipelineStep struct {
+	Filter *PipelineFilter `yaml:"filter,omitempty"`
+	// MapTo maps nodes to their parents in the topologies, e.g. service
+	MapTo []string `yaml:"mapTo,omitempty"`
+	// GroupBy groups nodes by the value of a metadata key
+	GroupBy string `yaml:"groupBy,omitempty"`
+}
+
+// PipelineFilter keeps the nodes whose value of a metadata key matches
+// a regular expression, or those whose value doesn't if Exclude. Pseudo
+// nodes are kept regardless.
+type PipelineFilter struct {
+	Key     string `yaml:"key"`
+	Match   string `yaml:"match"`
+	Exclude bool   `yaml:"exclude,omitempty"`
+}
+
+// PipelineMetadata is a metadata key of nodes to show, as a metadata
+// template.
+type PipelineMetadata struct {
+	ID       string  `yaml:"id"`
+	Label    string  `yaml:"label"`
+	Priority float64 `yaml:"priority,omitempty"`
+	Truncate int     `yaml:"truncate,omitempty"`
+}
+
+type pipelinesFile struct {
+	Pipelines []Pipeline `yaml:"pipelines"`
+}
+
+// ParsePipelines reads the pipelines of a YAML document of the form
+//
+//	pipelines:
+//	- id: containers-by-team
+//	  name: by team
+//	  parent: containers
+//	  base: containers
+//	  steps:
+//	  - filter: {key: docker_label_team, match: ".+"}
+//	  - groupBy: docker_label_team
+func ParsePipelines(r io.Reader) ([]Pipeline, error) {
+	var file pipelinesFile
+	if err := yaml.NewDecoder(r).Decode(&file); err == io.EOF {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return file.Pipelines, nil
+}
+
+// LoadPipelines reads the pipelines of the file at path.
+func LoadPipelines(path string) ([]Pipeline, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParsePipelines(f)
+}
+
+// topology makes the topology of the pipeline, starting from base.
+func (p Pipeline) topology(base APITopologyDesc) (APITopologyDesc, error) {
+	if p.ID == "" || p.Name == "" {
+		return APITopologyDesc{}, fmt.Errorf("pipelines need an id and a name")
+	}
+	renderer := base.renderer
+	for i, step := range p.Steps {
+		steps := 0
+		if step.Filter != nil {
+			steps++
+			f, err := step.Filter.filterFunc()
+			if err != nil {
+				return APITopologyDesc{}, fmt.Errorf("pipeline %s, step %d: %v", p.ID, i, err)
+			}
+			renderer = render.MakeFilter(f, renderer)
+		}
+		if len(step.MapTo) > 0 {
+			steps++
+			renderer = render.MapToParents(step.MapTo, renderer)
+		}
+		if step.GroupBy != "" {
+			steps++
+			renderer = render.GroupBy(step.GroupBy, renderer)
+		}
+		if steps != 1 {
+			return APITopologyDesc{}, fmt.Errorf("pipeline %s, step %d: steps need one of a filter, mapTo or groupBy", p.ID, i)
+		}
+	}
+	desc := APITopologyDesc{
+		id:          p.ID,
+		parent:      p.Parent,
+		renderer:    renderer,
+		pipeline:    true,
+		Name:        p.Name,
+		Rank:        p.Rank,
+		HideIfEmpty: true,
+	}
+	for topology, metadata := range p.Metadata {
+		if desc.metadataTemplates == nil {
+			desc.metadataTemplates = map[string]report.MetadataTemplates{}
+		}
+		templates := report.MetadataTemplates{}
+		for _, m := range metadata {
+			templates[m.ID] = report.MetadataTemplate{ID: m.ID, Label: m.Label, Priority: m.Priority, Truncate: m.Truncate, From: report.FromLatest}
+		}
+		desc.metadataTemplates[topology] = templates
+	}
+	return desc, nil
+}
+
+func (f PipelineFilter) filterFunc() (render.FilterFunc, error) {
+	if f.Key == "" {
+		return nil, fmt.Errorf("filters need a key")
+	}
+	re, err := regexp.Compile("^(?:" + f.Match + ")$")
+	if err != nil {
+		return nil, err
+	}
+	return func(n report.Node) bool {
+		value, _ := n.Latest.Lookup(f.Key)
+		return re.MatchString(value) != f.Exclude
+	}, nil
+}
+
+// SetPipelines replaces the views of the pipelines previously set, if
+// any, with those of the given pipelines; or returns an error, leaving
+// them as they are, if any pipeline is invalid.
+func (r *Registry) SetPipelines(pipelines []Pipeline) error {
+	apply, err := r.preparePipelines(pipelines)
+	if err != nil {
+		return err
+	}
+	apply()
+	return nil
+}
+
+// preparePipelines validates the pipelines, returning how to set their
+// views.
+func (r *Registry) preparePipelines(pipelines []Pipeline) (func(), error) {
+	r.RLock()
+	defer r.RUnlock()
+	descs := make([]APITopologyDesc, 0, len(pipelines))
+	ids := map[string]struct{}{}
+	for _, p := range pipelines {
+		base, ok := r.items[p.Base]
+		if !ok || base.pipeline || base.plugin {
+			return nil, fmt.Errorf("pipeline %s: unknown base topology %q", p.ID, p.Base)
+		}
+		if existing, ok := r.items[p.ID]; ok && !existing.pipeline {
+			return nil, fmt.Errorf("pipeline %s: another topology has that id", p.ID)
+		}
+		if _, ok := ids[p.ID]; ok {
+			return nil, fmt.Errorf("pipeline %s: defined twice", p.ID)
+		}
+		if parent, ok := r.items[p.Parent]; p.Parent != "" && (!ok || parent.pipeline || parent.plugin || parent.parent != "") {
+			return nil, fmt.Errorf("pipeline %s: unknown parent topology %q", p.ID, p.Parent)
+		}
+		desc, err := p.topology(base)
+		if err != nil {
+			return nil, err
+		}
+		ids[p.ID] = struct{}{}
+		descs = append(descs, desc)
+	}
+
+	return func() {
+		r.Lock()
+		defer r.Unlock()
+		r.remove(func(desc APITopologyDesc) bool { return desc.pipeline })
+		r.add(descs...)
+	}, nil
+}
+
+// remove removes the views matching f, and their entries as
+// sub-topologies. Must be called with the lock held.
+func (r *Registry) remove(f func(APITopologyDesc) bool) {
+	for id, desc := range r.items {
+		if f(desc) {
+			delete(r.items, id)
+			continue
+		}
+		subs := desc.SubTopologies[:0:0]
+		for _, sub := range desc.SubTopologies {
+			if !f(sub) {
+				subs = append(subs, sub)
+			}
+		}
+		desc.SubTopologies = subs
+		r.items[id] = desc
+	}
+}
+
+// Pipelines keeps the views of the pipelines of a file set in the
+// registry, reloading them when the file changes.
+type Pipelines struct {
+	path     string
+	registry *Registry
+	modified time.Time
+	quit     chan struct{}
+	stopped  sync.WaitGroup
+}
+
+// NewPipelines sets the views of the pipelines of the file at path in
+// the default registry, and then checks the file for changes every
+// interval until stopped, if it is positive. Pipelines failing to
+// reload are logged, and the previous ones kept.
+func NewPipelines(path string, interval time.Duration) (*Pipelines, error) {
+	p := &Pipelines{path: path, registry: topologyRegistry, quit: make(chan struct{})}
+	if err := p.load(); err != nil {
+		return nil, err
+	}
+	if interval <= 0 {
+		// Only reloaded when asked to
+		return p, nil
+	}
+	p.stopped.Add(1)
+	go func() {
+		defer p.stopped.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				info, err := os.Stat(p.path)
+				if err != nil || info.ModTime().Equal(p.modified) {
+					continue
+				}
+				if err := p.load(); err != nil {
+					log.Warnf("Error reloading the pipelines: %v", err)
+				} else {
+					log.Infof("Reloaded the pipelines of %s", p.path)
+				}
+			case <-p.quit:
+				return
+			}
+		}
+	}()
+	return p, nil
+}
+
+func (p *Pipelines) load() error {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return err
+	}
+	// Whether it loads or not, don't try again until it changes
+	p.modified = info.ModTime()
+	pipelines, err := LoadPipelines(p.path)
+	if err != nil {
+		return err
+	}
+	return p.registry.SetPipelines(pipelines)
+}
+
+// Prepare implements Reloadable, loading the pipelines of the file again.
+func (p *Pipelines) Prepare() (func(), error) {
+	pipelines, err := LoadPipelines(p.path)
+	if err != nil {
+		return nil, err
+	}
+	return p.registry.preparePipelines(pipelines)
+}
+
+// Stop stops reloading the pipelines.
+func (p *Pipelines) Stop() {
+	close(p.quit)
+	p.stopped.Wait()
+}
@@ -0,0 +1,122 @@
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+const testPipelines = `
+pipelines:
+- id: containers-by-role
+  name: by role
+  parent: containers
+  base: containers
+  steps:
+  - filter: {key: docker_label_myrole, match: ".+"}
+  - groupBy: docker_label_myrole
+  metadata:
+    container:
+    - {id: docker_label_myrole, label: Role, priority: 20}
+`
+
+func TestPipelines(t *testing.T) {
+	pipelines, err := ParsePipelines(strings.NewReader(testPipelines))
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := MakeRegistry()
+	if err := registry.SetPipelines(pipelines); err != nil {
+		t.Fatal(err)
+	}
+	desc, ok := registry.get("containers-by-role")
+	if !ok {
+		t.Fatal("expected the view of the pipeline")
+	}
+	if _, ok := desc.withMetadataTemplates(fixture.Report).Container.MetadataTemplates[docker.LabelPrefix+fixture.TestLabelKey1]; !ok {
+		t.Error("expected the metadata template of the pipeline")
+	}
+	var found bool
+	for _, sub := range mustGet(t, registry, "containers").SubTopologies {
+		found = found || sub.id == "containers-by-role"
+	}
+	if !found {
+		t.Error("expected the view under its parent")
+	}
+
+	renderer, filter, err := registry.RendererForTopology("containers-by-role", nil, fixture.Report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes := render.Render(fixture.Report, renderer, filter).Nodes
+	if _, ok := nodes[fixture.ApplicationLabelValue1]; !ok {
+		t.Errorf("expected a group of the containers of the role, have %v", nodes)
+	}
+
+	// Setting the pipelines again replaces them
+	if err := registry.SetPipelines(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := registry.get("containers-by-role"); ok {
+		t.Error("expected the view of the pipeline gone")
+	}
+	if subs := len(mustGet(t, registry, "containers").SubTopologies); subs != 4 {
+		t.Errorf("expected the built-in sub-topologies only, have %d", subs)
+	}
+}
+
+func mustGet(t *testing.T, r *Registry, id string) APITopologyDesc {
+	desc, ok := r.get(id)
+	if !ok {
+		t.Fatalf("no topology %s", id)
+	}
+	return desc
+}
+
+func TestInvalidPipelines(t *testing.T) {
+	for _, pipeline := range []Pipeline{
+		{ID: "x", Name: "x", Base: "nope"},
+		{ID: "x", Name: "x", Base: "containers", Parent: "nope"},
+		{ID: "containers", Name: "x", Base: "containers"},
+		{ID: "", Name: "x", Base: "containers"},
+		{ID: "x", Name: "x", Base: "containers", Steps: []PipelineStep{{}}},
+		{ID: "x", Name: "x", Base: "containers", Steps: []PipelineStep{{GroupBy: "a", MapTo: []string{"pod"}}}},
+		{ID: "x", Name: "x", Base: "containers", Steps: []PipelineStep{{Filter: &PipelineFilter{Key: "a", Match: "["}}}},
+	} {
+		if err := MakeRegistry().SetPipelines([]Pipeline{pipeline}); err == nil {
+			t.Errorf("expected an error for %+v", pipeline)
+		}
+	}
+}
+
+func TestPipelinesReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pipelines")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "pipelines.yaml")
+	if err := ioutil.WriteFile(path, []byte(testPipelines), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p := &Pipelines{path: path, registry: MakeRegistry()}
+	if err := p.load(); err != nil {
+		t.Fatal(err)
+	}
+	mustGet(t, p.registry, "containers-by-role")
+
+	// Broken pipelines keep the previous ones
+	if err := ioutil.WriteFile(path, []byte("pipelines: [{id: x, name: x, base: nope}]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.load(); err == nil {
+		t.Error("expected an error")
+	}
+	mustGet(t, p.registry, "containers-by-role")
+}
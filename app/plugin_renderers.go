@@ -0,0 +1,321 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/grpc"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+)
+
+const (
+	// PluginRenderMethod is the gRPC method renderer plugins implement.
+	// Requests and responses are encoded as JSON, so that plugins don't
+	// need Scope's protobufs, or any.
+	PluginRenderMethod = "/scope.plugins.Renderer/Render"
+
+	defaultPluginRenderTimeout  = 5 * time.Second
+	maxPluginRenderTimeout      = 30 * time.Second
+	maxPluginRendererBodyLength = 16 * 1024
+)
+
+var (
+	pluginRendererErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "scope",
+		Name:      "plugin_renderer_errors_total",
+		Help:      "Total count of views renderer plugins failed to render, or to render in time.",
+	}, []string{"view"})
+	pluginRenderDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "scope",
+		Name:      "plugin_render_duration_seconds",
+		Help:      "Time in seconds renderer plugins took to render views.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"view"})
+)
+
+func init() {
+	prometheus.MustRegister(pluginRendererErrors)
+	prometheus.MustRegister(pluginRenderDuration)
+}
+
+var pluginRendererName = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// PluginRenderer is a view rendered by an external gRPC service, for
+// views too complex for pipelines. The service is sent a slice of the
+// report, the nodes of the topologies it asks for, and returns the nodes
+// of the view. Views it fails to render in time are empty.
+type PluginRenderer struct {
+	// Name is the ID of the view
+	Name    string `json:"name"`
+	Label   string `json:"label"`
+	Parent  string `json:"parent,omitempty"`
+	Address string `json:"address"`
+	// Topologies are those of the report sent to the plugin
+	Topologies []string `json:"topologies"`
+	// Timeout is how long the plugin has to render, as a duration
+	Timeout string `json:"timeout,omitempty"`
+}
+
+func (p PluginRenderer) validate() error {
+	if !pluginRendererName.MatchString(p.Name) {
+		return fmt.Errorf("invalid renderer plugin name %q", p.Name)
+	}
+	if p.Label == "" || p.Address == "" || len(p.Topologies) == 0 {
+		return fmt.Errorf("renderer plugins need a label, an address and topologies")
+	}
+	rpt := report.MakeReport()
+	for _, t := range p.Topologies {
+		if _, ok := rpt.Topology(t); !ok {
+			return fmt.Errorf("unknown topology %q", t)
+		}
+	}
+	if _, err := p.timeout(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (p PluginRenderer) timeout() (time.Duration, error) {
+	if p.Timeout == "" {
+		return defaultPluginRenderTimeout, nil
+	}
+	timeout, err := time.ParseDuration(p.Timeout)
+	if err != nil || timeout <= 0 || timeout > maxPluginRenderTimeout {
+		return 0, fmt.Errorf("invalid timeout %q, must be at most %v", p.Timeout, maxPluginRenderTimeout)
+	}
+	return timeout, nil
+}
+
+// PluginNode is a node as exchanged with renderer plugins. Nodes the
+// plugins return in no known topology are shown as groups of nodes of
+// the first topology sent.
+type PluginNode struct {
+	ID        string              `json:"id"`
+	Topology  string              `json:"topology,omitempty"`
+	Latest    map[string]string   `json:"latest,omitempty"`
+	Parents   map[string][]string `json:"parents,omitempty"`
+	Adjacency []string            `json:"adjacency,omitempty"`
+}
+
+// PluginRenderRequest is sent to renderer plugins.
+type PluginRenderRequest struct {
+	View       string                  `json:"view"`
+	Topologies map[string][]PluginNode `json:"topologies"`
+}
+
+// PluginRenderResponse is returned by renderer plugins.
+type PluginRenderResponse struct {
+	Nodes []PluginNode `json:"nodes"`
+}
+
+// pluginCodec encodes the messages of renderer plugins as JSON.
+type pluginCodec struct{}
+
+func (pluginCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (pluginCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (pluginCodec) String() string                             { return "json" }
+
+func toPluginNode(n report.Node) PluginNode {
+	result := PluginNode{ID: n.ID, Topology: n.Topology, Latest: map[string]string{}, Adjacency: n.Adjacency}
+	n.Latest.ForEach(func(key string, _ time.Time, value string) {
+		result.Latest[key] = value
+	})
+	for _, topology := range n.Parents.Keys() {
+		if result.Parents == nil {
+			result.Parents = map[string][]string{}
+		}
+		result.Parents[topology], _ = n.Parents.Lookup(topology)
+	}
+	return result
+}
+
+func (n PluginNode) node(defaultTopology string, now time.Time) report.Node {
+	result := report.MakeNode(n.ID)
+	if _, ok := report.MakeReport().Topology(n.Topology); ok || n.Topology == render.Pseudo {
+		result = result.WithTopology(n.Topology)
+	} else {
+		result = result.WithTopology(render.MakeGroupNodeTopology(defaultTopology, "plugin"))
+	}
+	for key, value := range n.Latest {
+		result = result.WithLatest(key, now, value)
+	}
+	for topology, ids := range n.Parents {
+		result = result.WithParents(report.MakeSets().Add(topology, report.MakeStringSet(ids...)))
+	}
+	result.Adjacency = report.MakeIDList(n.Adjacency...)
+	return result
+}
+
+// pluginRenderer renders a view with a renderer plugin.
+type pluginRenderer struct {
+	config  PluginRenderer
+	timeout time.Duration
+	conn    *grpc.ClientConn
+}
+
+func (p pluginRenderer) Render(rpt report.Report) render.Nodes {
+	defer func(begin time.Time) {
+		pluginRenderDuration.WithLabelValues(p.config.Name).Observe(time.Since(begin).Seconds())
+	}(time.Now())
+	req := PluginRenderRequest{View: p.config.Name, Topologies: map[string][]PluginNode{}}
+	for _, name := range p.config.Topologies {
+		t, _ := rpt.Topology(name)
+		nodes := make([]PluginNode, 0, len(t.Nodes))
+		for _, n := range t.Nodes {
+			nodes = append(nodes, toPluginNode(n))
+		}
+		req.Topologies[name] = nodes
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	var resp PluginRenderResponse
+	if err := grpc.Invoke(ctx, PluginRenderMethod, &req, &resp, p.conn); err != nil {
+		log.Warnf("Renderer plugin %s failed to render: %v", p.config.Name, err)
+		pluginRendererErrors.WithLabelValues(p.config.Name).Inc()
+		return render.Nodes{}
+	}
+	var (
+		now    = time.Now()
+		result = make(report.Nodes, len(resp.Nodes))
+	)
+	for _, n := range resp.Nodes {
+		if n.ID != "" {
+			result[n.ID] = n.node(p.config.Topologies[0], now)
+		}
+	}
+	return render.Nodes{Nodes: result}
+}
+
+// setPlugin adds or replaces the view of a renderer plugin. Plugins can't
+// replace other views, nor be the parents of views.
+func (r *Registry) setPlugin(desc APITopologyDesc) error {
+	r.Lock()
+	defer r.Unlock()
+	if existing, ok := r.items[desc.id]; ok && !existing.plugin {
+		return fmt.Errorf("renderer plugin %s: another topology has that id", desc.id)
+	}
+	if parent, ok := r.items[desc.parent]; desc.parent != "" && (!ok || parent.pipeline || parent.plugin || parent.parent != "") {
+		return fmt.Errorf("renderer plugin %s: unknown parent topology %q", desc.id, desc.parent)
+	}
+	r.remove(func(d APITopologyDesc) bool { return d.plugin && d.id == desc.id })
+	r.add(desc)
+	return nil
+}
+
+func (r *Registry) removePlugin(name string) {
+	r.Lock()
+	defer r.Unlock()
+	r.remove(func(d APITopologyDesc) bool { return d.plugin && d.id == name })
+}
+
+// pluginRenderers are the renderer plugins registered, by name.
+var pluginRenderers = struct {
+	sync.Mutex
+	plugins map[string]pluginRenderer
+}{plugins: map[string]pluginRenderer{}}
+
+// RegisterPluginRenderer registers the view of a renderer plugin with
+// the default registry, replacing any of the same name.
+func RegisterPluginRenderer(config PluginRenderer) error {
+	return registerPluginRenderer(topologyRegistry, config)
+}
+
+func registerPluginRenderer(registry *Registry, config PluginRenderer) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+	timeout, _ := config.timeout()
+	pluginRenderers.Lock()
+	defer pluginRenderers.Unlock()
+	conn, err := grpc.Dial(config.Address, grpc.WithInsecure(), grpc.WithCodec(pluginCodec{}))
+	if err != nil {
+		return err
+	}
+	plugin := pluginRenderer{config: config, timeout: timeout, conn: conn}
+	if err := registry.setPlugin(APITopologyDesc{
+		id:          config.Name,
+		parent:      config.Parent,
+		renderer:    plugin,
+		plugin:      true,
+		Name:        config.Label,
+		HideIfEmpty: true,
+	}); err != nil {
+		conn.Close()
+		return err
+	}
+	if old, ok := pluginRenderers.plugins[config.Name]; ok {
+		old.conn.Close()
+	}
+	pluginRenderers.plugins[config.Name] = plugin
+	return nil
+}
+
+func unregisterPluginRenderer(registry *Registry, name string) bool {
+	pluginRenderers.Lock()
+	defer pluginRenderers.Unlock()
+	plugin, ok := pluginRenderers.plugins[name]
+	if !ok {
+		return false
+	}
+	registry.remove(func(desc APITopologyDesc) bool { return desc.plugin && desc.id == name })
+	plugin.conn.Close()
+	delete(pluginRenderers.plugins, name)
+	return true
+}
+
+type pluginRenderersByName []PluginRenderer
+
+func (p pluginRenderersByName) Len() int           { return len(p) }
+func (p pluginRenderersByName) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
+func (p pluginRenderersByName) Less(i, j int) bool { return p[i].Name < p[j].Name }
+
+// RegisterPluginRendererRoutes registers the routes to list, register
+// and unregister renderer plugins. Their views are shared by every org.
+func RegisterPluginRendererRoutes(router *mux.Router) {
+	router.Methods("GET").Path("/api/plugin-renderers").
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pluginRenderers.Lock()
+			result := make([]PluginRenderer, 0, len(pluginRenderers.plugins))
+			for _, plugin := range pluginRenderers.plugins {
+				result = append(result, plugin.config)
+			}
+			pluginRenderers.Unlock()
+			sort.Sort(pluginRenderersByName(result))
+			respondWith(w, http.StatusOK, result)
+		})
+	router.Methods("POST").Path("/api/plugin-renderers").
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var config PluginRenderer
+			if err := codec.NewDecoder(io.LimitReader(r.Body, maxPluginRendererBodyLength), &codec.JsonHandle{}).Decode(&config); err != nil {
+				respondWith(w, http.StatusBadRequest, err)
+				return
+			}
+			if err := RegisterPluginRenderer(config); err != nil {
+				respondWith(w, http.StatusBadRequest, err)
+				return
+			}
+			respondWith(w, http.StatusCreated, config)
+		})
+	router.Methods("DELETE").Path("/api/plugin-renderers/{name}").
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !unregisterPluginRenderer(topologyRegistry, mux.Vars(r)["name"]) {
+				respondWith(w, http.StatusNotFound, fmt.Errorf("no such renderer plugin"))
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+}
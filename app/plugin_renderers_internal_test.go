@@ -0,0 +1,137 @@
+package app
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+// testRendererPlugin serves a renderer plugin grouping containers by
+// host, or not answering in time if slow.
+func testRendererPlugin(t *testing.T, slow bool) (string, func()) {
+	handler := func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+		var req PluginRenderRequest
+		if err := dec(&req); err != nil {
+			return nil, err
+		}
+		if slow {
+			time.Sleep(time.Second)
+		}
+		hosts := map[string][]string{}
+		for _, n := range req.Topologies[report.Container] {
+			for _, host := range n.Parents[report.Host] {
+				hosts[host] = append(hosts[host], n.ID)
+			}
+		}
+		resp := PluginRenderResponse{}
+		for host, ids := range hosts {
+			resp.Nodes = append(resp.Nodes, PluginNode{ID: host, Latest: map[string]string{"containers": ids[0]}})
+		}
+		return &resp, nil
+	}
+	server := grpc.NewServer(grpc.CustomCodec(pluginCodec{}))
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "scope.plugins.Renderer",
+		HandlerType: (*interface{})(nil),
+		Methods:     []grpc.MethodDesc{{MethodName: "Render", Handler: handler}},
+	}, struct{}{})
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(lis)
+	return lis.Addr().String(), server.Stop
+}
+
+func hasSubTopology(registry *Registry, parent, id string) bool {
+	desc, _ := registry.get(parent)
+	for _, sub := range desc.SubTopologies {
+		if sub.id == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPluginRenderer(t *testing.T) {
+	addr, stop := testRendererPlugin(t, false)
+	defer stop()
+	registry := MakeRegistry()
+	config := PluginRenderer{Name: "containers-by-host", Label: "by host", Parent: "containers", Address: addr, Topologies: []string{report.Container}}
+	if err := registerPluginRenderer(registry, config); err != nil {
+		t.Fatal(err)
+	}
+	defer unregisterPluginRenderer(registry, config.Name)
+
+	desc, ok := registry.get(config.Name)
+	if !ok {
+		t.Fatal("expected the view of the plugin")
+	}
+	nodes := desc.renderer.Render(fixture.Report).Nodes
+	if len(nodes) != 2 {
+		t.Fatalf("expected a node per host, got %v", nodes)
+	}
+	for _, n := range nodes {
+		if n.Topology != render.MakeGroupNodeTopology(report.Container, "plugin") {
+			t.Errorf("expected nodes of the plugin to be groups, got %s", n.Topology)
+		}
+	}
+	if !hasSubTopology(registry, "containers", config.Name) {
+		t.Error("expected the view under its parent")
+	}
+
+	// Plugins can't replace built-in views
+	if err := registerPluginRenderer(registry, PluginRenderer{Name: "containers", Label: "containers", Address: addr, Topologies: []string{report.Container}}); err == nil {
+		t.Error("expected plugins not to replace built-in views")
+	}
+
+	if !unregisterPluginRenderer(registry, config.Name) {
+		t.Fatal("expected to unregister the plugin")
+	}
+	if _, ok := registry.get(config.Name); ok {
+		t.Error("expected the view of the plugin to be removed")
+	}
+	if hasSubTopology(registry, "containers", config.Name) {
+		t.Error("expected the view to be removed from its parent")
+	}
+}
+
+func TestPluginRendererTimeout(t *testing.T) {
+	addr, stop := testRendererPlugin(t, true)
+	defer stop()
+	registry := MakeRegistry()
+	config := PluginRenderer{Name: "slow", Label: "slow", Address: addr, Topologies: []string{report.Container}, Timeout: "100ms"}
+	if err := registerPluginRenderer(registry, config); err != nil {
+		t.Fatal(err)
+	}
+	defer unregisterPluginRenderer(registry, config.Name)
+
+	desc, _ := registry.get(config.Name)
+	begin := time.Now()
+	if nodes := desc.renderer.Render(fixture.Report).Nodes; len(nodes) != 0 {
+		t.Errorf("expected no nodes from a plugin timing out, got %v", nodes)
+	}
+	if took := time.Since(begin); took > 500*time.Millisecond {
+		t.Errorf("expected the render to time out, took %v", took)
+	}
+}
+
+func TestInvalidPluginRenderers(t *testing.T) {
+	for _, config := range []PluginRenderer{
+		{Name: "Bad Name", Label: "x", Address: "localhost:1", Topologies: []string{report.Container}},
+		{Name: "x", Label: "x", Address: "localhost:1", Topologies: []string{"nonesuch"}},
+		{Name: "x", Label: "x", Address: "localhost:1", Topologies: []string{report.Container}, Timeout: "1h"},
+		{Name: "x", Label: "x", Topologies: []string{report.Container}},
+	} {
+		if err := registerPluginRenderer(MakeRegistry(), config); err == nil {
+			t.Errorf("expected %v to be invalid", config)
+		}
+	}
+}
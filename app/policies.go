@@ -0,0 +1,177 @@
+package app
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/weaveworks/scope/render/detailed"
+)
+
+// policies is used by the compliance handler to check observed edges.
+// It is nil (and compliance checks disabled) by default.
+var policies *Policies
+
+// EnablePolicies turns on compliance checks in the topology API,
+// against the given policies.
+func EnablePolicies(p *Policies) {
+	policies = p
+}
+
+// PolicyRule allows traffic from the rendered nodes matching Source to
+// those matching Destination, in a topology. Source and Destination
+// are shell patterns, matched against node labels and IDs.
+type PolicyRule struct {
+	Topology    string `json:"topology"`
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Line        int    `json:"line"`
+}
+
+func (r PolicyRule) allows(src, dst detailed.BasicNodeSummary) bool {
+	return matchesNode(r.Source, src) && matchesNode(r.Destination, dst)
+}
+
+func matchesNode(pattern string, n detailed.BasicNodeSummary) bool {
+	for _, s := range []string{n.Label, n.ID} {
+		if ok, _ := path.Match(pattern, s); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// PolicyViolation is an observed edge which no rule allows.
+type PolicyViolation struct {
+	detailed.WeightedEdge
+	SourceLabel string `json:"sourceLabel"`
+	TargetLabel string `json:"targetLabel"`
+}
+
+// Compliance is the result of checking a topology against the policies.
+type Compliance struct {
+	Violations []PolicyViolation `json:"violations"`
+	DeadRules  []PolicyRule      `json:"deadRules"`
+}
+
+// Policies is an allowlist of the traffic expected between rendered
+// nodes. Policies are written one rule per line, as
+// "<topology>: <source> -> <destination>", e.g.
+// "containers: frontend-* -> backend". Blank lines and lines starting
+// with '#' are ignored.
+type Policies struct {
+	rules []PolicyRule
+}
+
+// LoadPolicies reads policies from the file at path.
+func LoadPolicies(path string) (*Policies, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParsePolicies(f)
+}
+
+// ParsePolicies reads policies from r.
+func ParsePolicies(r io.Reader) (*Policies, error) {
+	p := &Policies{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		topology, edge, ok := splitPolicy(text, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: missing topology in %q", line, text)
+		}
+		source, destination, ok := splitPolicy(edge, "->")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected '<source> -> <destination>' in %q", line, text)
+		}
+		for _, pattern := range []string{source, destination} {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("line %d: invalid pattern %q: %v", line, pattern, err)
+			}
+		}
+		p.rules = append(p.rules, PolicyRule{
+			Topology:    topology,
+			Source:      source,
+			Destination: destination,
+			Line:        line,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func splitPolicy(s, sep string) (string, string, bool) {
+	parts := strings.SplitN(s, sep, 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	left, right := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+	return left, right, left != "" && right != ""
+}
+
+// Check compares the edges observed in a topology against the rules
+// for that topology, returning the edges no rule allows and the rules
+// which allow none of the edges.
+func (p *Policies) Check(topologyID string, summaries detailed.NodeSummaries, weights map[detailed.Edge]int) Compliance {
+	result := Compliance{Violations: []PolicyViolation{}, DeadRules: []PolicyRule{}}
+	rules := []PolicyRule{}
+	for _, rule := range p.rules {
+		if rule.Topology == topologyID {
+			rules = append(rules, rule)
+		}
+	}
+
+	used := make([]bool, len(rules))
+	for edge, weight := range weights {
+		src, dst := summaryOf(summaries, edge.Source), summaryOf(summaries, edge.Target)
+		allowed := false
+		for i, rule := range rules {
+			if rule.allows(src, dst) {
+				allowed, used[i] = true, true
+			}
+		}
+		if !allowed {
+			result.Violations = append(result.Violations, PolicyViolation{
+				WeightedEdge: detailed.WeightedEdge{Edge: edge, Weight: weight},
+				SourceLabel:  src.Label,
+				TargetLabel:  dst.Label,
+			})
+		}
+	}
+	for i, rule := range rules {
+		if !used[i] {
+			result.DeadRules = append(result.DeadRules, rule)
+		}
+	}
+	sort.Sort(policyViolationsByID(result.Violations))
+	return result
+}
+
+// summaryOf returns the summary of the node with id, falling back to
+// using the id as the label of nodes which cannot be summarised.
+func summaryOf(summaries detailed.NodeSummaries, id string) detailed.BasicNodeSummary {
+	if s, ok := summaries[id]; ok {
+		return s.BasicNodeSummary
+	}
+	return detailed.BasicNodeSummary{ID: id, Label: id}
+}
+
+type policyViolationsByID []PolicyViolation
+
+func (v policyViolationsByID) Len() int      { return len(v) }
+func (v policyViolationsByID) Swap(i, j int) { v[i], v[j] = v[j], v[i] }
+func (v policyViolationsByID) Less(i, j int) bool {
+	return v[i].Source < v[j].Source || (v[i].Source == v[j].Source && v[i].Target < v[j].Target)
+}
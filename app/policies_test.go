@@ -0,0 +1,51 @@
+package app_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/render/detailed"
+)
+
+func TestPolicies(t *testing.T) {
+	policies, err := app.ParsePolicies(strings.NewReader(`
+# frontends may only talk to the backend
+containers: frontend-* -> backend
+containers: backend -> db
+hosts: * -> *
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	summaries := detailed.NodeSummaries{}
+	for _, id := range []string{"frontend-1", "backend", "db", "cache"} {
+		summaries[id] = detailed.NodeSummary{BasicNodeSummary: detailed.BasicNodeSummary{ID: id, Label: id}}
+	}
+	weights := map[detailed.Edge]int{
+		{Source: "frontend-1", Target: "backend"}: 2,
+		{Source: "frontend-1", Target: "cache"}:   1,
+	}
+
+	have := policies.Check("containers", summaries, weights)
+	want := app.Compliance{
+		Violations: []app.PolicyViolation{{
+			WeightedEdge: detailed.WeightedEdge{Edge: detailed.Edge{Source: "frontend-1", Target: "cache"}, Weight: 1},
+			SourceLabel:  "frontend-1",
+			TargetLabel:  "cache",
+		}},
+		DeadRules: []app.PolicyRule{{Topology: "containers", Source: "backend", Destination: "db", Line: 4}},
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+
+	for _, bad := range []string{"frontend -> backend", "containers: frontend", "containers: [ -> backend"} {
+		if _, err := app.ParsePolicies(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected error parsing %q", bad)
+		}
+	}
+}
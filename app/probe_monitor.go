@@ -0,0 +1,213 @@
+package app
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/report"
+)
+
+// ProbeStatus is the key of the status of the probes of hosts, set to
+// ProbeLost on hosts whose probes stopped sending heartbeats.
+const (
+	ProbeStatus = "host_probe_status"
+	ProbeLost   = "lost"
+)
+
+// How long the last node of a host whose probe is lost is shown, after
+// its reports are out of the window.
+const lostProbeRetention = 10 * time.Minute
+
+var probeStatusTemplates = report.MetadataTemplates{
+	ProbeStatus: {ID: ProbeStatus, Label: "Probe", From: report.FromLatest, Priority: 18},
+}
+
+var probesLost = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "scope",
+	Name:      "probes_lost",
+	Help:      "Number of hosts whose probes stopped sending heartbeats.",
+})
+
+func init() {
+	prometheus.MustRegister(probesLost)
+}
+
+// ProbeMonitor is a Collector telling hosts which are quiet from hosts
+// whose probes are gone, by the heartbeats of the probes: hosts not
+// heard from for the timeout are marked as having lost their probe, and
+// shown so for a while, rather than vanishing with their last reports.
+// Waiters are woken up when probes are lost or come back. Hosts are
+// tracked regardless of the tenant, so it is for single-tenant apps.
+type ProbeMonitor struct {
+	Collector
+	timeout time.Duration
+	waitableCondition
+
+	mtx   sync.Mutex
+	hosts map[string]*monitoredHost // by host node ID
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+type monitoredHost struct {
+	heartbeat time.Time
+	node      report.Node // the last full node of the host
+	lost      bool
+}
+
+// NewProbeMonitor makes a new ProbeMonitor of the reports added to the
+// collector, checking the heartbeats of the probes twice per timeout.
+func NewProbeMonitor(collector Collector, timeout time.Duration) *ProbeMonitor {
+	m := &ProbeMonitor{
+		Collector:         collector,
+		timeout:           timeout,
+		waitableCondition: waitableCondition{waiters: map[chan struct{}]struct{}{}},
+		hosts:             map[string]*monitoredHost{},
+		quit:              make(chan struct{}),
+		done:              make(chan struct{}),
+	}
+	go m.loop()
+	return m
+}
+
+// Stop stops checking the heartbeats.
+func (m *ProbeMonitor) Stop() {
+	close(m.quit)
+	<-m.done
+}
+
+func (m *ProbeMonitor) loop() {
+	defer close(m.done)
+	ticker := time.NewTicker(m.timeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.check(mtime.Now())
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+// Add implements Adder, recording the heartbeats of the report.
+func (m *ProbeMonitor) Add(ctx context.Context, rpt report.Report, buf []byte) error {
+	if err := m.Collector.Add(ctx, rpt, buf); err != nil {
+		return err
+	}
+	changed := false
+	m.mtx.Lock()
+	for id, node := range rpt.Host.Nodes {
+		_, heartbeat, ok := node.Latest.LookupEntry(report.HostHeartbeat)
+		if !ok {
+			continue
+		}
+		h, ok := m.hosts[id]
+		if !ok {
+			h = &monitoredHost{node: node}
+			m.hosts[id] = h
+		}
+		if heartbeat.Before(h.heartbeat) {
+			continue
+		}
+		h.heartbeat = heartbeat
+		// Publications with nothing spied only carry the heartbeat
+		if node.Latest.Size() > 1 {
+			h.node = node
+		}
+		if h.lost {
+			log.Infof("Probe of host %s is back", id)
+			h.lost, changed = false, true
+		}
+	}
+	m.updateGauge()
+	m.mtx.Unlock()
+	if changed {
+		m.Broadcast()
+	}
+	return nil
+}
+
+// check marks the hosts not heard from for the timeout as lost, and
+// forgets those lost for long.
+func (m *ProbeMonitor) check(now time.Time) {
+	changed := false
+	m.mtx.Lock()
+	for id, h := range m.hosts {
+		silence := now.Sub(h.heartbeat)
+		if silence > lostProbeRetention {
+			delete(m.hosts, id)
+			continue
+		}
+		if !h.lost && silence > m.timeout {
+			log.Warnf("Probe of host %s lost, last heard from %v ago", id, silence)
+			h.lost, changed = true, true
+		}
+	}
+	m.updateGauge()
+	m.mtx.Unlock()
+	if changed {
+		m.Broadcast()
+	}
+}
+
+// updateGauge must be called with the lock held.
+func (m *ProbeMonitor) updateGauge() {
+	lost := 0
+	for _, h := range m.hosts {
+		if h.lost {
+			lost++
+		}
+	}
+	probesLost.Set(float64(lost))
+}
+
+// Report implements Reporter, marking the hosts whose probes weren't
+// heard from for the timeout before timestamp, and adding them back if
+// their reports are out of the window.
+func (m *ProbeMonitor) Report(ctx context.Context, timestamp time.Time) (report.Report, error) {
+	rpt, err := m.Collector.Report(ctx, timestamp)
+	if err != nil {
+		return rpt, err
+	}
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	copied := false
+	for id, h := range m.hosts {
+		silence := timestamp.Sub(h.heartbeat)
+		if silence <= m.timeout || silence > lostProbeRetention {
+			continue
+		}
+		// The report may be cached by the collector, so it's copied
+		// before changing it
+		if !copied {
+			rpt.Host = rpt.Host.Copy().WithMetadataTemplates(probeStatusTemplates)
+			copied = true
+		}
+		node, ok := rpt.Host.Nodes[id]
+		if !ok {
+			node = h.node
+		}
+		rpt.Host.Nodes[id] = node.WithLatest(ProbeStatus, h.heartbeat, ProbeLost)
+	}
+	return rpt, nil
+}
+
+// WaitOn implements Reporter, waking waiter up on new reports and when
+// probes are lost or come back.
+func (m *ProbeMonitor) WaitOn(ctx context.Context, waiter chan struct{}) {
+	m.waitableCondition.WaitOn(ctx, waiter)
+	m.Collector.WaitOn(ctx, waiter)
+}
+
+// UnWait implements Reporter.
+func (m *ProbeMonitor) UnWait(ctx context.Context, waiter chan struct{}) {
+	m.waitableCondition.UnWait(ctx, waiter)
+	m.Collector.UnWait(ctx, waiter)
+}
@@ -0,0 +1,85 @@
+package app
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/report"
+)
+
+func heartbeatReport(hostNodeID string, timestamp time.Time, latest map[string]string) report.Report {
+	rpt := report.MakeReport()
+	rpt.Timestamp = timestamp
+	node := report.MakeNodeWith(hostNodeID, latest).WithLatest(report.HostHeartbeat, timestamp, "1")
+	rpt.Host.AddNode(node)
+	return rpt
+}
+
+func TestProbeMonitor(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	var (
+		hostNodeID = report.MakeHostNodeID("foo")
+		monitor    = NewProbeMonitor(NewCollector(5*time.Second), 10*time.Second)
+		waiter     = make(chan struct{}, 1)
+	)
+	defer monitor.Stop()
+	monitor.WaitOn(ctx, waiter)
+	defer monitor.UnWait(ctx, waiter)
+
+	if err := monitor.Add(ctx, heartbeatReport(hostNodeID, now, map[string]string{"host_name": "foo"}), nil); err != nil {
+		t.Fatal(err)
+	}
+	rpt, _ := monitor.Report(ctx, now)
+	if _, ok := rpt.Host.Nodes[hostNodeID].Latest.Lookup(ProbeStatus); ok {
+		t.Error("expected the probe of a host just heard from not to be lost")
+	}
+
+	// The host is out of the window of the collector, but shown as lost
+	later := now.Add(11 * time.Second)
+	mtime.NowForce(later)
+	monitor.check(later)
+	select {
+	case <-waiter:
+	default:
+		t.Error("expected waiters to be woken up when a probe is lost")
+	}
+	rpt, _ = monitor.Report(ctx, later)
+	node, ok := rpt.Host.Nodes[hostNodeID]
+	if !ok {
+		t.Fatal("expected the host of a lost probe to be shown")
+	}
+	if status, _ := node.Latest.Lookup(ProbeStatus); status != ProbeLost {
+		t.Errorf("expected the probe to be lost, got %q", status)
+	}
+	if name, _ := node.Latest.Lookup("host_name"); name != "foo" {
+		t.Errorf("expected the last node of the host, got %v", node)
+	}
+
+	// Heartbeats of empty reports bring the probe back
+	if err := monitor.Add(ctx, heartbeatReport(hostNodeID, later, nil), nil); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-waiter:
+	default:
+		t.Error("expected waiters to be woken up when a probe is back")
+	}
+	rpt, _ = monitor.Report(ctx, later)
+	if _, ok := rpt.Host.Nodes[hostNodeID].Latest.Lookup(ProbeStatus); ok {
+		t.Error("expected the probe to be back")
+	}
+
+	// Hosts lost for long are forgotten
+	muchLater := later.Add(lostProbeRetention + time.Second)
+	mtime.NowForce(muchLater)
+	monitor.check(muchLater)
+	if rpt, _ = monitor.Report(ctx, muchLater); len(rpt.Host.Nodes) != 0 {
+		t.Errorf("expected hosts lost for long to be forgotten, got %v", rpt.Host.Nodes)
+	}
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/probe/identity"
+)
+
+var rejectedSignatures = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "scope",
+	Name:      "report_signatures_rejected_total",
+	Help:      "Total count of reports rejected for their signatures.",
+})
+
+func init() {
+	prometheus.MustRegister(rejectedSignatures)
+}
+
+// verifyProbeSignature checks the report of the request was signed by
+// the probe of the ID it claims, that of its public key, so that reports
+// are attributed to the probes which sent them.
+func verifyProbeSignature(r *http.Request, requireSignatures bool) error {
+	var (
+		probeID   = r.Header.Get(xfer.ScopeProbeIDHeader)
+		publicKey = r.Header.Get(xfer.ScopeProbePublicKeyHeader)
+		signature = r.Header.Get(xfer.ScopeProbeSignatureHeader)
+	)
+	if publicKey == "" && signature == "" {
+		if requireSignatures {
+			rejectedSignatures.Inc()
+			return fmt.Errorf("unsigned report of probe %q", probeID)
+		}
+		return nil
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err := identity.Verify(probeID, publicKey, body, signature); err != nil {
+		rejectedSignatures.Inc()
+		return fmt.Errorf("report of probe %q: %v", probeID, err)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package app
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/probe/identity"
+)
+
+func TestVerifyProbeSignature(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scope-identity")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	id, err := identity.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := []byte("report")
+	request := func(probeID, signature string) error {
+		r := httptest.NewRequest("POST", "/api/report", bytes.NewReader(body))
+		r.Header.Set(xfer.ScopeProbeIDHeader, probeID)
+		r.Header.Set(xfer.ScopeProbePublicKeyHeader, id.PublicKey())
+		r.Header.Set(xfer.ScopeProbeSignatureHeader, signature)
+		err := verifyProbeSignature(r, false)
+		if read, _ := ioutil.ReadAll(r.Body); err == nil && !bytes.Equal(read, body) {
+			t.Errorf("expected the body to be read again, got %q", read)
+		}
+		return err
+	}
+	if err := request(id.ID, id.Sign(body)); err != nil {
+		t.Error(err)
+	}
+	if err := request("other", id.Sign(body)); err == nil {
+		t.Error("expected a report claiming another probe to be rejected")
+	}
+	if err := request(id.ID, id.Sign([]byte("other report"))); err == nil {
+		t.Error("expected a report with the signature of another to be rejected")
+	}
+
+	unsigned := httptest.NewRequest("POST", "/api/report", bytes.NewReader(body))
+	if err := verifyProbeSignature(unsigned, false); err != nil {
+		t.Error(err)
+	}
+	if err := verifyProbeSignature(unsigned, true); err == nil {
+		t.Error("expected an unsigned report to be rejected")
+	}
+}
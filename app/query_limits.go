@@ -0,0 +1,107 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/weaveworks/scope/app/auth"
+)
+
+// QueryCost is the estimated cost of the reports of a query.
+type QueryCost struct {
+	// Reports is how many reports are fetched and decoded
+	Reports int `json:"reports"`
+	// Bytes is the size of those reports, as stored
+	Bytes int64 `json:"bytes"`
+}
+
+// CostEstimator is a Reporter which can estimate the cost of the reports
+// of timestamps before fetching them, such as a collector reading them
+// from a store.
+type CostEstimator interface {
+	EstimateCost(ctx context.Context, timestamps []time.Time) (QueryCost, error)
+}
+
+// QueryLimits bound the cost of the historic queries of the APIs over a
+// time range, so that a query over a month can't take the app down.
+// Zero means no limit.
+type QueryLimits struct {
+	MaxReports int
+	MaxBytes   int64
+}
+
+var queryLimits struct {
+	sync.RWMutex
+	QueryLimits
+	estimator CostEstimator
+}
+
+// EnableQueryLimits has the APIs over time ranges check the estimated
+// cost of their queries against the limits, rejecting those exceeding
+// them unless overridden by admins with override=true.
+func EnableQueryLimits(limits QueryLimits, estimator CostEstimator) {
+	queryLimits.Lock()
+	defer queryLimits.Unlock()
+	queryLimits.QueryLimits = limits
+	queryLimits.estimator = estimator
+}
+
+var queriesRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "scope",
+	Name:      "queries_rejected_total",
+	Help:      "Total count of queries rejected for exceeding the query limits, by limit.",
+}, []string{"limit"})
+
+func init() {
+	prometheus.MustRegister(queriesRejected)
+}
+
+// errQueryTooCostly is the error of queries exceeding the limits.
+type errQueryTooCostly struct {
+	cost  QueryCost
+	limit string
+}
+
+func (e errQueryTooCostly) Error() string {
+	return fmt.Sprintf("query would read %d reports (%d bytes), more than the limit of %s; narrow it, or have an admin set override=true",
+		e.cost.Reports, e.cost.Bytes, e.limit)
+}
+
+// checkQueryCost checks the cost of the reports of the timestamps of a
+// request against the limits, unless overridden, returning the status to
+// respond with if the query should be rejected.
+func checkQueryCost(ctx context.Context, req *http.Request, timestamps []time.Time, override bool) (int, error) {
+	queryLimits.RLock()
+	limits, estimator := queryLimits.QueryLimits, queryLimits.estimator
+	queryLimits.RUnlock()
+	if estimator == nil || (limits.MaxReports <= 0 && limits.MaxBytes <= 0) {
+		return 0, nil
+	}
+	if override {
+		// Anybody may, unless authenticating
+		if identity, ok := auth.FromContext(req.Context()); ok && !identity.Role.Allows(auth.Administer) {
+			return http.StatusForbidden, fmt.Errorf("only admins may override the query limits")
+		}
+		return 0, nil
+	}
+	cost, err := estimator.EstimateCost(ctx, timestamps)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	switch {
+	case limits.MaxReports > 0 && cost.Reports > limits.MaxReports:
+		queriesRejected.WithLabelValues("reports").Inc()
+		return http.StatusBadRequest, errQueryTooCostly{cost, fmt.Sprintf("%d reports", limits.MaxReports)}
+	case limits.MaxBytes > 0 && cost.Bytes > limits.MaxBytes:
+		queriesRejected.WithLabelValues("bytes").Inc()
+		return http.StatusBadRequest, errQueryTooCostly{cost, fmt.Sprintf("%d bytes", limits.MaxBytes)}
+	}
+	log.Debugf("Query of %d timestamps estimated to read %d reports (%d bytes)", len(timestamps), cost.Reports, cost.Bytes)
+	return 0, nil
+}
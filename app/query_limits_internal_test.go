@@ -0,0 +1,57 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/app/auth"
+)
+
+type reportsPerTimestamp int
+
+func (r reportsPerTimestamp) EstimateCost(_ context.Context, timestamps []time.Time) (QueryCost, error) {
+	n := int(r) * len(timestamps)
+	return QueryCost{Reports: n, Bytes: int64(n) * 1000}, nil
+}
+
+func TestQueryLimits(t *testing.T) {
+	EnableQueryLimits(QueryLimits{MaxReports: 100, MaxBytes: 50000}, reportsPerTimestamp(10))
+	defer EnableQueryLimits(QueryLimits{}, nil)
+
+	tokens, err := auth.ParseTokens(strings.NewReader("reader read-only\nadmin admin\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := auth.NewAuthenticator(auth.Config{Tokens: tokens}).Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		timestamps := make([]time.Time, len(r.Form["t"]))
+		if code, err := checkQueryCost(context.Background(), r, timestamps, r.Form.Get("override") == "true"); err != nil {
+			w.WriteHeader(code)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for _, c := range []struct {
+		token, query string
+		want         int
+	}{
+		{"reader", "t=1&t=2", http.StatusOK},
+		// 60 reports, of 60000 bytes
+		{"reader", "t=1&t=2&t=3&t=4&t=5&t=6", http.StatusBadRequest},
+		{"reader", "t=1&t=2&t=3&t=4&t=5&t=6&override=true", http.StatusForbidden},
+		{"admin", "t=1&t=2&t=3&t=4&t=5&t=6&override=true", http.StatusOK},
+	} {
+		req := httptest.NewRequest("GET", "/api/topology/pods/changes?"+c.query, nil)
+		req.Header.Set("Authorization", "Bearer "+c.token)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != c.want {
+			t.Errorf("%s %s: want %d, have %d", c.token, c.query, c.want, w.Code)
+		}
+	}
+}
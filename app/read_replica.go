@@ -0,0 +1,32 @@
+package app
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// probeRoutes are the routes probes publish reports and connect to.
+var probeRoutes = []struct {
+	method  string
+	pattern string
+}{
+	{"POST", "/api/report"},
+	{"GET", "/api/control/ws"},
+	{"GET", "/api/pipe/{pipeID}/probe"},
+}
+
+// ReadReplica wraps the handler of an app serving queries from the
+// shared store of reports only, e.g. to scale dashboards independently
+// of ingestion: the routes of probes are refused, so that probes (and the
+// load balancers in front of them) retry with the apps ingesting.
+func ReadReplica(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, route := range probeRoutes {
+			if _, ok := matchURL(r, route.pattern); ok && r.Method == route.method {
+				respondWith(w, http.StatusServiceUnavailable, fmt.Errorf("this app is a read replica, and doesn't accept probes"))
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,32 @@
+package app_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/weaveworks/scope/app"
+)
+
+func TestReadReplica(t *testing.T) {
+	handler := app.ReadReplica(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	for _, c := range []struct {
+		method, path string
+		want         int
+	}{
+		{"POST", "/api/report", http.StatusServiceUnavailable},
+		{"GET", "/api/control/ws", http.StatusServiceUnavailable},
+		{"GET", "/api/pipe/pipe-1/probe", http.StatusServiceUnavailable},
+		{"GET", "/api/report", http.StatusOK},
+		{"GET", "/api/topology/containers", http.StatusOK},
+		{"GET", "/api/pipe/pipe-1", http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(c.method, c.path, nil))
+		if w.Code != c.want {
+			t.Errorf("%s %s: want %d, have %d", c.method, c.path, c.want, w.Code)
+		}
+	}
+}
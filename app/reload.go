@@ -0,0 +1,199 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+var configReloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "scope",
+	Name:      "config_reloads_total",
+	Help:      "Total count of reloads of the configuration of the app, by result.",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(configReloads)
+}
+
+// Reloadable is configuration of the app which can be reloaded while it
+// runs.
+type Reloadable interface {
+	// Prepare loads and validates the configuration again, returning how
+	// to apply it. Nothing changes until it's applied.
+	Prepare() (func(), error)
+}
+
+// ReloadFunc adapts a function to Reloadable.
+type ReloadFunc func() (func(), error)
+
+// Prepare implements Reloadable.
+func (f ReloadFunc) Prepare() (func(), error) { return f() }
+
+// Reloader reloads the reloadable configuration of the app, on SIGHUP
+// and on POST /api/admin/reload. Configurations are all prepared before
+// any is applied: if any is invalid, none is applied, and the ones in
+// use are kept.
+type Reloader struct {
+	mtx   sync.Mutex
+	items map[string]Reloadable
+}
+
+// NewReloader makes a new Reloader, of no configuration.
+func NewReloader() *Reloader {
+	return &Reloader{items: map[string]Reloadable{}}
+}
+
+// Register has the reloader reload the named configuration.
+func (r *Reloader) Register(name string, item Reloadable) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.items[name] = item
+}
+
+// Reload reloads every configuration, returning the names of those it
+// reloaded.
+func (r *Reloader) Reload() ([]string, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	names := make([]string, 0, len(r.items))
+	for name := range r.items {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var (
+		applies = make([]func(), 0, len(names))
+		errs    []string
+	)
+	for _, name := range names {
+		apply, err := r.items[name].Prepare()
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+			continue
+		}
+		applies = append(applies, apply)
+	}
+	if len(errs) > 0 {
+		configReloads.WithLabelValues("rejected").Inc()
+		return nil, fmt.Errorf("invalid configuration, keeping the one in use: %s", strings.Join(errs, "; "))
+	}
+	for _, apply := range applies {
+		apply()
+	}
+	configReloads.WithLabelValues("reloaded").Inc()
+	return names, nil
+}
+
+// ReloadOnSignal reloads the configuration on every SIGHUP, until the
+// context is done.
+func (r *Reloader) ReloadOnSignal(ctx context.Context) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	go func() {
+		defer signal.Stop(c)
+		for {
+			select {
+			case <-c:
+				if names, err := r.Reload(); err != nil {
+					log.Errorf("Error reloading the configuration: %v", err)
+				} else {
+					log.Infof("Reloaded the configuration of %s", strings.Join(names, ", "))
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// RegisterReloadRoute registers the route reloading the configuration,
+// answering with the names of the configurations reloaded, or a 400 Bad
+// Request if any is invalid.
+func RegisterReloadRoute(router *mux.Router, r *Reloader) {
+	router.Methods("POST").Path("/api/admin/reload").HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		names, err := r.Reload()
+		if err != nil {
+			respondWith(w, http.StatusBadRequest, err)
+			return
+		}
+		respondWith(w, http.StatusOK, map[string][]string{"reloaded": names})
+	})
+}
+
+// Settings are the settings of the app which can be reloaded from a
+// file, as YAML, overriding the flags setting them.
+type Settings struct {
+	TopologyTTLs string `yaml:"topology_ttls"`
+	Ingestion    struct {
+		MaxReportBytes    *int64 `yaml:"max_report_bytes"`
+		MaxNodes          *int   `yaml:"max_nodes"`
+		MaxMetadata       *int   `yaml:"max_metadata"`
+		MaxAdjacency      *int   `yaml:"max_adjacency"`
+		Truncate          *bool  `yaml:"truncate"`
+		RequireSignatures *bool  `yaml:"require_signatures"`
+	} `yaml:"ingestion"`
+}
+
+// LoadSettings reads the settings of the file at path.
+func LoadSettings(path string) (Settings, error) {
+	var s Settings
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+	if err := yaml.UnmarshalStrict(buf, &s); err != nil {
+		return s, fmt.Errorf("%s: %v", path, err)
+	}
+	if _, err := ParseTopologyTTLs(s.TopologyTTLs); err != nil {
+		return s, fmt.Errorf("%s: %v", path, err)
+	}
+	return s, nil
+}
+
+// Limits returns the ingestion limits, overridden by the settings.
+func (s Settings) Limits(limits IngestionLimits) IngestionLimits {
+	i := s.Ingestion
+	if i.MaxReportBytes != nil {
+		limits.MaxReportBytes = *i.MaxReportBytes
+	}
+	if i.MaxNodes != nil {
+		limits.MaxNodes = *i.MaxNodes
+	}
+	if i.MaxMetadata != nil {
+		limits.MaxMetadata = *i.MaxMetadata
+	}
+	if i.MaxAdjacency != nil {
+		limits.MaxAdjacency = *i.MaxAdjacency
+	}
+	if i.Truncate != nil {
+		limits.Truncate = *i.Truncate
+	}
+	if i.RequireSignatures != nil {
+		limits.RequireSignatures = *i.RequireSignatures
+	}
+	return limits
+}
+
+// TTLs returns the topology TTLs, overridden by the settings if they set
+// any.
+func (s Settings) TTLs(ttls TopologyTTLs) TopologyTTLs {
+	if s.TopologyTTLs == "" {
+		return ttls
+	}
+	// Validated on loading
+	parsed, _ := ParseTopologyTTLs(s.TopologyTTLs)
+	return parsed
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReloader(t *testing.T) {
+	var (
+		reloader = NewReloader()
+		applied  []string
+		invalid  = false
+	)
+	reloader.Register("good", ReloadFunc(func() (func(), error) {
+		return func() { applied = append(applied, "good") }, nil
+	}))
+	reloader.Register("bad", ReloadFunc(func() (func(), error) {
+		if invalid {
+			return nil, fmt.Errorf("invalid")
+		}
+		return func() { applied = append(applied, "bad") }, nil
+	}))
+
+	names, err := reloader.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"bad", "good"}; !reflect.DeepEqual(want, names) || !reflect.DeepEqual(want, applied) {
+		t.Errorf("want %v, have %v and %v applied", want, names, applied)
+	}
+
+	applied = nil
+	invalid = true
+	if _, err := reloader.Reload(); err == nil {
+		t.Error("expected an invalid configuration to be rejected")
+	}
+	if len(applied) != 0 {
+		t.Errorf("expected no configuration to be applied along an invalid one, have %v", applied)
+	}
+}
+
+func TestSettings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scope-settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "scope.yaml")
+
+	for _, c := range []struct {
+		content string
+		valid   bool
+	}{
+		{"topology_ttls: endpoint=10s\ningestion:\n  max_nodes: 10\n", true},
+		{"topology_ttls: nowhere=10s\n", false},
+		{"ingestion:\n  max_nodez: 10\n", false},
+	} {
+		if err := ioutil.WriteFile(path, []byte(c.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		settings, err := LoadSettings(path)
+		if (err == nil) != c.valid {
+			t.Errorf("%q: expected valid %t, got %v", c.content, c.valid, err)
+			continue
+		}
+		if !c.valid {
+			continue
+		}
+		limits := settings.Limits(IngestionLimits{MaxNodes: 100, MaxMetadata: 5})
+		if want := (IngestionLimits{MaxNodes: 10, MaxMetadata: 5}); limits != want {
+			t.Errorf("want %v, have %v", want, limits)
+		}
+		if want, have := (TopologyTTLs{"endpoint": 10 * time.Second}), settings.TTLs(nil); !reflect.DeepEqual(want, have) {
+			t.Errorf("want %v, have %v", want, have)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package app
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/weaveworks/common/mtime"
+)
+
+// How many sequence numbers below the highest one of a probe are
+// remembered, to tell late reports from duplicates, and how long the
+// sequence numbers of probes that stopped publishing are remembered.
+const (
+	sequenceWindow = 64
+	sequenceExpiry = 10 * time.Minute
+)
+
+var (
+	reportSequenceGaps = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "scope",
+		Name:      "report_sequence_gaps_total",
+		Help:      "Total count of reports missing from the sequences of the probes when a later report arrived.",
+	})
+	reportDuplicates = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "scope",
+		Name:      "report_duplicates_total",
+		Help:      "Total count of reports rejected as duplicates.",
+	})
+	reportsOutOfOrder = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "scope",
+		Name:      "reports_out_of_order_total",
+		Help:      "Total count of reports arriving after a later report of the same probe.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(reportSequenceGaps)
+	prometheus.MustRegister(reportDuplicates)
+	prometheus.MustRegister(reportsOutOfOrder)
+}
+
+// errDuplicateReport is returned for reports already received, or too
+// old to tell.
+var errDuplicateReport = errors.New("duplicate report")
+
+// reportSequences tracks the sequence numbers of the reports of every
+// probe, by probe ID.
+type reportSequences struct {
+	mtx     sync.Mutex
+	probes  map[string]*probeSequence
+	cleaned time.Time
+}
+
+type probeSequence struct {
+	run     uint64
+	highest uint64
+	// Bit i is set if highest-1-i was received
+	received uint64
+	updated  time.Time
+}
+
+func newReportSequences() *reportSequences {
+	return &reportSequences{probes: map[string]*probeSequence{}}
+}
+
+// check records the sequence number of a report of the run of the probe,
+// returning errDuplicateReport if it was already received. Reports
+// without a sequence number, or a probe ID, are not checked. A report of
+// another run of the probe starts its sequence anew.
+func (s *reportSequences) check(probeID string, run, sequence uint64) error {
+	if probeID == "" || sequence == 0 {
+		return nil
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	now := mtime.Now()
+	s.clean(now)
+
+	p, ok := s.probes[probeID]
+	if !ok || p.run != run {
+		s.probes[probeID] = &probeSequence{run: run, highest: sequence, updated: now}
+		return nil
+	}
+	p.updated = now
+	switch {
+	case run == 0 && sequence == 1 && p.highest > sequenceWindow:
+		// An older probe, not telling its runs apart, restarted under
+		// the same ID, numbering its reports from the start again
+		*p = probeSequence{highest: sequence, updated: now}
+		return nil
+	case sequence > p.highest:
+		shift := sequence - p.highest
+		if shift > 1 {
+			reportSequenceGaps.Add(float64(shift - 1))
+		}
+		if shift > sequenceWindow {
+			p.received = 0
+		} else {
+			p.received = (p.received<<1 | 1) << (shift - 1)
+		}
+		p.highest = sequence
+		return nil
+	case p.highest-sequence > sequenceWindow:
+		reportDuplicates.Inc()
+		return errDuplicateReport
+	case sequence == p.highest:
+		reportDuplicates.Inc()
+		return errDuplicateReport
+	}
+	bit := uint64(1) << (p.highest - sequence - 1)
+	if p.received&bit != 0 {
+		reportDuplicates.Inc()
+		return errDuplicateReport
+	}
+	p.received |= bit
+	reportsOutOfOrder.Inc()
+	return nil
+}
+
+// clean forgets the probes which stopped publishing, at most once per
+// expiry.
+func (s *reportSequences) clean(now time.Time) {
+	if now.Sub(s.cleaned) < sequenceExpiry {
+		return
+	}
+	s.cleaned = now
+	for id, p := range s.probes {
+		if now.Sub(p.updated) > sequenceExpiry {
+			delete(s.probes, id)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"testing"
+)
+
+func TestReportSequences(t *testing.T) {
+	s := newReportSequences()
+	for _, c := range []struct {
+		probe     string
+		sequence  uint64
+		duplicate bool
+	}{
+		{"a", 1, false},
+		{"a", 2, false},
+		{"a", 2, true},
+		{"b", 2, false}, // sequences are per probe
+		{"a", 5, false}, // 3 and 4 are missing
+		{"a", 4, false}, // late
+		{"a", 4, true},
+		{"a", 1, true},
+		{"a", 3, false},
+		{"a", 100, false},
+		{"a", 100 - sequenceWindow, false},
+		{"a", 100 - sequenceWindow, true},
+		{"a", 100 - sequenceWindow - 1, true}, // too old to tell
+		{"a", 0, false},                       // not sequenced
+		{"a", 0, false},
+		{"a", 1, false}, // restarted
+		{"a", 2, false},
+		{"a", 1, true},
+		{"", 100, false},
+	} {
+		err := s.check(c.probe, 0, c.sequence)
+		if duplicate := err == errDuplicateReport; duplicate != c.duplicate {
+			t.Errorf("%s %d: want duplicate %v, have %v", c.probe, c.sequence, c.duplicate, err)
+		}
+	}
+}
+
+func TestReportSequencesRuns(t *testing.T) {
+	s := newReportSequences()
+	for _, c := range []struct {
+		run, sequence uint64
+		duplicate     bool
+	}{
+		{1, 1, false},
+		{1, 2, false},
+		{1, 2, true},
+		{2, 1, false}, // restarted, within the window of the last run
+		{2, 2, false},
+		{2, 2, true},
+		{1, 3, false}, // a late report of the last run starts it anew
+		{3, 500, false},
+		{4, 3, false}, // restarted, and the first reports were lost
+		{4, 4, false},
+	} {
+		err := s.check("a", c.run, c.sequence)
+		if duplicate := err == errDuplicateReport; duplicate != c.duplicate {
+			t.Errorf("%d %d: want duplicate %v, have %v", c.run, c.sequence, c.duplicate, err)
+		}
+	}
+}
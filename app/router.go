@@ -92,30 +92,70 @@ func gzipHandler(h http.HandlerFunc) http.Handler {
 
 // RegisterTopologyRoutes registers the various topology routes with a http mux.
 func RegisterTopologyRoutes(router *mux.Router, r Reporter, capabilities map[string]bool) {
-	get := router.Methods("GET").Subrouter()
-	get.Handle("/api",
+	router.Methods("GET").Path("/api").Handler(
 		gzipHandler(requestContextDecorator(apiHandler(r, capabilities))))
-	get.Handle("/api/topology",
+	router.Methods("GET").Path("/api/openapi.json").Handler(
+		gzipHandler(requestContextDecorator(handleOpenAPI)))
+	router.Methods("GET").Path("/api/topology").Handler(
 		gzipHandler(requestContextDecorator(topologyRegistry.makeTopologyList(r))))
-	get.Handle("/api/topology/{topology}",
+	router.Methods("GET").Path("/api/topology/{topology}").Handler(
 		gzipHandler(requestContextDecorator(topologyRegistry.captureRenderer(r, handleTopology)))).
 		Name("api_topology_topology")
-	get.Handle("/api/topology/{topology}/ws",
+	router.Methods("GET").Path("/api/v2/topology/{topology}").Handler(
+		gzipHandler(requestContextDecorator(topologyRegistry.captureRenderer(r, handleTopologyV2)))).
+		Name("api_v2_topology_topology")
+	router.Methods("GET").Path("/api/topology/{topology}/ws").Handler(
 		requestContextDecorator(captureReporter(r, handleWebsocket))). // NB not gzip!
 		Name("api_topology_topology_ws")
-	get.MatcherFunc(URLMatcher("/api/topology/{topology}/{id}")).Handler(
+	router.Methods("GET").Path("/api/topology/{topology}/changes").Handler(
+		gzipHandler(requestContextDecorator(topologyRegistry.makeTopologyChangesHandler(r)))).
+		Name("api_topology_topology_changes")
+	router.Methods("GET").Path("/api/topology/{topology}/compare").Handler(
+		gzipHandler(requestContextDecorator(topologyRegistry.makeTopologyComparisonHandler(r)))).
+		Name("api_topology_topology_compare")
+	router.Methods("GET").Path("/api/topology/{topology}/compliance").Handler(
+		gzipHandler(requestContextDecorator(topologyRegistry.captureRenderer(r, handleCompliance)))).
+		Name("api_topology_topology_compliance")
+	router.Methods("GET").Path("/api/topology/{topology}/stats").Handler(
+		gzipHandler(requestContextDecorator(topologyRegistry.captureRenderer(r, handleTopologyStats)))).
+		Name("api_topology_topology_stats")
+	router.Methods("GET").MatcherFunc(URLMatcher("/api/topology/{topology}/{id}/blast-radius")).Handler(
+		gzipHandler(requestContextDecorator(topologyRegistry.captureRenderer(r, handleBlastRadius)))).
+		Name("api_topology_topology_id_blast_radius")
+	router.Methods("GET").MatcherFunc(URLMatcher("/api/topology/{topology}/{id}")).Handler(
 		gzipHandler(requestContextDecorator(topologyRegistry.captureRenderer(r, handleNode)))).
 		Name("api_topology_topology_id")
-	get.Handle("/api/report",
+	router.Methods("GET").Path("/api/report").Handler(
 		gzipHandler(requestContextDecorator(makeRawReportHandler(r))))
-	get.Handle("/api/probes",
+	router.Methods("GET").Path("/api/probes").Handler(
 		gzipHandler(requestContextDecorator(makeProbeHandler(r))))
+	router.Methods("GET").Path("/api/exposure").Handler(
+		gzipHandler(requestContextDecorator(makeExposureHandler(r))))
+	router.Methods("GET").Path("/api/network-policies").Handler(
+		gzipHandler(requestContextDecorator(makeNetworkPoliciesHandler(r))))
 }
 
-// RegisterReportPostHandler registers the handler for report submission
+// RegisterReportPostHandler registers the handler for report submission.
+// Reports the app already received from the probe are rejected with a
+// 409 Conflict, and the timestamps of the others are corrected by the
+// offset of the app's clock the probe estimated. Reports exceeding the
+// ingestion limits are rejected, or truncated, and reports not signed by
+// the probe they claim are rejected with a 401 Unauthorized.
 func RegisterReportPostHandler(a Adder, router *mux.Router) {
-	post := router.Methods("POST").Subrouter()
-	post.HandleFunc("/api/report", requestContextDecorator(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	sequences := newReportSequences()
+	router.Methods("POST").Path("/api/report").HandlerFunc(requestContextDecorator(func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		limits := currentIngestionLimits()
+		if max := limits.MaxReportBytes; max > 0 {
+			if r.ContentLength > max {
+				respondWith(w, http.StatusRequestEntityTooLarge, fmt.Errorf("report of %d bytes is larger than the limit of %d", r.ContentLength, max))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, max)
+		}
+		if err := verifyProbeSignature(r, limits.RequireSignatures); err != nil {
+			respondWith(w, http.StatusUnauthorized, err)
+			return
+		}
 		var (
 			rpt    report.Report
 			buf    = &bytes.Buffer{}
@@ -125,6 +165,14 @@ func RegisterReportPostHandler(a Adder, router *mux.Router) {
 		gzipped := strings.Contains(r.Header.Get("Content-Encoding"), "gzip")
 		if !gzipped {
 			reader = io.TeeReader(r.Body, gzip.NewWriter(buf))
+		} else if max := limits.MaxReportBytes; max > 0 {
+			// Bound the report decompressed, too
+			gz, err := gzip.NewReader(reader)
+			if err != nil {
+				respondWith(w, http.StatusBadRequest, err)
+				return
+			}
+			reader, gzipped = &maxBytesReader{r: gz, n: max}, false
 		}
 
 		contentType := r.Header.Get("Content-Type")
@@ -140,13 +188,25 @@ func RegisterReportPostHandler(a Adder, router *mux.Router) {
 			return
 		}
 
-		if err := rpt.ReadBinary(reader, gzipped, handle); err != nil {
+		if err := rpt.ReadBinary(reader, gzipped, handle); err == errReportTooLarge {
+			respondWith(w, http.StatusRequestEntityTooLarge, fmt.Errorf("report is larger than the limit of %d bytes, decompressed", limits.MaxReportBytes))
+			return
+		} else if err != nil {
+			respondWith(w, http.StatusBadRequest, err)
+			return
+		}
+		truncated, err := limits.apply(&rpt)
+		if err != nil {
 			respondWith(w, http.StatusBadRequest, err)
 			return
 		}
+		if err := sequences.check(r.Header.Get(xfer.ScopeProbeIDHeader), rpt.Run, rpt.Sequence); err != nil {
+			respondWith(w, http.StatusConflict, err)
+			return
+		}
 
 		// a.Add(..., buf) assumes buf is gzip'd msgpack
-		if !isMsgpack {
+		if corrected := correctClockSkew(r, &rpt); corrected || truncated || !isMsgpack {
 			buf, _ = rpt.WriteBinary()
 		}
 
@@ -176,6 +236,7 @@ func NewVersion(version, downloadURL string) {
 
 func apiHandler(rep Reporter, capabilities map[string]bool) CtxHandlerFunc {
 	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		received := time.Now()
 		report, err := rep.Report(ctx, time.Now())
 		if err != nil {
 			respondWith(w, http.StatusInternalServerError, err)
@@ -189,7 +250,10 @@ func apiHandler(rep Reporter, capabilities map[string]bool) CtxHandlerFunc {
 			Hostname:     hostname.Get(),
 			Plugins:      report.Plugins,
 			Capabilities: capabilities,
+			Peers:        MeshPeers(report, r.Header.Get(xfer.ScopeProbeIDHeader), meshPeersPerProbe),
 			NewVersion:   newVersion.NewVersionInfo,
+			ReceiveTime:  received,
+			TransmitTime: time.Now(),
 		})
 	}
 }
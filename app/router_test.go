@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -15,6 +16,7 @@ import (
 
 	"github.com/weaveworks/common/test"
 	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/test/fixture"
 )
 
@@ -94,3 +96,46 @@ func TestReportPostHandler(t *testing.T) {
 		return buf.Bytes(), err
 	})
 }
+
+func TestReportPostHandlerMaxReportBytes(t *testing.T) {
+	app.EnableIngestionLimits(app.IngestionLimits{MaxReportBytes: 10000})
+	defer app.EnableIngestionLimits(app.IngestionLimits{})
+
+	router := mux.NewRouter()
+	app.RegisterReportPostHandler(app.NewCollector(time.Minute), router)
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	for _, c := range []struct {
+		id   string
+		want int
+	}{
+		{"small", http.StatusOK},
+		// Compressing to much less than the limit
+		{strings.Repeat("x", 100000), http.StatusRequestEntityTooLarge},
+	} {
+		rpt := report.MakeReport()
+		rpt.Host.AddNode(report.MakeNode(c.id))
+		buf, err := rpt.WriteBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if buf.Len() >= 10000 {
+			t.Fatalf("report of %d bytes compressed", buf.Len())
+		}
+		req, err := http.NewRequest("POST", ts.URL+"/api/report", buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/msgpack")
+		req.Header.Set("Content-Encoding", "gzip")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.want {
+			t.Errorf("node ID of %d bytes: want %d, have %d", len(c.id), c.want, resp.StatusCode)
+		}
+	}
+}
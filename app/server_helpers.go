@@ -1,7 +1,11 @@
 package app
 
 import (
+	"encoding/json"
+	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
 
 	"github.com/ugorji/go/codec"
 
@@ -24,3 +28,26 @@ func respondWith(w http.ResponseWriter, code int, response interface{}) {
 		log.Errorf("Error encoding response: %v", err)
 	}
 }
+
+// saveJSON writes v as JSON to a temporary file and renames it over path,
+// so a crash never leaves a truncated file behind.
+func saveJSON(path string, v interface{}) error {
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(buf); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,134 @@
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/weaveworks/common/mtime"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+)
+
+var (
+	topologyNodes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "scope",
+		Name:      "nodes",
+		Help:      "Number of nodes in the topologies of the current report, by topology.",
+	}, []string{"topology"})
+	viewNodes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "scope",
+		Name:      "view_nodes",
+		Help:      "Number of nodes rendered in the views, with their default options, by view.",
+	}, []string{"view"})
+	viewEdges = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "scope",
+		Name:      "edges",
+		Help:      "Number of edges rendered in the views, with their default options, by view.",
+	}, []string{"view"})
+	internetEgressBytes = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "scope",
+		Name:      "internet_egress_bytes_total",
+		Help:      "Total bytes sent by processes to the internet, as seen in the reports. Only reports of packet captures count bytes.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(topologyNodes, viewNodes, viewEdges, internetEgressBytes)
+}
+
+// TopologyMetrics exports aggregates of the topologies of the current
+// report as metrics, updated every interval, so that alerts can be set on
+// them without custom code.
+type TopologyMetrics struct {
+	reporter Reporter
+	window   time.Duration
+	interval time.Duration
+	quit     chan struct{}
+	done     chan struct{}
+}
+
+// NewTopologyMetrics makes and starts a TopologyMetrics of the reports of
+// reporter over window.
+func NewTopologyMetrics(reporter Reporter, window, interval time.Duration) *TopologyMetrics {
+	m := &TopologyMetrics{
+		reporter: reporter,
+		window:   window,
+		interval: interval,
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go m.loop()
+	return m
+}
+
+// Stop stops updating the metrics.
+func (m *TopologyMetrics) Stop() {
+	close(m.quit)
+	<-m.done
+}
+
+func (m *TopologyMetrics) loop() {
+	defer close(m.done)
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			rpt, err := m.reporter.Report(context.Background(), mtime.Now())
+			if err != nil {
+				log.Errorf("Error exporting topology metrics: %v", err)
+				continue
+			}
+			m.update(rpt)
+		case <-m.quit:
+			return
+		}
+	}
+}
+
+func (m *TopologyMetrics) update(rpt report.Report) {
+	topologyNodes.Reset()
+	rpt.WalkNamedTopologies(func(name string, t *report.Topology) {
+		topologyNodes.WithLabelValues(name).Set(float64(len(t.Nodes)))
+	})
+
+	viewNodes.Reset()
+	viewEdges.Reset()
+	topologyRegistry.walk(func(desc APITopologyDesc) {
+		for _, view := range append([]APITopologyDesc{desc}, desc.SubTopologies...) {
+			if view.plugin {
+				continue
+			}
+			renderer, transformer, err := topologyRegistry.RendererForTopology(view.id, nil, rpt)
+			if err != nil {
+				continue
+			}
+			stats := computeStats(rpt, renderer, transformer)
+			viewNodes.WithLabelValues(view.id).Set(float64(stats.NodeCount))
+			viewEdges.WithLabelValues(view.id).Set(float64(stats.EdgeCount))
+		}
+	})
+
+	internetEgressBytes.Add(m.egressBytes(rpt))
+}
+
+// egressBytes is the share of the bytes sent to the internet over the
+// window of rpt attributable to the last interval, as reports add up the
+// bytes of the whole window rather than since the last update.
+func (m *TopologyMetrics) egressBytes(rpt report.Report) float64 {
+	nodes := render.Render(rpt, render.ProcessRenderer, render.FilterUnconnectedPseudo).Nodes
+	bytes := 0
+	for _, e := range detailed.ServiceEdges(nodes) {
+		if e.Target == render.OutgoingInternetID {
+			bytes += e.Bytes
+		}
+	}
+	if m.interval >= m.window {
+		return float64(bytes)
+	}
+	return float64(bytes) * float64(m.interval) / float64(m.window)
+}
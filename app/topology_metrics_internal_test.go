@@ -0,0 +1,39 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+func gaugeValue(t *testing.T, g interface {
+	Write(*dto.Metric) error
+}) float64 {
+	var m dto.Metric
+	if err := g.Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetGauge().GetValue()
+}
+
+func TestTopologyMetrics(t *testing.T) {
+	m := &TopologyMetrics{window: 15 * time.Second, interval: 5 * time.Second}
+	m.update(fixture.Report)
+
+	if have, want := gaugeValue(t, topologyNodes.WithLabelValues(report.Host)), float64(len(fixture.Report.Host.Nodes)); have != want {
+		t.Errorf("hosts: want %v nodes, have %v", want, have)
+	}
+	if have := gaugeValue(t, viewNodes.WithLabelValues(processesID)); have == 0 {
+		t.Error("expected the processes of the report to be rendered")
+	}
+
+	// Nodes gone from the report are no longer exported
+	m.update(report.MakeReport())
+	if have := gaugeValue(t, viewNodes.WithLabelValues(processesID)); have != 0 {
+		t.Errorf("expected no nodes in an empty report, have %v", have)
+	}
+}
@@ -0,0 +1,243 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/ugorji/go/codec"
+	"github.com/weaveworks/common/mtime"
+
+	"github.com/weaveworks/scope/app/auth"
+)
+
+const (
+	maxViewsPerOrg    = 1000
+	maxViewBodyLength = 64 * 1024
+)
+
+// View is a saved configuration of the topology views: the topology and
+// its options (the active filters), how nodes are grouped and the pinned
+// searches. Views are private to their owners unless shared with their
+// org.
+type View struct {
+	ID             string            `json:"id"`
+	Name           string            `json:"name"`
+	Topology       string            `json:"topology"`
+	Options        map[string]string `json:"options,omitempty"`
+	Grouping       string            `json:"grouping,omitempty"`
+	PinnedSearches []string          `json:"pinnedSearches,omitempty"`
+	Shared         bool              `json:"shared"`
+	Owner          string            `json:"owner"`
+	Created        time.Time         `json:"created"`
+	Updated        time.Time         `json:"updated"`
+}
+
+func (v View) validate() error {
+	if v.Name == "" {
+		return fmt.Errorf("views need a name")
+	}
+	if _, ok := topologyRegistry.get(v.Topology); !ok {
+		return fmt.Errorf("unknown topology %q", v.Topology)
+	}
+	return nil
+}
+
+type viewsByName []View
+
+func (v viewsByName) Len() int      { return len(v) }
+func (v viewsByName) Swap(i, j int) { v[i], v[j] = v[j], v[i] }
+func (v viewsByName) Less(i, j int) bool {
+	if v[i].Name != v[j].Name {
+		return v[i].Name < v[j].Name
+	}
+	return v[i].ID < v[j].ID
+}
+
+// Views stores the saved views of every org, persisting them to a file if
+// given one.
+type Views struct {
+	store *orgStore
+}
+
+// NewViews makes a new Views, loading any views previously saved at path.
+// With no path, views are kept in memory only.
+func NewViews(path string) (*Views, error) {
+	store, err := newOrgStore(path, maxViewsPerOrg, errViewNotFound, errTooManyViews, func(buf []byte) (interface{}, error) {
+		var view View
+		err := json.Unmarshal(buf, &view)
+		return view, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Views{store: store}, nil
+}
+
+func (view View) visibleTo(user auth.Identity) bool {
+	return view.Shared || view.Owner == user.Subject || user.Role == auth.Admin
+}
+
+// List returns the views of an org visible to a user, by name.
+func (v *Views) List(org string, user auth.Identity) []View {
+	result := []View{}
+	v.store.each(org, func(record interface{}) {
+		if view := record.(View); view.visibleTo(user) {
+			result = append(result, view)
+		}
+	})
+	sort.Sort(viewsByName(result))
+	return result
+}
+
+// Get returns a view of an org, if visible to the user.
+func (v *Views) Get(org, id string, user auth.Identity) (View, bool) {
+	record, ok := v.store.get(org, id)
+	if !ok || !record.(View).visibleTo(user) {
+		return View{}, false
+	}
+	return record.(View), true
+}
+
+// Create saves a new view of the user. Views are validated when decoded.
+func (v *Views) Create(org string, view View, user auth.Identity) (View, error) {
+	record, err := v.store.add(org, nil, func(id string) interface{} {
+		now := mtime.Now().UTC()
+		view.ID, view.Owner, view.Created, view.Updated = id, user.Subject, now, now
+		return view
+	})
+	if err != nil {
+		return View{}, err
+	}
+	return record.(View), nil
+}
+
+// Errors of the changes to views
+var (
+	errViewNotFound = fmt.Errorf("no such view")
+	errNotOwner     = fmt.Errorf("only the owner of a view can change it")
+	errTooManyViews = fmt.Errorf("at most %d views can be saved", maxViewsPerOrg)
+)
+
+// Update replaces a view of the user; admins may update any view.
+func (v *Views) Update(org, id string, view View, user auth.Identity) (View, error) {
+	record, err := v.store.update(org, id, func(record interface{}) (interface{}, error) {
+		old := record.(View)
+		if old.Owner != user.Subject && user.Role != auth.Admin {
+			return nil, errNotOwner
+		}
+		view.ID, view.Owner, view.Created = old.ID, old.Owner, old.Created
+		view.Updated = mtime.Now().UTC()
+		return view, nil
+	})
+	if err != nil {
+		return View{}, err
+	}
+	return record.(View), nil
+}
+
+// Delete deletes a view of the user; admins may delete any view.
+func (v *Views) Delete(org, id string, user auth.Identity) error {
+	return v.store.delete(org, id, func(record interface{}) error {
+		if record.(View).Owner != user.Subject && user.Role != auth.Admin {
+			return errNotOwner
+		}
+		return nil
+	})
+}
+
+// RegisterViewRoutes registers the routes to list, create, read, update
+// and delete saved views. Views are kept apart by the org the request
+// comes from, as told by orgID.
+func RegisterViewRoutes(router *mux.Router, views *Views, orgID func(context.Context) (string, error)) {
+	router.Methods("GET").Path("/api/views").
+		HandlerFunc(requestContextDecorator(handleOrgRequest(orgID, func(w http.ResponseWriter, r *http.Request, org string, user auth.Identity) {
+			respondWith(w, http.StatusOK, views.List(org, user))
+		})))
+	router.Methods("POST").Path("/api/views").
+		HandlerFunc(requestContextDecorator(handleOrgRequest(orgID, func(w http.ResponseWriter, r *http.Request, org string, user auth.Identity) {
+			view, err := decodeView(r.Body)
+			if err != nil {
+				respondWith(w, http.StatusBadRequest, err)
+				return
+			}
+			if view, err = views.Create(org, view, user); err != nil {
+				respondWith(w, viewErrorStatus(err), err)
+				return
+			}
+			respondWith(w, http.StatusCreated, view)
+		})))
+	router.Methods("GET").Path("/api/views/{id}").
+		HandlerFunc(requestContextDecorator(handleOrgRequest(orgID, func(w http.ResponseWriter, r *http.Request, org string, user auth.Identity) {
+			view, ok := views.Get(org, mux.Vars(r)["id"], user)
+			if !ok {
+				respondWith(w, http.StatusNotFound, errViewNotFound)
+				return
+			}
+			respondWith(w, http.StatusOK, view)
+		})))
+	router.Methods("PUT").Path("/api/views/{id}").
+		HandlerFunc(requestContextDecorator(handleOrgRequest(orgID, func(w http.ResponseWriter, r *http.Request, org string, user auth.Identity) {
+			view, err := decodeView(r.Body)
+			if err != nil {
+				respondWith(w, http.StatusBadRequest, err)
+				return
+			}
+			if view, err = views.Update(org, mux.Vars(r)["id"], view, user); err != nil {
+				respondWith(w, viewErrorStatus(err), err)
+				return
+			}
+			respondWith(w, http.StatusOK, view)
+		})))
+	router.Methods("DELETE").Path("/api/views/{id}").
+		HandlerFunc(requestContextDecorator(handleOrgRequest(orgID, func(w http.ResponseWriter, r *http.Request, org string, user auth.Identity) {
+			if err := views.Delete(org, mux.Vars(r)["id"], user); err != nil {
+				respondWith(w, viewErrorStatus(err), err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})))
+}
+
+type orgHandler func(w http.ResponseWriter, r *http.Request, org string, user auth.Identity)
+
+// handleOrgRequest identifies the org and the user of a request to the
+// data users keep on the app, such as views. Without authentication,
+// everybody is the same anonymous user.
+func handleOrgRequest(orgID func(context.Context) (string, error), f orgHandler) CtxHandlerFunc {
+	return func(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+		org, err := orgID(ctx)
+		if err != nil {
+			respondWith(w, http.StatusUnauthorized, err)
+			return
+		}
+		user, _ := auth.FromContext(r.Context())
+		f(w, r, org, user)
+	}
+}
+
+// decodeView decodes and validates a view.
+func decodeView(body io.Reader) (View, error) {
+	var view View
+	if err := codec.NewDecoder(io.LimitReader(body, maxViewBodyLength), &codec.JsonHandle{}).Decode(&view); err != nil {
+		return View{}, err
+	}
+	return view, view.validate()
+}
+
+func viewErrorStatus(err error) int {
+	switch err {
+	case errViewNotFound:
+		return http.StatusNotFound
+	case errNotOwner:
+		return http.StatusForbidden
+	case errTooManyViews:
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,138 @@
+package app_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/app/auth"
+)
+
+func viewsServer(t *testing.T, views *app.Views) *httptest.Server {
+	tokens, err := auth.ParseTokens(strings.NewReader("alice-token read-only alice\nbob-token read-only bob\nroot-token admin root\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := mux.NewRouter()
+	app.RegisterViewRoutes(router, views, func(context.Context) (string, error) { return "org1", nil })
+	return httptest.NewServer(auth.NewAuthenticator(auth.Config{Tokens: tokens}).Wrap(router))
+}
+
+func viewRequest(t *testing.T, ts *httptest.Server, token, method, path string, view interface{}) (int, []byte) {
+	var body []byte
+	if view != nil {
+		var err error
+		if body, err = json.Marshal(view); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, ts.URL+path, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, err = ioutil.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.StatusCode, body
+}
+
+func TestViews(t *testing.T) {
+	dir, err := ioutil.TempDir("", "views")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "views.json")
+	views, err := app.NewViews(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := viewsServer(t, views)
+	defer ts.Close()
+
+	// Views are validated
+	if code, _ := viewRequest(t, ts, "alice-token", "POST", "/api/views", app.View{Name: "web", Topology: "nope"}); code != http.StatusBadRequest {
+		t.Errorf("expected an unknown topology to be rejected, got %d", code)
+	}
+
+	code, body := viewRequest(t, ts, "alice-token", "POST", "/api/views", app.View{
+		Name:           "web tier",
+		Topology:       "containers",
+		Options:        map[string]string{"system": "application"},
+		Grouping:       "hostname",
+		PinnedSearches: []string{"label:tier=web"},
+		Shared:         true,
+	})
+	if code != http.StatusCreated {
+		t.Fatalf("expected the view to be created, got %d %s", code, body)
+	}
+	var shared app.View
+	if err := json.Unmarshal(body, &shared); err != nil {
+		t.Fatal(err)
+	}
+	if shared.ID == "" || shared.Owner != "alice" || shared.Created.IsZero() {
+		t.Errorf("unexpected view %+v", shared)
+	}
+	code, body = viewRequest(t, ts, "alice-token", "POST", "/api/views", app.View{Name: "mine", Topology: "hosts"})
+	var private app.View
+	if err := json.Unmarshal(body, &private); err != nil || code != http.StatusCreated {
+		t.Fatalf("expected the view to be created, got %d %s", code, body)
+	}
+
+	// Bob sees the shared view only, and can't change it
+	_, body = viewRequest(t, ts, "bob-token", "GET", "/api/views", nil)
+	var list []app.View
+	if err := json.Unmarshal(body, &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ID != shared.ID || list[0].Grouping != "hostname" {
+		t.Errorf("expected bob to see the shared view only, got %+v", list)
+	}
+	if code, _ := viewRequest(t, ts, "bob-token", "GET", "/api/views/"+private.ID, nil); code != http.StatusNotFound {
+		t.Errorf("expected the private view to be hidden from bob, got %d", code)
+	}
+	if code, _ := viewRequest(t, ts, "bob-token", "DELETE", "/api/views/"+shared.ID, nil); code != http.StatusForbidden {
+		t.Errorf("expected bob not to delete the view of alice, got %d", code)
+	}
+
+	// Alice and admins can
+	shared.Name = "web"
+	if code, body := viewRequest(t, ts, "alice-token", "PUT", "/api/views/"+shared.ID, shared); code != http.StatusOK {
+		t.Errorf("expected alice to update her view, got %d %s", code, body)
+	}
+	if code, _ := viewRequest(t, ts, "root-token", "DELETE", "/api/views/"+private.ID, nil); code != http.StatusNoContent {
+		t.Errorf("expected admins to delete any view, got %d", code)
+	}
+
+	// Views persist
+	reloaded, err := app.NewViews(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts2 := viewsServer(t, reloaded)
+	defer ts2.Close()
+	_, body = viewRequest(t, ts2, "alice-token", "GET", "/api/views", nil)
+	if err := json.Unmarshal(body, &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Name != "web" || list[0].Owner != "alice" {
+		t.Errorf("expected the updated shared view to persist, got %+v", list)
+	}
+}
@@ -0,0 +1,152 @@
+package app
+
+import (
+	"context"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/report"
+)
+
+const warmupExt = ".msgpack.gz"
+
+// Warmup is a Collector keeping the last full report of every probe in a
+// directory, for the app to load them on restart rather than showing an
+// empty window until probes publish again. Reports are written every
+// interval, rather than as they arrive.
+type Warmup struct {
+	Collector
+	dir string
+
+	mtx     sync.Mutex
+	pending map[string][]byte // by host node ID
+
+	quit chan struct{}
+	done chan struct{}
+}
+
+// NewWarmup makes a new Warmup of the reports added to the collector,
+// writing them to dir every interval.
+func NewWarmup(collector Collector, dir string, interval time.Duration) (*Warmup, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	w := &Warmup{
+		Collector: collector,
+		dir:       dir,
+		pending:   map[string][]byte{},
+		quit:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go w.loop(interval)
+	return w, nil
+}
+
+// Stop stops writing reports, after writing those pending.
+func (w *Warmup) Stop() {
+	close(w.quit)
+	<-w.done
+}
+
+func (w *Warmup) loop(interval time.Duration) {
+	defer close(w.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			w.flush()
+		case <-w.quit:
+			w.flush()
+			return
+		}
+	}
+}
+
+// Add implements Adder, keeping the report to write it. Shortcut
+// reports only have what changed, so aren't kept.
+func (w *Warmup) Add(ctx context.Context, rpt report.Report, buf []byte) error {
+	if err := w.Collector.Add(ctx, rpt, buf); err != nil {
+		return err
+	}
+	key := observer(rpt)
+	if rpt.Shortcut || key == "" {
+		return nil
+	}
+	if buf == nil {
+		encoded, err := rpt.WriteBinary()
+		if err != nil {
+			return err
+		}
+		buf = encoded.Bytes()
+	}
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	w.pending[key] = buf
+	return nil
+}
+
+func (w *Warmup) flush() {
+	w.mtx.Lock()
+	pending := w.pending
+	w.pending = map[string][]byte{}
+	w.mtx.Unlock()
+	for key, buf := range pending {
+		path := filepath.Join(w.dir, hex.EncodeToString([]byte(key))+warmupExt)
+		// Written aside and renamed, so that an app stopped half way
+		// doesn't leave a truncated report behind
+		tmp := path + ".tmp"
+		if err := ioutil.WriteFile(tmp, buf, 0600); err != nil {
+			log.Warnf("Error writing the report of %s for warmup: %v", key, err)
+			continue
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			log.Warnf("Error writing the report of %s for warmup: %v", key, err)
+		}
+	}
+}
+
+// LoadWarmup adds the reports kept in dir by a Warmup, written within the
+// window, to the adder, returning how many it added. Unreadable reports
+// are skipped.
+func LoadWarmup(adder Adder, dir string, window time.Duration) (int, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	var (
+		loaded = 0
+		oldest = mtime.Now().Add(-window)
+	)
+	for _, info := range infos {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), warmupExt) || info.ModTime().Before(oldest) {
+			continue
+		}
+		path := filepath.Join(dir, info.Name())
+		buf, err := ioutil.ReadFile(path)
+		if err != nil {
+			log.Warnf("Error reading report %s for warmup: %v", path, err)
+			continue
+		}
+		rpt, err := report.MakeFromBytes(buf)
+		if err != nil {
+			log.Warnf("Error reading report %s for warmup: %v", path, err)
+			continue
+		}
+		if err := adder.Add(context.Background(), *rpt, buf); err != nil {
+			return loaded, err
+		}
+		loaded++
+	}
+	return loaded, nil
+}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/report"
+)
+
+func TestWarmup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scope-warmup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	warmup, err := NewWarmup(NewCollector(time.Minute), dir, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, c := range []struct {
+		buf      string
+		shortcut bool
+	}{
+		{"first", false},
+		{"second", false},
+		{"shortcut", true},
+	} {
+		rpt := report.MakeReport()
+		rpt.Host.AddNode(report.MakeNode(report.MakeHostNodeID("foo")))
+		rpt.Shortcut = c.shortcut
+		if err := warmup.Add(ctx, rpt, []byte(c.buf)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	warmup.Stop()
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+warmupExt))
+	if err != nil || len(paths) != 1 {
+		t.Fatalf("expected a report per probe, have %v, %v", paths, err)
+	}
+	if buf, err := ioutil.ReadFile(paths[0]); err != nil || string(buf) != "second" {
+		t.Errorf("expected the last full report of the probe, have %q, %v", buf, err)
+	}
+
+	// Unreadable and old reports are skipped
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(paths[0], old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "bar"+warmupExt), []byte("not a report"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	collector := NewCollector(time.Minute)
+	if loaded, err := LoadWarmup(collector, dir, time.Minute); err != nil || loaded != 0 {
+		t.Errorf("expected no report to be loaded, have %d, %v", loaded, err)
+	}
+}
@@ -0,0 +1,134 @@
+package app
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/weaveworks/common/mtime"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/render/detailed"
+)
+
+// websocketMaxStaleness is how far behind the topology a WebSocket client
+// may fall, writing to it being slower than rendering, before it is
+// disconnected.
+const websocketMaxStaleness = 30 * time.Second
+
+var (
+	websocketDiffsCoalesced = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "scope",
+		Name:      "websocket_diffs_coalesced_total",
+		Help:      "Total count of diffs of topologies merged into the next, for WebSocket clients falling behind, by topology.",
+	}, []string{"topology"})
+	websocketStaleDisconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "scope",
+		Name:      "websocket_stale_disconnects_total",
+		Help:      "Total count of WebSocket clients disconnected for falling too far behind, by topology.",
+	}, []string{"topology"})
+)
+
+func init() {
+	prometheus.MustRegister(websocketDiffsCoalesced)
+	prometheus.MustRegister(websocketStaleDisconnects)
+}
+
+// diffWriter writes the changes of a topology to a WebSocket client
+// without holding up rendering. The topologies rendered while a diff is
+// being written are coalesced: once the write is done, the client is
+// sent one diff, from the topology it was last sent to the latest, so a
+// client falling behind costs neither unbounded buffering nor its
+// connection, until it falls behind by the max staleness.
+type diffWriter struct {
+	conn         xfer.Websocket
+	topologyID   string
+	maxStaleness time.Duration
+
+	mtx          sync.Mutex
+	pending      detailed.NodeSummaries
+	hasPending   bool
+	pendingSince time.Time // of the oldest change not written
+	err          error
+
+	ready chan struct{}
+	quit  chan struct{}
+	done  chan struct{}
+}
+
+func newDiffWriter(conn xfer.Websocket, topologyID string, maxStaleness time.Duration) *diffWriter {
+	w := &diffWriter{
+		conn:         conn,
+		topologyID:   topologyID,
+		maxStaleness: maxStaleness,
+		ready:        make(chan struct{}, 1),
+		quit:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	go w.loop()
+	return w
+}
+
+// Write queues the topology to be written, replacing that queued, if the
+// client is behind. It fails if writing failed, or if the client is
+// further behind than the max staleness.
+func (w *diffWriter) Write(topo detailed.NodeSummaries) error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.err != nil {
+		return w.err
+	}
+	now := mtime.Now()
+	if w.hasPending {
+		websocketDiffsCoalesced.WithLabelValues(w.topologyID).Inc()
+		if behind := now.Sub(w.pendingSince); behind > w.maxStaleness {
+			websocketStaleDisconnects.WithLabelValues(w.topologyID).Inc()
+			w.err = fmt.Errorf("client of topology %s is %s behind", w.topologyID, behind)
+			return w.err
+		}
+	} else {
+		w.pendingSince = now
+	}
+	w.pending, w.hasPending = topo, true
+	select {
+	case w.ready <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Done is closed once writing failed.
+func (w *diffWriter) Done() <-chan struct{} {
+	return w.done
+}
+
+// Stop stops writing. Closing the connection unblocks a write under way.
+func (w *diffWriter) Stop() {
+	close(w.quit)
+}
+
+func (w *diffWriter) loop() {
+	var sent detailed.NodeSummaries
+	for {
+		select {
+		case <-w.ready:
+		case <-w.quit:
+			return
+		}
+
+		w.mtx.Lock()
+		topo := w.pending
+		w.pending, w.hasPending = nil, false
+		w.mtx.Unlock()
+
+		if err := w.conn.WriteJSON(detailed.TopoDiff(sent, topo)); err != nil {
+			w.mtx.Lock()
+			w.err = err
+			w.mtx.Unlock()
+			close(w.done)
+			return
+		}
+		sent = topo
+	}
+}
@@ -0,0 +1,152 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/weaveworks/common/mtime"
+
+	"github.com/weaveworks/scope/render/detailed"
+)
+
+// slowWebsocket is a client reading each diff only when the test does.
+type slowWebsocket struct {
+	writing chan struct{}
+	diffs   chan detailed.Diff
+	closed  chan struct{}
+}
+
+func newSlowWebsocket() *slowWebsocket {
+	return &slowWebsocket{
+		writing: make(chan struct{}, 1),
+		diffs:   make(chan detailed.Diff),
+		closed:  make(chan struct{}),
+	}
+}
+
+func (s *slowWebsocket) ReadMessage() (int, []byte, error) { return 0, nil, nil }
+func (s *slowWebsocket) WriteMessage(int, []byte) error    { return nil }
+func (s *slowWebsocket) ReadJSON(interface{}) error        { return nil }
+
+func (s *slowWebsocket) WriteJSON(v interface{}) error {
+	s.writing <- struct{}{}
+	select {
+	case s.diffs <- v.(detailed.Diff):
+		return nil
+	case <-s.closed:
+		return fmt.Errorf("closed")
+	}
+}
+
+func (s *slowWebsocket) Close() error {
+	close(s.closed)
+	return nil
+}
+
+func counterValue(t *testing.T, c interface {
+	Write(*dto.Metric) error
+}) float64 {
+	var m dto.Metric
+	if err := c.Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func summaries(ids ...string) detailed.NodeSummaries {
+	result := detailed.NodeSummaries{}
+	for _, id := range ids {
+		result[id] = detailed.NodeSummary{BasicNodeSummary: detailed.BasicNodeSummary{ID: id}}
+	}
+	return result
+}
+
+func diffIDs(diff detailed.Diff) (added, removed []string) {
+	for _, n := range diff.Add {
+		added = append(added, n.ID)
+	}
+	sort.Strings(added)
+	return added, diff.Remove
+}
+
+func TestDiffWriterCoalesces(t *testing.T) {
+	conn := newSlowWebsocket()
+	w := newDiffWriter(conn, "coalesced", time.Minute)
+	defer w.Stop()
+	coalesced := counterValue(t, websocketDiffsCoalesced.WithLabelValues("coalesced"))
+
+	if err := w.Write(summaries("a")); err != nil {
+		t.Fatal(err)
+	}
+	<-conn.writing
+
+	// The client is behind: these are coalesced into one diff
+	for _, topo := range []detailed.NodeSummaries{summaries("a", "b"), summaries("b", "c")} {
+		if err := w.Write(topo); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if have := counterValue(t, websocketDiffsCoalesced.WithLabelValues("coalesced")) - coalesced; have != 1 {
+		t.Errorf("want 1 diff coalesced, have %v", have)
+	}
+
+	if diff := <-conn.diffs; !diff.Reset || len(diff.Add) != 1 {
+		t.Errorf("expected every node to be added first, have %v", diff)
+	}
+	<-conn.writing
+	added, removed := diffIDs(<-conn.diffs)
+	if fmt.Sprint(added) != "[b c]" || fmt.Sprint(removed) != "[a]" {
+		t.Errorf("want b and c added and a removed, have %v and %v", added, removed)
+	}
+}
+
+func TestDiffWriterStaleness(t *testing.T) {
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	conn := newSlowWebsocket()
+	defer conn.Close()
+	w := newDiffWriter(conn, "stale", 10*time.Second)
+	defer w.Stop()
+	disconnects := counterValue(t, websocketStaleDisconnects.WithLabelValues("stale"))
+
+	w.Write(summaries("a"))
+	<-conn.writing
+	if err := w.Write(summaries("b")); err != nil {
+		t.Fatal(err)
+	}
+
+	mtime.NowForce(now.Add(5 * time.Second))
+	if err := w.Write(summaries("c")); err != nil {
+		t.Errorf("expected a client 5s behind to be kept, have %v", err)
+	}
+	mtime.NowForce(now.Add(11 * time.Second))
+	if err := w.Write(summaries("d")); err == nil {
+		t.Error("expected a client 11s behind to be disconnected")
+	}
+	if have := counterValue(t, websocketStaleDisconnects.WithLabelValues("stale")) - disconnects; have != 1 {
+		t.Errorf("want 1 disconnect, have %v", have)
+	}
+}
+
+func TestDiffWriterFails(t *testing.T) {
+	conn := newSlowWebsocket()
+	w := newDiffWriter(conn, "failed", time.Minute)
+	defer w.Stop()
+
+	w.Write(summaries("a"))
+	<-conn.writing
+	conn.Close()
+	select {
+	case <-w.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected writing to fail once closed")
+	}
+	if err := w.Write(summaries("b")); err == nil {
+		t.Error("expected writes to fail once writing failed")
+	}
+}
@@ -1,6 +1,8 @@
 import { Map as makeMap, OrderedMap as makeOrderedMap } from 'immutable';
 
-import { buildUrlQuery, basePath, getApiPath, getWebsocketUrl } from '../web-api-utils';
+import {
+  buildUrlQuery, basePath, getApiPath, getWebsocketUrl, topologyV2Url
+} from '../web-api-utils';
 
 
 describe('WebApiUtils', () => {
@@ -22,6 +24,12 @@ describe('WebApiUtils', () => {
     });
   });
 
+  describe('topologyV2Url', () => {
+    it('should move topologies to v2', () => {
+      expect(topologyV2Url('/api/topology/processes')).toBe('/api/v2/topology/processes');
+    });
+  });
+
   describe('buildUrlQuery', () => {
     let state = makeMap();
 
@@ -188,6 +188,31 @@ function doRequest(opts) {
   return reqwest(config);
 }
 
+/**
+ * The URL of a topology in v2 of the API, which pages its nodes: e.g.
+ * /api/v2/topology/processes for /api/topology/processes.
+ */
+export function topologyV2Url(topologyUrl) {
+  return topologyUrl.replace(/^\/api\//, '/api/v2/');
+}
+
+/**
+ * Fetches all the nodes of a topology, a page at a time, following the
+ * cursors of v2 of the API.
+ */
+function getTopologyNodes(topologyUrl, optionsQuery, nodes = {}, cursor = '') {
+  const cursorQuery = cursor ? `&cursor=${encodeURIComponent(cursor)}` : '';
+  const url = `${getApiPath()}${topologyV2Url(topologyUrl)}?${optionsQuery}${cursorQuery}`;
+  return Promise.resolve(doRequest({ url }))
+    .then((json) => {
+      const pages = Object.assign(nodes, json.nodes);
+      if (json.nextCursor) {
+        return getTopologyNodes(topologyUrl, optionsQuery, pages, json.nextCursor);
+      }
+      return pages;
+    });
+}
+
 /**
  * Does a one-time fetch of all the nodes for a custom list of topologies.
  */
@@ -199,9 +224,9 @@ function getNodesForTopologies(state, dispatch, topologyIds, topologyOptions = m
       (sequence, topologyUrl, topologyId) => sequence
         .then(() => {
           const optionsQuery = buildUrlQuery(topologyOptions.get(topologyId), state);
-          return doRequest({ url: `${getApiPath()}${topologyUrl}?${optionsQuery}` });
+          return getTopologyNodes(topologyUrl, optionsQuery);
         })
-        .then(json => dispatch(receiveNodesForTopology(json.nodes, topologyId))),
+        .then(nodes => dispatch(receiveNodesForTopology(nodes, topologyId))),
       Promise.resolve()
     );
 }
@@ -211,17 +236,15 @@ function getNodesOnce(getState, dispatch) {
   const topologyUrl = getCurrentTopologyUrl(state);
   const topologyOptions = activeTopologyOptionsSelector(state);
   const optionsQuery = buildUrlQuery(topologyOptions, state);
-  const url = `${getApiPath()}${topologyUrl}?${optionsQuery}`;
-  doRequest({
-    url,
-    success: (res) => {
-      dispatch(receiveNodes(res.nodes));
+  getTopologyNodes(topologyUrl, optionsQuery).then(
+    (nodes) => {
+      dispatch(receiveNodes(nodes));
     },
-    error: (req) => {
+    (req) => {
       log(`Error in nodes request: ${req.responseText}`);
-      dispatch(receiveError(url));
+      dispatch(receiveError(topologyV2Url(topologyUrl)));
     }
-  });
+  );
 }
 
 /**
@@ -0,0 +1,125 @@
+// Package geoip reads MaxMind DB files, such as the GeoLite2 country,
+// city and ASN databases, to locate IP addresses.
+package geoip
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	maxminddb "github.com/oschwald/maxminddb-golang"
+)
+
+// DB is a MaxMind DB, as specified by
+// https://maxmind.github.io/MaxMind-DB/
+type DB struct {
+	Type string
+
+	reader *maxminddb.Reader
+}
+
+// Open reads a database file.
+func Open(path string) (*DB, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return &DB{Type: reader.Metadata.DatabaseType, reader: reader}, nil
+}
+
+// FromBytes reads a database from its contents.
+func FromBytes(buf []byte) (*DB, error) {
+	reader, err := maxminddb.FromBytes(buf)
+	if err != nil {
+		return nil, err
+	}
+	return &DB{Type: reader.Metadata.DatabaseType, reader: reader}, nil
+}
+
+// lookup decodes the record of an address into result, telling whether
+// there is one.
+func (db *DB) lookup(ip net.IP, result interface{}) bool {
+	if db == nil {
+		return false
+	}
+	offset, err := db.reader.LookupOffset(ip)
+	if err != nil || offset == maxminddb.NotFound {
+		return false
+	}
+	return db.reader.Decode(offset, result) == nil
+}
+
+type countryRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+type asnRecord struct {
+	Number       uint   `maxminddb:"autonomous_system_number"`
+	Organization string `maxminddb:"autonomous_system_organization"`
+}
+
+// Country returns the ISO code of the country of an address, from a
+// country or city database.
+func (db *DB) Country(ip net.IP) (string, bool) {
+	var record countryRecord
+	if !db.lookup(ip, &record) {
+		return "", false
+	}
+	for _, code := range []string{record.Country.ISOCode, record.RegisteredCountry.ISOCode} {
+		if code != "" {
+			return code, true
+		}
+	}
+	return "", false
+}
+
+// ASN returns the number and the organisation of the autonomous system
+// of an address, from an ASN database.
+func (db *DB) ASN(ip net.IP) (uint, string, bool) {
+	var record asnRecord
+	if !db.lookup(ip, &record) || record.Number == 0 {
+		return 0, "", false
+	}
+	return record.Number, record.Organization, true
+}
+
+// DBs are databases, looked up in turn.
+type DBs []*DB
+
+// Country returns the country of the first database locating it.
+func (dbs DBs) Country(ip net.IP) (string, bool) {
+	for _, db := range dbs {
+		if country, ok := db.Country(ip); ok {
+			return country, true
+		}
+	}
+	return "", false
+}
+
+// ASN returns the autonomous system of the first database with it.
+func (dbs DBs) ASN(ip net.IP) (uint, string, bool) {
+	for _, db := range dbs {
+		if number, org, ok := db.ASN(ip); ok {
+			return number, org, true
+		}
+	}
+	return 0, "", false
+}
+
+// OpenAll opens a comma separated list of database files.
+func OpenAll(paths string) (DBs, error) {
+	var dbs DBs
+	for _, path := range strings.Split(paths, ",") {
+		db, err := Open(strings.TrimSpace(path))
+		if err != nil {
+			return nil, err
+		}
+		dbs = append(dbs, db)
+	}
+	return dbs, nil
+}
@@ -0,0 +1,56 @@
+package geoip_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/weaveworks/scope/common/geoip"
+	"github.com/weaveworks/scope/test/mmdb"
+)
+
+func TestLookup(t *testing.T) {
+	countries, err := geoip.FromBytes(mmdb.Build("GeoLite2-Country", map[string]map[string]interface{}{
+		"8.8.8.0/24":   {"country": map[string]interface{}{"iso_code": "US"}},
+		"81.2.69.0/24": {"registered_country": map[string]interface{}{"iso_code": "GB"}},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	asns, err := geoip.FromBytes(mmdb.Build("GeoLite2-ASN", map[string]map[string]interface{}{
+		"8.8.8.0/24": {"autonomous_system_number": uint(15169), "autonomous_system_organization": "GOOGLE"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if countries.Type != "GeoLite2-Country" {
+		t.Errorf("unexpected type %q", countries.Type)
+	}
+
+	dbs := geoip.DBs{countries, asns}
+	for addr, want := range map[string]string{"8.8.8.8": "US", "81.2.69.160": "GB", "1.1.1.1": ""} {
+		if have, _ := dbs.Country(net.ParseIP(addr)); have != want {
+			t.Errorf("%s: expected country %q, got %q", addr, want, have)
+		}
+	}
+	if number, org, ok := dbs.ASN(net.ParseIP("8.8.4.4")); ok {
+		t.Errorf("expected no AS, got %d %s", number, org)
+	}
+	if number, org, _ := dbs.ASN(net.ParseIP("8.8.8.8")); number != 15169 || org != "GOOGLE" {
+		t.Errorf("expected AS15169 GOOGLE, got %d %s", number, org)
+	}
+	if _, ok := dbs.Country(net.ParseIP("2001:4860::8888")); ok {
+		t.Errorf("expected no country for an IPv6 address outside the database")
+	}
+}
+
+func TestFromBytesErrors(t *testing.T) {
+	for _, input := range [][]byte{
+		[]byte("not a database"),
+		[]byte("\xab\xcd\xefMaxMind.com\xe0"),
+		append([]byte("\xab\xcd\xefMaxMind.com"), 0xe1, 0x4a, 'n', 'o', 'd', 'e', '_', 'c', 'o', 'u', 'n', 't', 0xc1, 0xff),
+	} {
+		if _, err := geoip.FromBytes(input); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Package iprange looks up the records of ranges of IP addresses, such
+// as the autonomous systems or the locations they belong to.
+package iprange
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+)
+
+type ipRange struct {
+	start, end net.IP // 16 bytes
+	record     []string
+}
+
+// Table holds ranges of IP addresses along with their records. It is
+// loaded from tab separated lines of the first and last address of each
+// range followed by the fields of its record, as in the tables of
+// https://iptoasn.com
+type Table struct {
+	ranges []ipRange
+}
+
+// Load reads a Table. Empty lines and lines starting with # are skipped.
+func Load(r io.Reader) (*Table, error) {
+	t := &Table{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, "\t")
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("line %d: expected a range and a record", line)
+		}
+		start, end := net.ParseIP(fields[0]), net.ParseIP(fields[1])
+		if start == nil || end == nil || bytes.Compare(start.To16(), end.To16()) > 0 {
+			return nil, fmt.Errorf("line %d: invalid range %s-%s", line, fields[0], fields[1])
+		}
+		t.ranges = append(t.ranges, ipRange{start: start.To16(), end: end.To16(), record: fields[2:]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.Slice(t.ranges, func(i, j int) bool {
+		return bytes.Compare(t.ranges[i].start, t.ranges[j].start) < 0
+	})
+	return t, nil
+}
+
+// LoadFile reads a Table from a file.
+func LoadFile(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+// Len is the number of ranges of the table.
+func (t *Table) Len() int {
+	return len(t.ranges)
+}
+
+// Lookup returns the record of the range an address belongs to.
+func (t *Table) Lookup(ip net.IP) ([]string, bool) {
+	if t == nil || ip == nil {
+		return nil, false
+	}
+	ip = ip.To16()
+	// The first range starting past the address follows the one it may
+	// belong to
+	i := sort.Search(len(t.ranges), func(i int) bool {
+		return bytes.Compare(t.ranges[i].start, ip) > 0
+	})
+	if i == 0 || bytes.Compare(ip, t.ranges[i-1].end) > 0 {
+		return nil, false
+	}
+	return t.ranges[i-1].record, true
+}
@@ -0,0 +1,57 @@
+package iprange_test
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/weaveworks/scope/common/iprange"
+)
+
+func TestLookup(t *testing.T) {
+	table, err := iprange.Load(strings.NewReader(`# start	end	record
+8.8.8.0	8.8.8.255	15169	US	GOOGLE
+1.0.0.0	1.0.0.255	13335	US	CLOUDFLARENET
+
+2001:4860::	2001:4860:ffff:ffff:ffff:ffff:ffff:ffff	15169	US	GOOGLE
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if table.Len() != 3 {
+		t.Errorf("expected 3 ranges, got %d", table.Len())
+	}
+	for addr, want := range map[string][]string{
+		"8.8.8.8":         {"15169", "US", "GOOGLE"},
+		"1.0.0.0":         {"13335", "US", "CLOUDFLARENET"},
+		"1.0.0.255":       {"13335", "US", "CLOUDFLARENET"},
+		"2001:4860::8888": {"15169", "US", "GOOGLE"},
+		"1.0.1.0":         nil,
+		"0.0.0.1":         nil,
+		"192.168.0.1":     nil,
+		"2001:4861::1":    nil,
+	} {
+		have, ok := table.Lookup(net.ParseIP(addr))
+		if ok != (want != nil) || !reflect.DeepEqual(want, have) {
+			t.Errorf("%s: expected %v, got %v", addr, want, have)
+		}
+	}
+
+	var none *iprange.Table
+	if _, ok := none.Lookup(net.ParseIP("8.8.8.8")); ok {
+		t.Errorf("expected no records in a nil table")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for _, input := range []string{
+		"8.8.8.0\t8.8.8.255\n",
+		"8.8.8.0\tnope\t15169\n",
+		"8.8.8.255\t8.8.8.0\t15169\n",
+	} {
+		if _, err := iprange.Load(strings.NewReader(input)); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+}
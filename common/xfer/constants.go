@@ -1,5 +1,9 @@
 package xfer
 
+import (
+	"time"
+)
+
 const (
 	// AppPort is the default port that the app will use for its HTTP server.
 	// The app publishes the API and user interface, and receives reports from
@@ -7,17 +11,33 @@ const (
 	AppPort = 4040
 
 	// ScopeProbeIDHeader is the header we use to carry the probe's unique ID. The
-	// ID is currently set to the a random string on probe startup.
+	// ID is derived from the probe's key pair, kept across restarts, or a
+	// random string on probe startup if the probe has no key pair.
 	ScopeProbeIDHeader = "X-Scope-Probe-ID"
 
+	// ScopeProbePublicKeyHeader is the header we use to carry the probe's
+	// public key, and ScopeProbeSignatureHeader the signature of the
+	// reports a probe publishes, both base64-encoded.
+	ScopeProbePublicKeyHeader = "X-Scope-Probe-Public-Key"
+	ScopeProbeSignatureHeader = "X-Scope-Probe-Signature"
+
 	// ScopeProbeVersionHeader is the header we use to carry the probe's version.
 	ScopeProbeVersionHeader = "X-Scope-Probe-Version"
+
+	// ScopeProbeClockOffsetHeader is the header we use to carry the
+	// probe's estimate of the offset of the app's clock from its own, as
+	// a duration.
+	ScopeProbeClockOffsetHeader = "X-Scope-Probe-Clock-Offset"
 )
 
 // HistoricReportsCapability indicates whether reports older than the
 // current time (-app.window) can be retrieved.
 const HistoricReportsCapability = "historic_reports"
 
+// ReadReplicaCapability indicates that the app only serves queries, and
+// doesn't accept the reports and connections of probes.
+const ReadReplicaCapability = "read_replica"
+
 // Details are some generic details that can be fetched from /api
 type Details struct {
 	ID           string          `json:"id"`
@@ -25,8 +45,25 @@ type Details struct {
 	Hostname     string          `json:"hostname"`
 	Plugins      PluginSpecs     `json:"plugins,omitempty"`
 	Capabilities map[string]bool `json:"capabilities,omitempty"`
+	// Peers are the hosts the requesting probe should measure the
+	// latency to.
+	Peers []Peer `json:"peers,omitempty"`
 
 	NewVersion *NewVersionInfo `json:"newVersion,omitempty"`
+
+	// ReceiveTime and TransmitTime are when the app received the
+	// request and replied, for the probe to estimate the offset of the
+	// app's clock from its own, as NTP does.
+	ReceiveTime  time.Time `json:"receiveTime"`
+	TransmitTime time.Time `json:"transmitTime"`
+	// ClockOffset is the estimate, set by the probe.
+	ClockOffset time.Duration `json:"-"`
+}
+
+// Peer is a host a probe measures the latency to.
+type Peer struct {
+	HostID  string `json:"hostID"`
+	Address string `json:"address"`
 }
 
 // NewVersionInfo is the struct exposed in /api when there is a new
@@ -3,6 +3,7 @@ package xfer
 import (
 	"io"
 	"net/http"
+	"net/url"
 	"sync"
 	"time"
 
@@ -47,7 +48,28 @@ type pingingWebsocket struct {
 }
 
 var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool { return true },
+	CheckOrigin: SameOrigin,
+}
+
+// SameOrigin tells whether a request was made by a page of the app, or by
+// no page at all: its Origin, or its Referer if it has none, is the host
+// the request was made to, as given to the app or to a proxy in front of
+// it. Browsers give the origin of the page making cross-site requests
+// and WebSocket handshakes, so this keeps other sites from making them
+// with the cookies of the user.
+func SameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		origin = r.Header.Get("Referer")
+	}
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return u.Host == r.Host || u.Host == r.Header.Get("X-Forwarded-Host")
 }
 
 // Upgrade upgrades the HTTP server connection to the WebSocket protocol.
@@ -0,0 +1,35 @@
+package xfer_test
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/weaveworks/scope/common/xfer"
+)
+
+func TestSameOrigin(t *testing.T) {
+	for _, c := range []struct {
+		header, value string
+		forwarded     string
+		want          bool
+	}{
+		{"", "", "", true},
+		{"Origin", "http://scope:4040", "", true},
+		{"Origin", "https://scope.example.com", "scope.example.com", true},
+		{"Origin", "http://evil.com", "", false},
+		{"Origin", "null", "", false},
+		{"Referer", "http://scope:4040/", "", true},
+		{"Referer", "http://evil.com/scope:4040", "", false},
+	} {
+		r := httptest.NewRequest("GET", "http://scope:4040/api/topology/containers/ws", nil)
+		if c.header != "" {
+			r.Header.Set(c.header, c.value)
+		}
+		if c.forwarded != "" {
+			r.Header.Set("X-Forwarded-Host", c.forwarded)
+		}
+		if have := xfer.SameOrigin(r); have != c.want {
+			t.Errorf("%s %s: want %t, have %t", c.header, c.value, c.want, have)
+		}
+	}
+}
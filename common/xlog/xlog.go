@@ -0,0 +1,179 @@
+// Package xlog provides per-component loggers, built on logrus, whose
+// levels can be overridden at runtime.
+package xlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	mtx        sync.Mutex
+	components = map[string]*component{}
+	fields     = log.Fields{}
+)
+
+type component struct {
+	logger   *log.Logger
+	override bool
+}
+
+// Component returns the logger of the named component. Its entries
+// carry a "component" field, and are written with the output and
+// formatter of the standard logger.
+func Component(name string) *log.Entry {
+	mtx.Lock()
+	defer mtx.Unlock()
+	c, ok := components[name]
+	if !ok {
+		c = &component{logger: &log.Logger{
+			Out:       stdWriter{},
+			Formatter: stdFormatter{},
+			Hooks:     log.StandardLogger().Hooks,
+			Level:     log.GetLevel(),
+		}}
+		components[name] = c
+	}
+	return c.logger.WithField("component", name)
+}
+
+// SetField adds a field to the entries of every component, e.g. the
+// ID of the probe.
+func SetField(key string, value interface{}) {
+	mtx.Lock()
+	defer mtx.Unlock()
+	fields[key] = value
+}
+
+// SetDefaultLevel sets the level of the standard logger, and of every
+// component without an override.
+func SetDefaultLevel(level log.Level) {
+	mtx.Lock()
+	defer mtx.Unlock()
+	log.SetLevel(level)
+	for _, c := range components {
+		if !c.override {
+			c.logger.SetLevel(level)
+		}
+	}
+}
+
+// SetLevel overrides the level of the named component.
+func SetLevel(name string, level log.Level) error {
+	mtx.Lock()
+	defer mtx.Unlock()
+	c, ok := components[name]
+	if !ok {
+		return fmt.Errorf("unknown component %q", name)
+	}
+	c.override = true
+	c.logger.SetLevel(level)
+	return nil
+}
+
+// ResetLevel removes any override of the level of the named component.
+func ResetLevel(name string) error {
+	mtx.Lock()
+	defer mtx.Unlock()
+	c, ok := components[name]
+	if !ok {
+		return fmt.Errorf("unknown component %q", name)
+	}
+	c.override = false
+	c.logger.SetLevel(log.GetLevel())
+	return nil
+}
+
+// Levels describes the default level and the level of every component.
+type Levels struct {
+	Default    string            `json:"default"`
+	Components map[string]string `json:"components"`
+	Overrides  []string          `json:"overrides"`
+}
+
+// CurrentLevels returns the current levels.
+func CurrentLevels() Levels {
+	mtx.Lock()
+	defer mtx.Unlock()
+	levels := Levels{
+		Default:    log.GetLevel().String(),
+		Components: map[string]string{},
+		Overrides:  []string{},
+	}
+	for name, c := range components {
+		levels.Components[name] = c.logger.Level.String()
+		if c.override {
+			levels.Overrides = append(levels.Overrides, name)
+		}
+	}
+	sort.Strings(levels.Overrides)
+	return levels
+}
+
+// Handler serves the current levels on GET. On POST, it overrides the
+// level of the component given in the form values, or removes the
+// override when the level is empty or "default".
+func Handler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+	case "POST":
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		name, levelname := r.Form.Get("component"), r.Form.Get("level")
+		var err error
+		if levelname == "" || levelname == "default" {
+			err = ResetLevel(name)
+		} else {
+			var level log.Level
+			if level, err = log.ParseLevel(levelname); err == nil {
+				err = SetLevel(name, level)
+			}
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(CurrentLevels())
+}
+
+// stdWriter writes to the output of the standard logger, which may be
+// changed after components are created.
+type stdWriter struct{}
+
+func (stdWriter) Write(p []byte) (int, error) {
+	return log.StandardLogger().Out.Write(p)
+}
+
+// stdFormatter adds the global fields to entries, and formats them
+// with the formatter of the standard logger.
+type stdFormatter struct{}
+
+func (stdFormatter) Format(entry *log.Entry) ([]byte, error) {
+	mtx.Lock()
+	if len(fields) > 0 {
+		data := make(log.Fields, len(entry.Data)+len(fields))
+		for k, v := range fields {
+			data[k] = v
+		}
+		for k, v := range entry.Data {
+			data[k] = v
+		}
+		copied := *entry
+		copied.Data = data
+		entry = &copied
+	}
+	mtx.Unlock()
+	return log.StandardLogger().Formatter.Format(entry)
+}
@@ -0,0 +1,105 @@
+package xlog_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/weaveworks/scope/common/xlog"
+)
+
+func TestComponentLevels(t *testing.T) {
+	var buf bytes.Buffer
+	out, formatter := log.StandardLogger().Out, log.StandardLogger().Formatter
+	log.SetOutput(&buf)
+	log.SetFormatter(&log.TextFormatter{DisableTimestamp: true})
+	defer func() {
+		log.SetOutput(out)
+		log.SetFormatter(formatter)
+		xlog.SetDefaultLevel(log.InfoLevel)
+	}()
+
+	noisy, quiet := xlog.Component("noisy"), xlog.Component("quiet")
+	xlog.SetField("probe", "probe1")
+	xlog.SetDefaultLevel(log.InfoLevel)
+
+	noisy.Debug("hidden")
+	if buf.Len() != 0 {
+		t.Errorf("unexpected output: %q", buf.String())
+	}
+
+	if err := xlog.SetLevel("noisy", log.DebugLevel); err != nil {
+		t.Fatal(err)
+	}
+	noisy.Debug("shown")
+	quiet.Debug("hidden")
+	line := buf.String()
+	for _, want := range []string{"msg=shown", "component=noisy", "probe=probe1"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("expected %q in %q", want, line)
+		}
+	}
+	if strings.Contains(line, "hidden") {
+		t.Errorf("unexpected output: %q", line)
+	}
+
+	// Overrides survive changes to the default level, until reset
+	xlog.SetDefaultLevel(log.WarnLevel)
+	if have := xlog.CurrentLevels().Components; have["noisy"] != "debug" || have["quiet"] != "warning" {
+		t.Errorf("unexpected levels: %v", have)
+	}
+	if err := xlog.ResetLevel("noisy"); err != nil {
+		t.Fatal(err)
+	}
+	if have := xlog.CurrentLevels().Components["noisy"]; have != "warning" {
+		t.Errorf("expected noisy to be reset, got %q", have)
+	}
+
+	if err := xlog.SetLevel("missing", log.DebugLevel); err == nil {
+		t.Errorf("expected an error for an unknown component")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	xlog.Component("handled")
+	defer xlog.ResetLevel("handled")
+	server := httptest.NewServer(http.HandlerFunc(xlog.Handler))
+	defer server.Close()
+
+	for _, c := range []struct {
+		form url.Values
+		code int
+	}{
+		{url.Values{"component": {"handled"}, "level": {"debug"}}, http.StatusOK},
+		{url.Values{"component": {"handled"}, "level": {"loud"}}, http.StatusBadRequest},
+		{url.Values{"component": {"missing"}, "level": {"debug"}}, http.StatusBadRequest},
+	} {
+		resp, err := http.PostForm(server.URL, c.form)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != c.code {
+			t.Errorf("%v: expected %d, got %d", c.form, c.code, resp.StatusCode)
+		}
+	}
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var levels xlog.Levels
+	if err := json.NewDecoder(resp.Body).Decode(&levels); err != nil {
+		t.Fatal(err)
+	}
+	if levels.Components["handled"] != "debug" || len(levels.Overrides) != 1 || levels.Overrides[0] != "handled" {
+		t.Errorf("unexpected levels: %+v", levels)
+	}
+}
@@ -0,0 +1,32 @@
+// Convert a packet capture into a report of its endpoints and flows.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/weaveworks/scope/probe/endpoint"
+)
+
+func main() {
+	hostID := flag.String("host-id", "pcap", "ID of the host the capture was taken on")
+	flag.Parse()
+
+	if len(flag.Args()) != 2 {
+		log.Fatal("usage: pcap2report [-host-id <id>] capture.pcap dst.(json|msgpack)[.gz]")
+	}
+
+	f, err := os.Open(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	rpt, err := endpoint.ReportFromPcap(f, *hostID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err = rpt.WriteToFile(flag.Arg(1)); err != nil {
+		log.Fatal(err)
+	}
+}
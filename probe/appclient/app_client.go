@@ -1,6 +1,7 @@
 package appclient
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -13,16 +14,44 @@ import (
 
 	"github.com/gorilla/websocket"
 	"github.com/hashicorp/go-cleanhttp"
-	log "github.com/sirupsen/logrus"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/ugorji/go/codec"
+	"github.com/weaveworks/common/mtime"
 
 	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/common/xlog"
 )
 
+// log is the logger of the appclient component
+var log = xlog.Component("appclient")
+
+var (
+	reportsRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "scope",
+		Subsystem: "probe",
+		Name:      "reports_rejected_total",
+		Help:      "Total count of reports apps rejected as duplicates, by app.",
+	}, []string{"app"})
+	reportsBackfilled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "scope",
+		Subsystem: "probe",
+		Name:      "reports_backfilled_total",
+		Help:      "Total count of reports published late to apps, which failed to take them earlier, by app.",
+	}, []string{"app"})
+)
+
+func init() {
+	prometheus.MustRegister(reportsRejected)
+	prometheus.MustRegister(reportsBackfilled)
+}
+
 const (
 	httpClientTimeout = 12 * time.Second // a bit less than default app.window
 	initialBackoff    = 1 * time.Second
 	maxBackoff        = 60 * time.Second
+
+	// Of the spooled reports backfilled after each live report
+	maxBackfillsPerPublish = 5
 )
 
 // AppClient is a client to an app, dealing with report publishing, controls and pipes.
@@ -34,6 +63,7 @@ type AppClient interface {
 	Publish(io.Reader, bool) error
 	Target() url.URL
 	ReTarget(url.URL)
+	SetClockOffset(time.Duration)
 	Stop()
 }
 
@@ -48,6 +78,8 @@ type appClient struct {
 	appID    string
 	hostname string
 	target   url.URL
+	// Of the app's clock from the probe's
+	clockOffset time.Duration
 
 	// Track all the background goroutines, ensure they all stop
 	backgroundWait sync.WaitGroup
@@ -58,6 +90,8 @@ type appClient struct {
 	// For publish
 	publishLoop sync.Once
 	readers     chan io.Reader
+	// Of the reports which failed to be published, or were dropped
+	spool reportSpool
 
 	// For controls
 	control xfer.ControlHandler
@@ -164,6 +198,14 @@ func (c *appClient) ReTarget(target url.URL) {
 	c.target = target
 }
 
+// SetClockOffset sets the offset of the app's clock from the probe's,
+// for the app to correct the timestamps of the reports.
+func (c *appClient) SetClockOffset(offset time.Duration) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.clockOffset = offset
+}
+
 // Stop stops the appClient.
 func (c *appClient) Stop() {
 	c.mtx.Lock()
@@ -180,13 +222,15 @@ func (c *appClient) Stop() {
 	return
 }
 
-// Details fetches the details (version, id) of the app.
+// Details fetches the details (version, id) of the app, estimating the
+// offset of the app's clock from the probe's.
 func (c *appClient) Details() (xfer.Details, error) {
 	result := xfer.Details{}
 	req, err := c.ProbeConfig.authorizedRequest("GET", c.url("/api"), nil)
 	if err != nil {
 		return result, err
 	}
+	sent := time.Now()
 	resp, err := c.client.Do(req)
 	if err != nil {
 		return result, err
@@ -195,10 +239,21 @@ func (c *appClient) Details() (xfer.Details, error) {
 	if err := codec.NewDecoder(resp.Body, &codec.JsonHandle{}).Decode(&result); err != nil {
 		return result, err
 	}
+	result.ClockOffset = clockOffset(sent, result.ReceiveTime, result.TransmitTime, time.Now())
 	c.appID = result.ID
 	return result, nil
 }
 
+// clockOffset estimates the offset of the app's clock from the probe's
+// as NTP does, assuming the request and the reply took as long. It is 0
+// for apps which don't tell the times.
+func clockOffset(sent, received, transmitted, replied time.Time) time.Duration {
+	if received.IsZero() || transmitted.IsZero() {
+		return 0
+	}
+	return (received.Sub(sent) + transmitted.Sub(replied)) / 2
+}
+
 func (c *appClient) doWithBackoff(msg string, f func() (bool, error)) {
 	if !c.retainGoroutine() {
 		return
@@ -282,12 +337,28 @@ func (c *appClient) ControlConnection() {
 
 func (c *appClient) publish(r io.Reader) error {
 	url := c.url("/api/report")
+	var signature string
+	if c.ProbeConfig.Signer != nil {
+		body, err := ioutil.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		r, signature = bytes.NewReader(body), c.ProbeConfig.Signer.Sign(body)
+	}
 	req, err := c.ProbeConfig.authorizedRequest("POST", url, r)
 	if err != nil {
 		return err
 	}
+	if signature != "" {
+		req.Header.Set(xfer.ScopeProbeSignatureHeader, signature)
+	}
 	req.Header.Set("Content-Encoding", "gzip")
 	req.Header.Set("Content-Type", "application/msgpack")
+	c.mtx.Lock()
+	if c.clockOffset != 0 {
+		req.Header.Set(xfer.ScopeProbeClockOffsetHeader, c.clockOffset.String())
+	}
+	c.mtx.Unlock()
 	// req.Header.Set("Content-Type", "application/binary") // TODO: we should use http.DetectContentType(..) on the gob'ed
 
 	// Make sure this request is cancelled when we stop the client
@@ -299,13 +370,37 @@ func (c *appClient) publish(r io.Reader) error {
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusConflict:
+		// The app has the report already, or takes it for a duplicate:
+		// not worth retrying, but a sign of a probe ID shared by probes
+		text, _ := ioutil.ReadAll(resp.Body)
+		reportsRejected.WithLabelValues(c.hostname).Inc()
+		log.Warnf("Report rejected by %s as a duplicate: %s", c.hostname, bytes.TrimSpace(text))
+	default:
 		text, _ := ioutil.ReadAll(resp.Body)
-		return fmt.Errorf(resp.Status + ": " + string(text))
+		err := fmt.Errorf("%s: %s", resp.Status, text)
+		if resp.StatusCode >= 400 && resp.StatusCode < 500 {
+			return rejectedError{err}
+		}
+		return err
 	}
 	return nil
 }
 
+// rejectedError is the error of a report the app refused, e.g. as too
+// large or over a limit. Unlike those the app failed to take, the report
+// would be refused again, so isn't worth backfilling.
+type rejectedError struct {
+	error
+}
+
+func isRejected(err error) bool {
+	_, ok := err.(rejectedError)
+	return ok
+}
+
 func (c *appClient) startPublishing() {
 	go func() {
 		log.Infof("Publish loop for %s starting", c.hostname)
@@ -315,11 +410,55 @@ func (c *appClient) startPublishing() {
 			if r == nil {
 				return true, nil
 			}
-			return false, c.publish(r)
+			body, err := ioutil.ReadAll(r)
+			if err != nil {
+				return false, err
+			}
+			if err := c.publish(bytes.NewReader(body)); err != nil {
+				if !isRejected(err) {
+					c.mtx.Lock()
+					c.spool.add(spooledReport{body, mtime.Now()})
+					c.mtx.Unlock()
+				}
+				return false, err
+			}
+			c.backfill()
+			return false, nil
 		})
 	}()
 }
 
+// backfill publishes up to maxBackfillsPerPublish of the reports spooled
+// while the app couldn't take them, oldest first, until one fails: the
+// spool is drained a few reports at a time, between live reports, and
+// its failures are only retried with the next live report.
+func (c *appClient) backfill() {
+	for i := 0; i < maxBackfillsPerPublish; i++ {
+		c.mtx.Lock()
+		spooled, ok := c.spool.take(mtime.Now())
+		c.mtx.Unlock()
+		if !ok {
+			return
+		}
+		r, err := backfilled(spooled.body)
+		if err != nil {
+			log.Warnf("Dropping spooled report to %s: %v", c.hostname, err)
+			continue
+		}
+		if err := c.publish(r); isRejected(err) {
+			log.Warnf("Dropping spooled report rejected by %s: %v", c.hostname, err)
+			continue
+		} else if err != nil {
+			c.mtx.Lock()
+			c.spool.putBack(spooled)
+			c.mtx.Unlock()
+			log.Warnf("Error backfilling reports to %s: %v", c.hostname, err)
+			return
+		}
+		reportsBackfilled.WithLabelValues(c.hostname).Inc()
+	}
+}
+
 // Publish implements Publisher
 func (c *appClient) Publish(r io.Reader, shortcut bool) error {
 	// Lazily start the background publishing loop.
@@ -332,11 +471,14 @@ func (c *appClient) Publish(r io.Reader, shortcut bool) error {
 		if shortcut {
 			return nil
 		}
-		// drop an old report to make way for new one
+		// drop an old report to make way for new one, to be backfilled
 		c.mtx.Lock()
 		defer c.mtx.Unlock()
 		select {
-		case <-c.readers:
+		case old := <-c.readers:
+			if body, err := ioutil.ReadAll(old); err == nil {
+				c.spool.add(spooledReport{body, mtime.Now()})
+			}
 		default:
 		}
 		c.readers <- r
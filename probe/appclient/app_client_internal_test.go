@@ -3,6 +3,7 @@ package appclient
 import (
 	"compress/gzip"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -119,6 +120,178 @@ func TestAppClientPublish(t *testing.T) {
 	}
 }
 
+func TestAppClientBackfill(t *testing.T) {
+	var (
+		failed   = make(chan struct{})
+		received = make(chan report.Report, 10)
+		requests = 0
+	)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The app fails to take the first report
+		if requests++; requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			close(failed)
+			return
+		}
+		rpt, err := report.MakeFromBinary(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		received <- *rpt
+	})
+	s := httptest.NewServer(handler)
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewAppClient(ProbeConfig{}, u.Host, *u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+
+	publish := func(sequence uint64, timestamp time.Time) {
+		rpt := report.MakeReport()
+		rpt.Sequence, rpt.Timestamp = sequence, timestamp
+		buf, _ := rpt.WriteBinary()
+		if err := p.Publish(buf, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	captured := time.Unix(1500000000, 0)
+	publish(1, captured)
+	<-failed
+	publish(2, captured.Add(time.Second))
+
+	// Once the app takes reports again, it is sent the one it failed to
+	// take as a backfill
+	for _, want := range []struct {
+		backfill  bool
+		sequence  uint64
+		timestamp time.Time
+	}{
+		{false, 2, captured.Add(time.Second)},
+		{true, 0, captured},
+	} {
+		select {
+		case have := <-received:
+			if have.Backfill != want.backfill || have.Sequence != want.sequence || !have.Timestamp.Equal(want.timestamp) {
+				t.Errorf("want backfill %t, sequence %d at %v, have %t, %d at %v", want.backfill, want.sequence, want.timestamp, have.Backfill, have.Sequence, have.Timestamp)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout")
+		}
+	}
+}
+
+func TestAppClientDropsRejectedReports(t *testing.T) {
+	var (
+		rejected = make(chan struct{})
+		received = make(chan report.Report, 10)
+		requests = 0
+	)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The app refuses the first report, as too large
+		if requests++; requests == 1 {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			close(rejected)
+			return
+		}
+		rpt, err := report.MakeFromBinary(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		received <- *rpt
+	})
+	s := httptest.NewServer(handler)
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewAppClient(ProbeConfig{}, u.Host, *u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+
+	for sequence := uint64(1); sequence <= 2; sequence++ {
+		rpt := report.MakeReport()
+		rpt.Sequence = sequence
+		buf, _ := rpt.WriteBinary()
+		if err := p.Publish(buf, false); err != nil {
+			t.Fatal(err)
+		}
+		if sequence == 1 {
+			<-rejected
+		}
+	}
+
+	// The report refused is not backfilled
+	select {
+	case have := <-received:
+		if have.Backfill || have.Sequence != 2 {
+			t.Errorf("want report 2, have backfill %t, sequence %d", have.Backfill, have.Sequence)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+	select {
+	case have := <-received:
+		t.Errorf("want no backfill, have backfill %t, sequence %d", have.Backfill, have.Sequence)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestAppClientBackfillIsBounded(t *testing.T) {
+	var (
+		failing  = false
+		received = 0
+	)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		received++
+	}))
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewAppClient(ProbeConfig{}, u.Host, *u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Stop()
+	c := p.(*appClient)
+	for i := 0; i < 2*maxBackfillsPerPublish+1; i++ {
+		buf, _ := report.MakeReport().WriteBinary()
+		body, _ := ioutil.ReadAll(buf)
+		c.spool.add(spooledReport{body, time.Now()})
+	}
+
+	// Reports are backfilled a few at a time
+	c.backfill()
+	if received != maxBackfillsPerPublish || len(c.spool.reports) != maxBackfillsPerPublish+1 {
+		t.Errorf("want %d reports backfilled, have %d, with %d left spooled", maxBackfillsPerPublish, received, len(c.spool.reports))
+	}
+
+	// Those the app fails to take are kept for the next time
+	failing = true
+	c.backfill()
+	if len(c.spool.reports) != maxBackfillsPerPublish+1 {
+		t.Errorf("want the report which failed to be kept, have %d spooled", len(c.spool.reports))
+	}
+}
+
 func TestAppClientDetails(t *testing.T) {
 	var (
 		id      = "foobarbaz"
@@ -166,6 +339,22 @@ func TestAppClientDetails(t *testing.T) {
 	}
 }
 
+func TestClockOffset(t *testing.T) {
+	var (
+		sent = time.Now()
+		// The app's clock is 10s ahead, and each way takes 1s
+		received    = sent.Add(11 * time.Second)
+		transmitted = received.Add(time.Second)
+		replied     = sent.Add(3 * time.Second)
+	)
+	if want, have := 10*time.Second, clockOffset(sent, received, transmitted, replied); want != have {
+		t.Errorf("want %v, have %v", want, have)
+	}
+	if have := clockOffset(sent, time.Time{}, time.Time{}, replied); have != 0 {
+		t.Errorf("want no offset from old apps, have %v", have)
+	}
+}
+
 // Make sure Stopping a client works even if the connection or the remote app
 // gets stuck for whatever reason.
 // See https://github.com/weaveworks/scope/issues/1576
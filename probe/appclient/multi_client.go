@@ -5,11 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
+	"sort"
 	"strings"
 	"sync"
 
-	log "github.com/sirupsen/logrus"
-
 	"github.com/weaveworks/scope/common/xfer"
 	"github.com/weaveworks/scope/report"
 )
@@ -26,6 +25,7 @@ type multiClient struct {
 	sema       semaphore
 	clients    map[string]AppClient     // holds map from app id -> client
 	ids        map[string]report.IDList // holds map from hostname -> app ids
+	peers      map[string][]xfer.Peer   // holds map from app id -> peers
 	quit       chan struct{}
 	noControls bool
 }
@@ -43,6 +43,7 @@ type MultiAppClient interface {
 	PipeClose(appID, pipeID string) error
 	Stop()
 	Publish(r report.Report) error
+	Peers() []xfer.Peer
 }
 
 // NewMultiAppClient creates a new MultiAppClient.
@@ -53,6 +54,7 @@ func NewMultiAppClient(clientFactory ClientFactory, noControls bool) MultiAppCli
 		sema:       newSemaphore(maxConcurrentGET),
 		clients:    map[string]AppClient{},
 		ids:        map[string]report.IDList{},
+		peers:      map[string][]xfer.Peer{},
 		quit:       make(chan struct{}),
 		noControls: noControls,
 	}
@@ -94,14 +96,18 @@ func (c *multiClient) Set(hostname string, urls []url.URL) {
 	hostIDs := report.MakeIDList()
 	for tuple := range clients {
 		hostIDs = hostIDs.Add(tuple.ID)
-		if client, ok := c.clients[tuple.ID]; ok {
+		c.peers[tuple.ID] = tuple.Peers
+		client, ok := c.clients[tuple.ID]
+		if ok {
 			client.ReTarget(tuple.AppClient.Target())
 		} else {
-			c.clients[tuple.ID] = tuple.AppClient
+			client = tuple.AppClient
+			c.clients[tuple.ID] = client
 			if !c.noControls {
-				tuple.AppClient.ControlConnection()
+				client.ControlConnection()
 			}
 		}
+		client.SetClockOffset(tuple.ClockOffset)
 	}
 	c.ids[hostname] = hostIDs
 
@@ -114,6 +120,7 @@ func (c *multiClient) Set(hostname string, urls []url.URL) {
 		if !allReferencedIDs.Contains(id) {
 			client.Stop()
 			delete(c.clients, id)
+			delete(c.peers, id)
 		}
 	}
 }
@@ -177,6 +184,25 @@ func (c *multiClient) Publish(r report.Report) error {
 	return nil
 }
 
+// Peers returns the peers every app has asked this probe to measure the
+// latency to, as of the last time the app details were fetched.
+func (c *multiClient) Peers() []xfer.Peer {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	seen := map[string]struct{}{}
+	result := []xfer.Peer{}
+	for _, peers := range c.peers {
+		for _, peer := range peers {
+			if _, ok := seen[peer.HostID]; !ok {
+				seen[peer.HostID] = struct{}{}
+				result = append(result, peer)
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].HostID < result[j].HostID })
+	return result
+}
+
 type semaphore chan struct{}
 
 func newSemaphore(n int) semaphore {
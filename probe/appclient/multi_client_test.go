@@ -5,6 +5,7 @@ import (
 	"net/url"
 	"runtime"
 	"testing"
+	"time"
 
 	"github.com/weaveworks/scope/common/xfer"
 	"github.com/weaveworks/scope/probe/appclient"
@@ -16,10 +17,11 @@ type mockClient struct {
 	count   int
 	stopped int
 	publish int
+	peers   []xfer.Peer
 }
 
 func (c *mockClient) Details() (xfer.Details, error) {
-	return xfer.Details{ID: c.id}, nil
+	return xfer.Details{ID: c.id, Peers: c.peers}, nil
 }
 
 func (c *mockClient) ControlConnection() {
@@ -33,6 +35,9 @@ func (c *mockClient) Target() url.URL {
 func (c *mockClient) ReTarget(_ url.URL) {
 }
 
+func (c *mockClient) SetClockOffset(time.Duration) {
+}
+
 func (c *mockClient) Stop() {
 	c.stopped++
 }
@@ -115,3 +120,28 @@ func TestMultiClientPublish(t *testing.T) {
 		}
 	}
 }
+
+func TestMultiClientPeers(t *testing.T) {
+	var (
+		hostA   = xfer.Peer{HostID: "hostA", Address: "10.0.0.1"}
+		hostB   = xfer.Peer{HostID: "hostB", Address: "10.0.0.2"}
+		clients = map[string]*mockClient{
+			"app1": {id: "1", peers: []xfer.Peer{hostB, hostA}},
+			"app2": {id: "2", peers: []xfer.Peer{hostA}},
+		}
+	)
+	mp := appclient.NewMultiAppClient(func(hostname string, url url.URL) (appclient.AppClient, error) {
+		return clients[url.Host], nil
+	}, true)
+	defer mp.Stop()
+
+	mp.Set("app", []url.URL{{Host: "app1"}, {Host: "app2"}})
+	if have := mp.Peers(); len(have) != 2 || have[0] != hostA || have[1] != hostB {
+		t.Errorf("expected the peers of both apps, have %v", have)
+	}
+
+	mp.Set("app", []url.URL{{Host: "app2"}})
+	if have := mp.Peers(); len(have) != 1 || have[0] != hostA {
+		t.Errorf("expected the peers of the remaining app, have %v", have)
+	}
+}
@@ -35,12 +35,24 @@ type ProbeConfig struct {
 	ProbeVersion string
 	ProbeID      string
 	Insecure     bool
+	// Signer, if any, signs the reports published, and its public key is
+	// sent with every request
+	Signer Signer
+}
+
+// Signer is the key pair of a probe.
+type Signer interface {
+	PublicKey() string
+	Sign(data []byte) string
 }
 
 func (pc ProbeConfig) authorizeHeaders(headers http.Header) {
 	headers.Set("Authorization", fmt.Sprintf("Scope-Probe token=%s", pc.Token))
 	headers.Set(xfer.ScopeProbeIDHeader, pc.ProbeID)
 	headers.Set(xfer.ScopeProbeVersionHeader, pc.ProbeVersion)
+	if pc.Signer != nil {
+		headers.Set(xfer.ScopeProbePublicKeyHeader, pc.Signer.PublicKey())
+	}
 }
 
 func (pc ProbeConfig) authorizedRequest(method string, urlStr string, body io.Reader) (*http.Request, error) {
@@ -0,0 +1,75 @@
+package appclient
+
+import (
+	"bytes"
+	"io"
+	"time"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// Bounds of the reports kept for an app while it can't take them, to be
+// backfilled once it can again: of their gzipped size, and of their age.
+const (
+	maxSpoolBytes = 16 * 1024 * 1024
+	maxSpoolAge   = 10 * time.Minute
+)
+
+// reportSpool keeps the reports an app failed to take, oldest first, up
+// to the bounds.
+type reportSpool struct {
+	reports []spooledReport
+	bytes   int
+}
+
+type spooledReport struct {
+	body    []byte // gzipped msgpack, as published
+	spooled time.Time
+}
+
+func (s *reportSpool) add(r spooledReport) {
+	if len(r.body) > maxSpoolBytes {
+		return
+	}
+	s.reports = append(s.reports, r)
+	s.bytes += len(r.body)
+	for s.bytes > maxSpoolBytes {
+		s.drop()
+	}
+}
+
+// take removes the oldest report spooled since the max age, dropping
+// older ones.
+func (s *reportSpool) take(now time.Time) (spooledReport, bool) {
+	for len(s.reports) > 0 {
+		r := s.reports[0]
+		s.drop()
+		if now.Sub(r.spooled) <= maxSpoolAge {
+			return r, true
+		}
+	}
+	return spooledReport{}, false
+}
+
+// putBack returns a report taken to the front of the spool.
+func (s *reportSpool) putBack(r spooledReport) {
+	s.reports = append([]spooledReport{r}, s.reports...)
+	s.bytes += len(r.body)
+}
+
+func (s *reportSpool) drop() {
+	s.bytes -= len(s.reports[0].body)
+	s.reports = s.reports[1:]
+}
+
+// backfilled marks a report published earlier as a backfill. It is
+// stamped with when the probe published it first, and it is no longer
+// numbered in the sequence of the probe's reports.
+func backfilled(body []byte) (io.Reader, error) {
+	rpt, err := report.MakeFromBinary(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	rpt.Backfill, rpt.Sequence = true, 0
+	return rpt.WriteBinary()
+}
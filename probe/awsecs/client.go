@@ -11,7 +11,6 @@ import (
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/ecs"
 	"github.com/bluele/gcache"
-	log "github.com/sirupsen/logrus"
 )
 
 const servicePrefix = "ecs-svc" // Task StartedBy field begins with this if it was started by a service
@@ -1,16 +1,20 @@
 package awsecs
 
 import (
+	"context"
 	"fmt"
 	"time"
 
-	log "github.com/sirupsen/logrus"
 	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/common/xlog"
 	"github.com/weaveworks/scope/probe/controls"
 	"github.com/weaveworks/scope/probe/docker"
 	"github.com/weaveworks/scope/report"
 )
 
+// log is the logger of the awsecs component
+var log = xlog.Component("awsecs").WithField("topology", report.ECSTask)
+
 // TaskFamily is the key that stores the task family of an ECS Task
 const (
 	Cluster             = report.ECSCluster
@@ -78,7 +82,7 @@ func GetLabelInfo(rpt report.Report) map[string]map[string]*TaskLabelInfo {
 
 		task.ContainerIDs = append(task.ContainerIDs, nodeID)
 	}
-	log.Debug("Got ECS container info: %v", results)
+	log.Debugf("Got ECS container info: %v", results)
 	return results
 }
 
@@ -126,7 +130,7 @@ func (r Reporter) getClient(cluster string) (EcsClient, error) {
 }
 
 // Tag needed for Tagger
-func (r Reporter) Tag(rpt report.Report) (report.Report, error) {
+func (r Reporter) Tag(_ context.Context, rpt report.Report) (report.Report, error) {
 	rpt = rpt.Copy()
 
 	clusterMap := GetLabelInfo(rpt)
@@ -204,7 +208,7 @@ func (r Reporter) Tag(rpt report.Report) (report.Report, error) {
 }
 
 // Report needed for Reporter
-func (Reporter) Report() (report.Report, error) {
+func (Reporter) Report(context.Context) (report.Report, error) {
 	result := report.MakeReport()
 	taskTopology := report.MakeTopology().WithMetadataTemplates(taskMetadata)
 	result.ECSTask = result.ECSTask.Merge(taskTopology)
@@ -232,8 +236,11 @@ func (r Reporter) Name() string {
 	return "awsecs"
 }
 
+// Topologies of the reporter, for annotating them when it fails.
+func (Reporter) Topologies() []string { return []string{report.ECSTask, report.ECSService} }
+
 // Stop unregisters controls.
-func (r *Reporter) Stop() {
+func (r Reporter) Stop() {
 	r.handlerRegistry.Batch([]string{
 		ScaleUp,
 		ScaleDown,
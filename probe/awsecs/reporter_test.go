@@ -1,6 +1,7 @@
 package awsecs_test
 
 import (
+	"context"
 	"reflect"
 	"testing"
 	"time"
@@ -39,7 +40,7 @@ func getTestContainerNode() report.Node {
 func TestGetLabelInfo(t *testing.T) {
 	hr := controls.NewDefaultHandlerRegistry()
 	r := awsecs.Make(1e6, time.Hour, "", hr, "test-probe-id")
-	rpt, err := r.Report()
+	rpt, err := r.Report(context.Background())
 	if err != nil {
 		t.Fatalf("Error making report: %v", err)
 	}
@@ -123,12 +124,12 @@ func TestTagReport(t *testing.T) {
 		},
 	)
 
-	rpt, err := r.Report()
+	rpt, err := r.Report(context.Background())
 	if err != nil {
 		t.Fatalf("Error making report")
 	}
 	rpt.Container.AddNode(getTestContainerNode())
-	rpt, err = r.Tag(rpt)
+	rpt, err = r.Tag(context.Background(), rpt)
 	if err != nil {
 		t.Fatalf("Failed to tag: %v", err)
 	}
@@ -26,10 +26,16 @@ func NewReporter(cri client.RuntimeServiceClient) *Reporter {
 // Name of this reporter, for metrics gathering
 func (Reporter) Name() string { return "CRI" }
 
+// Topologies of the reporter, for annotating them when it fails.
+func (Reporter) Topologies() []string { return []string{report.Container} }
+
+// Stop implements Reporter
+func (Reporter) Stop() {}
+
 // Report generates a Report containing Container topologies
-func (r *Reporter) Report() (report.Report, error) {
+func (r *Reporter) Report(ctx context.Context) (report.Report, error) {
 	result := report.MakeReport()
-	containerTopol, err := r.containerTopology()
+	containerTopol, err := r.containerTopology(ctx)
 	if err != nil {
 		return report.MakeReport(), err
 	}
@@ -38,12 +44,11 @@ func (r *Reporter) Report() (report.Report, error) {
 	return result, nil
 }
 
-func (r *Reporter) containerTopology() (report.Topology, error) {
+func (r *Reporter) containerTopology(ctx context.Context) (report.Topology, error) {
 	result := report.MakeTopology().
 		WithMetadataTemplates(docker.ContainerImageMetadataTemplates).
 		WithTableTemplates(docker.ContainerImageTableTemplates)
 
-	ctx := context.Background()
 	resp, err := r.cri.ListContainers(ctx, &client.ListContainersRequest{})
 	if err != nil {
 		return result, err
@@ -69,5 +74,5 @@ func getNode(c *client.Container) report.Node {
 	)
 	result = result.AddPrefixPropertyList(docker.LabelPrefix, c.Labels)
 
-	return result
+	return result.WithSource(report.SourceCRI)
 }
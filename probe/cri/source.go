@@ -0,0 +1,34 @@
+package cri
+
+import (
+	"flag"
+
+	"github.com/weaveworks/scope/probe"
+)
+
+func init() {
+	probe.RegisterSource(&source{})
+}
+
+// source adds the CRI reporter to the probe when enabled by flags
+type source struct {
+	enabled  bool
+	endpoint string
+}
+
+func (s *source) Name() string { return "CRI" }
+
+func (s *source) RegisterFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&s.enabled, "probe.cri", false, "collect CRI-related attributes for processes")
+	fs.StringVar(&s.endpoint, "probe.cri.endpoint", "unix///var/run/dockershim.sock", "The endpoint to connect to the CRI")
+}
+
+func (s *source) Enabled() bool { return s.enabled }
+
+func (s *source) Make(probe.Env) ([]interface{}, error) {
+	client, err := NewCRIClient(s.endpoint)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{NewReporter(client)}, nil
+}
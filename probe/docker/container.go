@@ -10,7 +10,6 @@ import (
 	"time"
 
 	docker "github.com/fsouza/go-dockerclient"
-	log "github.com/sirupsen/logrus"
 
 	"github.com/weaveworks/common/mtime"
 	"github.com/weaveworks/scope/report"
@@ -30,6 +29,8 @@ const (
 	ContainerStateHuman    = report.DockerContainerStateHuman
 	ContainerUptime        = report.DockerContainerUptime
 	ContainerRestartCount  = report.DockerContainerRestartCount
+	ContainerRestarts      = report.DockerContainerRestarts
+	ContainerOOMKills      = report.DockerContainerOOMKills
 	ContainerNetworkMode   = report.DockerContainerNetworkMode
 
 	NetworkRxDropped = "network_rx_dropped"
@@ -41,6 +42,11 @@ const (
 	NetworkTxErrors  = "network_tx_errors"
 	NetworkTxBytes   = "network_tx_bytes"
 
+	// Traffic of all the interfaces of the container, from the
+	// statistics of their veth pairs
+	NetworkRxRate = "docker_network_rx_bytes_per_second"
+	NetworkTxRate = "docker_network_tx_bytes_per_second"
+
 	MemoryMaxUsage = "docker_memory_max_usage"
 	MemoryUsage    = "docker_memory_usage"
 	MemoryFailcnt  = "docker_memory_failcnt"
@@ -54,6 +60,11 @@ const (
 
 	LabelPrefix = "docker_label_"
 	EnvPrefix   = "docker_env_"
+
+	// The labels Docker Compose sets on the containers of the services
+	// of its projects
+	ComposeProject = LabelPrefix + "com.docker.compose.project"
+	ComposeService = LabelPrefix + "com.docker.compose.service"
 )
 
 // These 'constants' are used for node states.
@@ -103,6 +114,10 @@ type container struct {
 	baseNode               report.Node
 	noCommandLineArguments bool
 	noEnvironmentVariables bool
+	// restarts and oomKills are those seen since the container's node
+	// was last got, as counters sum over the reports on merging
+	restarts int
+	oomKills int
 }
 
 // NewContainer creates a new Container
@@ -120,6 +135,14 @@ func NewContainer(c *docker.Container, hostID string, noCommandLineArguments boo
 func (c *container) UpdateState(container *docker.Container) {
 	c.Lock()
 	defer c.Unlock()
+	if delta := container.RestartCount - c.container.RestartCount; delta > 0 {
+		c.restarts += delta
+	}
+	// The flag is set as the container dies, and cleared when it's
+	// started again
+	if container.State.OOMKilled && !c.container.State.OOMKilled {
+		c.oomKills++
+	}
 	c.container = container
 }
 
@@ -366,6 +389,32 @@ func (c *container) cpuPercentMetric(stats []docker.Stats) report.Metric {
 	return report.MakeMetric(samples).WithMax(100.0)
 }
 
+// networkRateMetric is the rate of a counter of the network interfaces
+// of the container, summed over the interfaces. Counters going backwards
+// (when the container restarts) give no sample.
+func (c *container) networkRateMetric(stats []docker.Stats, counter func(docker.NetworkStats) uint64) report.Metric {
+	total := func(s docker.Stats) uint64 {
+		var sum uint64
+		for _, n := range s.Networks {
+			sum += counter(n)
+		}
+		return sum
+	}
+	samples := []report.Sample{}
+	for i := 1; i < len(stats); i++ {
+		previous, s := stats[i-1], stats[i]
+		elapsed := s.Read.Sub(previous.Read).Seconds()
+		if elapsed <= 0 || total(s) < total(previous) {
+			continue
+		}
+		samples = append(samples, report.Sample{
+			Timestamp: s.Read,
+			Value:     float64(total(s)-total(previous)) / elapsed,
+		})
+	}
+	return report.MakeMetric(samples)
+}
+
 func (c *container) metrics() report.Metrics {
 	if c.numPending == 0 {
 		return report.Metrics{}
@@ -375,6 +424,11 @@ func (c *container) metrics() report.Metrics {
 		MemoryUsage:   c.memoryUsageMetric(pendingStats),
 		CPUTotalUsage: c.cpuPercentMetric(pendingStats),
 	}
+	// Containers sharing the network of their host have no interfaces
+	if len(pendingStats[len(pendingStats)-1].Networks) > 0 {
+		result[NetworkRxRate] = c.networkRateMetric(pendingStats, func(n docker.NetworkStats) uint64 { return n.RxBytes })
+		result[NetworkTxRate] = c.networkRateMetric(pendingStats, func(n docker.NetworkStats) uint64 { return n.TxBytes })
+	}
 
 	// leave one stat to help with relative metrics
 	c.pendingStats[0] = c.pendingStats[c.numPending-1]
@@ -434,8 +488,8 @@ func (c *container) controlsMap() map[string]report.NodeControlData {
 }
 
 func (c *container) GetNode() report.Node {
-	c.RLock()
-	defer c.RUnlock()
+	c.Lock()
+	defer c.Unlock()
 	latest := map[string]string{
 		ContainerName:       strings.TrimPrefix(c.container.Name, "/"),
 		ContainerState:      c.StateString(),
@@ -454,12 +508,56 @@ func (c *container) GetNode() report.Node {
 		latest[ContainerNetworkMode] = networkMode
 	}
 
-	result := c.baseNode.WithLatests(latest)
+	result := c.baseNode.WithLatests(latest).WithSource(report.SourceDocker)
 	result = result.WithLatestControls(controls)
 	result = result.WithMetrics(c.metrics())
+	if networks := c.networkNodeIDs(); len(networks) > 0 {
+		result = result.WithParents(report.MakeSets().Add(report.DockerNetwork, report.MakeStringSet(networks...)))
+	}
+	if volumes := c.volumeNodeIDs(); len(volumes) > 0 {
+		result = result.WithParents(report.MakeSets().Add(report.DockerVolume, report.MakeStringSet(volumes...)))
+	}
+	if c.restarts > 0 || c.oomKills > 0 {
+		result = result.WithCounters(map[string]int{
+			ContainerRestarts: c.restarts,
+			ContainerOOMKills: c.oomKills,
+		})
+		c.restarts, c.oomKills = 0, 0
+	}
 	return result
 }
 
+// networkNodeIDs returns the IDs of the nodes of the networks the
+// container is attached to.
+func (c *container) networkNodeIDs() []string {
+	if c.container.NetworkSettings == nil {
+		return nil
+	}
+	ids := []string{}
+	for name, settings := range c.container.NetworkSettings.Networks {
+		// Not reported as networks
+		if name == "none" || name == "host" {
+			continue
+		}
+		if settings.NetworkID != "" {
+			ids = append(ids, report.MakeDockerNetworkNodeID(settings.NetworkID))
+		}
+	}
+	return ids
+}
+
+// volumeNodeIDs returns the IDs of the nodes of the named volumes the
+// container mounts. Bind mounts have no name.
+func (c *container) volumeNodeIDs() []string {
+	ids := []string{}
+	for _, mount := range c.container.Mounts {
+		if mount.Name != "" {
+			ids = append(ids, report.MakeDockerVolumeNodeID(VolumeNodeKey(c.hostID, mount.Name)))
+		}
+	}
+	return ids
+}
+
 // ExtractContainerIPs returns the list of container IPs given a Node from the Container topology.
 func ExtractContainerIPs(nmd report.Node) []string {
 	v, _ := nmd.Sets.Lookup(ContainerIPs)
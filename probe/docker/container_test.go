@@ -82,24 +82,27 @@ func TestContainer(t *testing.T) {
 			"docker_container_state_human": c.Container().State.String(),
 			"docker_container_uptime":      strconv.Itoa(uptimeSeconds),
 			"docker_env_FOO":               "secret-bar",
-		}).WithLatestControls(
+		}).WithSource(report.SourceDocker).WithLatestControls(
 			controls,
 		).WithMetrics(report.Metrics{
 			"docker_cpu_total_usage": report.MakeMetric(nil),
 			"docker_memory_usage":    report.MakeSingletonMetric(now, 12345).WithMax(45678),
 		}).WithParents(report.MakeSets().
-			Add(report.ContainerImage, report.MakeStringSet(report.MakeContainerImageNodeID("baz"))),
+			Add(report.ContainerImage, report.MakeStringSet(report.MakeContainerImageNodeID("baz"))).
+			Add(report.DockerNetwork, report.MakeStringSet(report.MakeDockerNetworkNodeID("deadbeef"))),
 		)
 
 		test.Poll(t, 100*time.Millisecond, want, func() interface{} {
 			node := c.GetNode()
-			latest := report.MakeStringLatestMap()
+			latest, sources := report.MakeStringLatestMap(), report.MakeStringLatestMap()
 			node.Latest.ForEach(func(k string, t time.Time, v string) {
 				if v != "0" && v != "" {
 					latest = latest.Set(k, t, v)
+					source, _ := node.Source(k)
+					sources = sources.Set(k, t, source)
 				}
 			})
-			node.Latest = latest
+			node.Latest, node.Sources = latest, sources
 			return node
 		})
 	}
@@ -131,6 +134,62 @@ func TestContainer(t *testing.T) {
 	}
 }
 
+func TestContainerNetworkMetrics(t *testing.T) {
+	c := docker.NewContainer(container1, "scope", false, false)
+	s := newMockStatsGatherer()
+	if err := c.StartGatheringStats(s); err != nil {
+		t.Fatal(err)
+	}
+	defer c.StopGatheringStats()
+
+	now := time.Now()
+	for i, rx := range []uint64{1000, 6000} {
+		stats := &client.Stats{Networks: map[string]client.NetworkStats{
+			"eth0": {RxBytes: rx, TxBytes: 100},
+			"eth1": {RxBytes: rx},
+		}}
+		stats.Read = now.Add(time.Duration(i) * 10 * time.Second)
+		s.Send(stats)
+	}
+
+	// Metrics are consumed by GetNode, so keep the first with samples
+	var metrics report.Metrics
+	test.Poll(t, 100*time.Millisecond, 1000.0, func() interface{} {
+		if m := c.GetNode().Metrics; len(m[docker.NetworkRxRate].Samples) > 0 {
+			metrics = m
+		}
+		last, _ := metrics[docker.NetworkRxRate].LastSample()
+		return last.Value
+	})
+	if last, ok := metrics[docker.NetworkTxRate].LastSample(); !ok || last.Value != 0 {
+		t.Errorf("expected nothing sent, got %v", metrics[docker.NetworkTxRate])
+	}
+}
+
+func TestContainerRestartsAndOOMKills(t *testing.T) {
+	c := docker.NewContainer(container1, "scope", false, false)
+
+	// Killed for running out of memory, and restarted by its policy
+	died := *container1
+	died.State.OOMKilled = true
+	c.UpdateState(&died)
+	restarted := *container1
+	restarted.RestartCount = container1.RestartCount + 2
+	c.UpdateState(&restarted)
+
+	node := c.GetNode()
+	if restarts, _ := node.Counters.Lookup(docker.ContainerRestarts); restarts != 2 {
+		t.Errorf("expected 2 restarts, got %d", restarts)
+	}
+	if oomKills, _ := node.Counters.Lookup(docker.ContainerOOMKills); oomKills != 1 {
+		t.Errorf("expected 1 OOM kill, got %d", oomKills)
+	}
+	// The counts are only reported once, summing on merge
+	if node := c.GetNode(); node.Counters.Size() != 0 {
+		t.Errorf("expected the counts to be reported once, got %v", node.Counters)
+	}
+}
+
 func TestContainerHidingArgs(t *testing.T) {
 	const hostID = "scope"
 	c := docker.NewContainer(container1, hostID, true, false)
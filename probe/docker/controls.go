@@ -3,8 +3,6 @@ package docker
 import (
 	docker_client "github.com/fsouza/go-dockerclient"
 
-	log "github.com/sirupsen/logrus"
-
 	"github.com/weaveworks/scope/common/xfer"
 	"github.com/weaveworks/scope/probe/controls"
 	"github.com/weaveworks/scope/report"
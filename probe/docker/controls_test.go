@@ -119,3 +119,17 @@ func TestDockerImageName(t *testing.T) {
 		}
 	}
 }
+
+func TestDockerImageDigest(t *testing.T) {
+	repoDigests := []string{"foo/baz@sha256:other", "reg:123/foo/bar@sha256:abc"}
+	for _, input := range []struct{ name, digest string }{
+		{"foo/bar", "sha256:abc"},
+		{"foo/baz", "sha256:other"},
+		{"", "sha256:other"},
+		{"foo/none", ""},
+	} {
+		if digest := docker.ImageDigestOf(input.name, repoDigests); digest != input.digest {
+			t.Errorf("%s: %s != %s", input.name, digest, input.digest)
+		}
+	}
+}
@@ -1,12 +1,12 @@
 package docker
 
 import (
+	"context"
 	"sync"
 	"time"
 
 	"github.com/armon/go-radix"
 	docker_client "github.com/fsouza/go-dockerclient"
-	log "github.com/sirupsen/logrus"
 
 	"github.com/weaveworks/scope/probe/controls"
 	"github.com/weaveworks/scope/report"
@@ -19,6 +19,7 @@ const (
 	RenameEvent            = "rename"
 	StartEvent             = "start"
 	DieEvent               = "die"
+	OOMEvent               = "oom"
 	PauseEvent             = "pause"
 	UnpauseEvent           = "unpause"
 	NetworkConnectEvent    = "network:connect"
@@ -38,6 +39,7 @@ type Registry interface {
 	WalkContainers(f func(Container))
 	WalkImages(f func(docker_client.APIImages))
 	WalkNetworks(f func(docker_client.Network))
+	WalkVolumes(f func(docker_client.Volume))
 	WatchContainerUpdates(ContainerUpdateWatcher)
 	GetContainer(string) (Container, bool)
 	GetContainerByPrefix(string) (Container, bool)
@@ -49,7 +51,10 @@ type ContainerUpdateWatcher func(report.Node)
 
 type registry struct {
 	sync.RWMutex
-	quit                   chan chan struct{}
+	// ctx is cancelled to stop the registry, even mid-list
+	ctx                    context.Context
+	cancel                 context.CancelFunc
+	done                   chan struct{}
 	interval               time.Duration
 	collectStats           bool
 	client                 Client
@@ -64,6 +69,7 @@ type registry struct {
 	containersByPID map[int]Container
 	images          map[string]docker_client.APIImages
 	networks        []docker_client.Network
+	volumes         []docker_client.Volume
 	pipeIDToexecID  map[string]string
 }
 
@@ -73,6 +79,7 @@ type Client interface {
 	InspectContainer(string) (*docker_client.Container, error)
 	ListImages(docker_client.ListImagesOptions) ([]docker_client.APIImages, error)
 	ListNetworks() ([]docker_client.Network, error)
+	ListVolumes(docker_client.ListVolumesOptions) ([]docker_client.Volume, error)
 	AddEventListener(chan<- *docker_client.APIEvents) error
 	RemoveEventListener(chan *docker_client.APIEvents) error
 
@@ -127,11 +134,12 @@ func NewRegistry(options RegistryOptions) (Registry, error) {
 		collectStats:    options.CollectStats,
 		hostID:          options.HostID,
 		handlerRegistry: options.HandlerRegistry,
-		quit:            make(chan chan struct{}),
+		done:            make(chan struct{}),
 		noCommandLineArguments: options.NoCommandLineArguments,
 		noEnvironmentVariables: options.NoEnvironmentVariables,
 	}
 
+	r.ctx, r.cancel = context.WithCancel(context.Background())
 	r.registerControls()
 	go r.loop()
 	return r, nil
@@ -140,9 +148,8 @@ func NewRegistry(options RegistryOptions) (Registry, error) {
 // Stop stops the Docker registry's event subscriber.
 func (r *registry) Stop() {
 	r.deregisterControls()
-	ch := make(chan struct{})
-	r.quit <- ch
-	<-ch
+	r.cancel()
+	<-r.done
 }
 
 // WatchContainerUpdates registers a callback to be called
@@ -154,16 +161,22 @@ func (r *registry) WatchContainerUpdates(f ContainerUpdateWatcher) {
 }
 
 func (r *registry) loop() {
-	for {
-		// NB listenForEvents blocks.
-		// Returning false means we should exit.
-		if !r.listenForEvents() {
-			return
-		}
-
+	defer func() {
+		r.Lock()
+		r.stopGatheringStats()
+		r.Unlock()
+		close(r.done)
+	}()
+	// NB listenForEvents blocks.
+	// Returning false means we should exit.
+	for r.listenForEvents() {
 		// Sleep here so we don't hammer the
 		// logs if docker is down
-		time.Sleep(r.interval)
+		select {
+		case <-time.After(r.interval):
+		case <-r.ctx.Done():
+			return
+		}
 	}
 }
 
@@ -202,6 +215,11 @@ func (r *registry) listenForEvents() bool {
 		return true
 	}
 
+	if err := r.updateVolumes(); err != nil {
+		log.Errorf("docker registry: %s", err)
+		return true
+	}
+
 	otherUpdates := time.Tick(r.interval)
 	for {
 		select {
@@ -221,18 +239,12 @@ func (r *registry) listenForEvents() bool {
 				log.Errorf("docker registry: %s", err)
 				return true
 			}
-
-		case ch := <-r.quit:
-			r.Lock()
-			defer r.Unlock()
-
-			if r.collectStats {
-				r.containers.Walk(func(_ string, c interface{}) bool {
-					c.(Container).StopGatheringStats()
-					return false
-				})
+			if err := r.updateVolumes(); err != nil {
+				log.Errorf("docker registry: %s", err)
+				return true
 			}
-			close(ch)
+
+		case <-r.ctx.Done():
 			return false
 		}
 	}
@@ -242,26 +254,36 @@ func (r *registry) reset() {
 	r.Lock()
 	defer r.Unlock()
 
+	r.stopGatheringStats()
+	r.containers = radix.New()
+	r.containersByPID = map[int]Container{}
+	r.images = map[string]docker_client.APIImages{}
+	r.networks = r.networks[:0]
+	r.volumes = r.volumes[:0]
+}
+
+// stopGatheringStats stops gathering the stats of every container. Must
+// be called with the lock held.
+func (r *registry) stopGatheringStats() {
 	if r.collectStats {
 		r.containers.Walk(func(_ string, c interface{}) bool {
 			c.(Container).StopGatheringStats()
 			return false
 		})
 	}
-
-	r.containers = radix.New()
-	r.containersByPID = map[int]Container{}
-	r.images = map[string]docker_client.APIImages{}
-	r.networks = r.networks[:0]
 }
 
 func (r *registry) updateContainers() error {
-	apiContainers, err := r.client.ListContainers(docker_client.ListContainersOptions{All: true})
+	apiContainers, err := r.client.ListContainers(docker_client.ListContainersOptions{All: true, Context: r.ctx})
 	if err != nil {
 		return err
 	}
 
 	for _, apiContainer := range apiContainers {
+		// Inspecting thousands of containers takes a while
+		if err := r.ctx.Err(); err != nil {
+			return err
+		}
 		r.updateContainerState(apiContainer.ID, nil)
 	}
 
@@ -297,10 +319,23 @@ func (r *registry) updateNetworks() error {
 	return nil
 }
 
+func (r *registry) updateVolumes() error {
+	volumes, err := r.client.ListVolumes(docker_client.ListVolumesOptions{Context: r.ctx})
+	if err != nil {
+		return err
+	}
+
+	r.Lock()
+	r.volumes = volumes
+	r.Unlock()
+
+	return nil
+}
+
 func (r *registry) handleEvent(event *docker_client.APIEvents) {
 	// TODO: Send shortcut reports on networks being created/destroyed?
 	switch event.Status {
-	case CreateEvent, RenameEvent, StartEvent, DieEvent, DestroyEvent, PauseEvent, UnpauseEvent, NetworkConnectEvent, NetworkDisconnectEvent:
+	case CreateEvent, RenameEvent, StartEvent, DieEvent, OOMEvent, DestroyEvent, PauseEvent, UnpauseEvent, NetworkConnectEvent, NetworkDisconnectEvent:
 		r.updateContainerState(event.ID, stateAfterEvent(event.Status))
 	}
 }
@@ -469,3 +504,13 @@ func (r *registry) WalkNetworks(f func(docker_client.Network)) {
 		f(network)
 	}
 }
+
+// WalkVolumes runs f on every volume the registry knows of.
+func (r *registry) WalkVolumes(f func(docker_client.Volume)) {
+	r.RLock()
+	defer r.RUnlock()
+
+	for _, volume := range r.volumes {
+		f(volume)
+	}
+}
@@ -95,6 +95,7 @@ type mockDockerClient struct {
 	containers    map[string]*client.Container
 	apiImages     []client.APIImages
 	networks      []client.Network
+	volumes       []client.Volume
 	events        []chan<- *client.APIEvents
 }
 
@@ -120,6 +121,12 @@ func (m *mockDockerClient) ListImages(client.ListImagesOptions) ([]client.APIIma
 	return m.apiImages, nil
 }
 
+func (m *mockDockerClient) ListVolumes(client.ListVolumesOptions) ([]client.Volume, error) {
+	m.RLock()
+	defer m.RUnlock()
+	return m.volumes, nil
+}
+
 func (m *mockDockerClient) ListNetworks() ([]client.Network, error) {
 	m.RLock()
 	defer m.RUnlock()
@@ -230,6 +237,7 @@ var (
 			Networks: map[string]client.ContainerNetwork{
 				"network1": {
 					IPAddress: "5.6.7.8",
+					NetworkID: "deadbeef",
 				},
 			},
 		},
@@ -279,9 +287,10 @@ var (
 		},
 	}
 	network1 = client.Network{
-		ID:    "deadbeef",
-		Name:  "network1",
-		Scope: "local",
+		ID:     "deadbeef",
+		Name:   "network1",
+		Scope:  "local",
+		Driver: "bridge",
 		IPAM: client.IPAMOptions{
 			Config: []client.IPAMConfig{{Subnet: "5.6.7.8/24"}},
 		},
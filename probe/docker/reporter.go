@@ -1,17 +1,23 @@
 package docker
 
 import (
+	"context"
 	"net"
+	"strconv"
 	"strings"
 
 	humanize "github.com/dustin/go-humanize"
 	docker_client "github.com/fsouza/go-dockerclient"
 
+	"github.com/weaveworks/scope/common/xlog"
 	"github.com/weaveworks/scope/probe"
 	"github.com/weaveworks/scope/probe/host"
 	"github.com/weaveworks/scope/report"
 )
 
+// log is the logger of the docker component
+var log = xlog.Component("docker")
+
 // Keys for use in Node
 const (
 	ImageID          = report.DockerImageID
@@ -19,12 +25,21 @@ const (
 	ImageTag         = report.DockerImageTag
 	ImageSize        = report.DockerImageSize
 	ImageVirtualSize = report.DockerImageVirtualSize
+	ImageDigest      = report.DockerImageDigest
 	IsInHostNetwork  = report.DockerIsInHostNetwork
 	ImageLabelPrefix = "docker_image_label_"
 	ImageTableID     = "image_table"
 	ServiceName      = report.DockerServiceName
 	StackNamespace   = report.DockerStackNamespace
 	DefaultNamespace = "No stack"
+	NetworkName      = report.DockerNetworkName
+	NetworkDriver    = report.DockerNetworkDriver
+	NetworkScope     = report.DockerNetworkScope
+	NetworkInternal  = report.DockerNetworkInternal
+	NetworkSubnets   = report.DockerNetworkSubnets
+	VolumeName       = report.DockerVolumeName
+	VolumeDriver     = report.DockerVolumeDriver
+	VolumeMountpoint = report.DockerVolumeMountpoint
 )
 
 // Exposed for testing
@@ -36,16 +51,26 @@ var (
 		ContainerStateHuman:   {ID: ContainerStateHuman, Label: "State", From: report.FromLatest, Priority: 4},
 		ContainerUptime:       {ID: ContainerUptime, Label: "Uptime", From: report.FromLatest, Priority: 5, Datatype: report.Duration},
 		ContainerRestartCount: {ID: ContainerRestartCount, Label: "Restart #", From: report.FromLatest, Priority: 6},
-		ContainerNetworks:     {ID: ContainerNetworks, Label: "Networks", From: report.FromSets, Priority: 7},
-		ContainerIPs:          {ID: ContainerIPs, Label: "IPs", From: report.FromSets, Priority: 8},
-		ContainerPorts:        {ID: ContainerPorts, Label: "Ports", From: report.FromSets, Priority: 9},
-		ContainerCreated:      {ID: ContainerCreated, Label: "Created", From: report.FromLatest, Datatype: report.DateTime, Priority: 10},
-		ContainerID:           {ID: ContainerID, Label: "ID", From: report.FromLatest, Truncate: 12, Priority: 11},
+		ContainerRestarts:     {ID: ContainerRestarts, Label: "Recent restarts", From: report.FromCounters, Datatype: report.Number, Priority: 7},
+		ContainerOOMKills:     {ID: ContainerOOMKills, Label: "Recent OOM kills", From: report.FromCounters, Datatype: report.Number, Priority: 8},
+		ContainerNetworks:     {ID: ContainerNetworks, Label: "Networks", From: report.FromSets, Priority: 9},
+		ContainerIPs:          {ID: ContainerIPs, Label: "IPs", From: report.FromSets, Priority: 10},
+		ContainerPorts:        {ID: ContainerPorts, Label: "Ports", From: report.FromSets, Priority: 11},
+		ContainerCreated:      {ID: ContainerCreated, Label: "Created", From: report.FromLatest, Datatype: report.DateTime, Priority: 12},
+		ContainerID:           {ID: ContainerID, Label: "ID", From: report.FromLatest, Truncate: 12, Priority: 13},
+		ComposeProject:        {ID: ComposeProject, Label: "Compose project", From: report.FromLatest, Priority: 14},
+		ComposeService:        {ID: ComposeService, Label: "Compose service", From: report.FromLatest, Priority: 15},
 	}
 
 	ContainerMetricTemplates = report.MetricTemplates{
 		CPUTotalUsage: {ID: CPUTotalUsage, Label: "CPU", Format: report.PercentFormat, Priority: 1},
 		MemoryUsage:   {ID: MemoryUsage, Label: "Memory", Format: report.FilesizeFormat, Priority: 2},
+		NetworkRxRate: {ID: NetworkRxRate, Label: "Received/s", Format: report.FilesizeFormat, Priority: 5},
+		NetworkTxRate: {ID: NetworkTxRate, Label: "Sent/s", Format: report.FilesizeFormat, Priority: 6},
+
+		// Rolled up from the container's processes by the render pipeline
+		report.ProcessesCPUUsage:    {ID: report.ProcessesCPUUsage, Label: "Processes CPU", Format: report.PercentFormat, Priority: 3},
+		report.ProcessesMemoryUsage: {ID: report.ProcessesMemoryUsage, Label: "Processes memory", Format: report.FilesizeFormat, Priority: 4},
 	}
 
 	ContainerImageMetadataTemplates = report.MetadataTemplates{
@@ -144,6 +169,20 @@ var (
 		ServiceName:    {ID: ServiceName, Label: "Service name", From: report.FromLatest, Priority: 0},
 		StackNamespace: {ID: StackNamespace, Label: "Stack namespace", From: report.FromLatest, Priority: 1},
 	}
+
+	NetworkMetadataTemplates = report.MetadataTemplates{
+		NetworkDriver:    {ID: NetworkDriver, Label: "Driver", From: report.FromLatest, Priority: 1},
+		NetworkScope:     {ID: NetworkScope, Label: "Scope", From: report.FromLatest, Priority: 2},
+		NetworkInternal:  {ID: NetworkInternal, Label: "Internal", From: report.FromLatest, Priority: 3},
+		NetworkSubnets:   {ID: NetworkSubnets, Label: "Subnets", From: report.FromSets, Priority: 4},
+		report.Container: {ID: report.Container, Label: "# Containers", From: report.FromCounters, Datatype: report.Number, Priority: 5},
+	}
+
+	VolumeMetadataTemplates = report.MetadataTemplates{
+		VolumeDriver:     {ID: VolumeDriver, Label: "Driver", From: report.FromLatest, Priority: 1},
+		VolumeMountpoint: {ID: VolumeMountpoint, Label: "Mountpoint", From: report.FromLatest, Priority: 2},
+		report.Container: {ID: report.Container, Label: "# Containers", From: report.FromCounters, Datatype: report.Number, Priority: 3},
+	}
 )
 
 // Reporter generate Reports containing Container and ContainerImage topologies
@@ -169,6 +208,15 @@ func NewReporter(registry Registry, hostID string, probeID string, probe *probe.
 // Name of this reporter, for metrics gathering
 func (Reporter) Name() string { return "Docker" }
 
+// Topologies of the reporter, for annotating them when it fails.
+func (Reporter) Topologies() []string {
+	return []string{report.Container, report.ContainerImage, report.Overlay, report.SwarmService, report.DockerNetwork, report.DockerVolume}
+}
+
+// Stop implements Reporter. The registry is stopped by its owner, as it
+// is shared with the tagger.
+func (Reporter) Stop() {}
+
 // ContainerUpdated should be called whenever a container is updated.
 func (r *Reporter) ContainerUpdated(n report.Node) {
 	// Publish a 'short cut' report container just this container
@@ -179,7 +227,7 @@ func (r *Reporter) ContainerUpdated(n report.Node) {
 }
 
 // Report generates a Report containing Container and ContainerImage topologies
-func (r *Reporter) Report() (report.Report, error) {
+func (r *Reporter) Report(context.Context) (report.Report, error) {
 	localAddrs, err := report.LocalAddresses()
 	if err != nil {
 		return report.MakeReport(), nil
@@ -190,6 +238,8 @@ func (r *Reporter) Report() (report.Report, error) {
 	result.ContainerImage = result.ContainerImage.Merge(r.containerImageTopology())
 	result.Overlay = result.Overlay.Merge(r.overlayTopology())
 	result.SwarmService = result.SwarmService.Merge(r.swarmServiceTopology())
+	result.DockerNetwork = result.DockerNetwork.Merge(r.networkTopology())
+	result.DockerVolume = result.DockerVolume.Merge(r.volumeTopology())
 	return result, nil
 }
 
@@ -286,6 +336,9 @@ func (r *Reporter) containerImageTopology() report.Topology {
 			latests[ImageName] = ImageNameWithoutTag(imageFullName)
 			latests[ImageTag] = ImageNameTag(imageFullName)
 		}
+		if digest := ImageDigestOf(latests[ImageName], image.RepoDigests); digest != "" {
+			latests[ImageDigest] = digest
+		}
 		nodeID := report.MakeContainerImageNodeID(imageID)
 		node := report.MakeNodeWith(nodeID, latests)
 		node = node.AddPrefixPropertyList(ImageLabelPrefix, image.Labels)
@@ -312,10 +365,77 @@ func (r *Reporter) overlayTopology() report.Topology {
 	return t
 }
 
+// networkTopology reports the networks containers can be attached to.
+// Networks local to the host, such as bridges, are parented to it, as
+// their names and IDs are only unique on the host; those of the swarm,
+// such as overlays, are the same network on every host.
+func (r *Reporter) networkTopology() report.Topology {
+	result := report.MakeTopology().WithMetadataTemplates(NetworkMetadataTemplates)
+	r.registry.WalkNetworks(func(network docker_client.Network) {
+		// Containers attached to these are not on any network of their own
+		if network.Driver == "null" || network.Driver == "host" {
+			return
+		}
+		subnets := []string{}
+		for _, config := range network.IPAM.Config {
+			subnets = append(subnets, config.Subnet)
+		}
+		node := report.MakeNodeWith(report.MakeDockerNetworkNodeID(network.ID), map[string]string{
+			NetworkName:     network.Name,
+			NetworkDriver:   network.Driver,
+			NetworkScope:    network.Scope,
+			NetworkInternal: strconv.FormatBool(network.Internal),
+		}).WithTopology(report.DockerNetwork)
+		if len(subnets) > 0 {
+			node = node.WithSet(NetworkSubnets, report.MakeStringSet(subnets...))
+		}
+		if network.Scope == "local" {
+			node = node.WithParent(report.Host, report.MakeHostNodeID(r.hostID))
+		}
+		result.AddNode(node)
+	})
+	return result
+}
+
+// volumeTopology reports the named volumes containers can mount. Volumes
+// are parented to the host, as their names are only unique on the host.
+func (r *Reporter) volumeTopology() report.Topology {
+	result := report.MakeTopology().WithMetadataTemplates(VolumeMetadataTemplates)
+	r.registry.WalkVolumes(func(volume docker_client.Volume) {
+		result.AddNode(report.MakeNodeWith(report.MakeDockerVolumeNodeID(VolumeNodeKey(r.hostID, volume.Name)), map[string]string{
+			VolumeName:       volume.Name,
+			VolumeDriver:     volume.Driver,
+			VolumeMountpoint: volume.Mountpoint,
+		}).WithTopology(report.DockerVolume).WithParent(report.Host, report.MakeHostNodeID(r.hostID)))
+	})
+	return result
+}
+
+// VolumeNodeKey is the key of the node of a volume of the host, as
+// volumes are known by their names only.
+func VolumeNodeKey(hostID, name string) string {
+	return hostID + ":" + name
+}
+
 func (r *Reporter) swarmServiceTopology() report.Topology {
 	return report.MakeTopology().WithMetadataTemplates(SwarmServiceMetadataTemplates)
 }
 
+// ImageDigestOf returns the manifest digest of an image of the given
+// name, as ImageNameWithoutTag makes them, from its repo digests (e.g.
+// "weaveworks/scope@sha256:8a2c..."): that of the repo of the name, as
+// images pushed to several repos have a digest in each. It returns "" if
+// there is none, e.g. because the image was built locally and never
+// pushed or pulled. Images without a name have the digest of any repo.
+func ImageDigestOf(name string, repoDigests []string) string {
+	for _, d := range repoDigests {
+		if i := strings.LastIndex(d, "@"); i >= 0 && (name == "" || ImageNameWithoutTag(d[:i]) == name) {
+			return d[i+1:]
+		}
+	}
+	return ""
+}
+
 // Docker sometimes prefixes ids with a "type" annotation, but it renders a bit
 // ugly and isn't necessary, so we should strip it off
 func trimImageID(id string) string {
@@ -1,6 +1,7 @@
 package docker_test
 
 import (
+	"context"
 	"testing"
 
 	client "github.com/fsouza/go-dockerclient"
@@ -14,6 +15,7 @@ type mockRegistry struct {
 	containersByPID map[int]docker.Container
 	images          map[string]client.APIImages
 	networks        []client.Network
+	volumes         []client.Volume
 }
 
 func (r *mockRegistry) Stop() {}
@@ -42,6 +44,12 @@ func (r *mockRegistry) WalkNetworks(f func(client.Network)) {
 	}
 }
 
+func (r *mockRegistry) WalkVolumes(f func(client.Volume)) {
+	for _, i := range r.volumes {
+		f(i)
+	}
+}
+
 func (r *mockRegistry) WatchContainerUpdates(_ docker.ContainerUpdateWatcher) {}
 
 func (r *mockRegistry) GetContainer(_ string) (docker.Container, bool) { return nil, false }
@@ -63,6 +71,7 @@ var (
 			imageID: apiImage1,
 		},
 		networks: []client.Network{network1},
+		volumes:  []client.Volume{{Name: "data", Driver: "local", Mountpoint: "/var/lib/docker/volumes/data/_data"}},
 	}
 )
 
@@ -73,7 +82,7 @@ func TestReporter(t *testing.T) {
 	)
 
 	containerImageNodeID := report.MakeContainerImageNodeID(imageID)
-	rpt, err := docker.NewReporter(mockRegistryInstance, "host1", controlProbeID, nil).Report()
+	rpt, err := docker.NewReporter(mockRegistryInstance, "host1", controlProbeID, nil).Report(context.Background())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -146,4 +155,37 @@ func TestReporter(t *testing.T) {
 		}
 
 	}
+
+	// Reporter should add the Docker networks, local to the host
+	{
+		networkNodeID := report.MakeDockerNetworkNodeID("deadbeef")
+		node, ok := rpt.DockerNetwork.Nodes[networkNodeID]
+		if !ok {
+			t.Fatalf("Expected report to have network %q, but not found", networkNodeID)
+		}
+		for k, want := range map[string]string{
+			docker.NetworkName:     "network1",
+			docker.NetworkDriver:   "bridge",
+			docker.NetworkInternal: "false",
+		} {
+			if have, ok := node.Latest.Lookup(k); !ok || have != want {
+				t.Errorf("Expected network %s latest %q: %q, got %q", networkNodeID, k, want, have)
+			}
+		}
+		if parents, ok := node.Parents.Lookup(report.Host); !ok || !parents.Contains(report.MakeHostNodeID(hostID)) {
+			t.Errorf("Expected network %s to have parent host %q, got %q", networkNodeID, hostID, parents)
+		}
+	}
+
+	// Reporter should add the Docker volumes of the host
+	{
+		volumeNodeID := report.MakeDockerVolumeNodeID(docker.VolumeNodeKey(hostID, "data"))
+		node, ok := rpt.DockerVolume.Nodes[volumeNodeID]
+		if !ok {
+			t.Fatalf("Expected report to have volume %q, but not found", volumeNodeID)
+		}
+		if have, ok := node.Latest.Lookup(docker.VolumeDriver); !ok || have != "local" {
+			t.Errorf("Expected volume %s to have driver %q, got %q", volumeNodeID, "local", have)
+		}
+	}
 }
@@ -1,6 +1,7 @@
 package docker
 
 import (
+	"context"
 	"strconv"
 	"strings"
 
@@ -40,8 +41,8 @@ func NewTagger(registry Registry, procWalker process.Walker) *Tagger {
 func (Tagger) Name() string { return "Docker" }
 
 // Tag implements Tagger.
-func (t *Tagger) Tag(r report.Report) (report.Report, error) {
-	tree, err := NewProcessTreeStub(t.procWalker)
+func (t *Tagger) Tag(ctx context.Context, r report.Report) (report.Report, error) {
+	tree, err := NewProcessTreeStub(ctx, t.procWalker)
 	if err != nil {
 		return report.MakeReport(), err
 	}
@@ -1,6 +1,7 @@
 package docker_test
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -30,7 +31,7 @@ func TestTagger(t *testing.T) {
 	oldProcessTree := docker.NewProcessTreeStub
 	defer func() { docker.NewProcessTreeStub = oldProcessTree }()
 
-	docker.NewProcessTreeStub = func(_ process.Walker) (process.Tree, error) {
+	docker.NewProcessTreeStub = func(_ context.Context, _ process.Walker) (process.Tree, error) {
 		return &mockProcessTree{map[int]int{3: 2}}, nil
 	}
 
@@ -43,7 +44,7 @@ func TestTagger(t *testing.T) {
 	input.Process.AddNode(report.MakeNodeWith(pid1NodeID, map[string]string{process.PID: "2"}))
 	input.Process.AddNode(report.MakeNodeWith(pid2NodeID, map[string]string{process.PID: "3"}))
 
-	have, err := docker.NewTagger(mockRegistryInstance, nil).Tag(input)
+	have, err := docker.NewTagger(mockRegistryInstance, nil).Tag(context.Background(), input)
 	if err != nil {
 		t.Errorf("%v", err)
 	}
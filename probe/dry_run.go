@@ -0,0 +1,115 @@
+package probe
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// Diagnosis describes what a ticker, reporter or tagger did in a dry
+// run of the probe.
+type Diagnosis struct {
+	Kind   string
+	Timing Timing
+	Nodes  map[string]int
+	Sample *report.Node
+}
+
+// DryRun runs every ticker and reporter once, then tags the merged
+// report, without publishing anything. Reporters are run one after
+// another, so their timings don't interfere.
+func (p *Probe) DryRun() []Diagnosis {
+	result := []Diagnosis{}
+	for _, timing := range p.tick() {
+		result = append(result, Diagnosis{Kind: "ticker", Timing: timing})
+	}
+
+	rpt := report.MakeReport()
+	for _, rep := range p.reporters {
+		var r report.Report
+		t := time.Now()
+		err := safely(func() (err error) {
+			r, err = rep.Report(p.ctx)
+			return err
+		})
+		d := Diagnosis{
+			Kind:   "reporter",
+			Timing: Timing{Name: rep.Name(), Duration: time.Since(t), Error: errorString(err)},
+			Nodes:  map[string]int{},
+		}
+		if err == nil {
+			d.Nodes, d.Sample = summarise(r)
+			rpt = rpt.Merge(r)
+		}
+		result = append(result, d)
+	}
+
+	_, taggers := p.tagTimed(rpt)
+	for _, timing := range taggers {
+		result = append(result, Diagnosis{Kind: "tagger", Timing: timing})
+	}
+	return result
+}
+
+// summarise counts the nodes of every topology in r, and picks the
+// first node of the first non-empty topology as a sample.
+func summarise(r report.Report) (map[string]int, *report.Node) {
+	counts := map[string]int{}
+	names := []string{}
+	r.WalkNamedTopologies(func(name string, t *report.Topology) {
+		if len(t.Nodes) > 0 {
+			counts[name] = len(t.Nodes)
+			names = append(names, name)
+		}
+	})
+	if len(names) == 0 {
+		return counts, nil
+	}
+	sort.Strings(names)
+	t, _ := r.Topology(names[0])
+	ids := make([]string, 0, len(t.Nodes))
+	for id := range t.Nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	sample := t.Nodes[ids[0]]
+	return counts, &sample
+}
+
+// WriteDiagnoses writes a human-readable summary of a dry run to w.
+func WriteDiagnoses(w io.Writer, diagnoses []Diagnosis) {
+	for _, d := range diagnoses {
+		status := "ok"
+		if d.Timing.Error != "" {
+			status = "error: " + d.Timing.Error
+		}
+		fmt.Fprintf(w, "%-8s %-24s %10v  %s\n", d.Kind, d.Timing.Name, d.Timing.Duration, status)
+		if d.Kind != "reporter" || d.Timing.Error != "" {
+			continue
+		}
+		if len(d.Nodes) == 0 {
+			fmt.Fprintf(w, "         no nodes reported\n")
+			continue
+		}
+		topologies := make([]string, 0, len(d.Nodes))
+		for name := range d.Nodes {
+			topologies = append(topologies, name)
+		}
+		sort.Strings(topologies)
+		counts := make([]string, 0, len(topologies))
+		for _, name := range topologies {
+			counts = append(counts, fmt.Sprintf("%s=%d", name, d.Nodes[name]))
+		}
+		fmt.Fprintf(w, "         nodes: %s\n", strings.Join(counts, " "))
+		if d.Sample != nil {
+			fmt.Fprintf(w, "         sample: %s\n", d.Sample.ID)
+			d.Sample.Latest.ForEach(func(key string, _ time.Time, value string) {
+				fmt.Fprintf(w, "           %s: %s\n", key, value)
+			})
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package probe
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/weaveworks/scope/report"
+)
+
+func TestDryRun(t *testing.T) {
+	r := report.MakeReport()
+	r.Host.AddNode(report.MakeNodeWith("host1", map[string]string{"host_name": "host1"}))
+	r.Host.AddNode(report.MakeNode("host2"))
+
+	p := New(0, 0, nil, false)
+	p.AddReporter(
+		ReporterFunc("good", func(context.Context) (report.Report, error) { return r, nil }),
+		ReporterFunc("bad", func(context.Context) (report.Report, error) {
+			return report.MakeReport(), fmt.Errorf("permission denied")
+		}),
+		ReporterFunc("empty", func(context.Context) (report.Report, error) { return report.MakeReport(), nil }),
+	)
+	p.AddTagger(NewTopologyTagger())
+
+	diagnoses := p.DryRun()
+	if len(diagnoses) != 4 {
+		t.Fatalf("expected 3 reporters and 1 tagger, got %v", diagnoses)
+	}
+	if good := diagnoses[0]; good.Nodes[report.Host] != 2 || good.Sample == nil || good.Sample.ID != "host1" {
+		t.Errorf("unexpected diagnosis: %+v", good)
+	}
+
+	var buf bytes.Buffer
+	WriteDiagnoses(&buf, diagnoses)
+	for _, want := range []string{
+		"nodes: host=2",
+		"sample: host1",
+		"host_name: host1",
+		"error: permission denied",
+		"no nodes reported",
+		"tagger",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %q in:\n%s", want, buf.String())
+		}
+	}
+}
@@ -1,10 +1,12 @@
 package endpoint
 
 import (
+	"context"
 	"strconv"
 	"time"
 
-	log "github.com/sirupsen/logrus"
+	"github.com/weaveworks/common/mtime"
+
 	"github.com/weaveworks/scope/probe/endpoint/procspy"
 	"github.com/weaveworks/scope/probe/process"
 	"github.com/weaveworks/scope/report"
@@ -33,12 +35,29 @@ type connectionTracker struct {
 
 	// time of the previous ebpf failure, or zero if it didn't fail
 	ebpfLastFailureTime time.Time
+
+	// whether the tracker made the scanner, so must stop it
+	ownScanner bool
+
+	// ctx is cancelled on stopping, so that the scans for the initial
+	// state of the eBPF tracker, run in the background, return promptly
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 func newConnectionTracker(conf connectionTrackerConfig) connectionTracker {
+	ctx, cancel := context.WithCancel(context.Background())
 	ct := connectionTracker{
 		conf:            conf,
 		reverseResolver: newReverseResolver(),
+		ctx:             ctx,
+		cancel:          cancel,
+	}
+	if conf.WalkProc && conf.Scanner == nil {
+		// The sockets of processes are scanned for their states whichever
+		// tracks connections, and for connections without eBPF
+		ct.conf.Scanner = procspy.NewConnectionScanner(conf.ProcessCache, conf.SpyProcs)
+		ct.ownScanner = true
 	}
 	if conf.UseEbpfConn {
 		et, err := newEbpfTracker()
@@ -74,9 +93,6 @@ func flowToTuple(f flow) (ft fourTuple) {
 
 func (t *connectionTracker) useProcfs() {
 	t.ebpfTracker = nil
-	if t.conf.WalkProc && t.conf.Scanner == nil {
-		t.conf.Scanner = procspy.NewConnectionScanner(t.conf.ProcessCache, t.conf.SpyProcs)
-	}
 	if t.flowWalker == nil {
 		t.flowWalker = newConntrackFlowWalker(t.conf.UseConntrack, t.conf.ProcRoot, t.conf.BufferSize)
 	}
@@ -121,7 +137,11 @@ func (t *connectionTracker) ReportConnections(rpt *report.Report) {
 	t.flowWalker.walkFlows(func(f flow, alive bool) {
 		tuple := flowToTuple(f)
 		seenTuples[tuple.key()] = tuple
-		t.addConnection(rpt, false, tuple, "", nil, nil)
+		t.addConnection(rpt, false, true, tuple, "", nil, nil)
+	})
+	// and for connection attempts which were refused or timed out
+	t.flowWalker.walkFailedFlows(func(f flow) {
+		t.addFailedConnection(rpt, flowToTuple(f))
 	})
 
 	if t.conf.WalkProc && t.conf.Scanner != nil {
@@ -152,7 +172,6 @@ func (t *connectionTracker) performWalkProc(rpt *report.Report, hostNodeID strin
 		return err
 	}
 	for conn := conns.Next(); conn != nil; conn = conns.Next() {
-		tuple, namespaceID, incoming := connectionTuple(conn, seenTuples)
 		var toNodeInfo, fromNodeInfo map[string]string
 		if conn.Proc.PID > 0 {
 			fromNodeInfo = map[string]string{
@@ -160,7 +179,50 @@ func (t *connectionTracker) performWalkProc(rpt *report.Report, hostNodeID strin
 				report.HostNodeID: hostNodeID,
 			}
 		}
-		t.addConnection(rpt, incoming, tuple, namespaceID, fromNodeInfo, toNodeInfo)
+		if conn.Transport == "udp" {
+			// The flows seen by conntrack are all TCP, so the direction
+			// of UDP sockets is always guessed
+			tuple, namespaceID, incoming := connectionTuple(conn, nil)
+			t.addUDPConnection(rpt, incoming, tuple, namespaceID, fromNodeInfo, toNodeInfo)
+			continue
+		}
+		tuple, namespaceID, incoming := connectionTuple(conn, seenTuples)
+		// Only conntrack knows which end initiated the connection
+		_, known := seenTuples[tuple.key()]
+		t.addConnection(rpt, incoming, known, tuple, namespaceID, fromNodeInfo, toNodeInfo)
+	}
+	if err := t.addSocketStates(rpt, hostNodeID); err != nil {
+		return err
+	}
+	return t.addListeners(rpt, hostNodeID)
+}
+
+// addListeners adds an endpoint for every socket listening in a known
+// process. Listening endpoints are always scoped by host and network
+// namespace, since wildcard addresses are shared by every host.
+func (t *connectionTracker) addListeners(rpt *report.Report, hostNodeID string) error {
+	listeners, err := t.conf.Scanner.Listeners()
+	if err != nil {
+		return err
+	}
+	for conn := listeners.Next(); conn != nil; conn = listeners.Next() {
+		if conn.Proc.PID == 0 {
+			continue
+		}
+		scope := t.conf.HostID
+		if conn.Proc.NetNamespaceID > 0 {
+			scope += "-" + strconv.FormatUint(conn.Proc.NetNamespaceID, 10)
+		}
+		port := strconv.Itoa(int(conn.LocalPort))
+		if conn.Transport == "udp" {
+			port += report.UDPPortSuffix
+		}
+		id := report.MakeScopedEndpointNodeID(scope, conn.LocalAddress.String(), port)
+		rpt.Endpoint.AddNode(report.MakeNodeWith(id, map[string]string{
+			Listening:         "true",
+			process.PID:       strconv.FormatUint(uint64(conn.Proc.PID), 10),
+			report.HostNodeID: hostNodeID,
+		}))
 	}
 	return nil
 }
@@ -171,9 +233,9 @@ func (t *connectionTracker) getInitialState() {
 	var processCache *process.CachingWalker
 	walker := process.NewWalker(t.conf.ProcRoot, true)
 	processCache = process.NewCachingWalker(walker)
-	processCache.Tick()
+	processCache.Tick(t.ctx)
 
-	scanner := procspy.NewSyncConnectionScanner(processCache, t.conf.SpyProcs)
+	scanner := procspy.NewSyncConnectionScanner(t.ctx, processCache, t.conf.SpyProcs)
 
 	// Consult conntrack to get the initial state
 	seenTuples := t.existingFlows()
@@ -185,7 +247,7 @@ func (t *connectionTracker) getInitialState() {
 	scanner.Stop()
 
 	processesWaitingInAccept := []int{}
-	processCache.Walk(func(p, prev process.Process) {
+	processCache.Walk(t.ctx, func(p, prev process.Process) {
 		if p.IsWaitingInAccept {
 			processesWaitingInAccept = append(processesWaitingInAccept, p.PID)
 		}
@@ -194,6 +256,11 @@ func (t *connectionTracker) getInitialState() {
 	t.ebpfTracker.feedInitialConnections(conns, seenTuples, processesWaitingInAccept, report.MakeHostNodeID(t.conf.HostID))
 }
 
+// performEbpfTrack adds the connections seen by the eBPF tracker. The
+// tracer only emits events for established connections, so there are no
+// failed attempts to report here, but it sees connections come and go, so
+// it counts the connections opened and closed since the last report. It
+// doesn't see the states of sockets though, which are scanned for.
 func (t *connectionTracker) performEbpfTrack(rpt *report.Report, hostNodeID string) error {
 	t.ebpfTracker.walkConnections(func(e ebpfConnection) {
 		var toNodeInfo, fromNodeInfo map[string]string
@@ -203,12 +270,41 @@ func (t *connectionTracker) performEbpfTrack(rpt *report.Report, hostNodeID stri
 				report.HostNodeID: hostNodeID,
 			}
 		}
-		t.addConnection(rpt, e.incoming, e.tuple, e.networkNamespace, fromNodeInfo, toNodeInfo)
+		t.addConnection(rpt, e.incoming, !e.guessed, e.tuple, e.networkNamespace, fromNodeInfo, toNodeInfo)
+		t.addConnectionChurn(rpt, e)
 	})
+	if t.conf.WalkProc && t.conf.Scanner != nil {
+		return t.addSocketStates(rpt, hostNodeID)
+	}
 	return nil
 }
 
-func (t *connectionTracker) addConnection(rpt *report.Report, incoming bool, ft fourTuple, namespaceID string, extraFromNode, extraToNode map[string]string) {
+// addConnectionChurn counts the opening and closing of a connection on
+// its initiating endpoint, as with failures, so that edges of many
+// short-lived connections can be told apart from edges of long-lived ones.
+func (t *connectionTracker) addConnectionChurn(rpt *report.Report, e ebpfConnection) {
+	if !e.opened && !e.closed {
+		return
+	}
+	ft := e.tuple
+	if e.incoming {
+		ft = reverse(ft)
+	}
+	node := t.makeEndpointNode(e.networkNamespace, ft.fromAddr, ft.fromPort, nil)
+	if e.opened {
+		node.Counters = node.Counters.Add(report.ConnectionsOpened, 1)
+	}
+	if e.closed {
+		node.Counters = node.Counters.Add(report.ConnectionsClosed, 1)
+	}
+	rpt.Endpoint.AddNode(node)
+}
+
+// addConnection adds a connection, from its initiating endpoint. Unless
+// the direction was merely guessed, the endpoints are marked as having
+// initiated or accepted it, so the renderer can collapse the edges of
+// connections guessed the wrong way by other probes.
+func (t *connectionTracker) addConnection(rpt *report.Report, incoming, known bool, ft fourTuple, namespaceID string, extraFromNode, extraToNode map[string]string) {
 	if incoming {
 		ft = reverse(ft)
 		extraFromNode, extraToNode = extraToNode, extraFromNode
@@ -217,15 +313,61 @@ func (t *connectionTracker) addConnection(rpt *report.Report, incoming bool, ft
 		fromNode = t.makeEndpointNode(namespaceID, ft.fromAddr, ft.fromPort, extraFromNode)
 		toNode   = t.makeEndpointNode(namespaceID, ft.toAddr, ft.toPort, extraToNode)
 	)
+	if known {
+		fromNode = fromNode.WithLatest(report.Initiator, mtime.Now(), "true")
+		toNode = toNode.WithLatest(report.Initiator, mtime.Now(), "false")
+	}
 	rpt.Endpoint.AddNode(fromNode.WithAdjacent(toNode.ID))
 	rpt.Endpoint.AddNode(toNode)
 	t.addDNS(rpt, ft.fromAddr)
 	t.addDNS(rpt, ft.toAddr)
 }
 
+// addUDPConnection adds a connected UDP socket, like addConnection, but
+// with the IDs of UDP endpoints, so that they aren't merged with the TCP
+// endpoints on the same addresses and ports. Which end initiated it is
+// never known.
+func (t *connectionTracker) addUDPConnection(rpt *report.Report, incoming bool, ft fourTuple, namespaceID string, extraFromNode, extraToNode map[string]string) {
+	if incoming {
+		ft = reverse(ft)
+		extraFromNode, extraToNode = extraToNode, extraFromNode
+	}
+	var (
+		fromNode = t.makeUDPEndpointNode(namespaceID, ft.fromAddr, ft.fromPort, extraFromNode)
+		toNode   = t.makeUDPEndpointNode(namespaceID, ft.toAddr, ft.toPort, extraToNode)
+	)
+	rpt.Endpoint.AddNode(fromNode.WithAdjacent(toNode.ID))
+	rpt.Endpoint.AddNode(toNode)
+	t.addDNS(rpt, ft.fromAddr)
+	t.addDNS(rpt, ft.toAddr)
+}
+
+// addFailedConnection adds a connection which could not be established,
+// counting the failure on the initiating endpoint so the edge can be
+// told apart from healthy ones.
+func (t *connectionTracker) addFailedConnection(rpt *report.Report, ft fourTuple) {
+	var (
+		fromNode = t.makeEndpointNode("", ft.fromAddr, ft.fromPort, nil)
+		toNode   = t.makeEndpointNode("", ft.toAddr, ft.toPort, nil)
+	)
+	fromNode = fromNode.WithAdjacent(toNode.ID)
+	fromNode.Counters = fromNode.Counters.Add(report.ConnectionFailures, 1)
+	rpt.Endpoint.AddNode(fromNode)
+	rpt.Endpoint.AddNode(toNode)
+	t.addDNS(rpt, ft.fromAddr)
+	t.addDNS(rpt, ft.toAddr)
+}
+
 func (t *connectionTracker) makeEndpointNode(namespaceID string, addr string, port uint16, extra map[string]string) report.Node {
-	portStr := strconv.Itoa(int(port))
-	node := report.MakeNodeWith(report.MakeEndpointNodeID(t.conf.HostID, namespaceID, addr, portStr), nil)
+	return endpointNode(report.MakeEndpointNodeID(t.conf.HostID, namespaceID, addr, strconv.Itoa(int(port))), extra)
+}
+
+func (t *connectionTracker) makeUDPEndpointNode(namespaceID string, addr string, port uint16, extra map[string]string) report.Node {
+	return endpointNode(report.MakeUDPEndpointNodeID(t.conf.HostID, namespaceID, addr, strconv.Itoa(int(port))), extra)
+}
+
+func endpointNode(id string, extra map[string]string) report.Node {
+	node := report.MakeNodeWith(id, nil)
 	if extra != nil {
 		node = node.WithLatests(extra)
 	}
@@ -247,12 +389,16 @@ func (t *connectionTracker) addDNS(rpt *report.Report, addr string) {
 }
 
 func (t *connectionTracker) Stop() error {
+	t.cancel()
 	if t.ebpfTracker != nil {
 		t.ebpfTracker.stop()
 	}
 	if t.flowWalker != nil {
 		t.flowWalker.stop()
 	}
+	if t.ownScanner {
+		t.conf.Scanner.Stop()
+	}
 	t.reverseResolver.stop()
 	return nil
 }
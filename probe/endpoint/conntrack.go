@@ -13,8 +13,6 @@ import (
 	"time"
 	"unicode"
 
-	log "github.com/sirupsen/logrus"
-
 	"github.com/weaveworks/common/exec"
 )
 
@@ -23,6 +21,9 @@ const (
 	eventsPath = "sys/net/netfilter/nf_conntrack_events"
 
 	timeWait    = "TIME_WAIT"
+	synSent     = "SYN_SENT"
+	synRecv     = "SYN_RECV"
+	closed      = "CLOSE"
 	tcpProto    = "tcp"
 	newType     = "[NEW]"
 	updateType  = "[UPDATE]"
@@ -66,6 +67,7 @@ type conntrack struct {
 // method to walk them.
 type flowWalker interface {
 	walkFlows(f func(f flow, active bool))
+	walkFailedFlows(f func(f flow))
 	stop()
 }
 
@@ -73,6 +75,7 @@ type nilFlowWalker struct{}
 
 func (n nilFlowWalker) stop()                        {}
 func (n nilFlowWalker) walkFlows(f func(flow, bool)) {}
+func (n nilFlowWalker) walkFailedFlows(f func(flow)) {}
 
 // conntrackWalker uses the conntrack command to track network connections and
 // implement flowWalker.
@@ -81,6 +84,8 @@ type conntrackWalker struct {
 	cmd           exec.Cmd
 	activeFlows   map[int64]flow // active flows in state != TIME_WAIT
 	bufferedFlows []flow         // flows coming out of activeFlows spend 1 walk cycle here
+	attempts      map[int64]flow // flows which are not yet established
+	failedFlows   []flow         // attempts which were reset or timed out since the last walk
 	bufferSize    int
 	args          []string
 	quit          chan struct{}
@@ -96,6 +101,7 @@ func newConntrackFlowWalker(useConntrack bool, procRoot string, bufferSize int,
 	}
 	result := &conntrackWalker{
 		activeFlows: map[int64]flow{},
+		attempts:    map[int64]flow{},
 		bufferSize:  bufferSize,
 		args:        args,
 		quit:        make(chan struct{}),
@@ -145,6 +151,7 @@ func (c *conntrackWalker) clearFlows() {
 	}
 
 	c.activeFlows = map[int64]flow{}
+	c.attempts = map[int64]flow{}
 }
 
 func logPipe(prefix string, reader io.Reader) {
@@ -443,6 +450,10 @@ func (c *conntrackWalker) handleFlow(f flow, forceAdd bool) {
 		return
 	}
 
+	if c.trackAttempt(f) {
+		return
+	}
+
 	// Ignore flows for which we never saw an update; they are likely
 	// incomplete or wrong.  See #1462.
 	switch {
@@ -461,6 +472,44 @@ func (c *conntrackWalker) handleFlow(f flow, forceAdd bool) {
 	}
 }
 
+// trackAttempt follows connections from their first SYN, and records
+// those which are reset or destroyed before they are established as
+// failed, returning true if f is such a failure. Must be called with the
+// lock held.
+func (c *conntrackWalker) trackAttempt(f flow) bool {
+	id := f.Independent.ID
+	switch f.Type {
+	case newType:
+		if f.Independent.State == synSent {
+			c.attempts[id] = f
+		}
+	case updateType:
+		attempt, ok := c.attempts[id]
+		if !ok {
+			return false
+		}
+		switch f.Independent.State {
+		case synSent, synRecv:
+		case closed:
+			// The SYN was answered with a RST
+			delete(c.attempts, id)
+			delete(c.activeFlows, id)
+			c.failedFlows = append(c.failedFlows, attempt)
+			return true
+		default:
+			delete(c.attempts, id)
+		}
+	case destroyType:
+		if attempt, ok := c.attempts[id]; ok {
+			// The SYN was never answered, and conntrack gave up on it
+			delete(c.attempts, id)
+			c.failedFlows = append(c.failedFlows, attempt)
+			return true
+		}
+	}
+	return false
+}
+
 // walkFlows calls f with all active flows and flows that have come and gone
 // since the last call to walkFlows
 func (c *conntrackWalker) walkFlows(f func(flow, bool)) {
@@ -474,3 +523,14 @@ func (c *conntrackWalker) walkFlows(f func(flow, bool)) {
 	}
 	c.bufferedFlows = c.bufferedFlows[:0]
 }
+
+// walkFailedFlows calls f with all connection attempts which have failed
+// since the last call to walkFailedFlows
+func (c *conntrackWalker) walkFailedFlows(f func(flow)) {
+	c.Lock()
+	defer c.Unlock()
+	for _, flow := range c.failedFlows {
+		f(flow)
+	}
+	c.failedFlows = c.failedFlows[:0]
+}
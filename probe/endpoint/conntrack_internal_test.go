@@ -3,6 +3,7 @@ package endpoint
 import (
 	"bufio"
 	"io"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -251,3 +252,52 @@ var wantDumpedFlows = []flow{
 func TestDumpedFlowDecoding(t *testing.T) {
 	testFlowDecoding(t, dumpedFlowsSource, wantDumpedFlows, decodeDumpedFlow)
 }
+
+// Obtained though conntrack -E -p tcp -o id: one connection refused with a
+// RST, one timing out without a reply and one established.
+const failedFlowsSource = `    [NEW] tcp      6 120 SYN_SENT src=10.0.0.1 dst=10.0.0.2 sport=40001 dport=80 [UNREPLIED] src=10.0.0.2 dst=10.0.0.1 sport=80 dport=40001 id=1
+ [UPDATE] tcp      6 10 CLOSE src=10.0.0.1 dst=10.0.0.2 sport=40001 dport=80 src=10.0.0.2 dst=10.0.0.1 sport=80 dport=40001 id=1
+    [NEW] tcp      6 120 SYN_SENT src=10.0.0.1 dst=10.0.0.3 sport=40002 dport=80 [UNREPLIED] src=10.0.0.3 dst=10.0.0.1 sport=80 dport=40002 id=2
+    [NEW] tcp      6 120 SYN_SENT src=10.0.0.1 dst=10.0.0.4 sport=40003 dport=80 [UNREPLIED] src=10.0.0.4 dst=10.0.0.1 sport=80 dport=40003 id=3
+ [UPDATE] tcp      6 60 SYN_RECV src=10.0.0.1 dst=10.0.0.4 sport=40003 dport=80 src=10.0.0.4 dst=10.0.0.1 sport=80 dport=40003 id=3
+ [UPDATE] tcp      6 432000 ESTABLISHED src=10.0.0.1 dst=10.0.0.4 sport=40003 dport=80 src=10.0.0.4 dst=10.0.0.1 sport=80 dport=40003 [ASSURED] id=3
+[DESTROY] tcp      6 src=10.0.0.1 dst=10.0.0.2 sport=40001 dport=80 src=10.0.0.2 dst=10.0.0.1 sport=80 dport=40001 id=1
+[DESTROY] tcp      6 src=10.0.0.1 dst=10.0.0.3 sport=40002 dport=80 [UNREPLIED] src=10.0.0.3 dst=10.0.0.1 sport=80 dport=40002 id=2
+[DESTROY] tcp      6 src=10.0.0.1 dst=10.0.0.4 sport=40003 dport=80 src=10.0.0.4 dst=10.0.0.1 sport=80 dport=40003 [ASSURED] id=3`
+
+func TestFailedFlows(t *testing.T) {
+	c := &conntrackWalker{
+		activeFlows: map[int64]flow{},
+		attempts:    map[int64]flow{},
+	}
+	scanner := bufio.NewScanner(strings.NewReader(failedFlowsSource))
+	for {
+		f, err := decodeStreamedFlow(scanner)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Unexpected decoding error: %v", err)
+		}
+		c.handleFlow(f, false)
+	}
+
+	failed := []string{}
+	c.walkFailedFlows(func(f flow) {
+		failed = append(failed, f.Original.Layer3.DstIP)
+	})
+	if want := []string{"10.0.0.2", "10.0.0.3"}; !reflect.DeepEqual(want, failed) {
+		t.Errorf("want %v, have %v", want, failed)
+	}
+
+	active := []string{}
+	c.walkFlows(func(f flow, _ bool) {
+		active = append(active, f.Original.Layer3.DstIP)
+	})
+	if want := []string{"10.0.0.4"}; !reflect.DeepEqual(want, active) {
+		t.Errorf("want %v, have %v", want, active)
+	}
+
+	c.walkFailedFlows(func(f flow) {
+		t.Errorf("Unexpected failed flow: %v", f)
+	})
+}
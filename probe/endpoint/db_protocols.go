@@ -0,0 +1,596 @@
+package endpoint
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+)
+
+// Counters of the queries database clients made, and of the errors the
+// databases replied with, in reports made from their traffic.
+const (
+	Queries     = "queries"
+	QueryErrors = "query_errors"
+)
+
+// Bounds of the messages of the wire protocols, past which traffic is
+// assumed not to be of the protocol. Of each message, no more than its
+// first maxWireHeaderLen bytes are buffered.
+const (
+	maxStartupMessageLen = 10000
+	maxWireMessageLen    = 48 * 1000 * 1000
+	maxWireHeaderLen     = 4 * 1024
+)
+
+// A wireProtocol reads both sides of a connection, counting the queries
+// of the client and the errors of the server as long as the traffic is
+// of its protocol.
+type wireProtocol interface {
+	name() string
+	// feed reads traffic of the client, or of the server, returning
+	// false once it isn't of the protocol.
+	feed(fromClient bool, payload []byte) bool
+	// identified is whether the traffic bears the signature of the
+	// protocol, as opposed to not being inconsistent with it yet.
+	identified() bool
+	counts() (queries, errors int)
+}
+
+// wireDetector tells which database protocol a connection speaks from
+// its traffic, by ruling out the protocols it isn't.
+type wireDetector struct {
+	candidates []wireProtocol
+	lost       bool
+}
+
+func newWireDetector() wireDetector {
+	return wireDetector{candidates: []wireProtocol{
+		&postgresProtocol{},
+		&mysqlProtocol{},
+		&redisProtocol{},
+		&mongoProtocol{},
+	}}
+}
+
+func (d *wireDetector) feed(fromClient bool, payload []byte) {
+	if d.lost {
+		return
+	}
+	candidates := d.candidates[:0]
+	for _, p := range d.candidates {
+		if p.feed(fromClient, payload) {
+			candidates = append(candidates, p)
+		}
+	}
+	d.candidates = candidates
+}
+
+// skipped is told traffic of the connection was lost. The messages after
+// can't be told apart, so the protocol identified, if any, is read no
+// further, and none is identified after.
+func (d *wireDetector) skipped() {
+	d.lost = true
+}
+
+// protocol returns the protocol identified, or nil.
+func (d *wireDetector) protocol() wireProtocol {
+	for _, p := range d.candidates {
+		if p.identified() {
+			return p
+		}
+	}
+	return nil
+}
+
+// wireStream is the traffic of a direction of a connection, read a
+// message at a time. Of messages longer than maxWireHeaderLen, only the
+// start is buffered: the rest is skipped as it comes in.
+type wireStream struct {
+	buf  []byte
+	skip int
+}
+
+func (s *wireStream) write(payload []byte) {
+	n := s.skip
+	if n > len(payload) {
+		n = len(payload)
+	}
+	s.skip -= n
+	s.buf = append(s.buf, payload[n:]...)
+}
+
+// next splits the message of the length off the stream, once it, or the
+// first maxWireHeaderLen bytes of it, are buffered.
+func (s *wireStream) next(length int) ([]byte, bool) {
+	if len(s.buf) < length && len(s.buf) < maxWireHeaderLen {
+		return nil, false
+	}
+	msg := s.buf
+	if len(msg) > length {
+		msg = msg[:length]
+	}
+	s.discard(length)
+	return msg, true
+}
+
+// discard drops the next n bytes of the stream, buffered or not.
+func (s *wireStream) discard(n int) {
+	if n <= len(s.buf) {
+		s.buf = s.buf[n:]
+		return
+	}
+	s.skip += n - len(s.buf)
+	s.buf = nil
+}
+
+// postgresProtocol reads the PostgreSQL frontend/backend protocol 3.0:
+// after a startup message, messages of a type byte and a length. Simple
+// queries and the preparation of extended ones are counted as queries.
+type postgresProtocol struct {
+	client, server  wireStream
+	started         bool
+	sslRequested    bool
+	sslAnswered     bool
+	encrypted       bool
+	queries, errors int
+}
+
+const (
+	postgresProtocolVersion = 196608 // 3.0
+	postgresSSLRequest      = 80877103
+	postgresGSSENCRequest   = 80877104
+	postgresFrontendTypes   = "BCdcfDEHFpPQSX"
+	postgresBackendTypes    = "RKSZTDCEIN123ntsGHWAcdVv"
+)
+
+func (p *postgresProtocol) name() string                  { return "postgres" }
+func (p *postgresProtocol) identified() bool              { return p.started }
+func (p *postgresProtocol) counts() (queries, errors int) { return p.queries, p.errors }
+
+func (p *postgresProtocol) feed(fromClient bool, payload []byte) bool {
+	if p.encrypted {
+		return true
+	}
+	if fromClient {
+		p.client.write(payload)
+		return p.readClient()
+	}
+	p.server.write(payload)
+	return p.readServer()
+}
+
+func (p *postgresProtocol) readClient() bool {
+	for !p.started {
+		buf := p.client.buf
+		if len(buf) < 8 {
+			return true
+		}
+		length, code := int(binary.BigEndian.Uint32(buf)), binary.BigEndian.Uint32(buf[4:])
+		if length < 8 || length > maxStartupMessageLen {
+			return false
+		}
+		switch code {
+		case postgresSSLRequest, postgresGSSENCRequest:
+			if length != 8 {
+				return false
+			}
+			p.sslRequested = true
+		case postgresProtocolVersion:
+			p.started = true
+		default:
+			return false
+		}
+		p.client.discard(length)
+	}
+	// Only the types of the messages are read
+	for buf := p.client.buf; len(buf) >= 5; buf = p.client.buf {
+		typ, length := buf[0], int(binary.BigEndian.Uint32(buf[1:]))
+		if bytes.IndexByte([]byte(postgresFrontendTypes), typ) < 0 || length < 4 || length > maxWireMessageLen {
+			return false
+		}
+		if typ == 'Q' || typ == 'P' {
+			p.queries++
+		}
+		p.client.discard(1 + length)
+	}
+	return true
+}
+
+func (p *postgresProtocol) readServer() bool {
+	if p.sslRequested && !p.sslAnswered && len(p.server.buf) > 0 {
+		switch p.server.buf[0] {
+		case 'S', 'G':
+			// The rest is encrypted; the startup message, sent in the
+			// clear otherwise, won't be seen.
+			p.encrypted, p.started = true, true
+			p.client, p.server = wireStream{}, wireStream{}
+			return true
+		case 'N':
+			p.sslAnswered = true
+			p.server.discard(1)
+		default:
+			return false
+		}
+	}
+	for buf := p.server.buf; len(buf) >= 5; buf = p.server.buf {
+		typ, length := buf[0], int(binary.BigEndian.Uint32(buf[1:]))
+		if bytes.IndexByte([]byte(postgresBackendTypes), typ) < 0 || length < 4 || length > maxWireMessageLen {
+			return false
+		}
+		if typ == 'E' {
+			p.errors++
+		}
+		p.server.discard(1 + length)
+	}
+	return true
+}
+
+// mysqlProtocol reads the MySQL client/server protocol: packets of a
+// 3-byte length and a sequence number, starting with the handshake of
+// the server. Text queries and prepared statements are counted as
+// queries, ERR packets as errors.
+type mysqlProtocol struct {
+	client, server  wireStream
+	handshake       bool
+	responded       bool
+	encrypted       bool
+	queries, errors int
+}
+
+const (
+	mysqlHandshakeV10  = 0x0a
+	mysqlComQuery      = 0x03
+	mysqlComPrepare    = 0x16
+	mysqlComExecute    = 0x17
+	mysqlErrPacket     = 0xff
+	mysqlClientSSL     = 0x800
+	mysqlSSLRequestLen = 32
+)
+
+func (p *mysqlProtocol) name() string                  { return "mysql" }
+func (p *mysqlProtocol) identified() bool              { return p.handshake }
+func (p *mysqlProtocol) counts() (queries, errors int) { return p.queries, p.errors }
+
+// mysqlPacket splits the next packet off the stream, or the start of it
+// if long.
+func mysqlPacket(s *wireStream) (seq byte, payload []byte, ok bool) {
+	if len(s.buf) < 4 {
+		return 0, nil, false
+	}
+	length, seq := int(s.buf[0])|int(s.buf[1])<<8|int(s.buf[2])<<16, s.buf[3]
+	packet, ok := s.next(4 + length)
+	if !ok {
+		return 0, nil, false
+	}
+	return seq, packet[4:], true
+}
+
+func (p *mysqlProtocol) feed(fromClient bool, payload []byte) bool {
+	if p.encrypted {
+		return true
+	}
+	if fromClient {
+		// The server speaks first
+		if !p.handshake {
+			return false
+		}
+		p.client.write(payload)
+		for {
+			seq, packet, ok := mysqlPacket(&p.client)
+			if !ok {
+				return true
+			}
+			if !p.responded {
+				p.responded = true
+				if len(packet) == mysqlSSLRequestLen && binary.LittleEndian.Uint32(packet)&mysqlClientSSL != 0 {
+					p.encrypted = true
+					p.client, p.server = wireStream{}, wireStream{}
+					return true
+				}
+				continue
+			}
+			// Commands start new sequences
+			if seq == 0 && len(packet) > 0 {
+				switch packet[0] {
+				case mysqlComQuery, mysqlComPrepare, mysqlComExecute:
+					p.queries++
+				}
+			}
+		}
+	}
+
+	p.server.write(payload)
+	for {
+		seq, packet, ok := mysqlPacket(&p.server)
+		if !ok {
+			return p.handshake || len(p.server.buf) < 4 || p.server.buf[3] == 0
+		}
+		if !p.handshake {
+			if seq != 0 || len(packet) < 2 || packet[0] != mysqlHandshakeV10 || bytes.IndexByte(packet[1:], 0) < 0 {
+				return false
+			}
+			p.handshake = true
+			continue
+		}
+		// No rows nor column definitions start with 0xff
+		if len(packet) > 0 && packet[0] == mysqlErrPacket {
+			p.errors++
+		}
+	}
+}
+
+// redisProtocol reads RESP, the Redis serialization protocol: clients
+// send commands as arrays of bulk strings, each counted as a query, and
+// servers reply with values, error values being counted as errors.
+type redisProtocol struct {
+	client, server  respStream
+	commands        bool
+	queries, errors int
+}
+
+func (p *redisProtocol) name() string                  { return "redis" }
+func (p *redisProtocol) identified() bool              { return p.commands }
+func (p *redisProtocol) counts() (queries, errors int) { return p.queries, p.errors }
+
+func (p *redisProtocol) feed(fromClient bool, payload []byte) bool {
+	if fromClient {
+		p.client.write(payload)
+		return p.client.read(func(typ byte) bool {
+			if typ != '*' {
+				return false
+			}
+			p.commands = true
+			p.queries++
+			return true
+		})
+	}
+	p.server.write(payload)
+	return p.server.read(func(typ byte) bool {
+		if typ == '-' || typ == '!' {
+			p.errors++
+		}
+		return true
+	})
+}
+
+// respTypes are the types of the values of RESP 2 and 3.
+const respTypes = "+-:_,#($!=*~>%"
+
+// respStream reads the RESP values of a direction of a connection a line
+// at a time, skipping the contents of bulk strings.
+type respStream struct {
+	wireStream
+	// The elements left to read of each aggregate value being read
+	open []int
+}
+
+// read reads the lines buffered, telling value the type of each value at
+// the top level as it starts, and returns false once either isn't RESP.
+func (s *respStream) read(value func(typ byte) bool) bool {
+	for len(s.buf) > 0 {
+		if bytes.IndexByte([]byte(respTypes), s.buf[0]) < 0 {
+			return false
+		}
+		end := bytes.Index(s.buf, []byte("\r\n"))
+		if end < 0 {
+			return len(s.buf) <= maxWireHeaderLen
+		}
+		if end < 1 || end > maxWireHeaderLen {
+			return false
+		}
+		typ, line := s.buf[0], s.buf[1:end]
+		if len(s.open) == 0 && !value(typ) {
+			return false
+		}
+		s.discard(end + 2)
+
+		elements := 0
+		switch typ {
+		case '$', '!', '=':
+			length, ok := respLength(line)
+			if !ok || length > maxWireMessageLen {
+				return false
+			}
+			if length >= 0 {
+				s.discard(length + 2)
+			}
+		case '*', '~', '>', '%':
+			count, ok := respLength(line)
+			if !ok {
+				return false
+			}
+			if typ == '%' {
+				count *= 2
+			}
+			elements = count
+		}
+		if elements > 0 {
+			if len(s.open) >= 8 {
+				return false
+			}
+			s.open = append(s.open, elements)
+			continue
+		}
+		// The value read completes an element of the aggregate it is of,
+		// which may complete the aggregate
+		for len(s.open) > 0 {
+			if s.open[len(s.open)-1]--; s.open[len(s.open)-1] > 0 {
+				break
+			}
+			s.open = s.open[:len(s.open)-1]
+		}
+	}
+	return true
+}
+
+func respLength(line []byte) (int, bool) {
+	if len(line) == 2 && line[0] == '-' && line[1] == '1' {
+		return -1, true
+	}
+	if len(line) == 0 || len(line) > 10 {
+		return 0, false
+	}
+	n := 0
+	for _, c := range line {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n, true
+}
+
+// mongoProtocol reads the MongoDB wire protocol: messages of a 16 byte
+// header of their length, IDs and operation. Queries and commands are
+// counted as queries, replies failing or not ok as errors, as far as
+// told by their first maxWireHeaderLen bytes.
+type mongoProtocol struct {
+	client, server  wireStream
+	requests        bool
+	queries, errors int
+}
+
+const (
+	mongoHeaderLen   = 16
+	mongoOpReply     = 1
+	mongoOpQuery     = 2004
+	mongoOpMsg       = 2013
+	mongoOpCommand   = 2010
+	mongoQueryFailed = 0x2
+)
+
+func (p *mongoProtocol) name() string                  { return "mongodb" }
+func (p *mongoProtocol) identified() bool              { return p.requests }
+func (p *mongoProtocol) counts() (queries, errors int) { return p.queries, p.errors }
+
+func isMongoOp(op uint32) bool {
+	return op == mongoOpReply || (op >= 2001 && op <= 2013 && op != 2003 && op != 2008 && op != 2009)
+}
+
+func (p *mongoProtocol) feed(fromClient bool, payload []byte) bool {
+	s := &p.server
+	if fromClient {
+		s = &p.client
+	}
+	s.write(payload)
+	for len(s.buf) >= mongoHeaderLen {
+		var (
+			length     = int(binary.LittleEndian.Uint32(s.buf))
+			responseTo = binary.LittleEndian.Uint32(s.buf[8:])
+			op         = binary.LittleEndian.Uint32(s.buf[12:])
+		)
+		if length < mongoHeaderLen || length > maxWireMessageLen || !isMongoOp(op) || fromClient != (responseTo == 0) {
+			return false
+		}
+		msg, ok := s.next(length)
+		if !ok {
+			return true
+		}
+		if fromClient {
+			p.requests = true
+			if op == mongoOpQuery || op == mongoOpMsg || op == mongoOpCommand {
+				p.queries++
+			}
+		} else if mongoReplyFailed(op, msg[mongoHeaderLen:]) {
+			p.errors++
+		}
+	}
+	return true
+}
+
+// mongoReplyFailed tells whether a reply reports an error: legacy
+// replies by flag, others by the ok field of their body.
+func mongoReplyFailed(op uint32, body []byte) bool {
+	switch op {
+	case mongoOpReply:
+		return len(body) >= 4 && binary.LittleEndian.Uint32(body)&mongoQueryFailed != 0
+	case mongoOpMsg:
+		// Flags, then a section of kind 0 holding the body document
+		if len(body) < 5 || body[4] != 0 {
+			return false
+		}
+		ok, found := bsonOK(body[5:])
+		return found && !ok
+	}
+	return false
+}
+
+// bsonOK looks up the numeric or boolean ok field of a BSON document.
+func bsonOK(doc []byte) (ok bool, found bool) {
+	if len(doc) < 5 {
+		return false, false
+	}
+	if length := int(binary.LittleEndian.Uint32(doc)); length <= len(doc) {
+		doc = doc[:length]
+	}
+	for i := 4; i < len(doc) && doc[i] != 0; {
+		typ := doc[i]
+		end := bytes.IndexByte(doc[i+1:], 0)
+		if end < 0 {
+			return false, false
+		}
+		name, value := string(doc[i+1:i+1+end]), doc[i+2+end:]
+		size := bsonValueLen(typ, value)
+		if size < 0 || size > len(value) {
+			return false, false
+		}
+		if name == "ok" {
+			switch typ {
+			case 0x01: // double
+				return math.Float64frombits(binary.LittleEndian.Uint64(value)) != 0, true
+			case 0x08: // boolean
+				return value[0] != 0, true
+			case 0x10: // int32
+				return binary.LittleEndian.Uint32(value) != 0, true
+			case 0x12: // int64
+				return binary.LittleEndian.Uint64(value) != 0, true
+			}
+			return false, false
+		}
+		i += 2 + end + size
+	}
+	return false, false
+}
+
+// bsonValueLen is the length of a BSON value of a type, or -1.
+func bsonValueLen(typ byte, value []byte) int {
+	prefixed := func(extra int) int {
+		if len(value) < 4 {
+			return -1
+		}
+		return 4 + int(binary.LittleEndian.Uint32(value)) + extra
+	}
+	switch typ {
+	case 0x06, 0x0A, 0x7F, 0xFF: // undefined, null, max key, min key
+		return 0
+	case 0x08: // boolean
+		return 1
+	case 0x10: // int32
+		return 4
+	case 0x01, 0x09, 0x11, 0x12: // double, datetime, timestamp, int64
+		return 8
+	case 0x07: // object ID
+		return 12
+	case 0x13: // decimal128
+		return 16
+	case 0x02, 0x0D, 0x0E: // string, JavaScript, symbol
+		return prefixed(0)
+	case 0x03, 0x04, 0x0F: // document, array, code with scope
+		return prefixed(-4)
+	case 0x05: // binary
+		return prefixed(1)
+	case 0x0C: // DB pointer
+		return prefixed(12)
+	case 0x0B: // regular expression
+		first := bytes.IndexByte(value, 0)
+		if first < 0 {
+			return -1
+		}
+		second := bytes.IndexByte(value[first+1:], 0)
+		if second < 0 {
+			return -1
+		}
+		return first + second + 2
+	}
+	return -1
+}
@@ -0,0 +1,245 @@
+package endpoint
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+func postgresMessage(typ byte, body string) []byte {
+	msg := []byte{typ, 0, 0, 0, 0}
+	binary.BigEndian.PutUint32(msg[1:], uint32(4+len(body)))
+	return append(msg, body...)
+}
+
+func postgresStartup() []byte {
+	body := "user\x00scope\x00\x00"
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint32(msg, uint32(8+len(body)))
+	binary.BigEndian.PutUint32(msg[4:], postgresProtocolVersion)
+	return append(msg, body...)
+}
+
+func mysqlPacketOf(seq byte, payload string) []byte {
+	return append([]byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), seq}, payload...)
+}
+
+func mongoMessage(responseTo, op uint32, body []byte) []byte {
+	msg := make([]byte, mongoHeaderLen)
+	binary.LittleEndian.PutUint32(msg, uint32(mongoHeaderLen+len(body)))
+	binary.LittleEndian.PutUint32(msg[8:], responseTo)
+	binary.LittleEndian.PutUint32(msg[12:], op)
+	return append(msg, body...)
+}
+
+// mongoReply is an OP_MSG reply with a body document {ok: <ok>}.
+func mongoReply(ok float64) []byte {
+	doc := []byte{0, 0, 0, 0, 0x01, 'o', 'k', 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	if ok != 0 {
+		binary.LittleEndian.PutUint64(doc[8:], 0x3ff0000000000000) // 1.0
+	}
+	binary.LittleEndian.PutUint32(doc, uint32(len(doc)))
+	return mongoMessage(1, mongoOpMsg, append([]byte{0, 0, 0, 0, 0}, doc...))
+}
+
+type wirePacket struct {
+	fromClient bool
+	payload    []byte
+}
+
+func TestWireDetector(t *testing.T) {
+	for _, tc := range []struct {
+		protocol        string
+		packets         []wirePacket
+		queries, errors int
+	}{
+		{
+			protocol: "postgres",
+			packets: []wirePacket{
+				{true, postgresStartup()},
+				{false, append(postgresMessage('R', "\x00\x00\x00\x00"), postgresMessage('Z', "I")...)},
+				// A query split across packets
+				{true, postgresMessage('Q', "SELECT 1\x00")[:4]},
+				{true, postgresMessage('Q', "SELECT 1\x00")[4:]},
+				{false, append(postgresMessage('T', "..."), postgresMessage('Z', "I")...)},
+				{true, postgresMessage('Q', "SELECT nope\x00")},
+				{false, append(postgresMessage('E', "SERROR\x00\x00"), postgresMessage('Z', "I")...)},
+			},
+			queries: 2,
+			errors:  1,
+		},
+		{
+			protocol: "mysql",
+			packets: []wirePacket{
+				{false, mysqlPacketOf(0, "\x0a5.7.0\x00...")},
+				{true, mysqlPacketOf(1, "handshake response of more than thirty-two bytes")},
+				{false, mysqlPacketOf(2, "\x00\x00\x00\x02\x00\x00\x00")},
+				{true, mysqlPacketOf(0, "\x03SELECT 1")},
+				{false, mysqlPacketOf(1, "\x01")},
+				{true, mysqlPacketOf(0, "\x03SELECT nope")},
+				{false, mysqlPacketOf(1, "\xff\x28\x04#42000syntax error")},
+			},
+			queries: 2,
+			errors:  1,
+		},
+		{
+			protocol: "redis",
+			packets: []wirePacket{
+				{true, []byte("*2\r\n$3\r\nGET\r\n$3\r\nfoo\r\n*1\r\n$4\r\nP")},
+				{true, []byte("ING\r\n")},
+				{false, []byte("$3\r\nbar\r\n+PONG\r\n")},
+				{true, []byte("*1\r\n$4\r\nNOPE\r\n")},
+				{false, []byte("-ERR unknown command\r\n")},
+			},
+			queries: 3,
+			errors:  1,
+		},
+		{
+			protocol: "mongodb",
+			packets: []wirePacket{
+				{true, mongoMessage(0, mongoOpMsg, []byte("..."))},
+				{false, mongoReply(1)},
+				{true, mongoMessage(0, mongoOpMsg, []byte("..."))},
+				{false, mongoReply(0)},
+			},
+			queries: 2,
+			errors:  1,
+		},
+	} {
+		detector := newWireDetector()
+		for _, p := range tc.packets {
+			detector.feed(p.fromClient, p.payload)
+		}
+		protocol := detector.protocol()
+		if protocol == nil {
+			t.Errorf("%s: not detected", tc.protocol)
+			continue
+		}
+		if protocol.name() != tc.protocol {
+			t.Errorf("%s: detected %s", tc.protocol, protocol.name())
+			continue
+		}
+		if queries, errors := protocol.counts(); queries != tc.queries || errors != tc.errors {
+			t.Errorf("%s: expected %d queries and %d errors, got %d and %d", tc.protocol, tc.queries, tc.errors, queries, errors)
+		}
+	}
+}
+
+func TestWireDetectorOtherProtocols(t *testing.T) {
+	for _, payload := range []string{
+		"GET / HTTP/1.1\r\nHost: example.com\r\n\r\n",
+		"PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n",
+		"\x16\x03\x01\x02\x00\x01\x00\x01\xfc\x03\x03random bytes of a TLS client hello",
+		"SSH-2.0-OpenSSH_8.0\r\n",
+	} {
+		detector := newWireDetector()
+		detector.feed(true, []byte(payload))
+		if protocol := detector.protocol(); protocol != nil {
+			t.Errorf("%q: detected %s", payload, protocol.name())
+		}
+		if len(detector.candidates) != 0 {
+			t.Errorf("%q: not ruled out: %v", payload, detector.candidates)
+		}
+	}
+}
+
+func TestWireDetectorLongMessages(t *testing.T) {
+	var (
+		value   = bytes.Repeat([]byte("x"), 1000*1000)
+		mongoOK = mongoReply(1)
+	)
+	for _, tc := range []struct {
+		protocol string
+		packets  []wirePacket
+	}{
+		{
+			protocol: "postgres",
+			packets: []wirePacket{
+				{true, postgresStartup()},
+				{false, postgresMessage('Z', "I")},
+				{true, postgresMessage('Q', "INSERT "+string(value)+"\x00")},
+				{false, postgresMessage('E', "SERROR\x00\x00")},
+			},
+		},
+		{
+			protocol: "mysql",
+			packets: []wirePacket{
+				{false, mysqlPacketOf(0, "\x0a5.7.0\x00...")},
+				{true, mysqlPacketOf(1, "handshake response of more than thirty-two bytes")},
+				{true, mysqlPacketOf(0, "\x03INSERT "+string(value))},
+				{false, mysqlPacketOf(1, "\xff\x28\x04#42000syntax error")},
+			},
+		},
+		{
+			protocol: "redis",
+			packets: []wirePacket{
+				{true, []byte(fmt.Sprintf("*3\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$%d\r\n%s\r\n", len(value), value))},
+				{false, []byte("-ERR out of memory\r\n")},
+			},
+		},
+		{
+			protocol: "mongodb",
+			packets: []wirePacket{
+				{true, mongoMessage(0, mongoOpMsg, value)},
+				{false, mongoReply(0)},
+				{false, append(mongoOK[:len(mongoOK):len(mongoOK)], mongoMessage(1, mongoOpMsg, value)...)},
+			},
+		},
+	} {
+		detector := newWireDetector()
+		for _, p := range tc.packets {
+			// Fed in segments, as reassembled
+			for payload := p.payload; len(payload) > 0; {
+				n := 1400
+				if n > len(payload) {
+					n = len(payload)
+				}
+				detector.feed(p.fromClient, payload[:n])
+				payload = payload[n:]
+				for _, candidate := range detector.candidates {
+					if buffered := wireBuffered(candidate); buffered > maxWireHeaderLen+n {
+						t.Fatalf("%s: %s buffers %d bytes", tc.protocol, candidate.name(), buffered)
+					}
+				}
+			}
+		}
+		protocol := detector.protocol()
+		if protocol == nil || protocol.name() != tc.protocol {
+			t.Errorf("%s: not detected", tc.protocol)
+			continue
+		}
+		if queries, errors := protocol.counts(); queries != 1 || errors != 1 {
+			t.Errorf("%s: expected 1 query and 1 error, got %d and %d", tc.protocol, queries, errors)
+		}
+	}
+}
+
+func wireBuffered(p wireProtocol) int {
+	switch p := p.(type) {
+	case *postgresProtocol:
+		return len(p.client.buf) + len(p.server.buf)
+	case *mysqlProtocol:
+		return len(p.client.buf) + len(p.server.buf)
+	case *redisProtocol:
+		return len(p.client.buf) + len(p.server.buf)
+	case *mongoProtocol:
+		return len(p.client.buf) + len(p.server.buf)
+	}
+	return 0
+}
+
+func TestWireDetectorSkipped(t *testing.T) {
+	detector := newWireDetector()
+	detector.feed(true, postgresStartup())
+	detector.feed(true, postgresMessage('Q', "SELECT 1\x00"))
+	detector.skipped()
+	detector.feed(true, postgresMessage('Q', "SELECT 1\x00")[3:])
+	protocol := detector.protocol()
+	if protocol == nil || protocol.name() != "postgres" {
+		t.Fatal("expected postgres, detected before the traffic lost")
+	}
+	if queries, _ := protocol.counts(); queries != 1 {
+		t.Errorf("expected the query before the traffic lost only, got %d", queries)
+	}
+}
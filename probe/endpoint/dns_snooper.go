@@ -16,7 +16,6 @@ import (
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
 	"github.com/google/gopacket/pcap"
-	log "github.com/sirupsen/logrus"
 )
 
 const (
@@ -38,7 +37,7 @@ type DNSSnooper struct {
 
 // NewDNSSnooper creates a new snooper of DNS queries
 func NewDNSSnooper() (*DNSSnooper, error) {
-	pcapHandle, err := newPcapHandle()
+	pcapHandle, err := newPcapHandle(pcap.DirectionIn, "inbound and port 53")
 	if err != nil {
 		return nil, err
 	}
@@ -54,7 +53,9 @@ func NewDNSSnooper() (*DNSSnooper, error) {
 	return s, nil
 }
 
-func newPcapHandle() (*pcap.Handle, error) {
+// newPcapHandle captures the packets of the filter, in the direction, on
+// every interface.
+func newPcapHandle(direction pcap.Direction, filter string) (*pcap.Handle, error) {
 	inactive, err := pcap.NewInactiveHandle("any")
 	if err != nil {
 		return nil, err
@@ -85,11 +86,11 @@ func newPcapHandle() (*pcap.Handle, error) {
 	if err != nil {
 		return nil, err
 	}
-	if err := pcapHandle.SetDirection(pcap.DirectionIn); err != nil {
+	if err := pcapHandle.SetDirection(direction); err != nil {
 		pcapHandle.Close()
 		return nil, err
 	}
-	if err := pcapHandle.SetBPFFilter("inbound and port 53"); err != nil {
+	if err := pcapHandle.SetBPFFilter(filter); err != nil {
 		pcapHandle.Close()
 		return nil, err
 	}
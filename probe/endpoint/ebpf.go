@@ -11,7 +11,6 @@ import (
 	"sync"
 	"syscall"
 
-	log "github.com/sirupsen/logrus"
 	"github.com/weaveworks/common/fs"
 	"github.com/weaveworks/scope/probe/endpoint/procspy"
 	"github.com/weaveworks/scope/probe/host"
@@ -24,7 +23,14 @@ type ebpfConnection struct {
 	tuple            fourTuple
 	networkNamespace string
 	incoming         bool
-	pid              int
+	// guessed is set when the direction was guessed from the ports, for
+	// connections open before the tracker started which conntrack missed
+	guessed bool
+	pid     int
+	// opened and closed are set when the connection was opened or closed
+	// since the last call to walkConnections
+	opened bool
+	closed bool
 }
 
 // EbpfTracker contains the sets of open and closed TCP connections.
@@ -255,6 +261,7 @@ func (t *EbpfTracker) handleFdInstall(ev tracer.EventType, pid int, fd int) {
 		tuple:            tuple,
 		pid:              pid,
 		networkNamespace: netns,
+		opened:           true,
 	}
 }
 
@@ -272,6 +279,7 @@ func (t *EbpfTracker) handleConnection(ev tracer.EventType, tuple fourTuple, pid
 			tuple:            tuple,
 			pid:              pid,
 			networkNamespace: networkNamespace,
+			opened:           true,
 		}
 	case tracer.EventAccept:
 		t.openConnections[tuple] = ebpfConnection{
@@ -279,6 +287,7 @@ func (t *EbpfTracker) handleConnection(ev tracer.EventType, tuple fourTuple, pid
 			tuple:            tuple,
 			pid:              pid,
 			networkNamespace: networkNamespace,
+			opened:           true,
 		}
 	case tracer.EventClose:
 		if !t.ready {
@@ -286,6 +295,7 @@ func (t *EbpfTracker) handleConnection(ev tracer.EventType, tuple fourTuple, pid
 		}
 		if deadConn, ok := t.openConnections[tuple]; ok {
 			delete(t.openConnections, tuple)
+			deadConn.closed = true
 			t.closedConnections = append(t.closedConnections, deadConn)
 		} else {
 			log.Debugf("EbpfTracker: unmatched close event: %s pid=%d netns=%s", tuple, pid, networkNamespace)
@@ -301,8 +311,12 @@ func (t *EbpfTracker) walkConnections(f func(ebpfConnection)) {
 	t.Lock()
 	defer t.Unlock()
 
-	for _, connection := range t.openConnections {
+	for tuple, connection := range t.openConnections {
 		f(connection)
+		if connection.opened {
+			connection.opened = false
+			t.openConnections[tuple] = connection
+		}
 	}
 	for _, connection := range t.closedConnections {
 		f(connection)
@@ -313,11 +327,17 @@ func (t *EbpfTracker) walkConnections(f func(ebpfConnection)) {
 func (t *EbpfTracker) feedInitialConnections(conns procspy.ConnIter, seenTuples map[string]fourTuple, processesWaitingInAccept []int, hostNodeID string) {
 	t.Lock()
 	for conn := conns.Next(); conn != nil; conn = conns.Next() {
+		// The tracer only follows TCP connections
+		if conn.Transport == "udp" {
+			continue
+		}
 		tuple, namespaceID, incoming := connectionTuple(conn, seenTuples)
+		_, seen := seenTuples[tuple.key()]
 		if _, ok := t.closedDuringInit[tuple]; !ok {
 			if _, ok := t.openConnections[tuple]; !ok {
 				t.openConnections[tuple] = ebpfConnection{
 					incoming:         incoming,
+					guessed:          !seen,
 					tuple:            tuple,
 					pid:              int(conn.Proc.PID),
 					networkNamespace: namespaceID,
@@ -9,7 +9,9 @@ import (
 
 	"github.com/weaveworks/tcptracer-bpf/pkg/tracer"
 
+	"github.com/weaveworks/scope/probe/endpoint/procspy"
 	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/report"
 )
 
 func newMockEbpfTracker() *EbpfTracker {
@@ -53,6 +55,7 @@ func TestHandleConnection(t *testing.T) {
 			networkNamespace: strconv.Itoa(int(NetNS)),
 			incoming:         false,
 			pid:              int(ClientPid),
+			opened:           true,
 		}
 
 		IPv4ConnectCloseEvent = tracer.TcpV4{
@@ -89,6 +92,7 @@ func TestHandleConnection(t *testing.T) {
 			networkNamespace: strconv.Itoa(int(NetNS)),
 			incoming:         true,
 			pid:              int(ServerPid),
+			opened:           true,
 		}
 
 		IPv4AcceptCloseEvent = tracer.TcpV4{
@@ -177,6 +181,69 @@ func TestWalkConnections(t *testing.T) {
 	}
 }
 
+func TestWalkConnectionsChurn(t *testing.T) {
+	var (
+		longLived  = fourTuple{fromAddr: "10.0.0.1", toAddr: "10.0.0.2", fromPort: 40000, toPort: 80}
+		shortLived = fourTuple{fromAddr: "10.0.0.1", toAddr: "10.0.0.2", fromPort: 40001, toPort: 80}
+	)
+	mockEbpfTracker := newMockEbpfTracker()
+	mockEbpfTracker.handleConnection(tracer.EventConnect, longLived, 1, "12345")
+	mockEbpfTracker.handleConnection(tracer.EventConnect, shortLived, 1, "12345")
+	mockEbpfTracker.handleConnection(tracer.EventClose, shortLived, 1, "12345")
+
+	churn := func() (opened, closed int) {
+		mockEbpfTracker.walkConnections(func(e ebpfConnection) {
+			if e.opened {
+				opened++
+			}
+			if e.closed {
+				closed++
+			}
+		})
+		return
+	}
+	if opened, closed := churn(); opened != 2 || closed != 1 {
+		t.Errorf("expected 2 opened and 1 closed connections, got %d and %d", opened, closed)
+	}
+	// Connections are only counted once
+	if opened, closed := churn(); opened != 0 || closed != 0 {
+		t.Errorf("expected no churn, got %d opened and %d closed connections", opened, closed)
+	}
+}
+
+func TestEbpfTrackSocketStates(t *testing.T) {
+	mockEbpfTracker := newMockEbpfTracker()
+	mockEbpfTracker.handleConnection(tracer.EventConnect, fourTuple{fromAddr: "10.0.0.1", toAddr: "10.0.0.2", fromPort: 40000, toPort: 80}, 42, "12345")
+	ct := connectionTracker{
+		conf: connectionTrackerConfig{
+			HostID:   "host",
+			WalkProc: true,
+			Scanner: procspy.FixedScanner{
+				{Transport: "tcp", LocalAddress: net.ParseIP("10.0.0.1"), LocalPort: 40000, State: 1, Proc: procspy.Proc{PID: 42}}, // ESTABLISHED
+				{Transport: "tcp", LocalAddress: net.ParseIP("10.0.0.1"), LocalPort: 40001, State: 6},                              // TIME_WAIT
+			},
+		},
+		ebpfTracker:     mockEbpfTracker,
+		reverseResolver: newReverseResolver(),
+	}
+	defer ct.reverseResolver.stop()
+
+	rpt := report.MakeReport()
+	ct.ReportConnections(&rpt)
+	if len(rpt.Endpoint.Nodes) == 0 {
+		t.Errorf("expected the connections of the eBPF tracker, got %v", rpt.Endpoint.Nodes)
+	}
+	host := rpt.Host.Nodes[report.MakeHostNodeID("host")]
+	for key, want := range map[string]float64{report.SocketsEstablished: 1, report.SocketsTimeWait: 1} {
+		if last, ok := host.Metrics[key].LastSample(); !ok || last.Value != want {
+			t.Errorf("want %v %s, have %v", want, key, host.Metrics[key])
+		}
+	}
+	if last, ok := rpt.Process.Nodes[report.MakeProcessNodeID("host", "42")].Metrics[report.SocketsEstablished].LastSample(); !ok || last.Value != 1 {
+		t.Errorf("expected the sockets of process 42 to be counted, got %v", last)
+	}
+}
+
 func TestInvalidTimeStampDead(t *testing.T) {
 	var (
 		cnt        int
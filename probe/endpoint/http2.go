@@ -0,0 +1,188 @@
+package endpoint
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/http2/hpack"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// Requests is the prefix of the keys of the counters of the requests an
+// endpoint made, one per API called: the service and method for gRPC,
+// the method and path otherwise.
+const Requests = "requests"
+
+// L7Protocol is the key of the application protocol of the connections
+// of an endpoint: "grpc" or "http2", or a database protocol.
+const L7Protocol = "l7_protocol"
+
+// MaxRequestNames bounds the number of APIs requests are counted by.
+// Requests to the least called APIs are counted as OtherRequests.
+const (
+	MaxRequestNames = 10
+	OtherRequests   = "other"
+)
+
+// RequestsKey is the key of the counter of the requests to an API.
+func RequestsKey(name string) string {
+	return Requests + report.EdgeDelim + name
+}
+
+// ParseRequestsKey returns the API of a requests counter key.
+func ParseRequestsKey(key string) (string, bool) {
+	if !strings.HasPrefix(key, Requests+report.EdgeDelim) {
+		return "", false
+	}
+	return key[len(Requests+report.EdgeDelim):], true
+}
+
+// TopRequests keeps the counts of the MaxRequestNames most called APIs,
+// adding up the others as OtherRequests.
+func TopRequests(counts map[string]int) map[string]int {
+	if len(counts) <= MaxRequestNames {
+		return counts
+	}
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	result := make(map[string]int, MaxRequestNames+1)
+	for i, name := range names {
+		if i < MaxRequestNames && name != OtherRequests {
+			result[name] = counts[name]
+		} else {
+			result[OtherRequests] += counts[name]
+		}
+	}
+	return result
+}
+
+var http2Preface = []byte("PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n")
+
+// HTTP/2 frame types and flags of RFC 7540 needed to read request headers
+const (
+	http2FrameHeaderLen    = 9
+	http2FrameHeaders      = 0x1
+	http2FrameContinuation = 0x9
+	http2FlagEndHeaders    = 0x4
+	http2FlagPadded        = 0x8
+	http2FlagPriority      = 0x20
+)
+
+// http2Stream reads the requests off the client side of a captured
+// connection. The connection is not HTTP/2 unless it starts with the
+// client preface; it is abandoned when the capture misses some of it, as
+// header compression depends on all the headers sent before.
+type http2Stream struct {
+	buf         []byte
+	started     bool
+	broken      bool
+	decoder     *hpack.Decoder
+	headerBlock []byte
+	protocol    string
+	requests    map[string]int
+}
+
+func (s *http2Stream) feed(payload []byte) {
+	if s.broken {
+		return
+	}
+	s.buf = append(s.buf, payload...)
+	if !s.started {
+		if len(s.buf) < len(http2Preface) {
+			s.broken = !bytes.HasPrefix(http2Preface, s.buf)
+			return
+		}
+		if !bytes.HasPrefix(s.buf, http2Preface) {
+			s.broken = true
+			return
+		}
+		s.buf = s.buf[len(http2Preface):]
+		s.started = true
+		s.decoder = hpack.NewDecoder(4096, nil)
+		s.protocol = "http2"
+		s.requests = map[string]int{}
+	}
+
+	for len(s.buf) >= http2FrameHeaderLen {
+		length := int(s.buf[0])<<16 | int(s.buf[1])<<8 | int(s.buf[2])
+		if len(s.buf) < http2FrameHeaderLen+length {
+			return
+		}
+		typ, flags := s.buf[3], s.buf[4]
+		frame := s.buf[http2FrameHeaderLen : http2FrameHeaderLen+length]
+		s.buf = s.buf[http2FrameHeaderLen+length:]
+
+		switch typ {
+		case http2FrameHeaders:
+			if flags&http2FlagPadded != 0 {
+				if len(frame) < 1 || int(frame[0]) >= len(frame) {
+					s.broken = true
+					return
+				}
+				frame = frame[1 : len(frame)-int(frame[0])]
+			}
+			if flags&http2FlagPriority != 0 {
+				if len(frame) < 5 {
+					s.broken = true
+					return
+				}
+				frame = frame[5:]
+			}
+			s.headerBlock = append(s.headerBlock[:0], frame...)
+		case http2FrameContinuation:
+			s.headerBlock = append(s.headerBlock, frame...)
+		default:
+			continue
+		}
+		if flags&http2FlagEndHeaders != 0 {
+			if !s.decodeHeaders() {
+				s.broken = true
+				return
+			}
+		}
+	}
+}
+
+// decodeHeaders counts the request of the header block.
+func (s *http2Stream) decodeHeaders() bool {
+	fields, err := s.decoder.DecodeFull(s.headerBlock)
+	if err != nil {
+		return false
+	}
+	var method, path, contentType string
+	for _, f := range fields {
+		switch f.Name {
+		case ":method":
+			method = f.Value
+		case ":path":
+			path = f.Value
+		case "content-type":
+			contentType = f.Value
+		}
+	}
+	if method == "" || path == "" {
+		// Trailers, or headers of a server push
+		return true
+	}
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	if strings.HasPrefix(contentType, "application/grpc") {
+		// gRPC paths are /package.Service/Method
+		s.protocol = "grpc"
+		s.requests[strings.TrimPrefix(path, "/")]++
+	} else {
+		s.requests[method+" "+path]++
+	}
+	return true
+}
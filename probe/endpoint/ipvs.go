@@ -0,0 +1,182 @@
+package endpoint
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// ipvsService is a virtual service of the IPVS table, as kube-proxy sets
+// up for the cluster IPs of services in IPVS mode, and the real servers
+// it balances between.
+type ipvsService struct {
+	ipvsAddress
+	backends []ipvsAddress
+}
+
+type ipvsAddress struct {
+	address, port string
+}
+
+// ipvsConn is a connection of the IPVS connection table, from a client
+// to a virtual service, and the real server IPVS sent it to.
+type ipvsConn struct {
+	client, vip, backend ipvsAddress
+}
+
+// readIPVS reads the IPVS table from procRoot/net/ip_vs. Hosts without
+// IPVS have no such file, and no services. Only IPv4 services are read.
+func readIPVS(procRoot string) ([]ipvsService, error) {
+	f, err := os.Open(filepath.Join(procRoot, "net", "ip_vs"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseIPVS(f)
+}
+
+// parseIPVS parses the IPVS table, as in
+//
+//	TCP  0A600001:01BB rr
+//	  -> AC110002:1A0A      Masq    1      0          0
+func parseIPVS(r io.Reader) ([]ipvsService, error) {
+	var (
+		services []ipvsService
+		current  *ipvsService
+		scanner  = bufio.NewScanner(r)
+	)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		switch {
+		case len(fields) >= 2 && (fields[0] == "TCP" || fields[0] == "UDP"):
+			address, err := parseIPVSAddress(fields[1])
+			if err != nil {
+				current = nil
+				continue
+			}
+			services = append(services, ipvsService{ipvsAddress: address})
+			current = &services[len(services)-1]
+		case len(fields) >= 2 && fields[0] == "->" && current != nil:
+			address, err := parseIPVSAddress(fields[1])
+			if err != nil {
+				continue
+			}
+			// Real servers with no weight are being drained
+			if len(fields) >= 4 && fields[3] == "0" {
+				continue
+			}
+			current.backends = append(current.backends, address)
+		}
+	}
+	return services, scanner.Err()
+}
+
+// readIPVSConns reads the IPVS connection table from
+// procRoot/net/ip_vs_conn. Only IPv4 connections are read.
+func readIPVSConns(procRoot string) ([]ipvsConn, error) {
+	f, err := os.Open(filepath.Join(procRoot, "net", "ip_vs_conn"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseIPVSConns(f)
+}
+
+// parseIPVSConns parses the IPVS connection table, as in
+//
+//	Pro FromIP   FPrt ToIP     TPrt DestIP   DPrt State       Expires PEName PEData
+//	TCP 0A000001 9C41 0A600001 01BB AC110002 1A0A ESTABLISHED     897
+func parseIPVSConns(r io.Reader) ([]ipvsConn, error) {
+	var (
+		conns   []ipvsConn
+		scanner = bufio.NewScanner(r)
+	)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 7 || (fields[0] != "TCP" && fields[0] != "UDP") {
+			continue
+		}
+		var (
+			addresses [3]ipvsAddress
+			err       error
+		)
+		for i := range addresses {
+			if addresses[i], err = parseIPVSAddress(fields[1+2*i] + ":" + fields[2+2*i]); err != nil {
+				break
+			}
+		}
+		if err != nil {
+			continue
+		}
+		conns = append(conns, ipvsConn{client: addresses[0], vip: addresses[1], backend: addresses[2]})
+	}
+	return conns, scanner.Err()
+}
+
+// parseIPVSAddress parses the hex addr:port of the IPVS table.
+func parseIPVSAddress(s string) (ipvsAddress, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 2 || len(parts[0]) != 8 {
+		return ipvsAddress{}, fmt.Errorf("invalid IPVS address %q", s)
+	}
+	ip, err := strconv.ParseUint(parts[0], 16, 32)
+	if err != nil {
+		return ipvsAddress{}, err
+	}
+	port, err := strconv.ParseUint(parts[1], 16, 16)
+	if err != nil {
+		return ipvsAddress{}, err
+	}
+	return ipvsAddress{
+		address: net.IPv4(byte(ip>>24), byte(ip>>16), byte(ip>>8), byte(ip)).String(),
+		port:    strconv.FormatUint(port, 10),
+	}, nil
+}
+
+// addVIPs tags the endpoints of the virtual IPs of the IPVS table with
+// their backends, and the backend each connection to them went to, so
+// that the renderer can attribute edges to the virtual IPs to the
+// backends instead.
+func (r *Reporter) addVIPs(rpt *report.Report) error {
+	services, err := readIPVS(r.conf.ProcRoot)
+	if err != nil {
+		return err
+	}
+	conns, err := readIPVSConns(r.conf.ProcRoot)
+	if err != nil {
+		return err
+	}
+	connsByVIP := map[ipvsAddress][]string{}
+	for _, c := range conns {
+		client := report.MakeEndpointNodeID(r.conf.HostID, "", c.client.address, c.client.port)
+		backend := report.MakeEndpointNodeID(r.conf.HostID, "", c.backend.address, c.backend.port)
+		connsByVIP[c.vip] = append(connsByVIP[c.vip], client+report.EdgeDelim+backend)
+	}
+	for _, s := range services {
+		if len(s.backends) == 0 {
+			continue
+		}
+		backends := make([]string, 0, len(s.backends))
+		for _, b := range s.backends {
+			backends = append(backends, report.MakeEndpointNodeID(r.conf.HostID, "", b.address, b.port))
+		}
+		id := report.MakeEndpointNodeID(r.conf.HostID, "", s.address, s.port)
+		node := report.MakeNode(id).WithSet(report.VIPBackends, report.MakeStringSet(backends...))
+		if c := connsByVIP[s.ipvsAddress]; len(c) > 0 {
+			node = node.WithSet(report.VIPConnections, report.MakeStringSet(c...))
+		}
+		rpt.Endpoint.AddNode(node)
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package endpoint
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const ipvsTable = `IP Virtual Server version 1.2.1 (size=4096)
+Prot LocalAddress:Port Scheduler Flags
+  -> RemoteAddress:Port Forward Weight ActiveConn InActConn
+TCP  0A600001:01BB rr
+  -> AC110002:1A0A      Masq    1      0          0
+  -> AC110003:1A0A      Masq    1      2          0
+  -> AC110004:1A0A      Masq    0      0          0
+UDP  0A60000A:0035 rr
+  -> AC110005:0035      Masq    1      0          0
+TCP  [fd00:0000:0000:0000:0000:0000:0000:0001]:0050 rr
+  -> [fd00:0000:0000:0000:0000:0000:0000:0002]:0050      Masq    1      0          0
+`
+
+const ipvsConnTable = `Pro FromIP   FPrt ToIP     TPrt DestIP   DPrt State       Expires PEName PEData
+TCP 0A000001 9C41 0A600001 01BB AC110003 1A0A ESTABLISHED     897
+UDP 0A000002 D431 0A60000A 0035 AC110005 0035 UDP             148
+TCP FD000000000000000000000000000001 0050 FD000000000000000000000000000002 0050 FD000000000000000000000000000003 0050 ESTABLISHED 897
+`
+
+func TestParseIPVS(t *testing.T) {
+	have, err := parseIPVS(strings.NewReader(ipvsTable))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ipvsService{
+		{
+			ipvsAddress: ipvsAddress{"10.96.0.1", "443"},
+			backends:    []ipvsAddress{{"172.17.0.2", "6666"}, {"172.17.0.3", "6666"}},
+		},
+		{
+			ipvsAddress: ipvsAddress{"10.96.0.10", "53"},
+			backends:    []ipvsAddress{{"172.17.0.5", "53"}},
+		},
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
+
+func TestParseIPVSConns(t *testing.T) {
+	have, err := parseIPVSConns(strings.NewReader(ipvsConnTable))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ipvsConn{
+		{
+			client:  ipvsAddress{"10.0.0.1", "40001"},
+			vip:     ipvsAddress{"10.96.0.1", "443"},
+			backend: ipvsAddress{"172.17.0.3", "6666"},
+		},
+		{
+			client:  ipvsAddress{"10.0.0.2", "54321"},
+			vip:     ipvsAddress{"10.96.0.10", "53"},
+			backend: ipvsAddress{"172.17.0.5", "53"},
+		},
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
@@ -0,0 +1,238 @@
+package endpoint
+
+import (
+	"encoding/binary"
+	"strconv"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/tcpassembly"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// Bounds of the connections read at once, and of the out of order
+// segments buffered for them (of about 2KB each), past which their
+// traffic is assumed lost.
+const (
+	maxL7Flows               = 10000
+	maxBufferedPagesPerFlow  = 16
+	maxBufferedPagesAllFlows = 4096
+)
+
+// l7Flow reads the application protocol of a TCP connection off its
+// traffic, reassembled: the requests of HTTP/2 and gRPC clients, the
+// queries of database clients and their errors, and the server names TLS
+// clients ask for. It also times the handshake of the connection. tuple
+// goes from the client to the server.
+type l7Flow struct {
+	tuple fourTuple
+	http2 http2Stream
+	wire  wireDetector
+	tls   tlsClientHello
+
+	// The requests, queries and errors counted in reports already
+	reported                        map[string]int
+	reportedQueries, reportedErrors int
+	// The streams of each direction of the connection, and those of them
+	// complete
+	streams, complete int
+	// When the handshake was started, and whether the server acknowledged
+	// it, then its round trip time, until reported
+	synAt    time.Time
+	synAcked bool
+	rtt      time.Duration
+	rttAt    time.Time
+}
+
+// handshake times the handshake of the connection, from the SYN of the
+// client to its acknowledgement of that of the server: a round trip,
+// wherever the traffic is captured between the two.
+func (f *l7Flow) handshake(fromClient bool, tcp *layers.TCP, timestamp time.Time) {
+	switch {
+	case fromClient && tcp.SYN && !tcp.ACK:
+		f.synAt, f.synAcked = timestamp, false
+	case !fromClient && tcp.SYN && tcp.ACK:
+		f.synAcked = !f.synAt.IsZero()
+	case fromClient && tcp.ACK && f.synAcked:
+		f.rtt, f.rttAt = timestamp.Sub(f.synAt), timestamp
+		f.synAt, f.synAcked = time.Time{}, false
+	}
+}
+
+// feed reads traffic of the client, or of the server.
+func (f *l7Flow) feed(fromClient bool, payload []byte) {
+	if fromClient {
+		f.http2.feed(payload)
+		f.tls.feed(payload)
+	}
+	f.wire.feed(fromClient, payload)
+}
+
+// skipped is told traffic of the client, or of the server, was lost.
+func (f *l7Flow) skipped(fromClient bool) {
+	if fromClient {
+		// Header compression depends on all the headers sent before
+		f.http2.broken = true
+		f.tls.done = true
+	}
+	f.wire.skipped()
+}
+
+// l7Flows reassembles the TCP connections of the packets it is given,
+// reading their application protocols as the traffic of each direction
+// comes in order, whichever order it was captured in.
+type l7Flows struct {
+	flows     map[string]*l7Flow
+	assembler *tcpassembly.Assembler
+}
+
+func newL7Flows() *l7Flows {
+	f := &l7Flows{flows: map[string]*l7Flow{}}
+	f.assembler = tcpassembly.NewAssembler(tcpassembly.NewStreamPool(f))
+	f.assembler.MaxBufferedPagesPerConnection = maxBufferedPagesPerFlow
+	f.assembler.MaxBufferedPagesTotal = maxBufferedPagesAllFlows
+	return f
+}
+
+// packet reads the packet, captured at the time, if of TCP.
+func (f *l7Flows) packet(packet gopacket.Packet, timestamp time.Time) {
+	tcp, ok := packet.TransportLayer().(*layers.TCP)
+	if !ok {
+		return
+	}
+	tuple, syn, ok := packetTuple(packet)
+	if !ok {
+		return
+	}
+	key := tuple.key()
+	flow, ok := f.flows[key]
+	if !ok {
+		if len(f.flows) >= maxL7Flows {
+			return
+		}
+		// The client is the endpoint sending the SYN or, when the start
+		// of the connection wasn't captured, the one with the highest
+		// (most likely ephemeral) port.
+		client := tuple
+		if !syn && client.fromPort < client.toPort {
+			client.reverse()
+		}
+		flow = &l7Flow{tuple: client, wire: newWireDetector(), reported: map[string]int{}}
+		f.flows[key] = flow
+	}
+	flow.handshake(tuple.fromAddr == flow.tuple.fromAddr && tuple.fromPort == flow.tuple.fromPort, tcp, timestamp)
+	f.assembler.AssembleWithTimestamp(packet.NetworkLayer().NetworkFlow(), tcp, timestamp)
+}
+
+// flushOlderThan gives up on the segments missing of the connections
+// idle since the time, reading on past them. Connections closed, or idle
+// since, are forgotten once reported.
+func (f *l7Flows) flushOlderThan(t time.Time) {
+	f.assembler.FlushOlderThan(t)
+}
+
+// flushAll reads on past the segments missing of every connection.
+func (f *l7Flows) flushAll() {
+	f.assembler.FlushAll()
+}
+
+// New implements tcpassembly.StreamFactory, reading the traffic of a
+// direction of a connection into its flow.
+func (f *l7Flows) New(netFlow, tcpFlow gopacket.Flow) tcpassembly.Stream {
+	src, dst := netFlow.Endpoints()
+	srcPort, dstPort := tcpFlow.Endpoints()
+	tuple := fourTuple{
+		fromAddr: src.String(),
+		toAddr:   dst.String(),
+		fromPort: binary.BigEndian.Uint16(srcPort.Raw()),
+		toPort:   binary.BigEndian.Uint16(dstPort.Raw()),
+	}
+	flow, ok := f.flows[tuple.key()]
+	if !ok {
+		return &l7Stream{}
+	}
+	flow.streams++
+	return &l7Stream{
+		flow:       flow,
+		fromClient: tuple.fromAddr == flow.tuple.fromAddr && tuple.fromPort == flow.tuple.fromPort,
+	}
+}
+
+// report adds what was read of the connections since the last report to
+// the endpoints of their clients, as if reported by a probe on hostID,
+// and forgets the connections closed.
+func (f *l7Flows) report(rpt *report.Report, hostID string) {
+	for key, flow := range f.flows {
+		var (
+			node = report.MakeNode(report.MakeEndpointNodeID(hostID, "", flow.tuple.fromAddr, strconv.Itoa(int(flow.tuple.fromPort))))
+			read = false
+		)
+		if flow.http2.requests != nil {
+			requests := map[string]int{}
+			for name, count := range flow.http2.requests {
+				if count > flow.reported[name] {
+					requests[name] = count - flow.reported[name]
+				}
+				flow.reported[name] = count
+			}
+			for name, count := range TopRequests(requests) {
+				node.Counters = node.Counters.Add(RequestsKey(name), count)
+			}
+			node, read = node.WithLatests(map[string]string{L7Protocol: flow.http2.protocol}), true
+		}
+		if protocol := flow.wire.protocol(); protocol != nil {
+			queries, errors := protocol.counts()
+			node.Counters = node.Counters.
+				Add(Queries, queries-flow.reportedQueries).
+				Add(QueryErrors, errors-flow.reportedErrors)
+			flow.reportedQueries, flow.reportedErrors = queries, errors
+			node, read = node.WithLatests(map[string]string{L7Protocol: protocol.name()}), true
+		}
+		if flow.tls.serverName != "" {
+			node, read = node.WithLatests(map[string]string{TLSServerName: flow.tls.serverName}), true
+		}
+		if flow.rtt > 0 {
+			node, read = node.WithLatencies(flow.rttAt, flow.rtt), true
+			flow.rtt = 0
+		}
+		if read {
+			rpt.Endpoint.AddNode(node)
+		}
+		if flow.streams > 0 && flow.complete == flow.streams {
+			delete(f.flows, key)
+		}
+	}
+}
+
+// l7Stream is a direction of a connection, or of none once there are too
+// many.
+type l7Stream struct {
+	flow       *l7Flow
+	fromClient bool
+}
+
+// Reassembled implements tcpassembly.Stream.
+func (s *l7Stream) Reassembled(reassemblies []tcpassembly.Reassembly) {
+	if s.flow == nil {
+		return
+	}
+	for _, r := range reassemblies {
+		// Traffic before the first captured (-1) is that of connections
+		// opened before the capture, whose protocols go unread
+		if r.Skip > 0 {
+			s.flow.skipped(s.fromClient)
+		}
+		if len(r.Bytes) > 0 {
+			s.flow.feed(s.fromClient, r.Bytes)
+		}
+	}
+}
+
+// ReassemblyComplete implements tcpassembly.Stream.
+func (s *l7Stream) ReassemblyComplete() {
+	if s.flow != nil {
+		s.flow.complete++
+	}
+}
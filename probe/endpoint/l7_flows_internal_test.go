@@ -0,0 +1,87 @@
+package endpoint
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/weaveworks/scope/report"
+)
+
+func TestL7FlowsReportSinceLast(t *testing.T) {
+	var (
+		flows  = newL7Flows()
+		flow   = &l7Flow{tuple: fourTuple{"10.0.0.1", "10.0.0.2", 50000, 8080}, reported: map[string]int{}}
+		client = report.MakeEndpointNodeID("host", "", "10.0.0.1", "50000")
+		key    = RequestsKey("pkg.Service/Get")
+	)
+	flows.flows[flow.tuple.key()] = flow
+	flow.http2 = http2Stream{started: true, protocol: "grpc", requests: map[string]int{"pkg.Service/Get": 2}}
+
+	rpt := report.MakeReport()
+	flows.report(&rpt, "host")
+	if have, _ := rpt.Endpoint.Nodes[client].Counters.Lookup(key); have != 2 {
+		t.Errorf("expected 2 requests, got %d", have)
+	}
+
+	// Reports have the requests since the last, and the connections closed
+	// are forgotten once reported
+	flow.http2.requests["pkg.Service/Get"]++
+	flow.streams, flow.complete = 1, 1
+	rpt = report.MakeReport()
+	flows.report(&rpt, "host")
+	if have, _ := rpt.Endpoint.Nodes[client].Counters.Lookup(key); have != 1 {
+		t.Errorf("expected 1 request since the last report, got %d", have)
+	}
+	if len(flows.flows) != 0 {
+		t.Errorf("expected the connection closed forgotten, got %v", flows.flows)
+	}
+}
+
+// tcpPacket is a TCP segment of the tuple, without payload.
+func tcpPacket(t *testing.T, tuple fourTuple, tcp *layers.TCP) gopacket.Packet {
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolTCP, SrcIP: net.ParseIP(tuple.fromAddr), DstIP: net.ParseIP(tuple.toAddr)}
+	tcp.SrcPort, tcp.DstPort = layers.TCPPort(tuple.fromPort), layers.TCPPort(tuple.toPort)
+	tcp.SetNetworkLayerForChecksum(ip)
+	eth := &layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0, 0, 0, 0, 0, 1},
+		DstMAC:       net.HardwareAddr{0, 0, 0, 0, 0, 2},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	buf := gopacket.NewSerializeBuffer()
+	if err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}, eth, ip, tcp); err != nil {
+		t.Fatal(err)
+	}
+	return gopacket.NewPacket(buf.Bytes(), layers.LayerTypeEthernet, gopacket.Default)
+}
+
+func TestL7FlowsHandshakeLatency(t *testing.T) {
+	var (
+		flows  = newL7Flows()
+		tuple  = fourTuple{"10.0.0.1", "10.0.0.2", 50000, 5432}
+		server = fourTuple{"10.0.0.2", "10.0.0.1", 5432, 50000}
+		client = report.MakeEndpointNodeID("host", "", "10.0.0.1", "50000")
+		start  = time.Unix(1500000000, 0)
+	)
+	// The handshake takes a round trip, of 3ms, from the SYN to the ACK
+	flows.packet(tcpPacket(t, tuple, &layers.TCP{SYN: true, Seq: 100}), start)
+	flows.packet(tcpPacket(t, server, &layers.TCP{SYN: true, ACK: true, Seq: 300, Ack: 101}), start.Add(2*time.Millisecond))
+	flows.packet(tcpPacket(t, tuple, &layers.TCP{ACK: true, Seq: 101, Ack: 301}), start.Add(3*time.Millisecond))
+
+	rpt := report.MakeReport()
+	flows.report(&rpt, "host")
+	if have, _ := rpt.Endpoint.Nodes[client].Counters.Lookup(report.LatencySumMicros); have != 3000 {
+		t.Errorf("expected a latency of 3000us, got %d", have)
+	}
+
+	// The latency is reported once
+	flows.packet(tcpPacket(t, tuple, &layers.TCP{ACK: true, Seq: 101, Ack: 301}), start.Add(time.Second))
+	rpt = report.MakeReport()
+	flows.report(&rpt, "host")
+	if n, ok := rpt.Endpoint.Nodes[client]; ok {
+		t.Errorf("expected no latency reported again, got %v", n.Counters)
+	}
+}
@@ -0,0 +1,94 @@
+// +build linux,amd64 linux,ppc64le
+
+// Build constraint to use this file for amd64 & ppc64le on Linux
+
+package endpoint
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// l7FlowTimeout is how long connections go idle before the segments
+// missing of them are given up on, and they are forgotten.
+const l7FlowTimeout = 2 * time.Minute
+
+// L7Sniffer reads the application protocols of the TCP connections of
+// the host off their traffic, captured and reassembled, for the endpoint
+// reporter.
+type L7Sniffer struct {
+	stop       chan struct{}
+	pcapHandle *pcap.Handle
+
+	mtx   sync.Mutex
+	flows *l7Flows
+}
+
+// NewL7Sniffer creates a new sniffer of the application protocols of
+// connections.
+func NewL7Sniffer() (*L7Sniffer, error) {
+	pcapHandle, err := newPcapHandle(pcap.DirectionInOut, "tcp")
+	if err != nil {
+		return nil, err
+	}
+	s := &L7Sniffer{
+		stop:       make(chan struct{}),
+		pcapHandle: pcapHandle,
+		flows:      newL7Flows(),
+	}
+	go s.run()
+	return s, nil
+}
+
+// Stop makes the sniffer stop reading connections
+func (s *L7Sniffer) Stop() {
+	if s != nil {
+		close(s.stop)
+	}
+}
+
+func (s *L7Sniffer) run() {
+	flushed := time.Now()
+	for {
+		select {
+		case <-s.stop:
+			s.pcapHandle.Close()
+			return
+		default:
+		}
+
+		data, ci, err := s.pcapHandle.ZeroCopyReadPacketData()
+		if err != nil && err != pcap.NextErrorTimeoutExpired {
+			log.Errorf("L7Sniffer: error reading packet data: %s", err)
+			continue
+		}
+
+		// assumes that the "any" interface is being used (see https://wiki.wireshark.org/SLL)
+		s.mtx.Lock()
+		if err == nil {
+			s.flows.packet(gopacket.NewPacket(data, layers.LayerTypeLinuxSLL, gopacket.NoCopy), ci.Timestamp)
+		}
+		if now := time.Now(); now.Sub(flushed) > l7FlowTimeout/4 {
+			s.flows.flushOlderThan(now.Add(-l7FlowTimeout))
+			flushed = now
+		}
+		s.mtx.Unlock()
+	}
+}
+
+// report adds what was read of the connections since the last report to
+// the endpoints of their clients.
+func (s *L7Sniffer) report(rpt *report.Report, hostID string) {
+	if s == nil {
+		return
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.flows.report(rpt, hostID)
+}
@@ -0,0 +1,26 @@
+// +build darwin arm
+
+// Cross-compiling the sniffer requires having pcap binaries, as for the
+// DNS snooper.
+
+package endpoint
+
+import (
+	"github.com/weaveworks/scope/report"
+)
+
+// L7Sniffer is a sniffer of the application protocols of connections
+type L7Sniffer struct{}
+
+// NewL7Sniffer creates a new sniffer of the application protocols of
+// connections
+func NewL7Sniffer() (*L7Sniffer, error) {
+	return nil, nil
+}
+
+// Stop makes the sniffer stop reading connections
+func (s *L7Sniffer) Stop() {
+}
+
+func (s *L7Sniffer) report(*report.Report, string) {
+}
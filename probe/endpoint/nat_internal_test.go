@@ -19,6 +19,8 @@ func (m *mockFlowWalker) walkFlows(f func(f flow, active bool)) {
 	}
 }
 
+func (m *mockFlowWalker) walkFailedFlows(f func(f flow)) {}
+
 func (m *mockFlowWalker) stop() {}
 
 func TestNat(t *testing.T) {
@@ -0,0 +1,165 @@
+package endpoint
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// Counters of the traffic sent by endpoints, in reports made from packet
+// captures.
+const (
+	BytesSent   = "bytes_sent"
+	PacketsSent = "packets_sent"
+)
+
+// capturedFlow accumulates the traffic of a connection seen in a
+// capture. tuple goes from the client to the server; index 0 of the
+// counters is the traffic sent by the client, 1 the replies.
+type capturedFlow struct {
+	tuple   fourTuple
+	udp     bool
+	bytes   [2]int
+	packets [2]int
+}
+
+// ReportFromPcap reads a packet capture in the libpcap format and
+// reconstructs its TCP and UDP flows into an endpoint topology, as if
+// reported by a probe on hostID. The bytes and packets sent by each
+// endpoint are counted, as are the requests of HTTP/2 and gRPC clients,
+// by API, and the queries and errors of database clients. The server
+// names TLS clients asked for are added to their endpoints, and the
+// names of the addresses resolved by captured DNS responses added to the
+// report.
+func ReportFromPcap(r io.Reader, hostID string) (report.Report, error) {
+	rpt := report.MakeReport()
+	reader, err := pcapgo.NewReader(r)
+	if err != nil {
+		return rpt, err
+	}
+
+	flows := map[string]*capturedFlow{}
+	order := []string{}
+	l7 := newL7Flows()
+	for {
+		data, ci, err := reader.ReadPacketData()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return rpt, err
+		}
+		packet := gopacket.NewPacket(data, reader.LinkType(), gopacket.NoCopy)
+		if dns, ok := packet.Layer(layers.LayerTypeDNS).(*layers.DNS); ok && dns.QR {
+			addCapturedDNS(&rpt, dns)
+		}
+		tuple, syn, ok := packetTuple(packet)
+		if !ok {
+			continue
+		}
+		l7.packet(packet, ci.Timestamp)
+		// UDP flows are kept apart from the TCP ones of the same tuple
+		_, udp := packet.TransportLayer().(*layers.UDP)
+		key := tuple.key()
+		if udp {
+			key += report.UDPPortSuffix
+		}
+		flow, ok := flows[key]
+		if !ok {
+			// The client is the endpoint sending the SYN or, when the
+			// start of the connection wasn't captured, the one with
+			// the highest (most likely ephemeral) port.
+			if !syn && tuple.fromPort < tuple.toPort {
+				tuple.reverse()
+			}
+			flow = &capturedFlow{tuple: tuple, udp: udp}
+			flows[key] = flow
+			order = append(order, key)
+		}
+		direction := 0
+		if tuple.fromAddr != flow.tuple.fromAddr || tuple.fromPort != flow.tuple.fromPort {
+			direction = 1
+		}
+		flow.bytes[direction] += len(data)
+		flow.packets[direction]++
+	}
+
+	l7.flushAll()
+	for _, key := range order {
+		flow := flows[key]
+		var (
+			fromNode = capturedEndpointNode(hostID, flow.tuple.fromAddr, flow.tuple.fromPort, flow.udp, flow.bytes[0], flow.packets[0])
+			toNode   = capturedEndpointNode(hostID, flow.tuple.toAddr, flow.tuple.toPort, flow.udp, flow.bytes[1], flow.packets[1])
+		)
+		rpt.Endpoint.AddNode(fromNode.WithAdjacent(toNode.ID))
+		rpt.Endpoint.AddNode(toNode)
+	}
+	l7.report(&rpt, hostID)
+	return rpt, nil
+}
+
+// packetTuple returns the four tuple of a TCP or UDP packet, and whether
+// it opens a TCP connection.
+func packetTuple(packet gopacket.Packet) (fourTuple, bool, bool) {
+	var tuple fourTuple
+	switch ip := packet.NetworkLayer().(type) {
+	case *layers.IPv4:
+		tuple.fromAddr, tuple.toAddr = ip.SrcIP.String(), ip.DstIP.String()
+	case *layers.IPv6:
+		tuple.fromAddr, tuple.toAddr = ip.SrcIP.String(), ip.DstIP.String()
+	default:
+		return tuple, false, false
+	}
+	switch transport := packet.TransportLayer().(type) {
+	case *layers.TCP:
+		tuple.fromPort, tuple.toPort = uint16(transport.SrcPort), uint16(transport.DstPort)
+		return tuple, transport.SYN && !transport.ACK, true
+	case *layers.UDP:
+		tuple.fromPort, tuple.toPort = uint16(transport.SrcPort), uint16(transport.DstPort)
+		return tuple, false, true
+	}
+	return tuple, false, false
+}
+
+func capturedEndpointNode(hostID, addr string, port uint16, udp bool, bytes, packets int) report.Node {
+	id := report.MakeEndpointNodeID(hostID, "", addr, strconv.Itoa(int(port)))
+	if udp {
+		id = report.MakeUDPEndpointNodeID(hostID, "", addr, strconv.Itoa(int(port)))
+	}
+	node := report.MakeNode(id)
+	if packets > 0 {
+		node.Counters = node.Counters.Add(BytesSent, bytes).Add(PacketsSent, packets)
+	}
+	return node
+}
+
+// addCapturedDNS names the addresses of the A and AAAA records of a DNS
+// response after the queried names, following CNAMEs.
+func addCapturedDNS(rpt *report.Report, dns *layers.DNS) {
+	if len(dns.Questions) == 0 {
+		return
+	}
+	names := map[string][]string{}
+	for _, q := range dns.Questions {
+		names[string(q.Name)] = []string{string(q.Name)}
+	}
+	for _, answer := range dns.Answers {
+		aliases := names[string(answer.Name)]
+		switch answer.Type {
+		case layers.DNSTypeCNAME:
+			names[string(answer.CNAME)] = append(aliases, string(answer.CNAME))
+		case layers.DNSTypeA, layers.DNSTypeAAAA:
+			if len(aliases) == 0 {
+				aliases = []string{string(answer.Name)}
+			}
+			addr := answer.IP.String()
+			record := rpt.DNS[addr]
+			record.Forward = record.Forward.Add(aliases...)
+			rpt.DNS[addr] = record
+		}
+	}
+}
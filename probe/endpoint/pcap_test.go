@@ -0,0 +1,299 @@
+package endpoint_test
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"golang.org/x/net/http2/hpack"
+
+	"github.com/weaveworks/scope/probe/endpoint"
+	"github.com/weaveworks/scope/report"
+)
+
+// nextSeqs are the sequence numbers of the next segments of the TCP
+// senders of the captures, so that they reassemble in order.
+var nextSeqs = map[string]uint32{}
+
+func writePacket(t *testing.T, w *pcapgo.Writer, src, dst string, transport gopacket.SerializableLayer, payload []byte) {
+	writePacketData(t, w, packetData(t, w, src, dst, transport, payload))
+}
+
+// packetData is a packet of the capture, to be written later.
+func packetData(t *testing.T, w *pcapgo.Writer, src, dst string, transport gopacket.SerializableLayer, payload []byte) []byte {
+	ip := &layers.IPv4{Version: 4, TTL: 64, SrcIP: net.ParseIP(src), DstIP: net.ParseIP(dst)}
+	switch l := transport.(type) {
+	case *layers.TCP:
+		ip.Protocol = layers.IPProtocolTCP
+		l.SetNetworkLayerForChecksum(ip)
+		sender := fmt.Sprintf("%p %s:%d", w, src, l.SrcPort)
+		l.Seq = nextSeqs[sender]
+		nextSeqs[sender] += uint32(len(payload))
+		if l.SYN {
+			nextSeqs[sender]++
+		}
+	case *layers.UDP:
+		ip.Protocol = layers.IPProtocolUDP
+		l.SetNetworkLayerForChecksum(ip)
+	}
+	eth := &layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0, 0, 0, 0, 0, 1},
+		DstMAC:       net.HardwareAddr{0, 0, 0, 0, 0, 2},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, eth, ip, transport, gopacket.Payload(payload)); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func writePacketData(t *testing.T, w *pcapgo.Writer, data []byte) {
+	if err := w.WritePacket(gopacket.CaptureInfo{Timestamp: time.Now(), CaptureLength: len(data), Length: len(data)}, data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReportFromPcap(t *testing.T) {
+	capture := &bytes.Buffer{}
+	w := pcapgo.NewWriter(capture)
+	if err := w.WriteFileHeader(65536, layers.LinkTypeEthernet); err != nil {
+		t.Fatal(err)
+	}
+
+	// A DNS lookup, resolving a CNAME
+	dns := &layers.DNS{
+		ID: 1, QR: true,
+		Questions: []layers.DNSQuestion{{Name: []byte("www.example.com"), Type: layers.DNSTypeA, Class: layers.DNSClassIN}},
+		Answers: []layers.DNSResourceRecord{
+			{Name: []byte("www.example.com"), Type: layers.DNSTypeCNAME, Class: layers.DNSClassIN, CNAME: []byte("example.com")},
+			{Name: []byte("example.com"), Type: layers.DNSTypeA, Class: layers.DNSClassIN, IP: net.ParseIP("10.0.0.2")},
+		},
+	}
+	dnsBuf := gopacket.NewSerializeBuffer()
+	if err := dns.SerializeTo(dnsBuf, gopacket.SerializeOptions{FixLengths: true}); err != nil {
+		t.Fatal(err)
+	}
+	writePacket(t, w, "10.0.0.53", "10.0.0.1", &layers.UDP{SrcPort: 53, DstPort: 40000}, dnsBuf.Bytes())
+
+	// A connection to the resolved address: a SYN, a reply, and data
+	writePacket(t, w, "10.0.0.1", "10.0.0.2", &layers.TCP{SrcPort: 50000, DstPort: 443, SYN: true}, nil)
+	writePacket(t, w, "10.0.0.2", "10.0.0.1", &layers.TCP{SrcPort: 443, DstPort: 50000, SYN: true, ACK: true}, nil)
+	writePacket(t, w, "10.0.0.1", "10.0.0.2", &layers.TCP{SrcPort: 50000, DstPort: 443, ACK: true}, []byte("hello"))
+
+	rpt, err := endpoint.ReportFromPcap(capture, "host")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := report.MakeEndpointNodeID("host", "", "10.0.0.1", "50000")
+	server := report.MakeEndpointNodeID("host", "", "10.0.0.2", "443")
+	clientNode, ok := rpt.Endpoint.Nodes[client]
+	if !ok {
+		t.Fatalf("client endpoint missing: %v", rpt.Endpoint.Nodes)
+	}
+	if !clientNode.Adjacency.Contains(server) {
+		t.Errorf("expected an edge from client to server, got %v", clientNode.Adjacency)
+	}
+	if packets, _ := clientNode.Counters.Lookup(endpoint.PacketsSent); packets != 2 {
+		t.Errorf("expected 2 packets sent by the client, got %d", packets)
+	}
+	if packets, _ := rpt.Endpoint.Nodes[server].Counters.Lookup(endpoint.PacketsSent); packets != 1 {
+		t.Errorf("expected 1 packet sent by the server, got %d", packets)
+	}
+
+	// The DNS server is the UDP server, despite only its reply being captured
+	resolver := report.MakeUDPEndpointNodeID("host", "", "10.0.0.1", "40000")
+	if !rpt.Endpoint.Nodes[resolver].Adjacency.Contains(report.MakeUDPEndpointNodeID("host", "", "10.0.0.53", "53")) {
+		t.Errorf("expected an edge to the DNS server, got %v", rpt.Endpoint.Nodes[resolver].Adjacency)
+	}
+
+	names := rpt.DNS["10.0.0.2"].Forward
+	if !names.Contains("www.example.com") || !names.Contains("example.com") {
+		t.Errorf("expected the resolved names of 10.0.0.2, got %v", names)
+	}
+}
+
+// http2Headers is an HTTP/2 HEADERS frame of a request, on stream 1.
+func http2Headers(t *testing.T, enc *hpack.Encoder, buf *bytes.Buffer, fields ...string) []byte {
+	buf.Reset()
+	for i := 0; i < len(fields); i += 2 {
+		if err := enc.WriteField(hpack.HeaderField{Name: fields[i], Value: fields[i+1]}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	block := buf.Bytes()
+	frame := []byte{byte(len(block) >> 16), byte(len(block) >> 8), byte(len(block)), 0x1, 0x4, 0, 0, 0, 1}
+	return append(frame, block...)
+}
+
+func TestReportFromPcapHTTP2(t *testing.T) {
+	capture := &bytes.Buffer{}
+	w := pcapgo.NewWriter(capture)
+	if err := w.WriteFileHeader(65536, layers.LinkTypeEthernet); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		buf = &bytes.Buffer{}
+		enc = hpack.NewEncoder(buf)
+		get = func() []byte {
+			return http2Headers(t, enc, buf, ":method", "POST", ":path", "/pkg.Service/Get", "content-type", "application/grpc")
+		}
+		// Empty SETTINGS frame
+		settings = []byte{0, 0, 0, 0x4, 0, 0, 0, 0, 0}
+		client   = &layers.TCP{SrcPort: 50000, DstPort: 8080, ACK: true}
+	)
+	writePacket(t, w, "10.0.0.1", "10.0.0.2", &layers.TCP{SrcPort: 50000, DstPort: 8080, SYN: true}, nil)
+	// The preface, split across packets
+	writePacket(t, w, "10.0.0.1", "10.0.0.2", client, []byte("PRI * HTTP/2.0\r\n"))
+	writePacket(t, w, "10.0.0.1", "10.0.0.2", client, append([]byte("\r\nSM\r\n\r\n"), settings...))
+	writePacket(t, w, "10.0.0.1", "10.0.0.2", client, get())
+	// The second request refers to the dynamic table of the first, and
+	// the third is split across segments, captured out of order
+	writePacket(t, w, "10.0.0.1", "10.0.0.2", client, get())
+	var (
+		put    = http2Headers(t, enc, buf, ":method", "POST", ":path", "/pkg.Service/Put", "content-type", "application/grpc")
+		first  = packetData(t, w, "10.0.0.1", "10.0.0.2", client, put[:10])
+		second = packetData(t, w, "10.0.0.1", "10.0.0.2", client, put[10:])
+	)
+	writePacketData(t, w, second)
+	writePacketData(t, w, first)
+	// Not HTTP/2
+	writePacket(t, w, "10.0.0.1", "10.0.0.3", &layers.TCP{SrcPort: 50001, DstPort: 80, ACK: true}, []byte("GET / HTTP/1.1\r\n\r\n"))
+
+	rpt, err := endpoint.ReportFromPcap(capture, "host")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientNode := rpt.Endpoint.Nodes[report.MakeEndpointNodeID("host", "", "10.0.0.1", "50000")]
+	for name, want := range map[string]int{"pkg.Service/Get": 2, "pkg.Service/Put": 1} {
+		if have, _ := clientNode.Counters.Lookup(endpoint.RequestsKey(name)); have != want {
+			t.Errorf("expected %d requests to %s, got %d", want, name, have)
+		}
+	}
+	if protocol, _ := clientNode.Latest.Lookup(endpoint.L7Protocol); protocol != "grpc" {
+		t.Errorf("expected grpc, got %q", protocol)
+	}
+	if http1 := rpt.Endpoint.Nodes[report.MakeEndpointNodeID("host", "", "10.0.0.1", "50001")]; http1.Counters.Size() != 2 {
+		t.Errorf("expected no requests counted for HTTP/1, got %v", http1.Counters)
+	}
+}
+
+func TestReportFromPcapDatabase(t *testing.T) {
+	capture := &bytes.Buffer{}
+	w := pcapgo.NewWriter(capture)
+	if err := w.WriteFileHeader(65536, layers.LinkTypeEthernet); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		client  = &layers.TCP{SrcPort: 50000, DstPort: 6379, ACK: true}
+		server  = &layers.TCP{SrcPort: 6379, DstPort: 50000, ACK: true}
+		command = []byte("*2\r\n$3\r\nGET\r\n$3\r\nfoo\r\n")
+	)
+	writePacket(t, w, "10.0.0.1", "10.0.0.2", &layers.TCP{SrcPort: 50000, DstPort: 6379, SYN: true}, nil)
+	writePacket(t, w, "10.0.0.2", "10.0.0.1", &layers.TCP{SrcPort: 6379, DstPort: 50000, SYN: true, ACK: true}, nil)
+	writePacket(t, w, "10.0.0.1", "10.0.0.2", client, command)
+	writePacket(t, w, "10.0.0.2", "10.0.0.1", server, []byte("$3\r\nbar\r\n"))
+	// A command split across segments, captured out of order
+	var (
+		first  = packetData(t, w, "10.0.0.1", "10.0.0.2", client, command[:7])
+		second = packetData(t, w, "10.0.0.1", "10.0.0.2", client, command[7:])
+	)
+	writePacketData(t, w, second)
+	writePacketData(t, w, first)
+	writePacket(t, w, "10.0.0.2", "10.0.0.1", server, []byte("-ERR busy\r\n"))
+
+	rpt, err := endpoint.ReportFromPcap(capture, "host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientNode := rpt.Endpoint.Nodes[report.MakeEndpointNodeID("host", "", "10.0.0.1", "50000")]
+	if protocol, _ := clientNode.Latest.Lookup(endpoint.L7Protocol); protocol != "redis" {
+		t.Errorf("expected redis, got %q", protocol)
+	}
+	queries, _ := clientNode.Counters.Lookup(endpoint.Queries)
+	errors, _ := clientNode.Counters.Lookup(endpoint.QueryErrors)
+	if queries != 2 || errors != 1 {
+		t.Errorf("expected 2 queries and 1 error, got %d and %d", queries, errors)
+	}
+}
+
+func TestTopRequests(t *testing.T) {
+	counts := map[string]int{}
+	for i := 0; i < endpoint.MaxRequestNames+2; i++ {
+		counts[fmt.Sprintf("api%02d", i)] = 100 - i
+	}
+	top := endpoint.TopRequests(counts)
+	if len(top) != endpoint.MaxRequestNames+1 {
+		t.Errorf("expected %d APIs, got %v", endpoint.MaxRequestNames+1, top)
+	}
+	if have, want := top[endpoint.OtherRequests], 100-endpoint.MaxRequestNames+100-endpoint.MaxRequestNames-1; have != want {
+		t.Errorf("expected %d other requests, got %d", want, have)
+	}
+	if top["api00"] != 100 {
+		t.Errorf("expected the most called API to be kept, got %v", top)
+	}
+}
+
+// clientHello is a TLS record of a ClientHello asking for the server
+// name.
+func clientHello(serverName string) []byte {
+	name := append([]byte{0, byte(len(serverName) >> 8), byte(len(serverName))}, serverName...)
+	list := append([]byte{byte(len(name) >> 8), byte(len(name))}, name...)
+	extensions := append([]byte{
+		0, 0x0b, 0, 2, 1, 0, // ec point formats
+		0, 0, byte(len(list) >> 8), byte(len(list)),
+	}, list...)
+	hello := []byte{3, 3}
+	hello = append(hello, make([]byte, 32)...) // random
+	hello = append(hello, 0)                   // session ID
+	hello = append(hello, 0, 2, 0x13, 0x01)    // cipher suites
+	hello = append(hello, 1, 0)                // compression methods
+	hello = append(hello, byte(len(extensions)>>8), byte(len(extensions)))
+	hello = append(hello, extensions...)
+	handshake := append([]byte{1, 0, byte(len(hello) >> 8), byte(len(hello))}, hello...)
+	return append([]byte{0x16, 3, 1, byte(len(handshake) >> 8), byte(len(handshake))}, handshake...)
+}
+
+func TestReportFromPcapTLSServerName(t *testing.T) {
+	capture := &bytes.Buffer{}
+	w := pcapgo.NewWriter(capture)
+	if err := w.WriteFileHeader(65536, layers.LinkTypeEthernet); err != nil {
+		t.Fatal(err)
+	}
+
+	hello := clientHello("api.example.com")
+	writePacket(t, w, "10.0.0.1", "93.184.216.34", &layers.TCP{SrcPort: 50000, DstPort: 443, SYN: true}, nil)
+	// The ClientHello split across segments, captured out of order
+	var (
+		first  = packetData(t, w, "10.0.0.1", "93.184.216.34", &layers.TCP{SrcPort: 50000, DstPort: 443, ACK: true}, hello[:20])
+		second = packetData(t, w, "10.0.0.1", "93.184.216.34", &layers.TCP{SrcPort: 50000, DstPort: 443, ACK: true}, hello[20:])
+	)
+	writePacketData(t, w, second)
+	writePacketData(t, w, first)
+	// Not TLS
+	writePacket(t, w, "10.0.0.1", "93.184.216.34", &layers.TCP{SrcPort: 50001, DstPort: 80, SYN: true}, nil)
+	writePacket(t, w, "10.0.0.1", "93.184.216.34", &layers.TCP{SrcPort: 50001, DstPort: 80, ACK: true}, []byte("GET / HTTP/1.1\r\n\r\n"))
+
+	rpt, err := endpoint.ReportFromPcap(capture, "host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlsClient := rpt.Endpoint.Nodes[report.MakeEndpointNodeID("host", "", "10.0.0.1", "50000")]
+	if name, _ := tlsClient.Latest.Lookup(endpoint.TLSServerName); name != "api.example.com" {
+		t.Errorf("expected the server name api.example.com, got %q", name)
+	}
+	httpClient := rpt.Endpoint.Nodes[report.MakeEndpointNodeID("host", "", "10.0.0.1", "50001")]
+	if name, ok := httpClient.Latest.Lookup(endpoint.TLSServerName); ok {
+		t.Errorf("expected no server name, got %q", name)
+	}
+}
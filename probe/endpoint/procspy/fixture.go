@@ -23,5 +23,19 @@ func (s FixedScanner) Connections() (ConnIter, error) {
 	return &iter, nil
 }
 
+// Listeners implements ConnectionsScanner.Listeners (dummy since
+// FixedScanner only has connections)
+func (s FixedScanner) Listeners() (ConnIter, error) {
+	iter := fixedConnIter{}
+	return &iter, nil
+}
+
+// Sockets implements ConnectionsScanner.Sockets, returning the
+// connections.
+func (s FixedScanner) Sockets() (ConnIter, error) {
+	iter := fixedConnIter(s)
+	return &iter, nil
+}
+
 // Stop implements ConnectionsScanner.Stop (dummy since there is no background work)
 func (s FixedScanner) Stop() {}
@@ -14,3 +14,8 @@ func ReadTCPFiles(pid int, buf *bytes.Buffer) (int64, error) {
 func ReadNetnsFromPID(pid int) (uint64, error) {
 	return 0, fmt.Errorf("not supported on non-Linux systems")
 }
+
+// ReadUDPFiles reads the proc files udp and udp6 for a pid
+func ReadUDPFiles(pid int, buf *bytes.Buffer) (int64, error) {
+	return 0, fmt.Errorf("not supported on non-Linux systems")
+}
@@ -2,6 +2,7 @@ package procspy
 
 import (
 	"bytes"
+	"context"
 	"reflect"
 	"syscall"
 	"testing"
@@ -66,7 +67,7 @@ func TestWalkProcPid(t *testing.T) {
 	ticker := time.NewTicker(time.Millisecond)
 	defer ticker.Stop()
 	pWalker := newPidWalker(walker, ticker.C, 1)
-	have, err := pWalker.walk(&buf)
+	have, err := pWalker.walk(context.Background(), &buf)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -80,3 +81,82 @@ func TestWalkProcPid(t *testing.T) {
 		t.Fatalf("%+v", have)
 	}
 }
+
+func TestWalkProcPidNamespaces(t *testing.T) {
+	// Process 2 lives in its own network namespace, and owns a socket
+	// there. The other socket of the namespace has no known process.
+	procFS := fs.Dir("",
+		fs.Dir("proc",
+			fs.Dir("2",
+				fs.Dir("fd",
+					fs.File{
+						FName: "3",
+						FStat: syscall.Stat_t{
+							Ino:  7001,
+							Mode: syscall.S_IFSOCK,
+						},
+					},
+				),
+				fs.File{
+					FName:     "cmdline",
+					FContents: "bar",
+				},
+				fs.Dir("ns",
+					fs.File{
+						FName: "net",
+						FStat: syscall.Stat_t{Ino: 42},
+					},
+				),
+				fs.Dir("net",
+					fs.File{
+						FName: "tcp",
+						FContents: `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0100007F:1F90 0100007F:C350 01 00000000:00000000 00:00000000 00000000     0        0 7001 1 ffff8800a6aaf040 100 0 0 10 0
+   1: 0100007F:C350 0100007F:1F90 01 00000000:00000000 00:00000000 00000000     0        0 7002 1 ffff8800a6aaf140 100 0 0 10 0
+`,
+					},
+					fs.File{
+						FName: "tcp6",
+					},
+					fs.File{
+						FName: "udp",
+						FContents: `   sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops
+  0: 00000000:0035 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 7003 2 ffff8800a6aaf840 0
+`,
+					},
+					fs.File{
+						FName: "udp6",
+					},
+				),
+				fs.File{
+					FName:     "stat",
+					FContents: "2 na R 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 1 0 0 0 0 0",
+				},
+				fs.File{
+					FName:     "limits",
+					FContents: "",
+				},
+			),
+		),
+	)
+	fs_hook.Mock(procFS)
+	defer fs_hook.Restore()
+
+	buf := bytes.Buffer{}
+	walker := process.NewWalker(procRoot, false)
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	pWalker := newPidWalker(walker, ticker.C, 1)
+	have, err := pWalker.walk(context.Background(), &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[uint64]*Proc{
+		7001: {PID: 2, Name: "bar", NetNamespaceID: 42},
+		7002: {NetNamespaceID: 42},
+		7003: {NetNamespaceID: 42},
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Fatalf("%+v", have)
+	}
+}
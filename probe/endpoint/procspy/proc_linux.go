@@ -4,6 +4,7 @@ package procspy
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"path/filepath"
 	"strconv"
@@ -11,7 +12,6 @@ import (
 	"time"
 
 	"github.com/armon/go-metrics"
-	log "github.com/sirupsen/logrus"
 
 	"github.com/weaveworks/common/fs"
 	"github.com/weaveworks/scope/probe/process"
@@ -119,6 +119,17 @@ func ReadTCPFiles(pid int, buf *bytes.Buffer) (int64, error) {
 	return read + read6, errRead6
 }
 
+// ReadUDPFiles reads the proc files udp and udp6 for a pid
+func ReadUDPFiles(pid int, buf *bytes.Buffer) (int64, error) {
+	dirName := strconv.Itoa(pid)
+	read, err := readFile(filepath.Join(procRoot, dirName, "/net/udp"), buf)
+	if err != nil || !ipv6IsSupported {
+		return read, err
+	}
+	read6, err := readFile(filepath.Join(procRoot, dirName, "/net/udp6"), buf)
+	return read + read6, err
+}
+
 // Read the connections for a group of processes living in the same namespace,
 // which are found (identically) in /proc/PID/net/tcp{,6} for any of the
 // processes.
@@ -133,6 +144,10 @@ func readProcessConnections(buf *bytes.Buffer, namespaceProcs []*process.Process
 			// try next process
 			continue
 		}
+		// UDP tables are a bonus, so a process without them is fine
+		if readUDP, err := ReadUDPFiles(p.PID, buf); err == nil {
+			read += readUDP
+		}
 		// Return after succeeding on any process
 		// (proc/PID/net/tcp and proc/PID/net/tcp6 are identical for all the processes in the same namespace)
 		return read > 0, nil
@@ -148,9 +163,11 @@ func readProcessConnections(buf *bytes.Buffer, namespaceProcs []*process.Process
 // walkNamespace does the work of walk for a single namespace
 func (w pidWalker) walkNamespace(namespaceID uint64, buf *bytes.Buffer, sockets map[uint64]*Proc, namespaceProcs []*process.Process) error {
 
+	start := buf.Len()
 	if found, err := readProcessConnections(buf, namespaceProcs); err != nil || !found {
 		return err
 	}
+	markNamespace(buf.Bytes()[start:], namespaceID, sockets)
 
 	var statT syscall.Stat_t
 	var fdBlockCount uint64
@@ -171,9 +188,13 @@ func (w pidWalker) walkNamespace(namespaceID uint64, buf *bytes.Buffer, sockets
 			fdBlockCount = 0
 			// read the connections again to
 			// avoid the race between between /net/tcp{,6} and /proc/PID/fd/*
+			start = buf.Len()
 			if found, err := readProcessConnections(buf, namespaceProcs[i:]); err != nil || !found {
 				return err
 			}
+			// The sockets without inodes were read already
+			buf.Truncate(start + len(withoutInodeless(buf.Bytes()[start:])))
+			markNamespace(buf.Bytes()[start:], namespaceID, sockets)
 		}
 
 		fds, err := fs.ReadDirNames(fdBase)
@@ -215,6 +236,22 @@ func (w pidWalker) walkNamespace(namespaceID uint64, buf *bytes.Buffer, sockets
 	return nil
 }
 
+// markNamespace attributes every socket in the tables of a namespace to
+// that namespace, so that sockets whose process can't be found (it is
+// gone, or its fds can't be read) still get scoped correctly. Sockets
+// found in the fds of a process are attributed to it afterwards.
+func markNamespace(tables []byte, namespaceID uint64, sockets map[uint64]*Proc) {
+	namespace := &Proc{NetNamespaceID: namespaceID}
+	for _, newProcNet := range []func([]byte) *ProcNet{NewProcNet, NewListeningProcNet} {
+		pn := newProcNet(tables)
+		for c := pn.Next(); c != nil; c = pn.Next() {
+			if _, ok := sockets[c.Inode]; !ok {
+				sockets[c.Inode] = namespace
+			}
+		}
+	}
+}
+
 // ReadNetnsFromPID gets the netns inode of the specified pid
 func ReadNetnsFromPID(pid int) (uint64, error) {
 	var statT syscall.Stat_t
@@ -229,10 +266,11 @@ func ReadNetnsFromPID(pid int) (uint64, error) {
 }
 
 // walk walks over all numerical (PID) /proc entries. It reads
-// /proc/PID/net/tcp{,6} for each namespace and sees if the ./fd/* files of each
+// /proc/PID/net/{tcp,udp}{,6} once for each distinct network namespace,
+// and sees if the ./fd/* files of each
 // process in that namespace are symlinks to sockets. Returns a map from socket
 // ID (inode) to PID.
-func (w pidWalker) walk(buf *bytes.Buffer) (map[uint64]*Proc, error) {
+func (w pidWalker) walk(ctx context.Context, buf *bytes.Buffer) (map[uint64]*Proc, error) {
 	var (
 		sockets    = map[uint64]*Proc{}              // map socket inode -> process
 		namespaces = map[uint64][]*process.Process{} // map network namespace id -> processes
@@ -246,7 +284,7 @@ func (w pidWalker) walk(buf *bytes.Buffer) (map[uint64]*Proc, error) {
 	// between reading /net/tcp{,6} of each namespace and /proc/PID/fd/* for
 	// the processes living in that namespace.
 
-	w.walker.Walk(func(p, _ process.Process) {
+	w.walker.Walk(ctx, func(p, _ process.Process) {
 		namespaceID, err := ReadNetnsFromPID(p.PID)
 		if err != nil {
 			return
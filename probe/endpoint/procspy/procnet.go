@@ -5,26 +5,52 @@ import (
 	"net"
 )
 
-// Used to check whether we are parsing a header line
-var slHeader = []byte("sl")
+var (
+	// Used to check whether we are parsing a header line
+	slHeader = []byte("sl")
+	// Only the header of /proc/net/udp{,6} has a drops column, which
+	// tells the tables apart when they are read into the same buffer
+	udpHeader = []byte("drops")
+)
 
-// ProcNet is an iterator to parse /proc/net/tcp{,6} files.
+// ProcNet is an iterator to parse /proc/net/tcp{,6} and /proc/net/udp{,6}
+// files.
 type ProcNet struct {
 	b                       []byte
 	c                       Connection
 	bytesLocal, bytesRemote [16]byte
 	seen                    map[uint64]struct{}
+	listening               bool
+	all                     bool
+	transport               string
 }
 
 // NewProcNet gives a new ProcNet parser.
 func NewProcNet(b []byte) *ProcNet {
 	return &ProcNet{
-		b:    b,
-		c:    Connection{},
-		seen: map[uint64]struct{}{},
+		b:         b,
+		c:         Connection{},
+		seen:      map[uint64]struct{}{},
+		transport: "tcp",
 	}
 }
 
+// NewListeningProcNet gives a new ProcNet parser which only returns
+// listening sockets.
+func NewListeningProcNet(b []byte) *ProcNet {
+	p := NewProcNet(b)
+	p.listening = true
+	return p
+}
+
+// NewSocketProcNet gives a new ProcNet parser which returns all TCP
+// sockets, in any state.
+func NewSocketProcNet(b []byte) *ProcNet {
+	p := NewProcNet(b)
+	p.all = true
+	return p
+}
+
 // Next returns the next connection. All buffers are re-used, so if you want
 // to keep the IPs you have to copy them.
 func (p *ProcNet) Next() *Connection {
@@ -40,17 +66,23 @@ again:
 
 	sl, b = nextField(b) // 'sl' column
 	if bytes.Equal(sl, slHeader) {
-		// Skip header
-		p.b = nextLine(b)
+		// Skip header, noting which table follows it
+		next := nextLine(b)
+		header := b
+		if next != nil {
+			header = b[:len(b)-len(next)]
+		}
+		p.transport = "tcp"
+		if bytes.Contains(header, udpHeader) {
+			p.transport = "udp"
+		}
+		p.b = next
 		goto again
 	}
 	local, b = nextField(b)
 	remote, b = nextField(b)
 	state, b = nextField(b)
-	switch parseHex(state) {
-	// Only process established or half-closed connections
-	case tcpEstablished, tcpFinWait1, tcpFinWait2, tcpCloseWait:
-	default:
+	if !p.wanted(parseHex(state)) {
 		p.b = nextLine(b)
 		goto again
 	}
@@ -64,7 +96,15 @@ again:
 	p.c.LocalAddress, p.c.LocalPort = scanAddressNA(local, &p.bytesLocal)
 	p.c.RemoteAddress, p.c.RemotePort = scanAddressNA(remote, &p.bytesRemote)
 	p.c.Inode = parseDec(inode)
+	p.c.Transport = p.transport
 	p.b = nextLine(b)
+	if p.all {
+		p.c.State = parseHex(state)
+		// Sockets without inodes (in TIME_WAIT) are all different
+		if p.c.Inode == 0 {
+			return &p.c
+		}
+	}
 	if _, alreadySeen := p.seen[p.c.Inode]; alreadySeen {
 		goto again
 	}
@@ -72,6 +112,33 @@ again:
 	return &p.c
 }
 
+// wanted is whether sockets in the given state are returned. Only
+// established or half-closed connections are, or listening sockets if
+// asked to, unless all TCP sockets are. Connected UDP sockets are reported
+// as established, and bound but unconnected ones are the UDP equivalent
+// of listening.
+func (p *ProcNet) wanted(state uint) bool {
+	if p.all {
+		return p.transport == "tcp"
+	}
+	if p.transport == "udp" {
+		switch state {
+		case tcpEstablished:
+			return !p.listening
+		case udpUnconnected:
+			return p.listening
+		}
+		return false
+	}
+	switch state {
+	case tcpEstablished, tcpFinWait1, tcpFinWait2, tcpCloseWait:
+		return !p.listening
+	case tcpListen:
+		return p.listening
+	}
+	return false
+}
+
 // scanAddressNA parses 'A12CF62E:00AA' to the address/port. Handles IPv4 and
 // IPv6 addresses. The address is a big endian 32 bit ints, hex encoded. We
 // just decode the hex and flip the bytes in every group of 4.
@@ -118,6 +185,31 @@ func nextField(s []byte) ([]byte, []byte) {
 	return nil, nil
 }
 
+// withoutInodeless drops the sockets without inodes, in TIME_WAIT, from
+// tables, in place. Sockets with inodes are told apart by them when
+// tables are read again, but those without would be seen twice.
+func withoutInodeless(tables []byte) []byte {
+	result := tables[:0]
+	for b := tables; len(b) > 0; {
+		next := nextLine(b)
+		line := b[:len(b)-len(next)]
+		b = next
+
+		field, rest := nextField(line)
+		if !bytes.Equal(field, slHeader) {
+			// 'local_address' to 'timeout' columns, as parsed by Next
+			for i := 0; i < 8; i++ {
+				_, rest = nextField(rest)
+			}
+			if inode, _ := nextField(rest); parseDec(inode) == 0 {
+				continue
+			}
+		}
+		result = append(result, line...)
+	}
+	return result
+}
+
 func nextLine(s []byte) []byte {
 	i := bytes.IndexByte(s, '\n')
 	if i == -1 {
@@ -16,6 +16,7 @@ func TestProcNet(t *testing.T) {
 	p := NewProcNet([]byte(testString))
 	expected := []Connection{
 		{
+			Transport:     "tcp",
 			LocalAddress:  net.IP([]byte{0, 0, 0, 0}),
 			LocalPort:     0xa6c0,
 			RemoteAddress: net.IP([]byte{0, 0, 0, 0}),
@@ -23,6 +24,7 @@ func TestProcNet(t *testing.T) {
 			Inode:         5107,
 		},
 		{
+			Transport:     "tcp",
 			LocalAddress:  net.IP([]byte{0, 0, 0, 0}),
 			LocalPort:     0x006f,
 			RemoteAddress: net.IP([]byte{0, 0, 0, 0}),
@@ -30,6 +32,7 @@ func TestProcNet(t *testing.T) {
 			Inode:         5084,
 		},
 		{
+			Transport:     "tcp",
 			LocalAddress:  net.IP([]byte{0x7f, 0x0, 0x0, 0x01}),
 			LocalPort:     0x0019,
 			RemoteAddress: net.IP([]byte{0, 0, 0, 0}),
@@ -37,6 +40,7 @@ func TestProcNet(t *testing.T) {
 			Inode:         10550,
 		},
 		{
+			Transport:     "tcp",
 			LocalAddress:  net.IP([]byte{0x2e, 0xf6, 0x2c, 0xa1}),
 			LocalPort:     0xe4d7,
 			RemoteAddress: net.IP([]byte{0xc0, 0x1e, 0xfc, 0x57}),
@@ -67,6 +71,7 @@ func TestTransport6(t *testing.T) {
 	p := NewProcNet([]byte(testString))
 	expected := []Connection{
 		{
+			Transport: "tcp",
 			// state:         10,
 			LocalAddress:  net.IP(make([]byte, 16)),
 			LocalPort:     0x19c8,
@@ -76,6 +81,7 @@ func TestTransport6(t *testing.T) {
 			Inode: 23661201,
 		},
 		{
+			Transport: "tcp",
 			// state: 1,
 			LocalAddress: net.IP([]byte{
 				0x20, 0x03, 0, 0x45,
@@ -117,6 +123,7 @@ broken line
 	p := NewProcNet([]byte(testString))
 	expected := []Connection{
 		{
+			Transport:     "tcp",
 			LocalAddress:  net.IP([]byte{0, 0, 0, 0}),
 			LocalPort:     0xa6c0,
 			RemoteAddress: net.IP([]byte{0, 0, 0, 0}),
@@ -144,6 +151,7 @@ func TestProcNetFiltersDuplicates(t *testing.T) {
 `
 	p := NewProcNet([]byte(testString))
 	expected := Connection{
+		Transport:     "tcp",
 		LocalAddress:  net.IP([]byte{0, 0, 0, 0}),
 		LocalPort:     0xa6c0,
 		RemoteAddress: net.IP([]byte{0, 0, 0, 0}),
@@ -160,3 +168,90 @@ func TestProcNetFiltersDuplicates(t *testing.T) {
 	}
 
 }
+
+func TestListeningProcNet(t *testing.T) {
+	testString := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout Inode                                                     
+   0: 00000000:0050 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 5107 1 ffff8800a6aaf040 100 0 0 10 0                      
+   1: A12CF62E:E4D7 57FC1EC0:01BB 01 00000000:00000000 02:000006FA 00000000  1000        0 639474 2 ffff88007e75a740 48 4 26 10 -1                   
+`
+	p := NewListeningProcNet([]byte(testString))
+	want := Connection{
+		Transport:     "tcp",
+		LocalAddress:  net.IP([]byte{0, 0, 0, 0}),
+		LocalPort:     80,
+		RemoteAddress: net.IP([]byte{0, 0, 0, 0}),
+		RemotePort:    0,
+		Inode:         5107,
+	}
+	if have := p.Next(); have == nil || !reflect.DeepEqual(*have, want) {
+		t.Errorf("Got\n%+v\nExpected\n%+v\n", have, want)
+	}
+	if got := p.Next(); got != nil {
+		t.Errorf("p.Next() wasn't empty")
+	}
+}
+
+func TestUDPProcNet(t *testing.T) {
+	testString := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:A6C0 00000000:0000 01 00000000:00000000 00:00000000 00000000   105        0 5107 1 ffff8800a6aaf040 100 0 0 10 0
+   sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops
+  0: 00000000:0035 00000000:0000 07 00000000:00000000 00:00000000 00000000     0        0 6001 2 ffff8800a6aaf840 0
+  1: 0100007F:C350 0100007F:0035 01 00000000:00000000 00:00000000 00000000     0        0 6002 2 ffff8800a6aaf940 0
+`
+	for _, c := range []struct {
+		p    *ProcNet
+		want []Connection
+	}{
+		{NewProcNet([]byte(testString)), []Connection{
+			{Transport: "tcp", LocalAddress: net.IP([]byte{0, 0, 0, 0}), LocalPort: 0xa6c0, RemoteAddress: net.IP([]byte{0, 0, 0, 0}), Inode: 5107},
+			{Transport: "udp", LocalAddress: net.IP([]byte{127, 0, 0, 1}), LocalPort: 50000, RemoteAddress: net.IP([]byte{127, 0, 0, 1}), RemotePort: 53, Inode: 6002},
+		}},
+		{NewListeningProcNet([]byte(testString)), []Connection{
+			{Transport: "udp", LocalAddress: net.IP([]byte{0, 0, 0, 0}), LocalPort: 53, RemoteAddress: net.IP([]byte{0, 0, 0, 0}), Inode: 6001},
+		}},
+	} {
+		for _, want := range c.want {
+			if have := c.p.Next(); have == nil || !reflect.DeepEqual(*have, want) {
+				t.Errorf("Got\n%+v\nExpected\n%+v\n", have, want)
+			}
+		}
+		if got := c.p.Next(); got != nil {
+			t.Errorf("p.Next() wasn't empty")
+		}
+	}
+}
+
+func TestSocketProcNet(t *testing.T) {
+	// A listening socket, a connection, and two sockets in TIME_WAIT
+	testString := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout Inode
+   0: 00000000:A6C0 00000000:0000 0A 00000000:00000000 00:00000000 00000000   105        0 5107 1 ffff8800a6aaf040 100 0 0 10 0
+   1: A12CF62E:E4D7 57FC1EC0:01BB 01 00000000:00000000 02:000006FA 00000000  1000        0 639474 2 ffff88007e75a740 48 4 26 10 -1
+   2: A12CF62E:E4D8 57FC1EC0:01BB 06 00000000:00000000 03:00000F6B 00000000     0        0 0 3 ffff88007e75a740
+   3: A12CF62E:E4D9 57FC1EC0:01BB 06 00000000:00000000 03:00000F6B 00000000     0        0 0 3 ffff88007e75a740
+`
+	p := NewSocketProcNet([]byte(testString))
+	var states []string
+	for c := p.Next(); c != nil; c = p.Next() {
+		states = append(states, TCPState(c.State))
+	}
+	if want := []string{"LISTEN", "ESTABLISHED", "TIME_WAIT", "TIME_WAIT"}; !reflect.DeepEqual(want, states) {
+		t.Errorf("want %v, have %v", want, states)
+	}
+}
+
+func TestSocketProcNetReadAgain(t *testing.T) {
+	table := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout Inode
+   0: A12CF62E:E4D7 57FC1EC0:01BB 01 00000000:00000000 02:000006FA 00000000  1000        0 639474 2 ffff88007e75a740 48 4 26 10 -1
+   1: A12CF62E:E4D8 57FC1EC0:01BB 06 00000000:00000000 03:00000F6B 00000000     0        0 0 3 ffff88007e75a740
+`
+	// The tables of a namespace read again, after those read first
+	tables := append([]byte(table), withoutInodeless([]byte(table))...)
+	p := NewSocketProcNet(tables)
+	var states []string
+	for c := p.Next(); c != nil; c = p.Next() {
+		states = append(states, TCPState(c.State))
+	}
+	if want := []string{"ESTABLISHED", "TIME_WAIT"}; !reflect.DeepEqual(want, states) {
+		t.Errorf("want %v, have %v", want, states)
+	}
+}
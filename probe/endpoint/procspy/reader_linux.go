@@ -2,12 +2,11 @@ package procspy
 
 import (
 	"bytes"
+	"context"
 	"io"
 	"sync"
 	"time"
 
-	log "github.com/sirupsen/logrus"
-
 	"github.com/weaveworks/scope/probe/process"
 )
 
@@ -27,6 +26,7 @@ type reader interface {
 
 type backgroundReader struct {
 	stopc         chan struct{}
+	cancel        context.CancelFunc // of the walks, on stopping
 	mtx           sync.Mutex
 	latestBuf     *bytes.Buffer
 	latestSockets map[uint64]*Proc
@@ -35,15 +35,18 @@ type backgroundReader struct {
 // starts a rate-limited background goroutine to read the expensive files from
 // proc.
 func newBackgroundReader(walker process.Walker) reader {
+	ctx, cancel := context.WithCancel(context.Background())
 	br := &backgroundReader{
 		stopc:         make(chan struct{}),
+		cancel:        cancel,
 		latestSockets: map[uint64]*Proc{},
 	}
-	go br.loop(walker)
+	go br.loop(ctx, walker)
 	return br
 }
 
 func (br *backgroundReader) stop() {
+	br.cancel()
 	close(br.stopc)
 }
 
@@ -61,7 +64,7 @@ func (br *backgroundReader) getWalkedProcPid(buf *bytes.Buffer) (map[uint64]*Pro
 	return br.latestSockets, err
 }
 
-func (br *backgroundReader) loop(walker process.Walker) {
+func (br *backgroundReader) loop(ctx context.Context, walker process.Walker) {
 	var (
 		begin           time.Time                      // when we started the last performWalk
 		tickc           = time.After(time.Millisecond) // fire immediately
@@ -75,10 +78,10 @@ func (br *backgroundReader) loop(walker process.Walker) {
 	for {
 		select {
 		case <-tickc:
-			tickc = nil                      // turn off until the next loop
-			walkc = make(chan walkResult, 1) // turn on (need buffered so we don't leak performWalk)
-			begin = time.Now()               // reset counter
-			go performWalk(pWalker, walkc)   // do work
+			tickc = nil                         // turn off until the next loop
+			walkc = make(chan walkResult, 1)    // turn on (need buffered so we don't leak performWalk)
+			begin = time.Now()                  // reset counter
+			go performWalk(ctx, pWalker, walkc) // do work
 
 		case result := <-walkc:
 			// Expose results
@@ -113,7 +116,7 @@ type foregroundReader struct {
 }
 
 // reads synchronously files from /proc
-func newForegroundReader(walker process.Walker) reader {
+func newForegroundReader(ctx context.Context, walker process.Walker) reader {
 	fr := &foregroundReader{
 		stopc:         make(chan struct{}),
 		latestSockets: map[uint64]*Proc{},
@@ -124,7 +127,7 @@ func newForegroundReader(walker process.Walker) reader {
 		pWalker = newPidWalker(walker, ticker.C, fdBlockSize)
 	)
 
-	go performWalk(pWalker, walkc)
+	go performWalk(ctx, pWalker, walkc)
 
 	result := <-walkc
 	fr.latestBuf = result.buf
@@ -153,7 +156,7 @@ type walkResult struct {
 	sockets map[uint64]*Proc
 }
 
-func performWalk(w pidWalker, c chan<- walkResult) {
+func performWalk(ctx context.Context, w pidWalker, c chan<- walkResult) {
 	var (
 		err    error
 		result = walkResult{
@@ -161,7 +164,7 @@ func performWalk(w pidWalker, c chan<- walkResult) {
 		}
 	)
 
-	result.sockets, err = w.walk(result.buf)
+	result.sockets, err = w.walk(ctx, result.buf)
 	if err != nil {
 		log.Errorf("background /proc reader: error walking /proc: %s", err)
 		result.buf.Reset()
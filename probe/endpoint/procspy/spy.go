@@ -5,17 +5,51 @@ package procspy
 
 import (
 	"net"
+
+	"github.com/weaveworks/scope/common/xlog"
 )
 
+// log is the logger of the procspy component
+var log = xlog.Component("procspy")
+
 const (
 	// according to /include/net/tcp_states.h
 	tcpEstablished = 1
+	tcpSynSent     = 2
 	tcpFinWait1    = 4
 	tcpFinWait2    = 5
+	tcpTimeWait    = 6
 	tcpCloseWait   = 8
+	tcpListen      = 10
+
+	// UDP sockets use the TCP states, and the ones which aren't connected
+	// are closed
+	udpUnconnected = 7
 )
 
-// Connection is a (TCP) connection. The Proc struct might not be filled in.
+// TCP states of sockets, by their name in /include/net/tcp_states.h
+var tcpStates = map[uint]string{
+	tcpEstablished: "ESTABLISHED",
+	tcpSynSent:     "SYN_SENT",
+	3:              "SYN_RECV",
+	tcpFinWait1:    "FIN_WAIT1",
+	tcpFinWait2:    "FIN_WAIT2",
+	tcpTimeWait:    "TIME_WAIT",
+	7:              "CLOSE",
+	tcpCloseWait:   "CLOSE_WAIT",
+	9:              "LAST_ACK",
+	tcpListen:      "LISTEN",
+	11:             "CLOSING",
+}
+
+// TCPState returns the name of the TCP state of a socket, e.g.
+// TIME_WAIT.
+func TCPState(state uint) string {
+	return tcpStates[state]
+}
+
+// Connection is a TCP or UDP connection. The Proc struct might not be
+// filled in.
 type Connection struct {
 	Transport     string
 	LocalAddress  net.IP
@@ -23,6 +57,7 @@ type Connection struct {
 	RemoteAddress net.IP
 	RemotePort    uint16
 	Inode         uint64
+	State         uint // only set by ConnectionScanner.Sockets
 	Proc          Proc
 }
 
@@ -38,10 +73,16 @@ type ConnIter interface {
 	Next() *Connection
 }
 
-// ConnectionScanner scans the system for established (TCP) connections
+// ConnectionScanner scans the system for established connections
 type ConnectionScanner interface {
-	// Connections returns all established (TCP) connections.
+	// Connections returns all established connections.
 	Connections() (ConnIter, error)
+	// Listeners returns all listening sockets. Their remote address and
+	// port are unset.
+	Listeners() (ConnIter, error)
+	// Sockets returns all TCP sockets, whatever their state. Sockets in
+	// TIME_WAIT belong to no process.
+	Sockets() (ConnIter, error)
 	// Stops the scanning
 	Stop()
 }
@@ -1,6 +1,7 @@
 package procspy
 
 import (
+	"context"
 	"net"
 	"os/exec"
 	"strconv"
@@ -19,7 +20,7 @@ func NewConnectionScanner(_ process.Walker, processes bool) ConnectionScanner {
 }
 
 // NewSyncConnectionScanner creates a new synchronous Darwin ConnectionScanner
-func NewSyncConnectionScanner(_ process.Walker, processes bool) ConnectionScanner {
+func NewSyncConnectionScanner(_ context.Context, _ process.Walker, processes bool) ConnectionScanner {
 	return &darwinScanner{processes}
 }
 
@@ -75,5 +76,19 @@ func (s *darwinScanner) Connections() (ConnIter, error) {
 	return &f, nil
 }
 
+// Listeners returns no sockets, since netstat only reports established
+// connections the way we call it.
+func (s *darwinScanner) Listeners() (ConnIter, error) {
+	f := fixedConnIter(nil)
+	return &f, nil
+}
+
+// Sockets returns no sockets, since netstat only reports established
+// connections the way we call it.
+func (s *darwinScanner) Sockets() (ConnIter, error) {
+	f := fixedConnIter(nil)
+	return &f, nil
+}
+
 // Nothing to stop since there's nothing running in the background
 func (s *darwinScanner) Stop() {}
@@ -2,6 +2,7 @@ package procspy
 
 import (
 	"bytes"
+	"context"
 	"sync"
 
 	"github.com/weaveworks/scope/probe/process"
@@ -46,11 +47,12 @@ func NewConnectionScanner(walker process.Walker, processes bool) ConnectionScann
 	return scanner
 }
 
-// NewSyncConnectionScanner creates a new synchronous Linux ConnectionScanner
-func NewSyncConnectionScanner(walker process.Walker, processes bool) ConnectionScanner {
+// NewSyncConnectionScanner creates a new synchronous Linux ConnectionScanner,
+// walking the processes until ctx is cancelled
+func NewSyncConnectionScanner(ctx context.Context, walker process.Walker, processes bool) ConnectionScanner {
 	scanner := &linuxScanner{}
 	if processes {
-		scanner.r = newForegroundReader(walker)
+		scanner.r = newForegroundReader(ctx, walker)
 	}
 	return scanner
 }
@@ -60,6 +62,18 @@ type linuxScanner struct {
 }
 
 func (s *linuxScanner) Connections() (ConnIter, error) {
+	return s.scan(NewProcNet)
+}
+
+func (s *linuxScanner) Listeners() (ConnIter, error) {
+	return s.scan(NewListeningProcNet)
+}
+
+func (s *linuxScanner) Sockets() (ConnIter, error) {
+	return s.scan(NewSocketProcNet)
+}
+
+func (s *linuxScanner) scan(newProcNet func([]byte) *ProcNet) (ConnIter, error) {
 	// buffer for contents of /proc/<pid>/net/tcp
 	buf := bufPool.Get().(*bytes.Buffer)
 	buf.Reset()
@@ -77,10 +91,14 @@ func (s *linuxScanner) Connections() (ConnIter, error) {
 		if ipv6IsSupported {
 			readFile(procRoot+"/net/tcp6", buf)
 		}
+		readFile(procRoot+"/net/udp", buf)
+		if ipv6IsSupported {
+			readFile(procRoot+"/net/udp6", buf)
+		}
 	}
 
 	return &pnConnIter{
-		pn:    NewProcNet(buf.Bytes()),
+		pn:    newProcNet(buf.Bytes()),
 		buf:   buf,
 		procs: procs,
 	}, nil
@@ -26,6 +26,7 @@ func TestLinuxConnections(t *testing.T) {
 	}
 	have := iter.Next()
 	want := &Connection{
+		Transport:     "tcp",
 		LocalAddress:  net.ParseIP("0.0.0.0").To4(),
 		LocalPort:     42688,
 		RemoteAddress: net.ParseIP("0.0.0.0").To4(),
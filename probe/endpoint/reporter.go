@@ -1,19 +1,28 @@
 package endpoint
 
 import (
+	"context"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/weaveworks/scope/common/xlog"
 	"github.com/weaveworks/scope/probe/endpoint/procspy"
 	"github.com/weaveworks/scope/probe/process"
 	"github.com/weaveworks/scope/report"
 )
 
+// log is the logger of the endpoint component
+var log = xlog.Component("endpoint").WithField("topology", report.Endpoint)
+
 // Node metadata keys.
 const (
-	ReverseDNSNames = report.ReverseDNSNames
-	SnoopedDNSNames = report.SnoopedDNSNames
-	CopyOf          = report.CopyOf
+	ReverseDNSNames    = report.ReverseDNSNames
+	SnoopedDNSNames    = report.SnoopedDNSNames
+	CopyOf             = report.CopyOf
+	ConnectionFailures = report.ConnectionFailures
+	ConnectionsOpened  = report.ConnectionsOpened
+	ConnectionsClosed  = report.ConnectionsClosed
+	Listening          = report.Listening
 )
 
 // ReporterConfig are the config options for the endpoint reporter.
@@ -24,11 +33,13 @@ type ReporterConfig struct {
 	UseConntrack bool
 	WalkProc     bool
 	UseEbpfConn  bool
+	UseIPVS      bool
 	ProcRoot     string
 	BufferSize   int
 	ProcessCache *process.CachingWalker
 	Scanner      procspy.ConnectionScanner
 	DNSSnooper   *DNSSnooper
+	L7Sniffer    *L7Sniffer
 }
 
 // Reporter generates Reports containing the Endpoint topology.
@@ -78,6 +89,9 @@ func NewReporter(conf ReporterConfig) *Reporter {
 // Name of this reporter, for metrics gathering
 func (Reporter) Name() string { return "Endpoint" }
 
+// Topologies of the reporter, for annotating them when it fails.
+func (Reporter) Topologies() []string { return []string{report.Endpoint} }
+
 // Stop stop stop
 func (r *Reporter) Stop() {
 	r.connectionTracker.Stop()
@@ -88,7 +102,7 @@ func (r *Reporter) Stop() {
 }
 
 // Report implements Reporter.
-func (r *Reporter) Report() (report.Report, error) {
+func (r *Reporter) Report(context.Context) (report.Report, error) {
 	defer func(begin time.Time) {
 		SpyDuration.WithLabelValues().Observe(time.Since(begin).Seconds())
 	}(time.Now())
@@ -97,5 +111,11 @@ func (r *Reporter) Report() (report.Report, error) {
 
 	r.connectionTracker.ReportConnections(&rpt)
 	r.natMapper.applyNAT(rpt, r.conf.HostID)
+	r.conf.L7Sniffer.report(&rpt, r.conf.HostID)
+	if r.conf.UseIPVS {
+		if err := r.addVIPs(&rpt); err != nil {
+			log.Warnf("reading the IPVS table: %v", err)
+		}
+	}
 	return rpt, nil
 }
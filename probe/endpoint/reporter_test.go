@@ -1,6 +1,7 @@
 package endpoint_test
 
 import (
+	"context"
 	"net"
 	"strconv"
 	"testing"
@@ -77,7 +78,7 @@ func TestSpyNoProcesses(t *testing.T) {
 		BufferSize: bufferSize,
 		Scanner:    scanner,
 	})
-	r, _ := reporter.Report()
+	r, _ := reporter.Report(context.Background())
 	//buf, _ := json.MarshalIndent(r, "", "    ")
 	//t.Logf("\n%s\n", buf)
 
@@ -101,7 +102,7 @@ func TestSpyWithProcesses(t *testing.T) {
 		BufferSize: bufferSize,
 		Scanner:    scanner,
 	})
-	r, _ := reporter.Report()
+	r, _ := reporter.Report(context.Background())
 	// buf, _ := json.MarshalIndent(r, "", "    ") ; t.Logf("\n%s\n", buf)
 
 	var (
@@ -126,3 +127,108 @@ func TestSpyWithProcesses(t *testing.T) {
 		}
 	}
 }
+
+func TestSpyUDP(t *testing.T) {
+	const hostID = "host"
+	connection := func(transport string, pid uint) procspy.Connection {
+		return procspy.Connection{
+			Transport:     transport,
+			LocalAddress:  fixLocalAddress,
+			LocalPort:     fixLocalPort,
+			RemoteAddress: fixRemoteAddress,
+			RemotePort:    fixRemotePort,
+			State:         1, // ESTABLISHED
+			Proc:          procspy.Proc{PID: pid},
+		}
+	}
+	scanner := procspy.FixedScanner{
+		connection("tcp", fixProcessPID),
+		connection("udp", fixProcessPID+1),
+	}
+	reporter := endpoint.NewReporter(endpoint.ReporterConfig{
+		HostID:     hostID,
+		SpyProcs:   true,
+		WalkProc:   true,
+		BufferSize: bufferSize,
+		Scanner:    scanner,
+	})
+	r, err := reporter.Report(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		port     = strconv.Itoa(int(fixLocalPort))
+		tcpLocal = report.MakeEndpointNodeID(hostID, "", fixLocalAddress.String(), port)
+		udpLocal = report.MakeUDPEndpointNodeID(hostID, "", fixLocalAddress.String(), port)
+	)
+	for id, want := range map[string]uint{tcpLocal: fixProcessPID, udpLocal: fixProcessPID + 1} {
+		if have, _ := r.Endpoint.Nodes[id].Latest.Lookup("pid"); have != strconv.Itoa(int(want)) {
+			t.Errorf("%s: want pid %d, have %q", id, want, have)
+		}
+	}
+	udpRemote := report.MakeUDPEndpointNodeID(hostID, "", fixRemoteAddress.String(), strconv.Itoa(int(fixRemotePort)))
+	if !r.Endpoint.Nodes[udpRemote].Adjacency.Contains(udpLocal) {
+		t.Errorf("expected an edge between the UDP endpoints, got %v", r.Endpoint.Nodes[udpRemote].Adjacency)
+	}
+	if _, ok := r.Endpoint.Nodes[udpRemote].Latest.Lookup(report.Initiator); ok {
+		t.Errorf("expected the initiator of UDP connections to be unknown")
+	}
+	if last, _ := r.Host.Nodes[report.MakeHostNodeID(hostID)].Metrics[report.SocketsEstablished].LastSample(); last.Value != 1 {
+		t.Errorf("expected only the TCP socket to be counted as established, got %v", last.Value)
+	}
+}
+
+func TestSocketStates(t *testing.T) {
+	const hostID = "host"
+	socket := func(port uint16, state uint, pid uint) procspy.Connection {
+		return procspy.Connection{
+			Transport:     "tcp",
+			LocalAddress:  fixLocalAddress,
+			LocalPort:     port,
+			RemoteAddress: fixRemoteAddress,
+			RemotePort:    fixRemotePort,
+			State:         state,
+			Proc:          procspy.Proc{PID: pid},
+		}
+	}
+	scanner := procspy.FixedScanner{
+		socket(1001, 1, fixProcessPID), // ESTABLISHED
+		socket(1002, 8, fixProcessPID), // CLOSE_WAIT
+		socket(1003, 8, fixProcessPID),
+		socket(1004, 6, 0), // TIME_WAIT, of no process
+	}
+	reporter := endpoint.NewReporter(endpoint.ReporterConfig{
+		HostID:     hostID,
+		SpyProcs:   true,
+		WalkProc:   true,
+		BufferSize: bufferSize,
+		Scanner:    scanner,
+	})
+	r, err := reporter.Report(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for id, want := range map[string]map[string]float64{
+		report.MakeProcessNodeID(hostID, strconv.Itoa(int(fixProcessPID))): {
+			report.SocketsEstablished: 1, report.SocketsCloseWait: 2, report.SocketsTimeWait: 0,
+		},
+		report.MakeHostNodeID(hostID): {
+			report.SocketsEstablished: 1, report.SocketsCloseWait: 2, report.SocketsTimeWait: 1, report.SocketsSynSent: 0,
+		},
+	} {
+		node, ok := r.Process.Nodes[id]
+		if !ok {
+			node = r.Host.Nodes[id]
+		}
+		for key, count := range want {
+			if last, ok := node.Metrics[key].LastSample(); !ok || last.Value != count {
+				t.Errorf("%s: want %v %s, have %v", id, count, key, node.Metrics[key])
+			}
+		}
+	}
+	if _, ok := r.Container.MetricTemplates[report.SocketsCloseWait]; !ok {
+		t.Errorf("expected templates for containers, got %v", r.Container.MetricTemplates)
+	}
+}
@@ -0,0 +1,81 @@
+package endpoint
+
+import (
+	"strconv"
+
+	"github.com/weaveworks/common/mtime"
+
+	"github.com/weaveworks/scope/probe/endpoint/procspy"
+	"github.com/weaveworks/scope/report"
+)
+
+// socketStateMetrics are the keys of the counts of sockets, by the TCP
+// states worth counting: leaked connections pile up in CLOSE_WAIT, and
+// ephemeral ports run out in TIME_WAIT.
+var socketStateMetrics = map[string]string{
+	"ESTABLISHED": report.SocketsEstablished,
+	"SYN_SENT":    report.SocketsSynSent,
+	"TIME_WAIT":   report.SocketsTimeWait,
+	"CLOSE_WAIT":  report.SocketsCloseWait,
+}
+
+// SocketStateMetricTemplates are the templates of the socket counts of
+// processes, containers and hosts. Exposed for testing.
+var SocketStateMetricTemplates = report.MetricTemplates{
+	report.SocketsEstablished: {ID: report.SocketsEstablished, Label: "Established sockets", Format: report.IntegerFormat, Group: "sockets", Priority: 10},
+	report.SocketsSynSent:     {ID: report.SocketsSynSent, Label: "SYN_SENT sockets", Format: report.IntegerFormat, Group: "sockets", Priority: 11},
+	report.SocketsTimeWait:    {ID: report.SocketsTimeWait, Label: "TIME_WAIT sockets", Format: report.IntegerFormat, Group: "sockets", Priority: 12},
+	report.SocketsCloseWait:   {ID: report.SocketsCloseWait, Label: "CLOSE_WAIT sockets", Format: report.IntegerFormat, Group: "sockets", Priority: 13},
+}
+
+// addSocketStates counts the TCP sockets by state, for every process
+// and for the host. Sockets in TIME_WAIT belong to no process, so they
+// are only counted for the host.
+func (t *connectionTracker) addSocketStates(rpt *report.Report, hostNodeID string) error {
+	sockets, err := t.conf.Scanner.Sockets()
+	if err != nil {
+		return err
+	}
+	var (
+		hostCounts = map[string]int{}
+		pidCounts  = map[uint]map[string]int{}
+	)
+	for s := sockets.Next(); s != nil; s = sockets.Next() {
+		// UDP sockets only borrow the TCP states
+		if s.Transport == "udp" {
+			continue
+		}
+		key, ok := socketStateMetrics[procspy.TCPState(s.State)]
+		if !ok {
+			continue
+		}
+		hostCounts[key]++
+		if s.Proc.PID == 0 {
+			continue
+		}
+		if pidCounts[s.Proc.PID] == nil {
+			pidCounts[s.Proc.PID] = map[string]int{}
+		}
+		pidCounts[s.Proc.PID][key]++
+	}
+
+	now := mtime.Now()
+	metrics := func(counts map[string]int) report.Metrics {
+		// Every state is counted, so that counts drop back to zero
+		result := make(report.Metrics, len(socketStateMetrics))
+		for _, key := range socketStateMetrics {
+			result[key] = report.MakeSingletonMetric(now, float64(counts[key]))
+		}
+		return result
+	}
+	for pid, counts := range pidCounts {
+		id := report.MakeProcessNodeID(t.conf.HostID, strconv.FormatUint(uint64(pid), 10))
+		rpt.Process.AddNode(report.MakeNode(id).WithMetrics(metrics(counts)))
+	}
+	rpt.Host.AddNode(report.MakeNode(hostNodeID).WithMetrics(metrics(hostCounts)))
+
+	rpt.Process = rpt.Process.WithMetricTemplates(SocketStateMetricTemplates)
+	rpt.Container = rpt.Container.WithMetricTemplates(SocketStateMetricTemplates)
+	rpt.Host = rpt.Host.WithMetricTemplates(SocketStateMetricTemplates)
+	return nil
+}
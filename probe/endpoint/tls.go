@@ -0,0 +1,144 @@
+package endpoint
+
+import (
+	"encoding/binary"
+)
+
+// TLSServerName is the key of the server name a TLS client asked for in
+// its ClientHello, naming the external services encrypted connections go
+// to without decrypting them.
+const TLSServerName = "tls_server_name"
+
+// TLS record and handshake values of RFC 5246 needed to read the server
+// name indication of RFC 6066.
+const (
+	tlsRecordHeaderLen      = 5
+	tlsRecordHandshake      = 0x16
+	tlsHandshakeClientHello = 0x01
+	tlsExtensionServerName  = 0x0000
+	tlsServerNameHost       = 0x00
+	// ClientHellos are much smaller in practice
+	maxClientHelloLen = 16 * 1024
+)
+
+// tlsClientHello reads the server name off the ClientHello at the start
+// of the client side of a connection, reassembled.
+type tlsClientHello struct {
+	buf        []byte
+	done       bool
+	serverName string
+}
+
+func (h *tlsClientHello) feed(payload []byte) {
+	if h.done {
+		return
+	}
+	if len(h.buf) == 0 && (len(payload) == 0 || payload[0] != tlsRecordHandshake) {
+		h.done = true
+		return
+	}
+	h.buf = append(h.buf, payload...)
+
+	// The ClientHello may span several records
+	var handshake []byte
+	for rest := h.buf; len(rest) >= tlsRecordHeaderLen; {
+		if rest[0] != tlsRecordHandshake {
+			h.done = true
+			return
+		}
+		length := int(binary.BigEndian.Uint16(rest[3:]))
+		if len(rest) < tlsRecordHeaderLen+length {
+			break
+		}
+		handshake = append(handshake, rest[tlsRecordHeaderLen:tlsRecordHeaderLen+length]...)
+		rest = rest[tlsRecordHeaderLen+length:]
+	}
+	if len(handshake) >= 4 {
+		if handshake[0] != tlsHandshakeClientHello {
+			h.done = true
+			return
+		}
+		length := int(handshake[1])<<16 | int(handshake[2])<<8 | int(handshake[3])
+		if len(handshake) >= 4+length {
+			h.serverName, _ = parseServerName(handshake[4 : 4+length])
+			h.done = true
+		}
+	}
+	if len(h.buf) > maxClientHelloLen {
+		h.done = true
+	}
+	if h.done {
+		h.buf = nil
+	}
+}
+
+// parseServerName returns the host name of the server name extension of
+// the body of a ClientHello.
+func parseServerName(hello []byte) (string, bool) {
+	// Version and random
+	hello, ok := skip(hello, 2+32)
+	if !ok {
+		return "", false
+	}
+	// Session ID, cipher suites and compression methods
+	for _, lengthLen := range []int{1, 2, 1} {
+		if hello, ok = skipPrefixed(hello, lengthLen); !ok {
+			return "", false
+		}
+	}
+	if len(hello) < 2 {
+		return "", false
+	}
+	extensions := hello[2:]
+	if length := int(binary.BigEndian.Uint16(hello)); length < len(extensions) {
+		extensions = extensions[:length]
+	}
+	for len(extensions) >= 4 {
+		typ, length := binary.BigEndian.Uint16(extensions), int(binary.BigEndian.Uint16(extensions[2:]))
+		if len(extensions) < 4+length {
+			return "", false
+		}
+		if typ == tlsExtensionServerName {
+			return parseServerNameList(extensions[4 : 4+length])
+		}
+		extensions = extensions[4+length:]
+	}
+	return "", false
+}
+
+func parseServerNameList(list []byte) (string, bool) {
+	if len(list) < 2 {
+		return "", false
+	}
+	list = list[2:]
+	for len(list) >= 3 {
+		typ, length := list[0], int(binary.BigEndian.Uint16(list[1:]))
+		if len(list) < 3+length {
+			return "", false
+		}
+		if typ == tlsServerNameHost {
+			return string(list[3 : 3+length]), true
+		}
+		list = list[3+length:]
+	}
+	return "", false
+}
+
+func skip(b []byte, n int) ([]byte, bool) {
+	if len(b) < n {
+		return nil, false
+	}
+	return b[n:], true
+}
+
+// skipPrefixed skips a vector prefixed by its length, of lengthLen bytes.
+func skipPrefixed(b []byte, lengthLen int) ([]byte, bool) {
+	if len(b) < lengthLen {
+		return nil, false
+	}
+	length := 0
+	for _, c := range b[:lengthLen] {
+		length = length<<8 | int(c)
+	}
+	return skip(b[lengthLen:], length)
+}
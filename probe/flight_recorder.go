@@ -0,0 +1,94 @@
+package probe
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Timing is how long a ticker, reporter or tagger took in a spy cycle.
+type Timing struct {
+	Name     string        `json:"name"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+	Skipped  bool          `json:"skipped,omitempty"`
+}
+
+// SpyCycle records one spy cycle of the probe.
+type SpyCycle struct {
+	Start     time.Time     `json:"start"`
+	Duration  time.Duration `json:"duration"`
+	Tickers   []Timing      `json:"tickers"`
+	Reporters []Timing      `json:"reporters"`
+	Taggers   []Timing      `json:"taggers"`
+}
+
+// Publication records one attempt to publish a report.
+type Publication struct {
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// FlightRecorder keeps the last spy cycles and publications of the
+// probe, to debug its overhead in the field.
+type FlightRecorder struct {
+	mtx          sync.Mutex
+	size         int
+	cycles       []SpyCycle
+	publications []Publication
+}
+
+// NewFlightRecorder makes a FlightRecorder keeping the last size spy
+// cycles and publications.
+func NewFlightRecorder(size int) *FlightRecorder {
+	return &FlightRecorder{size: size}
+}
+
+func (f *FlightRecorder) recordCycle(c SpyCycle) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.cycles = append(f.cycles, c)
+	if len(f.cycles) > f.size {
+		f.cycles = f.cycles[len(f.cycles)-f.size:]
+	}
+}
+
+func (f *FlightRecorder) recordPublication(p Publication) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.publications = append(f.publications, p)
+	if len(f.publications) > f.size {
+		f.publications = f.publications[len(f.publications)-f.size:]
+	}
+}
+
+// Dump writes the recorded spy cycles and publications to w, as JSON.
+func (f *FlightRecorder) Dump(w io.Writer) error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	buf, err := json.MarshalIndent(struct {
+		Cycles       []SpyCycle    `json:"cycles"`
+		Publications []Publication `json:"publications"`
+	}{f.cycles, f.publications}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(buf, '\n'))
+	return err
+}
+
+// ServeHTTP implements http.Handler, dumping the recorder.
+func (f *FlightRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	f.Dump(w)
+}
+
+func errorString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
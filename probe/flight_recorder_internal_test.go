@@ -0,0 +1,45 @@
+package probe
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test"
+)
+
+func TestFlightRecorder(t *testing.T) {
+	recorder := NewFlightRecorder(2)
+	pub := mockPublisher{make(chan report.Report, 100)}
+
+	p := New(10*time.Millisecond, 10*time.Millisecond, pub, false)
+	p.SetFlightRecorder(recorder)
+	p.AddReporter(mockReporter{report.MakeReport()})
+	p.Start()
+	test.Poll(t, 300*time.Millisecond, true, func() interface{} {
+		recorder.mtx.Lock()
+		defer recorder.mtx.Unlock()
+		return len(recorder.cycles) == 2 && len(recorder.publications) == 2
+	})
+	p.Stop()
+
+	var buf bytes.Buffer
+	if err := recorder.Dump(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var dump struct {
+		Cycles       []SpyCycle
+		Publications []Publication
+	}
+	if err := json.Unmarshal(buf.Bytes(), &dump); err != nil {
+		t.Fatal(err)
+	}
+	if len(dump.Cycles) != 2 || len(dump.Publications) != 2 {
+		t.Fatalf("expected the last 2 cycles and publications, got %s", buf.String())
+	}
+	if reporters := dump.Cycles[1].Reporters; len(reporters) != 1 || reporters[0].Name != "Mock" {
+		t.Errorf("expected a timing for the mock reporter, got %v", reporters)
+	}
+}
@@ -0,0 +1,188 @@
+package host
+
+import (
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/weaveworks/scope/common/xfer"
+)
+
+// Control IDs of the bandwidth test, which the app invokes on the probes
+// of two hosts: the server on the receiving host, then the client on the
+// sending host.
+const (
+	BandwidthTestServer = "host_bandwidth_test_server"
+	BandwidthTestClient = "host_bandwidth_test_client"
+)
+
+// Arguments of the bandwidth test controls. The server listens on the
+// address, the client sends to it, and the client is told apart from
+// others by sending the token first.
+const (
+	BandwidthTestAddress  = "address"
+	BandwidthTestPort     = "port"
+	BandwidthTestToken    = "token"
+	BandwidthTestDuration = "duration"
+	BandwidthTestRate     = "rate" // in Mb/s
+)
+
+// Bounds of the bandwidth tests, so that they can't saturate a link for
+// long.
+const (
+	maxBandwidthTestDuration = 30 * time.Second
+	maxBandwidthTestRate     = 1000 // Mb/s
+	bandwidthTestChunk       = 32 * 1024
+	// How long the server waits for the client past the test duration
+	bandwidthTestSlack = 10 * time.Second
+	// How long the server waits for the token of a connection
+	bandwidthTestTokenTimeout = 2 * time.Second
+)
+
+// BandwidthTestResult is the result of a bandwidth test, as measured by
+// the client from the bytes the server received.
+type BandwidthTestResult struct {
+	Bytes         int64   `json:"bytes"`
+	Seconds       float64 `json:"seconds"`
+	BitsPerSecond float64 `json:"bitsPerSecond"`
+}
+
+func parseBandwidthTestDuration(args map[string]string) (time.Duration, error) {
+	duration, err := time.ParseDuration(args[BandwidthTestDuration])
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration: %v", err)
+	}
+	if duration <= 0 || duration > maxBandwidthTestDuration {
+		return 0, fmt.Errorf("duration must be positive and at most %v", maxBandwidthTestDuration)
+	}
+	return duration, nil
+}
+
+// bandwidthTestServer listens on the address for a single connection of
+// the client, replying with the number of bytes received once the client
+// is done. Connections not sending the token are closed, so that no one
+// else can take the place of the client. It responds with the port it
+// listens on.
+func (r *Reporter) bandwidthTestServer(req xfer.Request) xfer.Response {
+	duration, err := parseBandwidthTestDuration(req.ControlArgs)
+	if err != nil {
+		return xfer.ResponseError(err)
+	}
+	ip := net.ParseIP(req.ControlArgs[BandwidthTestAddress])
+	if ip == nil {
+		return xfer.ResponseErrorf("invalid address %q", req.ControlArgs[BandwidthTestAddress])
+	}
+	token := req.ControlArgs[BandwidthTestToken]
+	if token == "" {
+		return xfer.ResponseErrorf("missing token")
+	}
+	listener, err := net.ListenTCP("tcp", &net.TCPAddr{IP: ip})
+	if err != nil {
+		return xfer.ResponseError(err)
+	}
+	deadline := time.Now().Add(duration + bandwidthTestSlack)
+	listener.SetDeadline(deadline)
+
+	go func() {
+		defer listener.Close()
+		conn, err := acceptBandwidthTestClient(listener, token)
+		if err != nil {
+			log.Warnf("Bandwidth test: no client: %v", err)
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(deadline)
+		received, err := io.Copy(ioutil.Discard, conn)
+		if err != nil {
+			log.Warnf("Bandwidth test: error receiving: %v", err)
+			return
+		}
+		if err := binary.Write(conn, binary.BigEndian, received); err != nil {
+			log.Warnf("Bandwidth test: error replying: %v", err)
+		}
+	}()
+
+	return xfer.Response{Value: strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)}
+}
+
+// acceptBandwidthTestClient accepts connections until one sends the
+// token, closing the others.
+func acceptBandwidthTestClient(listener *net.TCPListener, token string) (net.Conn, error) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		conn.SetReadDeadline(time.Now().Add(bandwidthTestTokenTimeout))
+		sent := make([]byte, len(token))
+		if _, err := io.ReadFull(conn, sent); err == nil && subtle.ConstantTimeCompare(sent, []byte(token)) == 1 {
+			return conn, nil
+		}
+		log.Warnf("Bandwidth test: closing connection from %s, without the token", conn.RemoteAddr())
+		conn.Close()
+	}
+}
+
+// bandwidthTestClient sends the token, then to the server for the
+// duration, at most at the rate, responding with a BandwidthTestResult.
+func (r *Reporter) bandwidthTestClient(req xfer.Request) xfer.Response {
+	duration, err := parseBandwidthTestDuration(req.ControlArgs)
+	if err != nil {
+		return xfer.ResponseError(err)
+	}
+	rate, err := strconv.Atoi(req.ControlArgs[BandwidthTestRate])
+	if err != nil || rate <= 0 || rate > maxBandwidthTestRate {
+		return xfer.ResponseErrorf("rate must be between 1 and %d Mb/s", maxBandwidthTestRate)
+	}
+	address := net.JoinHostPort(req.ControlArgs[BandwidthTestAddress], req.ControlArgs[BandwidthTestPort])
+
+	conn, err := net.DialTimeout("tcp", address, bandwidthTestSlack)
+	if err != nil {
+		return xfer.ResponseError(err)
+	}
+	defer conn.Close()
+	if _, err := io.WriteString(conn, req.ControlArgs[BandwidthTestToken]); err != nil {
+		return xfer.ResponseError(err)
+	}
+
+	var (
+		bytesPerSecond = float64(rate) * 1e6 / 8
+		chunk          = make([]byte, bandwidthTestChunk)
+		start          = time.Now()
+		end            = start.Add(duration)
+		sent           int64
+	)
+	conn.SetDeadline(end.Add(bandwidthTestSlack))
+	for now := start; now.Before(end); now = time.Now() {
+		// Wait until sending another chunk stays within the rate
+		if ahead := time.Duration(float64(sent+bandwidthTestChunk)/bytesPerSecond*float64(time.Second)) - now.Sub(start); ahead > 0 {
+			if now.Add(ahead).After(end) {
+				break
+			}
+			time.Sleep(ahead)
+		}
+		n, err := conn.Write(chunk)
+		sent += int64(n)
+		if err != nil {
+			return xfer.ResponseError(err)
+		}
+	}
+	if err := conn.(*net.TCPConn).CloseWrite(); err != nil {
+		return xfer.ResponseError(err)
+	}
+	var received int64
+	if err := binary.Read(conn, binary.BigEndian, &received); err != nil {
+		return xfer.ResponseErrorf("error reading the result: %v", err)
+	}
+	seconds := time.Since(start).Seconds()
+	return xfer.Response{Value: BandwidthTestResult{
+		Bytes:         received,
+		Seconds:       seconds,
+		BitsPerSecond: float64(received) * 8 / seconds,
+	}}
+}
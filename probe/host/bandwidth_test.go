@@ -0,0 +1,73 @@
+package host_test
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/probe/controls"
+	"github.com/weaveworks/scope/probe/host"
+)
+
+func TestBandwidthTestBounds(t *testing.T) {
+	registry := controls.NewDefaultHandlerRegistry()
+	reporter := host.NewReporter("hostid", "hostname", "probe-id", "", nil, registry)
+	defer reporter.Stop()
+
+	for _, req := range []xfer.Request{
+		{Control: host.BandwidthTestServer, ControlArgs: map[string]string{host.BandwidthTestDuration: "1h"}},
+		{Control: host.BandwidthTestServer, ControlArgs: map[string]string{}},
+		{Control: host.BandwidthTestServer, ControlArgs: map[string]string{host.BandwidthTestDuration: "1s", host.BandwidthTestToken: "token"}},
+		{Control: host.BandwidthTestServer, ControlArgs: map[string]string{host.BandwidthTestDuration: "1s", host.BandwidthTestAddress: "127.0.0.1"}},
+		{Control: host.BandwidthTestClient, ControlArgs: map[string]string{host.BandwidthTestDuration: "1s", host.BandwidthTestRate: "100000"}},
+	} {
+		if res := registry.HandleControlRequest(req); res.Error == "" {
+			t.Errorf("%s %v: expected an error", req.Control, req.ControlArgs)
+		}
+	}
+}
+
+func TestBandwidthTestToken(t *testing.T) {
+	registry := controls.NewDefaultHandlerRegistry()
+	reporter := host.NewReporter("hostid", "hostname", "probe-id", "", nil, registry)
+	defer reporter.Stop()
+
+	server := registry.HandleControlRequest(xfer.Request{Control: host.BandwidthTestServer, ControlArgs: map[string]string{
+		host.BandwidthTestAddress:  "127.0.0.1",
+		host.BandwidthTestToken:    "0123456789abcdef",
+		host.BandwidthTestDuration: "200ms",
+	}})
+	if server.Error != "" {
+		t.Fatal(server.Error)
+	}
+	port := server.Value.(string)
+
+	// Connections without the token are closed, and the client still
+	// gets through
+	stranger, err := net.Dial("tcp", net.JoinHostPort("127.0.0.1", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stranger.Close()
+	io.WriteString(stranger, "fedcba9876543210")
+	stranger.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := stranger.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("expected a connection with the wrong token to be closed, got %v", err)
+	}
+
+	client := registry.HandleControlRequest(xfer.Request{Control: host.BandwidthTestClient, ControlArgs: map[string]string{
+		host.BandwidthTestAddress:  "127.0.0.1",
+		host.BandwidthTestPort:     port,
+		host.BandwidthTestToken:    "0123456789abcdef",
+		host.BandwidthTestDuration: "200ms",
+		host.BandwidthTestRate:     "8",
+	}})
+	if client.Error != "" {
+		t.Fatal(client.Error)
+	}
+	if result := client.Value.(host.BandwidthTestResult); result.Bytes <= 0 {
+		t.Errorf("expected bytes to be received, got %+v", result)
+	}
+}
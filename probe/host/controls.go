@@ -5,7 +5,6 @@ import (
 
 	"github.com/docker/docker/pkg/term"
 	"github.com/kr/pty"
-	log "github.com/sirupsen/logrus"
 
 	"github.com/weaveworks/scope/common/xfer"
 	"github.com/weaveworks/scope/probe/controls"
@@ -20,11 +19,15 @@ const (
 func (r *Reporter) registerControls() {
 	r.handlerRegistry.Register(ExecHost, r.execHost)
 	r.handlerRegistry.Register(ResizeExecTTY, xfer.ResizeTTYControlWrapper(r.resizeExecTTY))
+	r.handlerRegistry.Register(BandwidthTestServer, r.bandwidthTestServer)
+	r.handlerRegistry.Register(BandwidthTestClient, r.bandwidthTestClient)
 }
 
 func (r *Reporter) deregisterControls() {
 	r.handlerRegistry.Rm(ExecHost)
 	r.handlerRegistry.Rm(ResizeExecTTY)
+	r.handlerRegistry.Rm(BandwidthTestServer)
+	r.handlerRegistry.Rm(BandwidthTestClient)
 }
 
 func (r *Reporter) execHost(req xfer.Request) xfer.Response {
@@ -6,7 +6,6 @@ import (
 	"strings"
 	"syscall"
 
-	log "github.com/sirupsen/logrus"
 	"github.com/willdonnelly/passwd"
 )
 
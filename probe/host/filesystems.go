@@ -0,0 +1,128 @@
+package host
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// Keys for the filesystems of host nodes.
+const (
+	// Prefix of the metrics of the bytes used of each filesystem, by
+	// mountpoint, with the capacity of the filesystem as their max
+	FilesystemUsagePrefix = "host_fs_usage_bytes_"
+	// The usage of the fullest filesystem, for querying hosts by it
+	FilesystemMaxUsage = "host_fs_max_usage_percent"
+	// The mountpoints of the filesystems above FilesystemUsageThreshold
+	FilesystemsAboveThreshold = "host_fs_above_threshold"
+)
+
+// FilesystemUsageThreshold is the percent of usage above which filesystems
+// are annotated on their hosts.
+const FilesystemUsageThreshold = 90.0
+
+// pseudoFilesystems are the filesystem types not backed by storage, whose
+// usage means nothing for capacity.
+var pseudoFilesystems = map[string]struct{}{
+	"autofs": {}, "binfmt_misc": {}, "bpf": {}, "cgroup": {}, "cgroup2": {},
+	"configfs": {}, "debugfs": {}, "devpts": {}, "devtmpfs": {}, "fusectl": {},
+	"hugetlbfs": {}, "mqueue": {}, "nsfs": {}, "overlay": {}, "proc": {},
+	"pstore": {}, "ramfs": {}, "rpc_pipefs": {}, "securityfs": {}, "shm": {},
+	"squashfs": {}, "sysfs": {}, "tmpfs": {}, "tracefs": {},
+}
+
+// Filesystem is the capacity and usage of a mounted filesystem.
+type Filesystem struct {
+	Mountpoint string
+	Device     string
+	Type       string
+	Size, Used uint64
+}
+
+// UsagePercent is the percent of the capacity of the filesystem used.
+func (f Filesystem) UsagePercent() float64 {
+	if f.Size == 0 {
+		return 0
+	}
+	return 100 * float64(f.Used) / float64(f.Size)
+}
+
+// Mount is a mounted filesystem, as listed in /proc/mounts.
+type Mount struct {
+	Device, Mountpoint, Type string
+}
+
+// ParseMounts parses the mounts of storage filesystems from the contents
+// of /proc/mounts, skipping pseudo filesystems, and the further mounts of
+// a device already mounted, such as bind mounts.
+func ParseMounts(buf []byte) []Mount {
+	var (
+		mounts  []Mount
+		devices = map[string]struct{}{}
+		scanner = bufio.NewScanner(bytes.NewReader(buf))
+	)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		m := Mount{Device: fields[0], Mountpoint: unescapeMountpoint(fields[1]), Type: fields[2]}
+		if _, ok := pseudoFilesystems[m.Type]; ok {
+			continue
+		}
+		if _, ok := devices[m.Device]; ok {
+			continue
+		}
+		devices[m.Device] = struct{}{}
+		mounts = append(mounts, m)
+	}
+	return mounts
+}
+
+// unescapeMountpoint undoes the octal escaping of whitespace in the
+// mountpoints of /proc/mounts.
+func unescapeMountpoint(s string) string {
+	return strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`).Replace(s)
+}
+
+// filesystemMetrics are the metrics of the usage of filesystems, with
+// their templates, and the mountpoints of those above the threshold.
+func filesystemMetrics(now time.Time, filesystems []Filesystem) (report.Metrics, report.MetricTemplates, []string) {
+	sort.Slice(filesystems, func(i, j int) bool { return filesystems[i].Mountpoint < filesystems[j].Mountpoint })
+	var (
+		metrics   = report.Metrics{}
+		templates = report.MetricTemplates{}
+		above     []string
+		max       float64
+	)
+	for i, fs := range filesystems {
+		if fs.Size == 0 {
+			continue
+		}
+		id := FilesystemUsagePrefix + fs.Mountpoint
+		metrics[id] = report.MakeSingletonMetric(now, float64(fs.Used)).WithMax(float64(fs.Size))
+		templates[id] = report.MetricTemplate{
+			ID:       id,
+			Label:    fmt.Sprintf("Disk %s", fs.Mountpoint),
+			Format:   report.FilesizeFormat,
+			Group:    "filesystems",
+			Priority: 21 + float64(i),
+		}
+		usage := fs.UsagePercent()
+		if usage > max {
+			max = usage
+		}
+		if usage > FilesystemUsageThreshold {
+			above = append(above, fs.Mountpoint)
+		}
+	}
+	if len(metrics) > 0 {
+		metrics[FilesystemMaxUsage] = report.MakeSingletonMetric(now, max).WithMax(100)
+	}
+	return metrics, templates, above
+}
@@ -0,0 +1,61 @@
+package host_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/weaveworks/scope/probe/controls"
+	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/report"
+)
+
+const procMounts = `sysfs /sys sysfs rw,nosuid,nodev,noexec,relatime 0 0
+proc /proc proc rw,nosuid,nodev,noexec,relatime 0 0
+/dev/sda1 / ext4 rw,relatime 0 0
+tmpfs /run tmpfs rw,nosuid,nodev 0 0
+/dev/sdb1 /mnt/my\040data xfs rw,relatime 0 0
+/dev/sda1 /var/lib/docker/plugins ext4 rw,relatime 0 0
+overlay /var/lib/docker/overlay2/abc/merged overlay rw,relatime 0 0
+`
+
+func TestParseMounts(t *testing.T) {
+	want := []host.Mount{
+		{Device: "/dev/sda1", Mountpoint: "/", Type: "ext4"},
+		{Device: "/dev/sdb1", Mountpoint: "/mnt/my data", Type: "xfs"},
+	}
+	if have := host.ParseMounts([]byte(procMounts)); !reflect.DeepEqual(want, have) {
+		t.Errorf("want %+v, have %+v", want, have)
+	}
+}
+
+func TestFilesystems(t *testing.T) {
+	oldGetFilesystems := host.GetFilesystems
+	defer func() { host.GetFilesystems = oldGetFilesystems }()
+	host.GetFilesystems = func() ([]host.Filesystem, error) {
+		return []host.Filesystem{
+			{Mountpoint: "/", Device: "/dev/sda1", Type: "ext4", Size: 1000, Used: 500},
+			{Mountpoint: "/data", Device: "/dev/sdb1", Type: "xfs", Size: 1000, Used: 950},
+		}, nil
+	}
+
+	rpt, err := host.NewReporter("hostid", "hostname", "probe-id", "", nil, controls.NewDefaultHandlerRegistry()).Report(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := rpt.Host.Nodes[report.MakeHostNodeID("hostid")]
+
+	metric, ok := node.Metrics[host.FilesystemUsagePrefix+"/data"]
+	if sample, _ := metric.LastSample(); !ok || sample.Value != 950 || metric.Max != 1000 {
+		t.Errorf("expected the usage of /data, have %+v", metric)
+	}
+	if _, ok := rpt.Host.MetricTemplates[host.FilesystemUsagePrefix+"/"]; !ok {
+		t.Error("expected a template of the usage of /")
+	}
+	if sample, _ := node.Metrics[host.FilesystemMaxUsage].LastSample(); sample.Value != 95 {
+		t.Errorf("expected the usage of the fullest filesystem, have %v", sample.Value)
+	}
+	if have, _ := node.Sets.Lookup(host.FilesystemsAboveThreshold); !reflect.DeepEqual(have, report.MakeStringSet("/data")) {
+		t.Errorf("expected only /data above the threshold, have %v", have)
+	}
+}
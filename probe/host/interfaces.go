@@ -0,0 +1,229 @@
+package host
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// Keys for use in network interface nodes.
+const (
+	InterfaceName      = "interface_name"
+	InterfaceMAC       = "interface_mac"
+	InterfaceAddresses = "interface_addresses"
+	InterfaceType      = "interface_type"
+	InterfaceSpeed     = "interface_speed_mbps"
+	InterfaceRxBytes   = "interface_rx_bytes_per_second"
+	InterfaceTxBytes   = "interface_tx_bytes_per_second"
+	InterfaceRxErrors  = "interface_rx_errors_per_second"
+	InterfaceTxErrors  = "interface_tx_errors_per_second"
+	// Addresses of the other hosts on the segment of the interface
+	InterfaceNeighbours = "interface_neighbours"
+	// The switch port the interface is attached to, from LLDP
+	InterfaceSwitch     = "interface_switch"
+	InterfaceSwitchPort = "interface_switch_port"
+
+	// Values of InterfaceType
+	PhysicalInterface = "physical"
+	VirtualInterface  = "virtual"
+)
+
+// Exposed for testing.
+var (
+	InterfaceMetadataTemplates = report.MetadataTemplates{
+		InterfaceName:       {ID: InterfaceName, Label: "Name", From: report.FromLatest, Priority: 1},
+		InterfaceType:       {ID: InterfaceType, Label: "Type", From: report.FromLatest, Priority: 2},
+		InterfaceMAC:        {ID: InterfaceMAC, Label: "MAC", From: report.FromLatest, Priority: 3},
+		InterfaceAddresses:  {ID: InterfaceAddresses, Label: "Addresses", From: report.FromSets, Priority: 4},
+		InterfaceSpeed:      {ID: InterfaceSpeed, Label: "Speed (Mb/s)", From: report.FromLatest, Datatype: report.Number, Priority: 5},
+		InterfaceNeighbours: {ID: InterfaceNeighbours, Label: "Neighbours", From: report.FromSets, Priority: 6},
+		InterfaceSwitch:     {ID: InterfaceSwitch, Label: "Switch", From: report.FromLatest, Priority: 8},
+		InterfaceSwitchPort: {ID: InterfaceSwitchPort, Label: "Switch port", From: report.FromLatest, Priority: 9},
+	}
+
+	InterfaceMetricTemplates = report.MetricTemplates{
+		InterfaceRxBytes:  {ID: InterfaceRxBytes, Label: "Received/s", Format: report.FilesizeFormat, Priority: 1},
+		InterfaceTxBytes:  {ID: InterfaceTxBytes, Label: "Sent/s", Format: report.FilesizeFormat, Priority: 2},
+		InterfaceRxErrors: {ID: InterfaceRxErrors, Label: "Receive errors/s", Format: report.DefaultFormat, Priority: 3},
+		InterfaceTxErrors: {ID: InterfaceTxErrors, Label: "Send errors/s", Format: report.DefaultFormat, Priority: 4},
+	}
+)
+
+// InterfaceStats are the counters and link properties of a network
+// interface.
+type InterfaceStats struct {
+	RxBytes, TxBytes   uint64
+	RxErrors, TxErrors uint64
+	// Speed of the link in Mb/s, or 0 if unknown
+	Speed    int
+	Physical bool
+}
+
+// ParseNetDev parses the counters of every interface in /proc/net/dev.
+func ParseNetDev(buf []byte) (map[string]InterfaceStats, error) {
+	stats := map[string]InterfaceStats{}
+	lines := strings.Split(string(buf), "\n")
+	if len(lines) < 2 {
+		return nil, fmt.Errorf("invalid format: %s", string(buf))
+	}
+	// The first two lines are headers
+	for _, line := range lines[2:] {
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		fields := strings.Fields(parts[1])
+		if len(fields) < 11 {
+			return nil, fmt.Errorf("invalid format: %s", line)
+		}
+		var values [4]uint64
+		for i, field := range []int{0, 2, 8, 10} {
+			v, err := strconv.ParseUint(fields[field], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = v
+		}
+		stats[strings.TrimSpace(parts[0])] = InterfaceStats{
+			RxBytes:  values[0],
+			RxErrors: values[1],
+			TxBytes:  values[2],
+			TxErrors: values[3],
+		}
+	}
+	return stats, nil
+}
+
+// ParseARP parses the complete entries of /proc/net/arp, returning the
+// addresses of the neighbours on every interface.
+func ParseARP(buf []byte) map[string][]string {
+	neighbours := map[string][]string{}
+	lines := strings.Split(string(buf), "\n")
+	// The first line is a header
+	for _, line := range lines[1:] {
+		// IP address, HW type, Flags, HW address, Mask, Device
+		fields := strings.Fields(line)
+		if len(fields) < 6 {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[2], 0, 32)
+		if err != nil || flags&arpComplete == 0 {
+			continue
+		}
+		neighbours[fields[5]] = append(neighbours[fields[5]], fields[0])
+	}
+	return neighbours
+}
+
+// ATF_COM, from linux/if_arp.h
+const arpComplete = 0x2
+
+// interfaceSample is the last reading of the interface counters, used
+// to compute rates.
+type interfaceSample struct {
+	at    time.Time
+	stats map[string]InterfaceStats
+}
+
+// InterfaceAddrs is swappable for mocking in tests.
+var InterfaceAddrs = func(name string) (string, []string) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return "", nil
+	}
+	var addrs []string
+	if as, err := iface.Addrs(); err == nil {
+		for _, a := range as {
+			addrs = append(addrs, a.String())
+		}
+	}
+	return iface.HardwareAddr.String(), addrs
+}
+
+// interfacesTopology makes a child node of the host for every network
+// interface, with traffic rates since the previous sample.
+func (r *Reporter) interfacesTopology(now time.Time) (report.Topology, error) {
+	topology := report.MakeTopology().
+		WithMetadataTemplates(InterfaceMetadataTemplates).
+		WithMetricTemplates(InterfaceMetricTemplates)
+
+	stats, err := GetNetworkInterfaceStats()
+	if err != nil {
+		return topology, err
+	}
+	neighbours, err := GetNeighbours()
+	if err != nil {
+		log.Warnf("Error reading neighbour table: %v", err)
+	}
+	lldp := r.lldpNeighbours(now)
+
+	r.Lock()
+	previous := r.interfaces
+	r.interfaces = interfaceSample{at: now, stats: stats}
+	r.Unlock()
+	elapsed := now.Sub(previous.at).Seconds()
+
+	hostNodeID := report.MakeHostNodeID(r.hostID)
+	for name, s := range stats {
+		mac, addrs := InterfaceAddrs(name)
+		kind := VirtualInterface
+		if s.Physical {
+			kind = PhysicalInterface
+		}
+		latest := map[string]string{
+			InterfaceName: name,
+			InterfaceType: kind,
+		}
+		if mac != "" {
+			latest[InterfaceMAC] = mac
+		}
+		if s.Speed > 0 {
+			latest[InterfaceSpeed] = strconv.Itoa(s.Speed)
+		}
+		node := report.MakeNodeWith(report.MakeNetworkInterfaceNodeID(r.hostID, name), latest).
+			WithSet(InterfaceAddresses, report.MakeStringSet(addrs...)).
+			WithSet(InterfaceNeighbours, report.MakeStringSet(neighbours[name]...)).
+			WithParent(report.Host, hostNodeID)
+		if neighbour, ok := lldp[name]; ok {
+			// An edge to the switch port, which is the interface of a
+			// network device when the switch is polled over SNMP
+			node = node.WithLatests(map[string]string{
+				InterfaceSwitch:     neighbour.Chassis,
+				InterfaceSwitchPort: neighbour.Port,
+			}).WithAdjacent(report.MakeNetworkInterfaceNodeID(neighbour.Chassis, neighbour.Port))
+		}
+
+		if prev, ok := previous.stats[name]; ok && elapsed > 0 {
+			node = WithInterfaceRates(node, prev, s, now, elapsed)
+		}
+		topology.AddNode(node)
+	}
+	return topology, nil
+}
+
+// WithInterfaceRates adds the traffic and error rates of an interface
+// to its node, from two readings of its counters elapsed seconds apart.
+func WithInterfaceRates(node report.Node, prev, s InterfaceStats, now time.Time, elapsed float64) report.Node {
+	// Link speed is in Mb/s, and traffic in B/s
+	maxBytes := float64(s.Speed) * 1e6 / 8
+	for key, counters := range map[string][2]uint64{
+		InterfaceRxBytes:  {prev.RxBytes, s.RxBytes},
+		InterfaceTxBytes:  {prev.TxBytes, s.TxBytes},
+		InterfaceRxErrors: {prev.RxErrors, s.RxErrors},
+		InterfaceTxErrors: {prev.TxErrors, s.TxErrors},
+	} {
+		if counters[1] < counters[0] {
+			continue // counters were reset
+		}
+		metric := report.MakeSingletonMetric(now, float64(counters[1]-counters[0])/elapsed)
+		if (key == InterfaceRxBytes || key == InterfaceTxBytes) && maxBytes > 0 {
+			metric = metric.WithMax(maxBytes)
+		}
+		node = node.WithMetric(key, metric)
+	}
+	return node
+}
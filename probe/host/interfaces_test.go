@@ -0,0 +1,148 @@
+package host_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/probe/controls"
+	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/report"
+)
+
+const netDev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:    1000      10    0    0    0     0          0         0     1000      10    0    0    0     0       0          0
+  eth0: 5000000    4000    3    0    0     0          0         0  2000000    3000    1    0    0     0       0          0
+`
+
+func TestParseNetDev(t *testing.T) {
+	stats, err := host.ParseNetDev([]byte(netDev))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := host.InterfaceStats{RxBytes: 5000000, RxErrors: 3, TxBytes: 2000000, TxErrors: 1}
+	if len(stats) != 2 || stats["eth0"] != want {
+		t.Errorf("want %+v, have %+v", want, stats)
+	}
+}
+
+func TestInterfaces(t *testing.T) {
+	var (
+		oldGetNetworkInterfaceStats = host.GetNetworkInterfaceStats
+		oldInterfaceAddrs           = host.InterfaceAddrs
+		oldGetLLDPNeighbours        = host.GetLLDPNeighbours
+		stats                       = host.InterfaceStats{RxBytes: 1000, TxBytes: 1000, Speed: 1000, Physical: true}
+		start                       = time.Now()
+	)
+	defer func() {
+		host.GetNetworkInterfaceStats = oldGetNetworkInterfaceStats
+		host.InterfaceAddrs = oldInterfaceAddrs
+		host.GetLLDPNeighbours = oldGetLLDPNeighbours
+		mtime.NowReset()
+	}()
+	host.GetNetworkInterfaceStats = func() (map[string]host.InterfaceStats, error) {
+		return map[string]host.InterfaceStats{"eth0": stats}, nil
+	}
+	host.InterfaceAddrs = func(string) (string, []string) {
+		return "02:42:ac:11:00:02", []string{"10.0.0.1/24"}
+	}
+	host.GetLLDPNeighbours = func() (map[string]host.LLDPNeighbour, error) {
+		return map[string]host.LLDPNeighbour{"eth0": {Chassis: "switch1", Port: "Gi0/1", Protocol: "LLDP"}}, nil
+	}
+
+	reporter := host.NewReporter("hostid", "hostname", "probe-id", "", nil, controls.NewDefaultHandlerRegistry())
+	mtime.NowForce(start)
+	if _, err := reporter.Report(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	stats.RxBytes += 10 * 1000
+	stats.TxErrors += 20
+	mtime.NowForce(start.Add(10 * time.Second))
+	rpt, err := reporter.Report(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node, ok := rpt.NetworkInterface.Nodes[report.MakeNetworkInterfaceNodeID("hostid", "eth0")]
+	if !ok {
+		t.Fatalf("expected an eth0 node, got %v", rpt.NetworkInterface.Nodes)
+	}
+	for key, want := range map[string]string{
+		host.InterfaceName:       "eth0",
+		host.InterfaceType:       host.PhysicalInterface,
+		host.InterfaceMAC:        "02:42:ac:11:00:02",
+		host.InterfaceSpeed:      "1000",
+		host.InterfaceSwitch:     "switch1",
+		host.InterfaceSwitchPort: "Gi0/1",
+	} {
+		if have, _ := node.Latest.Lookup(key); have != want {
+			t.Errorf("%s: want %q, have %q", key, want, have)
+		}
+	}
+	if parents, _ := node.Parents.Lookup(report.Host); len(parents) != 1 || parents[0] != report.MakeHostNodeID("hostid") {
+		t.Errorf("expected the host as parent, got %v", parents)
+	}
+	if port := report.MakeNetworkInterfaceNodeID("switch1", "Gi0/1"); !node.Adjacency.Contains(port) {
+		t.Errorf("expected an edge to the switch port, got %v", node.Adjacency)
+	}
+	for key, want := range map[string]float64{
+		host.InterfaceRxBytes:  1000,
+		host.InterfaceTxBytes:  0,
+		host.InterfaceTxErrors: 2,
+	} {
+		metric, ok := node.Metrics[key]
+		if !ok {
+			t.Errorf("%s: missing", key)
+			continue
+		}
+		if have, _ := metric.LastSample(); have.Value != want {
+			t.Errorf("%s: want %v, have %v", key, want, have.Value)
+		}
+	}
+	if max := node.Metrics[host.InterfaceRxBytes].Max; max != 125e6 {
+		t.Errorf("expected the link speed as max, got %v", max)
+	}
+}
+
+func TestParseARP(t *testing.T) {
+	arp := `IP address       HW type     Flags       HW address            Mask     Device
+10.0.0.2         0x1         0x2         02:42:ac:11:00:03     *        eth0
+10.0.0.3         0x1         0x0         00:00:00:00:00:00     *        eth0
+172.17.0.2       0x1         0x2         02:42:ac:11:00:02     *        docker0
+`
+	neighbours := host.ParseARP([]byte(arp))
+	if len(neighbours) != 2 || len(neighbours["eth0"]) != 1 || neighbours["eth0"][0] != "10.0.0.2" || neighbours["docker0"][0] != "172.17.0.2" {
+		t.Errorf("unexpected neighbours: %v", neighbours)
+	}
+}
+
+func TestParseLLDPNeighbours(t *testing.T) {
+	out := `lldp.eth0.via=LLDP
+lldp.eth0.rid=1
+lldp.eth0.chassis.mac=00:11:22:33:44:55
+lldp.eth0.chassis.name=switch1
+lldp.eth0.port.ifname=Gi0/1
+lldp.eth0.port.descr=GigabitEthernet0/1
+lldp.eth1.via=CDPv2
+lldp.eth1.chassis.local=switch2
+lldp.eth1.port.local=Ethernet1/3
+lldp.eth2.via=LLDP
+lldp.eth2.chassis.mac=00:11:22:33:44:66
+`
+	neighbours := host.ParseLLDPNeighbours([]byte(out))
+	want := map[string]host.LLDPNeighbour{
+		"eth0": {Chassis: "switch1", Port: "Gi0/1", Protocol: "LLDP"},
+		"eth1": {Chassis: "switch2", Port: "Ethernet1/3", Protocol: "CDPv2"},
+	}
+	if len(neighbours) != len(want) {
+		t.Fatalf("want %v, have %v", want, neighbours)
+	}
+	for name, n := range want {
+		if neighbours[name] != n {
+			t.Errorf("%s: want %+v, have %+v", name, n, neighbours[name])
+		}
+	}
+}
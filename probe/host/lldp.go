@@ -0,0 +1,118 @@
+package host
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	// lldpRefreshInterval is how often the neighbours are read from
+	// lldpd. Neighbours advertise themselves every 30s by default.
+	lldpRefreshInterval = 30 * time.Second
+	// lldpTimeout bounds each run of lldpcli
+	lldpTimeout = 5 * time.Second
+	// lldpSocket is the socket of lldpd, as mounted from the host
+	lldpSocket = "/var/run/lldpd.socket"
+)
+
+type lldpSample struct {
+	at         time.Time
+	neighbours map[string]LLDPNeighbour
+}
+
+// LLDPNeighbour is the switch port a network interface is attached to,
+// as advertised by the switch over LLDP or CDP.
+type LLDPNeighbour struct {
+	// Chassis is the system name of the switch, or its chassis ID
+	Chassis string
+	// Port is the name of the switch port, or its port ID
+	Port string
+	// Protocol is the protocol of the advertisement, e.g. LLDP or CDPv2
+	Protocol string
+}
+
+// ParseLLDPNeighbours parses the output of `lldpcli -f keyvalue show
+// neighbors`, returning the neighbour of every interface.
+func ParseLLDPNeighbours(buf []byte) map[string]LLDPNeighbour {
+	fields := map[string]map[string]string{}
+	for _, line := range strings.Split(string(buf), "\n") {
+		// e.g. lldp.eth0.chassis.name=switch1
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		parts := strings.SplitN(kv[0], ".", 3)
+		if len(parts) != 3 || parts[0] != "lldp" {
+			continue
+		}
+		if fields[parts[1]] == nil {
+			fields[parts[1]] = map[string]string{}
+		}
+		fields[parts[1]][parts[2]] = kv[1]
+	}
+
+	neighbours := map[string]LLDPNeighbour{}
+	for name, f := range fields {
+		neighbour := LLDPNeighbour{
+			Chassis:  firstOf(f, "chassis.name", "chassis.mac", "chassis.ip", "chassis.local"),
+			Port:     firstOf(f, "port.ifname", "port.local", "port.mac", "port.descr"),
+			Protocol: f["via"],
+		}
+		if neighbour.Chassis != "" && neighbour.Port != "" {
+			neighbours[name] = neighbour
+		}
+	}
+	return neighbours
+}
+
+func firstOf(fields map[string]string, keys ...string) string {
+	for _, key := range keys {
+		if v := fields[key]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// GetLLDPNeighbours returns the neighbour of every network interface
+// known to lldpd. Without lldpd, or lldpcli, there are none.
+var GetLLDPNeighbours = func() (map[string]LLDPNeighbour, error) {
+	if _, err := os.Stat(lldpSocket); os.IsNotExist(err) {
+		return map[string]LLDPNeighbour{}, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), lldpTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "lldpcli", "-u", lldpSocket, "-f", "keyvalue", "show", "neighbors").Output()
+	if err, ok := err.(*exec.Error); ok && err.Err == exec.ErrNotFound {
+		return map[string]LLDPNeighbour{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParseLLDPNeighbours(out), nil
+}
+
+// lldpNeighbours returns the neighbours of the interfaces, read from
+// lldpd at most every lldpRefreshInterval. lldpcli is run without
+// holding the lock.
+func (r *Reporter) lldpNeighbours(now time.Time) map[string]LLDPNeighbour {
+	r.Lock()
+	if r.lldp.neighbours != nil && now.Sub(r.lldp.at) < lldpRefreshInterval {
+		defer r.Unlock()
+		return r.lldp.neighbours
+	}
+	r.Unlock()
+
+	neighbours, err := GetLLDPNeighbours()
+	if err != nil {
+		log.Warnf("Error reading LLDP neighbours: %v", err)
+		neighbours = map[string]LLDPNeighbour{}
+	}
+	r.Lock()
+	defer r.Unlock()
+	r.lldp.neighbours, r.lldp.at = neighbours, now
+	return neighbours
+}
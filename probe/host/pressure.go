@@ -0,0 +1,86 @@
+package host
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Keys for the saturation metrics of host nodes.
+const (
+	SwapUsage = "host_swap_usage_bytes"
+	// The percent of the last 10s some tasks were stalled on each
+	// resource, from the pressure stall information of the kernel
+	CPUPressure    = "host_cpu_pressure_percent"
+	MemoryPressure = "host_mem_pressure_percent"
+	IOPressure     = "host_io_pressure_percent"
+	// The average time tasks waited on a run queue before running, over
+	// the last report
+	RunQueueLatency = "host_run_queue_latency_ms"
+)
+
+// Exposed for testing.
+const (
+	ProcPressure  = "/proc/pressure"
+	ProcSchedstat = "/proc/schedstat"
+)
+
+// Pressure is the pressure stall information of the resources of a host,
+// as the avg10 of the tasks stalled on them, in percent.
+type Pressure struct {
+	CPU, Memory, IO float64
+}
+
+// ParsePressure parses the avg10 of the "some" line of a file of
+// /proc/pressure.
+func ParsePressure(buf []byte) (float64, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(buf))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || fields[0] != "some" {
+			continue
+		}
+		for _, field := range fields[1:] {
+			if strings.HasPrefix(field, "avg10=") {
+				return strconv.ParseFloat(strings.TrimPrefix(field, "avg10="), 64)
+			}
+		}
+	}
+	return 0, fmt.Errorf("no pressure in %q", buf)
+}
+
+// ParseSchedstat parses the total time tasks waited on the run queues of
+// every cpu, in nanoseconds, and the number of timeslices run, from the
+// contents of /proc/schedstat.
+func ParseSchedstat(buf []byte) (uint64, uint64, error) {
+	var (
+		found             bool
+		delay, timeslices uint64
+		scanner           = bufio.NewScanner(bytes.NewReader(buf))
+	)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		// cpuN, then 9 counters, of which the last two are the run delay
+		// and the timeslices
+		if len(fields) < 10 || !strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+		d, err := strconv.ParseUint(fields[8], 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+		n, err := strconv.ParseUint(fields[9], 10, 64)
+		if err != nil {
+			return 0, 0, err
+		}
+		delay += d
+		timeslices += n
+		found = true
+	}
+	if !found {
+		return 0, 0, fmt.Errorf("no cpus in schedstat")
+	}
+	return delay, timeslices, nil
+}
@@ -0,0 +1,73 @@
+package host_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/weaveworks/scope/probe/controls"
+	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/report"
+)
+
+const schedstat = `version 15
+timestamp 4295906580
+cpu0 0 0 0 0 0 0 1000000000 3000000 1000
+domain0 00000003 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0
+cpu1 0 0 0 0 0 0 2000000000 1000000 1000
+`
+
+func TestParsePressure(t *testing.T) {
+	have, err := host.ParsePressure([]byte("some avg10=4.04 avg60=4.35 avg300=5.39 total=787232586\nfull avg10=1.00 avg60=0.00 avg300=0.00 total=0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have != 4.04 {
+		t.Errorf("want 4.04, have %v", have)
+	}
+	if _, err := host.ParsePressure([]byte("")); err == nil {
+		t.Error("expected an error without pressure")
+	}
+}
+
+func TestParseSchedstat(t *testing.T) {
+	delay, timeslices, err := host.ParseSchedstat([]byte(schedstat))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delay != 4000000 || timeslices != 2000 {
+		t.Errorf("want 4000000ns over 2000 timeslices, have %dns over %d", delay, timeslices)
+	}
+}
+
+func TestSaturationMetrics(t *testing.T) {
+	var (
+		oldGetSwapUsageBytes  = host.GetSwapUsageBytes
+		oldGetPressure        = host.GetPressure
+		oldGetRunQueueLatency = host.GetRunQueueLatency
+	)
+	defer func() {
+		host.GetSwapUsageBytes = oldGetSwapUsageBytes
+		host.GetPressure = oldGetPressure
+		host.GetRunQueueLatency = oldGetRunQueueLatency
+	}()
+	host.GetSwapUsageBytes = func() (float64, float64) { return 10.0, 100.0 }
+	host.GetPressure = func() (host.Pressure, error) { return host.Pressure{CPU: 1, Memory: 2, IO: 3}, nil }
+	host.GetRunQueueLatency = func() (float64, bool) { return 0.5, true }
+
+	rpt, err := host.NewReporter("hostid", "hostname", "probe-id", "", nil, controls.NewDefaultHandlerRegistry()).Report(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := rpt.Host.Nodes[report.MakeHostNodeID("hostid")]
+	for key, want := range map[string]float64{
+		host.SwapUsage:       10,
+		host.CPUPressure:     1,
+		host.MemoryPressure:  2,
+		host.IOPressure:      3,
+		host.RunQueueLatency: 0.5,
+	} {
+		if sample, ok := node.Metrics[key].LastSample(); !ok || sample.Value != want {
+			t.Errorf("expected %s %v, have %v", key, want, sample.Value)
+		}
+	}
+}
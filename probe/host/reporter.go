@@ -1,17 +1,23 @@
 package host
 
 import (
+	"context"
 	"fmt"
+	"net"
 	"runtime"
 	"strconv"
 	"sync"
 	"time"
 
 	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/common/xlog"
 	"github.com/weaveworks/scope/probe/controls"
 	"github.com/weaveworks/scope/report"
 )
 
+// log is the logger of the host component
+var log = xlog.Component("host").WithField("topology", report.Host)
+
 // Keys for use in Node.Latest.
 const (
 	Timestamp     = "ts"
@@ -32,6 +38,13 @@ const (
 	ProcLoad    = "/proc/loadavg"
 	ProcStat    = "/proc/stat"
 	ProcMemInfo = "/proc/meminfo"
+	ProcNetDev  = "/proc/net/dev"
+	ProcNetARP  = "/proc/net/arp"
+	// The mounts of the host, through its init process, as the probe
+	// may run in a container of its own
+	ProcMounts  = "/proc/1/mounts"
+	ProcRootFS  = "/proc/1/root"
+	SysClassNet = "/sys/class/net"
 )
 
 // Exposed for testing.
@@ -43,12 +56,26 @@ var (
 		OS:            {ID: OS, Label: "OS", From: report.FromLatest, Priority: 12},
 		LocalNetworks: {ID: LocalNetworks, Label: "Local networks", From: report.FromSets, Priority: 13},
 		ScopeVersion:  {ID: ScopeVersion, Label: "Scope version", From: report.FromLatest, Priority: 14},
+
+		FilesystemsAboveThreshold: {ID: FilesystemsAboveThreshold, Label: fmt.Sprintf("Disks above %.0f%%", FilesystemUsageThreshold), From: report.FromSets, Priority: 3},
 	}
 
 	MetricTemplates = report.MetricTemplates{
 		CPUUsage:    {ID: CPUUsage, Label: "CPU", Format: report.PercentFormat, Priority: 1},
 		MemoryUsage: {ID: MemoryUsage, Label: "Memory", Format: report.FilesizeFormat, Priority: 2},
 		Load1:       {ID: Load1, Label: "Load (1m)", Format: report.DefaultFormat, Group: "load", Priority: 11},
+
+		FilesystemMaxUsage: {ID: FilesystemMaxUsage, Label: "Fullest disk", Format: report.PercentFormat, Priority: 5},
+
+		SwapUsage:       {ID: SwapUsage, Label: "Swap", Format: report.FilesizeFormat, Priority: 6},
+		CPUPressure:     {ID: CPUPressure, Label: "CPU pressure", Format: report.PercentFormat, Group: "pressure", Priority: 7},
+		MemoryPressure:  {ID: MemoryPressure, Label: "Memory pressure", Format: report.PercentFormat, Group: "pressure", Priority: 8},
+		IOPressure:      {ID: IOPressure, Label: "IO pressure", Format: report.PercentFormat, Group: "pressure", Priority: 9},
+		RunQueueLatency: {ID: RunQueueLatency, Label: "Run queue latency (ms)", Format: report.DefaultFormat, Priority: 10},
+
+		// Rolled up from the host's processes by the render pipeline
+		report.ProcessesCPUUsage:    {ID: report.ProcessesCPUUsage, Label: "Processes CPU", Format: report.PercentFormat, Priority: 3},
+		report.ProcessesMemoryUsage: {ID: report.ProcessesMemoryUsage, Label: "Processes memory", Format: report.FilesizeFormat, Priority: 4},
 	}
 )
 
@@ -63,6 +90,8 @@ type Reporter struct {
 	hostShellCmd    []string
 	handlerRegistry *controls.HandlerRegistry
 	pipeIDToTTY     map[string]uintptr
+	interfaces      interfaceSample
+	lldp            lldpSample
 }
 
 // NewReporter returns a Reporter which produces a report containing host
@@ -85,14 +114,33 @@ func NewReporter(hostID, hostName, probeID, version string, pipes controls.PipeC
 // Name of this reporter, for metrics gathering
 func (*Reporter) Name() string { return "Host" }
 
+// Topologies of the reporter, for annotating them when it fails.
+func (*Reporter) Topologies() []string { return []string{report.Host} }
+
 // GetLocalNetworks is exported for mocking
 var GetLocalNetworks = report.GetLocalNetworks
 
+// sharedIPs are the addresses bridges and NATs take by default, so many
+// hosts have them: docker0, libvirt's virbr0, podman's and VirtualBox's.
+var sharedIPs = map[string]bool{
+	"172.17.0.1":    true,
+	"192.168.122.1": true,
+	"10.88.0.1":     true,
+	"10.0.2.15":     true,
+}
+
+// uniqueIP tells whether the address may identify the host: loopback,
+// link-local and the addresses of default bridges are shared by hosts.
+func uniqueIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsUnspecified() && !sharedIPs[ip.String()]
+}
+
 // Report implements Reporter.
-func (r *Reporter) Report() (report.Report, error) {
+func (r *Reporter) Report(context.Context) (report.Report, error) {
 	var (
 		rep        = report.MakeReport()
 		localCIDRs []string
+		localIPs   []string
 	)
 
 	localNets, err := GetLocalNetworks()
@@ -101,6 +149,9 @@ func (r *Reporter) Report() (report.Report, error) {
 	}
 	for _, localNet := range localNets {
 		localCIDRs = append(localCIDRs, localNet.String())
+		if uniqueIP(localNet.IP) {
+			localIPs = append(localIPs, localNet.IP.String())
+		}
 	}
 
 	uptime, err := GetUptime()
@@ -123,6 +174,27 @@ func (r *Reporter) Report() (report.Report, error) {
 	metrics[CPUUsage] = report.MakeSingletonMetric(now, cpuUsage).WithMax(max)
 	memoryUsage, max := GetMemoryUsageBytes()
 	metrics[MemoryUsage] = report.MakeSingletonMetric(now, memoryUsage).WithMax(max)
+	if swapUsage, max := GetSwapUsageBytes(); max > 0 {
+		metrics[SwapUsage] = report.MakeSingletonMetric(now, swapUsage).WithMax(max)
+	}
+	if pressure, err := GetPressure(); err == nil {
+		metrics[CPUPressure] = report.MakeSingletonMetric(now, pressure.CPU).WithMax(100)
+		metrics[MemoryPressure] = report.MakeSingletonMetric(now, pressure.Memory).WithMax(100)
+		metrics[IOPressure] = report.MakeSingletonMetric(now, pressure.IO).WithMax(100)
+	}
+	if latency, ok := GetRunQueueLatency(); ok {
+		metrics[RunQueueLatency] = report.MakeSingletonMetric(now, latency)
+	}
+
+	filesystems, err := GetFilesystems()
+	if err != nil {
+		log.Warnf("Error reading filesystems: %v", err)
+	}
+	fsMetrics, fsTemplates, fsAboveThreshold := filesystemMetrics(now, filesystems)
+	for id, metric := range fsMetrics {
+		metrics[id] = metric
+	}
+	rep.Host = rep.Host.WithMetricTemplates(fsTemplates)
 
 	rep.Host.AddNode(
 		report.MakeNodeWith(report.MakeHostNodeID(r.hostID), map[string]string{
@@ -135,8 +207,11 @@ func (r *Reporter) Report() (report.Report, error) {
 			ScopeVersion:          r.version,
 		}).
 			WithSets(report.MakeSets().
-				Add(LocalNetworks, report.MakeStringSet(localCIDRs...)),
+				Add(LocalNetworks, report.MakeStringSet(localCIDRs...)).
+				Add(FilesystemsAboveThreshold, report.MakeStringSet(fsAboveThreshold...)),
 			).
+			WithIdentities(report.IdentityHostname, r.hostID).
+			WithIdentities(report.IdentityIP, localIPs...).
 			WithMetrics(metrics).
 			WithLatestActiveControls(ExecHost),
 	)
@@ -147,6 +222,12 @@ func (r *Reporter) Report() (report.Report, error) {
 		Icon:  "fa-terminal",
 	})
 
+	interfaces, err := r.interfacesTopology(now)
+	if err != nil {
+		log.Warnf("Error reading network interfaces: %v", err)
+	}
+	rep.NetworkInterface = rep.NetworkInterface.Merge(interfaces)
+
 	return rep, nil
 }
 
@@ -1,6 +1,7 @@
 package host_test
 
 import (
+	"context"
 	"net"
 	"runtime"
 	"testing"
@@ -54,10 +55,12 @@ func TestReporter(t *testing.T) {
 	host.GetUptime = func() (time.Duration, error) { return time.Hour, nil }
 	host.GetCPUUsagePercent = func() (float64, float64) { return 30.0, 100.0 }
 	host.GetMemoryUsageBytes = func() (float64, float64) { return 60.0, 100.0 }
-	host.GetLocalNetworks = func() ([]*net.IPNet, error) { return []*net.IPNet{ipnet}, nil }
+	_, bridge, _ := net.ParseCIDR("172.17.0.0/16")
+	bridge.IP = net.ParseIP("172.17.0.1").To4()
+	host.GetLocalNetworks = func() ([]*net.IPNet, error) { return []*net.IPNet{ipnet, bridge}, nil }
 
 	hr := controls.NewDefaultHandlerRegistry()
-	rpt, err := host.NewReporter(hostID, hostname, "probe-id", "", nil, hr).Report()
+	rpt, err := host.NewReporter(hostID, hostname, "probe-id", "", nil, hr).Report(context.Background())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -89,6 +92,21 @@ func TestReporter(t *testing.T) {
 		t.Errorf("Expected host.LocalNetworks to include %q, got %q", network, have)
 	}
 
+	// Should be identified by its ID, which is qualified by its cluster, and
+	// its local address, but not that of the docker bridge
+	identities, _ := node.Sets.Lookup(report.Identities)
+	for _, want := range []string{
+		report.MakeIdentity(report.IdentityHostname, hostID),
+		report.MakeIdentity(report.IdentityIP, "192.168.0.0"),
+	} {
+		if !identities.Contains(want) {
+			t.Errorf("Expected identity %q, got %q", want, identities)
+		}
+	}
+	if bridge := report.MakeIdentity(report.IdentityIP, "172.17.0.1"); identities.Contains(bridge) {
+		t.Errorf("Expected no identity %q, got %q", bridge, identities)
+	}
+
 	// Should have metrics
 	for key, want := range metrics {
 		wantSample, _ := want.LastSample()
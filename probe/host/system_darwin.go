@@ -2,6 +2,7 @@ package host
 
 import (
 	"bytes"
+	"fmt"
 	"os/exec"
 	"regexp"
 	"strconv"
@@ -84,3 +85,38 @@ var GetCPUUsagePercent = func() (float64, float64) {
 var GetMemoryUsageBytes = func() (float64, float64) {
 	return 0.0, 0.0
 }
+
+// GetSwapUsageBytes returns the bytes swap usage and max
+var GetSwapUsageBytes = func() (float64, float64) {
+	return 0.0, 0.0
+}
+
+// GetPressure returns the pressure stall information of the host. Not
+// implemented on darwin.
+var GetPressure = func() (Pressure, error) {
+	return Pressure{}, fmt.Errorf("pressure stall information not available on darwin")
+}
+
+// GetRunQueueLatency returns the average time in milliseconds tasks
+// waited on the run queues. Not implemented on darwin.
+var GetRunQueueLatency = func() (float64, bool) {
+	return 0, false
+}
+
+// GetNetworkInterfaceStats returns the counters and link properties of
+// every network interface. Not implemented on darwin.
+var GetNetworkInterfaceStats = func() (map[string]InterfaceStats, error) {
+	return map[string]InterfaceStats{}, nil
+}
+
+// GetNeighbours returns the addresses of the neighbours on every
+// network interface. Not implemented on darwin.
+var GetNeighbours = func() (map[string][]string, error) {
+	return map[string][]string{}, nil
+}
+
+// GetFilesystems returns the capacity and usage of the mounted storage
+// filesystems. Not implemented on darwin.
+var GetFilesystems = func() ([]Filesystem, error) {
+	return nil, nil
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -108,3 +110,117 @@ var GetMemoryUsageBytes = func() (float64, float64) {
 	used := meminfo.MemTotal - meminfo.MemFree - meminfo.Buffers - meminfo.Cached
 	return float64(used * kb), float64(meminfo.MemTotal * kb)
 }
+
+// GetSwapUsageBytes returns the bytes swap usage and max
+var GetSwapUsageBytes = func() (float64, float64) {
+	meminfo, err := linuxproc.ReadMemInfo(ProcMemInfo)
+	if err != nil {
+		return 0.0, 0.0
+	}
+	return float64((meminfo.SwapTotal - meminfo.SwapFree) * kb), float64(meminfo.SwapTotal * kb)
+}
+
+// GetPressure returns the pressure stall information of the host, which
+// only kernels from 4.20 with it enabled have.
+var GetPressure = func() (Pressure, error) {
+	var (
+		pressure  Pressure
+		resources = map[string]*float64{"cpu": &pressure.CPU, "memory": &pressure.Memory, "io": &pressure.IO}
+	)
+	for name, value := range resources {
+		buf, err := ioutil.ReadFile(filepath.Join(ProcPressure, name))
+		if err != nil {
+			return Pressure{}, err
+		}
+		if *value, err = ParsePressure(buf); err != nil {
+			return Pressure{}, err
+		}
+	}
+	return pressure, nil
+}
+
+var previousRunDelay, previousTimeslices uint64
+
+// GetRunQueueLatency returns the average time in milliseconds tasks
+// waited on the run queues since it was last called, and false on the
+// first call, or if unavailable.
+var GetRunQueueLatency = func() (float64, bool) {
+	buf, err := ioutil.ReadFile(ProcSchedstat)
+	if err != nil {
+		return 0, false
+	}
+	delay, timeslices, err := ParseSchedstat(buf)
+	if err != nil {
+		return 0, false
+	}
+	prevDelay, prevTimeslices := previousRunDelay, previousTimeslices
+	previousRunDelay, previousTimeslices = delay, timeslices
+	if prevTimeslices == 0 || timeslices <= prevTimeslices || delay < prevDelay {
+		return 0, false
+	}
+	return float64(delay-prevDelay) / float64(timeslices-prevTimeslices) / float64(time.Millisecond), true
+}
+
+// GetNetworkInterfaceStats returns the counters and link properties of
+// every network interface.
+var GetNetworkInterfaceStats = func() (map[string]InterfaceStats, error) {
+	buf, err := ioutil.ReadFile(ProcNetDev)
+	if err != nil {
+		return nil, err
+	}
+	stats, err := ParseNetDev(buf)
+	if err != nil {
+		return nil, err
+	}
+	for name, s := range stats {
+		dir := filepath.Join(SysClassNet, name)
+		if speed, err := ioutil.ReadFile(filepath.Join(dir, "speed")); err == nil {
+			// Virtual interfaces report -1, or fail to read
+			if mbps, err := strconv.Atoi(strings.TrimSpace(string(speed))); err == nil && mbps > 0 {
+				s.Speed = mbps
+			}
+		}
+		// Only interfaces backed by a device are physical
+		if _, err := os.Stat(filepath.Join(dir, "device")); err == nil {
+			s.Physical = true
+		}
+		stats[name] = s
+	}
+	return stats, nil
+}
+
+// GetFilesystems returns the capacity and usage of the mounted storage
+// filesystems of the host.
+var GetFilesystems = func() ([]Filesystem, error) {
+	buf, err := ioutil.ReadFile(ProcMounts)
+	if err != nil {
+		return nil, err
+	}
+	var filesystems []Filesystem
+	for _, m := range ParseMounts(buf) {
+		var stat unix.Statfs_t
+		if err := unix.Statfs(filepath.Join(ProcRootFS, m.Mountpoint), &stat); err != nil {
+			continue
+		}
+		// Used as df does, counting the blocks reserved for root as free
+		size := stat.Blocks * uint64(stat.Bsize)
+		filesystems = append(filesystems, Filesystem{
+			Mountpoint: m.Mountpoint,
+			Device:     m.Device,
+			Type:       m.Type,
+			Size:       size,
+			Used:       size - stat.Bfree*uint64(stat.Bsize),
+		})
+	}
+	return filesystems, nil
+}
+
+// GetNeighbours returns the addresses of the neighbours on every
+// network interface, from the ARP table.
+var GetNeighbours = func() (map[string][]string, error) {
+	buf, err := ioutil.ReadFile(ProcNetARP)
+	if err != nil {
+		return nil, err
+	}
+	return ParseARP(buf), nil
+}
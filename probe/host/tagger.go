@@ -1,6 +1,7 @@
 package host
 
 import (
+	"context"
 	"github.com/weaveworks/scope/report"
 )
 
@@ -8,6 +9,7 @@ import (
 // node ID of this (probe) host. Effectively, a foreign key linking every node
 // in every topology to an origin host node in the host topology.
 type Tagger struct {
+	hostID     string
 	hostNodeID string
 }
 
@@ -15,6 +17,7 @@ type Tagger struct {
 // in the host topology.
 func NewTagger(hostID string) Tagger {
 	return Tagger{
+		hostID:     hostID,
 		hostNodeID: report.MakeHostNodeID(hostID),
 	}
 }
@@ -23,7 +26,7 @@ func NewTagger(hostID string) Tagger {
 func (Tagger) Name() string { return "Host" }
 
 // Tag implements Tagger.
-func (t Tagger) Tag(r report.Report) (report.Report, error) {
+func (t Tagger) Tag(_ context.Context, r report.Report) (report.Report, error) {
 	var (
 		metadata = map[string]string{report.HostNodeID: t.hostNodeID}
 	)
@@ -35,5 +38,13 @@ func (t Tagger) Tag(r report.Report) (report.Report, error) {
 			topology.ReplaceNode(node.WithLatests(metadata).WithParent(report.Host, t.hostNodeID))
 		}
 	}
+	// Errors are told apart by host once merged with those of other probes
+	if len(r.Errors) > 0 {
+		annotated := make(map[string]string, len(r.Errors))
+		for topology, message := range r.Errors {
+			annotated[topology] = "host " + t.hostID + ": " + message
+		}
+		r.Errors = annotated
+	}
 	return r, nil
 }
@@ -1,6 +1,7 @@
 package host_test
 
 import (
+	"context"
 	"testing"
 
 	"github.com/weaveworks/scope/probe/host"
@@ -16,7 +17,7 @@ func TestTagger(t *testing.T) {
 
 	r := report.MakeReport()
 	r.Process.AddNode(node)
-	rpt, _ := host.NewTagger(hostID).Tag(r)
+	rpt, _ := host.NewTagger(hostID).Tag(context.Background(), r)
 	have := rpt.Process.Nodes[endpointNodeID]
 
 	// It should now have the host ID
@@ -37,3 +38,12 @@ func TestTagger(t *testing.T) {
 		t.Errorf("Expected %q got %q", report.MakeStringSet(wantParent), have)
 	}
 }
+
+func TestTaggerErrors(t *testing.T) {
+	r := report.MakeReport()
+	r.AddError(report.Container, "Docker: connection refused")
+	rpt, _ := host.NewTagger("foo").Tag(context.Background(), r)
+	if want, have := "host foo: Docker: connection refused", rpt.Errors[report.Container]; want != have {
+		t.Errorf("Expected %q got %q", want, have)
+	}
+}
@@ -0,0 +1,154 @@
+package identity
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// DefaultDir is where probes keep their key pair.
+const DefaultDir = "/var/lib/scope"
+
+const keyFile = "probe.key"
+
+// Identity is the key pair of a probe, generated on its first start and
+// kept across restarts, and the ID of the probe, derived from the public
+// key. Probes sign what they publish with it, so that apps can tell
+// probes apart and attribute their reports across restarts.
+type Identity struct {
+	ID  string
+	key ed25519.PrivateKey
+}
+
+// Load loads the key pair kept in dir, generating and keeping a new one
+// if there is none.
+func Load(dir string) (*Identity, error) {
+	path := filepath.Join(dir, keyFile)
+	buf, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return generate(path)
+	} else if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(buf)
+	if block == nil {
+		return nil, fmt.Errorf("no key in %s", path)
+	}
+	key, err := parsePKCS8(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key in %s: %v", path, err)
+	}
+	return newIdentity(key), nil
+}
+
+func generate(path string) (*Identity, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := marshalPKCS8(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	// Written aside and renamed, so that a probe stopped half way doesn't
+	// leave a truncated key behind
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return nil, err
+	}
+	return newIdentity(key), nil
+}
+
+// oidEd25519 identifies ed25519 keys (RFC 8410).
+var oidEd25519 = asn1.ObjectIdentifier{1, 3, 101, 112}
+
+// pkcs8 is a PKCS #8 private key. crypto/x509 only reads and writes those
+// of ed25519 from Go 1.13, so they are (un)marshalled here.
+type pkcs8 struct {
+	Version    int
+	Algo       pkix.AlgorithmIdentifier
+	PrivateKey []byte
+}
+
+func marshalPKCS8(key ed25519.PrivateKey) ([]byte, error) {
+	// The private key of ed25519 is its seed, as an octet string
+	seed, err := asn1.Marshal(key[:32])
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(pkcs8{Algo: pkix.AlgorithmIdentifier{Algorithm: oidEd25519}, PrivateKey: seed})
+}
+
+func parsePKCS8(der []byte) (ed25519.PrivateKey, error) {
+	var key pkcs8
+	if _, err := asn1.Unmarshal(der, &key); err != nil {
+		return nil, err
+	}
+	if !key.Algo.Algorithm.Equal(oidEd25519) {
+		return nil, fmt.Errorf("not an ed25519 key")
+	}
+	var seed []byte
+	if _, err := asn1.Unmarshal(key.PrivateKey, &seed); err != nil {
+		return nil, err
+	}
+	if len(seed) != 32 {
+		return nil, fmt.Errorf("invalid ed25519 seed")
+	}
+	// The key pair is generated from its seed
+	_, priv, err := ed25519.GenerateKey(bytes.NewReader(seed))
+	return priv, err
+}
+
+func newIdentity(key ed25519.PrivateKey) *Identity {
+	return &Identity{ID: IDOf(key.Public().(ed25519.PublicKey)), key: key}
+}
+
+// IDOf returns the ID of the probe of a public key.
+func IDOf(publicKey ed25519.PublicKey) string {
+	sum := sha256.Sum256(publicKey)
+	return hex.EncodeToString(sum[:8])
+}
+
+// PublicKey returns the public key of the probe, base64-encoded.
+func (i *Identity) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(i.key.Public().(ed25519.PublicKey))
+}
+
+// Sign returns the signature of data, base64-encoded.
+func (i *Identity) Sign(data []byte) string {
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(i.key, data))
+}
+
+// Verify checks that data was signed by the probe of the ID with the
+// base64-encoded public key and signature.
+func Verify(id, publicKey string, data []byte, signature string) error {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid public key")
+	}
+	if IDOf(key) != id {
+		return fmt.Errorf("public key is not of probe %s", id)
+	}
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || !ed25519.Verify(key, data, sig) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+// Package imagefetch fetches what is known of container images in the
+// background, for taggers which can't hold reports up on slow lookups
+// of registries or scanners.
+package imagefetch
+
+import (
+	"sync"
+	"time"
+
+	"github.com/weaveworks/common/mtime"
+)
+
+// queueLength bounds the images waiting to be fetched.
+const queueLength = 1024
+
+// Image is a container image, as reported: the name it was pulled by,
+// and the digest of its manifest.
+type Image struct {
+	Name, Digest string
+}
+
+// Fetch fetches what is known of an image.
+type Fetch func(Image) (interface{}, error)
+
+type result struct {
+	value interface{}
+	err   error
+	at    time.Time
+}
+
+// Cache fetches images one at a time, in the background, the first time
+// they are looked up, and caches what was fetched by digest: an image is
+// known in the lookups following its first fetch. Fetches are done
+// again once expired, if a TTL is given, and failed fetches are retried
+// after a while.
+type Cache struct {
+	fetch      Fetch
+	ttl        time.Duration
+	retryAfter time.Duration
+
+	mtx     sync.Mutex
+	results map[string]result
+	pending map[string]struct{}
+
+	queue chan Image
+	quit  chan struct{}
+	done  chan struct{}
+}
+
+// NewCache makes a new Cache, keeping what was fetched for ttl, or for as
+// long as images are looked up if ttl is 0, and retrying failed fetches
+// after retryAfter. Don't forget to Stop it.
+func NewCache(fetch Fetch, ttl, retryAfter time.Duration) *Cache {
+	c := &Cache{
+		fetch:      fetch,
+		ttl:        ttl,
+		retryAfter: retryAfter,
+		results:    map[string]result{},
+		pending:    map[string]struct{}{},
+		queue:      make(chan Image, queueLength),
+		quit:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	go c.loop()
+	return c
+}
+
+// Stop stops the background fetches.
+func (c *Cache) Stop() {
+	close(c.quit)
+	<-c.done
+}
+
+// Lookup returns what was fetched of the image, if the fetch succeeded,
+// scheduling a fetch unless one is cached, expired or not.
+func (c *Cache) Lookup(image Image) (interface{}, bool) {
+	now := mtime.Now()
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	r, ok := c.results[image.Digest]
+	if !ok || c.expired(r, now) {
+		c.enqueue(image)
+	}
+	if !ok || r.err != nil {
+		return nil, false
+	}
+	return r.value, true
+}
+
+// Retain forgets the images other than those of the digests, e.g. as
+// they are gone.
+func (c *Cache) Retain(digests map[string]struct{}) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	for digest := range c.results {
+		if _, ok := digests[digest]; !ok {
+			delete(c.results, digest)
+		}
+	}
+}
+
+func (c *Cache) expired(r result, now time.Time) bool {
+	age := now.Sub(r.at)
+	if r.err != nil {
+		return age > c.retryAfter
+	}
+	return c.ttl > 0 && age > c.ttl
+}
+
+// enqueue schedules a fetch, unless one is already scheduled. Must be
+// called with the lock held.
+func (c *Cache) enqueue(image Image) {
+	if _, ok := c.pending[image.Digest]; ok {
+		return
+	}
+	select {
+	case c.queue <- image:
+		c.pending[image.Digest] = struct{}{}
+	default:
+		// The queue is full; try again in the next lookup
+	}
+}
+
+func (c *Cache) loop() {
+	defer close(c.done)
+	for {
+		select {
+		case image := <-c.queue:
+			// Results are as old as their fetches
+			at := mtime.Now()
+			value, err := c.fetch(image)
+			c.mtx.Lock()
+			c.results[image.Digest] = result{value: value, err: err, at: at}
+			delete(c.pending, image.Digest)
+			c.mtx.Unlock()
+		case <-c.quit:
+			return
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package imagefetch_test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/common/mtime"
+
+	"github.com/weaveworks/scope/probe/imagefetch"
+	"github.com/weaveworks/scope/test"
+)
+
+type mockFetcher struct {
+	sync.Mutex
+	fetches map[string]int
+}
+
+func (m *mockFetcher) fetch(image imagefetch.Image) (interface{}, error) {
+	m.Lock()
+	defer m.Unlock()
+	m.fetches[image.Digest]++
+	if image.Digest == "sha256:broken" {
+		return nil, fmt.Errorf("not found")
+	}
+	return image.Name + "@" + image.Digest, nil
+}
+
+func (m *mockFetcher) count(digest string) int {
+	m.Lock()
+	defer m.Unlock()
+	return m.fetches[digest]
+}
+
+func TestCache(t *testing.T) {
+	now := time.Now()
+	mtime.NowForce(now)
+	defer mtime.NowReset()
+
+	fetcher := &mockFetcher{fetches: map[string]int{}}
+	cache := imagefetch.NewCache(fetcher.fetch, time.Hour, time.Minute)
+	defer cache.Stop()
+
+	var (
+		image  = imagefetch.Image{Name: "foo/bar", Digest: "sha256:abc"}
+		broken = imagefetch.Image{Name: "foo/bar", Digest: "sha256:broken"}
+	)
+	lookup := func(image imagefetch.Image) interface{} {
+		value, _ := cache.Lookup(image)
+		return value
+	}
+	// The first lookups trigger the fetches
+	if value, ok := cache.Lookup(image); ok {
+		t.Errorf("expected nothing before the fetch, got %v", value)
+	}
+	cache.Lookup(broken)
+	test.Poll(t, 300*time.Millisecond, "foo/bar@sha256:abc", func() interface{} { return lookup(image) })
+	test.Poll(t, 300*time.Millisecond, 1, func() interface{} { return fetcher.count(broken.Digest) })
+	if value, ok := cache.Lookup(broken); ok {
+		t.Errorf("expected nothing for a failed fetch, got %v", value)
+	}
+
+	// Fetches are cached, failed fetches retried after a while, and
+	// others once expired, the value expired being kept meanwhile
+	mtime.NowForce(now.Add(2 * time.Minute))
+	cache.Lookup(broken)
+	test.Poll(t, 300*time.Millisecond, 2, func() interface{} { return fetcher.count(broken.Digest) })
+	if have := fetcher.count(image.Digest); have != 1 {
+		t.Errorf("expected one fetch, got %d", have)
+	}
+	mtime.NowForce(now.Add(2 * time.Hour))
+	if value := lookup(image); value != "foo/bar@sha256:abc" {
+		t.Errorf("expected the value expired to be kept, got %v", value)
+	}
+	test.Poll(t, 300*time.Millisecond, 2, func() interface{} { return fetcher.count(image.Digest) })
+
+	// Images not retained are fetched again
+	cache.Retain(map[string]struct{}{})
+	if value, ok := cache.Lookup(image); ok {
+		t.Errorf("expected the image to be forgotten, got %v", value)
+	}
+	test.Poll(t, 300*time.Millisecond, 3, func() interface{} { return fetcher.count(image.Digest) })
+}
@@ -0,0 +1,303 @@
+package imageregistry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	dockerHub         = "registry-1.docker.io"
+	dockerHubAuthKey  = "https://index.docker.io/v1/"
+	manifestV2        = "application/vnd.docker.distribution.manifest.v2+json"
+	manifestList      = "application/vnd.docker.distribution.manifest.list.v2+json"
+	ociManifest       = "application/vnd.oci.image.manifest.v1+json"
+	ociIndex          = "application/vnd.oci.image.index.v1+json"
+	maxManifestLength = 4 << 20
+)
+
+// Metadata is what the registry knows of an image.
+type Metadata struct {
+	Created      time.Time
+	Size         int64
+	Architecture string
+	OS           string
+	Labels       map[string]string
+}
+
+// Credentials are the username and password to log into a registry.
+type Credentials struct {
+	Username, Password string
+}
+
+// Client fetches image metadata from registries implementing the Docker
+// Registry HTTP API V2, logging in with token auth where needed.
+type Client struct {
+	client      *http.Client
+	credentials map[string]Credentials
+	// scheme is only swapped by tests, to talk to plain HTTP registries
+	scheme string
+}
+
+// NewClient makes a new Client, logging into registries with the given
+// credentials, keyed by registry host.
+func NewClient(credentials map[string]Credentials) *Client {
+	return &Client{
+		client:      &http.Client{Timeout: 30 * time.Second},
+		credentials: credentials,
+		scheme:      "https",
+	}
+}
+
+// LoadDockerConfig reads the registry credentials from a Docker client
+// configuration file, i.e. the "auths" of ~/.docker/config.json. An
+// empty path is the default location.
+func LoadDockerConfig(path string) (map[string]Credentials, error) {
+	if path == "" {
+		home, err := homeDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(home, ".docker", "config.json")
+	}
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config struct {
+		Auths map[string]struct {
+			Auth string `json:"auth"`
+		} `json:"auths"`
+	}
+	if err := json.Unmarshal(buf, &config); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	credentials := map[string]Credentials{}
+	for registry, auth := range config.Auths {
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("%s: auth of %s: %v", path, registry, err)
+		}
+		parts := strings.SplitN(string(decoded), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if registry == dockerHubAuthKey {
+			registry = dockerHub
+		}
+		credentials[strings.TrimPrefix(strings.TrimPrefix(registry, "https://"), "http://")] = Credentials{parts[0], parts[1]}
+	}
+	return credentials, nil
+}
+
+// homeDir returns the home directory of the user: $HOME, or that of the
+// user account if unset.
+func homeDir() (string, error) {
+	if home := os.Getenv("HOME"); home != "" {
+		return home, nil
+	}
+	u, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return u.HomeDir, nil
+}
+
+// SplitRepository splits an image name into the host of its registry and
+// its repository in that registry, following the conventions of Docker:
+// images without a registry are on the Docker Hub, where official images
+// are in the library namespace.
+func SplitRepository(imageName string) (registry, repository string) {
+	parts := strings.SplitN(imageName, "/", 2)
+	if len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		return parts[0], parts[1]
+	}
+	if len(parts) == 1 {
+		return dockerHub, "library/" + imageName
+	}
+	return dockerHub, imageName
+}
+
+// Metadata fetches the metadata of the image with the given name and
+// manifest digest. Multi-architecture images are resolved to the
+// manifest of the probe's platform.
+func (c *Client) Metadata(imageName, digest string) (Metadata, error) {
+	registry, repository := SplitRepository(imageName)
+	r := &repositoryClient{Client: c, registry: registry, repository: repository}
+
+	var manifest struct {
+		MediaType string `json:"mediaType"`
+		Config    struct {
+			Digest string `json:"digest"`
+		} `json:"config"`
+		Layers []struct {
+			Size int64 `json:"size"`
+		} `json:"layers"`
+		Manifests []struct {
+			Digest   string `json:"digest"`
+			Platform struct {
+				Architecture string `json:"architecture"`
+				OS           string `json:"os"`
+			} `json:"platform"`
+		} `json:"manifests"`
+	}
+	mediaType, err := r.get("manifests/"+digest, strings.Join([]string{manifestV2, manifestList, ociManifest, ociIndex}, ", "), &manifest)
+	if err != nil {
+		return Metadata{}, err
+	}
+	if mediaType == manifestList || mediaType == ociIndex || len(manifest.Manifests) > 0 {
+		resolved := ""
+		for _, m := range manifest.Manifests {
+			if m.Platform.Architecture == runtime.GOARCH && m.Platform.OS == "linux" {
+				resolved = m.Digest
+				break
+			}
+		}
+		if resolved == "" {
+			return Metadata{}, fmt.Errorf("%s@%s has no manifest for linux/%s", imageName, digest, runtime.GOARCH)
+		}
+		manifest.Manifests = nil
+		if _, err := r.get("manifests/"+resolved, strings.Join([]string{manifestV2, ociManifest}, ", "), &manifest); err != nil {
+			return Metadata{}, err
+		}
+	}
+
+	var config struct {
+		Created      time.Time `json:"created"`
+		Architecture string    `json:"architecture"`
+		OS           string    `json:"os"`
+		Config       struct {
+			Labels map[string]string `json:"Labels"`
+		} `json:"config"`
+	}
+	if _, err := r.get("blobs/"+manifest.Config.Digest, "", &config); err != nil {
+		return Metadata{}, err
+	}
+	var size int64
+	for _, l := range manifest.Layers {
+		size += l.Size
+	}
+	return Metadata{
+		Created:      config.Created,
+		Size:         size,
+		Architecture: config.Architecture,
+		OS:           config.OS,
+		Labels:       config.Config.Labels,
+	}, nil
+}
+
+// repositoryClient talks to a single repository, caching its token.
+type repositoryClient struct {
+	*Client
+	registry, repository string
+	token                string
+}
+
+// get decodes the JSON of a path of the repository into v, returning
+// its media type.
+func (r *repositoryClient) get(path, accept string, v interface{}) (string, error) {
+	u := fmt.Sprintf("%s://%s/v2/%s/%s", r.scheme, r.registry, r.repository, path)
+	resp, err := r.do(u, accept)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized && r.token == "" {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if err = r.login(challenge); err == nil {
+			resp, err = r.do(u, accept)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s: %s", u, resp.Status)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxManifestLength)).Decode(v); err != nil {
+		return "", fmt.Errorf("GET %s: %v", u, err)
+	}
+	return strings.TrimSpace(strings.SplitN(resp.Header.Get("Content-Type"), ";", 2)[0]), nil
+}
+
+func (r *repositoryClient) do(u, accept string) (*http.Response, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	} else if creds, ok := r.credentials[r.registry]; ok {
+		req.SetBasicAuth(creds.Username, creds.Password)
+	}
+	return r.client.Do(req)
+}
+
+// login gets a pull token from the authorization server named in a
+// Bearer challenge, e.g.
+//
+//	Bearer realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull"
+func (r *repositoryClient) login(challenge string) error {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return fmt.Errorf("%s: unsupported authentication challenge %q", r.registry, challenge)
+	}
+	params := map[string]string{}
+	for _, param := range strings.Split(strings.TrimPrefix(challenge, "Bearer "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+		if len(kv) == 2 {
+			params[kv[0]] = strings.Trim(kv[1], `"`)
+		}
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return fmt.Errorf("%s: invalid authentication realm %q", r.registry, params["realm"])
+	}
+	query := realm.Query()
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	query.Set("scope", "repository:"+r.repository+":pull")
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequest("GET", realm.String(), nil)
+	if err != nil {
+		return err
+	}
+	if creds, ok := r.credentials[r.registry]; ok {
+		req.SetBasicAuth(creds.Username, creds.Password)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: login: %s", r.registry, resp.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("%s: login: %v", r.registry, err)
+	}
+	r.token = token.Token
+	if r.token == "" {
+		r.token = token.AccessToken
+	}
+	if r.token == "" {
+		return fmt.Errorf("%s: login: no token", r.registry)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package imageregistry
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitRepository(t *testing.T) {
+	for _, c := range []struct{ in, registry, repository string }{
+		{"nginx", dockerHub, "library/nginx"},
+		{"weaveworks/scope", dockerHub, "weaveworks/scope"},
+		{"quay.io/coreos/etcd", "quay.io", "coreos/etcd"},
+		{"localhost/app", "localhost", "app"},
+		{"registry:5000/app", "registry:5000", "app"},
+	} {
+		if registry, repository := SplitRepository(c.in); registry != c.registry || repository != c.repository {
+			t.Errorf("%s: expected %s %s, got %s %s", c.in, c.registry, c.repository, registry, repository)
+		}
+	}
+}
+
+func TestClientMetadata(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if user, password, _ := r.BasicAuth(); user != "user" || password != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if r.URL.Query().Get("scope") != "repository:team/app:pull" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, `{"token": "t0k3n"}`)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer t0k3n" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/team/app/manifests/sha256:list":
+			w.Header().Set("Content-Type", manifestList)
+			fmt.Fprintf(w, `{"manifests": [
+				{"digest": "sha256:other", "platform": {"architecture": "s390x", "os": "linux"}},
+				{"digest": "sha256:image", "platform": {"architecture": %q, "os": "linux"}}
+			]}`, runtime.GOARCH)
+		case "/v2/team/app/manifests/sha256:image":
+			w.Header().Set("Content-Type", manifestV2)
+			fmt.Fprint(w, `{"config": {"digest": "sha256:config"}, "layers": [{"size": 1000}, {"size": 24}]}`)
+		case "/v2/team/app/blobs/sha256:config":
+			fmt.Fprintf(w, `{"created": "2020-01-02T03:04:05Z", "architecture": %q, "os": "linux", "config": {"Labels": {"team": "core"}}}`, runtime.GOARCH)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	registry := strings.TrimPrefix(server.URL, "http://")
+	client := NewClient(map[string]Credentials{registry: {"user", "secret"}})
+	client.scheme = "http"
+	have, err := client.Metadata(registry+"/team/app", "sha256:list")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have.Size != 1024 || have.Architecture != runtime.GOARCH || have.OS != "linux" ||
+		!have.Created.Equal(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)) || have.Labels["team"] != "core" {
+		t.Errorf("unexpected metadata: %+v", have)
+	}
+
+	if _, err := client.Metadata(registry+"/team/app", "sha256:missing"); err == nil {
+		t.Errorf("expected an error for a missing manifest")
+	}
+}
+
+func TestLoadDockerConfig(t *testing.T) {
+	home, err := ioutil.TempDir("", "scope-imageregistry")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+
+	if err := os.MkdirAll(filepath.Join(home, ".docker"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	config := `{"auths": {"https://index.docker.io/v1/": {"auth": "dXNlcjpwYXNz"}, "quay.io": {"auth": "Ym90OnNlY3JldA=="}}}`
+	if err := ioutil.WriteFile(filepath.Join(home, ".docker", "config.json"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	credentials, err := LoadDockerConfig("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have := credentials[dockerHub]; have != (Credentials{"user", "pass"}) {
+		t.Errorf("expected the credentials of the Docker Hub, got %v", have)
+	}
+	if have := credentials["quay.io"]; have != (Credentials{"bot", "secret"}) {
+		t.Errorf("expected the credentials of quay.io, got %v", have)
+	}
+}
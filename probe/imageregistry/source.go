@@ -0,0 +1,43 @@
+package imageregistry
+
+import (
+	"flag"
+	"os"
+	"time"
+
+	"github.com/weaveworks/scope/probe"
+)
+
+func init() {
+	probe.RegisterSource(&source{})
+}
+
+// source adds the registry metadata tagger to the probe when enabled by
+// flags
+type source struct {
+	enabled    bool
+	config     string
+	retryAfter time.Duration
+}
+
+func (s *source) Name() string { return "ImageRegistry" }
+
+func (s *source) RegisterFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&s.enabled, "probe.image-registry", false, "fetch the metadata of container images from their registry")
+	fs.StringVar(&s.config, "probe.image-registry.config", "", "Docker client configuration file with the credentials of registries (default ~/.docker/config.json)")
+	fs.DurationVar(&s.retryAfter, "probe.image-registry.retry", 10*time.Minute, "how long to wait before fetching the metadata of an image again after failing")
+}
+
+func (s *source) Enabled() bool { return s.enabled }
+
+func (s *source) Make(probe.Env) ([]interface{}, error) {
+	credentials, err := LoadDockerConfig(s.config)
+	if os.IsNotExist(err) && s.config == "" {
+		// Without a configuration, only public images can be fetched
+		credentials, err = map[string]Credentials{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{NewTagger(NewClient(credentials), s.retryAfter)}, nil
+}
@@ -0,0 +1,111 @@
+package imageregistry
+
+import (
+	"context"
+	"time"
+
+	humanize "github.com/dustin/go-humanize"
+
+	"github.com/weaveworks/scope/common/xlog"
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/imagefetch"
+	"github.com/weaveworks/scope/report"
+)
+
+// log is the logger of the imageregistry component
+var log = xlog.Component("imageregistry")
+
+// Keys for use in container image nodes.
+const (
+	Created      = "image_registry_created"
+	Size         = "image_registry_size"
+	Architecture = "image_registry_architecture"
+)
+
+// Exposed for testing
+var (
+	MetadataTemplates = report.MetadataTemplates{
+		Created:      {ID: Created, Label: "Created", From: report.FromLatest, Datatype: report.DateTime, Priority: 5},
+		Size:         {ID: Size, Label: "Compressed size", From: report.FromLatest, Priority: 6},
+		Architecture: {ID: Architecture, Label: "Platform", From: report.FromLatest, Priority: 7},
+	}
+)
+
+// Fetcher fetches the metadata of images from their registry.
+type Fetcher interface {
+	Metadata(imageName, digest string) (Metadata, error)
+}
+
+// Tagger attaches what the registry knows of every image with a digest
+// to its container image node. The metadata is fetched lazily, in the
+// background, the first time an image is reported. Digests are
+// immutable, so metadata is kept for as long as the image is, and only
+// failed fetches are retried.
+type Tagger struct {
+	fetcher Fetcher
+	fetches *imagefetch.Cache
+}
+
+// NewTagger makes a new Tagger, retrying failed fetches after
+// retryAfter.
+func NewTagger(fetcher Fetcher, retryAfter time.Duration) *Tagger {
+	t := &Tagger{fetcher: fetcher}
+	t.fetches = imagefetch.NewCache(t.fetch, 0, retryAfter)
+	return t
+}
+
+// Name of this tagger, for metrics gathering
+func (*Tagger) Name() string { return "ImageRegistry" }
+
+// Report implements Reporter, adding the templates of the metadata.
+func (*Tagger) Report(context.Context) (report.Report, error) {
+	rpt := report.MakeReport()
+	rpt.ContainerImage = rpt.ContainerImage.WithMetadataTemplates(MetadataTemplates)
+	return rpt, nil
+}
+
+// Stop implements Reporter, stopping the background fetches.
+func (t *Tagger) Stop() {
+	t.fetches.Stop()
+}
+
+// Tag implements Tagger.
+func (t *Tagger) Tag(_ context.Context, rpt report.Report) (report.Report, error) {
+	seen := map[string]struct{}{}
+	for id, n := range rpt.ContainerImage.Nodes {
+		digest, ok := n.Latest.Lookup(docker.ImageDigest)
+		if !ok {
+			continue
+		}
+		name, ok := n.Latest.Lookup(docker.ImageName)
+		if !ok {
+			continue
+		}
+		seen[digest] = struct{}{}
+		value, ok := t.fetches.Lookup(imagefetch.Image{Name: name, Digest: digest})
+		if !ok {
+			continue
+		}
+		metadata := value.(Metadata)
+		latests := map[string]string{
+			Size:         humanize.Bytes(uint64(metadata.Size)),
+			Architecture: metadata.OS + "/" + metadata.Architecture,
+		}
+		if !metadata.Created.IsZero() {
+			latests[Created] = metadata.Created.Format(time.RFC3339Nano)
+		}
+		rpt.ContainerImage.Nodes[id] = n.WithLatests(latests).
+			AddPrefixPropertyList(docker.ImageLabelPrefix, metadata.Labels)
+	}
+	// Forget images which are gone
+	t.fetches.Retain(seen)
+	return rpt, nil
+}
+
+func (t *Tagger) fetch(image imagefetch.Image) (interface{}, error) {
+	metadata, err := t.fetcher.Metadata(image.Name, image.Digest)
+	if err != nil {
+		log.Warnf("Error fetching metadata of %s@%s: %v", image.Name, image.Digest, err)
+	}
+	return metadata, err
+}
@@ -0,0 +1,61 @@
+package imagescan
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ClairScanner looks up vulnerabilities in the vulnerability reports of
+// a Clair (v4) server. Images are indexed by Clair itself, usually
+// from the registry, so an image Clair hasn't indexed yet is an error.
+type ClairScanner struct {
+	url    string
+	client *http.Client
+}
+
+// NewClairScanner makes a new ClairScanner for the server at url.
+func NewClairScanner(url string) *ClairScanner {
+	return &ClairScanner{
+		url:    strings.TrimSuffix(url, "/"),
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type clairVulnerabilityReport struct {
+	Vulnerabilities map[string]struct {
+		NormalizedSeverity string `json:"normalized_severity"`
+	} `json:"vulnerabilities"`
+}
+
+// Vulnerabilities implements Scanner.
+func (c *ClairScanner) Vulnerabilities(digest string) (Counts, error) {
+	resp, err := c.client.Get(c.url + "/matcher/api/v1/vulnerability_report/" + digest)
+	if err != nil {
+		return Counts{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Counts{}, fmt.Errorf("vulnerability report of %s: %s", digest, resp.Status)
+	}
+	var report clairVulnerabilityReport
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return Counts{}, err
+	}
+	var counts Counts
+	for _, v := range report.Vulnerabilities {
+		switch v.NormalizedSeverity {
+		case "Critical", "Defcon1":
+			counts.Critical++
+		case "High":
+			counts.High++
+		case "Medium":
+			counts.Medium++
+		case "Low", "Negligible":
+			counts.Low++
+		}
+	}
+	return counts, nil
+}
@@ -0,0 +1,39 @@
+package imagescan_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/weaveworks/scope/probe/imagescan"
+)
+
+func TestClairScanner(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/matcher/api/v1/vulnerability_report/sha256:abc" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"vulnerabilities": {
+			"1": {"normalized_severity": "Critical"},
+			"2": {"normalized_severity": "High"},
+			"3": {"normalized_severity": "High"},
+			"4": {"normalized_severity": "Negligible"},
+			"5": {"normalized_severity": "Unknown"}
+		}}`)
+	}))
+	defer server.Close()
+
+	scanner := imagescan.NewClairScanner(server.URL + "/")
+	have, err := scanner.Vulnerabilities("sha256:abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (imagescan.Counts{Critical: 1, High: 2, Low: 1}); have != want {
+		t.Errorf("expected %+v, got %+v", want, have)
+	}
+	if _, err := scanner.Vulnerabilities("sha256:missing"); err == nil {
+		t.Errorf("expected an error for an image which isn't indexed")
+	}
+}
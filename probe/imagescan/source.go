@@ -0,0 +1,36 @@
+package imagescan
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/weaveworks/scope/probe"
+)
+
+func init() {
+	probe.RegisterSource(&source{})
+}
+
+// source adds the image scan tagger to the probe when a scanner is
+// configured by flags
+type source struct {
+	clairURL string
+	ttl      time.Duration
+}
+
+func (s *source) Name() string { return "ImageScan" }
+
+func (s *source) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&s.clairURL, "probe.image-scan.clair-url", "", "URL of a Clair server to report the vulnerabilities of container images from (disabled if empty)")
+	fs.DurationVar(&s.ttl, "probe.image-scan.ttl", time.Hour, "how long the vulnerabilities of an image are cached for")
+}
+
+func (s *source) Enabled() bool { return s.clairURL != "" }
+
+func (s *source) Make(probe.Env) ([]interface{}, error) {
+	if s.ttl <= 0 {
+		return nil, fmt.Errorf("invalid cache TTL %v", s.ttl)
+	}
+	return []interface{}{NewTagger(NewClairScanner(s.clairURL), s.ttl)}, nil
+}
@@ -0,0 +1,109 @@
+package imagescan
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/weaveworks/scope/common/xlog"
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/imagefetch"
+	"github.com/weaveworks/scope/report"
+)
+
+// log is the logger of the imagescan component
+var log = xlog.Component("imagescan")
+
+// Keys for use in container image nodes.
+const (
+	VulnerabilitiesCritical = "image_vulnerabilities_critical"
+	VulnerabilitiesHigh     = "image_vulnerabilities_high"
+	VulnerabilitiesMedium   = "image_vulnerabilities_medium"
+	VulnerabilitiesLow      = "image_vulnerabilities_low"
+)
+
+// Exposed for testing
+var (
+	MetadataTemplates = report.MetadataTemplates{
+		VulnerabilitiesCritical: {ID: VulnerabilitiesCritical, Label: "Critical CVEs", From: report.FromLatest, Datatype: report.Number, Priority: 10},
+		VulnerabilitiesHigh:     {ID: VulnerabilitiesHigh, Label: "High CVEs", From: report.FromLatest, Datatype: report.Number, Priority: 11},
+		VulnerabilitiesMedium:   {ID: VulnerabilitiesMedium, Label: "Medium CVEs", From: report.FromLatest, Datatype: report.Number, Priority: 12},
+		VulnerabilitiesLow:      {ID: VulnerabilitiesLow, Label: "Low CVEs", From: report.FromLatest, Datatype: report.Number, Priority: 13},
+	}
+)
+
+// Counts are the numbers of vulnerabilities of an image, by severity.
+type Counts struct {
+	Critical, High, Medium, Low int
+}
+
+func (c Counts) latests() map[string]string {
+	return map[string]string{
+		VulnerabilitiesCritical: strconv.Itoa(c.Critical),
+		VulnerabilitiesHigh:     strconv.Itoa(c.High),
+		VulnerabilitiesMedium:   strconv.Itoa(c.Medium),
+		VulnerabilitiesLow:      strconv.Itoa(c.Low),
+	}
+}
+
+// Scanner looks up the vulnerabilities of images by manifest digest.
+type Scanner interface {
+	Vulnerabilities(digest string) (Counts, error)
+}
+
+// Tagger attaches the vulnerability counts of every image with a digest
+// to its container image node. Scans are slow, so they are done in the
+// background, one at a time, and cached by digest: an image gets its
+// counts in the reports following its first scan.
+type Tagger struct {
+	scanner Scanner
+	scans   *imagefetch.Cache
+}
+
+// NewTagger makes a new Tagger, caching scans for ttl.
+func NewTagger(scanner Scanner, ttl time.Duration) *Tagger {
+	t := &Tagger{scanner: scanner}
+	t.scans = imagefetch.NewCache(t.scan, ttl, ttl)
+	return t
+}
+
+// Name of this tagger, for metrics gathering
+func (*Tagger) Name() string { return "ImageScan" }
+
+// Report implements Reporter, adding the templates of the counts.
+func (*Tagger) Report(context.Context) (report.Report, error) {
+	rpt := report.MakeReport()
+	rpt.ContainerImage = rpt.ContainerImage.WithMetadataTemplates(MetadataTemplates)
+	return rpt, nil
+}
+
+// Stop implements Reporter, stopping the background scans.
+func (t *Tagger) Stop() {
+	t.scans.Stop()
+}
+
+// Tag implements Tagger.
+func (t *Tagger) Tag(_ context.Context, rpt report.Report) (report.Report, error) {
+	seen := map[string]struct{}{}
+	for id, n := range rpt.ContainerImage.Nodes {
+		digest, ok := n.Latest.Lookup(docker.ImageDigest)
+		if !ok {
+			continue
+		}
+		seen[digest] = struct{}{}
+		if counts, ok := t.scans.Lookup(imagefetch.Image{Digest: digest}); ok {
+			rpt.ContainerImage.Nodes[id] = n.WithLatests(counts.(Counts).latests())
+		}
+	}
+	// Forget images which are gone
+	t.scans.Retain(seen)
+	return rpt, nil
+}
+
+func (t *Tagger) scan(image imagefetch.Image) (interface{}, error) {
+	counts, err := t.scanner.Vulnerabilities(image.Digest)
+	if err != nil {
+		log.Warnf("Error scanning image %s: %v", image.Digest, err)
+	}
+	return counts, err
+}
@@ -0,0 +1,76 @@
+package imagescan_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/imagescan"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test"
+)
+
+type mockScanner struct {
+	sync.Mutex
+	scans map[string]int
+}
+
+func (m *mockScanner) Vulnerabilities(digest string) (imagescan.Counts, error) {
+	m.Lock()
+	defer m.Unlock()
+	m.scans[digest]++
+	if digest == "sha256:broken" {
+		return imagescan.Counts{}, fmt.Errorf("not indexed")
+	}
+	return imagescan.Counts{Critical: 1, High: 2}, nil
+}
+
+func (m *mockScanner) count(digest string) int {
+	m.Lock()
+	defer m.Unlock()
+	return m.scans[digest]
+}
+
+func TestTagger(t *testing.T) {
+	scanner := &mockScanner{scans: map[string]int{}}
+	tagger := imagescan.NewTagger(scanner, time.Hour)
+	defer tagger.Stop()
+
+	rpt := report.MakeReport()
+	rpt.ContainerImage.AddNode(report.MakeNodeWith("scanned", map[string]string{docker.ImageDigest: "sha256:abc"}))
+	rpt.ContainerImage.AddNode(report.MakeNodeWith("broken", map[string]string{docker.ImageDigest: "sha256:broken"}))
+	rpt.ContainerImage.AddNode(report.MakeNode("local"))
+
+	tag := func() report.Report {
+		tagged, err := tagger.Tag(context.Background(), rpt.Copy())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tagged
+	}
+	// The first report triggers the scans
+	if _, ok := tag().ContainerImage.Nodes["scanned"].Latest.Lookup(imagescan.VulnerabilitiesCritical); ok {
+		t.Errorf("expected no counts before the scan")
+	}
+	test.Poll(t, 300*time.Millisecond, "1 2", func() interface{} {
+		n := tag().ContainerImage.Nodes["scanned"]
+		critical, _ := n.Latest.Lookup(imagescan.VulnerabilitiesCritical)
+		high, _ := n.Latest.Lookup(imagescan.VulnerabilitiesHigh)
+		return critical + " " + high
+	})
+
+	tagged := tag()
+	if _, ok := tagged.ContainerImage.Nodes["broken"].Latest.Lookup(imagescan.VulnerabilitiesCritical); ok {
+		t.Errorf("expected no counts for a failed scan")
+	}
+	if _, ok := tagged.ContainerImage.Nodes["local"].Latest.Lookup(imagescan.VulnerabilitiesCritical); ok {
+		t.Errorf("expected no counts for an image without a digest")
+	}
+	// Scans are cached
+	if have := scanner.count("sha256:abc"); have != 1 {
+		t.Errorf("expected one scan, got %d", have)
+	}
+}
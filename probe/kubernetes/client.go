@@ -8,8 +8,8 @@ import (
 
 	"github.com/weaveworks/common/backoff"
 
-	log "github.com/sirupsen/logrus"
 	apiappsv1beta1 "k8s.io/api/apps/v1beta1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	apibatchv1 "k8s.io/api/batch/v1"
 	apibatchv1beta1 "k8s.io/api/batch/v1beta1"
 	apibatchv2alpha1 "k8s.io/api/batch/v2alpha1"
@@ -18,9 +18,13 @@ import (
 	storagev1 "k8s.io/api/storage/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
@@ -41,9 +45,14 @@ type Client interface {
 	WalkPersistentVolumes(f func(PersistentVolume) error) error
 	WalkPersistentVolumeClaims(f func(PersistentVolumeClaim) error) error
 	WalkStorageClasses(f func(StorageClass) error) error
+	WalkCustomResources(f func(CustomResource) error) error
+	WalkEvents(f func(EventResource) error) error
+	WalkHorizontalPodAutoscalers(f func(HorizontalPodAutoscaler) error) error
 
 	WatchPods(f func(Event, Pod))
 
+	GetClusterUID() (string, error)
+
 	GetLogs(namespaceID, podID string, containerNames []string) (io.ReadCloser, error)
 	DeletePod(namespaceID, podID string) error
 	ScaleUp(resource, namespaceID, id string) error
@@ -65,6 +74,11 @@ type client struct {
 	persistentVolumeStore      cache.Store
 	persistentVolumeClaimStore cache.Store
 	storageClassStore          cache.Store
+	eventStore                 cache.Store
+	hpaStore                   cache.Store
+
+	dynamicClient        dynamic.Interface
+	customResourceStores []customResourceStore
 
 	podWatchesMutex sync.Mutex
 	podWatches      []func(Event, Pod)
@@ -84,6 +98,15 @@ type ClientConfig struct {
 	Token                string
 	User                 string
 	Username             string
+
+	// CustomResources are the custom resources to report
+	CustomResources CustomResourceSpecs
+}
+
+// customResourceStore holds the custom resources of a spec
+type customResourceStore struct {
+	spec  CustomResourceSpec
+	store cache.Store
 }
 
 // NewClient returns a usable Client. Don't forget to Stop it.
@@ -152,6 +175,19 @@ func NewClient(config ClientConfig) (Client, error) {
 	result.persistentVolumeStore = result.setupStore("persistentvolumes")
 	result.persistentVolumeClaimStore = result.setupStore("persistentvolumeclaims")
 	result.storageClassStore = result.setupStore("storageclasses")
+	result.eventStore = result.setupStore("events")
+	result.hpaStore = result.setupStore("horizontalpodautoscalers")
+
+	if len(config.CustomResources) > 0 {
+		if result.dynamicClient, err = dynamic.NewForConfig(restConfig); err != nil {
+			return nil, err
+		}
+		for _, spec := range config.CustomResources {
+			store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+			result.runCustomResourceReflectorUntil(spec, store)
+			result.customResourceStores = append(result.customResourceStores, customResourceStore{spec: spec, store: store})
+		}
+	}
 
 	return result, nil
 }
@@ -194,6 +230,10 @@ func (c *client) clientAndType(resource string) (rest.Interface, interface{}, er
 		return c.client.CoreV1().RESTClient(), &apiv1.PersistentVolume{}, nil
 	case "persistentvolumeclaims":
 		return c.client.CoreV1().RESTClient(), &apiv1.PersistentVolumeClaim{}, nil
+	case "horizontalpodautoscalers":
+		return c.client.AutoscalingV1().RESTClient(), &autoscalingv1.HorizontalPodAutoscaler{}, nil
+	case "events":
+		return c.client.CoreV1().RESTClient(), &apiv1.Event{}, nil
 	case "storageclasses":
 		return c.client.StorageV1().RESTClient(), &storagev1.StorageClass{}, nil
 	case "deployments":
@@ -254,6 +294,47 @@ func (c *client) runReflectorUntil(resource string, store cache.Store) {
 	go bo.Start()
 }
 
+// runCustomResourceReflectorUntil is runReflectorUntil for custom
+// resources, which are listed and watched through the dynamic client.
+func (c *client) runCustomResourceReflectorUntil(spec CustomResourceSpec, store cache.Store) {
+	var r *cache.Reflector
+	listAndWatch := func() (bool, error) {
+		if r == nil {
+			ok, err := c.isResourceSupported(spec.GroupVersion(), spec.Resource)
+			if err != nil {
+				return false, err
+			}
+			if !ok {
+				log.Infof("%v are not supported by this Kubernetes cluster", spec.GroupResource())
+				return true, nil
+			}
+			resource := c.dynamicClient.Resource(spec.GroupVersionResource)
+			lw := &cache.ListWatch{
+				ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+					options.LabelSelector = spec.Selector
+					return resource.List(options)
+				},
+				WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+					options.LabelSelector = spec.Selector
+					return resource.Watch(options)
+				},
+			}
+			r = cache.NewReflector(lw, &unstructured.Unstructured{}, store, 0)
+		}
+
+		select {
+		case <-c.quit:
+			return true, nil
+		default:
+			err := r.ListAndWatch(c.quit)
+			return false, err
+		}
+	}
+	bo := backoff.New(listAndWatch, fmt.Sprintf("Kubernetes reflector (%s)", spec.GroupResource()))
+	bo.SetMaxBackoff(5 * time.Minute)
+	go bo.Start()
+}
+
 func (c *client) WatchPods(f func(Event, Pod)) {
 	c.podWatchesMutex.Lock()
 	defer c.podWatchesMutex.Unlock()
@@ -308,6 +389,41 @@ func (c *client) WalkStorageClasses(f func(StorageClass) error) error {
 	return nil
 }
 
+// WalkHorizontalPodAutoscalers calls f for each horizontal pod autoscaler
+func (c *client) WalkHorizontalPodAutoscalers(f func(HorizontalPodAutoscaler) error) error {
+	for _, m := range c.hpaStore.List() {
+		h := m.(*autoscalingv1.HorizontalPodAutoscaler)
+		if err := f(NewHorizontalPodAutoscaler(h)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WalkEvents calls f for each event
+func (c *client) WalkEvents(f func(EventResource) error) error {
+	for _, m := range c.eventStore.List() {
+		e := m.(*apiv1.Event)
+		if err := f(NewEvent(e)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WalkCustomResources calls f for each custom resource
+func (c *client) WalkCustomResources(f func(CustomResource) error) error {
+	for _, s := range c.customResourceStores {
+		for _, m := range s.store.List() {
+			u := m.(*unstructured.Unstructured)
+			if err := f(NewCustomResource(u, s.spec.Fields)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func (c *client) WalkServices(f func(Service) error) error {
 	for _, m := range c.serviceStore.List() {
 		s := m.(*apiv1.Service)
@@ -412,6 +528,17 @@ func (c *client) GetLogs(namespaceID, podID string, containerNames []string) (io
 	return NewLogReadCloser(readClosersWithLabel), nil
 }
 
+// GetClusterUID gets the UID of the kube-system namespace, which exists
+// in every cluster and lives as long as the cluster does, straight from
+// the API server, so it is known before the namespaces are listed.
+func (c *client) GetClusterUID() (string, error) {
+	ns, err := c.client.CoreV1().Namespaces().Get("kube-system", metav1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	return string(ns.UID), nil
+}
+
 func (c *client) DeletePod(namespaceID, podID string) error {
 	return c.client.CoreV1().Pods(namespaceID).Delete(podID, &metav1.DeleteOptions{})
 }
@@ -0,0 +1,72 @@
+package kubernetes
+
+import (
+	"context"
+	"sync"
+
+	"github.com/weaveworks/common/mtime"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// Cluster is the key of the cluster of a node
+const Cluster = report.KubernetesCluster
+
+// ClusterMetadataTemplates are the templates of the cluster of nodes
+var ClusterMetadataTemplates = report.MetadataTemplates{
+	Cluster: {ID: Cluster, Label: "Cluster", From: report.FromLatest, Priority: 15},
+}
+
+// ClusterTagger stamps the cluster of the probe on all its nodes, so
+// an app receiving reports from several clusters can tell them apart.
+// Without a configured cluster name, the cluster is identified by the
+// UID of its kube-system namespace, which exists in every cluster and
+// lives as long as the cluster does.
+type ClusterTagger struct {
+	client Client
+
+	mtx  sync.Mutex
+	name string
+}
+
+// NewClusterTagger makes a new ClusterTagger, for the named cluster or,
+// if name is empty, the cluster of client.
+func NewClusterTagger(name string, client Client) *ClusterTagger {
+	return &ClusterTagger{name: name, client: client}
+}
+
+// Name of this tagger, for metrics gathering
+func (*ClusterTagger) Name() string { return "K8sCluster" }
+
+// Tag implements Tagger.
+func (t *ClusterTagger) Tag(_ context.Context, rpt report.Report) (report.Report, error) {
+	name := t.clusterName()
+	if name == "" {
+		return rpt, nil
+	}
+	now := mtime.Now()
+	rpt.WalkTopologies(func(topology *report.Topology) {
+		for id, n := range topology.Nodes {
+			topology.Nodes[id] = n.WithLatest(Cluster, now, name)
+		}
+	})
+	rpt.Host = rpt.Host.WithMetadataTemplates(ClusterMetadataTemplates)
+	rpt.Pod = rpt.Pod.WithMetadataTemplates(ClusterMetadataTemplates)
+	return rpt, nil
+}
+
+func (t *ClusterTagger) clusterName() string {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.name != "" || t.client == nil {
+		return t.name
+	}
+	// Until the namespaces are listed, the cluster is unknown
+	t.client.WalkNamespaces(func(ns NamespaceResource) error {
+		if ns.Name() == "kube-system" {
+			t.name = ns.UID()
+		}
+		return nil
+	})
+	return t.name
+}
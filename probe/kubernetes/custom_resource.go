@@ -0,0 +1,159 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// CustomResourceFieldPrefix is the prefix of the keys of the selected
+// fields of custom resources
+const CustomResourceFieldPrefix = "kubernetes_custom_resource_field_"
+
+// CustomResourceSpec selects the custom resources to report: those of
+// a group, version and resource matching an optional label selector.
+// Fields are the dotted paths of the spec and status fields to report,
+// e.g. status.phase.
+type CustomResourceSpec struct {
+	schema.GroupVersionResource
+	Selector string
+	Fields   []string
+}
+
+// ParseCustomResourceSpec parses a custom resource spec of the form
+//
+//	group/version/resource[?labels=<selector>][&fields=<path>,<path>...]
+//
+// e.g. monitoring.coreos.com/v1/prometheuses?fields=spec.replicas,status.availableReplicas
+func ParseCustomResourceSpec(s string) (CustomResourceSpec, error) {
+	path, query := s, ""
+	if i := strings.IndexByte(s, '?'); i >= 0 {
+		path, query = s[:i], s[i+1:]
+	}
+	parts := strings.Split(path, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return CustomResourceSpec{}, fmt.Errorf("invalid custom resource %q: expected group/version/resource", s)
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return CustomResourceSpec{}, fmt.Errorf("invalid custom resource %q: %v", s, err)
+	}
+	spec := CustomResourceSpec{
+		GroupVersionResource: schema.GroupVersionResource{Group: parts[0], Version: parts[1], Resource: parts[2]},
+		Selector:             values.Get("labels"),
+	}
+	if _, err := labels.Parse(spec.Selector); err != nil {
+		return CustomResourceSpec{}, fmt.Errorf("invalid custom resource %q: %v", s, err)
+	}
+	for _, field := range strings.Split(values.Get("fields"), ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			spec.Fields = append(spec.Fields, field)
+		}
+	}
+	return spec, nil
+}
+
+func (s CustomResourceSpec) String() string {
+	var query []string
+	if s.Selector != "" {
+		query = append(query, "labels="+url.QueryEscape(s.Selector))
+	}
+	if len(s.Fields) > 0 {
+		query = append(query, "fields="+strings.Join(s.Fields, ","))
+	}
+	result := s.Group + "/" + s.Version + "/" + s.Resource
+	if len(query) > 0 {
+		result += "?" + strings.Join(query, "&")
+	}
+	return result
+}
+
+// CustomResourceSpecs is a flag.Value collecting the custom resources
+// to report, one per use of the flag.
+type CustomResourceSpecs []CustomResourceSpec
+
+func (s *CustomResourceSpecs) String() string {
+	specs := make([]string, 0, len(*s))
+	for _, spec := range *s {
+		specs = append(specs, spec.String())
+	}
+	return strings.Join(specs, " ")
+}
+
+// Set implements flag.Value
+func (s *CustomResourceSpecs) Set(value string) error {
+	spec, err := ParseCustomResourceSpec(value)
+	if err != nil {
+		return err
+	}
+	*s = append(*s, spec)
+	return nil
+}
+
+// CustomResource represents a Kubernetes custom resource
+type CustomResource interface {
+	Meta
+	GetNode() report.Node
+}
+
+type customResource struct {
+	*unstructured.Unstructured
+	Meta
+	fields []string
+}
+
+// NewCustomResource creates a new CustomResource, reporting the given
+// fields.
+func NewCustomResource(u *unstructured.Unstructured, fields []string) CustomResource {
+	return &customResource{
+		Unstructured: u,
+		Meta: meta{metav1.ObjectMeta{
+			UID:               u.GetUID(),
+			Name:              u.GetName(),
+			Namespace:         u.GetNamespace(),
+			CreationTimestamp: u.GetCreationTimestamp(),
+			Labels:            u.GetLabels(),
+		}},
+		fields: fields,
+	}
+}
+
+func (c *customResource) GetNode() report.Node {
+	fields := map[string]string{}
+	for _, field := range c.fields {
+		value, ok, err := unstructured.NestedFieldNoCopy(c.Object, strings.Split(field, ".")...)
+		if err != nil || !ok || value == nil {
+			continue
+		}
+		fields[field] = formatField(value)
+	}
+	return c.MetaNode(report.MakeCustomResourceNodeID(c.UID())).WithLatests(map[string]string{
+		NodeType:   c.GetKind(),
+		APIVersion: c.GetAPIVersion(),
+	}).AddPrefixPropertyList(CustomResourceFieldPrefix, fields)
+}
+
+// formatField renders the value of a field, with objects and lists as
+// JSON.
+func formatField(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		buf, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(buf)
+	default:
+		return fmt.Sprint(v)
+	}
+}
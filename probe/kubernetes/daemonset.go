@@ -44,11 +44,27 @@ func (d *daemonSet) Selector() (labels.Selector, error) {
 }
 
 func (d *daemonSet) GetNode(probeID string) report.Node {
-	return d.MetaNode(report.MakeDaemonSetNodeID(d.UID())).WithLatests(map[string]string{
+	latests := map[string]string{
 		DesiredReplicas:       fmt.Sprint(d.Status.DesiredNumberScheduled),
 		Replicas:              fmt.Sprint(d.Status.CurrentNumberScheduled),
 		MisscheduledReplicas:  fmt.Sprint(d.Status.NumberMisscheduled),
 		NodeType:              "DaemonSet",
 		report.ControlProbeID: probeID,
-	})
+		RolloutStatus:         d.rolloutStatus(),
+	}
+	for k, v := range podResources(d.Spec.Template.Spec) {
+		latests[k] = v
+	}
+	return d.MetaNode(report.MakeDaemonSetNodeID(d.UID())).WithLatests(latests)
+}
+
+// rolloutStatus follows `kubectl rollout status`: a rollout is complete
+// once the pods of all nodes are updated and available.
+func (d *daemonSet) rolloutStatus() string {
+	if d.Status.ObservedGeneration < d.Generation ||
+		d.Status.UpdatedNumberScheduled < d.Status.DesiredNumberScheduled ||
+		d.Status.NumberAvailable < d.Status.DesiredNumberScheduled {
+		return RolloutProgressing
+	}
+	return RolloutComplete
 }
@@ -51,7 +51,7 @@ func (d *deployment) GetNode(probeID string) report.Node {
 	if d.Spec.Replicas != nil {
 		desiredReplicas = int(*d.Spec.Replicas)
 	}
-	return d.MetaNode(report.MakeDeploymentNodeID(d.UID())).WithLatests(map[string]string{
+	latests := map[string]string{
 		ObservedGeneration:    fmt.Sprint(d.Status.ObservedGeneration),
 		DesiredReplicas:       fmt.Sprint(desiredReplicas),
 		Replicas:              fmt.Sprint(d.Status.Replicas),
@@ -61,5 +61,29 @@ func (d *deployment) GetNode(probeID string) report.Node {
 		Strategy:              string(d.Spec.Strategy.Type),
 		report.ControlProbeID: probeID,
 		NodeType:              "Deployment",
-	}).WithLatestActiveControls(ScaleUp, ScaleDown)
+		RolloutStatus:         d.rolloutStatus(desiredReplicas),
+	}
+	for k, v := range podResources(d.Spec.Template.Spec) {
+		latests[k] = v
+	}
+	return d.MetaNode(report.MakeDeploymentNodeID(d.UID())).WithLatests(latests).
+		WithLatestActiveControls(ScaleUp, ScaleDown)
+}
+
+// rolloutStatus follows `kubectl rollout status`: a rollout is complete
+// once the controller has seen the latest spec and all replicas are
+// updated and available.
+func (d *deployment) rolloutStatus(desiredReplicas int) string {
+	for _, c := range d.Status.Conditions {
+		if c.Type == apiv1beta1.DeploymentProgressing && c.Reason == "ProgressDeadlineExceeded" {
+			return RolloutFailed
+		}
+	}
+	if d.Status.ObservedGeneration < d.Generation ||
+		int(d.Status.UpdatedReplicas) < desiredReplicas ||
+		d.Status.Replicas > d.Status.UpdatedReplicas ||
+		d.Status.AvailableReplicas < d.Status.UpdatedReplicas {
+		return RolloutProgressing
+	}
+	return RolloutComplete
 }
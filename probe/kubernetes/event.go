@@ -0,0 +1,113 @@
+package kubernetes
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// MaxEventsPerNode is the number of most recent events reported for a
+// node
+const MaxEventsPerNode = 10
+
+// These constants are keys used in the events table
+const (
+	EventsTablePrefix = "kubernetes_events_"
+	EventLastSeen     = "kubernetes_event_last_seen"
+	EventType         = "kubernetes_event_type"
+	EventReason       = "kubernetes_event_reason"
+	EventMessage      = "kubernetes_event_message"
+	EventCount        = "kubernetes_event_count"
+)
+
+// EventTableTemplates are the templates of the table of recent events
+// of pods and their controllers.
+var EventTableTemplates = report.TableTemplates{
+	EventsTablePrefix: {
+		ID:     EventsTablePrefix,
+		Label:  "Recent events",
+		Type:   report.MulticolumnTableType,
+		Prefix: EventsTablePrefix,
+		Columns: []report.Column{
+			{ID: EventLastSeen, Label: "Last seen", DataType: report.DateTime},
+			{ID: EventType, Label: "Type"},
+			{ID: EventReason, Label: "Reason"},
+			{ID: EventMessage, Label: "Message"},
+			{ID: EventCount, Label: "Count", DataType: report.Number},
+		},
+	},
+}
+
+// EventResource represents a Kubernetes event
+// `Event` is already taken in store.go
+type EventResource interface {
+	InvolvedObject() (kind, uid string)
+	LastSeen() time.Time
+	Row() map[string]string
+}
+
+type event struct {
+	*apiv1.Event
+}
+
+// NewEvent creates a new EventResource
+func NewEvent(e *apiv1.Event) EventResource {
+	return &event{Event: e}
+}
+
+func (e *event) InvolvedObject() (kind, uid string) {
+	return e.Event.InvolvedObject.Kind, string(e.Event.InvolvedObject.UID)
+}
+
+// LastSeen is when the event last occurred. Events of the newer API
+// only have an event time.
+func (e *event) LastSeen() time.Time {
+	switch {
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	}
+	return e.FirstTimestamp.Time
+}
+
+// Row returns the entries of the event in the events table
+func (e *event) Row() map[string]string {
+	count := e.Count
+	if count == 0 {
+		count = 1
+	}
+	return map[string]string{
+		EventLastSeen: e.LastSeen().Format(time.RFC3339Nano),
+		EventType:     e.Type,
+		EventReason:   e.Reason,
+		EventMessage:  e.Message,
+		EventCount:    strconv.Itoa(int(count)),
+	}
+}
+
+type byLastSeen []EventResource
+
+func (e byLastSeen) Len() int           { return len(e) }
+func (e byLastSeen) Swap(i, j int)      { e[i], e[j] = e[j], e[i] }
+func (e byLastSeen) Less(i, j int) bool { return e[i].LastSeen().After(e[j].LastSeen()) }
+
+// eventRows returns the table rows of the most recent events, newest
+// first.
+func eventRows(events []EventResource) []report.Row {
+	sort.Sort(byLastSeen(events))
+	if len(events) > MaxEventsPerNode {
+		events = events[:MaxEventsPerNode]
+	}
+	rows := make([]report.Row, 0, len(events))
+	for i, e := range events {
+		// Rows are sorted by ID
+		rows = append(rows, report.Row{ID: fmt.Sprintf("%02d", i), Entries: e.Row()})
+	}
+	return rows
+}
@@ -0,0 +1,67 @@
+package kubernetes
+
+import (
+	"fmt"
+
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// These constants are keys used in node metadata
+const (
+	HPA                  = report.KubernetesHPA
+	HPAMinReplicas       = report.KubernetesHPAMinReplicas
+	HPAMaxReplicas       = report.KubernetesHPAMaxReplicas
+	HPACurrentReplicas   = report.KubernetesHPACurrentReplicas
+	HPADesiredReplicas   = report.KubernetesHPADesiredReplicas
+	HPATargetCPUPercent  = report.KubernetesHPATargetCPUPercent
+	HPACurrentCPUPercent = report.KubernetesHPACurrentCPUPercent
+)
+
+// HorizontalPodAutoscaler represents a Kubernetes horizontal pod
+// autoscaler. Autoscalers aren't nodes of their own: what they know is
+// added to the workloads they scale.
+type HorizontalPodAutoscaler interface {
+	Meta
+	ScaleTarget() (kind, name string)
+	Latests() map[string]string
+}
+
+type horizontalPodAutoscaler struct {
+	*autoscalingv1.HorizontalPodAutoscaler
+	Meta
+}
+
+// NewHorizontalPodAutoscaler creates a new HorizontalPodAutoscaler
+func NewHorizontalPodAutoscaler(h *autoscalingv1.HorizontalPodAutoscaler) HorizontalPodAutoscaler {
+	return &horizontalPodAutoscaler{HorizontalPodAutoscaler: h, Meta: meta{h.ObjectMeta}}
+}
+
+func (h *horizontalPodAutoscaler) ScaleTarget() (kind, name string) {
+	return h.Spec.ScaleTargetRef.Kind, h.Spec.ScaleTargetRef.Name
+}
+
+// Latests returns the node metadata of the workload scaled by the
+// autoscaler
+func (h *horizontalPodAutoscaler) Latests() map[string]string {
+	// Spec.MinReplicas can be omitted, and the pointer will be nil. It defaults to 1.
+	minReplicas := 1
+	if h.Spec.MinReplicas != nil {
+		minReplicas = int(*h.Spec.MinReplicas)
+	}
+	latests := map[string]string{
+		HPA:                h.Name(),
+		HPAMinReplicas:     fmt.Sprint(minReplicas),
+		HPAMaxReplicas:     fmt.Sprint(h.Spec.MaxReplicas),
+		HPACurrentReplicas: fmt.Sprint(h.Status.CurrentReplicas),
+		HPADesiredReplicas: fmt.Sprint(h.Status.DesiredReplicas),
+	}
+	if h.Spec.TargetCPUUtilizationPercentage != nil {
+		latests[HPATargetCPUPercent] = fmt.Sprint(*h.Spec.TargetCPUUtilizationPercentage)
+	}
+	if h.Status.CurrentCPUUtilizationPercentage != nil {
+		latests[HPACurrentCPUPercent] = fmt.Sprint(*h.Status.CurrentCPUUtilizationPercentage)
+	}
+	return latests
+}
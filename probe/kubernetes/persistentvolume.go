@@ -76,6 +76,9 @@ func (p *persistentVolume) GetNode() report.Node {
 	if p.GetStorageDriver() != "" {
 		latests[StorageDriver] = p.GetStorageDriver()
 	}
+	if q, ok := p.Spec.Capacity[apiv1.ResourceStorage]; ok {
+		latests[Capacity] = q.String()
+	}
 
 	return p.MetaNode(report.MakePersistentVolumeNodeID(p.UID())).WithLatests(latests)
 }
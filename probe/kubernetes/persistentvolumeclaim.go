@@ -49,12 +49,20 @@ func (p *persistentVolumeClaim) GetStorageClass() string {
 
 // GetNode returns Persistent Volume Claim as Node
 func (p *persistentVolumeClaim) GetNode() report.Node {
-	return p.MetaNode(report.MakePersistentVolumeClaimNodeID(p.UID())).WithLatests(map[string]string{
+	latests := map[string]string{
 		NodeType:         "Persistent Volume Claim",
 		Status:           string(p.Status.Phase),
 		VolumeName:       p.Spec.VolumeName,
 		StorageClassName: p.GetStorageClass(),
-	})
+	}
+	if q, ok := p.Spec.Resources.Requests[apiv1.ResourceStorage]; ok {
+		latests[RequestedCapacity] = q.String()
+	}
+	// Only known once bound
+	if q, ok := p.Status.Capacity[apiv1.ResourceStorage]; ok {
+		latests[Capacity] = q.String()
+	}
+	return p.MetaNode(report.MakePersistentVolumeClaimNodeID(p.UID())).WithLatests(latests)
 }
 
 // Selector returns all Persistent Volume Claim selector
@@ -102,7 +102,15 @@ func (p *pod) GetNode(probeID string) report.Node {
 		latests[IsInHostNetwork] = "true"
 	}
 
-	return p.MetaNode(report.MakePodNodeID(p.UID())).WithLatests(latests).
+	for k, v := range podResources(p.Pod.Spec) {
+		latests[k] = v
+	}
+
+	node := p.MetaNode(report.MakePodNodeID(p.UID())).WithIdentities(report.IdentityPodUID, p.UID())
+	if !p.Pod.Spec.HostNetwork {
+		node = node.WithIdentities(report.IdentityIP, p.Status.PodIP)
+	}
+	return node.WithLatests(latests).
 		WithParents(p.parents).
 		WithLatestActiveControls(GetLogs, DeletePod)
 }
@@ -1,14 +1,15 @@
 package kubernetes
 
 import (
+	"context"
 	"fmt"
 	"net"
 	"strings"
 
 	"k8s.io/apimachinery/pkg/labels"
 
-	log "github.com/sirupsen/logrus"
 	"github.com/weaveworks/common/mtime"
+	"github.com/weaveworks/scope/common/xlog"
 	"github.com/weaveworks/scope/probe"
 	"github.com/weaveworks/scope/probe/controls"
 	"github.com/weaveworks/scope/probe/docker"
@@ -16,6 +17,9 @@ import (
 	"github.com/weaveworks/scope/report"
 )
 
+// log is the logger of the kubernetes component
+var log = xlog.Component("kubernetes")
+
 // These constants are keys used in node metadata
 const (
 	IP                 = report.KubernetesIP
@@ -34,10 +38,34 @@ const (
 	VolumeName         = report.KubernetesVolumeName
 	Provisioner        = report.KubernetesProvisioner
 	StorageDriver      = report.KubernetesStorageDriver
+	Capacity           = report.KubernetesCapacity
+	RequestedCapacity  = report.KubernetesRequestedCapacity
+	APIVersion         = report.KubernetesAPIVersion
 )
 
 // Exposed for testing
 var (
+	ResourceMetadataTemplates = report.MetadataTemplates{
+		CPURequest:    {ID: CPURequest, Label: "CPU requests", From: report.FromLatest, Priority: 20},
+		CPULimit:      {ID: CPULimit, Label: "CPU limits", From: report.FromLatest, Priority: 21},
+		MemoryRequest: {ID: MemoryRequest, Label: "Memory requests", From: report.FromLatest, Priority: 22},
+		MemoryLimit:   {ID: MemoryLimit, Label: "Memory limits", From: report.FromLatest, Priority: 23},
+	}
+
+	RolloutMetadataTemplates = report.MetadataTemplates{
+		RolloutStatus: {ID: RolloutStatus, Label: "Rollout", From: report.FromLatest, Priority: 19},
+	}
+
+	HPAMetadataTemplates = report.MetadataTemplates{
+		HPA:                  {ID: HPA, Label: "Autoscaler", From: report.FromLatest, Priority: 24},
+		HPAMinReplicas:       {ID: HPAMinReplicas, Label: "Min replicas", From: report.FromLatest, Datatype: report.Number, Priority: 25},
+		HPAMaxReplicas:       {ID: HPAMaxReplicas, Label: "Max replicas", From: report.FromLatest, Datatype: report.Number, Priority: 26},
+		HPACurrentReplicas:   {ID: HPACurrentReplicas, Label: "Current replicas", From: report.FromLatest, Datatype: report.Number, Priority: 27},
+		HPADesiredReplicas:   {ID: HPADesiredReplicas, Label: "Autoscaled replicas", From: report.FromLatest, Datatype: report.Number, Priority: 28},
+		HPATargetCPUPercent:  {ID: HPATargetCPUPercent, Label: "Target CPU %", From: report.FromLatest, Datatype: report.Number, Priority: 29},
+		HPACurrentCPUPercent: {ID: HPACurrentCPUPercent, Label: "Current CPU %", From: report.FromLatest, Datatype: report.Number, Priority: 30},
+	}
+
 	PodMetadataTemplates = report.MetadataTemplates{
 		State:            {ID: State, Label: "State", From: report.FromLatest, Priority: 2},
 		IP:               {ID: IP, Label: "IP", From: report.FromLatest, Datatype: report.IP, Priority: 3},
@@ -45,7 +73,7 @@ var (
 		Namespace:        {ID: Namespace, Label: "Namespace", From: report.FromLatest, Priority: 5},
 		Created:          {ID: Created, Label: "Created", From: report.FromLatest, Datatype: report.DateTime, Priority: 6},
 		RestartCount:     {ID: RestartCount, Label: "Restart #", From: report.FromLatest, Priority: 7},
-	}
+	}.Merge(ResourceMetadataTemplates)
 
 	PodMetricTemplates = docker.ContainerMetricTemplates
 
@@ -69,7 +97,7 @@ var (
 		DesiredReplicas:    {ID: DesiredReplicas, Label: "Desired replicas", From: report.FromLatest, Datatype: report.Number, Priority: 5},
 		report.Pod:         {ID: report.Pod, Label: "# Pods", From: report.FromCounters, Datatype: report.Number, Priority: 6},
 		Strategy:           {ID: Strategy, Label: "Strategy", From: report.FromLatest, Priority: 7},
-	}
+	}.Merge(RolloutMetadataTemplates).Merge(ResourceMetadataTemplates).Merge(HPAMetadataTemplates)
 
 	DeploymentMetricTemplates = PodMetricTemplates
 
@@ -79,7 +107,7 @@ var (
 		Created:         {ID: Created, Label: "Created", From: report.FromLatest, Datatype: report.DateTime, Priority: 3},
 		DesiredReplicas: {ID: DesiredReplicas, Label: "Desired replicas", From: report.FromLatest, Datatype: report.Number, Priority: 4},
 		report.Pod:      {ID: report.Pod, Label: "# Pods", From: report.FromCounters, Datatype: report.Number, Priority: 5},
-	}
+	}.Merge(RolloutMetadataTemplates).Merge(ResourceMetadataTemplates)
 
 	DaemonSetMetricTemplates = PodMetricTemplates
 
@@ -90,7 +118,7 @@ var (
 		ObservedGeneration: {ID: ObservedGeneration, Label: "Observed gen.", From: report.FromLatest, Datatype: report.Number, Priority: 4},
 		DesiredReplicas:    {ID: DesiredReplicas, Label: "Desired replicas", From: report.FromLatest, Datatype: report.Number, Priority: 5},
 		report.Pod:         {ID: report.Pod, Label: "# Pods", From: report.FromCounters, Datatype: report.Number, Priority: 6},
-	}
+	}.Merge(RolloutMetadataTemplates).Merge(ResourceMetadataTemplates).Merge(HPAMetadataTemplates)
 
 	StatefulSetMetricTemplates = PodMetricTemplates
 
@@ -114,14 +142,17 @@ var (
 		AccessModes:      {ID: AccessModes, Label: "Access modes", From: report.FromLatest, Priority: 5},
 		Status:           {ID: Status, Label: "Status", From: report.FromLatest, Priority: 6},
 		StorageDriver:    {ID: StorageDriver, Label: "Storage driver", From: report.FromLatest, Priority: 7},
+		Capacity:         {ID: Capacity, Label: "Capacity", From: report.FromLatest, Priority: 8},
 	}
 
 	PersistentVolumeClaimMetadataTemplates = report.MetadataTemplates{
-		NodeType:         {ID: NodeType, Label: "Type", From: report.FromLatest, Priority: 1},
-		Namespace:        {ID: Namespace, Label: "Namespace", From: report.FromLatest, Priority: 2},
-		Status:           {ID: Status, Label: "Status", From: report.FromLatest, Priority: 3},
-		VolumeName:       {ID: VolumeName, Label: "Volume", From: report.FromLatest, Priority: 4},
-		StorageClassName: {ID: StorageClassName, Label: "Storage class", From: report.FromLatest, Priority: 5},
+		NodeType:          {ID: NodeType, Label: "Type", From: report.FromLatest, Priority: 1},
+		Namespace:         {ID: Namespace, Label: "Namespace", From: report.FromLatest, Priority: 2},
+		Status:            {ID: Status, Label: "Status", From: report.FromLatest, Priority: 3},
+		VolumeName:        {ID: VolumeName, Label: "Volume", From: report.FromLatest, Priority: 4},
+		StorageClassName:  {ID: StorageClassName, Label: "Storage class", From: report.FromLatest, Priority: 5},
+		RequestedCapacity: {ID: RequestedCapacity, Label: "Requested capacity", From: report.FromLatest, Priority: 6},
+		Capacity:          {ID: Capacity, Label: "Capacity", From: report.FromLatest, Priority: 7},
 	}
 
 	StorageClassMetadataTemplates = report.MetadataTemplates{
@@ -130,6 +161,13 @@ var (
 		Provisioner: {ID: Provisioner, Label: "Provisioner", From: report.FromLatest, Priority: 3},
 	}
 
+	CustomResourceMetadataTemplates = report.MetadataTemplates{
+		NodeType:   {ID: NodeType, Label: "Kind", From: report.FromLatest, Priority: 1},
+		Namespace:  {ID: Namespace, Label: "Namespace", From: report.FromLatest, Priority: 2},
+		Created:    {ID: Created, Label: "Created", From: report.FromLatest, Datatype: report.DateTime, Priority: 3},
+		APIVersion: {ID: APIVersion, Label: "API version", From: report.FromLatest, Priority: 4},
+	}
+
 	TableTemplates = report.TableTemplates{
 		LabelPrefix: {
 			ID:     LabelPrefix,
@@ -139,6 +177,15 @@ var (
 		},
 	}
 
+	CustomResourceTableTemplates = TableTemplates.Merge(report.TableTemplates{
+		CustomResourceFieldPrefix: {
+			ID:     CustomResourceFieldPrefix,
+			Label:  "Fields",
+			Type:   report.PropertyListType,
+			Prefix: CustomResourceFieldPrefix,
+		},
+	})
+
 	ScalingControls = []report.Control{
 		{
 			ID:    ScaleDown,
@@ -192,6 +239,14 @@ func (r *Reporter) Stop() {
 // Name of this reporter, for metrics gathering
 func (Reporter) Name() string { return "K8s" }
 
+// Topologies of the reporter, for annotating them when it fails.
+func (Reporter) Topologies() []string {
+	return []string{
+		report.Pod, report.Service, report.DaemonSet, report.StatefulSet, report.CronJob, report.Deployment,
+		report.Namespace, report.PersistentVolume, report.PersistentVolumeClaim, report.StorageClass, report.CustomResource,
+	}
+}
+
 func (r *Reporter) podEvent(e Event, pod Pod) {
 	switch e {
 	case ADD:
@@ -239,7 +294,7 @@ func isPauseContainer(n report.Node, rpt report.Report) bool {
 }
 
 // Tag adds pod parents to container nodes.
-func (r *Reporter) Tag(rpt report.Report) (report.Report, error) {
+func (r *Reporter) Tag(_ context.Context, rpt report.Report) (report.Report, error) {
 	for id, n := range rpt.Container.Nodes {
 		uid, ok := n.Latest.Lookup(docker.LabelPrefix + "io.kubernetes.pod.uid")
 		if !ok {
@@ -257,7 +312,13 @@ func (r *Reporter) Tag(rpt report.Report) (report.Report, error) {
 }
 
 // Report generates a Report containing Container and ContainerImage topologies
-func (r *Reporter) Report() (report.Report, error) {
+// partial annotates a topology of the report with why it's partial.
+func (r *Reporter) partial(rpt *report.Report, topology string, err error) {
+	log.Warnf("failed to report %s: %v", topology, err)
+	rpt.AddError(topology, fmt.Sprintf("%s: %v", r.Name(), err))
+}
+
+func (r *Reporter) Report(ctx context.Context) (report.Report, error) {
 	result := report.MakeReport()
 	serviceTopology, services, err := r.serviceTopology()
 	if err != nil {
@@ -280,6 +341,11 @@ func (r *Reporter) Report() (report.Report, error) {
 	if err != nil {
 		return result, err
 	}
+	// Give up between walks of the stores, which can be big, if the probe
+	// is stopping
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
 	podTopology, err := r.podTopology(services, deployments, daemonSets, statefulSets, cronJobs)
 	if err != nil {
 		return result, err
@@ -288,15 +354,42 @@ func (r *Reporter) Report() (report.Report, error) {
 	if err != nil {
 		return result, err
 	}
+	// Storage and custom resources are reported apart from the workloads,
+	// so failing to report them only leaves their topologies partial
 	persistentVolumeTopology, _, err := r.persistentVolumeTopology()
 	if err != nil {
-		return result, err
+		r.partial(&result, report.PersistentVolume, err)
 	}
 	persistentVolumeClaimTopology, _, err := r.persistentVolumeClaimTopology()
 	if err != nil {
-		return result, err
+		r.partial(&result, report.PersistentVolumeClaim, err)
 	}
 	storageClassTopology, _, err := r.storageClassTopology()
+	if err != nil {
+		r.partial(&result, report.StorageClass, err)
+	}
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+	customResourceTopology, err := r.customResourceTopology()
+	if err != nil {
+		r.partial(&result, report.CustomResource, err)
+	}
+	err = r.attachHorizontalPodAutoscalers(map[string]*report.Topology{
+		"Deployment":  &deploymentTopology,
+		"StatefulSet": &statefulSetTopology,
+	})
+	if err != nil {
+		return result, err
+	}
+	err = r.attachEvents(map[string]eventTopology{
+		"Pod":         {&podTopology, report.MakePodNodeID},
+		"Service":     {&serviceTopology, report.MakeServiceNodeID},
+		"Deployment":  {&deploymentTopology, report.MakeDeploymentNodeID},
+		"DaemonSet":   {&daemonSetTopology, report.MakeDaemonSetNodeID},
+		"StatefulSet": {&statefulSetTopology, report.MakeStatefulSetNodeID},
+		"CronJob":     {&cronJobTopology, report.MakeCronJobNodeID},
+	})
 	if err != nil {
 		return result, err
 	}
@@ -311,6 +404,7 @@ func (r *Reporter) Report() (report.Report, error) {
 	result.PersistentVolume = result.PersistentVolume.Merge(persistentVolumeTopology)
 	result.PersistentVolumeClaim = result.PersistentVolumeClaim.Merge(persistentVolumeClaimTopology)
 	result.StorageClass = result.StorageClass.Merge(storageClassTopology)
+	result.CustomResource = result.CustomResource.Merge(customResourceTopology)
 	return result, nil
 }
 
@@ -459,6 +553,90 @@ func (r *Reporter) storageClassTopology() (report.Topology, []StorageClass, erro
 	return result, storageClasses, err
 }
 
+// attachHorizontalPodAutoscalers adds what the autoscalers know to the
+// nodes of the workloads they scale, in the topologies keyed by the
+// kind of workload.
+func (r *Reporter) attachHorizontalPodAutoscalers(topologies map[string]*report.Topology) error {
+	// Autoscalers target workloads by namespace and name
+	ids := map[string]map[string]string{}
+	for kind, t := range topologies {
+		ids[kind] = map[string]string{}
+		for id, n := range t.Nodes {
+			namespace, _ := n.Latest.Lookup(Namespace)
+			name, _ := n.Latest.Lookup(Name)
+			ids[kind][namespace+"/"+name] = id
+		}
+	}
+	return r.client.WalkHorizontalPodAutoscalers(func(h HorizontalPodAutoscaler) error {
+		kind, name := h.ScaleTarget()
+		id, ok := ids[kind][h.Namespace()+"/"+name]
+		if !ok {
+			return nil
+		}
+		t := topologies[kind]
+		t.Nodes[id] = t.Nodes[id].WithLatests(h.Latests())
+		return nil
+	})
+}
+
+// eventTopology is where the events of a kind of object go
+type eventTopology struct {
+	topology *report.Topology
+	makeID   func(uid string) string
+}
+
+// attachEvents adds the recent events of the reported objects to their
+// nodes, in the topologies keyed by the kind of object.
+func (r *Reporter) attachEvents(topologies map[string]eventTopology) error {
+	events := map[string][]EventResource{}
+	err := r.client.WalkEvents(func(e EventResource) error {
+		kind, uid := e.InvolvedObject()
+		t, ok := topologies[kind]
+		if !ok {
+			return nil
+		}
+		id := t.makeID(uid)
+		if _, ok := t.topology.Nodes[id]; ok {
+			events[id] = append(events[id], e)
+		}
+		return nil
+	})
+	for _, t := range topologies {
+		*t.topology = t.topology.WithTableTemplates(EventTableTemplates)
+		for id, n := range t.topology.Nodes {
+			if es, ok := events[id]; ok {
+				t.topology.Nodes[id] = n.AddPrefixMulticolumnTable(EventsTablePrefix, eventRows(es))
+			}
+		}
+	}
+	return err
+}
+
+// customResourceTopology reports the custom resources as children of
+// their namespaces.
+func (r *Reporter) customResourceTopology() (report.Topology, error) {
+	result := report.MakeTopology().
+		WithMetadataTemplates(CustomResourceMetadataTemplates).
+		WithTableTemplates(CustomResourceTableTemplates)
+	namespaces := map[string]string{}
+	err := r.client.WalkNamespaces(func(ns NamespaceResource) error {
+		namespaces[ns.Name()] = ns.UID()
+		return nil
+	})
+	if err != nil {
+		return result, err
+	}
+	err = r.client.WalkCustomResources(func(c CustomResource) error {
+		n := c.GetNode()
+		if uid, ok := namespaces[c.Namespace()]; ok {
+			n = n.WithParent(report.Namespace, report.MakeNamespaceNodeID(uid))
+		}
+		result.AddNode(n)
+		return nil
+	})
+	return result, err
+}
+
 type labelledChild interface {
 	Labels() map[string]string
 	AddParent(string, string)
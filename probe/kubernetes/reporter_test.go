@@ -1,15 +1,21 @@
 package kubernetes_test
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"strings"
 	"testing"
+	"time"
 
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
 	apiv1 "k8s.io/api/core/v1"
 	apiv1beta1 "k8s.io/api/extensions/v1beta1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/apimachinery/pkg/types"
 
 	"github.com/weaveworks/scope/common/xfer"
@@ -112,10 +118,14 @@ func newMockClient() *mockClient {
 }
 
 type mockClient struct {
-	pods        []kubernetes.Pod
-	services    []kubernetes.Service
-	deployments []kubernetes.Deployment
-	logs        map[string]io.ReadCloser
+	pods            []kubernetes.Pod
+	services        []kubernetes.Service
+	deployments     []kubernetes.Deployment
+	namespaces      []kubernetes.NamespaceResource
+	customResources []kubernetes.CustomResource
+	events          []kubernetes.EventResource
+	hpas            []kubernetes.HorizontalPodAutoscaler
+	logs            map[string]io.ReadCloser
 }
 
 func (c *mockClient) Stop() {}
@@ -153,6 +163,11 @@ func (c *mockClient) WalkDeployments(f func(kubernetes.Deployment) error) error
 	return nil
 }
 func (c *mockClient) WalkNamespaces(f func(kubernetes.NamespaceResource) error) error {
+	for _, namespace := range c.namespaces {
+		if err := f(namespace); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 func (c *mockClient) WalkPersistentVolumes(f func(kubernetes.PersistentVolume) error) error {
@@ -164,6 +179,30 @@ func (c *mockClient) WalkPersistentVolumeClaims(f func(kubernetes.PersistentVolu
 func (c *mockClient) WalkStorageClasses(f func(kubernetes.StorageClass) error) error {
 	return nil
 }
+func (c *mockClient) WalkCustomResources(f func(kubernetes.CustomResource) error) error {
+	for _, customResource := range c.customResources {
+		if err := f(customResource); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (c *mockClient) WalkEvents(f func(kubernetes.EventResource) error) error {
+	for _, event := range c.events {
+		if err := f(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+func (c *mockClient) WalkHorizontalPodAutoscalers(f func(kubernetes.HorizontalPodAutoscaler) error) error {
+	for _, hpa := range c.hpas {
+		if err := f(hpa); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 func (*mockClient) WatchPods(func(kubernetes.Event, kubernetes.Pod)) {}
 func (c *mockClient) GetLogs(namespaceID, podName string, _ []string) (io.ReadCloser, error) {
 	r, ok := c.logs[namespaceID+";"+podName]
@@ -172,6 +211,14 @@ func (c *mockClient) GetLogs(namespaceID, podName string, _ []string) (io.ReadCl
 	}
 	return r, nil
 }
+func (c *mockClient) GetClusterUID() (string, error) {
+	for _, ns := range c.namespaces {
+		if ns.Name() == "kube-system" {
+			return ns.UID(), nil
+		}
+	}
+	return "", fmt.Errorf("namespace kube-system not found")
+}
 func (c *mockClient) DeletePod(namespaceID, podID string) error {
 	return nil
 }
@@ -210,7 +257,7 @@ func TestReporter(t *testing.T) {
 	pod2ID := report.MakePodNodeID(pod2UID)
 	serviceID := report.MakeServiceNodeID(serviceUID)
 	hr := controls.NewDefaultHandlerRegistry()
-	rpt, _ := kubernetes.NewReporter(newMockClient(), nil, "probe-id", "foo", nil, hr, "", 0).Report()
+	rpt, _ := kubernetes.NewReporter(newMockClient(), nil, "probe-id", "foo", nil, hr, "", 0).Report(context.Background())
 
 	// Reporter should have added the following pods
 	for _, pod := range []struct {
@@ -318,7 +365,7 @@ func BenchmarkReporter(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		reporter.Report()
+		reporter.Report(context.Background())
 	}
 }
 
@@ -329,7 +376,7 @@ func TestTagger(t *testing.T) {
 	}))
 
 	hr := controls.NewDefaultHandlerRegistry()
-	rpt, err := kubernetes.NewReporter(newMockClient(), nil, "", "", nil, hr, "", 0).Tag(rpt)
+	rpt, err := kubernetes.NewReporter(newMockClient(), nil, "", "", nil, hr, "", 0).Tag(context.Background(), rpt)
 	if err != nil {
 		t.Errorf("Unexpected error: %v", err)
 	}
@@ -348,6 +395,266 @@ type callbackReadCloser struct {
 
 func (c *callbackReadCloser) Close() error { return c.close() }
 
+func TestReporterWorkloadCapacity(t *testing.T) {
+	oldGetNodeName := kubernetes.GetLocalPodUIDs
+	defer func() { kubernetes.GetLocalPodUIDs = oldGetNodeName }()
+	kubernetes.GetLocalPodUIDs = func(string) (map[string]struct{}, error) {
+		return map[string]struct{}{}, nil
+	}
+
+	replicas, minReplicas, targetCPU := int32(3), int32(2), int32(80)
+	deployment := kubernetes.NewDeployment(&apiv1beta1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "pong", Namespace: "ping", UID: "deployment1234", Generation: 2},
+		Spec: apiv1beta1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"ponger": "true"}},
+			Template: apiv1.PodTemplateSpec{Spec: apiv1.PodSpec{Containers: []apiv1.Container{
+				{Name: "pong", Resources: apiv1.ResourceRequirements{
+					Requests: apiv1.ResourceList{apiv1.ResourceCPU: resource.MustParse("250m"), apiv1.ResourceMemory: resource.MustParse("64Mi")},
+					Limits:   apiv1.ResourceList{apiv1.ResourceCPU: resource.MustParse("500m"), apiv1.ResourceMemory: resource.MustParse("128Mi")},
+				}},
+				{Name: "sidecar", Resources: apiv1.ResourceRequirements{
+					Requests: apiv1.ResourceList{apiv1.ResourceCPU: resource.MustParse("1")},
+					Limits:   apiv1.ResourceList{apiv1.ResourceCPU: resource.MustParse("1")},
+				}},
+			}}},
+		},
+		Status: apiv1beta1.DeploymentStatus{ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 2, AvailableReplicas: 2},
+	})
+	client := newMockClient()
+	client.deployments = []kubernetes.Deployment{deployment}
+	client.hpas = []kubernetes.HorizontalPodAutoscaler{
+		kubernetes.NewHorizontalPodAutoscaler(&autoscalingv1.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: "pong-hpa", Namespace: "ping"},
+			Spec: autoscalingv1.HorizontalPodAutoscalerSpec{
+				ScaleTargetRef:                 autoscalingv1.CrossVersionObjectReference{Kind: "Deployment", Name: "pong"},
+				MinReplicas:                    &minReplicas,
+				MaxReplicas:                    10,
+				TargetCPUUtilizationPercentage: &targetCPU,
+			},
+			Status: autoscalingv1.HorizontalPodAutoscalerStatus{CurrentReplicas: 3, DesiredReplicas: 4},
+		}),
+		// Autoscalers of workloads in other namespaces don't apply
+		kubernetes.NewHorizontalPodAutoscaler(&autoscalingv1.HorizontalPodAutoscaler{
+			ObjectMeta: metav1.ObjectMeta{Name: "other-hpa", Namespace: "other"},
+			Spec: autoscalingv1.HorizontalPodAutoscalerSpec{
+				ScaleTargetRef: autoscalingv1.CrossVersionObjectReference{Kind: "Deployment", Name: "pong"},
+				MaxReplicas:    1,
+			},
+		}),
+	}
+	hr := controls.NewDefaultHandlerRegistry()
+	rpt, _ := kubernetes.NewReporter(client, nil, "probe-id", "foo", nil, hr, "", 0).Report(context.Background())
+
+	node, ok := rpt.Deployment.Nodes[report.MakeDeploymentNodeID("deployment1234")]
+	if !ok {
+		t.Fatalf("Expected report to have the deployment")
+	}
+	for k, want := range map[string]string{
+		// The sidecar has no memory limit, so neither has the pod
+		kubernetes.CPURequest:          "1250m",
+		kubernetes.CPULimit:            "1500m",
+		kubernetes.MemoryRequest:       "64Mi",
+		kubernetes.RolloutStatus:       kubernetes.RolloutProgressing,
+		kubernetes.HPA:                 "pong-hpa",
+		kubernetes.HPAMinReplicas:      "2",
+		kubernetes.HPAMaxReplicas:      "10",
+		kubernetes.HPACurrentReplicas:  "3",
+		kubernetes.HPADesiredReplicas:  "4",
+		kubernetes.HPATargetCPUPercent: "80",
+	} {
+		if have, ok := node.Latest.Lookup(k); !ok || have != want {
+			t.Errorf("Expected deployment %s %q, got %q", k, want, have)
+		}
+	}
+	for _, k := range []string{kubernetes.MemoryLimit, kubernetes.HPACurrentCPUPercent} {
+		if have, ok := node.Latest.Lookup(k); ok {
+			t.Errorf("Expected deployment to have no %s, got %q", k, have)
+		}
+	}
+}
+
+func TestReporterEvents(t *testing.T) {
+	oldGetNodeName := kubernetes.GetLocalPodUIDs
+	defer func() { kubernetes.GetLocalPodUIDs = oldGetNodeName }()
+	kubernetes.GetLocalPodUIDs = func(string) (map[string]struct{}, error) {
+		return map[string]struct{}{pod1UID: {}, pod2UID: {}}, nil
+	}
+
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	client := newMockClient()
+	for i := 0; i < kubernetes.MaxEventsPerNode+2; i++ {
+		client.events = append(client.events, kubernetes.NewEvent(&apiv1.Event{
+			InvolvedObject: apiv1.ObjectReference{Kind: "Pod", UID: types.UID(pod1UID)},
+			Type:           "Warning",
+			Reason:         "BackOff",
+			Message:        fmt.Sprintf("Back-off restarting failed container (%d)", i),
+			Count:          int32(i + 1),
+			LastTimestamp:  metav1.NewTime(start.Add(time.Duration(i) * time.Minute)),
+		}))
+	}
+	client.events = append(client.events,
+		// Events of objects which aren't reported are dropped
+		kubernetes.NewEvent(&apiv1.Event{
+			InvolvedObject: apiv1.ObjectReference{Kind: "Pod", UID: "notfound"},
+			Reason:         "FailedScheduling",
+		}),
+		kubernetes.NewEvent(&apiv1.Event{
+			InvolvedObject: apiv1.ObjectReference{Kind: "Node", UID: types.UID(pod2UID)},
+			Reason:         "OOMKilling",
+		}),
+	)
+	hr := controls.NewDefaultHandlerRegistry()
+	rpt, _ := kubernetes.NewReporter(client, nil, "probe-id", "foo", nil, hr, "", 0).Report(context.Background())
+
+	rows, _ := rpt.Pod.Nodes[report.MakePodNodeID(pod1UID)].ExtractTable(kubernetes.EventTableTemplates[kubernetes.EventsTablePrefix])
+	if len(rows) != kubernetes.MaxEventsPerNode {
+		t.Fatalf("Expected %d events, got %d", kubernetes.MaxEventsPerNode, len(rows))
+	}
+	latest := kubernetes.MaxEventsPerNode + 1
+	want := map[string]string{
+		kubernetes.EventLastSeen: start.Add(time.Duration(latest) * time.Minute).Format(time.RFC3339Nano),
+		kubernetes.EventType:     "Warning",
+		kubernetes.EventReason:   "BackOff",
+		kubernetes.EventMessage:  fmt.Sprintf("Back-off restarting failed container (%d)", latest),
+		kubernetes.EventCount:    fmt.Sprint(latest + 1),
+	}
+	if !reflect.DeepEqual(want, rows[0].Entries) {
+		t.Errorf("Expected the latest event first, want %v, got %v", want, rows[0].Entries)
+	}
+	if rows, _ := rpt.Pod.Nodes[report.MakePodNodeID(pod2UID)].ExtractTable(kubernetes.EventTableTemplates[kubernetes.EventsTablePrefix]); len(rows) != 0 {
+		t.Errorf("Expected no events of pod 2, got %v", rows)
+	}
+	if _, ok := rpt.Pod.TableTemplates[kubernetes.EventsTablePrefix]; !ok {
+		t.Errorf("Expected pods to have the events table template")
+	}
+}
+
+func TestReporterCustomResources(t *testing.T) {
+	oldGetNodeName := kubernetes.GetLocalPodUIDs
+	defer func() { kubernetes.GetLocalPodUIDs = oldGetNodeName }()
+	kubernetes.GetLocalPodUIDs = func(string) (map[string]struct{}, error) {
+		return map[string]struct{}{}, nil
+	}
+
+	namespace := kubernetes.NewNamespace(&apiv1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "ping", UID: "namespace1234"},
+	})
+	prometheus := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "monitoring.coreos.com/v1",
+		"kind":       "Prometheus",
+		"metadata": map[string]interface{}{
+			"name":      "k8s",
+			"namespace": "ping",
+			"uid":       "prometheus1234",
+			"labels":    map[string]interface{}{"prometheus": "k8s"},
+		},
+		"spec": map[string]interface{}{
+			"replicas":     int64(2),
+			"retention":    "24h",
+			"ruleSelector": map[string]interface{}{"matchLabels": map[string]interface{}{"role": "alert-rules"}},
+		},
+		"status": map[string]interface{}{},
+	}}
+	client := newMockClient()
+	client.namespaces = []kubernetes.NamespaceResource{namespace}
+	client.customResources = []kubernetes.CustomResource{
+		kubernetes.NewCustomResource(prometheus, []string{"spec.replicas", "spec.ruleSelector", "status.availableReplicas"}),
+	}
+	hr := controls.NewDefaultHandlerRegistry()
+	rpt, _ := kubernetes.NewReporter(client, nil, "probe-id", "foo", nil, hr, "", 0).Report(context.Background())
+
+	node, ok := rpt.CustomResource.Nodes[report.MakeCustomResourceNodeID("prometheus1234")]
+	if !ok {
+		t.Fatalf("Expected report to have the custom resource, got %v", rpt.CustomResource.Nodes)
+	}
+	for k, want := range map[string]string{
+		kubernetes.Name:                                            "k8s",
+		kubernetes.Namespace:                                       "ping",
+		kubernetes.NodeType:                                        "Prometheus",
+		kubernetes.APIVersion:                                      "monitoring.coreos.com/v1",
+		kubernetes.LabelPrefix + "prometheus":                      "k8s",
+		kubernetes.CustomResourceFieldPrefix + "spec.replicas":     "2",
+		kubernetes.CustomResourceFieldPrefix + "spec.ruleSelector": `{"matchLabels":{"role":"alert-rules"}}`,
+	} {
+		if have, ok := node.Latest.Lookup(k); !ok || have != want {
+			t.Errorf("Expected custom resource %s %q, got %q", k, want, have)
+		}
+	}
+	if _, ok := node.Latest.Lookup(kubernetes.CustomResourceFieldPrefix + "status.availableReplicas"); ok {
+		t.Errorf("Expected missing fields not to be reported")
+	}
+	if parents, ok := node.Parents.Lookup(report.Namespace); !ok || !parents.Contains(report.MakeNamespaceNodeID("namespace1234")) {
+		t.Errorf("Expected custom resource to have its namespace as parent, got %q", parents)
+	}
+}
+
+func TestParseCustomResourceSpec(t *testing.T) {
+	for _, c := range []struct {
+		in   string
+		want kubernetes.CustomResourceSpec
+	}{
+		{"monitoring.coreos.com/v1/prometheuses", kubernetes.CustomResourceSpec{
+			GroupVersionResource: schema.GroupVersionResource{Group: "monitoring.coreos.com", Version: "v1", Resource: "prometheuses"},
+		}},
+		{"example.com/v1alpha1/widgets?labels=app%3Dfoo,tier!%3Dweb&fields=spec.size,%20status.phase", kubernetes.CustomResourceSpec{
+			GroupVersionResource: schema.GroupVersionResource{Group: "example.com", Version: "v1alpha1", Resource: "widgets"},
+			Selector:             "app=foo,tier!=web",
+			Fields:               []string{"spec.size", "status.phase"},
+		}},
+	} {
+		have, err := kubernetes.ParseCustomResourceSpec(c.in)
+		if err != nil {
+			t.Errorf("%s: %v", c.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(c.want, have) {
+			t.Errorf("%s: want %+v, have %+v", c.in, c.want, have)
+		}
+	}
+	for _, in := range []string{"", "v1/pods", "example.com//widgets", "example.com/v1/widgets?labels=app%3D%3Dfoo%3D"} {
+		if _, err := kubernetes.ParseCustomResourceSpec(in); err == nil {
+			t.Errorf("%s: expected an error", in)
+		}
+	}
+}
+
+func TestClusterTagger(t *testing.T) {
+	rpt := report.MakeReport()
+	rpt.Host.AddNode(report.MakeNode(report.MakeHostNodeID("foo")))
+	rpt.Endpoint.AddNode(report.MakeNode(report.MakeEndpointNodeID("foo", "", "1.2.3.4", "80")))
+
+	// Until the namespaces are known, nodes are left alone
+	client := newMockClient()
+	tagger := kubernetes.NewClusterTagger("", client)
+	have, _ := tagger.Tag(context.Background(), rpt.Copy())
+	if cluster, ok := have.Host.Nodes[report.MakeHostNodeID("foo")].Latest.Lookup(kubernetes.Cluster); ok {
+		t.Errorf("Expected no cluster, got %q", cluster)
+	}
+
+	client.namespaces = []kubernetes.NamespaceResource{
+		kubernetes.NewNamespace(&apiv1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default", UID: "default1234"}}),
+		kubernetes.NewNamespace(&apiv1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system", UID: "cluster1234"}}),
+	}
+	for _, c := range []struct {
+		tagger *kubernetes.ClusterTagger
+		want   string
+	}{
+		{tagger, "cluster1234"},
+		{kubernetes.NewClusterTagger("prod", client), "prod"},
+	} {
+		have, _ := c.tagger.Tag(context.Background(), rpt.Copy())
+		if cluster, _ := have.Host.Nodes[report.MakeHostNodeID("foo")].Latest.Lookup(kubernetes.Cluster); cluster != c.want {
+			t.Errorf("Expected cluster %q, got %q", c.want, cluster)
+		}
+		for id, n := range have.Endpoint.Nodes {
+			if cluster, _ := n.Latest.Lookup(kubernetes.Cluster); cluster != c.want {
+				t.Errorf("Expected endpoint %s of cluster %q, got %q", id, c.want, cluster)
+			}
+		}
+	}
+}
+
 func TestReporterGetLogs(t *testing.T) {
 	oldGetNodeName := kubernetes.GetLocalPodUIDs
 	defer func() { kubernetes.GetLocalPodUIDs = oldGetNodeName }()
@@ -0,0 +1,68 @@
+package kubernetes
+
+import (
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// These constants are keys used in node metadata
+const (
+	CPURequest    = report.KubernetesCPURequest
+	CPULimit      = report.KubernetesCPULimit
+	MemoryRequest = report.KubernetesMemoryRequest
+	MemoryLimit   = report.KubernetesMemoryLimit
+	RolloutStatus = report.KubernetesRolloutStatus
+)
+
+// Rollout statuses of workloads
+const (
+	RolloutComplete    = "complete"
+	RolloutProgressing = "progressing"
+	RolloutFailed      = "failed"
+)
+
+// podResources sums the resource requests and limits of the containers
+// of a pod. Resources without any request or limit are left out, as are
+// limits which don't apply to all containers, since the pod has no
+// limit then.
+func podResources(spec apiv1.PodSpec) map[string]string {
+	var (
+		result            = map[string]string{}
+		requests          = apiv1.ResourceList{}
+		limits            = apiv1.ResourceList{}
+		limitedContainers = map[apiv1.ResourceName]int{}
+		resources         = map[apiv1.ResourceName][2]string{apiv1.ResourceCPU: {CPURequest, CPULimit}, apiv1.ResourceMemory: {MemoryRequest, MemoryLimit}}
+	)
+	for _, c := range spec.Containers {
+		for name := range resources {
+			if q, ok := c.Resources.Requests[name]; ok {
+				addQuantity(requests, name, q)
+			}
+			if q, ok := c.Resources.Limits[name]; ok {
+				addQuantity(limits, name, q)
+				limitedContainers[name]++
+			}
+		}
+	}
+	for name, keys := range resources {
+		if q, ok := requests[name]; ok {
+			result[keys[0]] = q.String()
+		}
+		if q, ok := limits[name]; ok && limitedContainers[name] == len(spec.Containers) {
+			result[keys[1]] = q.String()
+		}
+	}
+	return result
+}
+
+func addQuantity(list apiv1.ResourceList, name apiv1.ResourceName, q resource.Quantity) {
+	sum, ok := list[name]
+	if !ok {
+		list[name] = q.DeepCopy()
+		return
+	}
+	sum.Add(q)
+	list[name] = sum
+}
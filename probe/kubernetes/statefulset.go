@@ -52,5 +52,20 @@ func (s *statefulSet) GetNode(probeID string) report.Node {
 	if s.Status.ObservedGeneration != nil {
 		latests[ObservedGeneration] = fmt.Sprint(*s.Status.ObservedGeneration)
 	}
+	latests[RolloutStatus] = s.rolloutStatus(desiredReplicas)
+	for k, v := range podResources(s.Spec.Template.Spec) {
+		latests[k] = v
+	}
 	return s.MetaNode(report.MakeStatefulSetNodeID(s.UID())).WithLatests(latests)
 }
+
+// rolloutStatus follows `kubectl rollout status`: a rollout is complete
+// once all replicas are ready and run the latest revision.
+func (s *statefulSet) rolloutStatus(desiredReplicas int) string {
+	if s.Status.ObservedGeneration == nil || *s.Status.ObservedGeneration < s.Generation ||
+		int(s.Status.ReadyReplicas) < desiredReplicas ||
+		s.Status.UpdateRevision != s.Status.CurrentRevision {
+		return RolloutProgressing
+	}
+	return RolloutComplete
+}
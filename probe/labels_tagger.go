@@ -0,0 +1,64 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// LabelPrefix is the prefix of the metadata keys of probe labels
+const LabelPrefix = "probe_label_"
+
+var labelsTableTemplates = report.TableTemplates{
+	LabelPrefix: {
+		ID:     LabelPrefix,
+		Label:  "Probe labels",
+		Type:   report.PropertyListType,
+		Prefix: LabelPrefix,
+	},
+}
+
+// ParseLabels parses a comma-separated list of key=value labels.
+func ParseLabels(s string) (map[string]string, error) {
+	labels := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", pair)
+		}
+		labels[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return labels, nil
+}
+
+type labelsTagger struct {
+	labels map[string]string
+}
+
+// NewLabelsTagger tags every node in the report with the given labels,
+// e.g. the team, environment or datacenter the probe is deployed in.
+func NewLabelsTagger(labels map[string]string) Tagger {
+	return &labelsTagger{labels: labels}
+}
+
+func (labelsTagger) Name() string { return "Labels" }
+
+// Tag implements Tagger
+func (t labelsTagger) Tag(_ context.Context, r report.Report) (report.Report, error) {
+	r.WalkTopologies(func(topology *report.Topology) {
+		if len(topology.Nodes) == 0 {
+			return
+		}
+		*topology = topology.WithTableTemplates(labelsTableTemplates)
+		for _, node := range topology.Nodes {
+			topology.ReplaceNode(node.AddPrefixPropertyList(LabelPrefix, t.labels))
+		}
+	})
+	return r, nil
+}
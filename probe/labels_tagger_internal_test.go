@@ -0,0 +1,44 @@
+package probe
+
+import (
+	"context"
+	"testing"
+
+	"github.com/weaveworks/scope/report"
+)
+
+func TestParseLabels(t *testing.T) {
+	labels, err := ParseLabels("team=net, env = prod,,dc=eu-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(labels) != 3 || labels["team"] != "net" || labels["env"] != "prod" || labels["dc"] != "eu-1" {
+		t.Errorf("unexpected labels: %v", labels)
+	}
+	for _, invalid := range []string{"team", "=net"} {
+		if _, err := ParseLabels(invalid); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestLabelsTagger(t *testing.T) {
+	r := report.MakeReport()
+	r.Host.AddNode(report.MakeNode("host1"))
+	r.Container.AddNode(report.MakeNode("container1"))
+
+	r, _ = NewLabelsTagger(map[string]string{"env": "prod"}).Tag(context.Background(), r)
+	for _, topology := range []report.Topology{r.Host, r.Container} {
+		for id, node := range topology.Nodes {
+			if env, _ := node.Latest.Lookup(LabelPrefix + "env"); env != "prod" {
+				t.Errorf("%s: expected env=prod, got %q", id, env)
+			}
+		}
+		if _, ok := topology.TableTemplates[LabelPrefix]; !ok {
+			t.Errorf("expected the probe labels table template")
+		}
+	}
+	if _, ok := r.Process.TableTemplates[LabelPrefix]; ok {
+		t.Errorf("expected empty topologies to be left alone")
+	}
+}
@@ -0,0 +1,139 @@
+package mesh
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weaveworks/common/mtime"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/common/xlog"
+	"github.com/weaveworks/scope/report"
+)
+
+// log is the logger of the mesh component
+var log = xlog.Component("mesh")
+
+// RTT is the prefix of the keys of the round trip time metrics of host
+// nodes, one per peer host.
+const RTT = "mesh_rtt"
+
+// RTTKey is the key of the round trip time metric to a peer host,
+// identified by its node ID.
+func RTTKey(peerNodeID string) string {
+	return RTT + report.EdgeDelim + peerNodeID
+}
+
+// ParseRTTKey returns the node ID of the peer host of a round trip time
+// metric key.
+func ParseRTTKey(key string) (string, bool) {
+	if !strings.HasPrefix(key, RTT+report.EdgeDelim) {
+		return "", false
+	}
+	return key[len(RTT+report.EdgeDelim):], true
+}
+
+// PingFunc measures the round trip time to an address.
+type PingFunc func(address string) (time.Duration, error)
+
+// Pinger periodically measures the round trip time to the peers the
+// apps give it, reporting it as metrics of the host.
+type Pinger struct {
+	hostID   string
+	peers    func() []xfer.Peer
+	interval time.Duration
+	ping     PingFunc
+
+	mtx  sync.Mutex
+	rtts map[string]rtt // by peer host ID
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+type rtt struct {
+	at    time.Time
+	value time.Duration
+}
+
+// NewPinger makes a new Pinger, measuring the round trip time to each of
+// the peers every interval.
+func NewPinger(hostID string, peers func() []xfer.Peer, interval time.Duration, ping PingFunc) *Pinger {
+	p := &Pinger{
+		hostID:   hostID,
+		peers:    peers,
+		interval: interval,
+		ping:     ping,
+		rtts:     map[string]rtt{},
+		quit:     make(chan struct{}),
+	}
+	p.wg.Add(1)
+	go p.loop()
+	return p
+}
+
+// Name of this reporter, for metrics gathering
+func (*Pinger) Name() string { return "Mesh" }
+
+// Stop implements Reporter, stopping the measurements.
+func (p *Pinger) Stop() {
+	close(p.quit)
+	p.wg.Wait()
+}
+
+func (p *Pinger) loop() {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.measure()
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+// measure pings the peers one at a time, to keep the rate of probing
+// low.
+func (p *Pinger) measure() {
+	rtts := map[string]rtt{}
+	for _, peer := range p.peers() {
+		if peer.HostID == p.hostID {
+			continue
+		}
+		value, err := p.ping(peer.Address)
+		if err != nil {
+			log.Debugf("Error pinging %s (%s): %v", peer.HostID, peer.Address, err)
+			continue
+		}
+		rtts[peer.HostID] = rtt{at: mtime.Now(), value: value}
+	}
+	p.mtx.Lock()
+	p.rtts = rtts
+	p.mtx.Unlock()
+}
+
+// Report implements Reporter.
+func (p *Pinger) Report(context.Context) (report.Report, error) {
+	rpt := report.MakeReport()
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	if len(p.rtts) == 0 {
+		return rpt, nil
+	}
+
+	metrics := report.Metrics{}
+	templates := report.MetricTemplates{}
+	for peerHostID, rtt := range p.rtts {
+		key := RTTKey(report.MakeHostNodeID(peerHostID))
+		metrics[key] = report.MakeSingletonMetric(rtt.at, rtt.value.Seconds()*1000)
+		templates[key] = report.MetricTemplate{ID: key, Label: "RTT to " + peerHostID + " (ms)", Group: RTT, Priority: 20}
+	}
+	rpt.Host = rpt.Host.WithMetricTemplates(templates)
+	rpt.Host.AddNode(report.MakeNode(report.MakeHostNodeID(p.hostID)).WithMetrics(metrics))
+	return rpt, nil
+}
@@ -0,0 +1,85 @@
+package mesh_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/probe/mesh"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestPinger(t *testing.T) {
+	peers := func() []xfer.Peer {
+		return []xfer.Peer{
+			{HostID: "hostA", Address: "10.0.0.1"},
+			{HostID: "hostB", Address: "10.0.0.2"},
+			{HostID: "hostC", Address: "10.0.0.3"},
+		}
+	}
+	measured := make(chan string, 10)
+	ping := func(address string) (time.Duration, error) {
+		measured <- address
+		if address == "10.0.0.3" {
+			return 0, &net.OpError{Op: "dial", Err: errTimeout{}}
+		}
+		return 2 * time.Millisecond, nil
+	}
+	p := mesh.NewPinger("hostA", peers, 10*time.Millisecond, ping)
+	defer p.Stop()
+
+	// Wait for a full round of measurements, skipping our own host
+	for _, want := range []string{"10.0.0.2", "10.0.0.3", "10.0.0.2"} {
+		if have := <-measured; have != want {
+			t.Fatalf("expected to ping %s, pinged %s", want, have)
+		}
+	}
+
+	rpt, err := p.Report(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := rpt.Host.Nodes[report.MakeHostNodeID("hostA")]
+	key := mesh.RTTKey(report.MakeHostNodeID("hostB"))
+	if last, ok := node.Metrics[key].LastSample(); !ok || last.Value != 2 {
+		t.Errorf("expected an RTT of 2ms to hostB, got %v", node.Metrics)
+	}
+	if len(node.Metrics) != 1 {
+		t.Errorf("expected no RTT to unreachable hosts, got %v", node.Metrics)
+	}
+	if _, ok := rpt.Host.MetricTemplates[key]; !ok {
+		t.Errorf("expected a template for the RTT to hostB, got %v", rpt.Host.MetricTemplates)
+	}
+	if peer, ok := mesh.ParseRTTKey(key); !ok || peer != report.MakeHostNodeID("hostB") {
+		t.Errorf("expected to parse the peer of %s, got %s", key, peer)
+	}
+}
+
+type errTimeout struct{}
+
+func (errTimeout) Error() string   { return "i/o timeout" }
+func (errTimeout) Timeout() bool   { return true }
+func (errTimeout) Temporary() bool { return true }
+
+func TestTCPPing(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	ping := mesh.TCPPing(port, time.Second)
+	if _, err := ping("127.0.0.1"); err != nil {
+		t.Errorf("expected to time an accepted connection: %v", err)
+	}
+
+	// Refused connections are timed too
+	listener.Close()
+	if _, err := ping("127.0.0.1"); err != nil {
+		t.Errorf("expected to time a refused connection: %v", err)
+	}
+	if _, err := mesh.ParsePingMethod("udp", port, time.Second); err == nil {
+		t.Errorf("expected an unknown method to be an error")
+	}
+}
@@ -0,0 +1,109 @@
+package mesh
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// TCPPing returns a PingFunc timing TCP handshakes to the port. A
+// refused connection is as good a measurement as an accepted one, so the
+// port need not be open, only not firewalled.
+func TCPPing(port int, timeout time.Duration) PingFunc {
+	return func(address string) (time.Duration, error) {
+		start := time.Now()
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(address, strconv.Itoa(port)), timeout)
+		rtt := time.Since(start)
+		if err == nil {
+			conn.Close()
+			return rtt, nil
+		}
+		if opErr, ok := err.(*net.OpError); ok {
+			if sysErr, ok := opErr.Err.(*os.SyscallError); ok && sysErr.Err == syscall.ECONNREFUSED {
+				return rtt, nil
+			}
+		}
+		return 0, err
+	}
+}
+
+var icmpSequence uint32
+
+// ICMPPing returns a PingFunc timing ICMP echo requests. It needs a raw
+// socket, so the probe must run with CAP_NET_RAW.
+func ICMPPing(timeout time.Duration) PingFunc {
+	return func(address string) (time.Duration, error) {
+		conn, err := net.ListenPacket("ip4:icmp", "0.0.0.0")
+		if err != nil {
+			return 0, err
+		}
+		defer conn.Close()
+		dst, err := net.ResolveIPAddr("ip4", address)
+		if err != nil {
+			return 0, err
+		}
+
+		id, seq := uint16(os.Getpid()), uint16(atomic.AddUint32(&icmpSequence, 1))
+		start := time.Now()
+		if _, err := conn.WriteTo(echoRequest(id, seq), dst); err != nil {
+			return 0, err
+		}
+		conn.SetReadDeadline(start.Add(timeout))
+		buf := make([]byte, 1500)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return 0, err
+			}
+			if isEchoReply(buf[:n], id, seq) && from.String() == dst.String() {
+				return time.Since(start), nil
+			}
+		}
+	}
+}
+
+// echoRequest is an ICMP echo request message.
+func echoRequest(id, seq uint16) []byte {
+	msg := make([]byte, 8)
+	msg[0] = 8 // echo request, code 0
+	binary.BigEndian.PutUint16(msg[4:], id)
+	binary.BigEndian.PutUint16(msg[6:], seq)
+	binary.BigEndian.PutUint16(msg[2:], checksum(msg))
+	return msg
+}
+
+func isEchoReply(msg []byte, id, seq uint16) bool {
+	return len(msg) >= 8 && msg[0] == 0 && msg[1] == 0 &&
+		binary.BigEndian.Uint16(msg[4:]) == id && binary.BigEndian.Uint16(msg[6:]) == seq
+}
+
+// checksum is the internet checksum of RFC 1071.
+func checksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
+
+// ParsePingMethod returns the PingFunc of a method, tcp or icmp.
+func ParsePingMethod(method string, port int, timeout time.Duration) (PingFunc, error) {
+	switch method {
+	case "tcp":
+		return TCPPing(port, timeout), nil
+	case "icmp":
+		return ICMPPing(timeout), nil
+	}
+	return nil, fmt.Errorf("unknown ping method %q", method)
+}
@@ -0,0 +1,48 @@
+package mesh
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/weaveworks/scope/probe"
+)
+
+func init() {
+	probe.RegisterSource(&source{})
+}
+
+// source adds the Pinger to the probe when enabled by flags
+type source struct {
+	enabled  bool
+	interval time.Duration
+	method   string
+	port     int
+	timeout  time.Duration
+}
+
+func (s *source) Name() string { return "Mesh" }
+
+func (s *source) RegisterFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&s.enabled, "probe.mesh.enabled", false, "measure the latency to the peer hosts given by the apps")
+	fs.DurationVar(&s.interval, "probe.mesh.interval", 30*time.Second, "how often to measure the latency to peer hosts")
+	fs.StringVar(&s.method, "probe.mesh.method", "tcp", "how to measure the latency to peer hosts: tcp (handshakes) or icmp (echo requests, needs CAP_NET_RAW)")
+	fs.IntVar(&s.port, "probe.mesh.port", 22, "port of peer hosts to time TCP handshakes to")
+	fs.DurationVar(&s.timeout, "probe.mesh.timeout", 2*time.Second, "timeout of latency measurements")
+}
+
+func (s *source) Enabled() bool { return s.enabled }
+
+func (s *source) Make(env probe.Env) ([]interface{}, error) {
+	if env.Peers == nil {
+		return nil, fmt.Errorf("no apps to get peers from")
+	}
+	if s.interval <= 0 {
+		return nil, fmt.Errorf("invalid interval %v", s.interval)
+	}
+	ping, err := ParsePingMethod(s.method, s.port, s.timeout)
+	if err != nil {
+		return nil, err
+	}
+	return []interface{}{NewPinger(env.HostID, env.Peers, s.interval, ping)}, nil
+}
@@ -1,6 +1,7 @@
 package overlay
 
 import (
+	"context"
 	"fmt"
 	"regexp"
 	"strings"
@@ -173,6 +174,9 @@ func NewWeave(hostID string, client weave.Client) (*Weave, error) {
 // Name of this reporter/tagger/ticker, for metrics gathering
 func (*Weave) Name() string { return "Weave" }
 
+// Topologies of the reporter, for annotating them when it fails.
+func (*Weave) Topologies() []string { return []string{report.Overlay} }
+
 // Stop gathering weave status.
 func (w *Weave) Stop() {
 	w.backoff.Stop()
@@ -193,7 +197,7 @@ func (w *Weave) status() (bool, error) {
 }
 
 // Tag implements Tagger.
-func (w *Weave) Tag(r report.Report) (report.Report, error) {
+func (w *Weave) Tag(_ context.Context, r report.Report) (report.Report, error) {
 	w.mtx.RLock()
 	defer w.mtx.RUnlock()
 
@@ -231,7 +235,7 @@ func (w *Weave) Tag(r report.Report) (report.Report, error) {
 }
 
 // Report implements Reporter.
-func (w *Weave) Report() (report.Report, error) {
+func (w *Weave) Report(context.Context) (report.Report, error) {
 	w.mtx.RLock()
 	defer w.mtx.RUnlock()
 
@@ -1,6 +1,7 @@
 package overlay_test
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -26,7 +27,7 @@ func runTest(t *testing.T, f func(*overlay.Weave)) {
 
 	// Wait until the reporter reports some nodes
 	test.Poll(t, 300*time.Millisecond, 1, func() interface{} {
-		have, _ := w.Report()
+		have, _ := w.Report(context.Background())
 		return len(have.Overlay.Nodes)
 	})
 
@@ -44,7 +45,7 @@ func TestContainerTopologyTagging(t *testing.T) {
 		topology.AddNode(report.MakeNodeWith(nodeID, map[string]string{
 			docker.ContainerID: weave.MockContainerID,
 		}))
-		have, err := w.Tag(report.Report{Container: topology})
+		have, err := w.Tag(context.Background(), report.Report{Container: topology})
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -66,7 +67,7 @@ func TestContainerTopologyTagging(t *testing.T) {
 func TestOverlayTopology(t *testing.T) {
 	test := func(w *overlay.Weave) {
 		// Overlay node should include peer name and nickname
-		have, err := w.Report()
+		have, err := w.Report(context.Background())
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -0,0 +1,264 @@
+package packages
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Package is the OS package a file belongs to.
+type Package struct {
+	Name    string
+	Version string
+	// Manager is the package manager which installed the package: dpkg,
+	// apk or rpm
+	Manager string
+}
+
+// A database is the database of the packages installed in a root
+// filesystem, looking up the package owning a file by its path.
+type database interface {
+	lookup(path string) (Package, bool)
+}
+
+// Locations of the package databases, relative to root filesystems
+const (
+	dpkgStatus = "var/lib/dpkg/status"
+	dpkgInfo   = "var/lib/dpkg/info"
+	apkDB      = "lib/apk/db/installed"
+	rpmDB      = "var/lib/rpm"
+)
+
+// databaseFiles are the package databases by precedence, relative to
+// root filesystems.
+var databaseFiles = []string{dpkgStatus, apkDB, rpmDB}
+
+// databaseModTime returns when the package database of a root filesystem
+// was last modified, or the zero time without a database.
+func databaseModTime(root string) time.Time {
+	for _, file := range databaseFiles {
+		if info, err := os.Stat(filepath.Join(root, file)); err == nil {
+			return info.ModTime()
+		}
+	}
+	return time.Time{}
+}
+
+// isUsrMerged returns whether /bin of a root filesystem is a link to
+// /usr/bin, as on distributions which merged /bin, /sbin and /lib into
+// /usr.
+func isUsrMerged(root string) bool {
+	info, err := os.Lstat(filepath.Join(root, "bin"))
+	return err == nil && info.Mode()&os.ModeSymlink != 0
+}
+
+// usrMergedDirs are the directories merged into /usr
+var usrMergedDirs = []string{"/bin/", "/sbin/", "/lib/", "/lib32/", "/lib64/", "/libx32/"}
+
+// usrMergedPath returns the other path of a file of a directory merged
+// into /usr: its path under /usr, or the other way round. Packages list
+// their files by either.
+func usrMergedPath(path string) (string, bool) {
+	for _, dir := range usrMergedDirs {
+		if strings.HasPrefix(path, dir) {
+			return "/usr" + path, true
+		}
+		if strings.HasPrefix(path, "/usr"+dir) {
+			return strings.TrimPrefix(path, "/usr"), true
+		}
+	}
+	return "", false
+}
+
+// openDatabase opens the package database of a root filesystem. The dpkg
+// and apk databases are read at once; rpm ones are queried with rpm, one
+// file at a time, if it is installed.
+func openDatabase(root string) (database, error) {
+	for _, file := range databaseFiles {
+		if _, err := os.Stat(filepath.Join(root, file)); err != nil {
+			continue
+		}
+		switch file {
+		case dpkgStatus:
+			return readDpkg(root)
+		case apkDB:
+			f, err := os.Open(filepath.Join(root, apkDB))
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			return parseAPKInstalled(f)
+		case rpmDB:
+			if !RPMInstalled() {
+				return noDatabase{}, errors.New("rpm is not installed, to query its database")
+			}
+			return rpmDatabase{root: root}, nil
+		}
+	}
+	return noDatabase{}, nil
+}
+
+type noDatabase struct{}
+
+func (noDatabase) lookup(string) (Package, bool) { return Package{}, false }
+
+// fileDatabase holds the package of every file of a database read at
+// once.
+type fileDatabase map[string]Package
+
+func (db fileDatabase) lookup(path string) (Package, bool) {
+	pkg, ok := db[path]
+	return pkg, ok
+}
+
+// readDpkg reads the versions of the installed packages from the dpkg
+// status file, and their files from the lists of the info directory.
+func readDpkg(root string) (fileDatabase, error) {
+	f, err := os.Open(filepath.Join(root, dpkgStatus))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	versions, err := parseDpkgStatus(f)
+	if err != nil {
+		return nil, err
+	}
+
+	lists, err := filepath.Glob(filepath.Join(root, dpkgInfo, "*.list"))
+	if err != nil {
+		return nil, err
+	}
+	db := fileDatabase{}
+	for _, list := range lists {
+		// Lists are named <package>.list, or <package>:<arch>.list for
+		// packages of several architectures
+		name := strings.TrimSuffix(filepath.Base(list), ".list")
+		name = strings.SplitN(name, ":", 2)[0]
+		version, ok := versions[name]
+		if !ok {
+			continue
+		}
+		buf, err := ioutil.ReadFile(list)
+		if err != nil {
+			continue
+		}
+		pkg := Package{Name: name, Version: version, Manager: "dpkg"}
+		for _, file := range strings.Split(string(buf), "\n") {
+			if file != "" {
+				db[file] = pkg
+			}
+		}
+	}
+	return db, nil
+}
+
+// parseDpkgStatus returns the version of every installed package of a
+// dpkg status file.
+func parseDpkgStatus(r io.Reader) (map[string]string, error) {
+	var (
+		versions              = map[string]string{}
+		name, version, status string
+		scanner               = bufio.NewScanner(r)
+		flush                 = func() {
+			if name != "" && version != "" && strings.HasSuffix(status, " installed") {
+				versions[name] = version
+			}
+			name, version, status = "", "", ""
+		}
+	)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			flush()
+		case strings.HasPrefix(line, "Package: "):
+			name = strings.TrimPrefix(line, "Package: ")
+		case strings.HasPrefix(line, "Version: "):
+			version = strings.TrimPrefix(line, "Version: ")
+		case strings.HasPrefix(line, "Status: "):
+			status = strings.TrimPrefix(line, "Status: ")
+		}
+	}
+	flush()
+	return versions, scanner.Err()
+}
+
+// parseAPKInstalled reads the files of the packages of an apk installed
+// database: stanzas of lines of a letter, a colon and a value, of which
+// P is the name of the package, V its version, F a directory, and R a
+// file of the directory before.
+func parseAPKInstalled(r io.Reader) (fileDatabase, error) {
+	var (
+		db      = fileDatabase{}
+		pkg     = Package{Manager: "apk"}
+		dir     string
+		files   []string
+		scanner = bufio.NewScanner(r)
+		flush   = func() {
+			for _, file := range files {
+				db[file] = pkg
+			}
+			pkg, dir, files = Package{Manager: "apk"}, "", nil
+		}
+	)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			flush()
+			continue
+		}
+		if len(line) < 2 || line[1] != ':' {
+			continue
+		}
+		value := line[2:]
+		switch line[0] {
+		case 'P':
+			pkg.Name = value
+		case 'V':
+			pkg.Version = value
+		case 'F':
+			dir = value
+		case 'R':
+			files = append(files, path.Join("/", dir, value))
+		}
+	}
+	flush()
+	return db, scanner.Err()
+}
+
+// rpmDatabase queries rpm for the package of each file.
+type rpmDatabase struct {
+	root string
+}
+
+func (db rpmDatabase) lookup(path string) (Package, bool) {
+	return QueryRPM(db.root, path)
+}
+
+// RPMInstalled returns whether rpm is in the PATH. Exposed for testing.
+var RPMInstalled = func() bool {
+	_, err := exec.LookPath("rpm")
+	return err == nil
+}
+
+// QueryRPM returns the package owning a file of a root filesystem, as
+// told by rpm. Exposed for testing.
+var QueryRPM = func(root, path string) (Package, bool) {
+	out, err := exec.Command("rpm", "--root", root, "-qf", "--queryformat", "%{NAME}\t%{VERSION}-%{RELEASE}", path).Output()
+	if err != nil {
+		return Package{}, false
+	}
+	fields := strings.Split(strings.TrimSpace(string(out)), "\t")
+	if len(fields) != 2 {
+		return Package{}, false
+	}
+	return Package{Name: fields[0], Version: fields[1], Manager: "rpm"}, true
+}
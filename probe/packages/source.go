@@ -0,0 +1,28 @@
+package packages
+
+import (
+	"flag"
+
+	"github.com/weaveworks/scope/probe"
+)
+
+func init() {
+	probe.RegisterSource(&source{})
+}
+
+// source adds the package tagger to the probe when enabled by flags
+type source struct {
+	enabled bool
+}
+
+func (s *source) Name() string { return "Packages" }
+
+func (s *source) RegisterFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&s.enabled, "probe.packages.enabled", false, "report the OS packages owning the binaries of processes, from the dpkg, apk or rpm databases of their root filesystems")
+}
+
+func (s *source) Enabled() bool { return s.enabled }
+
+func (s *source) Make(env probe.Env) ([]interface{}, error) {
+	return []interface{}{NewTagger(env.ProcRoot)}, nil
+}
@@ -0,0 +1,296 @@
+package packages
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weaveworks/common/mtime"
+
+	"github.com/weaveworks/scope/common/xlog"
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/report"
+)
+
+// log is the logger of the packages component
+var log = xlog.Component("packages")
+
+// Keys for use in process nodes.
+const (
+	PackageName    = "process_package"
+	PackageVersion = "process_package_version"
+	PackageManager = "process_package_manager"
+)
+
+// Exposed for testing
+var (
+	MetadataTemplates = report.MetadataTemplates{
+		PackageName:    {ID: PackageName, Label: "Package", From: report.FromLatest, Priority: 20},
+		PackageVersion: {ID: PackageVersion, Label: "Package version", From: report.FromLatest, Priority: 21},
+		PackageManager: {ID: PackageManager, Label: "Package manager", From: report.FromLatest, Priority: 22},
+	}
+)
+
+// How often the package database of a root is checked for changes.
+const databaseCheckInterval = time.Minute
+
+func (p Package) latests() map[string]string {
+	return map[string]string{
+		PackageName:    p.Name,
+		PackageVersion: p.Version,
+		PackageManager: p.Manager,
+	}
+}
+
+// binary identifies an executable: the mount namespace it is seen from,
+// its path in there and its modification time, so that upgraded binaries
+// are looked up again.
+type binary struct {
+	namespace string
+	path      string
+	mtime     time.Time
+}
+
+type lookup struct {
+	pkg  Package
+	ok   bool
+	seen bool
+}
+
+// root is the package database of a mount namespace
+type root struct {
+	dir       string
+	db        database
+	usrMerged bool
+	modified  time.Time
+	checked   time.Time
+}
+
+// openRoot opens the package database of the root filesystem at dir.
+func openRoot(dir string, now time.Time) *root {
+	db, err := openDatabase(dir)
+	if err != nil {
+		log.Warnf("Error reading the package database of %s: %v", dir, err)
+		db = noDatabase{}
+	}
+	return &root{dir: dir, db: db, usrMerged: isUsrMerged(dir), modified: databaseModTime(dir), checked: now}
+}
+
+// lookup returns the package owning a file, by either of its paths if
+// /usr is merged.
+func (r *root) lookup(path string) (Package, bool) {
+	if pkg, ok := r.db.lookup(path); ok {
+		return pkg, true
+	}
+	if other, ok := usrMergedPath(path); ok && r.usrMerged {
+		return r.db.lookup(other)
+	}
+	return Package{}, false
+}
+
+// Tagger attaches the OS package owning the binary of every process to
+// its process node. The package is looked up in the database of the root
+// filesystem the process runs in, so that the binaries of containers are
+// attributed to the packages of their images. Databases are read, and
+// binaries looked up, in the background, so binaries are tagged from the
+// reports after the one they first show up in.
+type Tagger struct {
+	procRoot string
+
+	mtx     sync.Mutex
+	lookups map[binary]*lookup
+	pending map[binary]struct{} // to look up
+	dirs    map[string]string   // a root directory of each mount namespace
+
+	roots map[string]*root // only used in the background
+	kick  chan struct{}
+	quit  chan struct{}
+	done  chan struct{}
+}
+
+// NewTagger makes a new Tagger, inspecting processes under procRoot.
+func NewTagger(procRoot string) *Tagger {
+	t := &Tagger{
+		procRoot: procRoot,
+		lookups:  map[binary]*lookup{},
+		pending:  map[binary]struct{}{},
+		dirs:     map[string]string{},
+		roots:    map[string]*root{},
+		kick:     make(chan struct{}, 1),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go t.loop()
+	return t
+}
+
+// Name of this tagger, for metrics gathering
+func (*Tagger) Name() string { return "Packages" }
+
+// Report implements Reporter, adding the templates of the packages.
+func (*Tagger) Report(context.Context) (report.Report, error) {
+	rpt := report.MakeReport()
+	rpt.Process = rpt.Process.WithMetadataTemplates(MetadataTemplates)
+	return rpt, nil
+}
+
+// Stop implements Reporter, stopping the background lookups.
+func (t *Tagger) Stop() {
+	close(t.quit)
+	<-t.done
+}
+
+// Tag implements Tagger.
+func (t *Tagger) Tag(_ context.Context, rpt report.Report) (report.Report, error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	namespaces := map[string]struct{}{}
+	for id, n := range rpt.Process.Nodes {
+		pid, ok := n.Latest.Lookup(process.PID)
+		if !ok {
+			continue
+		}
+		b, ok := t.binary(pid)
+		if !ok {
+			continue
+		}
+		namespaces[b.namespace] = struct{}{}
+		if l, ok := t.lookups[b]; ok {
+			l.seen = true
+			if l.ok {
+				rpt.Process.Nodes[id] = n.WithLatests(l.pkg.latests())
+			}
+		} else {
+			t.pending[b] = struct{}{}
+		}
+	}
+	t.prune(namespaces)
+	if len(t.pending) > 0 {
+		select {
+		case t.kick <- struct{}{}:
+		default:
+		}
+	}
+	return rpt, nil
+}
+
+// binary returns the binary of a process, and notes the root directory
+// of its mount namespace. Must be called with the lock held.
+func (t *Tagger) binary(pid string) (binary, bool) {
+	dir := filepath.Join(t.procRoot, pid)
+	exe, err := os.Readlink(filepath.Join(dir, "exe"))
+	if err != nil || strings.HasSuffix(exe, " (deleted)") {
+		return binary{}, false
+	}
+	namespace, err := os.Readlink(filepath.Join(dir, "ns", "mnt"))
+	if err != nil {
+		return binary{}, false
+	}
+	rootDir := filepath.Join(dir, "root")
+	info, err := os.Stat(filepath.Join(rootDir, exe))
+	if err != nil {
+		return binary{}, false
+	}
+	t.dirs[namespace] = rootDir
+	return binary{namespace: namespace, path: exe, mtime: info.ModTime()}, true
+}
+
+// prune forgets the binaries and namespaces of the processes which are
+// gone since the last report. Must be called with the lock held.
+func (t *Tagger) prune(namespaces map[string]struct{}) {
+	for b, l := range t.lookups {
+		if !l.seen {
+			delete(t.lookups, b)
+			continue
+		}
+		l.seen = false
+	}
+	for b := range t.pending {
+		if _, ok := namespaces[b.namespace]; !ok {
+			delete(t.pending, b)
+		}
+	}
+	for namespace := range t.dirs {
+		if _, ok := namespaces[namespace]; !ok {
+			delete(t.dirs, namespace)
+		}
+	}
+}
+
+func (t *Tagger) loop() {
+	defer close(t.done)
+	ticker := time.NewTicker(databaseCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.kick:
+		case <-ticker.C:
+		case <-t.quit:
+			return
+		}
+		t.index()
+	}
+}
+
+// index reads the package databases of the mount namespaces again when
+// they changed, and looks up the binaries pending. Binaries are looked
+// up without holding up reports, as rpm is run for each.
+func (t *Tagger) index() {
+	t.mtx.Lock()
+	pending := t.pending
+	t.pending = map[binary]struct{}{}
+	dirs := make(map[string]string, len(t.dirs))
+	for namespace, dir := range t.dirs {
+		dirs[namespace] = dir
+	}
+	t.mtx.Unlock()
+
+	now := mtime.Now()
+	changed := map[string]struct{}{}
+	for namespace, r := range t.roots {
+		dir, ok := dirs[namespace]
+		if !ok {
+			delete(t.roots, namespace)
+			continue
+		}
+		// The process the directory was of may be gone
+		r.dir = dir
+		if now.Sub(r.checked) < databaseCheckInterval {
+			continue
+		}
+		r.checked = now
+		if !databaseModTime(dir).Equal(r.modified) {
+			t.roots[namespace] = openRoot(dir, now)
+			changed[namespace] = struct{}{}
+		}
+	}
+	found := make(map[binary]*lookup, len(pending))
+	for b := range pending {
+		dir, ok := dirs[b.namespace]
+		if !ok {
+			continue
+		}
+		r, ok := t.roots[b.namespace]
+		if !ok {
+			r = openRoot(dir, now)
+			t.roots[b.namespace] = r
+		}
+		pkg, ok := r.lookup(b.path)
+		found[b] = &lookup{pkg: pkg, ok: ok, seen: true}
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	for b := range t.lookups {
+		if _, ok := changed[b.namespace]; ok {
+			// The packages changed, so look the binaries up again
+			delete(t.lookups, b)
+		}
+	}
+	for b, l := range found {
+		t.lookups[b] = l
+	}
+}
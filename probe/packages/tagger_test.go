@@ -0,0 +1,243 @@
+package packages_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/probe/packages"
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/report"
+)
+
+const dpkgStatus = `Package: coreutils
+Status: install ok installed
+Version: 8.30-3
+
+Package: removed
+Status: deinstall ok config-files
+Version: 1.0-1
+`
+
+const apkInstalled = `P:musl
+V:1.1.24-r2
+F:lib
+R:ld-musl-x86_64.so.1
+
+P:busybox
+V:1.31.1-r9
+F:bin
+R:busybox
+R:sh
+`
+
+func write(t *testing.T, path, content string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func symlink(t *testing.T, target, path string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// addProcess adds a process to the proc root, running exe in the root
+// filesystem rootfs and mount namespace ns.
+func addProcess(t *testing.T, procRoot, pid, exe, rootfs, ns string) {
+	symlink(t, exe, filepath.Join(procRoot, pid, "exe"))
+	symlink(t, rootfs, filepath.Join(procRoot, pid, "root"))
+	symlink(t, ns, filepath.Join(procRoot, pid, "ns", "mnt"))
+}
+
+// tag tags the report until the process has a package, as binaries are
+// looked up in the background.
+func tag(t *testing.T, tagger *packages.Tagger, rpt report.Report, pid string) report.Report {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		rpt, err := tagger.Tag(context.Background(), rpt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := rpt.Process.Nodes[report.MakeProcessNodeID("host", pid)].Latest.Lookup(packages.PackageName); ok {
+			return rpt
+		}
+	}
+	t.Fatalf("process %s was never tagged", pid)
+	return rpt
+}
+
+func processes(pids ...string) report.Report {
+	rpt := report.MakeReport()
+	for _, pid := range pids {
+		rpt.Process.AddNode(report.MakeNodeWith(report.MakeProcessNodeID("host", pid), map[string]string{process.PID: pid}))
+	}
+	return rpt
+}
+
+func TestTagger(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		procRoot = filepath.Join(dir, "proc")
+		host     = filepath.Join(dir, "host")
+		alpine   = filepath.Join(dir, "alpine")
+	)
+	write(t, filepath.Join(host, "var/lib/dpkg/status"), dpkgStatus)
+	write(t, filepath.Join(host, "var/lib/dpkg/info/coreutils:amd64.list"), "/.\n/bin\n/bin/sleep\n")
+	write(t, filepath.Join(host, "var/lib/dpkg/info/removed.list"), "/bin/removed\n")
+	write(t, filepath.Join(host, "bin/sleep"), "")
+	write(t, filepath.Join(host, "bin/removed"), "")
+	write(t, filepath.Join(host, "usr/local/bin/mine"), "")
+	write(t, filepath.Join(alpine, "lib/apk/db/installed"), apkInstalled)
+	write(t, filepath.Join(alpine, "bin/sh"), "")
+	addProcess(t, procRoot, "1", "/bin/sleep", host, "mnt:[1]")
+	addProcess(t, procRoot, "2", "/bin/removed", host, "mnt:[1]")
+	addProcess(t, procRoot, "3", "/usr/local/bin/mine", host, "mnt:[1]")
+	addProcess(t, procRoot, "4", "/bin/sh", alpine, "mnt:[2]")
+
+	tagger := packages.NewTagger(procRoot)
+	defer tagger.Stop()
+	rpt := tag(t, tagger, processes("1", "2", "3", "4", "5"), "1")
+
+	for pid, want := range map[string]packages.Package{
+		"1": {Name: "coreutils", Version: "8.30-3", Manager: "dpkg"},
+		"2": {},
+		"3": {},
+		"4": {Name: "busybox", Version: "1.31.1-r9", Manager: "apk"},
+		"5": {},
+	} {
+		node := rpt.Process.Nodes[report.MakeProcessNodeID("host", pid)]
+		name, _ := node.Latest.Lookup(packages.PackageName)
+		version, _ := node.Latest.Lookup(packages.PackageVersion)
+		manager, _ := node.Latest.Lookup(packages.PackageManager)
+		if have := (packages.Package{Name: name, Version: version, Manager: manager}); have != want {
+			t.Errorf("process %s: want %+v, have %+v", pid, want, have)
+		}
+	}
+}
+
+func TestTaggerRPM(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		procRoot = filepath.Join(dir, "proc")
+		rootfs   = filepath.Join(dir, "centos")
+		queries  int32
+	)
+	if err := os.MkdirAll(filepath.Join(rootfs, "var/lib/rpm"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	write(t, filepath.Join(rootfs, "usr/sbin/sshd"), "")
+	addProcess(t, procRoot, "1", "/usr/sbin/sshd", rootfs, "mnt:[1]")
+	addProcess(t, procRoot, "2", "/usr/sbin/sshd", rootfs, "mnt:[1]")
+
+	oldRPMInstalled, oldQueryRPM := packages.RPMInstalled, packages.QueryRPM
+	defer func() { packages.RPMInstalled, packages.QueryRPM = oldRPMInstalled, oldQueryRPM }()
+	packages.RPMInstalled = func() bool { return true }
+	packages.QueryRPM = func(root, path string) (packages.Package, bool) {
+		atomic.AddInt32(&queries, 1)
+		if path != "/usr/sbin/sshd" {
+			t.Errorf("unexpected query of %s in %s", path, root)
+		}
+		return packages.Package{Name: "openssh-server", Version: "7.4p1-21.el7", Manager: "rpm"}, true
+	}
+
+	tagger := packages.NewTagger(procRoot)
+	defer tagger.Stop()
+	rpt := tag(t, tagger, processes("1", "2"), "1")
+	if rpt, err = tagger.Tag(context.Background(), rpt); err != nil {
+		t.Fatal(err)
+	}
+	if queries := atomic.LoadInt32(&queries); queries != 1 {
+		t.Errorf("want the binary queried once, have %d queries", queries)
+	}
+	for _, pid := range []string{"1", "2"} {
+		node := rpt.Process.Nodes[report.MakeProcessNodeID("host", pid)]
+		if name, _ := node.Latest.Lookup(packages.PackageName); name != "openssh-server" {
+			t.Errorf("process %s: want openssh-server, have %q", pid, name)
+		}
+	}
+}
+
+func TestTaggerWithoutRPM(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		procRoot = filepath.Join(dir, "proc")
+		rootfs   = filepath.Join(dir, "centos")
+		host     = filepath.Join(dir, "host")
+	)
+	if err := os.MkdirAll(filepath.Join(rootfs, "var/lib/rpm"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	write(t, filepath.Join(rootfs, "usr/sbin/sshd"), "")
+	write(t, filepath.Join(host, "var/lib/dpkg/status"), dpkgStatus)
+	write(t, filepath.Join(host, "var/lib/dpkg/info/coreutils.list"), "/bin/sleep\n")
+	write(t, filepath.Join(host, "bin/sleep"), "")
+	addProcess(t, procRoot, "1", "/usr/sbin/sshd", rootfs, "mnt:[1]")
+	addProcess(t, procRoot, "2", "/bin/sleep", host, "mnt:[2]")
+
+	oldRPMInstalled, oldQueryRPM := packages.RPMInstalled, packages.QueryRPM
+	defer func() { packages.RPMInstalled, packages.QueryRPM = oldRPMInstalled, oldQueryRPM }()
+	packages.RPMInstalled = func() bool { return false }
+	packages.QueryRPM = func(root, path string) (packages.Package, bool) {
+		t.Errorf("unexpected query of %s in %s", path, root)
+		return packages.Package{}, false
+	}
+
+	tagger := packages.NewTagger(procRoot)
+	defer tagger.Stop()
+	rpt := tag(t, tagger, processes("1", "2"), "2")
+	if _, ok := rpt.Process.Nodes[report.MakeProcessNodeID("host", "1")].Latest.Lookup(packages.PackageName); ok {
+		t.Error("unexpected package of a root without rpm")
+	}
+}
+
+func TestTaggerUsrMerged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "packages")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		procRoot = filepath.Join(dir, "proc")
+		host     = filepath.Join(dir, "host")
+	)
+	// Packages list their files by /bin, which is a link to /usr/bin
+	write(t, filepath.Join(host, "var/lib/dpkg/status"), dpkgStatus)
+	write(t, filepath.Join(host, "var/lib/dpkg/info/coreutils.list"), "/bin/sleep\n")
+	write(t, filepath.Join(host, "usr/bin/sleep"), "")
+	symlink(t, "usr/bin", filepath.Join(host, "bin"))
+	addProcess(t, procRoot, "1", "/usr/bin/sleep", host, "mnt:[1]")
+
+	tagger := packages.NewTagger(procRoot)
+	defer tagger.Stop()
+	rpt := tag(t, tagger, processes("1"), "1")
+	if name, _ := rpt.Process.Nodes[report.MakeProcessNodeID("host", "1")].Latest.Lookup(packages.PackageName); name != "coreutils" {
+		t.Errorf("want coreutils, have %q", name)
+	}
+}
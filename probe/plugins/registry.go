@@ -15,17 +15,20 @@ import (
 	"time"
 
 	"context"
-	log "github.com/sirupsen/logrus"
 	"github.com/ugorji/go/codec"
 	"golang.org/x/net/context/ctxhttp"
 
 	"github.com/weaveworks/common/backoff"
 	"github.com/weaveworks/common/fs"
 	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/common/xlog"
 	"github.com/weaveworks/scope/probe/controls"
 	"github.com/weaveworks/scope/report"
 )
 
+// log is the logger of the plugins component
+var log = xlog.Component("plugins")
+
 // Exposed for testing
 var (
 	transport                 = makeUnixRoundTripper
@@ -210,12 +213,15 @@ func (r *Registry) Implementers(iface string, f func(p *Plugin)) {
 // Name implements the Reporter interface
 func (r *Registry) Name() string { return "plugins" }
 
+// Stop implements the Reporter interface
+func (r *Registry) Stop() { r.Close() }
+
 // Report implements the Reporter interface
-func (r *Registry) Report() (report.Report, error) {
+func (r *Registry) Report(ctx context.Context) (report.Report, error) {
 	rpt := report.MakeReport()
 	// All plugins are assumed to (and must) implement reporter
 	r.forEach(&r.lock, func(plugin *Plugin) {
-		pluginReport, err := plugin.Report()
+		pluginReport, err := plugin.Report(ctx)
 		if err != nil {
 			log.Errorf("plugins: %s: /report error: %v", plugin.socket, err)
 		}
@@ -386,8 +392,9 @@ func NewPlugin(ctx context.Context, socket string, client *http.Client, expected
 	return plugin, nil
 }
 
-// Report gets the latest report from the plugin
-func (p *Plugin) Report() (result report.Report, err error) {
+// Report gets the latest report from the plugin, giving up if the
+// context is cancelled.
+func (p *Plugin) Report(ctx context.Context) (result report.Report, err error) {
 	result = report.MakeReport()
 	defer func() {
 		p.setStatus(err)
@@ -398,7 +405,7 @@ func (p *Plugin) Report() (result report.Report, err error) {
 		}
 	}()
 
-	if err := p.get("/report", p.handshakeMetadata, &result); err != nil {
+	if err := p.get(ctx, "/report", p.handshakeMetadata, &result); err != nil {
 		return result, err
 	}
 	if result.Plugins.Size() != 1 {
@@ -460,10 +467,18 @@ func (p *Plugin) setStatus(err error) {
 	}
 }
 
-func (p *Plugin) get(path string, params url.Values, result interface{}) error {
-	// Context here lets us either timeout req. or cancel it in Plugin.Close
-	ctx, cancel := context.WithTimeout(p.context, pluginTimeout)
+func (p *Plugin) get(ctx context.Context, path string, params url.Values, result interface{}) error {
+	// Context here lets us either timeout req. or cancel it in Plugin.Close,
+	// as well as when the caller gives up
+	ctx, cancel := context.WithTimeout(ctx, pluginTimeout)
 	defer cancel()
+	go func() {
+		select {
+		case <-p.context.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
 	resp, err := ctxhttp.Get(ctx, p.client, fmt.Sprintf("http://plugin%s?%s", path, params.Encode()))
 	if err != nil {
 		return err
@@ -1,15 +1,22 @@
 package probe
 
 import (
+	"context"
+	"fmt"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/armon/go-metrics"
-	log "github.com/sirupsen/logrus"
+	"github.com/weaveworks/common/mtime"
 
+	"github.com/weaveworks/scope/common/xlog"
 	"github.com/weaveworks/scope/report"
 )
 
+// log is the logger of the probe component
+var log = xlog.Component("probe")
+
 const (
 	reportBufferSize = 16
 )
@@ -24,49 +31,75 @@ type Probe struct {
 	spyInterval, publishInterval time.Duration
 	publisher                    ReportPublisher
 	noControls                   bool
+	recorder                     *FlightRecorder
+	redaction                    RedactionRules
+	supervisor                   *supervisor
+	sequence                     uint64 // of the last published report
+	run                          uint64 // of the probe, told apart on restarts
+	heartbeat                    string // host node ID stamped on publications
 
 	tickers   []Ticker
 	reporters []Reporter
 	taggers   []Tagger
 
-	quit chan struct{}
-	done sync.WaitGroup
+	// ctx is cancelled when the probe is stopped, so that reporters and
+	// tickers mid-scan return promptly
+	ctx    context.Context
+	cancel context.CancelFunc
+	quit   chan struct{}
+	done   sync.WaitGroup
 
 	spiedReports    chan report.Report
 	shortcutReports chan report.Report
 }
 
-// Tagger tags nodes with value-add node metadata.
+// Tagger tags nodes with value-add node metadata. Like reports, tagging
+// is cancelled when the probe is stopped.
 type Tagger interface {
 	Name() string
-	Tag(r report.Report) (report.Report, error)
+	Tag(ctx context.Context, r report.Report) (report.Report, error)
 }
 
-// Reporter generates Reports.
+// Reporter generates Reports. The probe stops its reporters when it is
+// stopped, cancelling the context of any report in progress first;
+// reporters scanning for long should give up when it is.
 type Reporter interface {
 	Name() string
-	Report() (report.Report, error)
+	Report(ctx context.Context) (report.Report, error)
+	Stop()
+}
+
+// TopologyReporter is a Reporter saying which topologies it reports, so
+// that the probe can annotate them with why they are missing when it
+// fails.
+type TopologyReporter interface {
+	Reporter
+	Topologies() []string
 }
 
 // ReporterFunc uses a function to implement a Reporter
-func ReporterFunc(name string, f func() (report.Report, error)) Reporter {
+func ReporterFunc(name string, f func(context.Context) (report.Report, error)) Reporter {
 	return reporterFunc{name, f}
 }
 
 type reporterFunc struct {
 	name string
-	f    func() (report.Report, error)
+	f    func(context.Context) (report.Report, error)
 }
 
-func (r reporterFunc) Name() string                   { return r.name }
-func (r reporterFunc) Report() (report.Report, error) { return r.f() }
+func (r reporterFunc) Name() string { return r.name }
+func (r reporterFunc) Report(ctx context.Context) (report.Report, error) {
+	return r.f(ctx)
+}
+func (r reporterFunc) Stop() {}
 
 // Ticker is something which will be invoked every spyDuration.
 // It's useful for things that should be updated on that interval.
 // For example, cached shared state between Taggers and Reporters.
+// Like reports, ticks are cancelled when the probe is stopped.
 type Ticker interface {
 	Name() string
-	Tick() error
+	Tick(ctx context.Context) error
 }
 
 // New makes a new Probe.
@@ -75,11 +108,16 @@ func New(
 	publisher ReportPublisher,
 	noControls bool,
 ) *Probe {
+	ctx, cancel := context.WithCancel(context.Background())
 	result := &Probe{
+		ctx:             ctx,
+		cancel:          cancel,
 		spyInterval:     spyInterval,
 		publishInterval: publishInterval,
 		publisher:       publisher,
 		noControls:      noControls,
+		supervisor:      newSupervisor(),
+		run:             uint64(time.Now().UnixNano()),
 		quit:            make(chan struct{}),
 		spiedReports:    make(chan report.Report, reportBufferSize),
 		shortcutReports: make(chan report.Report, reportBufferSize),
@@ -87,6 +125,25 @@ func New(
 	return result
 }
 
+// SetFlightRecorder makes the Probe record its spy cycles and
+// publications in f.
+func (p *Probe) SetFlightRecorder(f *FlightRecorder) {
+	p.recorder = f
+}
+
+// SetRedaction makes the Probe redact its reports with rules right
+// before publishing them.
+func (p *Probe) SetRedaction(rules RedactionRules) {
+	p.redaction = rules
+}
+
+// SetHeartbeat makes the Probe stamp the node of its host with a
+// heartbeat on every publication, even if nothing was spied since the
+// last one, so that apps can tell its host is quiet rather than lost.
+func (p *Probe) SetHeartbeat(hostID string) {
+	p.heartbeat = report.MakeHostNodeID(hostID)
+}
+
 // AddTagger adds a new Tagger to the Probe
 func (p *Probe) AddTagger(ts ...Tagger) {
 	p.taggers = append(p.taggers, ts...)
@@ -109,10 +166,14 @@ func (p *Probe) Start() {
 	go p.publishLoop()
 }
 
-// Stop stops the probe
+// Stop stops the probe, and then its reporters
 func (p *Probe) Stop() error {
+	p.cancel()
 	close(p.quit)
 	p.done.Wait()
+	for i := len(p.reporters) - 1; i >= 0; i-- {
+		p.reporters[i].Stop()
+	}
 	return nil
 }
 
@@ -131,60 +192,110 @@ func (p *Probe) spyLoop() {
 		select {
 		case <-spyTick:
 			t := time.Now()
-			p.tick()
-			rpt := p.report()
-			rpt = p.tag(rpt)
+			tickers := p.tick()
+			rpt, reporters := p.report()
+			if p.ctx.Err() != nil {
+				return
+			}
+			rpt, taggers := p.tagTimed(rpt)
 			p.spiedReports <- rpt
 			metrics.MeasureSince([]string{"Report Generaton"}, t)
+			if p.recorder != nil {
+				p.recorder.recordCycle(SpyCycle{
+					Start:     t,
+					Duration:  time.Since(t),
+					Tickers:   tickers,
+					Reporters: reporters,
+					Taggers:   taggers,
+				})
+			}
 		case <-p.quit:
 			return
 		}
 	}
 }
 
-func (p *Probe) tick() {
+func (p *Probe) tick() []Timing {
+	timings := make([]Timing, 0, len(p.tickers))
 	for _, ticker := range p.tickers {
 		t := time.Now()
-		err := ticker.Tick()
+		skipped, err := p.supervisor.run("ticker", ticker.Name(), func() error { return ticker.Tick(p.ctx) })
 		metrics.MeasureSince([]string{ticker.Name(), "ticker"}, t)
 		if err != nil {
 			log.Errorf("error doing ticker: %v", err)
 		}
+		timings = append(timings, Timing{Name: ticker.Name(), Duration: time.Since(t), Error: errorString(err), Skipped: skipped})
 	}
+	return timings
 }
 
-func (p *Probe) report() report.Report {
-	reports := make(chan report.Report, len(p.reporters))
+type timedReport struct {
+	report.Report
+	timing Timing
+}
+
+func (p *Probe) report() (report.Report, []Timing) {
+	reports := make(chan timedReport, len(p.reporters))
 	for _, rep := range p.reporters {
 		go func(rep Reporter) {
 			t := time.Now()
 			timer := time.AfterFunc(p.spyInterval, func() { log.Warningf("%v reporter took longer than %v", rep.Name(), p.spyInterval) })
-			newReport, err := rep.Report()
+			var newReport report.Report
+			skipped, err := p.supervisor.run("reporter", rep.Name(), func() (err error) {
+				newReport, err = rep.Report(p.ctx)
+				return err
+			})
 			if !timer.Stop() {
 				log.Warningf("%v reporter took %v (longer than %v)", rep.Name(), time.Now().Sub(t), p.spyInterval)
 			}
 			metrics.MeasureSince([]string{rep.Name(), "reporter"}, t)
+			timing := Timing{Name: rep.Name(), Duration: time.Since(t), Error: errorString(err), Skipped: skipped}
 			if err != nil {
 				log.Errorf("error generating report: %v", err)
+			}
+			if err != nil || skipped {
 				newReport = report.MakeReport() // empty is OK to merge
+				if tr, ok := rep.(TopologyReporter); ok {
+					message := fmt.Sprintf("%s: %v", rep.Name(), err)
+					if skipped {
+						message = fmt.Sprintf("%s: skipped after failing repeatedly", rep.Name())
+					}
+					for _, topology := range tr.Topologies() {
+						newReport.AddError(topology, message)
+					}
+				}
 			}
-			reports <- newReport
+			reports <- timedReport{newReport, timing}
 		}(rep)
 	}
 
 	result := report.MakeReport()
+	timings := make([]Timing, 0, cap(reports))
 	for i := 0; i < cap(reports); i++ {
-		result = result.Merge(<-reports)
+		r := <-reports
+		result = result.Merge(r.Report)
+		timings = append(timings, r.timing)
 	}
-	return result
+	return result, timings
 }
 
 func (p *Probe) tag(r report.Report) report.Report {
-	var err error
+	r, _ = p.tagTimed(r)
+	return r
+}
+
+func (p *Probe) tagTimed(r report.Report) (report.Report, []Timing) {
+	timings := make([]Timing, 0, len(p.taggers))
 	for _, tagger := range p.taggers {
 		t := time.Now()
 		timer := time.AfterFunc(p.spyInterval, func() { log.Warningf("%v tagger took longer than %v", tagger.Name(), p.spyInterval) })
-		r, err = tagger.Tag(r)
+		skipped, err := p.supervisor.run("tagger", tagger.Name(), func() error {
+			tagged, err := tagger.Tag(p.ctx, r)
+			if err == nil {
+				r = tagged
+			}
+			return err
+		})
 		if !timer.Stop() {
 			log.Warningf("%v tagger took %v (longer than %v)", tagger.Name(), time.Now().Sub(t), p.spyInterval)
 		}
@@ -192,8 +303,9 @@ func (p *Probe) tag(r report.Report) report.Report {
 		if err != nil {
 			log.Errorf("error applying tagger: %v", err)
 		}
+		timings = append(timings, Timing{Name: tagger.Name(), Duration: time.Since(t), Error: errorString(err), Skipped: skipped})
 	}
-	return r
+	return r, timings
 }
 
 func (p *Probe) drainAndPublish(rpt report.Report, rs chan report.Report) {
@@ -212,9 +324,23 @@ ForLoop:
 			t.Controls = report.Controls{}
 		})
 	}
-	if err := p.publisher.Publish(rpt); err != nil {
+	if len(p.redaction) > 0 {
+		rpt = p.redaction.Redact(rpt)
+	}
+	t := time.Now()
+	p.sequence++
+	rpt.Sequence, rpt.Run, rpt.Timestamp = p.sequence, p.run, mtime.Now()
+	if p.heartbeat != "" {
+		rpt.Host.AddNode(report.MakeNode(p.heartbeat).
+			WithLatest(report.HostHeartbeat, rpt.Timestamp, strconv.FormatUint(rpt.Sequence, 10)))
+	}
+	err := p.publisher.Publish(rpt)
+	if err != nil {
 		log.Infof("publish: %v", err)
 	}
+	if p.recorder != nil {
+		p.recorder.recordPublication(Publication{Start: t, Duration: time.Since(t), Error: errorString(err)})
+	}
 }
 
 func (p *Probe) publishLoop() {
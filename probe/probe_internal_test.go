@@ -1,6 +1,8 @@
 package probe
 
 import (
+	"context"
+	"fmt"
 	"testing"
 	"time"
 
@@ -40,11 +42,12 @@ type mockReporter struct {
 	r report.Report
 }
 
-func (m mockReporter) Report() (report.Report, error) {
+func (m mockReporter) Report(context.Context) (report.Report, error) {
 	return m.r.Copy(), nil
 }
 
 func (mockReporter) Name() string { return "Mock" }
+func (mockReporter) Stop()        {}
 
 type mockPublisher struct {
 	have chan report.Report
@@ -76,6 +79,7 @@ func TestProbe(t *testing.T) {
 		t.Controls = nil
 	})
 	want.Endpoint.AddNode(node)
+	want.Timestamp = now
 
 	pub := mockPublisher{make(chan report.Report, 10)}
 
@@ -85,6 +89,88 @@ func TestProbe(t *testing.T) {
 	defer p.Stop()
 
 	test.Poll(t, 300*time.Millisecond, want, func() interface{} {
-		return <-pub.have
+		have := <-pub.have
+		have.Sequence, have.Run = 0, 0
+		return have
 	})
 }
+
+func TestProbeSequence(t *testing.T) {
+	pub := mockPublisher{make(chan report.Report, 10)}
+	p := New(0, 0, pub, false)
+	for i := uint64(1); i <= 3; i++ {
+		p.drainAndPublish(report.MakeReport(), p.spiedReports)
+		if have := <-pub.have; have.Sequence != i {
+			t.Errorf("want sequence %d, have %d", i, have.Sequence)
+		}
+	}
+}
+
+func TestProbeStopCancelsReporters(t *testing.T) {
+	pub := mockPublisher{make(chan report.Report, 10)}
+	p := New(10*time.Millisecond, time.Hour, pub, false)
+	started := make(chan struct{})
+	p.AddReporter(ReporterFunc("blocking", func(ctx context.Context) (report.Report, error) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-ctx.Done()
+		return report.MakeReport(), ctx.Err()
+	}))
+	p.Start()
+	<-started
+
+	stopped := make(chan struct{})
+	go func() {
+		p.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("expected stopping the probe to cancel its reporters")
+	}
+}
+
+type failingReporter struct{}
+
+func (failingReporter) Name() string { return "Failing" }
+func (failingReporter) Report(context.Context) (report.Report, error) {
+	return report.MakeReport(), fmt.Errorf("connection refused")
+}
+func (failingReporter) Stop()                {}
+func (failingReporter) Topologies() []string { return []string{report.Container} }
+
+func TestProbeReportsErrors(t *testing.T) {
+	want := report.MakeReport()
+	want.Host.AddNode(report.MakeNode("a"))
+	p := New(0, 0, nil, false)
+	p.AddReporter(mockReporter{want}, failingReporter{})
+
+	rpt, _ := p.report()
+	if _, ok := rpt.Host.Nodes["a"]; !ok {
+		t.Error("expected the report of the other reporters")
+	}
+	if have := rpt.Errors[report.Container]; have != "Failing: connection refused" {
+		t.Errorf("expected the topology of the failing reporter annotated, got %q", have)
+	}
+	if len(rpt.Errors) != 1 {
+		t.Errorf("expected only the topologies of the failing reporter annotated, got %v", rpt.Errors)
+	}
+}
+
+func TestProbeHeartbeat(t *testing.T) {
+	pub := mockPublisher{make(chan report.Report, 10)}
+	p := New(0, 0, pub, false)
+	p.SetHeartbeat("foo")
+	p.drainAndPublish(report.MakeReport(), p.spiedReports)
+	have := <-pub.have
+	node, ok := have.Host.Nodes[report.MakeHostNodeID("foo")]
+	if !ok {
+		t.Fatal("expected the node of the host in empty reports")
+	}
+	if sequence, ts, ok := node.Latest.LookupEntry(report.HostHeartbeat); !ok || sequence != "1" || !ts.Equal(have.Timestamp) {
+		t.Errorf("expected a heartbeat at %v of report 1, got %q at %v", have.Timestamp, sequence, ts)
+	}
+}
@@ -1,6 +1,7 @@
 package process
 
 import (
+	"context"
 	"strconv"
 	"strings"
 
@@ -18,6 +19,11 @@ const (
 	CPUUsage       = "process_cpu_usage_percent"
 	MemoryUsage    = "process_memory_usage_bytes"
 	OpenFilesCount = "open_files_count"
+	SocketsCount   = "open_sockets_count"
+	ThreadsCount   = "threads_count"
+	// "true" when only some of the file descriptors of the process were
+	// looked at for sockets
+	SocketsCountTruncated = "open_sockets_count_truncated"
 )
 
 // Exposed for testing
@@ -27,12 +33,16 @@ var (
 		Cmdline: {ID: Cmdline, Label: "Command", From: report.FromLatest, Priority: 2},
 		PPID:    {ID: PPID, Label: "Parent PID", From: report.FromLatest, Datatype: report.Number, Priority: 3},
 		Threads: {ID: Threads, Label: "# Threads", From: report.FromLatest, Datatype: report.Number, Priority: 4},
+
+		SocketsCountTruncated: {ID: SocketsCountTruncated, Label: "Open sockets partly counted", From: report.FromLatest, Priority: 5},
 	}
 
 	MetricTemplates = report.MetricTemplates{
 		CPUUsage:       {ID: CPUUsage, Label: "CPU", Format: report.PercentFormat, Priority: 1},
 		MemoryUsage:    {ID: MemoryUsage, Label: "Memory", Format: report.FilesizeFormat, Priority: 2},
 		OpenFilesCount: {ID: OpenFilesCount, Label: "Open files", Format: report.IntegerFormat, Priority: 3},
+		SocketsCount:   {ID: SocketsCount, Label: "Open sockets", Format: report.IntegerFormat, Priority: 4},
+		ThreadsCount:   {ID: ThreadsCount, Label: "Threads", Format: report.IntegerFormat, Priority: 5},
 	}
 )
 
@@ -60,10 +70,16 @@ func NewReporter(walker Walker, scope string, jiffies Jiffies, noCommandLineArgu
 // Name of this reporter, for metrics gathering
 func (Reporter) Name() string { return "Process" }
 
+// Topologies of the reporter, for annotating them when it fails.
+func (Reporter) Topologies() []string { return []string{report.Process} }
+
+// Stop implements Reporter. The walker is stopped by its owner.
+func (Reporter) Stop() {}
+
 // Report implements Reporter.
-func (r *Reporter) Report() (report.Report, error) {
+func (r *Reporter) Report(ctx context.Context) (report.Report, error) {
 	result := report.MakeReport()
-	processes, err := r.processTopology()
+	processes, err := r.processTopology(ctx)
 	if err != nil {
 		return result, err
 	}
@@ -71,7 +87,7 @@ func (r *Reporter) Report() (report.Report, error) {
 	return result, nil
 }
 
-func (r *Reporter) processTopology() (report.Topology, error) {
+func (r *Reporter) processTopology(ctx context.Context) (report.Topology, error) {
 	t := report.MakeTopology().
 		WithMetadataTemplates(MetadataTemplates).
 		WithMetricTemplates(MetricTemplates)
@@ -81,7 +97,7 @@ func (r *Reporter) processTopology() (report.Topology, error) {
 		return t, err
 	}
 
-	err = r.walker.Walk(func(p, prev Process) {
+	err = r.walker.Walk(ctx, func(p, prev Process) {
 		pidstr := strconv.Itoa(p.PID)
 		nodeID := report.MakeProcessNodeID(r.scope, pidstr)
 		node := report.MakeNode(nodeID)
@@ -106,6 +122,14 @@ func (r *Reporter) processTopology() (report.Topology, error) {
 		var metrics = report.Metrics{
 			MemoryUsage:    report.MakeSingletonMetric(now, float64(p.RSSBytes)).WithMax(float64(p.RSSBytesLimit)),
 			OpenFilesCount: report.MakeSingletonMetric(now, float64(p.OpenFilesCount)).WithMax(float64(p.OpenFilesLimit)),
+			ThreadsCount:   report.MakeSingletonMetric(now, float64(p.Threads)),
+		}
+		if p.SocketsCounted {
+			// Sockets count against the open files limit too
+			metrics[SocketsCount] = report.MakeSingletonMetric(now, float64(p.OpenSocketsCount)).WithMax(float64(p.OpenFilesLimit))
+			if p.SocketsTruncated {
+				node = node.WithLatest(SocketsCountTruncated, now, "true")
+			}
 		}
 		if deltaTotal > 0 {
 			cpuUsage := float64(p.Jiffies-prev.Jiffies) / float64(deltaTotal) * 100.
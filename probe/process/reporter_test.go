@@ -1,6 +1,7 @@
 package process_test
 
 import (
+	"context"
 	"fmt"
 	"testing"
 	"time"
@@ -14,8 +15,11 @@ type mockWalker struct {
 	processes []process.Process
 }
 
-func (m *mockWalker) Walk(f func(process.Process, process.Process)) error {
+func (m *mockWalker) Walk(ctx context.Context, f func(process.Process, process.Process)) error {
 	for _, p := range m.processes {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		f(p, process.Process{})
 	}
 	return nil
@@ -24,7 +28,7 @@ func (m *mockWalker) Walk(f func(process.Process, process.Process)) error {
 var processes = []process.Process{
 	{PID: 1, PPID: 0, Name: "init"},
 	{PID: 2, PPID: 1, Name: "bash"},
-	{PID: 3, PPID: 1, Name: "apache", Threads: 2},
+	{PID: 3, PPID: 1, Name: "apache", Threads: 2, OpenSocketsCount: 4, SocketsCounted: true, SocketsTruncated: true},
 	{PID: 4, PPID: 2, Name: "ping", Cmdline: "ping foo.bar.local"},
 	{PID: 5, PPID: 1, Cmdline: "tail -f /var/log/syslog"},
 }
@@ -36,7 +40,7 @@ func testReporter(t *testing.T, noCommandLineArguments bool, test func(report.Re
 	mtime.NowForce(now)
 	defer mtime.NowReset()
 
-	rpt, err := process.NewReporter(walker, "", getDeltaTotalJiffies, noCommandLineArguments).Report()
+	rpt, err := process.NewReporter(walker, "", getDeltaTotalJiffies, noCommandLineArguments).Report(context.Background())
 	if err != nil {
 		t.Error(err)
 	}
@@ -89,6 +93,27 @@ func TestThreadCounts(t *testing.T) {
 		if threads, ok := node.Latest.Lookup(process.Threads); !ok || threads != fmt.Sprint(processes[2].Threads) {
 			t.Errorf("Expected %d got %q", processes[2].Threads, threads)
 		}
+		if sample, ok := node.Metrics[process.ThreadsCount].LastSample(); !ok || sample.Value != 2 {
+			t.Errorf("Expected threads count metric of 2, got %v", sample)
+		}
+	}
+	testReporter(t, false, test)
+}
+
+func TestSocketCounts(t *testing.T) {
+	test := func(rpt report.Report) {
+		node := rpt.Process.Nodes[report.MakeProcessNodeID("", "3")]
+		if sample, ok := node.Metrics[process.SocketsCount].LastSample(); !ok || sample.Value != 4 {
+			t.Errorf("Expected open sockets metric of 4, got %v", sample)
+		}
+		if truncated, _ := node.Latest.Lookup(process.SocketsCountTruncated); truncated != "true" {
+			t.Errorf("Expected the open sockets count to be marked truncated")
+		}
+		// Sockets weren't counted for bash
+		node = rpt.Process.Nodes[report.MakeProcessNodeID("", "2")]
+		if _, ok := node.Metrics[process.SocketsCount]; ok {
+			t.Errorf("Expected no open sockets metric")
+		}
 	}
 	testReporter(t, false, test)
 }
@@ -142,7 +167,7 @@ func BenchmarkReporter(t *testing.B) {
 	t.ResetTimer()
 
 	for i := 0; i < t.N; i++ {
-		_, err := reporter.Report()
+		_, err := reporter.Report(context.Background())
 		if err != nil {
 			t.Error(err)
 		}
@@ -1,6 +1,7 @@
 package process
 
 import (
+	"context"
 	"fmt"
 )
 
@@ -14,9 +15,9 @@ type tree struct {
 }
 
 // NewTree returns a new Tree that can be polled.
-func NewTree(walker Walker) (Tree, error) {
+func NewTree(ctx context.Context, walker Walker) (Tree, error) {
 	pt := tree{processes: map[int]Process{}}
-	err := walker.Walk(func(p, _ Process) {
+	err := walker.Walk(ctx, func(p, _ Process) {
 		pt.processes[p.PID] = p
 	})
 
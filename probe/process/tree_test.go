@@ -1,6 +1,7 @@
 package process_test
 
 import (
+	"context"
 	"reflect"
 	"testing"
 
@@ -17,7 +18,7 @@ func TestTree(t *testing.T) {
 		},
 	}
 
-	tree, err := process.NewTree(walker)
+	tree, err := process.NewTree(context.Background(), walker)
 	if err != nil {
 		t.Fatalf("newProcessTree error: %v", err)
 	}
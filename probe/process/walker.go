@@ -1,6 +1,9 @@
 package process
 
-import "sync"
+import (
+	"context"
+	"sync"
+)
 
 // Process represents a single process.
 type Process struct {
@@ -14,11 +17,17 @@ type Process struct {
 	OpenFilesCount    int
 	OpenFilesLimit    uint64
 	IsWaitingInAccept bool
+	// OpenSocketsCount is only set when SocketsCounted, and is a lower
+	// bound when SocketsTruncated
+	OpenSocketsCount int
+	SocketsCounted   bool
+	SocketsTruncated bool
 }
 
-// Walker is something that walks the /proc directory
+// Walker is something that walks the /proc directory. Walks stop early,
+// returning the error of the context, when it is cancelled.
 type Walker interface {
-	Walk(func(Process, Process)) error
+	Walk(context.Context, func(Process, Process)) error
 }
 
 // CachingWalker is a walker than caches a copy of the output from another
@@ -39,20 +48,23 @@ func NewCachingWalker(source Walker) *CachingWalker {
 func (*CachingWalker) Name() string { return "Process" }
 
 // Walk walks a cached copy of process list
-func (c *CachingWalker) Walk(f func(Process, Process)) error {
+func (c *CachingWalker) Walk(ctx context.Context, f func(Process, Process)) error {
 	c.cacheLock.RLock()
 	defer c.cacheLock.RUnlock()
 
 	for _, p := range c.cache {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		f(p, c.previousByPID[p.PID])
 	}
 	return nil
 }
 
 // Tick updates cached copy of process list
-func (c *CachingWalker) Tick() error {
+func (c *CachingWalker) Tick(ctx context.Context) error {
 	newCache := map[int]Process{}
-	err := c.source.Walk(func(p, _ Process) {
+	err := c.source.Walk(ctx, func(p, _ Process) {
 		newCache[p.PID] = p
 	})
 	if err != nil {
@@ -1,6 +1,7 @@
 package process
 
 import (
+	"context"
 	"fmt"
 	"os/exec"
 	"strconv"
@@ -12,6 +13,12 @@ func NewWalker(_ string, _ bool) Walker {
 	return &walker{}
 }
 
+// NewSocketCountingWalker returns a Darwin (lsof-based) walker, which
+// doesn't count sockets.
+func NewSocketCountingWalker(_ string, _ bool, _ int) Walker {
+	return &walker{}
+}
+
 type walker struct{}
 
 const (
@@ -29,8 +36,9 @@ func IsProcInAccept(procRoot, pid string) (ret bool) {
 	return false
 }
 
-func (walker) Walk(f func(Process, Process)) error {
-	output, err := exec.Command(
+func (walker) Walk(ctx context.Context, f func(Process, Process)) error {
+	output, err := exec.CommandContext(
+		ctx,
 		lsofBinary,
 		"-i",       // only Internet files
 		"-n", "-P", // no number resolving
@@ -2,12 +2,16 @@ package process
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
 	"fmt"
 	"os"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 
 	linuxproc "github.com/c9s/goprocinfo/linux"
 	"github.com/coocood/freecache"
@@ -19,6 +23,12 @@ import (
 type walker struct {
 	procRoot                 string
 	gatheringWaitingInAccept bool
+	maxSocketScanFDs         int
+
+	mtx sync.Mutex
+	// The PID of the first process the previous walk had no budget left
+	// to count the sockets of, if any
+	socketScanFrom int
 }
 
 var (
@@ -40,9 +50,20 @@ const (
 
 // NewWalker creates a new process Walker.
 func NewWalker(procRoot string, gatheringWaitingInAccept bool) Walker {
+	return NewSocketCountingWalker(procRoot, gatheringWaitingInAccept, 0)
+}
+
+// NewSocketCountingWalker creates a new process Walker which also counts
+// the sockets open by processes. Counting them stats every file
+// descriptor, so at most maxSocketScanFDs are looked at per walk: the
+// sockets of a process are counted among as many of its file
+// descriptors as are left, truncated if not all, and each walk starts
+// with the processes the previous one had none left for.
+func NewSocketCountingWalker(procRoot string, gatheringWaitingInAccept bool, maxSocketScanFDs int) Walker {
 	return &walker{
 		procRoot:                 procRoot,
 		gatheringWaitingInAccept: gatheringWaitingInAccept,
+		maxSocketScanFDs:         maxSocketScanFDs,
 	}
 }
 
@@ -170,6 +191,30 @@ func (w *walker) readCmdline(filename string) (cmdline, name string) {
 	return
 }
 
+// countSockets counts the file descriptors of a process which are
+// sockets, looking at up to max of them, and returns how many it looked
+// at, and whether there were more.
+func (w *walker) countSockets(filename string, max int) (sockets, scanned int, truncated bool, err error) {
+	fdBase := path.Join(w.procRoot, filename, "fd")
+	fds, err := fs.ReadDirNames(fdBase)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	if len(fds) > max {
+		fds, truncated = fds[:max], true
+	}
+	var statT syscall.Stat_t
+	for _, fd := range fds {
+		if err := fs.Stat(path.Join(fdBase, fd), &statT); err != nil {
+			continue
+		}
+		if statT.Mode&syscall.S_IFMT == syscall.S_IFSOCK {
+			sockets++
+		}
+	}
+	return sockets, len(fds), truncated, nil
+}
+
 // IsProcInAccept returns true if the process has a at least one thread
 // blocked on the accept() system call
 func IsProcInAccept(procRoot, pid string) (ret bool) {
@@ -198,17 +243,40 @@ func IsProcInAccept(procRoot, pid string) (ret bool) {
 // and marshalls the files into instances of Process, which it then
 // passes one-by-one to the supplied function. Walk is only made public
 // so that is can be tested.
-func (w *walker) Walk(f func(Process, Process)) error {
+func (w *walker) Walk(ctx context.Context, f func(Process, Process)) error {
 	dirEntries, err := fs.ReadDirNames(w.procRoot)
 	if err != nil {
 		return err
 	}
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
 
+	type entry struct {
+		pid      int
+		filename string
+	}
+	var entries []entry
 	for _, filename := range dirEntries {
-		pid, err := strconv.Atoi(filename)
-		if err != nil {
-			continue
+		if pid, err := strconv.Atoi(filename); err == nil {
+			entries = append(entries, entry{pid, filename})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].pid < entries[j].pid })
+	if w.maxSocketScanFDs > 0 {
+		// Spend the budget on the processes left uncounted by the
+		// previous walk first, so that those late in the walk aren't
+		// starved by those before them
+		start := sort.Search(len(entries), func(i int) bool { return entries[i].pid >= w.socketScanFrom })
+		entries = append(entries[start:len(entries):len(entries)], entries[:start]...)
+	}
+	socketScanBudget, socketScanFrom := w.maxSocketScanFDs, 0
+	defer func() { w.socketScanFrom = socketScanFrom }()
+
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
 		}
+		pid, filename := e.pid, e.filename
 
 		ppid, threads, jiffies, rss, rssLimit, err := readStats(path.Join(w.procRoot, filename, "stat"))
 		if err != nil {
@@ -248,6 +316,20 @@ func (w *walker) Walk(f func(Process, Process)) error {
 			isWaitingInAccept = IsProcInAccept(w.procRoot, filename)
 		}
 
+		var (
+			openSocketsCount int
+			socketsCounted   bool
+			socketsTruncated bool
+		)
+		if w.maxSocketScanFDs > 0 && socketScanBudget > 0 {
+			var scanned int
+			openSocketsCount, scanned, socketsTruncated, err = w.countSockets(filename, socketScanBudget)
+			socketScanBudget -= scanned
+			socketsCounted = err == nil
+		} else if w.maxSocketScanFDs > 0 && socketScanFrom == 0 {
+			socketScanFrom = pid
+		}
+
 		f(Process{
 			PID:               pid,
 			PPID:              ppid,
@@ -260,6 +342,9 @@ func (w *walker) Walk(f func(Process, Process)) error {
 			OpenFilesCount:    openFilesCount,
 			OpenFilesLimit:    openFilesLimit,
 			IsWaitingInAccept: isWaitingInAccept,
+			OpenSocketsCount:  openSocketsCount,
+			SocketsCounted:    socketsCounted,
+			SocketsTruncated:  socketsTruncated,
 		}, Process{})
 	}
 
@@ -1,8 +1,10 @@
 package process_test
 
 import (
+	"context"
 	"os"
 	"reflect"
+	"syscall"
 	"testing"
 
 	fs_hook "github.com/weaveworks/common/fs"
@@ -92,7 +94,7 @@ func TestWalker(t *testing.T) {
 
 	have := map[int]process.Process{}
 	walker := process.NewWalker("/proc", false)
-	err := walker.Walk(func(p, _ process.Process) {
+	err := walker.Walk(context.Background(), func(p, _ process.Process) {
 		have[p.PID] = p
 	})
 
@@ -100,3 +102,60 @@ func TestWalker(t *testing.T) {
 		t.Errorf("%v (%v)", test.Diff(want, have), err)
 	}
 }
+
+func TestSocketCountingWalker(t *testing.T) {
+	socket := func(name string) fs.File {
+		return fs.File{FName: name, FStat: syscall.Stat_t{Mode: syscall.S_IFSOCK}}
+	}
+	proc := func(pid string, fds ...fs.Entry) fs.Entry {
+		return fs.Dir(pid,
+			fs.File{FName: "cmdline", FContents: "server"},
+			fs.File{FName: "stat", FContents: pid + " na R 1 0 0 0 0 0 0 0 0 0 0 0 0 0 0 0 1 0 0 0 0 0"},
+			fs.File{FName: "limits", FContents: ""},
+			fs.Dir("fd", fds...),
+		)
+	}
+	fs_hook.Mock(fs.Dir("",
+		fs.Dir("proc",
+			proc("1", fs.File{FName: "0"}, socket("1"), socket("2")),
+			// Doesn't fit in what is left of the budget
+			proc("2", socket("0"), socket("1"), socket("2")),
+			// Never fits in the budget
+			proc("3", socket("0"), socket("1"), socket("2"), socket("3"), socket("4")),
+		),
+	))
+	defer fs_hook.Restore()
+
+	walker := process.NewSocketCountingWalker("/proc", false, 4)
+	walk := func() map[int]process.Process {
+		have := map[int]process.Process{}
+		if err := walker.Walk(context.Background(), func(p, _ process.Process) {
+			have[p.PID] = p
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return have
+	}
+	have := walk()
+	if p := have[1]; !p.SocketsCounted || p.SocketsTruncated || p.OpenSocketsCount != 2 {
+		t.Errorf("expected 2 sockets for pid 1, got %+v", p)
+	}
+	if p := have[2]; !p.SocketsCounted || !p.SocketsTruncated || p.OpenSocketsCount != 1 {
+		t.Errorf("expected 1 socket for pid 2, truncated, got %+v", p)
+	}
+	if p := have[3]; p.SocketsCounted {
+		t.Errorf("expected no socket count for pid 3, got %+v", p)
+	}
+
+	// The next walk starts with the process left uncounted
+	have = walk()
+	if p := have[3]; !p.SocketsCounted || !p.SocketsTruncated || p.OpenSocketsCount != 4 {
+		t.Errorf("expected 4 sockets for pid 3, truncated, got %+v", p)
+	}
+	if p := have[1]; p.SocketsCounted {
+		t.Errorf("expected no socket count for pid 1, got %+v", p)
+	}
+	if p := walk()[1]; !p.SocketsCounted || p.OpenSocketsCount != 2 {
+		t.Errorf("expected 2 sockets for pid 1 in the walk after, got %+v", p)
+	}
+}
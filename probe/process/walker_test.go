@@ -1,6 +1,7 @@
 package process_test
 
 import (
+	"context"
 	"reflect"
 	"testing"
 
@@ -13,7 +14,7 @@ func TestBasicWalk(t *testing.T) {
 		procRoot = "/proc"
 		procFunc = func(process.Process, process.Process) {}
 	)
-	if err := process.NewWalker(procRoot, false).Walk(procFunc); err != nil {
+	if err := process.NewWalker(procRoot, false).Walk(context.Background(), procFunc); err != nil {
 		t.Fatal(err)
 	}
 }
@@ -29,7 +30,7 @@ func TestCache(t *testing.T) {
 		processes: processes,
 	}
 	cachingWalker := process.NewCachingWalker(walker)
-	err := cachingWalker.Tick()
+	err := cachingWalker.Tick(context.Background())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -46,7 +47,7 @@ func TestCache(t *testing.T) {
 		t.Errorf("%v (%v)", test.Diff(want, have), err)
 	}
 
-	err = cachingWalker.Tick()
+	err = cachingWalker.Tick(context.Background())
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -60,8 +61,23 @@ func TestCache(t *testing.T) {
 
 func all(w process.Walker) (map[process.Process]struct{}, error) {
 	all := map[process.Process]struct{}{}
-	err := w.Walk(func(p, _ process.Process) {
+	err := w.Walk(context.Background(), func(p, _ process.Process) {
 		all[p] = struct{}{}
 	})
 	return all, err
 }
+
+func TestCacheCancelled(t *testing.T) {
+	cachingWalker := process.NewCachingWalker(&mockWalker{processes: []process.Process{{PID: 1, Name: "init"}}})
+	if err := cachingWalker.Tick(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := cachingWalker.Tick(ctx); err != context.Canceled {
+		t.Errorf("expected ticking with a cancelled context to fail, got %v", err)
+	}
+	if err := cachingWalker.Walk(ctx, func(process.Process, process.Process) {}); err != context.Canceled {
+		t.Errorf("expected walking with a cancelled context to fail, got %v", err)
+	}
+}
@@ -0,0 +1,190 @@
+package probe
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// Redacted replaces the parts of values masked by redaction rules.
+const Redacted = "[REDACTED]"
+
+// RedactionRule drops or masks the metadata of the nodes of a topology
+// (or of every topology, for "*") whose keys match Key. Drop rules drop
+// whole entries, or only those whose values match Value if given; mask
+// rules replace the parts of values matching Value with Redacted.
+type RedactionRule struct {
+	Topology string
+	Mask     bool
+	Key      *regexp.Regexp
+	Value    *regexp.Regexp
+}
+
+func (r RedactionRule) redact(key, value string) (string, bool) {
+	if !r.Key.MatchString(key) {
+		return value, true
+	}
+	if r.Mask {
+		return r.Value.ReplaceAllString(value, Redacted), true
+	}
+	return value, r.Value != nil && !r.Value.MatchString(value)
+}
+
+// RedactionRules redact the metadata of reports before the probe
+// publishes them, so that secrets never leave the host. Rules are
+// written one per line, as "<topology>: drop <key regexp> [<value
+// regexp>]" or "<topology>: mask <key regexp> <value regexp>", e.g.
+// "container: drop ^docker_env_AWS_SECRET" or
+// "process: mask ^cmdline$ \b(?:\d[ -]?){13,16}\b". Regexps can't
+// contain spaces; use \s. Blank lines and lines starting with '#' are
+// ignored.
+type RedactionRules []RedactionRule
+
+// LoadRedactionRules reads redaction rules from the file at path.
+func LoadRedactionRules(path string) (RedactionRules, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseRedactionRules(f)
+}
+
+// ParseRedactionRules reads redaction rules from r.
+func ParseRedactionRules(r io.Reader) (RedactionRules, error) {
+	var (
+		rules   RedactionRules
+		scanner = bufio.NewScanner(r)
+		line    = 0
+	)
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		rule, err := parseRedactionRule(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, scanner.Err()
+}
+
+func parseRedactionRule(text string) (RedactionRule, error) {
+	parts := strings.SplitN(text, ":", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+		return RedactionRule{}, fmt.Errorf("expected <topology>: <drop|mask> <key> [<value>]")
+	}
+	rule := RedactionRule{Topology: strings.TrimSpace(parts[0])}
+	fields := strings.Fields(parts[1])
+	if len(fields) < 2 || len(fields) > 3 {
+		return RedactionRule{}, fmt.Errorf("expected <drop|mask> <key> [<value>]")
+	}
+	switch fields[0] {
+	case "drop":
+	case "mask":
+		if len(fields) != 3 {
+			return RedactionRule{}, fmt.Errorf("mask rules need a value regexp")
+		}
+		rule.Mask = true
+	default:
+		return RedactionRule{}, fmt.Errorf("unknown action %q", fields[0])
+	}
+	var err error
+	if rule.Key, err = regexp.Compile(fields[1]); err != nil {
+		return RedactionRule{}, err
+	}
+	if len(fields) == 3 {
+		if rule.Value, err = regexp.Compile(fields[2]); err != nil {
+			return RedactionRule{}, err
+		}
+	}
+	return rule, nil
+}
+
+// Redact applies the rules to the latest metadata and the sets of the
+// nodes of the report. The nodes of the report are left untouched, as
+// callers of Publish may still hold them.
+func (rs RedactionRules) Redact(rpt report.Report) report.Report {
+	rpt.WalkNamedTopologies(func(name string, topology *report.Topology) {
+		var rules RedactionRules
+		for _, rule := range rs {
+			if rule.Topology == "*" || rule.Topology == name {
+				rules = append(rules, rule)
+			}
+		}
+		if len(rules) == 0 {
+			return
+		}
+		nodes := make(report.Nodes, len(topology.Nodes))
+		for id, node := range topology.Nodes {
+			node.Latest = rules.redactLatest(node.Latest)
+			node.Sets = rules.redactSets(node.Sets)
+			nodes[id] = node
+		}
+		topology.Nodes = nodes
+	})
+	return rpt
+}
+
+func (rs RedactionRules) apply(key, value string) (string, bool) {
+	for _, rule := range rs {
+		var keep bool
+		if value, keep = rule.redact(key, value); !keep {
+			return "", false
+		}
+	}
+	return value, true
+}
+
+func (rs RedactionRules) redactLatest(latest report.StringLatestMap) report.StringLatestMap {
+	var (
+		result  = report.MakeStringLatestMap()
+		changed = false
+	)
+	latest.ForEach(func(key string, ts time.Time, value string) {
+		redacted, keep := rs.apply(key, value)
+		if !keep {
+			changed = true
+			return
+		}
+		changed = changed || redacted != value
+		result = result.Set(key, ts, redacted)
+	})
+	if !changed {
+		return latest
+	}
+	return result
+}
+
+func (rs RedactionRules) redactSets(sets report.Sets) report.Sets {
+	for _, key := range sets.Keys() {
+		set, _ := sets.Lookup(key)
+		var (
+			redacted = make([]string, 0, len(set))
+			changed  = false
+		)
+		for _, value := range set {
+			r, keep := rs.apply(key, value)
+			if keep {
+				redacted = append(redacted, r)
+			}
+			changed = changed || !keep || r != value
+		}
+		if changed {
+			sets = sets.Delete(key)
+			if len(redacted) > 0 {
+				sets = sets.Add(key, report.MakeStringSet(redacted...))
+			}
+		}
+	}
+	return sets
+}
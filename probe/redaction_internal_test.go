@@ -0,0 +1,82 @@
+package probe
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/report"
+)
+
+const testRedactionRules = `
+# Secrets in the environment of containers
+container: drop ^docker_env_AWS_SECRET
+*: drop ^token$ ^secret-
+process: mask ^cmdline$ \b(?:\d[\s-]?){13,16}\b
+`
+
+func TestParseRedactionRules(t *testing.T) {
+	rules, err := ParseRedactionRules(strings.NewReader(testRedactionRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 3 || rules[0].Topology != "container" || rules[0].Mask || rules[0].Value != nil ||
+		rules[1].Topology != "*" || rules[1].Value == nil || !rules[2].Mask {
+		t.Errorf("unexpected rules: %+v", rules)
+	}
+	for _, invalid := range []string{
+		"drop ^token$",
+		"process: mask ^cmdline$",
+		"process: hide ^cmdline$",
+		"process: drop (",
+		"process: drop a b c",
+	} {
+		if _, err := ParseRedactionRules(strings.NewReader(invalid)); err == nil {
+			t.Errorf("expected an error for %q", invalid)
+		}
+	}
+}
+
+func TestRedact(t *testing.T) {
+	rules, err := ParseRedactionRules(strings.NewReader(testRedactionRules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	rpt := report.MakeReport()
+	container := report.MakeNodeWith("container1", map[string]string{
+		"docker_env_AWS_SECRET_ACCESS_KEY": "hunter2",
+		"docker_env_PATH":                  "/bin",
+		"token":                            "secret-1",
+	}).WithSets(report.MakeSets().Add("token", report.MakeStringSet("public", "secret-2")))
+	rpt.Container.AddNode(container)
+	process := report.MakeNode("process1").
+		WithLatest("cmdline", now, "pay --card 4111 1111 1111 1111 --amount 10").
+		WithLatest("token", now, "public")
+	rpt.Process.AddNode(process)
+
+	redacted := rules.Redact(rpt)
+	c := redacted.Container.Nodes["container1"]
+	if _, ok := c.Latest.Lookup("docker_env_AWS_SECRET_ACCESS_KEY"); ok {
+		t.Errorf("expected the secret to be dropped")
+	}
+	if _, ok := c.Latest.Lookup("token"); ok {
+		t.Errorf("expected the secret token to be dropped")
+	}
+	if path, _ := c.Latest.Lookup("docker_env_PATH"); path != "/bin" {
+		t.Errorf("expected PATH to be kept, got %q", path)
+	}
+	if tokens, _ := c.Sets.Lookup("token"); !tokens.Equal(report.MakeStringSet("public")) {
+		t.Errorf("expected only the public token to be kept, got %v", tokens)
+	}
+	p := redacted.Process.Nodes["process1"]
+	if cmdline, _ := p.Latest.Lookup("cmdline"); cmdline != "pay --card "+Redacted+" --amount 10" {
+		t.Errorf("expected the card number to be masked, got %q", cmdline)
+	}
+	if token, _ := p.Latest.Lookup("token"); token != "public" {
+		t.Errorf("expected the public token to be kept, got %q", token)
+	}
+	if cmdline, _ := rpt.Process.Nodes["process1"].Latest.Lookup("cmdline"); !strings.Contains(cmdline, "4111") {
+		t.Errorf("expected the original report to be left untouched")
+	}
+}
@@ -0,0 +1,68 @@
+package snmp
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/soniah/gosnmp"
+)
+
+const maxRepetitions = 32
+
+// agent is what the poller queries of an SNMP agent
+type agent interface {
+	Get(oids []string) (*gosnmp.SnmpPacket, error)
+	BulkWalk(root string, f gosnmp.WalkFunc) error
+}
+
+// Client queries an SNMPv2c agent
+type Client struct {
+	Address string
+	snmp    *gosnmp.GoSNMP
+}
+
+// NewClient makes a new Client of the agent at address (host:port)
+func NewClient(address, community string, timeout time.Duration) *Client {
+	host, port, _ := net.SplitHostPort(address)
+	portNumber, _ := strconv.Atoi(port)
+	return &Client{
+		Address: address,
+		snmp: &gosnmp.GoSNMP{
+			Target:         host,
+			Port:           uint16(portNumber),
+			Transport:      "udp",
+			Community:      community,
+			Version:        gosnmp.Version2c,
+			Timeout:        timeout,
+			MaxOids:        gosnmp.MaxOids,
+			MaxRepetitions: maxRepetitions,
+		},
+	}
+}
+
+// Device fetches the system group and interface table of the agent.
+func (c *Client) Device() (Device, error) {
+	if err := c.snmp.Connect(); err != nil {
+		return Device{}, err
+	}
+	defer c.snmp.Conn.Close()
+	return fetchDevice(c.snmp)
+}
+
+// number returns the value of a numeric object, and whether it is one
+func number(pdu gosnmp.SnmpPDU) (uint64, bool) {
+	switch pdu.Type {
+	case gosnmp.Integer, gosnmp.Counter32, gosnmp.Gauge32, gosnmp.TimeTicks, gosnmp.Counter64, gosnmp.Uinteger32:
+		return gosnmp.ToBigInt(pdu.Value).Uint64(), true
+	}
+	return 0, false
+}
+
+// str returns the value of a string object
+func str(pdu gosnmp.SnmpPDU) string {
+	if s, ok := pdu.Value.([]byte); ok {
+		return string(s)
+	}
+	return ""
+}
@@ -0,0 +1,336 @@
+package snmp
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/soniah/gosnmp"
+	"github.com/weaveworks/common/mtime"
+
+	"github.com/weaveworks/scope/common/xlog"
+	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/report"
+)
+
+// log is the logger of the snmp component
+var log = xlog.Component("snmp")
+
+// Keys for use in network device and device interface nodes.
+const (
+	DeviceName        = "network_device_name"
+	DeviceDescription = "network_device_description"
+	DeviceAddress     = "network_device_address"
+	DeviceUptime      = "network_device_uptime"
+	InterfaceStatus   = "interface_status"
+)
+
+// Exposed for testing
+var (
+	DeviceMetadataTemplates = report.MetadataTemplates{
+		DeviceName:        {ID: DeviceName, Label: "Name", From: report.FromLatest, Priority: 1},
+		DeviceAddress:     {ID: DeviceAddress, Label: "Address", From: report.FromLatest, Priority: 2},
+		DeviceUptime:      {ID: DeviceUptime, Label: "Uptime", From: report.FromLatest, Datatype: report.Duration, Priority: 3},
+		DeviceDescription: {ID: DeviceDescription, Label: "Description", From: report.FromLatest, Priority: 4},
+	}
+
+	InterfaceMetadataTemplates = host.InterfaceMetadataTemplates.Merge(report.MetadataTemplates{
+		InterfaceStatus: {ID: InterfaceStatus, Label: "Status", From: report.FromLatest, Priority: 7},
+	})
+)
+
+// Objects of SNMPv2-MIB and IF-MIB
+const (
+	sysDescr  = ".1.3.6.1.2.1.1.1.0"
+	sysUpTime = ".1.3.6.1.2.1.1.3.0"
+	sysName   = ".1.3.6.1.2.1.1.5.0"
+	ifEntry   = ".1.3.6.1.2.1.2.2.1"
+	ifXEntry  = ".1.3.6.1.2.1.31.1.1.1"
+)
+
+// How many polling intervals the last poll of an agent is reported for,
+// once it stops answering
+const sampleExpiry = 3
+
+// Columns of the ifTable
+const (
+	ifDescr     = 2
+	ifType      = 3
+	ifSpeed     = 5
+	ifOperState = 8
+	ifInOctets  = 10
+	ifInErrors  = 14
+	ifOutOctets = 16
+	ifOutErrors = 20
+)
+
+// Columns of the ifXTable
+const (
+	// ifName, the short name switches advertise over LLDP
+	ifName = 1
+	// ifHCInOctets and ifHCOutOctets, the 64 bit octet counters: those of
+	// the ifTable wrap within a minute at 1Gb/s
+	ifHCInOctets  = 6
+	ifHCOutOctets = 10
+)
+
+// ifType values of physical interfaces: ethernetCsmacd, iso88023Csmacd,
+// fastEther, gigabitEthernet and ieee80211
+var physicalInterfaceTypes = map[uint64]bool{6: true, 7: true, 62: true, 117: true, 71: true}
+
+// ifOperStatus values
+var operStatus = map[uint64]string{
+	1: "up", 2: "down", 3: "testing", 4: "unknown", 5: "dormant", 6: "notPresent", 7: "lowerLayerDown",
+}
+
+// Device is what an agent reports of a network device
+type Device struct {
+	Name        string
+	Description string
+	Uptime      time.Duration
+	// Interfaces by ifIndex
+	Interfaces map[int]Interface
+}
+
+// Interface is a row of the ifTable of a device
+type Interface struct {
+	host.InterfaceStats
+	Name   string
+	Status string
+}
+
+// fetchDevice fetches the system group and interface table of an agent.
+func fetchDevice(a agent) (Device, error) {
+	device := Device{Interfaces: map[int]Interface{}}
+	packet, err := a.Get([]string{sysDescr, sysUpTime, sysName})
+	if err != nil {
+		return device, err
+	}
+	if packet.Error != gosnmp.NoError {
+		return device, fmt.Errorf("error status %v at index %d", packet.Error, packet.ErrorIndex)
+	}
+	for _, v := range packet.Variables {
+		switch v.Name {
+		case sysDescr:
+			device.Description = str(v)
+		case sysName:
+			device.Name = str(v)
+		case sysUpTime:
+			ticks, _ := number(v)
+			device.Uptime = time.Duration(ticks) * 10 * time.Millisecond
+		}
+	}
+
+	for _, column := range []int{ifDescr, ifType, ifSpeed, ifOperState, ifInOctets, ifInErrors, ifOutOctets, ifOutErrors} {
+		root := ifEntry + "." + strconv.Itoa(column)
+		if err := a.BulkWalk(root, func(v gosnmp.SnmpPDU) error {
+			index, ok := rowIndex(root, v.Name)
+			if !ok {
+				return nil
+			}
+			iface := device.Interfaces[index]
+			n, _ := number(v)
+			switch column {
+			case ifDescr:
+				iface.Name = str(v)
+			case ifType:
+				iface.Physical = physicalInterfaceTypes[n]
+			case ifSpeed:
+				iface.Speed = int(n / 1e6) // b/s to Mb/s
+			case ifOperState:
+				iface.Status = operStatus[n]
+			case ifInOctets:
+				iface.RxBytes = n
+			case ifInErrors:
+				iface.RxErrors = n
+			case ifOutOctets:
+				iface.TxBytes = n
+			case ifOutErrors:
+				iface.TxErrors = n
+			}
+			device.Interfaces[index] = iface
+			return nil
+		}); err != nil {
+			return device, err
+		}
+	}
+
+	// Prefer the short names, so the ports match those advertised over
+	// LLDP, and the 64 bit counters. Agents without the ifXTable return
+	// none.
+	for _, column := range []int{ifName, ifHCInOctets, ifHCOutOctets} {
+		root := ifXEntry + "." + strconv.Itoa(column)
+		if err := a.BulkWalk(root, func(v gosnmp.SnmpPDU) error {
+			index, ok := rowIndex(root, v.Name)
+			if !ok {
+				return nil
+			}
+			iface, ok := device.Interfaces[index]
+			if !ok {
+				return nil
+			}
+			n, isNumber := number(v)
+			switch {
+			case column == ifName && str(v) != "":
+				iface.Name = str(v)
+			case column == ifHCInOctets && isNumber:
+				iface.RxBytes = n
+			case column == ifHCOutOctets && isNumber:
+				iface.TxBytes = n
+			}
+			device.Interfaces[index] = iface
+			return nil
+		}); err != nil {
+			return device, err
+		}
+	}
+	return device, nil
+}
+
+// rowIndex returns the index of the row of a table column an object is
+// in, unless it is not one of the column.
+func rowIndex(column, oid string) (int, bool) {
+	if !strings.HasPrefix(oid, column+".") {
+		return 0, false
+	}
+	index, err := strconv.Atoi(oid[len(column)+1:])
+	return index, err == nil
+}
+
+type sample struct {
+	at     time.Time
+	device Device
+}
+
+// Poller periodically polls network devices over SNMP, reporting them
+// and their interfaces with the traffic and error rates between the
+// last two polls. Devices which stop answering are reported until their
+// last poll expires.
+type Poller struct {
+	clients  []*Client
+	interval time.Duration
+
+	mtx     sync.Mutex
+	samples map[string][2]*sample
+
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewPoller makes a new Poller, polling every client every interval.
+func NewPoller(clients []*Client, interval time.Duration) *Poller {
+	p := &Poller{
+		clients:  clients,
+		interval: interval,
+		samples:  map[string][2]*sample{},
+		quit:     make(chan struct{}),
+	}
+	for _, c := range clients {
+		p.wg.Add(1)
+		go p.loop(c)
+	}
+	return p
+}
+
+// Name of this reporter, for metrics gathering
+func (*Poller) Name() string { return "SNMP" }
+
+// Topologies of the poller, for annotating them when it fails.
+func (*Poller) Topologies() []string { return []string{report.NetworkDevice, report.NetworkInterface} }
+
+// Stop implements Reporter, stopping the polling.
+func (p *Poller) Stop() {
+	close(p.quit)
+	p.wg.Wait()
+}
+
+func (p *Poller) loop(c *Client) {
+	defer p.wg.Done()
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.poll(c)
+		select {
+		case <-ticker.C:
+		case <-p.quit:
+			return
+		}
+	}
+}
+
+func (p *Poller) poll(c *Client) {
+	device, err := c.Device()
+	if err != nil {
+		log.Warnf("Error polling %s: %v", c.Address, err)
+		return
+	}
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.samples[c.Address] = [2]*sample{p.samples[c.Address][1], {at: mtime.Now(), device: device}}
+}
+
+// Report implements Reporter.
+func (p *Poller) Report(context.Context) (report.Report, error) {
+	rpt := report.MakeReport()
+	rpt.NetworkDevice = rpt.NetworkDevice.WithMetadataTemplates(DeviceMetadataTemplates)
+	rpt.NetworkInterface = rpt.NetworkInterface.
+		WithMetadataTemplates(InterfaceMetadataTemplates).
+		WithMetricTemplates(host.InterfaceMetricTemplates)
+
+	now := mtime.Now()
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	for address, samples := range p.samples {
+		previous, current := samples[0], samples[1]
+		if now.Sub(current.at) > sampleExpiry*p.interval {
+			delete(p.samples, address)
+			continue
+		}
+		name := current.device.Name
+		if name == "" {
+			name = address
+		}
+		deviceNodeID := report.MakeNetworkDeviceNodeID(name)
+		rpt.NetworkDevice.AddNode(report.MakeNodeWith(deviceNodeID, map[string]string{
+			DeviceName:        name,
+			DeviceAddress:     address,
+			DeviceDescription: current.device.Description,
+			DeviceUptime:      strconv.Itoa(int(current.device.Uptime / time.Second)),
+		}))
+
+		for index, iface := range current.device.Interfaces {
+			ifName := iface.Name
+			if ifName == "" {
+				ifName = strconv.Itoa(index)
+			}
+			kind := host.VirtualInterface
+			if iface.Physical {
+				kind = host.PhysicalInterface
+			}
+			latest := map[string]string{
+				host.InterfaceName: ifName,
+				host.InterfaceType: kind,
+			}
+			if iface.Speed > 0 {
+				latest[host.InterfaceSpeed] = strconv.Itoa(iface.Speed)
+			}
+			if iface.Status != "" {
+				latest[InterfaceStatus] = iface.Status
+			}
+			node := report.MakeNodeWith(report.MakeNetworkInterfaceNodeID(name, ifName), latest).
+				WithParent(report.NetworkDevice, deviceNodeID)
+			if previous != nil {
+				if prev, ok := previous.device.Interfaces[index]; ok {
+					if elapsed := current.at.Sub(previous.at).Seconds(); elapsed > 0 {
+						node = host.WithInterfaceRates(node, prev.InterfaceStats, iface.InterfaceStats, current.at, elapsed)
+					}
+				}
+			}
+			rpt.NetworkInterface.AddNode(node)
+		}
+	}
+	return rpt, nil
+}
@@ -0,0 +1,157 @@
+package snmp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/soniah/gosnmp"
+
+	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/report"
+)
+
+// fakeAgent serves a fixed set of objects, as decoded by gosnmp.
+type fakeAgent []gosnmp.SnmpPDU
+
+func (a fakeAgent) Get(oids []string) (*gosnmp.SnmpPacket, error) {
+	packet := &gosnmp.SnmpPacket{}
+	for _, oid := range oids {
+		v := gosnmp.SnmpPDU{Name: oid, Type: gosnmp.NoSuchObject}
+		for _, object := range a {
+			if object.Name == oid {
+				v = object
+			}
+		}
+		packet.Variables = append(packet.Variables, v)
+	}
+	return packet, nil
+}
+
+func (a fakeAgent) BulkWalk(root string, f gosnmp.WalkFunc) error {
+	for _, object := range a {
+		if strings.HasPrefix(object.Name, root+".") {
+			if err := f(object); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func column(c, index int) string {
+	return fmt.Sprintf("%s.%d.%d", ifEntry, c, index)
+}
+
+func xColumn(c, index int) string {
+	return fmt.Sprintf("%s.%d.%d", ifXEntry, c, index)
+}
+
+// switchObjects are those of a switch, whose first interface has the 64
+// bit counters of the ifXTable, received those many octets beyond those
+// counted in 32 bits.
+func switchObjects(inOctets uint) fakeAgent {
+	objects := fakeAgent{
+		{Name: sysDescr, Type: gosnmp.OctetString, Value: []byte("Test switch")},
+		{Name: sysUpTime, Type: gosnmp.TimeTicks, Value: uint(360000)},
+		{Name: sysName, Type: gosnmp.OctetString, Value: []byte("switch1")},
+		// The short name of the first interface, in the ifXTable
+		{Name: xColumn(ifName, 1), Type: gosnmp.OctetString, Value: []byte("Gi0/1")},
+		{Name: xColumn(ifHCInOctets, 1), Type: gosnmp.Counter64, Value: 1<<32 + uint64(inOctets)},
+		{Name: xColumn(ifHCOutOctets, 1), Type: gosnmp.Counter64, Value: uint64(0)},
+	}
+	for index, name := range map[int]string{1: "GigabitEthernet0/1", 2: "Vlan1"} {
+		kind := 6 // ethernetCsmacd
+		if name == "Vlan1" {
+			kind = 53 // propVirtual
+		}
+		objects = append(objects,
+			gosnmp.SnmpPDU{Name: column(ifDescr, index), Type: gosnmp.OctetString, Value: []byte(name)},
+			gosnmp.SnmpPDU{Name: column(ifType, index), Type: gosnmp.Integer, Value: kind},
+			gosnmp.SnmpPDU{Name: column(ifSpeed, index), Type: gosnmp.Gauge32, Value: uint(1000000000)},
+			gosnmp.SnmpPDU{Name: column(ifOperState, index), Type: gosnmp.Integer, Value: 1},
+			gosnmp.SnmpPDU{Name: column(ifInOctets, index), Type: gosnmp.Counter32, Value: inOctets},
+			gosnmp.SnmpPDU{Name: column(ifOutOctets, index), Type: gosnmp.Counter32, Value: uint(0)},
+			gosnmp.SnmpPDU{Name: column(ifInErrors, index), Type: gosnmp.Counter32, Value: uint(0)},
+			gosnmp.SnmpPDU{Name: column(ifOutErrors, index), Type: gosnmp.Counter32, Value: uint(0)},
+		)
+	}
+	return objects
+}
+
+func TestPoller(t *testing.T) {
+	const address = "10.0.0.1:161"
+	device, err := fetchDevice(switchObjects(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.Name != "switch1" || device.Uptime != time.Hour || len(device.Interfaces) != 2 {
+		t.Fatalf("unexpected device: %+v", device)
+	}
+	if iface := device.Interfaces[1]; iface.Name != "Gi0/1" || !iface.Physical || iface.Speed != 1000 || iface.Status != "up" || iface.RxBytes != 1<<32+1000 {
+		t.Errorf("unexpected interface: %+v", iface)
+	}
+
+	// Poll twice, 10s apart, to get rates
+	p := &Poller{interval: 10 * time.Second, samples: map[string][2]*sample{}}
+	now := time.Now()
+	p.samples[address] = [2]*sample{{at: now.Add(-10 * time.Second), device: device}, nil}
+	if device, err = fetchDevice(switchObjects(6000)); err != nil {
+		t.Fatal(err)
+	}
+	p.samples[address] = [2]*sample{p.samples[address][0], {at: now, device: device}}
+
+	rpt, err := p.Report(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	deviceNodeID := report.MakeNetworkDeviceNodeID("switch1")
+	if have, _ := rpt.NetworkDevice.Nodes[deviceNodeID].Latest.Lookup(DeviceAddress); have != address {
+		t.Errorf("expected device at %s, got %q", address, have)
+	}
+	iface, ok := rpt.NetworkInterface.Nodes[report.MakeNetworkInterfaceNodeID("switch1", "Gi0/1")]
+	if !ok {
+		t.Fatalf("interface missing: %v", rpt.NetworkInterface.Nodes)
+	}
+	if parents, _ := iface.Parents.Lookup(report.NetworkDevice); len(parents) != 1 || parents[0] != deviceNodeID {
+		t.Errorf("expected device parent, got %v", parents)
+	}
+	if last, ok := iface.Metrics[host.InterfaceRxBytes].LastSample(); !ok || last.Value != 500 {
+		t.Errorf("expected 500 B/s received, got %v", iface.Metrics)
+	}
+	// Interfaces without the 64 bit counters have those of 32 bits
+	if vlan := device.Interfaces[2]; vlan.RxBytes != 6000 {
+		t.Errorf("expected the 32 bit counters of the second interface, got %+v", vlan)
+	}
+}
+
+func TestPollerExpiresSamples(t *testing.T) {
+	device, err := fetchDevice(switchObjects(1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Poller{interval: 10 * time.Second, samples: map[string][2]*sample{}}
+	p.samples["10.0.0.1:161"] = [2]*sample{nil, {at: time.Now().Add(-time.Minute), device: device}}
+
+	rpt, err := p.Report(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rpt.NetworkDevice.Nodes) != 0 || len(p.samples) != 0 {
+		t.Errorf("expected the device which stopped answering to expire, got %v", rpt.NetworkDevice.Nodes)
+	}
+}
+
+func TestParseTarget(t *testing.T) {
+	for target, want := range map[string][2]string{
+		"switch1":               {"public", "switch1:161"},
+		"private@10.0.0.1:1161": {"private", "10.0.0.1:1161"},
+		"[fe80::1]":             {"public", "[fe80::1]:161"},
+	} {
+		if community, address := ParseTarget(target); community != want[0] || address != want[1] {
+			t.Errorf("%s: got %s, %s", target, community, address)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package snmp
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/weaveworks/scope/probe"
+)
+
+func init() {
+	probe.RegisterSource(&source{})
+}
+
+// targets is a flag.Value collecting the agents to poll, one per use of
+// the flag.
+type targets []string
+
+func (t *targets) String() string { return strings.Join(*t, " ") }
+
+// Set implements flag.Value
+func (t *targets) Set(value string) error {
+	*t = append(*t, value)
+	return nil
+}
+
+// source adds the SNMP poller to the probe when targets are configured
+// by flags
+type source struct {
+	targets  targets
+	interval time.Duration
+	timeout  time.Duration
+}
+
+func (s *source) Name() string { return "SNMP" }
+
+func (s *source) RegisterFlags(fs *flag.FlagSet) {
+	fs.Var(&s.targets, "probe.snmp.target", "network device to poll over SNMPv2c, as [community@]host[:port] (may be repeated)")
+	fs.DurationVar(&s.interval, "probe.snmp.interval", 30*time.Second, "how often to poll network devices")
+	fs.DurationVar(&s.timeout, "probe.snmp.timeout", 5*time.Second, "timeout of SNMP requests")
+}
+
+func (s *source) Enabled() bool { return len(s.targets) > 0 }
+
+func (s *source) Make(probe.Env) ([]interface{}, error) {
+	if s.interval <= 0 {
+		return nil, fmt.Errorf("invalid polling interval %v", s.interval)
+	}
+	var clients []*Client
+	for _, target := range s.targets {
+		community, address := ParseTarget(target)
+		clients = append(clients, NewClient(address, community, s.timeout))
+	}
+	return []interface{}{NewPoller(clients, s.interval)}, nil
+}
+
+// ParseTarget splits a target of the form [community@]host[:port] into
+// its community and address, defaulting to the public community and
+// port 161.
+func ParseTarget(target string) (community, address string) {
+	community, address = "public", target
+	if i := strings.LastIndexByte(target, '@'); i >= 0 {
+		community, address = target[:i], target[i+1:]
+	}
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(strings.Trim(address, "[]"), "161")
+	}
+	return community, address
+}
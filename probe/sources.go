@@ -0,0 +1,98 @@
+package probe
+
+import (
+	"flag"
+	"sync"
+
+	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/probe/controls"
+)
+
+// Env is what the probe gives sources to build their components.
+type Env struct {
+	HostID          string
+	HostName        string
+	ProbeID         string
+	Probe           *Probe
+	HandlerRegistry *controls.HandlerRegistry
+	// ProcRoot is the location of the proc filesystem
+	ProcRoot string
+	// Peers returns the hosts the apps ask the probe to measure the
+	// latency to. It is nil without apps.
+	Peers func() []xfer.Peer
+}
+
+// Source is a self-contained source of topology. Sources register
+// themselves with RegisterSource, usually in an init function, so that
+// adding one only takes importing its package.
+type Source interface {
+	Name() string
+	// RegisterFlags registers the flags configuring the source.
+	RegisterFlags(fs *flag.FlagSet)
+	// Enabled is whether the flags enable the source.
+	Enabled() bool
+	// Make builds the tickers, reporters and taggers of the source.
+	Make(env Env) ([]interface{}, error)
+}
+
+var (
+	sourcesMtx sync.Mutex
+	sources    []Source
+)
+
+// RegisterSource registers a source, to be added to probes by AddSources.
+func RegisterSource(s Source) {
+	sourcesMtx.Lock()
+	defer sourcesMtx.Unlock()
+	sources = append(sources, s)
+}
+
+// RegisterSourceFlags registers the flags of every registered source.
+func RegisterSourceFlags(fs *flag.FlagSet) {
+	sourcesMtx.Lock()
+	defer sourcesMtx.Unlock()
+	for _, s := range sources {
+		s.RegisterFlags(fs)
+	}
+}
+
+// AddSources builds the enabled sources, and adds their components to
+// the probe. Sources which fail to build are logged and skipped.
+func (p *Probe) AddSources(env Env) {
+	sourcesMtx.Lock()
+	defer sourcesMtx.Unlock()
+	for _, s := range sources {
+		if !s.Enabled() {
+			continue
+		}
+		components, err := s.Make(env)
+		if err != nil {
+			log.Errorf("%s: failed to start: %v", s.Name(), err)
+			continue
+		}
+		p.Add(components...)
+	}
+}
+
+// Add adds each component to the probe as a Ticker, Reporter and
+// Tagger, depending on which of those interfaces it implements.
+func (p *Probe) Add(components ...interface{}) {
+	for _, c := range components {
+		added := false
+		if t, ok := c.(Ticker); ok {
+			p.AddTicker(t)
+			added = true
+		}
+		if r, ok := c.(Reporter); ok {
+			p.AddReporter(r)
+			added = true
+		}
+		if t, ok := c.(Tagger); ok {
+			p.AddTagger(t)
+			added = true
+		}
+		if !added {
+			log.Errorf("%T is not a ticker, reporter or tagger", c)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package probe
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"testing"
+
+	"github.com/weaveworks/scope/report"
+)
+
+type fakeSource struct {
+	name    string
+	enabled bool
+	err     error
+	made    []interface{}
+}
+
+func (s *fakeSource) Name() string { return s.name }
+func (s *fakeSource) RegisterFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&s.enabled, "probe."+s.name, false, "enable "+s.name)
+}
+func (s *fakeSource) Enabled() bool                   { return s.enabled }
+func (s *fakeSource) Make(Env) ([]interface{}, error) { return s.made, s.err }
+
+type stoppingReporter struct {
+	mockReporter
+	stopped *bool
+}
+
+func (r stoppingReporter) Stop() { *r.stopped = true }
+
+type reportingTagger struct{ mockReporter }
+
+func (reportingTagger) Tag(_ context.Context, r report.Report) (report.Report, error) { return r, nil }
+
+func TestSources(t *testing.T) {
+	defer func(saved []Source) { sources = saved }(sources)
+	sources = nil
+
+	stopped := false
+	enabled := &fakeSource{name: "enabled", made: []interface{}{
+		stoppingReporter{mockReporter{report.MakeReport()}, &stopped},
+		reportingTagger{mockReporter{report.MakeReport()}},
+	}}
+	disabled := &fakeSource{name: "disabled", made: []interface{}{mockReporter{report.MakeReport()}}}
+	failing := &fakeSource{name: "failing", err: fmt.Errorf("no socket")}
+	for _, s := range []*fakeSource{enabled, disabled, failing} {
+		RegisterSource(s)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	RegisterSourceFlags(fs)
+	if err := fs.Parse([]string{"-probe.enabled", "-probe.failing"}); err != nil {
+		t.Fatal(err)
+	}
+
+	p := New(0, 0, nil, false)
+	p.AddSources(Env{Probe: p})
+	if len(p.reporters) != 2 || len(p.taggers) != 1 {
+		t.Errorf("expected 2 reporters and 1 tagger, got %v and %v", p.reporters, p.taggers)
+	}
+
+	p.Stop()
+	if !stopped {
+		t.Errorf("expected the reporter to be stopped with the probe")
+	}
+}
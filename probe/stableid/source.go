@@ -0,0 +1,28 @@
+package stableid
+
+import (
+	"flag"
+
+	"github.com/weaveworks/scope/probe"
+)
+
+func init() {
+	probe.RegisterSource(&source{})
+}
+
+// source adds the stable ID tagger to the probe when enabled by flags
+type source struct {
+	enabled bool
+}
+
+func (s *source) Name() string { return "Stable IDs" }
+
+func (s *source) RegisterFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&s.enabled, "probe.stable-ids.enabled", true, "tag containers, pods and processes with IDs which survive their restarts, from their compose service, pod or systemd unit")
+}
+
+func (s *source) Enabled() bool { return s.enabled }
+
+func (s *source) Make(env probe.Env) ([]interface{}, error) {
+	return []interface{}{NewTagger(env.HostID, env.ProcRoot)}, nil
+}
@@ -0,0 +1,159 @@
+package stableid
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/weaveworks/common/mtime"
+
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/report"
+)
+
+// Labels of the containers identifying them across restarts
+const (
+	composeProject = docker.LabelPrefix + "com.docker.compose.project"
+	composeService = docker.LabelPrefix + "com.docker.compose.service"
+	composeNumber  = docker.LabelPrefix + "com.docker.compose.container-number"
+	podNamespace   = docker.LabelPrefix + "io.kubernetes.pod.namespace"
+	podName        = docker.LabelPrefix + "io.kubernetes.pod.name"
+	podContainer   = docker.LabelPrefix + "io.kubernetes.container.name"
+)
+
+// Tagger tags the nodes of containers, pods and processes with a
+// report.StableID, which stays the same when they restart with a new ID:
+//
+//   - containers of compose services by host, project, service and
+//     container number
+//   - containers of pods by the namespace and name of the pod and the
+//     name of the container, and pods by their namespace and name
+//   - the main processes of systemd services by host and unit
+type Tagger struct {
+	hostID   string
+	procRoot string
+
+	mtx   sync.Mutex
+	units map[string]string // by PID
+}
+
+// NewTagger makes a new Tagger, looking up the systemd units of
+// processes under procRoot.
+func NewTagger(hostID, procRoot string) *Tagger {
+	return &Tagger{hostID: hostID, procRoot: procRoot, units: map[string]string{}}
+}
+
+// Name of this tagger, for metrics gathering
+func (*Tagger) Name() string { return "Stable IDs" }
+
+// Tag implements Tagger.
+func (t *Tagger) Tag(_ context.Context, r report.Report) (report.Report, error) {
+	now := mtime.Now()
+	for id, n := range r.Container.Nodes {
+		if stableID, ok := t.containerID(n); ok {
+			r.Container.Nodes[id] = n.WithLatest(report.StableID, now, stableID)
+		}
+	}
+	for id, n := range r.Pod.Nodes {
+		namespace, ok1 := n.Latest.Lookup(report.KubernetesNamespace)
+		name, ok2 := n.Latest.Lookup(report.KubernetesName)
+		if ok1 && ok2 {
+			r.Pod.Nodes[id] = n.WithLatest(report.StableID, now, "k8s/"+namespace+"/"+name)
+		}
+	}
+	t.tagProcesses(&r.Process, now)
+	return r, nil
+}
+
+func (t *Tagger) containerID(n report.Node) (string, bool) {
+	if project, ok := n.Latest.Lookup(composeProject); ok {
+		service, _ := n.Latest.Lookup(composeService)
+		number, _ := n.Latest.Lookup(composeNumber)
+		return "compose/" + t.hostID + "/" + project + "/" + service + "/" + number, true
+	}
+	if name, ok := n.Latest.Lookup(podName); ok {
+		namespace, _ := n.Latest.Lookup(podNamespace)
+		container, _ := n.Latest.Lookup(podContainer)
+		return "k8s/" + namespace + "/" + name + "/" + container, true
+	}
+	return "", false
+}
+
+// tagProcesses tags the main processes of systemd services, those whose
+// parent is not of the service, remembering the units of processes for
+// as long as they run. The workers a main process forks come and go, so
+// they are left alone. Services of several main processes have them told
+// apart by name.
+func (t *Tagger) tagProcesses(topology *report.Topology, now time.Time) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	seen := map[string]struct{}{}
+	for _, n := range topology.Nodes {
+		pid, ok := n.Latest.Lookup(report.PID)
+		if !ok {
+			continue
+		}
+		seen[pid] = struct{}{}
+		if _, ok := t.units[pid]; !ok {
+			t.units[pid] = systemdUnit(filepath.Join(t.procRoot, pid, "cgroup"))
+		}
+	}
+	for pid := range t.units {
+		if _, ok := seen[pid]; !ok {
+			delete(t.units, pid)
+		}
+	}
+
+	mains := map[string][]string{} // node IDs, by unit
+	for id, n := range topology.Nodes {
+		pid, _ := n.Latest.Lookup(report.PID)
+		unit := t.units[pid]
+		if unit == "" {
+			continue
+		}
+		if ppid, ok := n.Latest.Lookup(report.PPID); ok && t.units[ppid] == unit {
+			continue
+		}
+		mains[unit] = append(mains[unit], id)
+	}
+	for unit, ids := range mains {
+		for _, id := range ids {
+			n := topology.Nodes[id]
+			stableID := "systemd/" + t.hostID + "/" + unit
+			if len(ids) > 1 {
+				name, _ := n.Latest.Lookup(report.Name)
+				stableID += "/" + name
+			}
+			topology.Nodes[id] = n.WithLatest(report.StableID, now, stableID)
+		}
+	}
+}
+
+// systemdUnit is the systemd service of a process, from the innermost
+// .service of its cgroups, or "" if the process is not in one.
+func systemdUnit(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	var unit string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// hierarchy-ID:controllers:path
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 || (fields[1] != "" && fields[1] != "name=systemd") {
+			continue
+		}
+		for _, element := range strings.Split(fields[2], "/") {
+			if strings.HasSuffix(element, ".service") {
+				unit = element
+			}
+		}
+	}
+	return unit
+}
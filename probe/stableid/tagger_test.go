@@ -0,0 +1,82 @@
+package stableid_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/probe/stableid"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestTagger(t *testing.T) {
+	procRoot, err := ioutil.TempDir("", "stableid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(procRoot)
+	cgroups := map[string]string{
+		"1": "0::/system.slice/nginx.service\n",
+		"2": "12:cpu:/docker/abc\n1:name=systemd:/system.slice/docker-abc.scope\n",
+		"3": "0::/system.slice/nginx.service\n",
+		"4": "0::/system.slice/cron.service\n",
+		"5": "0::/system.slice/cron.service\n",
+	}
+	for pid, cgroup := range cgroups {
+		os.Mkdir(filepath.Join(procRoot, pid), 0755)
+		if err := ioutil.WriteFile(filepath.Join(procRoot, pid, "cgroup"), []byte(cgroup), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now := time.Now()
+	rpt := report.MakeReport()
+	rpt.Container.AddNode(report.MakeNodeWith("compose", map[string]string{
+		"docker_label_com.docker.compose.project":          "shop",
+		"docker_label_com.docker.compose.service":          "web",
+		"docker_label_com.docker.compose.container-number": "2",
+	}))
+	rpt.Container.AddNode(report.MakeNodeWith("k8s", map[string]string{
+		"docker_label_io.kubernetes.pod.namespace":  "default",
+		"docker_label_io.kubernetes.pod.name":       "db-0",
+		"docker_label_io.kubernetes.container.name": "postgres",
+	}))
+	rpt.Container.AddNode(report.MakeNode("plain").WithLatest("foo", now, "bar"))
+	rpt.Pod.AddNode(report.MakeNodeWith("pod", map[string]string{
+		report.KubernetesNamespace: "default",
+		report.KubernetesName:      "db-0",
+	}))
+	rpt.Process.AddNode(report.MakeNodeWith("host;1", map[string]string{report.PID: "1"}))
+	rpt.Process.AddNode(report.MakeNodeWith("host;2", map[string]string{report.PID: "2"}))
+	// A worker of nginx, and two main processes of cron
+	rpt.Process.AddNode(report.MakeNodeWith("host;3", map[string]string{report.PID: "3", report.PPID: "1"}))
+	rpt.Process.AddNode(report.MakeNodeWith("host;4", map[string]string{report.PID: "4", report.PPID: "2", report.Name: "cron"}))
+	rpt.Process.AddNode(report.MakeNodeWith("host;5", map[string]string{report.PID: "5", report.PPID: "2", report.Name: "anacron"}))
+
+	rpt, err = stableid.NewTagger("host", procRoot).Tag(context.Background(), rpt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		topology report.Topology
+		node     string
+		want     string
+	}{
+		{rpt.Container, "compose", "compose/host/shop/web/2"},
+		{rpt.Container, "k8s", "k8s/default/db-0/postgres"},
+		{rpt.Container, "plain", ""},
+		{rpt.Pod, "pod", "k8s/default/db-0"},
+		{rpt.Process, "host;1", "systemd/host/nginx.service"},
+		{rpt.Process, "host;2", ""},
+		{rpt.Process, "host;3", ""},
+		{rpt.Process, "host;4", "systemd/host/cron.service/cron"},
+		{rpt.Process, "host;5", "systemd/host/cron.service/anacron"},
+	} {
+		if have, _ := c.topology.Nodes[c.node].Latest.Lookup(report.StableID); have != c.want {
+			t.Errorf("%s: want %q, have %q", c.node, c.want, have)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package probe
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+const (
+	// Consecutive failures after which a ticker, reporter or tagger is
+	// skipped for a while
+	maxConsecutiveFailures = 3
+	// Upper bound on the number of spy cycles a failing one is skipped for
+	maxSkippedCycles = 64
+)
+
+// supervisor isolates the tickers, reporters and taggers of the probe,
+// so that one which panics or keeps failing is logged and skipped
+// rather than degrading the whole probe.
+type supervisor struct {
+	mtx      sync.Mutex
+	failures map[string]int
+	skipping map[string]int
+}
+
+func newSupervisor() *supervisor {
+	return &supervisor{
+		failures: map[string]int{},
+		skipping: map[string]int{},
+	}
+}
+
+// run calls f unless the named component is being skipped, recovering
+// from any panic. Components failing repeatedly are skipped for an
+// exponentially growing number of calls.
+func (s *supervisor) run(kind, name string, f func() error) (skipped bool, err error) {
+	key := kind + "/" + name
+	s.mtx.Lock()
+	if s.skipping[key] > 0 {
+		s.skipping[key]--
+		s.mtx.Unlock()
+		return true, nil
+	}
+	s.mtx.Unlock()
+
+	err = safely(f)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if err == nil {
+		delete(s.failures, key)
+		return false, nil
+	}
+	s.failures[key]++
+	if failures := s.failures[key]; failures >= maxConsecutiveFailures {
+		skip := 1 << uint(failures-maxConsecutiveFailures)
+		if skip > maxSkippedCycles {
+			skip = maxSkippedCycles
+		}
+		s.skipping[key] = skip
+		log.Warnf("%s %s failed %d times in a row, skipping it for %d cycles: %v", name, kind, failures, skip, err)
+	}
+	return false, err
+}
+
+// safely calls f, turning any panic into an error.
+func safely(f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Errorf("recovered from panic: %v\n%s", r, debug.Stack())
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return f()
+}
@@ -0,0 +1,58 @@
+package probe
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/weaveworks/scope/report"
+)
+
+func TestSupervisorIsolatesPanics(t *testing.T) {
+	r := report.MakeReport()
+	r.Host.AddNode(report.MakeNode("host1"))
+
+	p := New(0, 0, nil, false)
+	p.AddReporter(
+		ReporterFunc("good", func(context.Context) (report.Report, error) { return r, nil }),
+		ReporterFunc("panicky", func(context.Context) (report.Report, error) { panic("boom") }),
+	)
+
+	have, timings := p.report()
+	if _, ok := have.Host.Nodes["host1"]; !ok {
+		t.Errorf("expected the report of the good reporter, got %v", have)
+	}
+	for _, timing := range timings {
+		if timing.Name == "panicky" && timing.Error != "panic: boom" {
+			t.Errorf("expected the panic to be reported, got %+v", timing)
+		}
+	}
+}
+
+func TestSupervisorSkipsFailures(t *testing.T) {
+	s := newSupervisor()
+	calls := 0
+	failing := func() error {
+		calls++
+		return fmt.Errorf("no docker socket")
+	}
+
+	var skipped []bool
+	for i := 0; i < maxConsecutiveFailures+4; i++ {
+		skip, _ := s.run("reporter", "docker", failing)
+		skipped = append(skipped, skip)
+	}
+	// Skipped once after the third failure, then twice after the fourth
+	want := []bool{false, false, false, true, false, true, true}
+	if fmt.Sprint(skipped) != fmt.Sprint(want) {
+		t.Errorf("want %v, have %v", want, skipped)
+	}
+	if calls != 4 {
+		t.Errorf("expected 4 calls, got %d", calls)
+	}
+
+	// Success resets the failures
+	if skip, err := s.run("reporter", "other", func() error { return nil }); skip || err != nil {
+		t.Errorf("unexpected skip=%v err=%v", skip, err)
+	}
+}
@@ -1,6 +1,7 @@
 package probe
 
 import (
+	"context"
 	"github.com/weaveworks/scope/report"
 )
 
@@ -15,7 +16,7 @@ func NewTopologyTagger() Tagger {
 func (topologyTagger) Name() string { return "Topology" }
 
 // Tag implements Tagger
-func (topologyTagger) Tag(r report.Report) (report.Report, error) {
+func (topologyTagger) Tag(_ context.Context, r report.Report) (report.Report, error) {
 	r.WalkNamedTopologies(func(name string, t *report.Topology) {
 		for _, node := range t.Nodes {
 			t.ReplaceNode(node.WithTopology(name))
@@ -1,6 +1,7 @@
 package probe
 
 import (
+	"context"
 	"testing"
 
 	"github.com/weaveworks/scope/report"
@@ -9,7 +10,7 @@ import (
 func TestTagMissingID(t *testing.T) {
 	const nodeID = "not-found"
 	r := report.MakeReport()
-	rpt, _ := NewTopologyTagger().Tag(r)
+	rpt, _ := NewTopologyTagger().Tag(context.Background(), r)
 	_, ok := rpt.Endpoint.Nodes[nodeID]
 	if ok {
 		t.Error("TopologyTagger erroneously tagged a missing node ID")
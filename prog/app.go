@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"net/http"
@@ -26,10 +27,16 @@ import (
 	"github.com/weaveworks/common/tracing"
 	"github.com/weaveworks/go-checkpoint"
 	"github.com/weaveworks/scope/app"
+	"github.com/weaveworks/scope/app/auth"
 	"github.com/weaveworks/scope/app/multitenant"
+	"github.com/weaveworks/scope/common/geoip"
+	"github.com/weaveworks/scope/common/iprange"
 	"github.com/weaveworks/scope/common/weave"
 	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/common/xlog"
+	"github.com/weaveworks/scope/probe/appclient"
 	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/render"
 )
 
 const (
@@ -52,17 +59,38 @@ func init() {
 }
 
 // Router creates the mux for all the various app components.
-func router(collector app.Collector, controlRouter app.ControlRouter, pipeRouter app.PipeRouter, externalUI bool, capabilities map[string]bool, metricsGraphURL string) http.Handler {
+func router(collector app.Collector, controlRouter app.ControlRouter, pipeRouter app.PipeRouter, views *app.Views, annotations *app.Annotations, maintenance *app.MaintenanceWindows, deployments *app.Deployments, reloader *app.Reloader, cluster *app.Cluster, auditor app.MergeAuditor, userIDer multitenant.UserIDer, externalUI, pluginRenderers, authenticated bool, capabilities map[string]bool, metricsGraphURL string) http.Handler {
 	router := mux.NewRouter().SkipClean(true)
 
 	// We pull in the http.DefaultServeMux to get the pprof routes
 	router.PathPrefix("/debug/pprof").Handler(http.DefaultServeMux)
 	router.Path("/metrics").Handler(prometheus.Handler())
+	// Log levels may only be changed by admins, so not unless requests
+	// are authenticated
+	router.Methods("GET").Path("/debug/log-levels").HandlerFunc(xlog.Handler)
+	if authenticated {
+		router.Methods("POST").Path("/debug/log-levels").HandlerFunc(xlog.Handler)
+	}
 
 	app.RegisterReportPostHandler(collector, router)
 	app.RegisterControlRoutes(router, controlRouter)
+	app.RegisterBandwidthTestRoute(router, collector, controlRouter)
 	app.RegisterPipeRoutes(router, pipeRouter)
-	app.RegisterTopologyRoutes(router, app.WebReporter{Reporter: collector, MetricsGraphURL: metricsGraphURL}, capabilities)
+	app.RegisterTopologyRoutes(router, app.WebReporter{Reporter: app.InvariantReporter(collector), MetricsGraphURL: metricsGraphURL}, capabilities)
+	app.RegisterViewRoutes(router, views, userIDer)
+	app.RegisterAnnotationRoutes(router, annotations, userIDer)
+	app.RegisterMaintenanceRoutes(router, maintenance, userIDer)
+	app.RegisterDeploymentRoutes(router, deployments, userIDer)
+	app.RegisterReloadRoute(router, reloader)
+	if cluster != nil {
+		app.RegisterClusterRoutes(router, cluster)
+	}
+	if auditor != nil {
+		app.RegisterMergeAuditRoute(router, auditor)
+	}
+	if pluginRenderers {
+		app.RegisterPluginRendererRoutes(router)
+	}
 
 	uiHandler := http.FileServer(GetFS(externalUI))
 	router.PathPrefix("/ui").Name("static").Handler(
@@ -82,9 +110,9 @@ func router(collector app.Collector, controlRouter app.ControlRouter, pipeRouter
 }
 
 func collectorFactory(userIDer multitenant.UserIDer, collectorURL, s3URL, natsHostname string,
-	memcacheConfig multitenant.MemcacheConfig, window time.Duration, createTables bool) (app.Collector, error) {
+	memcacheConfig multitenant.MemcacheConfig, window time.Duration, ttls app.TopologyTTLs, createTables bool, storeConfig multitenant.AWSCollectorConfig) (app.Collector, error) {
 	if collectorURL == "local" {
-		return app.NewCollector(window), nil
+		return app.NewCollectorWithTTLs(window, ttls), nil
 	}
 
 	parsed, err := url.Parse(collectorURL)
@@ -115,17 +143,16 @@ func collectorFactory(userIDer multitenant.UserIDer, collectorURL, s3URL, natsHo
 		if memcacheConfig.Host != "" {
 			memcacheClient = multitenant.NewMemcacheClient(memcacheConfig)
 		}
-		awsCollector, err := multitenant.NewAWSCollector(
-			multitenant.AWSCollectorConfig{
-				UserIDer:       userIDer,
-				DynamoDBConfig: dynamoDBConfig,
-				DynamoTable:    tableName,
-				S3Store:        &s3Store,
-				NatsHost:       natsHostname,
-				MemcacheClient: memcacheClient,
-				Window:         window,
-			},
-		)
+		// The compaction and caching of reports are configured by the
+		// caller
+		storeConfig.UserIDer = userIDer
+		storeConfig.DynamoDBConfig = dynamoDBConfig
+		storeConfig.DynamoTable = tableName
+		storeConfig.S3Store = &s3Store
+		storeConfig.NatsHost = natsHostname
+		storeConfig.MemcacheClient = memcacheClient
+		storeConfig.Window = window
+		awsCollector, err := multitenant.NewAWSCollector(storeConfig)
 		if err != nil {
 			return nil, err
 		}
@@ -201,6 +228,47 @@ func pipeRouterFactory(userIDer multitenant.UserIDer, pipeRouterURL, consulInf s
 	return nil, fmt.Errorf("Invalid pipe router '%s'", pipeRouterURL)
 }
 
+// startForwarder forwards the reports of collector to the upstream app,
+// authenticating like a probe does. It returns a function stopping the
+// forwarding.
+func startForwarder(collector app.Collector, flags appFlags) (func(), error) {
+	if flags.forwardInterval <= 0 {
+		return nil, fmt.Errorf("-app.forward.interval must be positive, not %v", flags.forwardInterval)
+	}
+	rawurl := flags.forwardTarget
+	if !strings.Contains(rawurl, "://") {
+		rawurl = "http://" + rawurl
+	}
+	target, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	token := ""
+	if target.User != nil {
+		token = target.User.Username()
+		target.User = nil // erase credentials, as we use a special header
+	}
+	client, err := appclient.NewAppClient(appclient.ProbeConfig{
+		Token:        token,
+		ProbeVersion: version,
+		ProbeID:      app.UniqueID,
+		Insecure:     flags.forwardInsecure,
+	}, target.Hostname(), *target, nil)
+	if err != nil {
+		return nil, err
+	}
+	forwarder := app.NewForwarder(collector, client, app.ForwarderConfig{
+		Interval:          flags.forwardInterval,
+		MaxSamples:        flags.forwardMaxSamples,
+		MaxBytesPerSecond: flags.forwardMaxBytesPerSecond,
+	})
+	log.Infof("Forwarding reports to %s", target.Host)
+	return func() {
+		forwarder.Stop()
+		client.Stop()
+	}, nil
+}
+
 // Main runs the app
 func appMain(flags appFlags) {
 	setLogLevel(flags.logLevel)
@@ -214,6 +282,11 @@ func appMain(flags appFlags) {
 	rand.Seed(time.Now().UnixNano())
 	app.UniqueID = strconv.FormatInt(rand.Int63(), 16)
 	app.Version = version
+	app.ClockSkewThreshold = flags.clockSkew
+	render.RawEdgeDirections = flags.rawEdges
+	if flags.layout {
+		app.EnableLayout(flags.layoutMaxNodes)
+	}
 	log.Infof("app starting, version %s, ID %s", app.Version, app.UniqueID)
 	logCensoredArgs()
 
@@ -222,21 +295,106 @@ func appMain(flags appFlags) {
 		userIDer = multitenant.UserIDHeader(flags.userIDHeader)
 	}
 
-	collector, err := collectorFactory(
-		userIDer, flags.collectorURL, flags.s3URL, flags.natsHostname,
-		multitenant.MemcacheConfig{
-			Host:             flags.memcachedHostname,
-			Timeout:          flags.memcachedTimeout,
-			Expiration:       flags.memcachedExpiration,
-			UpdateInterval:   memcacheUpdateInterval,
-			Service:          flags.memcachedService,
-			CompressionLevel: flags.memcachedCompressionLevel,
-		},
-		flags.window, flags.awsCreateTables)
+	if err := app.EnableInvariantChecks(flags.invariants); err != nil {
+		log.Fatalf("Error enabling invariant checks: %v", err)
+		return
+	}
+
+	if flags.readReplica && flags.collectorURL == "local" {
+		log.Fatal("A read replica needs a shared collector to read reports from")
+		return
+	}
+
+	ttls, err := app.ParseTopologyTTLs(flags.topologyTTLs)
+	if err != nil {
+		log.Fatalf("Error parsing topology TTLs: %v", err)
+		return
+	}
+	var settings app.Settings
+	if flags.configFile != "" {
+		if settings, err = app.LoadSettings(flags.configFile); err != nil {
+			log.Fatalf("Error loading settings: %v", err)
+			return
+		}
+	}
+	var collector app.Collector
+	if flags.importBundle != "" {
+		collector, err = app.NewBundleCollector(flags.importBundle, flags.window)
+	} else {
+		collector, err = collectorFactory(
+			userIDer, flags.collectorURL, flags.s3URL, flags.natsHostname,
+			multitenant.MemcacheConfig{
+				Host:             flags.memcachedHostname,
+				Timeout:          flags.memcachedTimeout,
+				Expiration:       flags.memcachedExpiration,
+				UpdateInterval:   memcacheUpdateInterval,
+				Service:          flags.memcachedService,
+				CompressionLevel: flags.memcachedCompressionLevel,
+			},
+			flags.window, settings.TTLs(ttls), flags.awsCreateTables,
+			multitenant.AWSCollectorConfig{
+				CompactAfter:         flags.awsCompactAfter,
+				CompactInterval:      flags.awsCompactInterval,
+				HistoryCacheSize:     flags.historyCacheSize,
+				HistoryCacheDir:      flags.historyCacheDir,
+				HistoryCacheDiskSize: flags.historyCacheDiskSize,
+			})
+	}
 	if err != nil {
 		log.Fatalf("Error creating collector: %v", err)
 		return
 	}
+	// Only the TTLs of the local collector can be reloaded
+	ttlSetter, _ := collector.(interface{ SetTopologyTTLs(app.TopologyTTLs) })
+	var auditor app.MergeAuditor
+	if flags.mergeAudit {
+		enabler, ok := collector.(interface{ EnableMergeAudit() })
+		if !ok {
+			log.Fatal("Merge audits need the local collector")
+			return
+		}
+		enabler.EnableMergeAudit()
+		auditor = collector.(app.MergeAuditor)
+	}
+	// Only the collectors reading reports from a store estimate the cost
+	// of queries
+	if estimator, ok := collector.(app.CostEstimator); ok {
+		app.EnableQueryLimits(app.QueryLimits{
+			MaxReports: flags.maxQueryReports,
+			MaxBytes:   flags.maxQueryBytes,
+		}, estimator)
+	}
+
+	if flags.warmupDir != "" && flags.collectorURL == "local" && flags.importBundle == "" {
+		loaded, err := app.LoadWarmup(collector, flags.warmupDir, flags.window)
+		if err != nil {
+			log.Fatalf("Error loading reports for warmup: %v", err)
+			return
+		}
+		log.Infof("Loaded %d reports for warmup from %s", loaded, flags.warmupDir)
+		warmup, err := app.NewWarmup(collector, flags.warmupDir, flags.warmupInterval)
+		if err != nil {
+			log.Fatalf("Error keeping reports for warmup: %v", err)
+			return
+		}
+		defer warmup.Stop()
+		collector = warmup
+	}
+
+	// Reports learnt from the other replicas go straight to the local
+	// collector, as they were billed and monitored by the replica the
+	// probe published them to
+	var cluster *app.Cluster
+	if flags.clusterPeers != "" && flags.collectorURL == "local" && flags.importBundle == "" {
+		cluster = app.NewCluster(collector, flags.window, app.ClusterConfig{
+			Peers:    strings.Split(flags.clusterPeers, ","),
+			Fanout:   flags.clusterFanout,
+			Interval: flags.clusterInterval,
+			Token:    flags.clusterToken,
+		})
+		defer cluster.Stop()
+		collector = cluster
+	}
 
 	if flags.BillingEmitterConfig.Enabled {
 		billingEmitter, err := emitterFactory(collector, flags.BillingClientConfig, userIDer, flags.BillingEmitterConfig)
@@ -248,6 +406,23 @@ func appMain(flags appFlags) {
 		collector = billingEmitter
 	}
 
+	// Lost probes are only told for the local collector, as hosts are
+	// tracked regardless of the tenant
+	if flags.probeLostTimeout > 0 && flags.collectorURL == "local" && flags.importBundle == "" {
+		monitor := app.NewProbeMonitor(collector, flags.probeLostTimeout)
+		defer monitor.Stop()
+		collector = monitor
+	}
+	if flags.egressTolerance > 0 && flags.collectorURL == "local" && flags.importBundle == "" {
+		collector = app.NewEgressCorrelator(collector, flags.window, flags.egressTolerance)
+	}
+
+	// Reporting needs no tenant only for the local collector
+	if flags.topologyMetricsInterval > 0 && flags.collectorURL == "local" {
+		topologyMetrics := app.NewTopologyMetrics(collector, flags.window, flags.topologyMetricsInterval)
+		defer topologyMetrics.Stop()
+	}
+
 	controlRouter, err := controlRouterFactory(userIDer, flags.controlRouterURL, flags.controlRPCTimeout)
 	if err != nil {
 		log.Fatalf("Error creating control router: %v", err)
@@ -260,6 +435,12 @@ func appMain(flags appFlags) {
 		return
 	}
 
+	// Configuration reloaded on SIGHUP and POST /api/admin/reload
+	reloader := app.NewReloader()
+	reloadCtx, stopReloading := context.WithCancel(context.Background())
+	defer stopReloading()
+	reloader.ReloadOnSignal(reloadCtx)
+
 	// Start background version checking
 	checkpoint.CheckInterval(&checkpoint.CheckParams{
 		Product: "scope-app",
@@ -287,11 +468,165 @@ func appMain(flags appFlags) {
 		}
 	}
 
+	if flags.baselineSigmas > 0 {
+		if flags.baselineInterval <= 0 {
+			log.Fatalf("Error loading edge baselines: -app.baseline.interval must be positive, not %v", flags.baselineInterval)
+			return
+		}
+		baselines, err := app.NewEdgeBaselines(flags.baselineFile, flags.baselineSigmas, flags.baselineInterval)
+		if err != nil {
+			log.Fatalf("Error loading edge baselines: %v", err)
+			return
+		}
+		// Baselines are updated from the present, which only the local
+		// collector reports without a tenant
+		if flags.collectorURL == "local" {
+			baselines.Start(collector)
+			defer baselines.Stop()
+		} else {
+			log.Warnf("Edge baselines are only updated with the local collector, so no edges will be flagged as anomalous")
+		}
+		app.EnableEdgeBaselines(baselines)
+	}
+	if flags.policyFile != "" {
+		p, err := app.LoadPolicies(flags.policyFile)
+		if err != nil {
+			log.Fatalf("Error loading policies: %v", err)
+			return
+		}
+		app.EnablePolicies(p)
+	}
+	if flags.asnFile != "" {
+		t, err := iprange.LoadFile(flags.asnFile)
+		if err != nil {
+			log.Fatalf("Error loading autonomous systems: %v", err)
+			return
+		}
+		app.EnableASNs(t)
+	}
+	if flags.geoIPFiles != "" {
+		dbs, err := geoip.OpenAll(flags.geoIPFiles)
+		if err != nil {
+			log.Fatalf("Error loading GeoIP databases: %v", err)
+			return
+		}
+		var countries []string
+		if flags.geoIPCountries != "" {
+			countries = strings.Split(flags.geoIPCountries, ",")
+		}
+		app.EnableGeoIP(dbs, countries)
+	}
+	switch {
+	case flags.catalogFile != "":
+		c, err := app.LoadCatalog(flags.catalogFile)
+		if err != nil {
+			log.Fatalf("Error loading the service catalog: %v", err)
+			return
+		}
+		app.EnableCatalog(c)
+		reloader.Register("catalog", c)
+	case flags.catalogBackstageURL != "":
+		if flags.catalogRefreshInterval <= 0 {
+			log.Fatalf("Error fetching the service catalog: -app.catalog.refresh-interval must be positive, not %v", flags.catalogRefreshInterval)
+			return
+		}
+		c, err := app.NewBackstageCatalog(flags.catalogBackstageURL, flags.catalogBackstageToken, flags.catalogRefreshInterval)
+		if err != nil {
+			log.Fatalf("Error fetching the service catalog: %v", err)
+			return
+		}
+		defer c.Stop()
+		app.EnableCatalog(c)
+	}
+	if flags.pipelinesFile != "" {
+		p, err := app.NewPipelines(flags.pipelinesFile, flags.pipelinesReloadInterval)
+		if err != nil {
+			log.Fatalf("Error loading the pipelines: %v", err)
+			return
+		}
+		defer p.Stop()
+		reloader.Register("pipelines", p)
+	}
+
+	if flags.forwardTarget != "" {
+		stopForwarding, err := startForwarder(collector, flags)
+		if err != nil {
+			log.Fatalf("Error creating forwarder: %v", err)
+			return
+		}
+		defer stopForwarding()
+	}
+
 	capabilities := map[string]bool{
 		xfer.HistoricReportsCapability: collector.HasHistoricReports(),
+		xfer.ReadReplicaCapability:     flags.readReplica,
 	}
 	logger := logging.Logrus(log.StandardLogger())
-	handler := router(collector, controlRouter, pipeRouter, flags.externalUI, capabilities, flags.metricsGraphURL)
+	views, err := app.NewViews(flags.viewsFile)
+	if err != nil {
+		log.Fatalf("Error loading saved views: %v", err)
+		return
+	}
+	annotations, err := app.NewAnnotations(flags.annotationsFile)
+	if err != nil {
+		log.Fatalf("Error loading annotations: %v", err)
+		return
+	}
+	app.EnableAnnotations(annotations, userIDer)
+	maintenance, err := app.NewMaintenanceWindows(flags.maintenanceFile)
+	if err != nil {
+		log.Fatalf("Error loading maintenance windows: %v", err)
+		return
+	}
+	app.EnableMaintenanceWindows(maintenance, userIDer)
+	deployments, err := app.NewDeployments(flags.deploymentsFile)
+	if err != nil {
+		log.Fatalf("Error loading deployments: %v", err)
+		return
+	}
+	app.EnableDeployments(deployments, userIDer)
+	limits := app.IngestionLimits{
+		MaxReportBytes:    flags.maxReportBytes,
+		MaxNodes:          flags.maxNodes,
+		MaxMetadata:       flags.maxMetadata,
+		MaxAdjacency:      flags.maxAdjacency,
+		Truncate:          flags.truncate,
+		RequireSignatures: flags.requireProbeSignatures,
+	}
+	app.EnableIngestionLimits(settings.Limits(limits))
+	if flags.configFile != "" {
+		reloader.Register("settings", app.ReloadFunc(func() (func(), error) {
+			settings, err := app.LoadSettings(flags.configFile)
+			if err != nil {
+				return nil, err
+			}
+			return func() {
+				app.EnableIngestionLimits(settings.Limits(limits))
+				if ttlSetter != nil {
+					ttlSetter.SetTopologyTTLs(settings.TTLs(ttls))
+				}
+			}, nil
+		}))
+	}
+	authenticated := flags.authTokensFile != "" || flags.authOIDCIssuer != ""
+	if flags.pluginRenderers && !authenticated {
+		// Otherwise anyone could have the app dial any address
+		log.Fatalf("Error configuring renderer plugins: -app.plugin-renderers requires -app.auth.tokens-file or -app.auth.oidc.issuer, for admins to register them")
+		return
+	}
+	handler := router(collector, controlRouter, pipeRouter, views, annotations, maintenance, deployments, reloader, cluster, auditor, userIDer, flags.externalUI, flags.pluginRenderers, authenticated, capabilities, flags.metricsGraphURL)
+	if flags.readReplica {
+		handler = app.ReadReplica(handler)
+	}
+	if authenticated {
+		authenticator, err := newAuthenticator(flags, reloader)
+		if err != nil {
+			log.Fatalf("Error configuring authentication: %v", err)
+			return
+		}
+		handler = authenticator.Wrap(handler)
+	}
+	handler = app.APIVersions(handler)
 	if flags.logHTTP {
 		handler = middleware.Log{
 			Log:               logger,
@@ -355,3 +690,42 @@ func newWeavePublisher(dockerEndpoint, weaveAddr, weaveHostname, containerName s
 		containerName,
 	), nil
 }
+
+func newAuthenticator(flags appFlags, reloader *app.Reloader) (*auth.Authenticator, error) {
+	config := auth.Config{SessionKey: flags.authSessionKey, SessionTTL: flags.authSessionTTL}
+	if flags.authTokensFile != "" {
+		tokens, err := auth.LoadTokens(flags.authTokensFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Tokens = tokens
+		reloader.Register("tokens", app.ReloadFunc(func() (func(), error) {
+			reloaded, err := auth.LoadTokens(flags.authTokensFile)
+			if err != nil {
+				return nil, err
+			}
+			return func() { tokens.Replace(reloaded) }, nil
+		}))
+	}
+	if flags.authOIDCIssuer != "" {
+		roles, err := auth.ParseRoleMap(flags.authOIDCRoles)
+		if err != nil {
+			return nil, err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		config.OIDC, err = auth.NewOIDC(ctx, auth.OIDCConfig{
+			Issuer:       flags.authOIDCIssuer,
+			ClientID:     flags.authOIDCClientID,
+			ClientSecret: flags.authOIDCClientSecret,
+			RedirectURL:  flags.authOIDCRedirectURL,
+			RoleClaim:    flags.authOIDCRoleClaim,
+			Roles:        roles,
+			Scopes:       []string{"email", flags.authOIDCRoleClaim},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return auth.NewAuthenticator(config), nil
+}
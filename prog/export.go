@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/weaveworks/scope/app"
+)
+
+// Main runs the export of a bundle of reports
+func exportBundleMain(flags exportFlags) {
+	to := time.Now()
+	if flags.to != "" {
+		var err error
+		if to, err = time.Parse(time.RFC3339, flags.to); err != nil {
+			log.Fatalf("Invalid value for -export.to: %v", err)
+		}
+	}
+	from := to.Add(-flags.duration)
+	if flags.from != "" {
+		var err error
+		if from, err = time.Parse(time.RFC3339, flags.from); err != nil {
+			log.Fatalf("Invalid value for -export.from: %v", err)
+		}
+	}
+	if flags.step <= 0 {
+		log.Fatalf("Invalid value for -export.step: must be positive")
+	}
+
+	var w io.Writer = os.Stdout
+	if flags.output != "-" {
+		f, err := os.Create(flags.output)
+		if err != nil {
+			log.Fatalf("Error creating bundle: %v", err)
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := app.ExportBundle(cleanhttp.DefaultClient(), app.BundleConfig{
+		AppURL: flags.appURL,
+		From:   from,
+		To:     to,
+		Step:   flags.step,
+		Token:  flags.token,
+	}, w); err != nil {
+		log.Fatalf("Error exporting bundle: %v", err)
+	}
+}
@@ -18,8 +18,11 @@ import (
 	"github.com/weaveworks/scope/app"
 	"github.com/weaveworks/scope/app/multitenant"
 	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/common/xlog"
+	"github.com/weaveworks/scope/probe"
 	"github.com/weaveworks/scope/probe/appclient"
 	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/probe/identity"
 	"github.com/weaveworks/scope/probe/kubernetes"
 	"github.com/weaveworks/scope/render"
 	"github.com/weaveworks/weave/common"
@@ -74,12 +77,13 @@ func setLogLevel(levelname string) {
 	if err != nil {
 		log.Fatal(err)
 	}
-	log.SetLevel(level)
+	xlog.SetDefaultLevel(level)
 }
 
 type flags struct {
-	probe probeFlags
-	app   appFlags
+	probe  probeFlags
+	app    appFlags
+	export exportFlags
 
 	mode                             string
 	debug                            bool
@@ -98,6 +102,12 @@ type probeFlags struct {
 	httpListen             string
 	publishInterval        time.Duration
 	spyInterval            time.Duration
+	flightRecorderSize     int
+	redactionFile          string
+	identityDir            string
+	labels                 string
+	dryRun                 bool
+	pluginsEnabled         bool
 	pluginsRoot            string
 	insecure               bool
 	logPrefix              string
@@ -108,25 +118,28 @@ type probeFlags struct {
 	noCommandLineArguments bool
 	noEnvironmentVariables bool
 
+	endpointsEnabled    bool // Produce endpoint topology
 	useConntrack        bool // Use conntrack for endpoint topo
 	conntrackBufferSize int  // Sie of kernel buffer for conntrack
 
-	spyProcs    bool // Associate endpoints with processes (must be root)
-	procEnabled bool // Produce process topology & process nodes in endpoint
-	useEbpfConn bool // Enable connection tracking with eBPF
-	procRoot    string
+	spyProcs         bool // Associate endpoints with processes (must be root)
+	procEnabled      bool // Produce process topology & process nodes in endpoint
+	maxSocketScanFDs int  // Cap on file descriptors stat'ed per walk to count sockets
+	useEbpfConn      bool // Enable connection tracking with eBPF
+	useIPVS          bool // Attribute connections to IPVS virtual IPs to their backends
+	sniffL7          bool // Read the application protocols of connections off their traffic
+	procRoot         string
 
 	dockerEnabled  bool
 	dockerInterval time.Duration
 	dockerBridge   string
 
-	criEnabled  bool
-	criEndpoint string
-
-	kubernetesEnabled      bool
-	kubernetesNodeName     string
-	kubernetesClientConfig kubernetes.ClientConfig
-	kubernetesKubeletPort  uint
+	kubernetesEnabled       bool
+	kubernetesNodeName      string
+	kubernetesClusterName   string
+	kubernetesQualifyHostID bool
+	kubernetesClientConfig  kubernetes.ClientConfig
+	kubernetesKubeletPort   uint
 
 	ecsEnabled       bool
 	ecsCacheSize     int
@@ -140,6 +153,8 @@ type probeFlags struct {
 
 type appFlags struct {
 	window         time.Duration
+	topologyTTLs   string
+	configFile     string
 	listen         string
 	stopTimeout    time.Duration
 	logLevel       string
@@ -171,13 +186,96 @@ type appFlags struct {
 
 	blockProfileRate int
 
-	awsCreateTables bool
-	consulInf       string
+	baselineFile     string
+	baselineSigmas   float64
+	baselineInterval time.Duration
+	policyFile       string
+	asnFile          string
+	geoIPFiles       string
+	geoIPCountries   string
+	viewsFile        string
+	annotationsFile  string
+	maintenanceFile  string
+	deploymentsFile  string
+	clockSkew        time.Duration
+	rawEdges         bool
+	layout           bool
+	layoutMaxNodes   int
+
+	maxReportBytes int64
+	maxNodes       int
+	maxMetadata    int
+	maxAdjacency   int
+	truncate       bool
+
+	requireProbeSignatures bool
+
+	maxQueryReports int
+	maxQueryBytes   int64
+
+	catalogFile            string
+	catalogBackstageURL    string
+	catalogBackstageToken  string
+	catalogRefreshInterval time.Duration
+
+	pipelinesFile           string
+	pipelinesReloadInterval time.Duration
+	pluginRenderers         bool
+
+	authTokensFile       string
+	authOIDCIssuer       string
+	authOIDCClientID     string
+	authOIDCClientSecret string
+	authOIDCRedirectURL  string
+	authOIDCRoleClaim    string
+	authOIDCRoles        string
+	authSessionKey       string
+	authSessionTTL       time.Duration
+
+	forwardTarget            string
+	readReplica              bool
+	mergeAudit               bool
+	invariants               string
+	forwardInterval          time.Duration
+	forwardMaxSamples        int
+	forwardMaxBytesPerSecond int
+	forwardInsecure          bool
+
+	importBundle string
+
+	probeLostTimeout        time.Duration
+	egressTolerance         time.Duration
+	topologyMetricsInterval time.Duration
+
+	warmupDir      string
+	warmupInterval time.Duration
+
+	clusterPeers    string
+	clusterFanout   int
+	clusterInterval time.Duration
+	clusterToken    string
+
+	awsCreateTables      bool
+	awsCompactAfter      time.Duration
+	awsCompactInterval   time.Duration
+	historyCacheSize     int
+	historyCacheDir      string
+	historyCacheDiskSize int
+	consulInf            string
 
 	multitenant.BillingEmitterConfig
 	BillingClientConfig billing.Config
 }
 
+type exportFlags struct {
+	appURL   string
+	from, to string
+	duration time.Duration
+	step     time.Duration
+	output   string
+	token    string
+}
+
 type containerLabelFiltersFlag struct {
 	apiTopologyOptions []app.APITopologyOption
 	filterNumber       int
@@ -285,6 +383,12 @@ func setupFlags(flags *flags) {
 	flag.StringVar(&flags.probe.httpListen, "probe.http.listen", "", "listen address for HTTP profiling and instrumentation server")
 	flag.DurationVar(&flags.probe.publishInterval, "probe.publish.interval", 3*time.Second, "publish (output) interval")
 	flag.DurationVar(&flags.probe.spyInterval, "probe.spy.interval", time.Second, "spy (scan) interval")
+	flag.IntVar(&flags.probe.flightRecorderSize, "probe.flight-recorder.size", 60, "Number of spy cycles and publications to keep for debugging; dumped on SIGUSR1 or at /debug/flight-recorder on the HTTP listen address. 0 disables the flight recorder")
+	flag.StringVar(&flags.probe.redactionFile, "probe.redaction.file", "", "File of rules redacting node metadata before it is published, one per line as \"<topology>: <drop|mask> <key regexp> [<value regexp>]\" (topology * for all)")
+	flag.StringVar(&flags.probe.identityDir, "probe.identity.dir", identity.DefaultDir, "Directory keeping the key pair of the probe, generated on its first start, which the probe ID is derived from and reports are signed with (empty for a random ID on every start)")
+	flag.StringVar(&flags.probe.labels, "probe.labels", "", "Comma-separated key=value labels to add to every node the probe reports, e.g. team=net,env=prod (also settable with SCOPE_PROBE_LABELS)")
+	flag.BoolVar(&flags.probe.dryRun, "probe.dry-run", false, "Run every reporter once, print a summary of what each produced and of any missing permissions, and exit")
+	flag.BoolVar(&flags.probe.pluginsEnabled, "probe.plugins", true, "load plugins from probe.plugins.root")
 	flag.StringVar(&flags.probe.pluginsRoot, "probe.plugins.root", "/var/run/scope/plugins", "Root directory to search for plugins")
 	flag.BoolVar(&flags.probe.noControls, "probe.no-controls", false, "Disable controls (e.g. start/stop containers, terminals, logs ...)")
 	flag.BoolVar(&flags.probe.noCommandLineArguments, "probe.omit.cmd-args", false, "Disable collection of command-line arguments")
@@ -296,22 +400,22 @@ func setupFlags(flags *flags) {
 	flag.StringVar(&flags.probe.logLevel, "probe.log.level", "info", "logging threshold level: debug|info|warn|error|fatal|panic")
 
 	// Proc & endpoint
+	flag.BoolVar(&flags.probe.endpointsEnabled, "probe.endpoints", true, "produce endpoint topology & track connections")
 	flag.BoolVar(&flags.probe.useConntrack, "probe.conntrack", true, "also use conntrack to track connections")
 	flag.IntVar(&flags.probe.conntrackBufferSize, "probe.conntrack.buffersize", 4096*1024, "conntrack buffer size")
 	flag.BoolVar(&flags.probe.spyProcs, "probe.proc.spy", true, "associate endpoints with processes (needs root)")
 	flag.StringVar(&flags.probe.procRoot, "probe.proc.root", "/proc", "location of the proc filesystem")
 	flag.BoolVar(&flags.probe.procEnabled, "probe.processes", true, "produce process topology & include procspied connections")
+	flag.IntVar(&flags.probe.maxSocketScanFDs, "probe.proc.max-socket-scan-fds", 10000, "maximum number of file descriptors looked at per process walk to count open sockets (0 to disable)")
 	flag.BoolVar(&flags.probe.useEbpfConn, "probe.ebpf.connections", true, "enable connection tracking with eBPF")
+	flag.BoolVar(&flags.probe.sniffL7, "probe.endpoints.l7", false, "read the requests of HTTP/2 and gRPC clients, the queries of database clients, the server names TLS clients ask for (SNI) and the round trip times of connection handshakes off the traffic of their connections, captured with pcap (costly on busy hosts)")
+	flag.BoolVar(&flags.probe.useIPVS, "probe.ipvs", true, "read the IPVS table (as set up by kube-proxy in IPVS mode), so connections to virtual IPs are shown going to their backends")
 
 	// Docker
 	flag.BoolVar(&flags.probe.dockerEnabled, "probe.docker", false, "collect Docker-related attributes for processes")
 	flag.DurationVar(&flags.probe.dockerInterval, "probe.docker.interval", 10*time.Second, "how often to update Docker attributes")
 	flag.StringVar(&flags.probe.dockerBridge, "probe.docker.bridge", "docker0", "the docker bridge name")
 
-	// CRI
-	flag.BoolVar(&flags.probe.criEnabled, "probe.cri", false, "collect CRI-related attributes for processes")
-	flag.StringVar(&flags.probe.criEndpoint, "probe.cri.endpoint", "unix///var/run/dockershim.sock", "The endpoint to connect to the CRI")
-
 	// K8s
 	flag.BoolVar(&flags.probe.kubernetesEnabled, "probe.kubernetes", false, "collect kubernetes-related attributes for containers")
 	flag.StringVar(&flags.probe.kubernetesClientConfig.Server, "probe.kubernetes.api", "", "The address and port of the Kubernetes API server (deprecated in favor of equivalent probe.kubernetes.server)")
@@ -328,6 +432,9 @@ func setupFlags(flags *flags) {
 	flag.StringVar(&flags.probe.kubernetesClientConfig.User, "probe.kubernetes.user", "", "The name of the kubeconfig user to use")
 	flag.StringVar(&flags.probe.kubernetesClientConfig.Username, "probe.kubernetes.username", "", "Username for basic authentication to the API server")
 	flag.StringVar(&flags.probe.kubernetesNodeName, "probe.kubernetes.node-name", "", "Name of this node, for filtering pods")
+	flag.StringVar(&flags.probe.kubernetesClusterName, "probe.kubernetes.cluster-name", "", "Name of the cluster of this node, stamped on all nodes to tell clusters apart (default: the UID of the kube-system namespace). When set, also qualifies the host ID")
+	flag.BoolVar(&flags.probe.kubernetesQualifyHostID, "probe.kubernetes.qualify-host-id", false, "Without a cluster name, qualify the host ID by the UID of the kube-system namespace, to tell apart the hosts of clusters with the same names. Needs get on namespaces, and changes the IDs of the hosts reported before")
+	flag.Var(&flags.probe.kubernetesClientConfig.CustomResources, "probe.kubernetes.custom-resource", "Custom resource to report, as group/version/resource[?labels=<selector>][&fields=<path>,...] (may be repeated)")
 	flag.UintVar(&flags.probe.kubernetesKubeletPort, "probe.kubernetes.kubelet-port", 10255, "Node-local TCP port for contacting kubelet")
 
 	// AWS ECS
@@ -342,6 +449,8 @@ func setupFlags(flags *flags) {
 
 	// App flags
 	flag.DurationVar(&flags.app.window, "app.window", 15*time.Second, "window")
+	flag.StringVar(&flags.app.topologyTTLs, "app.topology-ttls", "", "Comma-separated topology=duration pairs of how long the nodes of topologies live after their last report in the local collector, e.g. endpoint=10s,host=5m. Other topologies live for the window.")
+	flag.StringVar(&flags.app.configFile, "app.config.file", "", "YAML file of settings overriding the topology TTLs and ingestion limits flags (topology_ttls, and max_report_bytes, max_nodes, max_metadata, max_adjacency, truncate and require_signatures under ingestion). It is reloaded, with the pipelines, catalog and tokens files, on SIGHUP and POST /api/admin/reload.")
 	flag.StringVar(&flags.app.listen, "app.http.address", ":"+strconv.Itoa(xfer.AppPort), "webserver listen address")
 	flag.DurationVar(&flags.app.stopTimeout, "app.stopTimeout", 5*time.Second, "How long to wait for http requests to finish when shutting down")
 	flag.StringVar(&flags.app.logLevel, "app.log.level", "info", "logging threshold level: debug|info|warn|error|fatal|panic")
@@ -372,10 +481,83 @@ func setupFlags(flags *flags) {
 	flag.StringVar(&flags.app.metricsGraphURL, "app.metrics-graph", "", "Enable extended metrics graph by providing a templated URL (supports :orgID and :query). Example: --app.metric-graph=/prom/:orgID/notebook/new")
 	flag.StringVar(&flags.app.serviceName, "app.service-name", "app", "The name for this service which should be reported in instrumentation")
 
+	flag.StringVar(&flags.app.baselineFile, "app.baseline.file", "", "File in which to persist edge baselines across restarts. If empty, baselines are kept in memory only.")
+	flag.Float64Var(&flags.app.baselineSigmas, "app.baseline.sigmas", 0, "Flag edges whose connection count, or bytes sent, deviates from its baseline by more than this many standard deviations. If 0, anomaly flagging is disabled. Baselines are only updated with the local collector (--app.collector=local).")
+	flag.DurationVar(&flags.app.baselineInterval, "app.baseline.interval", time.Minute, "How often to fold the current edges of each view requested into their baselines, with the local collector. Must be positive.")
+	flag.StringVar(&flags.app.policyFile, "app.policy.file", "", "File of traffic allowlist rules ('<topology>: <source> -> <destination>') to check observed edges against. If empty, compliance checks are disabled.")
+	flag.StringVar(&flags.app.asnFile, "app.asn.file", "", "Table of the autonomous systems of IP address ranges, in the tab separated format of https://iptoasn.com, to name those of external destinations with. If empty, they are not named.")
+	flag.StringVar(&flags.app.geoIPFiles, "app.geoip.files", "", "Comma separated MaxMind DB files, such as GeoLite2-Country.mmdb and GeoLite2-ASN.mmdb, to locate external destinations with. If empty, they are not located.")
+	flag.StringVar(&flags.app.geoIPCountries, "app.geoip.expected-countries", "", "Comma separated ISO codes of the countries outbound connections are expected to go to. Connections to others are flagged as unexpected. If empty, none are flagged.")
+	flag.StringVar(&flags.app.viewsFile, "app.views.file", "", "File in which to persist the saved views of users across restarts. If empty, views are kept in memory only.")
+	flag.StringVar(&flags.app.annotationsFile, "app.annotations.file", "", "File in which to persist the annotations of nodes across restarts. If empty, annotations are kept in memory only.")
+	flag.StringVar(&flags.app.maintenanceFile, "app.maintenance.file", "", "File in which to persist maintenance windows across restarts. If empty, windows are kept in memory only.")
+	flag.StringVar(&flags.app.deploymentsFile, "app.deployments.file", "", "File in which to persist deployment markers across restarts. If empty, deployments are kept in memory only.")
+	flag.Int64Var(&flags.app.maxReportBytes, "app.ingestion.max-report-bytes", 0, "Largest report to accept, in bytes, decompressed if sent compressed. 0 means no limit.")
+	flag.IntVar(&flags.app.maxNodes, "app.ingestion.max-nodes", 0, "Most nodes per topology of a report to accept. 0 means no limit.")
+	flag.IntVar(&flags.app.maxMetadata, "app.ingestion.max-metadata", 0, "Most metadata keys and sets per node of a report to accept, each. 0 means no limit.")
+	flag.IntVar(&flags.app.maxAdjacency, "app.ingestion.max-adjacency", 0, "Most adjacent nodes per node of a report to accept. 0 means no limit.")
+	flag.BoolVar(&flags.app.truncate, "app.ingestion.truncate", false, "Truncate reports exceeding the ingestion limits rather than rejecting them.")
+	flag.BoolVar(&flags.app.requireProbeSignatures, "app.ingestion.require-signatures", false, "Reject the reports of probes without a key pair. The signatures of probes with one are always checked.")
+	flag.IntVar(&flags.app.maxQueryReports, "app.query.max-reports", 0, "Most reports the historic queries of the APIs over time ranges may read from the store of the collector, as estimated before running them. Admins may override it with override=true. 0 means no limit.")
+	flag.Int64Var(&flags.app.maxQueryBytes, "app.query.max-bytes", 0, "Most bytes of reports the historic queries of the APIs over time ranges may read from the store of the collector, as estimated before running them. Admins may override it with override=true. 0 means no limit.")
+	flag.DurationVar(&flags.app.clockSkew, "app.clock-skew-threshold", 2*time.Second, "How far off the app's the clocks of probes can be before their hosts are flagged. Report timestamps are corrected regardless.")
+	flag.BoolVar(&flags.app.rawEdges, "app.raw-edge-directions", false, "Show edges in the directions the probes reported them, rather than client to server where a probe knows which end initiated the connection")
+	flag.BoolVar(&flags.app.layout, "app.layout", false, "Lay out views in the app, in clusters, for clients asking for it with layout=true")
+	flag.IntVar(&flags.app.layoutMaxNodes, "app.layout.max-nodes", 20000, "The most nodes of views the app lays out (0 for no limit)")
+	flag.StringVar(&flags.app.catalogFile, "app.catalog.file", "", "Service catalog file of Backstage component entities (as in catalog-info.yaml) to show the owners of nodes from, matched by name. If empty, owners are not shown.")
+	flag.StringVar(&flags.app.catalogBackstageURL, "app.catalog.backstage-url", "", "URL of a Backstage instance to fetch the service catalog from, instead of a file")
+	flag.StringVar(&flags.app.catalogBackstageToken, "app.catalog.backstage-token", "", "Token to authenticate with the Backstage catalog API")
+	flag.DurationVar(&flags.app.catalogRefreshInterval, "app.catalog.refresh-interval", 10*time.Minute, "How often to fetch the service catalog from Backstage. Must be positive.")
+	flag.StringVar(&flags.app.pipelinesFile, "app.pipelines.file", "", "YAML file of custom views, defined as pipelines of render steps. If empty, only the built-in views are shown.")
+	flag.DurationVar(&flags.app.pipelinesReloadInterval, "app.pipelines.reload-interval", 30*time.Second, "How often to check the pipelines file for changes, to reload it. If 0, it is only reloaded on SIGHUP and POST /api/admin/reload.")
+	flag.BoolVar(&flags.app.pluginRenderers, "app.plugin-renderers", false, "Accept views rendered by external gRPC services, registered at /api/plugin-renderers by admins, so requiring authentication. Their views are shared by every org.")
+	flag.StringVar(&flags.app.authTokensFile, "app.auth.tokens-file", "", "File of machine tokens, one per line as '<token> <role> [<name>]' with role read-only, controls, admin, probe or deployer, accepted as bearer tokens and from probes. Setting this or app.auth.oidc.issuer requires every request to be authenticated.")
+	flag.StringVar(&flags.app.authOIDCIssuer, "app.auth.oidc.issuer", "", "URL of an OpenID Connect provider to log users in with. If empty, users can't log in.")
+	flag.StringVar(&flags.app.authOIDCClientID, "app.auth.oidc.client-id", "", "Client ID of the app with the OpenID Connect provider")
+	flag.StringVar(&flags.app.authOIDCClientSecret, "app.auth.oidc.client-secret", "", "Client secret of the app with the OpenID Connect provider")
+	flag.StringVar(&flags.app.authOIDCRedirectURL, "app.auth.oidc.redirect-url", "", "External URL of the /auth/callback handler of the app, as registered with the OpenID Connect provider")
+	flag.StringVar(&flags.app.authOIDCRoleClaim, "app.auth.oidc.role-claim", "groups", "Claim of ID tokens holding the roles or groups of users")
+	flag.StringVar(&flags.app.authOIDCRoles, "app.auth.oidc.roles", "*=read-only", "Comma separated claim=role mappings of the values of the role claim to roles (read-only, controls or admin); claim * is for users with no other")
+	flag.StringVar(&flags.app.authSessionKey, "app.auth.session-key", "", "Secret signing the session cookies of users. If empty, a random one is used and sessions don't survive restarts.")
+	flag.DurationVar(&flags.app.authSessionTTL, "app.auth.session-ttl", 12*time.Hour, "Maximum lifetime of the sessions of users")
+	flag.BoolVar(&flags.app.readReplica, "app.read-replica", false, "Only serve queries, from the shared store of the collector (app.collector, e.g. dynamodb://), refusing the reports and connections of probes, so that dashboards scale independently from ingestion.")
+	flag.BoolVar(&flags.app.mergeAudit, "app.merge-audit", false, "Keep the reports of each probe apart in the local collector, rather than merging those received within seconds, so that admins can audit which of them the metadata of a node is from at /api/admin/merge-audit?topology=<topology>&id=<node ID>. Rendering is slower.")
+	flag.StringVar(&flags.app.invariants, "app.invariants", app.DefaultInvariantChecks, "Check the invariants of merged reports and rendered nodes, to find bugs in staging: log or panic on violations. Empty disables the checks, which are costly.")
+	flag.StringVar(&flags.app.forwardTarget, "app.forward.target", "", "URL of an upstream app to forward the merged report of this app to, e.g. https://<token>@central-app:4040. If empty, reports are not forwarded.")
+	flag.DurationVar(&flags.app.forwardInterval, "app.forward.interval", 15*time.Second, "How often to forward reports to the upstream app. Must be positive.")
+	flag.IntVar(&flags.app.forwardMaxSamples, "app.forward.max-samples", 0, "Number of most recent samples of each metric to forward to the upstream app. If 0, all samples are forwarded.")
+	flag.IntVar(&flags.app.forwardMaxBytesPerSecond, "app.forward.max-bytes-per-second", 0, "Average bandwidth to limit forwarding to, by forwarding less often. If 0, bandwidth is unlimited.")
+	flag.BoolVar(&flags.app.forwardInsecure, "app.forward.insecure", false, "(SSL) explicitly allow \"insecure\" SSL connections to the upstream app")
+
+	flag.StringVar(&flags.app.importBundle, "app.import-bundle", "", "Serve the reports of a bundle written by export-bundle, each at the time it was exported, instead of collecting reports from probes")
+	flag.DurationVar(&flags.app.probeLostTimeout, "app.probe-lost-timeout", 0, "How long the local collector waits for a heartbeat of the probe of a host before marking it lost, e.g. 10s (disabled if 0). Must be less than the window to tell lost probes from quiet hosts.")
+	flag.StringVar(&flags.app.warmupDir, "app.warmup.dir", "", "Directory to keep the last full report of every probe in, to load them on restart so that the local collector doesn't start empty. If empty, reports are not kept.")
+	flag.DurationVar(&flags.app.warmupInterval, "app.warmup.interval", 10*time.Second, "How often to write the reports kept for warmup.")
+	flag.StringVar(&flags.app.clusterPeers, "app.cluster.peers", "", "Comma-separated base URLs of the other replicas of the app (e.g. http://scope-app-1:4040) to share the reports received from probes with, so that any replica answers with the complete picture. If empty, reports are not shared.")
+	flag.IntVar(&flags.app.clusterFanout, "app.cluster.fanout", 0, "How many replicas to gossip with every interval (0 for all of them).")
+	flag.DurationVar(&flags.app.clusterInterval, "app.cluster.interval", time.Second, "Interval between rounds of gossip with the other replicas of the app.")
+	flag.StringVar(&flags.app.clusterToken, "app.cluster.token", "", "Bearer token to authenticate to the other replicas of the app with, as a read-only machine token of theirs.")
+	flag.DurationVar(&flags.app.topologyMetricsInterval, "app.metrics.topology-interval", 0, "If more than 0, export the counts of nodes and edges of the topologies and the bytes sent to the internet as metrics, updated this often, for alerting, in the local collector.")
+	flag.DurationVar(&flags.app.egressTolerance, "app.egress-correlation-tolerance", 0, "If more than 0, attribute the connections probed servers see from NAT gateways to the probed clients behind them, pairing connections first seen this far apart at most, in the local collector.")
+
 	flag.IntVar(&flags.app.blockProfileRate, "app.block.profile.rate", 0, "If more than 0, enable block profiling. The profiler aims to sample an average of one blocking event per rate nanoseconds spent blocked.")
 
 	flag.BoolVar(&flags.app.awsCreateTables, "app.aws.create.tables", false, "Create the tables in DynamoDB")
+	flag.DurationVar(&flags.app.awsCompactAfter, "app.aws.compact-after", 0, "If more than 0, merge the reports of every window of every hour stored in DynamoDB and S3 into a snapshot this long after the hour, so that time travel queries into it read a snapshot or two rather than every report of their window. Must be more than the window.")
+	flag.DurationVar(&flags.app.awsCompactInterval, "app.aws.compact-interval", time.Minute, "How often to check for hours of reports due for compaction.")
+	flag.IntVar(&flags.app.historyCacheSize, "app.history-cache.size", 0, "How many merged reports of past windows to cache in memory, for time travel queries over the same range not to fetch their reports from DynamoDB and S3 again. 0 caches none.")
+	flag.StringVar(&flags.app.historyCacheDir, "app.history-cache.dir", "", "Directory to spill the merged reports evicted from the history cache to. If empty, they are not spilt.")
+	flag.IntVar(&flags.app.historyCacheDiskSize, "app.history-cache.disk-size", 1000, "How many merged reports to keep in the history cache directory.")
 	flag.StringVar(&flags.app.consulInf, "app.consul.inf", "", "The interface who's address I should advertise myself under in consul")
+
+	// Export bundle
+	flag.StringVar(&flags.export.appURL, "export.app", "http://localhost:4040", "URL of the app to export reports from")
+	flag.StringVar(&flags.export.from, "export.from", "", "Start of the time range to export (RFC3339). If empty, defaults to the end of the range minus -export.duration.")
+	flag.StringVar(&flags.export.to, "export.to", "", "End of the time range to export (RFC3339). If empty, defaults to now.")
+	flag.DurationVar(&flags.export.duration, "export.duration", time.Hour, "Length of the time range to export, when -export.from is not set")
+	flag.DurationVar(&flags.export.step, "export.step", 15*time.Second, "Time between successive exported reports")
+	flag.StringVar(&flags.export.output, "export.output", "-", "File to write the bundle to, or - for stdout")
+	flag.StringVar(&flags.export.token, "export.token", "", "Bearer token to authenticate to the app with, e.g. a read-only machine token, if it requires authentication")
 }
 
 func main() {
@@ -383,6 +565,7 @@ func main() {
 	setupFlags(&flags)
 	flags.app.BillingEmitterConfig.RegisterFlags(flag.CommandLine)
 	flags.app.BillingClientConfig.RegisterFlags(flag.CommandLine)
+	probe.RegisterSourceFlags(flag.CommandLine)
 	flag.Parse()
 
 	app.AddContainerFilters(append(flags.containerLabelFilterFlags.apiTopologyOptions, flags.containerLabelFilterFlagsExclude.apiTopologyOptions...)...)
@@ -440,6 +623,10 @@ func main() {
 		}
 	}
 
+	if flags.probe.labels == "" {
+		flags.probe.labels = os.Getenv("SCOPE_PROBE_LABELS")
+	}
+
 	// Node name may be set by environment variable, e.g. from the Kubernetes downward API
 	if flags.probe.kubernetesNodeName == "" {
 		flags.probe.kubernetesNodeName = os.Getenv("KUBERNETES_NODENAME")
@@ -454,6 +641,8 @@ func main() {
 		appMain(flags.app)
 	case "probe":
 		probeMain(flags.probe, targets)
+	case "export-bundle":
+		exportBundleMain(flags.export)
 	case "version":
 		fmt.Println("Weave Scope version", version)
 	case "help":
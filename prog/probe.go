@@ -1,13 +1,19 @@
 package main
 
 import (
+	"fmt"
+	"io/ioutil"
 	"math/rand"
 	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/armon/go-metrics"
@@ -23,18 +29,26 @@ import (
 	"github.com/weaveworks/scope/common/hostname"
 	"github.com/weaveworks/scope/common/weave"
 	"github.com/weaveworks/scope/common/xfer"
+	"github.com/weaveworks/scope/common/xlog"
 	"github.com/weaveworks/scope/probe"
 	"github.com/weaveworks/scope/probe/appclient"
 	"github.com/weaveworks/scope/probe/awsecs"
 	"github.com/weaveworks/scope/probe/controls"
-	"github.com/weaveworks/scope/probe/cri"
+	_ "github.com/weaveworks/scope/probe/cri" // registers itself as a source
 	"github.com/weaveworks/scope/probe/docker"
 	"github.com/weaveworks/scope/probe/endpoint"
 	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/probe/identity"
+	_ "github.com/weaveworks/scope/probe/imageregistry" // registers itself as a source
+	_ "github.com/weaveworks/scope/probe/imagescan"     // registers itself as a source
 	"github.com/weaveworks/scope/probe/kubernetes"
+	_ "github.com/weaveworks/scope/probe/mesh" // registers itself as a source
 	"github.com/weaveworks/scope/probe/overlay"
+	_ "github.com/weaveworks/scope/probe/packages" // registers itself as a source
 	"github.com/weaveworks/scope/probe/plugins"
 	"github.com/weaveworks/scope/probe/process"
+	_ "github.com/weaveworks/scope/probe/snmp"     // registers itself as a source
+	_ "github.com/weaveworks/scope/probe/stableid" // registers itself as a source
 	"github.com/weaveworks/scope/report"
 )
 
@@ -82,6 +96,15 @@ func maybeExportProfileData(flags probeFlags) {
 	if flags.httpListen != "" {
 		go func() {
 			http.Handle("/metrics", prometheus.Handler())
+			// Log levels may only be changed by admins, who the probe
+			// can't authenticate, so they may only be read
+			http.HandleFunc("/debug/log-levels", func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != "GET" {
+					w.WriteHeader(http.StatusMethodNotAllowed)
+					return
+				}
+				xlog.Handler(w, r)
+			})
 			log.Infof("Profiling data being exported to %s", flags.httpListen)
 			log.Infof("go tool pprof http://%s/debug/pprof/{profile,heap,block}", flags.httpListen)
 			log.Infof("Profiling endpoint %s terminated: %v", flags.httpListen, http.ListenAndServe(flags.httpListen, nil))
@@ -89,6 +112,62 @@ func maybeExportProfileData(flags probeFlags) {
 	}
 }
 
+// dumpFlightRecorderOnSignal dumps the flight recorder to stderr on every SIGUSR1
+func dumpFlightRecorderOnSignal(recorder *probe.FlightRecorder) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGUSR1)
+	for range c {
+		if err := recorder.Dump(os.Stderr); err != nil {
+			log.Errorf("Error dumping flight recorder: %v", err)
+		}
+	}
+}
+
+// Capabilities needed by probe features, from linux/capability.h
+const (
+	capNetAdmin  = 12
+	capSysPtrace = 19
+	capSysAdmin  = 21
+)
+
+// effectiveCapabilities reads the effective capability set of the probe
+func effectiveCapabilities(procRoot string) (uint64, error) {
+	buf, err := ioutil.ReadFile(filepath.Join(procRoot, "self", "status"))
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(buf), "\n") {
+		if strings.HasPrefix(line, "CapEff:") {
+			return strconv.ParseUint(strings.TrimSpace(strings.TrimPrefix(line, "CapEff:")), 16, 64)
+		}
+	}
+	return 0, fmt.Errorf("no CapEff in %s/self/status", procRoot)
+}
+
+// permissionProblems lists the capabilities missing for the enabled features
+func permissionProblems(flags probeFlags) []string {
+	caps, err := effectiveCapabilities(flags.procRoot)
+	if err != nil {
+		return []string{fmt.Sprintf("cannot read capabilities: %v", err)}
+	}
+	problems := []string{}
+	for _, c := range []struct {
+		enabled bool
+		cap     uint
+		name    string
+		feature string
+	}{
+		{flags.useConntrack, capNetAdmin, "CAP_NET_ADMIN", "conntrack (--probe.conntrack)"},
+		{flags.useEbpfConn, capSysAdmin, "CAP_SYS_ADMIN", "eBPF connection tracking (--probe.ebpf.connections)"},
+		{flags.spyProcs, capSysPtrace, "CAP_SYS_PTRACE", "associating connections with processes (--probe.proc.spy)"},
+	} {
+		if c.enabled && caps&(1<<c.cap) == 0 {
+			problems = append(problems, fmt.Sprintf("missing %s, needed for %s", c.name, c.feature))
+		}
+	}
+	return problems
+}
+
 // Main runs the probe
 func probeMain(flags probeFlags, targets []appclient.Target) {
 	setLogLevel(flags.logLevel)
@@ -115,6 +194,37 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 		hostName = hostname.Get()
 		hostID   = hostName // TODO(pb): we should sanitize the hostname
 	)
+	var (
+		clusterClient kubernetes.Client
+		clusterName   = flags.kubernetesClusterName
+	)
+	if flags.kubernetesEnabled {
+		if client, err := kubernetes.NewClient(flags.kubernetesClientConfig); err == nil {
+			defer client.Stop()
+			clusterClient = client
+			if clusterName == "" && flags.kubernetesQualifyHostID {
+				if clusterName, err = client.GetClusterUID(); err != nil {
+					log.Warnf("Kubernetes: cannot get the UID of the cluster, the host ID will not be qualified by it: %v", err)
+				}
+			}
+		} else {
+			log.Errorf("Kubernetes: failed to start client: %v", err)
+			log.Errorf("Kubernetes: make sure to run Scope inside a POD with a service account or provide valid probe.kubernetes.* flags")
+		}
+	}
+	if clusterName != "" {
+		// Hosts of different clusters may well have the same name
+		hostID = hostName + "." + clusterName
+	}
+	var signer appclient.Signer
+	if flags.identityDir != "" {
+		if id, err := identity.Load(flags.identityDir); err != nil {
+			log.Warnf("Cannot load the key pair of the probe, using a random ID: %v", err)
+		} else {
+			probeID, signer = id.ID, id
+		}
+	}
+	xlog.SetField("probe", probeID)
 	log.Infof("probe starting, version %s, ID %s", version, probeID)
 	checkNewScopeVersion(flags)
 
@@ -130,6 +240,7 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 			ProbeVersion: version,
 			ProbeID:      probeID,
 			Insecure:     flags.insecure,
+			Signer:       signer,
 		}
 		return appclient.NewAppClient(
 			probeConfig, hostname, url,
@@ -141,6 +252,7 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 		probe.ReportPublisher
 		controls.PipeClient
 	}
+	var peers func() []xfer.Peer
 	if flags.printOnStdout {
 		if len(targets) > 0 {
 			log.Warnf("Dumping to stdout only: targets %v will be ignored", targets)
@@ -192,43 +304,68 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 			}
 		}
 		clients = multiClients
+		peers = multiClients.Peers
 	}
 
 	p := probe.New(flags.spyInterval, flags.publishInterval, clients, flags.noControls)
+	p.SetHeartbeat(hostID)
+	if flags.flightRecorderSize > 0 {
+		recorder := probe.NewFlightRecorder(flags.flightRecorderSize)
+		p.SetFlightRecorder(recorder)
+		http.Handle("/debug/flight-recorder", recorder)
+		go dumpFlightRecorderOnSignal(recorder)
+	}
+	if flags.redactionFile != "" {
+		rules, err := probe.LoadRedactionRules(flags.redactionFile)
+		if err != nil {
+			log.Fatalf("Error loading redaction rules: %v", err)
+		}
+		p.SetRedaction(rules)
+	}
 
 	hostReporter := host.NewReporter(hostID, hostName, probeID, version, clients, handlerRegistry)
-	defer hostReporter.Stop()
 	p.AddReporter(hostReporter)
 	p.AddTagger(probe.NewTopologyTagger(), host.NewTagger(hostID))
 
 	var processCache *process.CachingWalker
 	if flags.procEnabled {
-		processCache = process.NewCachingWalker(process.NewWalker(flags.procRoot, false))
+		processCache = process.NewCachingWalker(process.NewSocketCountingWalker(flags.procRoot, false, flags.maxSocketScanFDs))
 		p.AddTicker(processCache)
 		p.AddReporter(process.NewReporter(processCache, hostID, process.GetDeltaTotalJiffies, flags.noCommandLineArguments))
 	}
 
-	dnsSnooper, err := endpoint.NewDNSSnooper()
-	if err != nil {
-		log.Errorf("Failed to start DNS snooper: nodes for external services will be less accurate: %s", err)
-	} else {
-		defer dnsSnooper.Stop()
-	}
+	if flags.endpointsEnabled {
+		dnsSnooper, err := endpoint.NewDNSSnooper()
+		if err != nil {
+			log.Errorf("Failed to start DNS snooper: nodes for external services will be less accurate: %s", err)
+		} else {
+			defer dnsSnooper.Stop()
+		}
+		var l7Sniffer *endpoint.L7Sniffer
+		if flags.sniffL7 {
+			if l7Sniffer, err = endpoint.NewL7Sniffer(); err != nil {
+				log.Errorf("Failed to start the sniffer of application protocols: %s", err)
+			} else {
+				defer l7Sniffer.Stop()
+			}
+		}
 
-	endpointReporter := endpoint.NewReporter(endpoint.ReporterConfig{
-		HostID:       hostID,
-		HostName:     hostName,
-		SpyProcs:     flags.spyProcs,
-		UseConntrack: flags.useConntrack,
-		WalkProc:     flags.procEnabled,
-		UseEbpfConn:  flags.useEbpfConn,
-		ProcRoot:     flags.procRoot,
-		BufferSize:   flags.conntrackBufferSize,
-		ProcessCache: processCache,
-		DNSSnooper:   dnsSnooper,
-	})
-	defer endpointReporter.Stop()
-	p.AddReporter(endpointReporter)
+		endpointReporter := endpoint.NewReporter(endpoint.ReporterConfig{
+			HostID:       hostID,
+			HostName:     hostName,
+			SpyProcs:     flags.spyProcs,
+			UseConntrack: flags.useConntrack,
+			WalkProc:     flags.procEnabled,
+			UseEbpfConn:  flags.useEbpfConn,
+			UseIPVS:      flags.useIPVS,
+			ProcRoot:     flags.procRoot,
+			BufferSize:   flags.conntrackBufferSize,
+			ProcessCache: processCache,
+			DNSSnooper:   dnsSnooper,
+			L7Sniffer:    l7Sniffer,
+		})
+		p.AddReporter(endpointReporter)
+	}
 
 	if flags.dockerEnabled {
 		// Don't add the bridge in Kubernetes since container IPs are global and
@@ -258,33 +395,15 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 		}
 	}
 
-	if flags.criEnabled {
-		client, err := cri.NewCRIClient(flags.criEndpoint)
-		if err != nil {
-			log.Errorf("CRI: failed to start registry: %v", err)
-		} else {
-			p.AddReporter(cri.NewReporter(client))
-		}
+	if clusterClient != nil {
+		p.Add(kubernetes.NewReporter(clusterClient, clients, probeID, hostID, p, handlerRegistry, flags.kubernetesNodeName, flags.kubernetesKubeletPort))
 	}
-
-	if flags.kubernetesEnabled {
-		if client, err := kubernetes.NewClient(flags.kubernetesClientConfig); err == nil {
-			defer client.Stop()
-			reporter := kubernetes.NewReporter(client, clients, probeID, hostID, p, handlerRegistry, flags.kubernetesNodeName, flags.kubernetesKubeletPort)
-			defer reporter.Stop()
-			p.AddReporter(reporter)
-			p.AddTagger(reporter)
-		} else {
-			log.Errorf("Kubernetes: failed to start client: %v", err)
-			log.Errorf("Kubernetes: make sure to run Scope inside a POD with a service account or provide valid probe.kubernetes.* flags")
-		}
+	if clusterClient != nil || clusterName != "" {
+		p.AddTagger(kubernetes.NewClusterTagger(clusterName, clusterClient))
 	}
 
 	if flags.ecsEnabled {
-		reporter := awsecs.Make(flags.ecsCacheSize, flags.ecsCacheExpiry, flags.ecsClusterRegion, handlerRegistry, probeID)
-		defer reporter.Stop()
-		p.AddReporter(reporter)
-		p.AddTagger(reporter)
+		p.Add(awsecs.Make(flags.ecsCacheSize, flags.ecsCacheExpiry, flags.ecsClusterRegion, handlerRegistry, probeID))
 	}
 
 	if flags.weaveEnabled {
@@ -293,27 +412,52 @@ func probeMain(flags probeFlags, targets []appclient.Target) {
 		if err != nil {
 			log.Errorf("Weave: failed to start client: %v", err)
 		} else {
-			defer weave.Stop()
-			p.AddTagger(weave)
-			p.AddReporter(weave)
+			p.Add(weave)
 		}
 	}
 
-	pluginRegistry, err := plugins.NewRegistry(
-		flags.pluginsRoot,
-		pluginAPIVersion,
-		map[string]string{
-			"probe_id":    probeID,
-			"api_version": pluginAPIVersion,
-		},
-		handlerRegistry,
-		p,
-	)
-	if err != nil {
-		log.Errorf("plugins: problem loading: %v", err)
-	} else {
-		defer pluginRegistry.Close()
-		p.AddReporter(pluginRegistry)
+	if flags.pluginsEnabled {
+		pluginRegistry, err := plugins.NewRegistry(
+			flags.pluginsRoot,
+			pluginAPIVersion,
+			map[string]string{
+				"probe_id":    probeID,
+				"api_version": pluginAPIVersion,
+			},
+			handlerRegistry,
+			p,
+		)
+		if err != nil {
+			log.Errorf("plugins: problem loading: %v", err)
+		} else {
+			p.AddReporter(pluginRegistry)
+		}
+	}
+
+	p.AddSources(probe.Env{
+		HostID:          hostID,
+		HostName:        hostName,
+		ProbeID:         probeID,
+		Probe:           p,
+		HandlerRegistry: handlerRegistry,
+		ProcRoot:        flags.procRoot,
+		Peers:           peers,
+	})
+
+	// Added last, to label the nodes added by other taggers too
+	if labels, err := probe.ParseLabels(flags.labels); err != nil {
+		log.Fatalf("Invalid probe labels: %v", err)
+	} else if len(labels) > 0 {
+		p.AddTagger(probe.NewLabelsTagger(labels))
+	}
+
+	if flags.dryRun {
+		probe.WriteDiagnoses(os.Stdout, p.DryRun())
+		for _, problem := range permissionProblems(flags) {
+			fmt.Fprintf(os.Stdout, "WARNING: %s\n", problem)
+		}
+		p.Stop()
+		return
 	}
 
 	maybeExportProfileData(flags)
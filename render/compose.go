@@ -0,0 +1,39 @@
+package render
+
+import (
+	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/report"
+)
+
+// ComposeProjectRenderer is a Renderer which produces a renderable graph
+// of the Docker Compose projects of the containers, by grouping containers
+// by project. The edges between projects are those of their containers.
+//
+// not memoised
+var ComposeProjectRenderer = FilterEmpty(report.Container,
+	MakeMap(
+		MapContainer2ComposeProject,
+		ContainerWithImageNameRenderer,
+	),
+)
+
+var composeProjectTopology = MakeGroupNodeTopology(report.Container, docker.ComposeProject)
+
+// MapContainer2ComposeProject maps container Nodes to 'compose project'
+// renderable nodes.
+func MapContainer2ComposeProject(n report.Node) report.Node {
+	// Propagate all pseudo nodes
+	if n.Topology == Pseudo {
+		return n
+	}
+
+	// Containers not started by Compose are dropped
+	id, ok := n.Latest.Lookup(docker.ComposeProject)
+	if !ok {
+		return report.Node{}
+	}
+
+	node := NewDerivedNode(id, n).WithTopology(composeProjectTopology)
+	node.Counters = node.Counters.Add(n.Topology, 1)
+	return node
+}
@@ -24,7 +24,7 @@ var UncontainedIDPrefix = MakePseudoNodeID(UncontainedID, "")
 // NB We only want processes in container _or_ processes with network connections
 // but we need to be careful to ensure we only include each edge once, by only
 // including the ProcessRenderer once.
-var ContainerRenderer = Memoise(MakeFilter(
+var ContainerRenderer = Memoise(SumChildMetrics(report.Process, containerProcessMetrics, MakeFilter(
 	func(n report.Node) bool {
 		// Drop deleted containers
 		state, ok := n.Latest.Lookup(docker.ContainerState)
@@ -37,7 +37,7 @@ var ContainerRenderer = Memoise(MakeFilter(
 		),
 		ConnectionJoin(MapContainer2IP, report.Container),
 	),
-))
+)))
 
 const originalNodeID = "original_node_id"
 
@@ -354,3 +354,62 @@ func MapContainer2Hostname(n report.Node) report.Node {
 func MapToEmpty(n report.Node) report.Node {
 	return report.MakeNode(n.ID).WithTopology(n.Topology)
 }
+
+// DockerNetworkRenderer is a Renderer which produces a renderable graph
+// of the Docker networks, with the containers attached to them as
+// children, and the edges between networks those of their containers.
+//
+// not memoised
+var DockerNetworkRenderer = MakeReduce(
+	SelectDockerNetwork,
+	CustomRenderer{RenderFunc: containers2Networks, Renderer: ContainerWithImageNameRenderer},
+)
+
+// containers2Networks maps containers to the networks they are attached
+// to, dropping those attached to none.
+func containers2Networks(nodes Nodes) Nodes {
+	ret := newJoinResults(nil)
+	for _, n := range nodes.Nodes {
+		if n.Topology == Pseudo {
+			continue
+		}
+		networkIDs, _ := n.Parents.Lookup(report.DockerNetwork)
+		for _, id := range networkIDs {
+			ret.addChild(n, id, report.DockerNetwork)
+		}
+	}
+	return ret.result(nodes)
+}
+
+// ContainerWithVolumesRenderer is a Renderer which produces a renderable
+// container graph with the Docker volumes the containers mount, and edges
+// from the containers to them.
+var ContainerWithVolumesRenderer = containerWithVolumesRenderer{ContainerWithImageNameRenderer}
+
+type containerWithVolumesRenderer struct {
+	Renderer
+}
+
+// Render renders the containers, along with the volumes they mount.
+func (r containerWithVolumesRenderer) Render(rpt report.Report) Nodes {
+	containers := r.Renderer.Render(rpt)
+	outputs := make(report.Nodes, len(containers.Nodes))
+	for id, c := range containers.Nodes {
+		volumeIDs, _ := c.Parents.Lookup(report.DockerVolume)
+		for _, volumeID := range volumeIDs {
+			volume, ok := rpt.DockerVolume.Nodes[volumeID]
+			if !ok {
+				continue
+			}
+			c.Adjacency = c.Adjacency.Add(volumeID)
+			if existing, ok := outputs[volumeID]; ok {
+				volume = existing
+			}
+			volume.Children = volume.Children.Add(c)
+			volume.Counters = volume.Counters.Add(report.Container, 1)
+			outputs[volumeID] = volume
+		}
+		outputs[id] = c
+	}
+	return Nodes{Nodes: outputs, Filtered: containers.Filtered}
+}
@@ -112,3 +112,79 @@ func TestContainerImageFilterRenderer(t *testing.T) {
 		t.Error(test.Diff(want, have))
 	}
 }
+
+func TestComposeProjectRenderer(t *testing.T) {
+	// Only the containers started by Compose are grouped
+	for id, n := range render.Render(fixture.Report, render.ComposeProjectRenderer, render.FilterUnconnectedPseudo).Nodes {
+		if n.Topology != render.Pseudo {
+			t.Errorf("expected no projects, have %s", id)
+		}
+	}
+
+	input := fixture.Report.Copy()
+	for _, id := range []string{fixture.ClientContainerNodeID, fixture.ServerContainerNodeID} {
+		input.Container.Nodes[id] = input.Container.Nodes[id].WithLatests(map[string]string{
+			docker.ComposeProject: "shop",
+		})
+	}
+	have := render.Render(input, render.ComposeProjectRenderer, render.FilterUnconnectedPseudo).Nodes
+	project, ok := have["shop"]
+	if !ok {
+		t.Fatalf("expected the containers to be grouped by project, have %v", have)
+	}
+	if containers, _ := project.Counters.Lookup(report.Container); containers != 2 {
+		t.Errorf("expected 2 containers in the project, have %d", containers)
+	}
+	_, hasClient := project.Children.Lookup(fixture.ClientContainerNodeID)
+	_, hasServer := project.Children.Lookup(fixture.ServerContainerNodeID)
+	if !hasClient || !hasServer {
+		t.Errorf("expected the containers as children of the project, have %v", project.Children)
+	}
+}
+
+func TestDockerNetworkRenderer(t *testing.T) {
+	network := report.MakeDockerNetworkNodeID("deadbeef")
+	input := fixture.Report.Copy()
+	input.DockerNetwork.AddNode(report.MakeNodeWith(network, map[string]string{
+		docker.NetworkName: "backend",
+	}).WithTopology(report.DockerNetwork))
+	input.Container.Nodes[fixture.ClientContainerNodeID] = input.Container.Nodes[fixture.ClientContainerNodeID].
+		WithParent(report.DockerNetwork, network)
+
+	have := render.DockerNetworkRenderer.Render(input).Nodes
+	if len(have) != 1 {
+		t.Fatalf("expected only the network, got %v", have)
+	}
+	if _, ok := have[network].Children.Lookup(fixture.ClientContainerNodeID); !ok {
+		t.Errorf("expected the attached container as a child, got %v", have[network].Children)
+	}
+	if name, _ := have[network].Latest.Lookup(docker.NetworkName); name != "backend" {
+		t.Errorf("expected the metadata of the network, got %q", name)
+	}
+}
+
+func TestContainerWithVolumesRenderer(t *testing.T) {
+	volume := report.MakeDockerVolumeNodeID(docker.VolumeNodeKey("host", "data"))
+	input := fixture.Report.Copy()
+	input.DockerVolume.AddNode(report.MakeNodeWith(volume, map[string]string{
+		docker.VolumeName: "data",
+	}).WithTopology(report.DockerVolume))
+	input.Container.Nodes[fixture.ClientContainerNodeID] = input.Container.Nodes[fixture.ClientContainerNodeID].
+		WithParent(report.DockerVolume, volume)
+
+	have := render.ContainerWithVolumesRenderer.Render(input).Nodes
+	if !have[fixture.ClientContainerNodeID].Adjacency.Contains(volume) {
+		t.Errorf("expected an edge from the container to its volume, got %v", have[fixture.ClientContainerNodeID].Adjacency)
+	}
+	if containers, _ := have[volume].Counters.Lookup(report.Container); containers != 1 {
+		t.Errorf("expected the volume to be mounted by 1 container, got %d", containers)
+	}
+
+	// Hidden with the storage
+	filtered := render.Render(input, render.ContainerWithVolumesRenderer, render.Transformers([]render.Transformer{
+		render.FilterFunc(render.IsPodComponent),
+	})).Nodes
+	if _, ok := filtered[volume]; ok {
+		t.Error("expected the volume to be hidden with the storage")
+	}
+}
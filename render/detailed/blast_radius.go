@@ -0,0 +1,106 @@
+package detailed
+
+import (
+	"sort"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// Directions in which BlastRadius can walk the graph.
+const (
+	// Downstream follows edges in the direction of traffic, i.e. towards
+	// the nodes a node connects to.
+	Downstream = "downstream"
+	// Upstream follows edges against the direction of traffic, i.e.
+	// towards the nodes which connect to a node.
+	Upstream = "upstream"
+)
+
+// ImpactedNode is a node reached by BlastRadius.
+type ImpactedNode struct {
+	BasicNodeSummary
+	Depth int `json:"depth"`
+}
+
+// Impact is the result of BlastRadius.
+type Impact struct {
+	Nodes []ImpactedNode `json:"nodes"`
+	Edges []WeightedEdge `json:"edges"`
+}
+
+// BlastRadius walks the rendered nodes transitively from the node with
+// id, in the given direction, and returns every node reached along
+// with the edges traversed. Edges carrying fewer than minWeight
+// connections are not followed. A maxDepth of zero means no limit.
+func BlastRadius(r report.Report, ns report.Nodes, id, direction string, maxDepth, minWeight int) Impact {
+	impact := Impact{Nodes: []ImpactedNode{}, Edges: []WeightedEdge{}}
+	if _, ok := ns[id]; !ok {
+		return impact
+	}
+
+	// Index incoming edges, for walking upstream
+	incoming := map[string][]string{}
+	if direction == Upstream {
+		for srcID, n := range ns {
+			for _, dstID := range n.Adjacency {
+				incoming[dstID] = append(incoming[dstID], srcID)
+			}
+		}
+	}
+
+	depths := map[string]int{id: 0}
+	frontier := []string{id}
+	for depth := 1; len(frontier) > 0 && (maxDepth <= 0 || depth <= maxDepth); depth++ {
+		next := []string{}
+		for _, nodeID := range frontier {
+			neighbours := []string(ns[nodeID].Adjacency)
+			if direction == Upstream {
+				neighbours = incoming[nodeID]
+			}
+			for _, neighbourID := range neighbours {
+				neighbour, ok := ns[neighbourID]
+				if !ok || neighbourID == nodeID {
+					continue
+				}
+				edge := Edge{Source: nodeID, Target: neighbourID}
+				src, dst := ns[nodeID], neighbour
+				if direction == Upstream {
+					edge = Edge{Source: neighbourID, Target: nodeID}
+					src, dst = neighbour, ns[nodeID]
+				}
+				weight := edgeWeight(src, dst)
+				if weight < minWeight {
+					continue
+				}
+				impact.Edges = append(impact.Edges, WeightedEdge{Edge: edge, Weight: weight})
+				if _, seen := depths[neighbourID]; !seen {
+					depths[neighbourID] = depth
+					next = append(next, neighbourID)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	for nodeID, depth := range depths {
+		if nodeID == id {
+			continue
+		}
+		summary, ok := MakeBasicNodeSummary(r, ns[nodeID])
+		if !ok {
+			continue
+		}
+		impact.Nodes = append(impact.Nodes, ImpactedNode{BasicNodeSummary: summary, Depth: depth})
+	}
+	sort.Sort(impactedNodesByDepth(impact.Nodes))
+	sort.Sort(weightedEdgesByID(impact.Edges))
+	return impact
+}
+
+type impactedNodesByDepth []ImpactedNode
+
+func (s impactedNodesByDepth) Len() int      { return len(s) }
+func (s impactedNodesByDepth) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s impactedNodesByDepth) Less(i, j int) bool {
+	return s[i].Depth < s[j].Depth || (s[i].Depth == s[j].Depth && s[i].ID < s[j].ID)
+}
@@ -0,0 +1,56 @@
+package detailed_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+func TestBlastRadius(t *testing.T) {
+	nodes := render.ProcessRenderer.Render(fixture.Report).Nodes
+	ids := func(impact detailed.Impact) []string {
+		result := []string{}
+		for _, n := range impact.Nodes {
+			result = append(result, n.ID)
+		}
+		return result
+	}
+
+	{
+		have := detailed.BlastRadius(fixture.Report, nodes, fixture.ClientProcess1NodeID, detailed.Downstream, 0, 0)
+		want := []detailed.WeightedEdge{{Edge: detailed.Edge{Source: fixture.ClientProcess1NodeID, Target: fixture.ServerProcessNodeID}, Weight: 1}}
+		if !reflect.DeepEqual(want, have.Edges) {
+			t.Error(test.Diff(want, have.Edges))
+		}
+		if want, have := []string{fixture.ServerProcessNodeID}, ids(have); !reflect.DeepEqual(want, have) {
+			t.Error(test.Diff(want, have))
+		}
+	}
+
+	{
+		have := detailed.BlastRadius(fixture.Report, nodes, fixture.ServerProcessNodeID, detailed.Upstream, 1, 0)
+		want := []string{fixture.ClientProcess1NodeID, fixture.ClientProcess2NodeID, render.IncomingInternetID}
+		if !reflect.DeepEqual(want, ids(have)) {
+			t.Error(test.Diff(want, ids(have)))
+		}
+		for _, n := range have.Nodes {
+			if n.Depth != 1 {
+				t.Errorf("%s: expected depth 1, got %d", n.ID, n.Depth)
+			}
+		}
+	}
+
+	// Edges below the weight threshold are not followed
+	if have := detailed.BlastRadius(fixture.Report, nodes, fixture.ClientProcess1NodeID, detailed.Downstream, 0, 2); len(have.Nodes) != 0 {
+		t.Errorf("expected no impacted nodes, got %v", ids(have))
+	}
+
+	// Unknown nodes have no impact
+	if have := detailed.BlastRadius(fixture.Report, nodes, "foo", detailed.Downstream, 0, 0); len(have.Nodes) != 0 || len(have.Edges) != 0 {
+		t.Errorf("expected empty impact, got %v", have)
+	}
+}
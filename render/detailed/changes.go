@@ -0,0 +1,192 @@
+package detailed
+
+import (
+	"math"
+	"sort"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// MetricShift describes a metric of a node whose value changed by
+// more than the requested threshold between two points in time.
+type MetricShift struct {
+	NodeID string  `json:"nodeId"`
+	ID     string  `json:"id"`
+	Label  string  `json:"label"`
+	Format string  `json:"format,omitempty"`
+	From   float64 `json:"from"`
+	To     float64 `json:"to"`
+	// Change is the relative change, measured against the larger of
+	// the two values so that it stays within [-2, 2], e.g. doubling is
+	// 0.5 and halving is -0.5.
+	Change float64 `json:"change"`
+}
+
+type metricShiftsByID []MetricShift
+
+func (m metricShiftsByID) Len() int      { return len(m) }
+func (m metricShiftsByID) Swap(i, j int) { m[i], m[j] = m[j], m[i] }
+func (m metricShiftsByID) Less(i, j int) bool {
+	return m[i].NodeID < m[j].NodeID || (m[i].NodeID == m[j].NodeID && m[i].ID < m[j].ID)
+}
+
+// Changes is returned by CompareSummaries. It represents the
+// differences between a topology at two points in time.
+type Changes struct {
+	Added        []BasicNodeSummary `json:"added"`
+	Removed      []BasicNodeSummary `json:"removed"`
+	AddedEdges   []Edge             `json:"addedEdges"`
+	RemovedEdges []Edge             `json:"removedEdges"`
+	MetricShifts []MetricShift      `json:"metricShifts"`
+	// Restarted are the nodes which got a new ID, but kept their
+	// stable ID, so are neither added nor removed.
+	Restarted []Restart `json:"restarted,omitempty"`
+}
+
+// Restart is a node which got a new ID.
+type Restart struct {
+	StableID string `json:"stableId"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+}
+
+type restartsByID []Restart
+
+func (r restartsByID) Len() int           { return len(r) }
+func (r restartsByID) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+func (r restartsByID) Less(i, j int) bool { return r[i].StableID < r[j].StableID }
+
+// CompareSummaries gives you the changes to get from a to b. Metrics
+// are reported as shifted when their relative change is at least
+// threshold (e.g. 0.5 for a doubling or halving). Nodes of a and b with
+// different IDs but the same stable ID are compared as one node, and so
+// are the targets of their edges.
+func CompareSummaries(a, b NodeSummaries, threshold float64) Changes {
+	changes := Changes{
+		Added:        []BasicNodeSummary{},
+		Removed:      []BasicNodeSummary{},
+		AddedEdges:   []Edge{},
+		RemovedEdges: []Edge{},
+		MetricShifts: []MetricShift{},
+	}
+
+	// The nodes of a which are gone from b, by stable ID, for the nodes
+	// of b new to a to take over
+	gone := map[string]NodeSummary{}
+	for id, node := range a {
+		if _, ok := b[id]; !ok && node.StableID != "" {
+			gone[node.StableID] = node
+		}
+	}
+	restarted := map[string]bool{}
+
+	for id, node := range b {
+		before, ok := a[id]
+		if !ok && node.StableID != "" {
+			if before, ok = gone[node.StableID]; ok {
+				delete(gone, node.StableID)
+				restarted[before.ID] = true
+				changes.Restarted = append(changes.Restarted, Restart{StableID: node.StableID, From: before.ID, To: id})
+			}
+		}
+		if !ok {
+			changes.Added = append(changes.Added, node.BasicNodeSummary)
+			for _, dst := range node.Adjacency {
+				changes.AddedEdges = append(changes.AddedEdges, Edge{Source: id, Target: dst})
+			}
+			continue
+		}
+		targetsBefore, targetsAfter := targetKeys(a, before.Adjacency), targetKeys(b, node.Adjacency)
+		for _, dst := range node.Adjacency {
+			if !targetsBefore[targetKey(b, dst)] {
+				changes.AddedEdges = append(changes.AddedEdges, Edge{Source: id, Target: dst})
+			}
+		}
+		for _, dst := range before.Adjacency {
+			if !targetsAfter[targetKey(a, dst)] {
+				changes.RemovedEdges = append(changes.RemovedEdges, Edge{Source: id, Target: dst})
+			}
+		}
+		changes.MetricShifts = append(changes.MetricShifts, metricShifts(before, node, threshold)...)
+	}
+
+	for id, node := range a {
+		if _, ok := b[id]; ok || restarted[id] {
+			continue
+		}
+		changes.Removed = append(changes.Removed, node.BasicNodeSummary)
+		for _, dst := range node.Adjacency {
+			changes.RemovedEdges = append(changes.RemovedEdges, Edge{Source: id, Target: dst})
+		}
+	}
+
+	sort.Sort(basicNodeSummariesByID(changes.Added))
+	sort.Sort(basicNodeSummariesByID(changes.Removed))
+	sort.Sort(edgesByID(changes.AddedEdges))
+	sort.Sort(edgesByID(changes.RemovedEdges))
+	sort.Sort(metricShiftsByID(changes.MetricShifts))
+	sort.Sort(restartsByID(changes.Restarted))
+	return changes
+}
+
+// targetKey identifies the target of an edge by its stable ID, if any,
+// so that edges to nodes which restarted with a new ID are the same.
+func targetKey(nodes NodeSummaries, id string) string {
+	if n, ok := nodes[id]; ok && n.StableID != "" {
+		// Stable IDs are kept apart from node IDs, which never start
+		// with a NUL
+		return "\x00" + n.StableID
+	}
+	return id
+}
+
+func targetKeys(nodes NodeSummaries, ids report.IDList) map[string]bool {
+	keys := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		keys[targetKey(nodes, id)] = true
+	}
+	return keys
+}
+
+func metricShifts(a, b NodeSummary, threshold float64) []MetricShift {
+	before := map[string]float64{}
+	for _, m := range a.Metrics {
+		if !m.ValueEmpty {
+			before[m.ID] = m.Value
+		}
+	}
+	var result []MetricShift
+	for _, m := range b.Metrics {
+		from, ok := before[m.ID]
+		if !ok || m.ValueEmpty {
+			continue
+		}
+		change := relativeChange(from, m.Value)
+		if math.Abs(change) < threshold {
+			continue
+		}
+		result = append(result, MetricShift{
+			NodeID: b.ID,
+			ID:     m.ID,
+			Label:  m.Label,
+			Format: m.Format,
+			From:   from,
+			To:     m.Value,
+			Change: change,
+		})
+	}
+	return result
+}
+
+func relativeChange(from, to float64) float64 {
+	if from == to {
+		return 0
+	}
+	return (to - from) / math.Max(math.Abs(from), math.Abs(to))
+}
+
+type basicNodeSummariesByID []BasicNodeSummary
+
+func (s basicNodeSummariesByID) Len() int           { return len(s) }
+func (s basicNodeSummariesByID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s basicNodeSummariesByID) Less(i, j int) bool { return s[i].ID < s[j].ID }
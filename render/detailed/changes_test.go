@@ -0,0 +1,98 @@
+package detailed_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestCompareSummaries(t *testing.T) {
+	summary := func(id string, cpu float64, adjacency ...string) detailed.NodeSummary {
+		return detailed.NodeSummary{
+			BasicNodeSummary: detailed.BasicNodeSummary{ID: id, Label: id},
+			Metrics:          []report.MetricRow{{ID: "cpu", Label: "CPU", Value: cpu}},
+			Adjacency:        report.MakeIDList(adjacency...),
+		}
+	}
+	nodes := func(ns ...detailed.NodeSummary) detailed.NodeSummaries {
+		r := detailed.NodeSummaries{}
+		for _, n := range ns {
+			r[n.ID] = n
+		}
+		return r
+	}
+
+	before := nodes(summary("a", 10, "b"), summary("b", 10, "c"), summary("c", 10))
+	after := nodes(summary("a", 12, "d"), summary("b", 30, "c"), summary("d", 1))
+
+	have := detailed.CompareSummaries(before, after, 0.5)
+	want := detailed.Changes{
+		Added:        []detailed.BasicNodeSummary{{ID: "d", Label: "d"}},
+		Removed:      []detailed.BasicNodeSummary{{ID: "c", Label: "c"}},
+		AddedEdges:   []detailed.Edge{{Source: "a", Target: "d"}},
+		RemovedEdges: []detailed.Edge{{Source: "a", Target: "b"}},
+		MetricShifts: []detailed.MetricShift{{NodeID: "b", ID: "cpu", Label: "CPU", From: 10, To: 30, Change: 20.0 / 30.0}},
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+
+	if have := detailed.CompareSummaries(before, before, 0.5); len(have.Added)+len(have.Removed)+len(have.AddedEdges)+len(have.RemovedEdges)+len(have.MetricShifts) != 0 {
+		t.Errorf("expected no changes, got %v", have)
+	}
+}
+
+func TestCompareSummariesRestarts(t *testing.T) {
+	summary := func(id, stableID string, adjacency ...string) detailed.NodeSummary {
+		return detailed.NodeSummary{
+			BasicNodeSummary: detailed.BasicNodeSummary{ID: id, Label: id, StableID: stableID},
+			Adjacency:        report.MakeIDList(adjacency...),
+		}
+	}
+	before := detailed.NodeSummaries{"a": summary("a", "web", "db"), "b": summary("b", ""), "c": summary("c", "", "a"), "db": summary("db", "db")}
+	after := detailed.NodeSummaries{"a2": summary("a2", "web", "db2"), "b2": summary("b2", ""), "c": summary("c", "", "a2"), "db2": summary("db2", "db")}
+
+	have := detailed.CompareSummaries(before, after, 0.5)
+	if want := []detailed.Restart{{StableID: "db", From: "db", To: "db2"}, {StableID: "web", From: "a", To: "a2"}}; !reflect.DeepEqual(want, have.Restarted) {
+		t.Error(test.Diff(want, have.Restarted))
+	}
+	if len(have.Added) != 1 || have.Added[0].ID != "b2" || len(have.Removed) != 1 || have.Removed[0].ID != "b" {
+		t.Errorf("expected only b to be replaced, got %v", have)
+	}
+	if len(have.AddedEdges)+len(have.RemovedEdges) != 0 {
+		t.Errorf("expected the edges between restarted nodes to stay, got %v", have)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	summary := func(id string, cpu float64, adjacency ...string) detailed.NodeSummary {
+		return detailed.NodeSummary{
+			BasicNodeSummary: detailed.BasicNodeSummary{ID: id, Label: id},
+			Metrics:          []report.MetricRow{{ID: "cpu", Label: "CPU", Value: cpu}},
+			Adjacency:        report.MakeIDList(adjacency...),
+		}
+	}
+	before := detailed.NodeSummaries{"a": summary("a", 10, "b"), "b": summary("b", 10, "c"), "c": summary("c", 10), "e": summary("e", 10)}
+	after := detailed.NodeSummaries{"a": summary("a", 10, "d"), "b": summary("b", 30, "c"), "d": summary("d", 1), "e": summary("e", 10)}
+
+	have := detailed.Compare(before, after, 0.5)
+	statuses := map[string]string{}
+	for id, n := range have.Nodes {
+		statuses[id] = n.Status
+	}
+	want := map[string]string{"a": detailed.Changed, "b": detailed.Changed, "c": detailed.Removed, "d": detailed.Added, "e": detailed.Unchanged}
+	if !reflect.DeepEqual(want, statuses) {
+		t.Error(test.Diff(want, statuses))
+	}
+	wantEdges := []detailed.ComparedEdge{
+		{Edge: detailed.Edge{Source: "a", Target: "b"}, Status: detailed.Removed},
+		{Edge: detailed.Edge{Source: "a", Target: "d"}, Status: detailed.Added},
+		{Edge: detailed.Edge{Source: "b", Target: "c"}, Status: detailed.Unchanged},
+	}
+	if !reflect.DeepEqual(wantEdges, have.Edges) {
+		t.Error(test.Diff(wantEdges, have.Edges))
+	}
+}
@@ -0,0 +1,111 @@
+package detailed
+
+import (
+	"sort"
+)
+
+// Statuses of the nodes and edges of a Comparison.
+const (
+	Unchanged = "unchanged"
+	Added     = "added"
+	Removed   = "removed"
+	Changed   = "changed"
+)
+
+// ComparedNode is a node of a Comparison. The summary is the latest one,
+// or the earlier one for nodes removed.
+type ComparedNode struct {
+	NodeSummary
+	Status string `json:"status"`
+	// MetricShifts are those of the node, for nodes changed
+	MetricShifts []MetricShift `json:"metricShifts,omitempty"`
+	// RestartedFrom is the earlier ID of nodes which got a new one
+	RestartedFrom string `json:"restartedFrom,omitempty"`
+}
+
+// ComparedEdge is an edge of a Comparison.
+type ComparedEdge struct {
+	Edge
+	Status string `json:"status"`
+}
+
+// Comparison is a topology at two points in time as a single graph, with
+// the nodes and edges of both, marked added, removed, changed or
+// unchanged.
+type Comparison struct {
+	Nodes map[string]ComparedNode `json:"nodes"`
+	Edges []ComparedEdge          `json:"edges"`
+}
+
+type comparedEdgesByID []ComparedEdge
+
+func (e comparedEdgesByID) Len() int      { return len(e) }
+func (e comparedEdgesByID) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e comparedEdgesByID) Less(i, j int) bool {
+	return e[i].Source < e[j].Source || (e[i].Source == e[j].Source && e[i].Target < e[j].Target)
+}
+
+// Compare merges the topologies a and b into one graph. Nodes are
+// changed when their edges, or metrics by at least threshold, changed,
+// or when they restarted with a new ID, as in CompareSummaries.
+func Compare(a, b NodeSummaries, threshold float64) Comparison {
+	return CompareChanges(a, b, CompareSummaries(a, b, threshold))
+}
+
+// CompareChanges merges the topologies a and b into one graph, marking
+// the nodes and edges of changes only, e.g. once some were left out.
+func CompareChanges(a, b NodeSummaries, changes Changes) Comparison {
+	var (
+		comparison = Comparison{Nodes: map[string]ComparedNode{}, Edges: []ComparedEdge{}}
+		statuses   = map[string]string{}
+		edges      = map[Edge]string{}
+	)
+	for _, n := range changes.Added {
+		statuses[n.ID] = Added
+	}
+	for _, e := range changes.AddedEdges {
+		edges[e] = Added
+		if statuses[e.Source] == "" {
+			statuses[e.Source] = Changed
+		}
+	}
+	for _, e := range changes.RemovedEdges {
+		edges[e] = Removed
+		if _, ok := b[e.Source]; ok && statuses[e.Source] == "" {
+			statuses[e.Source] = Changed
+		}
+	}
+	shifts := map[string][]MetricShift{}
+	for _, s := range changes.MetricShifts {
+		shifts[s.NodeID] = append(shifts[s.NodeID], s)
+	}
+	restartedFrom := map[string]string{}
+	for _, r := range changes.Restarted {
+		restartedFrom[r.To] = r.From
+	}
+
+	for id, n := range b {
+		status := statuses[id]
+		if status == "" && (len(shifts[id]) > 0 || restartedFrom[id] != "") {
+			status = Changed
+		} else if status == "" {
+			status = Unchanged
+		}
+		comparison.Nodes[id] = ComparedNode{NodeSummary: n, Status: status, MetricShifts: shifts[id], RestartedFrom: restartedFrom[id]}
+		for _, dst := range n.Adjacency {
+			e := Edge{Source: id, Target: dst}
+			if _, ok := edges[e]; !ok {
+				edges[e] = Unchanged
+			}
+		}
+	}
+	for _, n := range changes.Removed {
+		comparison.Nodes[n.ID] = ComparedNode{NodeSummary: a[n.ID], Status: Removed}
+	}
+
+	for e, status := range edges {
+		comparison.Edges = append(comparison.Edges, ComparedEdge{Edge: e, Status: status})
+	}
+	sort.Sort(comparedEdgesByID(comparison.Edges))
+	return comparison
+}
@@ -93,11 +93,17 @@ func (c *connectionCounters) add(dns report.DNSRecords, outgoing bool, localNode
 	if _, _, conn.port, ok = report.ParseEndpointNodeID(dstEndpoint.ID); !ok {
 		return
 	}
-	// For internet nodes we break out individual addresses
-	if conn.remoteAddr, ok = internetAddr(dns, remoteNode, remoteEndpoint); !ok {
+	// For internet nodes we break out individual addresses, named after
+	// the server name TLS clients asked for, if any, as it tells apart
+	// the services sharing addresses better than DNS
+	serverName, _ := srcEndpoint.Latest.Lookup(endpoint.TLSServerName)
+	if !outgoing {
+		serverName = ""
+	}
+	if conn.remoteAddr, ok = internetAddr(dns, remoteNode, remoteEndpoint, serverName); !ok {
 		return
 	}
-	if conn.localAddr, ok = internetAddr(dns, localNode, localEndpoint); !ok {
+	if conn.localAddr, ok = internetAddr(dns, localNode, localEndpoint, ""); !ok {
 		return
 	}
 
@@ -105,7 +111,7 @@ func (c *connectionCounters) add(dns report.DNSRecords, outgoing bool, localNode
 	c.counts[conn]++
 }
 
-func internetAddr(dns report.DNSRecords, node report.Node, ep report.Node) (string, bool) {
+func internetAddr(dns report.DNSRecords, node report.Node, ep report.Node, serverName string) (string, bool) {
 	if !render.IsInternetNode(node) {
 		return "", true
 	}
@@ -113,7 +119,9 @@ func internetAddr(dns report.DNSRecords, node report.Node, ep report.Node) (stri
 	if !ok {
 		return "", false
 	}
-	if name, found := dns.FirstMatch(ep.ID, func(string) bool { return true }); found {
+	if serverName != "" {
+		addr = fmt.Sprintf("%s (%s)", serverName, addr)
+	} else if name, found := dns.FirstMatch(ep.ID, func(string) bool { return true }); found {
 		// we show the "most important" name only, since we don't have
 		// space for more
 		addr = fmt.Sprintf("%s (%s)", name, addr)
@@ -0,0 +1,57 @@
+package detailed
+
+import (
+	"sort"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+)
+
+// Relations of the nodes links point to.
+const (
+	ParentRelation = "parent"
+	ChildRelation  = "child"
+)
+
+// Link is a reference to an entity related to a node, in the view it is
+// shown in, so that clients can offer to view a node's host, pods or
+// processes without deriving the relationships themselves.
+type Link struct {
+	Relation   string `json:"relation"`
+	ID         string `json:"id"`
+	Label      string `json:"label"`
+	TopologyID string `json:"topologyId"`
+}
+
+// links renders the links of a node to its parents, in the order of
+// Parents, then to its children, by view and label.
+func links(r report.Report, n report.Node, parents []Parent) []Link {
+	var links []Link
+	for _, p := range parents {
+		links = append(links, Link{Relation: ParentRelation, ID: p.ID, Label: p.Label, TopologyID: p.TopologyID})
+	}
+
+	var children []Link
+	n.Children.ForEach(func(child report.Node) {
+		if child.ID == n.ID || child.Topology == render.Pseudo {
+			return
+		}
+		apiTopologyID, ok := primaryAPITopology[child.Topology]
+		if !ok {
+			return
+		}
+		if summary, ok := MakeBasicNodeSummary(r, child); ok {
+			children = append(children, Link{Relation: ChildRelation, ID: summary.ID, Label: summary.Label, TopologyID: apiTopologyID})
+		}
+	})
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].TopologyID != children[j].TopologyID {
+			return children[i].TopologyID < children[j].TopologyID
+		}
+		if children[i].Label != children[j].Label {
+			return children[i].Label < children[j].Label
+		}
+		return children[i].ID < children[j].ID
+	})
+	return append(links, children...)
+}
@@ -0,0 +1,535 @@
+package detailed
+
+import (
+	"sort"
+
+	"github.com/weaveworks/scope/probe/endpoint"
+	"github.com/weaveworks/scope/probe/mesh"
+	"github.com/weaveworks/scope/report"
+)
+
+// EdgeIDSeparator joins the source and target of an edge into its ID, as
+// in the UI.
+const EdgeIDSeparator = "---"
+
+// Edge is a directed edge between two rendered nodes.
+type Edge struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+// ID of the edge, as the UI makes them.
+func (e Edge) ID() string {
+	return e.Source + EdgeIDSeparator + e.Target
+}
+
+type edgesByID []Edge
+
+func (e edgesByID) Len() int      { return len(e) }
+func (e edgesByID) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e edgesByID) Less(i, j int) bool {
+	return e[i].Source < e[j].Source || (e[i].Source == e[j].Source && e[i].Target < e[j].Target)
+}
+
+// WeightedEdge is an Edge along with the number of connections it
+// represents.
+type WeightedEdge struct {
+	Edge
+	Weight int `json:"weight"`
+}
+
+type weightedEdgesByID []WeightedEdge
+
+func (e weightedEdgesByID) Len() int      { return len(e) }
+func (e weightedEdgesByID) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e weightedEdgesByID) Less(i, j int) bool {
+	return edgesByID{e[i].Edge, e[j].Edge}.Less(0, 1)
+}
+
+// EdgeWeights returns the weight of every edge between the rendered
+// nodes.
+func EdgeWeights(ns report.Nodes) map[Edge]int {
+	weights := map[Edge]int{}
+	for srcID, src := range ns {
+		for _, dstID := range src.Adjacency {
+			if dst, ok := ns[dstID]; ok {
+				weights[Edge{Source: srcID, Target: dstID}] = edgeWeight(src, dst)
+			}
+		}
+	}
+	return weights
+}
+
+// EdgeBytes returns the bytes sent over every edge between the rendered
+// nodes over which any were.
+func EdgeBytes(ns report.Nodes) map[Edge]int {
+	bytes := map[Edge]int{}
+	for srcID, src := range ns {
+		for _, dstID := range src.Adjacency {
+			if dst, ok := ns[dstID]; ok {
+				if sent := edgeCount(src, dst, endpoint.BytesSent); sent > 0 {
+					bytes[Edge{Source: srcID, Target: dstID}] = sent
+				}
+			}
+		}
+	}
+	return bytes
+}
+
+// edgeWeight counts the connections between the endpoints of src and
+// the endpoints of dst.
+func edgeWeight(src, dst report.Node) int {
+	dstEndpointIDs, _ := endpointChildIDsAndCopyMapOf(dst)
+	weight := 0
+	for _, ep := range endpointChildrenOf(src) {
+		weight += len(ep.Adjacency.Intersection(dstEndpointIDs))
+	}
+	return weight
+}
+
+// FailedEdge is a WeightedEdge over which some connection attempts were
+// refused or timed out. An edge is broken when none of its connections
+// were established.
+type FailedEdge struct {
+	WeightedEdge
+	Failures int  `json:"failures"`
+	Broken   bool `json:"broken"`
+}
+
+// FailedEdges returns the edges between the rendered nodes which carry
+// failed connection attempts.
+func FailedEdges(ns report.Nodes) []FailedEdge {
+	result := []FailedEdge{}
+	for srcID, src := range ns {
+		for _, dstID := range src.Adjacency {
+			dst, ok := ns[dstID]
+			if !ok {
+				continue
+			}
+			failures := edgeCount(src, dst, report.ConnectionFailures)
+			if failures == 0 {
+				continue
+			}
+			weight := edgeWeight(src, dst)
+			result = append(result, FailedEdge{
+				WeightedEdge: WeightedEdge{Edge: Edge{Source: srcID, Target: dstID}, Weight: weight},
+				Failures:     failures,
+				Broken:       failures >= weight,
+			})
+		}
+	}
+	sort.Sort(failedEdgesByID(result))
+	return result
+}
+
+// edgeCount sums the counter of the endpoints of src connected to the
+// endpoints of dst.
+func edgeCount(src, dst report.Node, key string) int {
+	dstEndpointIDs, _ := endpointChildIDsAndCopyMapOf(dst)
+	total := 0
+	for _, ep := range endpointChildrenOf(src) {
+		count, ok := ep.Counters.Lookup(key)
+		if ok && len(ep.Adjacency.Intersection(dstEndpointIDs)) > 0 {
+			total += count
+		}
+	}
+	return total
+}
+
+// ChurnEdge is a WeightedEdge along with the number of connections
+// opened and closed over it since the previous report. Edges of many
+// short-lived connections have a churn high compared to their weight.
+type ChurnEdge struct {
+	WeightedEdge
+	Opened int `json:"opened"`
+	Closed int `json:"closed"`
+}
+
+// ChurnEdges returns the edges between the rendered nodes over which
+// connections were opened or closed.
+func ChurnEdges(ns report.Nodes) []ChurnEdge {
+	result := []ChurnEdge{}
+	for srcID, src := range ns {
+		for _, dstID := range src.Adjacency {
+			dst, ok := ns[dstID]
+			if !ok {
+				continue
+			}
+			var (
+				opened = edgeCount(src, dst, report.ConnectionsOpened)
+				closed = edgeCount(src, dst, report.ConnectionsClosed)
+			)
+			if opened == 0 && closed == 0 {
+				continue
+			}
+			result = append(result, ChurnEdge{
+				WeightedEdge: WeightedEdge{Edge: Edge{Source: srcID, Target: dstID}, Weight: edgeWeight(src, dst)},
+				Opened:       opened,
+				Closed:       closed,
+			})
+		}
+	}
+	sort.Sort(churnEdgesByID(result))
+	return result
+}
+
+// ConnectionLatencyEdge is an edge along with the round trip times of
+// the connections over it probes measured, of the reports merged: their
+// range, and their mean.
+type ConnectionLatencyEdge struct {
+	Edge
+	MinMicros    float64 `json:"minMicros"`
+	MaxMicros    float64 `json:"maxMicros"`
+	MeanMicros   float64 `json:"meanMicros"`
+	Observations int     `json:"observations"`
+}
+
+// ConnectionLatencyEdges returns the edges between the rendered nodes
+// with round trip times of their connections measured.
+func ConnectionLatencyEdges(ns report.Nodes) []ConnectionLatencyEdge {
+	result := []ConnectionLatencyEdge{}
+	for srcID, src := range ns {
+		for _, dstID := range src.Adjacency {
+			dst, ok := ns[dstID]
+			if !ok {
+				continue
+			}
+			if edge, ok := edgeLatency(src, dst); ok {
+				edge.Edge = Edge{Source: srcID, Target: dstID}
+				result = append(result, edge)
+			}
+		}
+	}
+	sort.Sort(connectionLatencyEdgesByID(result))
+	return result
+}
+
+// edgeLatency adds up the round trip times measured of the connections of
+// the endpoints of src to the endpoints of dst.
+func edgeLatency(src, dst report.Node) (ConnectionLatencyEdge, bool) {
+	var (
+		dstEndpointIDs, _ = endpointChildIDsAndCopyMapOf(dst)
+		edge              ConnectionLatencyEdge
+		sum               int
+	)
+	for _, ep := range endpointChildrenOf(src) {
+		observations, ok := ep.Counters.Lookup(report.LatencyObservations)
+		if !ok || observations == 0 || len(ep.Adjacency.Intersection(dstEndpointIDs)) == 0 {
+			continue
+		}
+		micros, _ := ep.Counters.Lookup(report.LatencySumMicros)
+		if metric, ok := ep.Metrics.Lookup(report.LatencyMicros); ok {
+			if edge.Observations == 0 || metric.Min < edge.MinMicros {
+				edge.MinMicros = metric.Min
+			}
+			if metric.Max > edge.MaxMicros {
+				edge.MaxMicros = metric.Max
+			}
+		}
+		sum += micros
+		edge.Observations += observations
+	}
+	if edge.Observations == 0 {
+		return ConnectionLatencyEdge{}, false
+	}
+	edge.MeanMicros = float64(sum) / float64(edge.Observations)
+	return edge, true
+}
+
+// RequestEdge is an edge along with the number of requests made over
+// it, by API. The least called APIs of an edge are added up as
+// endpoint.OtherRequests.
+type RequestEdge struct {
+	Edge
+	Protocol string         `json:"protocol"`
+	Requests map[string]int `json:"requests"`
+}
+
+// RequestEdges returns the edges between the rendered nodes over which
+// HTTP/2 or gRPC requests were seen.
+func RequestEdges(ns report.Nodes) []RequestEdge {
+	result := []RequestEdge{}
+	for srcID, src := range ns {
+		for _, dstID := range src.Adjacency {
+			dst, ok := ns[dstID]
+			if !ok {
+				continue
+			}
+			if edge, ok := edgeRequests(src, dst); ok {
+				edge.Edge = Edge{Source: srcID, Target: dstID}
+				result = append(result, edge)
+			}
+		}
+	}
+	sort.Sort(requestEdgesByID(result))
+	return result
+}
+
+// edgeRequests adds up the requests the endpoints of src made to the
+// endpoints of dst. The protocol is gRPC if any of them is.
+func edgeRequests(src, dst report.Node) (RequestEdge, bool) {
+	dstEndpointIDs, _ := endpointChildIDsAndCopyMapOf(dst)
+	edge := RequestEdge{Requests: map[string]int{}}
+	for _, ep := range endpointChildrenOf(src) {
+		if len(ep.Adjacency.Intersection(dstEndpointIDs)) == 0 {
+			continue
+		}
+		ep.Counters.ForEach(func(key string, value int) {
+			if name, ok := endpoint.ParseRequestsKey(key); ok {
+				edge.Requests[name] += value
+			}
+		})
+		if protocol, ok := ep.Latest.Lookup(endpoint.L7Protocol); ok && edge.Protocol != "grpc" {
+			edge.Protocol = protocol
+		}
+	}
+	if len(edge.Requests) == 0 {
+		return edge, false
+	}
+	edge.Requests = endpoint.TopRequests(edge.Requests)
+	return edge, true
+}
+
+// DatabaseEdge is a WeightedEdge to a database, along with the protocol
+// it speaks and the number of queries and errors over the edge.
+type DatabaseEdge struct {
+	WeightedEdge
+	Protocol string `json:"protocol"`
+	Queries  int    `json:"queries"`
+	Errors   int    `json:"errors"`
+}
+
+// DatabaseEdges returns the edges between the rendered nodes over which
+// a database protocol was spoken.
+func DatabaseEdges(ns report.Nodes) []DatabaseEdge {
+	result := []DatabaseEdge{}
+	for srcID, src := range ns {
+		for _, dstID := range src.Adjacency {
+			dst, ok := ns[dstID]
+			if !ok {
+				continue
+			}
+			protocol, ok := edgeDatabaseProtocol(src, dst)
+			if !ok {
+				continue
+			}
+			result = append(result, DatabaseEdge{
+				WeightedEdge: WeightedEdge{Edge: Edge{Source: srcID, Target: dstID}, Weight: edgeWeight(src, dst)},
+				Protocol:     protocol,
+				Queries:      edgeCount(src, dst, endpoint.Queries),
+				Errors:       edgeCount(src, dst, endpoint.QueryErrors),
+			})
+		}
+	}
+	sort.Sort(databaseEdgesByID(result))
+	return result
+}
+
+// edgeDatabaseProtocol returns the protocol of the endpoints of src
+// querying the endpoints of dst.
+func edgeDatabaseProtocol(src, dst report.Node) (string, bool) {
+	dstEndpointIDs, _ := endpointChildIDsAndCopyMapOf(dst)
+	for _, ep := range endpointChildrenOf(src) {
+		if _, ok := ep.Counters.Lookup(endpoint.Queries); !ok || len(ep.Adjacency.Intersection(dstEndpointIDs)) == 0 {
+			continue
+		}
+		if protocol, ok := ep.Latest.Lookup(endpoint.L7Protocol); ok {
+			return protocol, true
+		}
+	}
+	return "", false
+}
+
+// LatencyEdge is an edge between two hosts, along with the round trip
+// time between them as last measured by the probe of the source.
+type LatencyEdge struct {
+	Edge
+	RTT float64 `json:"rtt"`
+}
+
+// LatencyEdges returns the edges between the rendered nodes whose round
+// trip time has been measured, whether or not they are connected.
+func LatencyEdges(ns report.Nodes) []LatencyEdge {
+	result := []LatencyEdge{}
+	for srcID, src := range ns {
+		for key, metric := range src.Metrics {
+			dstID, ok := mesh.ParseRTTKey(key)
+			if !ok {
+				continue
+			}
+			last, ok := metric.LastSample()
+			if _, exists := ns[dstID]; !ok || !exists {
+				continue
+			}
+			result = append(result, LatencyEdge{Edge: Edge{Source: srcID, Target: dstID}, RTT: last.Value})
+		}
+	}
+	sort.Sort(latencyEdgesByID(result))
+	return result
+}
+
+// ServiceEdge is a WeightedEdge between two services, along with the
+// requests made and the bytes sent over it by the replicas of the
+// source, whichever replicas of the target served them. Requests are
+// those of HTTP/2 and gRPC, and the queries of databases. Only reports
+// made from packet captures count the bytes sent, so they are omitted
+// otherwise, and the edge is weighed by its connections alone.
+type ServiceEdge struct {
+	WeightedEdge
+	Requests int `json:"requests"`
+	Bytes    int `json:"bytes,omitempty"`
+}
+
+// ServiceEdges returns the edges between the rendered nodes, with the
+// traffic over them.
+func ServiceEdges(ns report.Nodes) []ServiceEdge {
+	result := []ServiceEdge{}
+	for srcID, src := range ns {
+		for _, dstID := range src.Adjacency {
+			dst, ok := ns[dstID]
+			if !ok {
+				continue
+			}
+			result = append(result, ServiceEdge{
+				WeightedEdge: WeightedEdge{Edge: Edge{Source: srcID, Target: dstID}, Weight: edgeWeight(src, dst)},
+				Requests:     edgeTotalRequests(src, dst),
+				Bytes:        edgeCount(src, dst, endpoint.BytesSent),
+			})
+		}
+	}
+	sort.Sort(serviceEdgesByID(result))
+	return result
+}
+
+// edgeTotalRequests adds up the requests and queries, of any API, the
+// endpoints of src made to the endpoints of dst.
+func edgeTotalRequests(src, dst report.Node) int {
+	dstEndpointIDs, _ := endpointChildIDsAndCopyMapOf(dst)
+	total := 0
+	for _, ep := range endpointChildrenOf(src) {
+		if len(ep.Adjacency.Intersection(dstEndpointIDs)) == 0 {
+			continue
+		}
+		ep.Counters.ForEach(func(key string, value int) {
+			if _, ok := endpoint.ParseRequestsKey(key); ok || key == endpoint.Queries {
+				total += value
+			}
+		})
+	}
+	return total
+}
+
+// RequestVolumes returns the requests each node received over the
+// edges, e.g. to size services by.
+func RequestVolumes(edges []ServiceEdge) map[string]int {
+	result := map[string]int{}
+	for _, e := range edges {
+		if e.Requests > 0 {
+			result[e.Target] += e.Requests
+		}
+	}
+	return result
+}
+
+// Traffic is the total traffic over the edges between nodes. As over
+// service edges, bytes are only counted in reports of packet captures.
+type Traffic struct {
+	Connections int `json:"connections"`
+	Requests    int `json:"requests"`
+	Bytes       int `json:"bytes,omitempty"`
+}
+
+// TotalTraffic adds up the traffic over the edges between the rendered
+// nodes.
+func TotalTraffic(ns report.Nodes) Traffic {
+	var result Traffic
+	for _, e := range ServiceEdges(ns) {
+		result.Connections += e.Weight
+		result.Requests += e.Requests
+		result.Bytes += e.Bytes
+	}
+	return result
+}
+
+type latencyEdgesByID []LatencyEdge
+
+func (e latencyEdgesByID) Len() int      { return len(e) }
+func (e latencyEdgesByID) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e latencyEdgesByID) Less(i, j int) bool {
+	return edgesByID{e[i].Edge, e[j].Edge}.Less(0, 1)
+}
+
+type failedEdgesByID []FailedEdge
+
+func (e failedEdgesByID) Len() int      { return len(e) }
+func (e failedEdgesByID) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e failedEdgesByID) Less(i, j int) bool {
+	return edgesByID{e[i].Edge, e[j].Edge}.Less(0, 1)
+}
+
+type churnEdgesByID []ChurnEdge
+
+func (e churnEdgesByID) Len() int      { return len(e) }
+func (e churnEdgesByID) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e churnEdgesByID) Less(i, j int) bool {
+	return edgesByID{e[i].Edge, e[j].Edge}.Less(0, 1)
+}
+
+type connectionLatencyEdgesByID []ConnectionLatencyEdge
+
+func (e connectionLatencyEdgesByID) Len() int      { return len(e) }
+func (e connectionLatencyEdgesByID) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e connectionLatencyEdgesByID) Less(i, j int) bool {
+	return edgesByID{e[i].Edge, e[j].Edge}.Less(0, 1)
+}
+
+type requestEdgesByID []RequestEdge
+
+func (e requestEdgesByID) Len() int      { return len(e) }
+func (e requestEdgesByID) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e requestEdgesByID) Less(i, j int) bool {
+	return edgesByID{e[i].Edge, e[j].Edge}.Less(0, 1)
+}
+
+type databaseEdgesByID []DatabaseEdge
+
+func (e databaseEdgesByID) Len() int      { return len(e) }
+func (e databaseEdgesByID) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e databaseEdgesByID) Less(i, j int) bool {
+	return edgesByID{e[i].Edge, e[j].Edge}.Less(0, 1)
+}
+
+type serviceEdgesByID []ServiceEdge
+
+func (e serviceEdgesByID) Len() int      { return len(e) }
+func (e serviceEdgesByID) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e serviceEdgesByID) Less(i, j int) bool {
+	return edgesByID{e[i].Edge, e[j].Edge}.Less(0, 1)
+}
+
+// EdgeAttributes are what is known of an edge, of each kind of edges it
+// is of, so that clients look edges up once, by ID.
+type EdgeAttributes struct {
+	Edge
+	Failed            *FailedEdge            `json:"failed,omitempty"`
+	Latency           *LatencyEdge           `json:"latency,omitempty"`
+	Churn             *ChurnEdge             `json:"churn,omitempty"`
+	ConnectionLatency *ConnectionLatencyEdge `json:"connectionLatency,omitempty"`
+	Requests          *RequestEdge           `json:"requests,omitempty"`
+	Database          *DatabaseEdge          `json:"database,omitempty"`
+	Countries         []CountryEdge          `json:"countries,omitempty"`
+	Service           *ServiceEdge           `json:"service,omitempty"`
+}
+
+// EdgesAttributes are the attributes of edges, by the IDs of the edges.
+type EdgesAttributes map[string]*EdgeAttributes
+
+// Of returns the attributes of the edge, added if there are none yet.
+func (a EdgesAttributes) Of(e Edge) *EdgeAttributes {
+	attributes, ok := a[e.ID()]
+	if !ok {
+		attributes = &EdgeAttributes{Edge: e}
+		a[e.ID()] = attributes
+	}
+	return attributes
+}
@@ -0,0 +1,226 @@
+package detailed_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/common/geoip"
+	"github.com/weaveworks/scope/probe/endpoint"
+	"github.com/weaveworks/scope/probe/mesh"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/fixture"
+	"github.com/weaveworks/scope/test/mmdb"
+)
+
+func TestFailedEdges(t *testing.T) {
+	if have := detailed.FailedEdges(render.ProcessRenderer.Render(fixture.Report).Nodes); len(have) != 0 {
+		t.Errorf("expected no failed edges, got %v", have)
+	}
+
+	rpt := fixture.Report.Copy()
+	client := rpt.Endpoint.Nodes[fixture.Client54001NodeID]
+	client.Counters = client.Counters.Add(report.ConnectionFailures, 1)
+	rpt.Endpoint.Nodes[fixture.Client54001NodeID] = client
+
+	have := detailed.FailedEdges(render.ProcessRenderer.Render(rpt).Nodes)
+	want := []detailed.FailedEdge{{
+		WeightedEdge: detailed.WeightedEdge{
+			Edge:   detailed.Edge{Source: fixture.ClientProcess1NodeID, Target: fixture.ServerProcessNodeID},
+			Weight: 1,
+		},
+		Failures: 1,
+		Broken:   true,
+	}}
+	if !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+}
+
+func TestChurnEdges(t *testing.T) {
+	if have := detailed.ChurnEdges(render.ProcessRenderer.Render(fixture.Report).Nodes); len(have) != 0 {
+		t.Errorf("expected no churn edges, got %v", have)
+	}
+
+	rpt := fixture.Report.Copy()
+	client := rpt.Endpoint.Nodes[fixture.Client54001NodeID]
+	client.Counters = client.Counters.Add(report.ConnectionsOpened, 3).Add(report.ConnectionsClosed, 2)
+	rpt.Endpoint.Nodes[fixture.Client54001NodeID] = client
+
+	have := detailed.ChurnEdges(render.ProcessRenderer.Render(rpt).Nodes)
+	want := []detailed.ChurnEdge{{
+		WeightedEdge: detailed.WeightedEdge{
+			Edge:   detailed.Edge{Source: fixture.ClientProcess1NodeID, Target: fixture.ServerProcessNodeID},
+			Weight: 1,
+		},
+		Opened: 3,
+		Closed: 2,
+	}}
+	if !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+}
+
+func TestConnectionLatencyEdges(t *testing.T) {
+	if have := detailed.ConnectionLatencyEdges(render.ProcessRenderer.Render(fixture.Report).Nodes); len(have) != 0 {
+		t.Errorf("expected no connection latency edges, got %v", have)
+	}
+
+	rpt := fixture.Report.Copy()
+	client := rpt.Endpoint.Nodes[fixture.Client54001NodeID]
+	client = client.WithLatencies(fixture.Now, 2*time.Millisecond, 6*time.Millisecond, 4*time.Millisecond)
+	rpt.Endpoint.Nodes[fixture.Client54001NodeID] = client
+
+	have := detailed.ConnectionLatencyEdges(render.ProcessRenderer.Render(rpt).Nodes)
+	want := []detailed.ConnectionLatencyEdge{{
+		Edge:         detailed.Edge{Source: fixture.ClientProcess1NodeID, Target: fixture.ServerProcessNodeID},
+		MinMicros:    2000,
+		MaxMicros:    6000,
+		MeanMicros:   4000,
+		Observations: 3,
+	}}
+	if !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+}
+
+func TestRequestEdges(t *testing.T) {
+	rpt := fixture.Report.Copy()
+	client := rpt.Endpoint.Nodes[fixture.Client54001NodeID]
+	client.Counters = client.Counters.Add(endpoint.RequestsKey("pkg.Service/Get"), 3).Add(endpoint.RequestsKey("pkg.Service/Put"), 1)
+	client = client.WithLatests(map[string]string{endpoint.L7Protocol: "grpc"})
+	rpt.Endpoint.Nodes[fixture.Client54001NodeID] = client
+
+	have := detailed.RequestEdges(render.ProcessRenderer.Render(rpt).Nodes)
+	want := []detailed.RequestEdge{{
+		Edge:     detailed.Edge{Source: fixture.ClientProcess1NodeID, Target: fixture.ServerProcessNodeID},
+		Protocol: "grpc",
+		Requests: map[string]int{"pkg.Service/Get": 3, "pkg.Service/Put": 1},
+	}}
+	if !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+}
+
+func TestDatabaseEdges(t *testing.T) {
+	rpt := fixture.Report.Copy()
+	client := rpt.Endpoint.Nodes[fixture.Client54001NodeID]
+	client.Counters = client.Counters.Add(endpoint.Queries, 5).Add(endpoint.QueryErrors, 1)
+	client = client.WithLatests(map[string]string{endpoint.L7Protocol: "postgres"})
+	rpt.Endpoint.Nodes[fixture.Client54001NodeID] = client
+
+	have := detailed.DatabaseEdges(render.ProcessRenderer.Render(rpt).Nodes)
+	want := []detailed.DatabaseEdge{{
+		WeightedEdge: detailed.WeightedEdge{
+			Edge:   detailed.Edge{Source: fixture.ClientProcess1NodeID, Target: fixture.ServerProcessNodeID},
+			Weight: 1,
+		},
+		Protocol: "postgres",
+		Queries:  5,
+		Errors:   1,
+	}}
+	if !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+}
+
+func TestCountryEdges(t *testing.T) {
+	countries, err := geoip.FromBytes(mmdb.Build("GeoLite2-Country", map[string]map[string]interface{}{
+		"8.8.8.0/24": {"country": map[string]interface{}{"iso_code": "US"}},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc := detailed.RenderContext{Report: fixture.Report, GeoIP: geoip.DBs{countries}}
+	nodes := render.ProcessRenderer.Render(fixture.Report).Nodes
+
+	have := detailed.CountryEdges(rc, nodes, map[string]bool{"GB": true})
+	want := []detailed.CountryEdge{{
+		Edge:        detailed.Edge{Source: fixture.NonContainerProcessNodeID, Target: render.OutgoingInternetID},
+		Country:     "US",
+		Connections: 1,
+		Unexpected:  true,
+	}}
+	if !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+	if have := detailed.CountryEdges(rc, nodes, map[string]bool{"US": true}); len(have) != 1 || have[0].Unexpected {
+		t.Errorf("expected the connections to be expected, got %v", have)
+	}
+}
+
+func TestLatencyEdges(t *testing.T) {
+	rpt := fixture.Report.Copy()
+	client := rpt.Host.Nodes[fixture.ClientHostNodeID]
+	client = client.WithMetric(mesh.RTTKey(fixture.ServerHostNodeID), report.MakeSingletonMetric(fixture.Now, 1.5))
+	// Peers which are not rendered have no edge
+	client = client.WithMetric(mesh.RTTKey(report.MakeHostNodeID("unknown")), report.MakeSingletonMetric(fixture.Now, 3))
+	rpt.Host.Nodes[fixture.ClientHostNodeID] = client
+
+	have := detailed.LatencyEdges(render.HostRenderer.Render(rpt).Nodes)
+	want := []detailed.LatencyEdge{{
+		Edge: detailed.Edge{Source: fixture.ClientHostNodeID, Target: fixture.ServerHostNodeID},
+		RTT:  1.5,
+	}}
+	if !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+}
+
+func TestServiceEdges(t *testing.T) {
+	rpt := fixture.Report.Copy()
+	client := rpt.Endpoint.Nodes[fixture.Client54001NodeID]
+	client.Counters = client.Counters.Add(endpoint.RequestsKey("pkg.Service/Get"), 3).Add(endpoint.BytesSent, 100)
+	rpt.Endpoint.Nodes[fixture.Client54001NodeID] = client
+
+	have := detailed.ServiceEdges(render.ServiceMapRenderer.Render(rpt).Nodes)
+	want := detailed.ServiceEdge{
+		WeightedEdge: detailed.WeightedEdge{
+			Edge:   detailed.Edge{Source: fixture.ServiceNodeID, Target: fixture.ServiceNodeID},
+			Weight: 2,
+		},
+		Requests: 3,
+		Bytes:    100,
+	}
+	var found bool
+	for _, e := range have {
+		if e.Edge == want.Edge {
+			found = true
+			if !reflect.DeepEqual(want, e) {
+				t.Error(test.Diff(want, e))
+			}
+		}
+	}
+	if !found {
+		t.Errorf("expected an edge between the replicas of the service, have %v", have)
+	}
+	if volumes := detailed.RequestVolumes(have); volumes[fixture.ServiceNodeID] != 3 {
+		t.Errorf("expected 3 requests to the service, have %v", volumes)
+	}
+	if traffic := detailed.TotalTraffic(render.ServiceMapRenderer.Render(rpt).Nodes); traffic.Requests != 3 || traffic.Bytes != 100 || traffic.Connections < 2 {
+		t.Errorf("expected the traffic of the edges, have %v", traffic)
+	}
+}
+
+func TestServiceEdgesWithoutBytes(t *testing.T) {
+	// Live probes don't count the bytes sent, so the edges have none to
+	// show, only their connections
+	edges := detailed.ServiceEdges(render.ServiceMapRenderer.Render(fixture.Report).Nodes)
+	if len(edges) == 0 {
+		t.Fatal("expected service edges")
+	}
+	for _, e := range edges {
+		buf, err := json.Marshal(e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(buf), `"bytes"`) {
+			t.Errorf("unexpected bytes of %s", buf)
+		}
+	}
+}
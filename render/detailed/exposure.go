@@ -0,0 +1,116 @@
+package detailed
+
+import (
+	"sort"
+
+	"github.com/weaveworks/scope/probe/endpoint"
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+)
+
+// ExposedPort is a port which a process listens on, on a non-loopback
+// address, and which has accepted connections from outside the
+// cluster.
+type ExposedPort struct {
+	Host    string   `json:"host"`
+	Address string   `json:"address"`
+	Port    string   `json:"port"`
+	PID     string   `json:"pid"`
+	Process string   `json:"process,omitempty"`
+	Peers   []string `json:"peers"`
+}
+
+// socket identifies the sockets accepted by a listening process.
+type socket struct {
+	hostNodeID, pid, port string
+}
+
+// ExposedPorts lists the externally-exposed ports in the report.
+func ExposedPorts(r report.Report) []ExposedPort {
+	local := render.LocalNetworks(r)
+	isExternal := func(addr string) bool {
+		// Create a buffer on the stack of this function, so we don't need to allocate in ParseIP
+		var into [5]byte
+		ip := report.ParseIP([]byte(addr), into[:4])
+		return ip != nil && !ip.IsLoopback() && !local.Contains(ip)
+	}
+
+	// Index the external peers of every accepted connection by the
+	// process and port which accepted it
+	peers := map[socket]report.StringSet{}
+	for _, src := range r.Endpoint.Nodes {
+		_, srcAddr, _, ok := report.ParseEndpointNodeID(src.ID)
+		if !ok || !isExternal(srcAddr) {
+			continue
+		}
+		for _, dstID := range src.Adjacency {
+			dst, ok := r.Endpoint.Nodes[dstID]
+			if !ok {
+				continue
+			}
+			key, ok := socketOf(dst)
+			if !ok {
+				continue
+			}
+			peers[key] = peers[key].Add(srcAddr)
+		}
+	}
+
+	result := []ExposedPort{}
+	for _, n := range r.Endpoint.Nodes {
+		if _, ok := n.Latest.Lookup(endpoint.Listening); !ok {
+			continue
+		}
+		_, addr, _, ok := report.ParseEndpointNodeID(n.ID)
+		if !ok || report.IsLoopback(addr) {
+			continue
+		}
+		key, ok := socketOf(n)
+		if !ok || len(peers[key]) == 0 {
+			continue
+		}
+		hostID, _ := report.ParseHostNodeID(key.hostNodeID)
+		name, _ := r.Process.Nodes[report.MakeProcessNodeID(hostID, key.pid)].Latest.Lookup(process.Name)
+		result = append(result, ExposedPort{
+			Host:    hostID,
+			Address: addr,
+			Port:    key.port,
+			PID:     key.pid,
+			Process: name,
+			Peers:   peers[key],
+		})
+	}
+	sort.Sort(exposedPortsByID(result))
+	return result
+}
+
+func socketOf(n report.Node) (socket, bool) {
+	hostNodeID, ok := n.Latest.Lookup(report.HostNodeID)
+	if !ok {
+		return socket{}, false
+	}
+	pid, ok := n.Latest.Lookup(process.PID)
+	if !ok {
+		return socket{}, false
+	}
+	_, _, port, ok := report.ParseEndpointNodeID(n.ID)
+	if !ok {
+		return socket{}, false
+	}
+	return socket{hostNodeID, pid, port}, true
+}
+
+type exposedPortsByID []ExposedPort
+
+func (e exposedPortsByID) Len() int      { return len(e) }
+func (e exposedPortsByID) Swap(i, j int) { e[i], e[j] = e[j], e[i] }
+func (e exposedPortsByID) Less(i, j int) bool {
+	if e[i].Host != e[j].Host {
+		return e[i].Host < e[j].Host
+	}
+	if e[i].Port != e[j].Port {
+		return e[i].Port < e[j].Port
+	}
+	return e[i].Address < e[j].Address
+}
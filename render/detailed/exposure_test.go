@@ -0,0 +1,41 @@
+package detailed_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/probe/endpoint"
+	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+func TestExposedPorts(t *testing.T) {
+	listener := func(addr string) report.Node {
+		return report.MakeNodeWith(report.MakeScopedEndpointNodeID(fixture.ServerHostID, addr, fixture.ServerPort), map[string]string{
+			endpoint.Listening: "true",
+			process.PID:        fixture.ServerPID,
+			report.HostNodeID:  fixture.ServerHostNodeID,
+		}).WithTopology(report.Endpoint)
+	}
+	rpt := fixture.Report.Copy()
+	for _, addr := range []string{"0.0.0.0", "127.0.0.1"} {
+		n := listener(addr)
+		rpt.Endpoint.Nodes[n.ID] = n
+	}
+
+	have := detailed.ExposedPorts(rpt)
+	want := []detailed.ExposedPort{{
+		Host:    fixture.ServerHostID,
+		Address: "0.0.0.0",
+		Port:    fixture.ServerPort,
+		PID:     fixture.ServerPID,
+		Process: fixture.ServerName,
+		Peers:   []string{fixture.RandomClientIP},
+	}}
+	if !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+}
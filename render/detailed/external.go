@@ -0,0 +1,225 @@
+package detailed
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/weaveworks/scope/probe/endpoint"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+)
+
+// Table of the external destinations of internet nodes
+const (
+	ExternalDestinationsTableID = "external-destinations"
+	maxExternalDestinations     = 10
+
+	destinationKey = "destination"
+	asnKey         = "asn"
+	countryKey     = "country"
+	bytesKey       = "bytes"
+)
+
+var (
+	externalDestinationsColumns = []report.Column{
+		{ID: destinationKey, Label: "Destination"},
+		{ID: asnKey, Label: "AS"},
+		{ID: countryKey, Label: "Country"},
+		{ID: countKey, Label: "Connections", DataType: report.Number},
+	}
+	externalDestinationsBytesColumn = report.Column{ID: bytesKey, Label: "Bytes", DataType: report.Number}
+)
+
+type externalDestination struct {
+	name, asn, country string
+	connections, bytes int
+}
+
+// externalDestinationsTable breaks an internet node down by the remote
+// hosts it stands for, named after the server names TLS clients asked
+// for, their DNS names, or their addresses. The hosts with the most
+// connections are listed; the others are added up in a last row. Only
+// reports made from packet captures count the bytes sent, so only then
+// are the bytes shown, and the hosts exchanging the most listed.
+func externalDestinationsTable(rc RenderContext, n report.Node) (report.Table, bool) {
+	if !render.IsInternetNode(n) {
+		return report.Table{}, false
+	}
+	remotes := endpointChildrenOf(n)
+	if len(remotes) == 0 {
+		return report.Table{}, false
+	}
+	remoteIDs := make(map[string]struct{}, len(remotes))
+	for _, ep := range remotes {
+		remoteIDs[ep.ID] = struct{}{}
+	}
+
+	// The local endpoints connected to the remote ones, for the bytes
+	// they sent and the server names they asked for
+	var (
+		localBytes       = map[string]int{}
+		localConnections = map[string]int{}
+		serverNames      = map[string]string{}
+	)
+	for _, local := range rc.Report.Endpoint.Nodes {
+		for _, remoteID := range local.Adjacency {
+			if _, ok := remoteIDs[remoteID]; !ok {
+				continue
+			}
+			sent, _ := local.Counters.Lookup(endpoint.BytesSent)
+			localBytes[remoteID] += sent
+			localConnections[remoteID]++
+			if name, ok := local.Latest.Lookup(endpoint.TLSServerName); ok {
+				serverNames[remoteID] = name
+			}
+		}
+	}
+
+	var (
+		byName   = map[string]*externalDestination{}
+		anyBytes bool
+	)
+	for _, ep := range remotes {
+		_, addr, _, ok := report.ParseEndpointNodeID(ep.ID)
+		if !ok {
+			continue
+		}
+		name, ok := serverNames[ep.ID]
+		if !ok {
+			if name, ok = rc.Report.DNS.FirstMatch(ep.ID, func(string) bool { return true }); !ok {
+				name = addr
+			}
+		}
+		dest, ok := byName[name]
+		if !ok {
+			dest = &externalDestination{name: name, asn: rc.asn(addr), country: rc.country(addr)}
+			byName[name] = dest
+		}
+		sent, _ := ep.Counters.Lookup(endpoint.BytesSent)
+		dest.bytes += sent + localBytes[ep.ID]
+		anyBytes = anyBytes || dest.bytes > 0
+		// Incoming connections are adjacencies of the remote endpoints
+		dest.connections += len(ep.Adjacency) + localConnections[ep.ID]
+	}
+
+	dests := make([]*externalDestination, 0, len(byName))
+	for _, dest := range byName {
+		dests = append(dests, dest)
+	}
+	sort.Slice(dests, func(i, j int) bool {
+		if anyBytes && dests[i].bytes != dests[j].bytes {
+			return dests[i].bytes > dests[j].bytes
+		}
+		if dests[i].connections != dests[j].connections {
+			return dests[i].connections > dests[j].connections
+		}
+		return dests[i].name < dests[j].name
+	})
+	if len(dests) > maxExternalDestinations {
+		other := &externalDestination{name: fmt.Sprintf("%d others", len(dests)-maxExternalDestinations)}
+		for _, dest := range dests[maxExternalDestinations:] {
+			other.connections += dest.connections
+			other.bytes += dest.bytes
+		}
+		dests = append(dests[:maxExternalDestinations], other)
+	}
+
+	columns := externalDestinationsColumns
+	if anyBytes {
+		columns = append(columns[:len(columns):len(columns)], externalDestinationsBytesColumn)
+	}
+	rows := make([]report.Row, 0, len(dests))
+	for _, dest := range dests {
+		entries := map[string]string{
+			destinationKey: dest.name,
+			asnKey:         dest.asn,
+			countryKey:     dest.country,
+			countKey:       strconv.Itoa(dest.connections),
+		}
+		if anyBytes {
+			entries[bytesKey] = strconv.Itoa(dest.bytes)
+		}
+		rows = append(rows, report.Row{ID: dest.name, Entries: entries})
+	}
+	return report.Table{
+		ID:      ExternalDestinationsTableID,
+		Label:   "External destinations",
+		Type:    report.MulticolumnTableType,
+		Columns: columns,
+		Rows:    rows,
+	}, true
+}
+
+// asn names the autonomous system of an address, as AS<number> <name>,
+// if the context has a table or a database of them.
+func (rc RenderContext) asn(addr string) string {
+	ip := net.ParseIP(addr)
+	// Records of https://iptoasn.com tables are number, country, name
+	if record, ok := rc.ASNs.Lookup(ip); ok && len(record) >= 3 && record[0] != "0" {
+		return fmt.Sprintf("AS%s %s", record[0], record[2])
+	}
+	if number, org, ok := rc.GeoIP.ASN(ip); ok {
+		return fmt.Sprintf("AS%d %s", number, org)
+	}
+	return ""
+}
+
+// country returns the ISO code of the country of an address, if the
+// context has a database of them.
+func (rc RenderContext) country(addr string) string {
+	country, _ := rc.GeoIP.Country(net.ParseIP(addr))
+	return country
+}
+
+// CountryEdge is an edge to the outbound internet node, along with a
+// country its connections go to.
+type CountryEdge struct {
+	Edge
+	Country     string `json:"country"`
+	Connections int    `json:"connections"`
+	Unexpected  bool   `json:"unexpected,omitempty"`
+}
+
+// CountryEdges breaks the edges to the outbound internet node down by
+// the countries of their remote addresses, flagging the countries not
+// expected, if any are.
+func CountryEdges(rc RenderContext, ns report.Nodes, expected map[string]bool) []CountryEdge {
+	result := []CountryEdge{}
+	internet, ok := ns[render.OutgoingInternetID]
+	if !ok || len(rc.GeoIP) == 0 {
+		return result
+	}
+	internetEndpointIDs, _ := endpointChildIDsAndCopyMapOf(internet)
+	for srcID, src := range ns {
+		if !src.Adjacency.Contains(render.OutgoingInternetID) {
+			continue
+		}
+		connections := map[string]int{}
+		for _, ep := range endpointChildrenOf(src) {
+			for _, remoteID := range ep.Adjacency.Intersection(internetEndpointIDs) {
+				if _, addr, _, ok := report.ParseEndpointNodeID(remoteID); ok {
+					if country := rc.country(addr); country != "" {
+						connections[country]++
+					}
+				}
+			}
+		}
+		for country, count := range connections {
+			result = append(result, CountryEdge{
+				Edge:        Edge{Source: srcID, Target: render.OutgoingInternetID},
+				Country:     country,
+				Connections: count,
+				Unexpected:  len(expected) > 0 && !expected[country],
+			})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Edge != result[j].Edge {
+			return edgesByID{result[i].Edge, result[j].Edge}.Less(0, 1)
+		}
+		return result[i].Country < result[j].Country
+	})
+	return result
+}
@@ -0,0 +1,145 @@
+package detailed
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Fields are the fields of node summaries a client renders, for sparse
+// responses. Metadata, metrics and tables are all kept if named alone, or
+// only those of the IDs given; the ID of nodes is always kept.
+type Fields struct {
+	fields map[string]bool
+	ids    map[string]map[string]bool // metadata, metrics and tables kept, by ID
+}
+
+var summaryFields = map[string]bool{
+	"label":      true,
+	"labelMinor": true,
+	"rank":       true,
+	"shape":      true,
+	"stack":      true,
+	"pseudo":     true,
+	"stableId":   true,
+	"metadata":   true,
+	"parents":    true,
+	"metrics":    true,
+	"tables":     true,
+	"adjacency":  true,
+}
+
+// ParseFields parses a comma-separated list of fields, named as in the
+// JSON of node summaries, e.g. "label,adjacency,metrics:host_cpu_usage_percent".
+// An empty list is all the fields.
+func ParseFields(s string) (Fields, error) {
+	if s == "" {
+		return Fields{}, nil
+	}
+	f := Fields{fields: map[string]bool{}, ids: map[string]map[string]bool{}}
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "id" || field == "" {
+			continue
+		}
+		name, id := field, ""
+		if i := strings.Index(field, ":"); i >= 0 {
+			name, id = field[:i], field[i+1:]
+			if (name != "metadata" && name != "metrics" && name != "tables") || id == "" {
+				return Fields{}, fmt.Errorf("invalid field %q", field)
+			}
+		}
+		if !summaryFields[name] {
+			return Fields{}, fmt.Errorf("unknown field %q", field)
+		}
+		if id == "" {
+			f.fields[name] = true
+			delete(f.ids, name)
+		} else if !f.fields[name] {
+			if f.ids[name] == nil {
+				f.ids[name] = map[string]bool{}
+			}
+			f.ids[name][id] = true
+		}
+	}
+	return f, nil
+}
+
+// All is whether all the fields are kept.
+func (f Fields) All() bool {
+	return f.fields == nil
+}
+
+func (f Fields) keeps(field string) bool {
+	return f.fields[field] || f.ids[field] != nil
+}
+
+// Apply returns the summaries with only the fields kept.
+func (f Fields) Apply(summaries NodeSummaries) NodeSummaries {
+	if f.All() {
+		return summaries
+	}
+	result := make(NodeSummaries, len(summaries))
+	for id, s := range summaries {
+		result[id] = f.apply(s)
+	}
+	return result
+}
+
+func (f Fields) apply(s NodeSummary) NodeSummary {
+	result := NodeSummary{BasicNodeSummary: BasicNodeSummary{ID: s.ID}}
+	if f.keeps("label") {
+		result.Label = s.Label
+	}
+	if f.keeps("labelMinor") {
+		result.LabelMinor = s.LabelMinor
+	}
+	if f.keeps("rank") {
+		result.Rank = s.Rank
+	}
+	if f.keeps("shape") {
+		result.Shape = s.Shape
+	}
+	if f.keeps("stack") {
+		result.Stack = s.Stack
+	}
+	if f.keeps("pseudo") {
+		result.Pseudo = s.Pseudo
+	}
+	if f.keeps("stableId") {
+		result.StableID = s.StableID
+	}
+	if f.keeps("parents") {
+		result.Parents = s.Parents
+	}
+	if f.keeps("adjacency") {
+		result.Adjacency = s.Adjacency
+	}
+	if f.fields["metadata"] {
+		result.Metadata = s.Metadata
+	} else if ids := f.ids["metadata"]; ids != nil {
+		for _, row := range s.Metadata {
+			if ids[row.ID] {
+				result.Metadata = append(result.Metadata, row)
+			}
+		}
+	}
+	if f.fields["metrics"] {
+		result.Metrics = s.Metrics
+	} else if ids := f.ids["metrics"]; ids != nil {
+		for _, row := range s.Metrics {
+			if ids[row.ID] {
+				result.Metrics = append(result.Metrics, row)
+			}
+		}
+	}
+	if f.fields["tables"] {
+		result.Tables = s.Tables
+	} else if ids := f.ids["tables"]; ids != nil {
+		for _, table := range s.Tables {
+			if ids[table.ID] {
+				result.Tables = append(result.Tables, table)
+			}
+		}
+	}
+	return result
+}
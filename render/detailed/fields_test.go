@@ -0,0 +1,57 @@
+package detailed_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestFields(t *testing.T) {
+	summaries := detailed.NodeSummaries{
+		"a": {
+			BasicNodeSummary: detailed.BasicNodeSummary{ID: "a", Label: "A", LabelMinor: "a host", Rank: "a"},
+			Metadata:         []report.MetadataRow{{ID: "pid", Value: "1"}},
+			Metrics:          []report.MetricRow{{ID: "cpu", Value: 1}, {ID: "memory", Value: 2}},
+			Adjacency:        report.MakeIDList("b"),
+		},
+	}
+	for _, c := range []struct {
+		fields string
+		want   detailed.NodeSummary
+	}{
+		{"", summaries["a"]},
+		{"id", detailed.NodeSummary{BasicNodeSummary: detailed.BasicNodeSummary{ID: "a"}}},
+		{
+			"label, adjacency,metrics:cpu",
+			detailed.NodeSummary{
+				BasicNodeSummary: detailed.BasicNodeSummary{ID: "a", Label: "A"},
+				Metrics:          []report.MetricRow{{ID: "cpu", Value: 1}},
+				Adjacency:        report.MakeIDList("b"),
+			},
+		},
+		{
+			"metrics:cpu,metrics,metadata:nonesuch",
+			detailed.NodeSummary{
+				BasicNodeSummary: detailed.BasicNodeSummary{ID: "a"},
+				Metrics:          summaries["a"].Metrics,
+			},
+		},
+	} {
+		fields, err := detailed.ParseFields(c.fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if have := fields.Apply(summaries)["a"]; !reflect.DeepEqual(c.want, have) {
+			t.Errorf("%q: %s", c.fields, test.Diff(c.want, have))
+		}
+	}
+
+	for _, invalid := range []string{"nonesuch", "label:a", "metrics:"} {
+		if _, err := detailed.ParseFields(invalid); err == nil {
+			t.Errorf("expected %q to be invalid", invalid)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package detailed
+
+import (
+	"math"
+	"sort"
+
+	"github.com/weaveworks/scope/report"
+)
+
+const (
+	// Spacing between neighbouring nodes, in layout units
+	layoutSpacing = 30.0
+	// Clusters up to this size are laid out force-directed, bigger ones
+	// as sunflowers, as force-directed layouts are quadratic in nodes
+	maxForceDirectedCluster = 200
+	forceDirectedIterations = 50
+	maxLouvainPasses        = 10
+	maxLouvainLevels        = 10
+)
+
+var goldenAngle = math.Pi * (3 - math.Sqrt(5))
+
+// Position is where a node is laid out, and the cluster it belongs to.
+// Nodes of a cluster are laid out close together, clusters apart.
+type Position struct {
+	X       float64 `json:"x"`
+	Y       float64 `json:"y"`
+	Cluster int     `json:"cluster"`
+}
+
+// Layout lays the rendered nodes out in clusters of densely connected
+// nodes, found with the local moving phase of Louvain, so that clients
+// needn't lay out giant views themselves. Layouts are deterministic:
+// the same nodes and edges always get the same positions. Clusters are
+// numbered by size, the biggest first.
+func Layout(ns report.Nodes) map[string]Position {
+	ids := make([]string, 0, len(ns))
+	for id := range ns {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	index := make(map[string]int, len(ids))
+	for i, id := range ids {
+		index[id] = i
+	}
+
+	// Undirected, weighted by the count of edges either way
+	neighbours := make([]map[int]float64, len(ids))
+	for i := range neighbours {
+		neighbours[i] = map[int]float64{}
+	}
+	for i, id := range ids {
+		for _, dstID := range ns[id].Adjacency {
+			if j, ok := index[dstID]; ok && j != i {
+				neighbours[i][j]++
+				neighbours[j][i]++
+			}
+		}
+	}
+
+	clusters := louvain(neighbours)
+	positions := make(map[string]Position, len(ids))
+	offset := 0.0
+	for c, members := range clusters {
+		local := layoutCluster(members, neighbours)
+		// Clusters are laid out along a spiral, each far enough from the
+		// origin to clear the ones before it
+		radius := layoutSpacing
+		for _, p := range local {
+			radius = math.Max(radius, math.Hypot(p[0], p[1])+layoutSpacing)
+		}
+		angle := float64(c) * goldenAngle
+		if c > 0 {
+			offset += radius
+		}
+		cx, cy := offset*math.Cos(angle), offset*math.Sin(angle)
+		offset += radius
+		for i, member := range members {
+			positions[ids[member]] = Position{X: round(cx + local[i][0]), Y: round(cy + local[i][1]), Cluster: c}
+		}
+	}
+	return positions
+}
+
+// louvain returns the clusters of the graph, biggest first, each
+// ordered, and the unconnected nodes as one more. It moves nodes to the
+// cluster of their neighbours improving modularity most, until none
+// improves it, and then does the same with the clusters, until they
+// don't merge.
+func louvain(neighbours []map[int]float64) [][]int {
+	var (
+		n         = len(neighbours)
+		cluster   = make([]int, n)
+		degree    = make([]float64, n)
+		twiceEdge float64
+	)
+	for i := range neighbours {
+		cluster[i] = i
+		for _, w := range neighbours[i] {
+			degree[i] += w
+		}
+		twiceEdge += degree[i]
+	}
+	if twiceEdge > 0 {
+		graph, internal := neighbours, make([]float64, n)
+		for level := 0; level < maxLouvainLevels; level++ {
+			moved, count := moveNodes(graph, internal, twiceEdge)
+			if count == len(graph) {
+				break
+			}
+			for i := range cluster {
+				cluster[i] = moved[cluster[i]]
+			}
+			// Clusters become the nodes of the next level, their internal
+			// edges loops
+			next, nextInternal := make([]map[int]float64, count), make([]float64, count)
+			for c := range next {
+				next[c] = map[int]float64{}
+			}
+			for u, edges := range graph {
+				nextInternal[moved[u]] += internal[u]
+				for v, w := range edges {
+					if moved[u] == moved[v] {
+						nextInternal[moved[u]] += w
+					} else {
+						next[moved[u]][moved[v]] += w
+					}
+				}
+			}
+			graph, internal = next, nextInternal
+		}
+	}
+
+	byCluster := map[int][]int{}
+	for i, c := range cluster {
+		byCluster[c] = append(byCluster[c], i)
+	}
+	// Unconnected nodes are all laid out together, rather than each a
+	// cluster of its own
+	result := make([][]int, 0, len(byCluster))
+	unconnected := []int{}
+	for _, members := range byCluster {
+		if len(members) == 1 && degree[members[0]] == 0 {
+			unconnected = append(unconnected, members[0])
+		} else {
+			result = append(result, members)
+		}
+	}
+	if len(unconnected) > 0 {
+		sort.Ints(unconnected)
+		result = append(result, unconnected)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if len(result[i]) != len(result[j]) {
+			return len(result[i]) > len(result[j])
+		}
+		return result[i][0] < result[j][0]
+	})
+	return result
+}
+
+// moveNodes is the local moving phase of Louvain, returning the cluster
+// of each node of the graph, numbered from 0, and the count of clusters.
+func moveNodes(graph []map[int]float64, internal []float64, twiceEdge float64) ([]int, int) {
+	var (
+		n       = len(graph)
+		cluster = make([]int, n)
+		degree  = make([]float64, n)
+		totals  = make([]float64, n) // total degree of the nodes of each cluster
+	)
+	for i := range graph {
+		cluster[i] = i
+		degree[i] = internal[i]
+		for _, w := range graph[i] {
+			degree[i] += w
+		}
+		totals[i] = degree[i]
+	}
+	for pass := 0; pass < maxLouvainPasses; pass++ {
+		moved := false
+		for i := 0; i < n; i++ {
+			// Weights of the edges to each neighbouring cluster
+			links := map[int]float64{}
+			for j, w := range graph[i] {
+				links[cluster[j]] += w
+			}
+			current := cluster[i]
+			totals[current] -= degree[i]
+			best, bestGain := current, links[current]-totals[current]*degree[i]/twiceEdge
+			candidates := make([]int, 0, len(links))
+			for c := range links {
+				candidates = append(candidates, c)
+			}
+			sort.Ints(candidates)
+			for _, c := range candidates {
+				if gain := links[c] - totals[c]*degree[i]/twiceEdge; gain > bestGain {
+					best, bestGain = c, gain
+				}
+			}
+			totals[best] += degree[i]
+			if best != current {
+				cluster[i] = best
+				moved = true
+			}
+		}
+		if !moved {
+			break
+		}
+	}
+
+	numbers := map[int]int{}
+	for i, c := range cluster {
+		if _, ok := numbers[c]; !ok {
+			numbers[c] = len(numbers)
+		}
+		cluster[i] = numbers[c]
+	}
+	return cluster, len(numbers)
+}
+
+// layoutCluster lays the members of a cluster out around the origin,
+// starting from a sunflower and, for small clusters, relaxing it with
+// Fruchterman-Reingold.
+func layoutCluster(members []int, neighbours []map[int]float64) [][2]float64 {
+	positions := make([][2]float64, len(members))
+	for i := range members {
+		r := layoutSpacing * math.Sqrt(float64(i))
+		positions[i] = [2]float64{r * math.Cos(float64(i)*goldenAngle), r * math.Sin(float64(i)*goldenAngle)}
+	}
+	if len(members) < 2 || len(members) > maxForceDirectedCluster {
+		return positions
+	}
+
+	local := make(map[int]int, len(members))
+	for i, member := range members {
+		local[member] = i
+	}
+	k := layoutSpacing
+	temperature := layoutSpacing * math.Sqrt(float64(len(members)))
+	for iteration := 0; iteration < forceDirectedIterations; iteration++ {
+		displacements := make([][2]float64, len(members))
+		for i := range members {
+			for j := i + 1; j < len(members); j++ {
+				dx, dy := positions[i][0]-positions[j][0], positions[i][1]-positions[j][1]
+				distance := math.Max(math.Hypot(dx, dy), 0.01)
+				force := k * k / distance
+				displacements[i][0] += dx / distance * force
+				displacements[i][1] += dy / distance * force
+				displacements[j][0] -= dx / distance * force
+				displacements[j][1] -= dy / distance * force
+			}
+		}
+		for i, member := range members {
+			for neighbour, w := range neighbours[member] {
+				j, ok := local[neighbour]
+				if !ok || j <= i {
+					continue
+				}
+				dx, dy := positions[i][0]-positions[j][0], positions[i][1]-positions[j][1]
+				distance := math.Max(math.Hypot(dx, dy), 0.01)
+				force := w * distance * distance / k
+				displacements[i][0] -= dx / distance * force
+				displacements[i][1] -= dy / distance * force
+				displacements[j][0] += dx / distance * force
+				displacements[j][1] += dy / distance * force
+			}
+		}
+		for i := range members {
+			length := math.Max(math.Hypot(displacements[i][0], displacements[i][1]), 0.01)
+			step := math.Min(length, temperature)
+			positions[i][0] += displacements[i][0] / length * step
+			positions[i][1] += displacements[i][1] / length * step
+		}
+		temperature *= 0.9
+	}
+
+	// Centre the cluster on the origin
+	var cx, cy float64
+	for _, p := range positions {
+		cx += p[0]
+		cy += p[1]
+	}
+	cx, cy = cx/float64(len(positions)), cy/float64(len(positions))
+	for i := range positions {
+		positions[i][0] -= cx
+		positions[i][1] -= cy
+	}
+	return positions
+}
+
+func round(f float64) float64 {
+	return math.Round(f*10) / 10
+}
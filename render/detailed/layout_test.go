@@ -0,0 +1,48 @@
+package detailed_test
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestLayout(t *testing.T) {
+	// Two triangles joined by a single edge, and a node on its own
+	ns := report.Nodes{
+		"a1": report.MakeNode("a1").WithAdjacent("a2").WithAdjacent("a3"),
+		"a2": report.MakeNode("a2").WithAdjacent("a3"),
+		"a3": report.MakeNode("a3").WithAdjacent("b1"),
+		"b1": report.MakeNode("b1").WithAdjacent("b2").WithAdjacent("b3"),
+		"b2": report.MakeNode("b2").WithAdjacent("b3"),
+		"b3": report.MakeNode("b3"),
+		"c":  report.MakeNode("c"),
+	}
+	layout := detailed.Layout(ns)
+	if len(layout) != len(ns) {
+		t.Fatalf("expected a position per node, got %v", layout)
+	}
+	for _, cluster := range [][]string{{"a1", "a2", "a3"}, {"b1", "b2", "b3"}} {
+		for _, id := range cluster[1:] {
+			if layout[id].Cluster != layout[cluster[0]].Cluster {
+				t.Errorf("expected %v in a cluster, got %v", cluster, layout)
+			}
+		}
+	}
+	if layout["a1"].Cluster == layout["b1"].Cluster || layout["c"].Cluster != 2 {
+		t.Errorf("expected three clusters, unconnected nodes last, got %v", layout)
+	}
+
+	distance := func(a, b string) float64 {
+		return math.Hypot(layout[a].X-layout[b].X, layout[a].Y-layout[b].Y)
+	}
+	if distance("a1", "a2") >= distance("a1", "b2") {
+		t.Errorf("expected nodes of a cluster closer than nodes of others, got %v", layout)
+	}
+
+	if again := detailed.Layout(ns); !reflect.DeepEqual(layout, again) {
+		t.Errorf("expected layouts to be deterministic, got %v and %v", layout, again)
+	}
+}
@@ -6,8 +6,11 @@ import (
 
 	"github.com/ugorji/go/codec"
 
+	"github.com/weaveworks/scope/common/geoip"
+	"github.com/weaveworks/scope/common/iprange"
 	"github.com/weaveworks/scope/probe/awsecs"
 	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/host"
 	"github.com/weaveworks/scope/probe/kubernetes"
 	"github.com/weaveworks/scope/probe/process"
 	"github.com/weaveworks/scope/report"
@@ -82,12 +85,19 @@ func (c *ControlInstance) CodecDecodeSelf(decoder *codec.Decoder) {
 type RenderContext struct {
 	report.Report
 	MetricsGraphURL string
+	// ASNs, if any, names the autonomous systems of external addresses
+	ASNs *iprange.Table
+	// GeoIP, if any, locates external addresses
+	GeoIP geoip.DBs
 }
 
 // MakeNode transforms a renderable node to a detailed node. It uses
 // aggregate metadata, plus the set of origin node IDs, to produce tables.
 func MakeNode(topologyID string, rc RenderContext, ns report.Nodes, n report.Node) Node {
 	summary, _ := MakeNodeSummary(rc, n)
+	if table, ok := externalDestinationsTable(rc, n); ok {
+		summary.Tables = append(summary.Tables, table)
+	}
 	return Node{
 		NodeSummary: summary,
 		Controls:    controls(rc.Report, n),
@@ -178,6 +188,17 @@ var nodeSummaryGroupSpecs = []struct {
 			},
 		},
 	},
+	{
+		topologyID: report.NetworkInterface,
+		NodeSummaryGroup: NodeSummaryGroup{
+			Label: "Network interfaces",
+			Columns: []Column{
+				{ID: host.InterfaceType, Label: "Type"},
+				{ID: host.InterfaceRxBytes, Label: "Received/s", Datatype: report.Number},
+				{ID: host.InterfaceTxBytes, Label: "Sent/s", Datatype: report.Number},
+			},
+		},
+	},
 	{
 		topologyID: report.ContainerImage,
 		NodeSummaryGroup: NodeSummaryGroup{
@@ -235,6 +256,9 @@ func children(rc RenderContext, n report.Node) []NodeSummaryGroup {
 		group := spec.NodeSummaryGroup
 		group.Nodes = summaries[spec.topologyID]
 		group.TopologyID = apiTopology
+		if spec.topologyID == report.Process && n.Topology == report.Container {
+			group = topProcesses(group)
+		}
 		nodeSummaryGroups = append(nodeSummaryGroups, group)
 		delete(summaries, spec.topologyID)
 	}
@@ -2,10 +2,14 @@ package detailed_test
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/common/geoip"
+	"github.com/weaveworks/scope/common/iprange"
 	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/endpoint"
 	"github.com/weaveworks/scope/probe/host"
 	"github.com/weaveworks/scope/probe/kubernetes"
 	"github.com/weaveworks/scope/probe/process"
@@ -14,6 +18,7 @@ import (
 	"github.com/weaveworks/scope/render/expected"
 	"github.com/weaveworks/scope/report"
 	"github.com/weaveworks/scope/test/fixture"
+	"github.com/weaveworks/scope/test/mmdb"
 	"github.com/weaveworks/scope/test/reflect"
 )
 
@@ -50,6 +55,13 @@ func TestMakeDetailedHostNode(t *testing.T) {
 				Shape:      "circle",
 			},
 			Adjacency: report.MakeIDList(fixture.ServerHostNodeID),
+			Links: []detailed.Link{
+				{Relation: detailed.ChildRelation, ID: fixture.ClientContainerNodeID, Label: fixture.ClientContainerName, TopologyID: "containers"},
+				{Relation: detailed.ChildRelation, ID: expected.ClientContainerImageNodeID, Label: fixture.ClientContainerImageName, TopologyID: "containers-by-image"},
+				{Relation: detailed.ChildRelation, ID: fixture.ClientPodNodeID, Label: "pong-a", TopologyID: "pods"},
+				{Relation: detailed.ChildRelation, ID: fixture.ClientProcess1NodeID, Label: fixture.Client1Name, TopologyID: "processes"},
+				{Relation: detailed.ChildRelation, ID: fixture.ClientProcess2NodeID, Label: fixture.Client2Name, TopologyID: "processes"},
+			},
 			Metadata: []report.MetadataRow{
 				{
 					ID:       "host_name",
@@ -87,6 +99,22 @@ func TestMakeDetailedHostNode(t *testing.T) {
 					Priority: 2,
 					Metric:   &fixture.ClientHostMemoryMetric,
 				},
+				{
+					ID:       report.ProcessesCPUUsage,
+					Label:    "Processes CPU",
+					Format:   "percent",
+					Value:    0.01,
+					Priority: 3,
+					Metric:   &fixture.ClientProcess1CPUMetric,
+				},
+				{
+					ID:       report.ProcessesMemoryUsage,
+					Label:    "Processes memory",
+					Format:   "filesize",
+					Value:    0.02,
+					Priority: 4,
+					Metric:   &fixture.ClientProcess1MemoryMetric,
+				},
 				{
 					ID:       host.Load1,
 					Label:    "Load (1m)",
@@ -197,7 +225,7 @@ func TestMakeDetailedContainerNode(t *testing.T) {
 			Metadata: []report.MetadataRow{
 				{ID: "docker_image_name", Label: "Image name", Value: fixture.ServerContainerImageName, Priority: 2},
 				{ID: "docker_container_state_human", Label: "State", Value: "running", Priority: 4},
-				{ID: "docker_container_id", Label: "ID", Value: fixture.ServerContainerID, Priority: 11, Truncate: 12},
+				{ID: "docker_container_id", Label: "ID", Value: fixture.ServerContainerID, Priority: 13, Truncate: 12},
 			},
 			Metrics: []report.MetricRow{
 				{
@@ -234,11 +262,18 @@ func TestMakeDetailedContainerNode(t *testing.T) {
 					TopologyID: "hosts",
 				},
 			},
+			Links: []detailed.Link{
+				{Relation: detailed.ParentRelation, ID: expected.ServerContainerImageNodeID, Label: fixture.ServerContainerImageName, TopologyID: "containers-by-image"},
+				{Relation: detailed.ParentRelation, ID: fixture.ServerPodNodeID, Label: "pong-b", TopologyID: "pods"},
+				{Relation: detailed.ParentRelation, ID: fixture.ServerHostNodeID, Label: "server", TopologyID: "hosts"},
+				{Relation: detailed.ChildRelation, ID: fixture.ServerProcessNodeID, Label: fixture.ServerName, TopologyID: "processes"},
+			},
 		},
 		Controls: []detailed.ControlInstance{},
 		Children: []detailed.NodeSummaryGroup{
 			{
-				Label:      "Processes",
+				ID:         detailed.TopProcessesID,
+				Label:      "Top processes",
 				TopologyID: "processes",
 				Columns: []detailed.Column{
 					{ID: process.PID, Label: "PID", Datatype: report.Number},
@@ -302,6 +337,27 @@ func TestMakeDetailedContainerNode(t *testing.T) {
 	}
 }
 
+func TestMakeDetailedContainerNodeTopProcesses(t *testing.T) {
+	rpt := fixture.Report.Copy()
+	busy := rpt.Process.Nodes[fixture.ClientProcess2NodeID]
+	rpt.Process.Nodes[fixture.ClientProcess2NodeID] = busy.WithMetrics(report.Metrics{
+		process.CPUUsage: report.MakeSingletonMetric(fixture.Now, 0.5),
+	})
+
+	renderableNodes := render.ContainerWithImageNameRenderer.Render(rpt).Nodes
+	have := detailed.MakeNode("containers", detailed.RenderContext{Report: rpt}, renderableNodes, renderableNodes[fixture.ClientContainerNodeID])
+	if len(have.Children) != 1 || have.Children[0].ID != detailed.TopProcessesID {
+		t.Fatalf("expected a group of the top processes, have %v", have.Children)
+	}
+	var ids []string
+	for _, n := range have.Children[0].Nodes {
+		ids = append(ids, n.ID)
+	}
+	if want := []string{fixture.ClientProcess2NodeID, fixture.ClientProcess1NodeID}; !reflect.DeepEqual(want, ids) {
+		t.Errorf("want processes %v, have %v", want, ids)
+	}
+}
+
 func TestMakeDetailedPodNode(t *testing.T) {
 	id := fixture.ServerPodNodeID
 	renderableNodes := render.PodRenderer.Render(fixture.Report).Nodes
@@ -340,6 +396,12 @@ func TestMakeDetailedPodNode(t *testing.T) {
 					TopologyID: "hosts",
 				},
 			},
+			Links: []detailed.Link{
+				{Relation: detailed.ParentRelation, ID: fixture.ServiceNodeID, Label: fixture.ServiceName, TopologyID: "services"},
+				{Relation: detailed.ParentRelation, ID: fixture.ServerHostNodeID, Label: "server", TopologyID: "hosts"},
+				{Relation: detailed.ChildRelation, ID: fixture.ServerContainerNodeID, Label: "server", TopologyID: "containers"},
+				{Relation: detailed.ChildRelation, ID: fixture.ServerProcessNodeID, Label: fixture.ServerName, TopologyID: "processes"},
+			},
 		},
 		Controls: []detailed.ControlInstance{},
 		Children: []detailed.NodeSummaryGroup{
@@ -416,3 +478,87 @@ func TestMakeDetailedPodNode(t *testing.T) {
 		t.Errorf("%s", test.Diff(want, have))
 	}
 }
+
+func TestMakeDetailedNodeTLSServerName(t *testing.T) {
+	rpt := fixture.Report.Copy()
+	client := rpt.Endpoint.Nodes[fixture.NonContainerNodeID]
+	rpt.Endpoint.Nodes[fixture.NonContainerNodeID] = client.WithLatests(map[string]string{endpoint.TLSServerName: "dns.google"})
+
+	renderableNodes := render.ProcessRenderer.Render(rpt).Nodes
+	have := detailed.MakeNode("processes", detailed.RenderContext{Report: rpt}, renderableNodes, renderableNodes[fixture.NonContainerProcessNodeID])
+	for _, summary := range have.Connections {
+		if summary.ID != "outgoing-connections" {
+			continue
+		}
+		for _, connection := range summary.Connections {
+			if connection.NodeID == render.OutgoingInternetID {
+				if want := "dns.google (" + fixture.GoogleIP + ")"; connection.Label != want {
+					t.Errorf("expected %q, got %q", want, connection.Label)
+				}
+				return
+			}
+		}
+	}
+	t.Errorf("no outbound connection to the internet: %v", have.Connections)
+}
+
+func TestMakeDetailedNodeExternalDestinations(t *testing.T) {
+	rpt := fixture.Report.Copy()
+	client := rpt.Endpoint.Nodes[fixture.NonContainerNodeID]
+	client.Counters = client.Counters.Add(endpoint.BytesSent, 100)
+	rpt.Endpoint.Nodes[fixture.NonContainerNodeID] = client.WithLatests(map[string]string{endpoint.TLSServerName: "dns.google"})
+	asns, err := iprange.Load(strings.NewReader("8.8.8.0\t8.8.8.255\t15169\tUS\tGOOGLE\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	countries, err := geoip.FromBytes(mmdb.Build("GeoLite2-Country", map[string]map[string]interface{}{
+		"8.8.8.0/24": {"country": map[string]interface{}{"iso_code": "US"}},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	renderableNodes := render.ProcessRenderer.Render(rpt).Nodes
+	have := detailed.MakeNode("processes", detailed.RenderContext{Report: rpt, ASNs: asns, GeoIP: geoip.DBs{countries}}, renderableNodes, renderableNodes[render.OutgoingInternetID])
+	for _, table := range have.Tables {
+		if table.ID != detailed.ExternalDestinationsTableID {
+			continue
+		}
+		want := []report.Row{{
+			ID: "dns.google",
+			Entries: map[string]string{
+				"destination": "dns.google",
+				"asn":         "AS15169 GOOGLE",
+				"country":     "US",
+				"count":       "1",
+				"bytes":       "100",
+			},
+		}}
+		if !reflect.DeepEqual(want, table.Rows) {
+			t.Error(test.Diff(want, table.Rows))
+		}
+		return
+	}
+	t.Errorf("no external destinations: %v", have.Tables)
+}
+
+func TestMakeDetailedNodeExternalDestinationsWithoutBytes(t *testing.T) {
+	// Live probes don't count the bytes sent, so there are none to show
+	renderableNodes := render.ProcessRenderer.Render(fixture.Report).Nodes
+	have := detailed.MakeNode("processes", detailed.RenderContext{Report: fixture.Report}, renderableNodes, renderableNodes[render.OutgoingInternetID])
+	for _, table := range have.Tables {
+		if table.ID != detailed.ExternalDestinationsTableID {
+			continue
+		}
+		for _, column := range table.Columns {
+			if column.ID == "bytes" {
+				t.Errorf("unexpected bytes column: %v", table.Columns)
+			}
+		}
+		if len(table.Rows) != 1 || table.Rows[0].Entries["count"] != "1" {
+			t.Errorf("expected one destination of one connection: %v", table.Rows)
+		}
+		return
+	}
+	t.Errorf("no external destinations: %v", have.Tables)
+}
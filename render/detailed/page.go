@@ -0,0 +1,94 @@
+package detailed
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// Page returns up to limit of the nodes, by ID, following the node of
+// the cursor, and the cursor of the next page, if any. Cursors are the
+// IDs the pages end at, so they stay stable as nodes come and go: a page
+// never repeats nor skips the nodes of another. A limit of zero is all
+// the nodes.
+func Page(nodes report.Nodes, cursor string, limit int) (report.Nodes, string, error) {
+	after, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	if limit <= 0 && after == "" {
+		return nodes, "", nil
+	}
+	ids := make([]string, 0, len(nodes))
+	for id := range nodes {
+		if after == "" || id > after {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	next := ""
+	if limit > 0 && len(ids) > limit {
+		ids = ids[:limit]
+		next = encodeCursor(ids[limit-1])
+	}
+	result := make(report.Nodes, len(ids))
+	for _, id := range ids {
+		result[id] = nodes[id]
+	}
+	return result, next, nil
+}
+
+// PageEdgeNodes returns the nodes of the page, and those adjacent to
+// them, either way, adjacent only to the nodes of the page: the edges
+// between them are those touching the page.
+func PageEdgeNodes(nodes, page report.Nodes) report.Nodes {
+	result := make(report.Nodes, len(page))
+	for id, n := range page {
+		result[id] = n
+		for _, dstID := range n.Adjacency {
+			if _, ok := page[dstID]; ok {
+				continue
+			}
+			if dst, ok := nodes[dstID]; ok {
+				result[dstID] = restrictAdjacency(dst, page)
+			}
+		}
+	}
+	for id, n := range nodes {
+		if _, ok := result[id]; ok {
+			continue
+		}
+		for _, dstID := range n.Adjacency {
+			if _, ok := page[dstID]; ok {
+				result[id] = restrictAdjacency(n, page)
+				break
+			}
+		}
+	}
+	return result
+}
+
+func restrictAdjacency(n report.Node, page report.Nodes) report.Node {
+	adjacency := report.MakeIDList()
+	for _, id := range n.Adjacency {
+		if _, ok := page[id]; ok {
+			adjacency = adjacency.Add(id)
+		}
+	}
+	n.Adjacency = adjacency
+	return n
+}
+
+func encodeCursor(id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(id))
+}
+
+func decodeCursor(cursor string) (string, error) {
+	id, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", fmt.Errorf("invalid cursor %q", cursor)
+	}
+	return string(id), nil
+}
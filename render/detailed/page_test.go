@@ -0,0 +1,75 @@
+package detailed_test
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/weaveworks/scope/render/detailed"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestPage(t *testing.T) {
+	nodes := report.Nodes{}
+	for _, id := range []string{"e", "a", "d", "c", "b"} {
+		nodes[id] = report.MakeNode(id)
+	}
+	var (
+		have   []string
+		cursor string
+		pages  int
+	)
+	for {
+		page, next, err := detailed.Page(nodes, cursor, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []string{}
+		for id := range page {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		have = append(have, ids...)
+		pages++
+		if next == "" {
+			break
+		}
+		cursor = next
+		// Nodes coming and going don't shift the pages that follow
+		delete(nodes, "a")
+		nodes["aa"] = report.MakeNode("aa")
+	}
+	if pages != 3 || len(have) != 5 || have[0] != "a" || have[2] != "c" || have[4] != "e" {
+		t.Errorf("expected 3 pages of a..e, got %d of %v", pages, have)
+	}
+
+	if all, next, _ := detailed.Page(nodes, "", 0); len(all) != len(nodes) || next != "" {
+		t.Errorf("expected all the nodes with no limit, got %v", all)
+	}
+	if _, _, err := detailed.Page(nodes, "not a cursor!", 2); err == nil {
+		t.Error("expected invalid cursors to be rejected")
+	}
+}
+
+func TestPageEdgeNodes(t *testing.T) {
+	nodes := report.Nodes{
+		"a": report.MakeNode("a").WithAdjacent("b", "c"),
+		"b": report.MakeNode("b").WithAdjacent("c"),
+		"c": report.MakeNode("c").WithAdjacent("a", "d"),
+		"d": report.MakeNode("d"),
+		"e": report.MakeNode("e").WithAdjacent("d"),
+	}
+	page, _, err := detailed.Page(nodes, "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	have := detailed.EdgeWeights(detailed.PageEdgeNodes(nodes, page))
+	edges := []string{}
+	for e := range have {
+		edges = append(edges, e.Source+"-"+e.Target)
+	}
+	sort.Strings(edges)
+	if fmt.Sprint(edges) != "[a-b a-c c-a]" {
+		t.Errorf("expected the edges touching a, got %v", edges)
+	}
+}
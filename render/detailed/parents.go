@@ -25,6 +25,9 @@ var parentTopologies = []string{
 	report.ECSService,
 	report.SwarmService,
 	report.Host,
+	report.NetworkDevice,
+	report.DockerNetwork,
+	report.DockerVolume,
 }
 
 // Parents renders the parents of this report.Node, which have been aggregated
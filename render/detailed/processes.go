@@ -0,0 +1,44 @@
+package detailed
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/weaveworks/scope/probe/process"
+)
+
+// Group of the processes of container nodes
+const (
+	TopProcessesID  = "top-processes"
+	maxTopProcesses = 10
+)
+
+// topProcesses turns the group of the processes of a container, joined to
+// it by their cgroup, into a table of the busiest of them, by CPU then
+// memory, instead of by ID. The rows still link to the process nodes.
+func topProcesses(group NodeSummaryGroup) NodeSummaryGroup {
+	nodes := append([]NodeSummary{}, group.Nodes...)
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if ci, cj := metricValue(nodes[i], process.CPUUsage), metricValue(nodes[j], process.CPUUsage); ci != cj {
+			return ci > cj
+		}
+		return metricValue(nodes[i], process.MemoryUsage) > metricValue(nodes[j], process.MemoryUsage)
+	})
+	group.ID = TopProcessesID
+	group.Label = "Top processes"
+	if len(nodes) > maxTopProcesses {
+		group.Label = fmt.Sprintf("Top processes (%d of %d)", maxTopProcesses, len(nodes))
+		nodes = nodes[:maxTopProcesses]
+	}
+	group.Nodes = nodes
+	return group
+}
+
+func metricValue(n NodeSummary, id string) float64 {
+	for _, m := range n.Metrics {
+		if m.ID == id {
+			return m.Value
+		}
+	}
+	return 0
+}
@@ -6,9 +6,11 @@ import (
 
 	"github.com/weaveworks/scope/probe/awsecs"
 	"github.com/weaveworks/scope/probe/docker"
+	"github.com/weaveworks/scope/probe/host"
 	"github.com/weaveworks/scope/probe/kubernetes"
 	"github.com/weaveworks/scope/probe/overlay"
 	"github.com/weaveworks/scope/probe/process"
+	"github.com/weaveworks/scope/probe/snmp"
 	"github.com/weaveworks/scope/render"
 	"github.com/weaveworks/scope/report"
 )
@@ -51,6 +53,9 @@ type BasicNodeSummary struct {
 	Shape      string `json:"shape,omitempty"`
 	Stack      bool   `json:"stack,omitempty"`
 	Pseudo     bool   `json:"pseudo,omitempty"`
+	// StableID identifies the node across restarts, if the probe could
+	// tell, where its ID doesn't.
+	StableID string `json:"stableId,omitempty"`
 }
 
 // NodeSummary is summary information about a Node.
@@ -58,9 +63,12 @@ type NodeSummary struct {
 	BasicNodeSummary
 	Metadata  []report.MetadataRow `json:"metadata,omitempty"`
 	Parents   []Parent             `json:"parents,omitempty"`
+	Links     []Link               `json:"links,omitempty"`
 	Metrics   []report.MetricRow   `json:"metrics,omitempty"`
 	Tables    []report.Table       `json:"tables,omitempty"`
 	Adjacency report.IDList        `json:"adjacency,omitempty"`
+	// Histograms are those of the node, and of its children, summed
+	Histograms report.Histograms `json:"histograms,omitempty"`
 }
 
 var renderers = map[string]func(BasicNodeSummary, report.Node) BasicNodeSummary{
@@ -83,6 +91,11 @@ var renderers = map[string]func(BasicNodeSummary, report.Node) BasicNodeSummary{
 	report.PersistentVolume:      persistentVolumeNodeSummary,
 	report.PersistentVolumeClaim: persistentVolumeClaimNodeSummary,
 	report.StorageClass:          storageClassNodeSummary,
+	report.NetworkInterface:      networkInterfaceNodeSummary,
+	report.CustomResource:        customResourceNodeSummary,
+	report.NetworkDevice:         networkDeviceNodeSummary,
+	report.DockerNetwork:         dockerNetworkNodeSummary,
+	report.DockerVolume:          dockerVolumeNodeSummary,
 }
 
 // For each report.Topology, map to a 'primary' API topology. This can then be used in a variety of places.
@@ -103,6 +116,11 @@ var primaryAPITopology = map[string]string{
 	report.PersistentVolume:      "pods",
 	report.PersistentVolumeClaim: "pods",
 	report.StorageClass:          "pods",
+	report.NetworkInterface:      "interfaces",
+	report.CustomResource:        "custom-resources",
+	report.NetworkDevice:         "network-devices",
+	report.DockerNetwork:         "docker-networks",
+	report.DockerVolume:          "containers",
 }
 
 // MakeBasicNodeSummary returns a basic summary of a node, if
@@ -113,6 +131,7 @@ func MakeBasicNodeSummary(r report.Report, n report.Node) (BasicNodeSummary, boo
 		Label: n.ID,
 		Shape: report.Triangle,
 	}
+	summary.StableID, _ = n.Latest.Lookup(report.StableID)
 	if t, ok := r.Topology(n.Topology); ok {
 		summary.Shape = t.GetShape()
 	}
@@ -151,7 +170,9 @@ func MakeNodeSummary(rc RenderContext, n report.Node) (NodeSummary, bool) {
 		BasicNodeSummary: base,
 		Parents:          Parents(rc.Report, n),
 		Adjacency:        n.Adjacency,
+		Histograms:       FlattenHistograms(n),
 	}
+	summary.Links = links(rc.Report, n, summary.Parents)
 	// Only include metadata, metrics, tables when it's not a group node
 	if _, ok := n.Counters.Lookup(n.Topology); !ok {
 		if topology, ok := rc.Topology(n.Topology); ok {
@@ -163,6 +184,22 @@ func MakeNodeSummary(rc RenderContext, n report.Node) (NodeSummary, bool) {
 	return RenderMetricURLs(summary, n, rc.Report, rc.MetricsGraphURL), true
 }
 
+// FlattenHistograms returns the histograms of the node merged with those
+// of its children, such that group nodes, e.g. of the containers of an
+// image, have the distributions of all of them. The node's own
+// histogram of a key is taken to include those of its children, if any.
+func FlattenHistograms(n report.Node) report.Histograms {
+	result := n.Histograms
+	n.Children.ForEach(func(child report.Node) {
+		for key, h := range child.Histograms {
+			if _, ok := n.Histograms[key]; !ok {
+				result = result.Merge(report.Histograms{key: h})
+			}
+		}
+	})
+	return result
+}
+
 // SummarizeMetrics returns a copy of the NodeSummary where the metrics are
 // replaced with their summaries
 func (n NodeSummary) SummarizeMetrics() NodeSummary {
@@ -363,6 +400,35 @@ func hostNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
 	return base
 }
 
+func networkInterfaceNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
+	hostID, name, _ := report.ParseNetworkInterfaceNodeID(n.ID)
+	kind, _ := n.Latest.Lookup(host.InterfaceType)
+	base.Label, base.Rank = name, name
+	base.LabelMinor = fmt.Sprintf("%s (%s)", hostID, kind)
+	return base
+}
+
+func networkDeviceNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
+	base.Label, _ = n.Latest.Lookup(snmp.DeviceName)
+	base.LabelMinor, _ = n.Latest.Lookup(snmp.DeviceAddress)
+	base.Rank = base.Label
+	return base
+}
+
+func dockerNetworkNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
+	base.Label, _ = n.Latest.Lookup(docker.NetworkName)
+	base.LabelMinor, _ = n.Latest.Lookup(docker.NetworkDriver)
+	base.Rank = base.Label
+	return base
+}
+
+func dockerVolumeNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
+	base.Label, _ = n.Latest.Lookup(docker.VolumeName)
+	base.LabelMinor, _ = n.Latest.Lookup(docker.VolumeDriver)
+	base.Rank = base.Label
+	return base
+}
+
 func weaveNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
 	var (
 		nickname, _ = n.Latest.Lookup(overlay.WeavePeerNickName)
@@ -392,6 +458,12 @@ func storageClassNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSumm
 	return base
 }
 
+func customResourceNodeSummary(base BasicNodeSummary, n report.Node) BasicNodeSummary {
+	base = addKubernetesLabelAndRank(base, n)
+	base.LabelMinor, _ = n.Latest.Lookup(kubernetes.NodeType)
+	return base
+}
+
 // groupNodeSummary renders the summary for a group node. n.Topology is
 // expected to be of the form: group:container:hostname
 func groupNodeSummary(base BasicNodeSummary, r report.Report, n report.Node) BasicNodeSummary {
@@ -428,6 +500,12 @@ func (s nodeSummariesByID) Less(i, j int) bool { return s[i].ID < s[j].ID }
 // NodeSummaries is a set of NodeSummaries indexed by ID.
 type NodeSummaries map[string]NodeSummary
 
+// SparklineSamples is how many of the latest samples of their metrics, of
+// the reports the collector merged, the summaries of nodes keep, so that
+// clients can draw sparklines of them without asking for the details of
+// every node.
+const SparklineSamples = 10
+
 // Summaries converts RenderableNodes into a set of NodeSummaries
 func Summaries(rc RenderContext, rns report.Nodes) NodeSummaries {
 
@@ -435,7 +513,7 @@ func Summaries(rc RenderContext, rns report.Nodes) NodeSummaries {
 	for id, node := range rns {
 		if summary, ok := MakeNodeSummary(rc, node); ok {
 			for i, m := range summary.Metrics {
-				summary.Metrics[i] = m.Summary()
+				summary.Metrics[i] = m.Sparkline(SparklineSamples)
 			}
 			result[id] = summary
 		}
@@ -44,10 +44,13 @@ func TestSummaries(t *testing.T) {
 		}
 	}
 
-	// It should summarize nodes' metrics
+	// It should summarize nodes' metrics, with their latest samples
 	{
-		t1, t2 := mtime.Now().Add(-1*time.Minute), mtime.Now()
-		metric := report.MakeMetric([]report.Sample{{Timestamp: t1, Value: 1}, {Timestamp: t2, Value: 2}})
+		var samples []report.Sample
+		for i := 0; i < detailed.SparklineSamples+2; i++ {
+			samples = append(samples, report.Sample{Timestamp: mtime.Now().Add(time.Duration(i) * time.Second), Value: float64(i)})
+		}
+		metric := report.MakeMetric(samples)
 		input := fixture.Report.Copy()
 		processNode := input.Process.Nodes[fixture.ClientProcess1NodeID]
 		processNode.Metrics = processNode.Metrics.Copy()
@@ -78,10 +81,10 @@ func TestSummaries(t *testing.T) {
 			ID:       process.CPUUsage,
 			Label:    "CPU",
 			Format:   "percent",
-			Value:    2,
+			Value:    detailed.SparklineSamples + 1,
 			Priority: 1,
 			Metric: &report.Metric{
-				Samples: nil,
+				Samples: samples[2:],
 				Min:     metric.Min,
 				Max:     metric.Max,
 			},
@@ -131,7 +134,11 @@ func TestMakeNodeSummary(t *testing.T) {
 				},
 				Metadata: []report.MetadataRow{
 					{ID: docker.ImageName, Label: "Image name", Value: fixture.ClientContainerImageName, Priority: 2},
-					{ID: docker.ContainerID, Label: "ID", Value: fixture.ClientContainerID, Priority: 11, Truncate: 12},
+					{ID: docker.ContainerID, Label: "ID", Value: fixture.ClientContainerID, Priority: 13, Truncate: 12},
+				},
+				Links: []detailed.Link{
+					{Relation: detailed.ChildRelation, ID: fixture.ClientProcess1NodeID, Label: fixture.Client1Name, TopologyID: "processes"},
+					{Relation: detailed.ChildRelation, ID: fixture.ClientProcess2NodeID, Label: fixture.Client2PID, TopologyID: "processes"},
 				},
 				Adjacency: report.MakeIDList(fixture.ServerContainerNodeID),
 			},
@@ -152,6 +159,11 @@ func TestMakeNodeSummary(t *testing.T) {
 				Metadata: []report.MetadataRow{
 					{ID: report.Container, Label: "# Containers", Value: "1", Priority: 2, Datatype: report.Number},
 				},
+				Links: []detailed.Link{
+					{Relation: detailed.ChildRelation, ID: fixture.ClientContainerNodeID, Label: fixture.ClientContainerName, TopologyID: "containers"},
+					{Relation: detailed.ChildRelation, ID: fixture.ClientProcess1NodeID, Label: fixture.Client1Name, TopologyID: "processes"},
+					{Relation: detailed.ChildRelation, ID: fixture.ClientProcess2NodeID, Label: fixture.Client2PID, TopologyID: "processes"},
+				},
 				Adjacency: report.MakeIDList(expected.ServerContainerImageNodeID),
 			},
 		},
@@ -170,6 +182,13 @@ func TestMakeNodeSummary(t *testing.T) {
 				Metadata: []report.MetadataRow{
 					{ID: host.HostName, Label: "Hostname", Value: fixture.ClientHostName, Priority: 11},
 				},
+				Links: []detailed.Link{
+					{Relation: detailed.ChildRelation, ID: fixture.ClientContainerNodeID, Label: fixture.ClientContainerName, TopologyID: "containers"},
+					{Relation: detailed.ChildRelation, ID: expected.ClientContainerImageNodeID, Label: fixture.ClientContainerImageName, TopologyID: "containers-by-image"},
+					{Relation: detailed.ChildRelation, ID: fixture.ClientPodNodeID, Label: fixture.ClientPodUID, TopologyID: "pods"},
+					{Relation: detailed.ChildRelation, ID: fixture.ClientProcess1NodeID, Label: fixture.Client1Name, TopologyID: "processes"},
+					{Relation: detailed.ChildRelation, ID: fixture.ClientProcess2NodeID, Label: fixture.Client2PID, TopologyID: "processes"},
+				},
 				Adjacency: report.MakeIDList(fixture.ServerHostNodeID),
 			},
 		},
@@ -186,6 +205,9 @@ func TestMakeNodeSummary(t *testing.T) {
 					Shape:      "square",
 					Stack:      true,
 				},
+				Links: []detailed.Link{
+					{Relation: detailed.ChildRelation, ID: fixture.ServerProcessNodeID, Label: fixture.ServerPID, TopologyID: "processes"},
+				},
 			},
 		},
 	}
@@ -251,8 +273,8 @@ func TestNodeMetadata(t *testing.T) {
 			),
 			want: []report.MetadataRow{
 				{ID: docker.ContainerStateHuman, Label: "State", Value: "running", Priority: 4},
-				{ID: docker.ContainerIPs, Label: "IPs", Value: "10.10.10.0/24, 10.10.10.1/24", Priority: 8},
-				{ID: docker.ContainerID, Label: "ID", Value: fixture.ClientContainerID, Priority: 11, Truncate: 12},
+				{ID: docker.ContainerIPs, Label: "IPs", Value: "10.10.10.0/24, 10.10.10.1/24", Priority: 10},
+				{ID: docker.ContainerID, Label: "ID", Value: fixture.ClientContainerID, Priority: 13, Truncate: 12},
 			},
 		},
 		{
@@ -403,6 +425,27 @@ func TestMetricRowSummary(t *testing.T) {
 	}
 }
 
+func TestMetricRowSparkline(t *testing.T) {
+	now := time.Now()
+	metric := report.MakeMetric([]report.Sample{
+		{Timestamp: now, Value: 1},
+		{Timestamp: now.Add(time.Second), Value: 2},
+		{Timestamp: now.Add(2 * time.Second), Value: 3},
+	})
+	row := report.MetricRow{ID: "id", Value: 3, Metric: &metric}
+
+	sparkline := row.Sparkline(2)
+	if want := metric.Samples[1:]; !reflect.DeepEqual(want, sparkline.Metric.Samples) {
+		t.Errorf("Expected the last samples: %s", test.Diff(want, sparkline.Metric.Samples))
+	}
+	if metric.Len() != 3 {
+		t.Errorf("Expected original metric to still have its samples, but had %d", metric.Len())
+	}
+	if sparkline := row.Sparkline(5); sparkline.Metric.Len() != 3 {
+		t.Errorf("Expected every sample, but had %d", sparkline.Metric.Len())
+	}
+}
+
 func TestNodeTables(t *testing.T) {
 	inputs := []struct {
 		name string
@@ -469,3 +512,31 @@ func TestNodeTables(t *testing.T) {
 		}
 	}
 }
+
+func TestFlattenHistograms(t *testing.T) {
+	var (
+		latency  = report.MakeHistogram(10, 100)
+		duration = report.MakeHistogram(60)
+		child1   = report.MakeNode("child1").WithHistogram("latency", latency.Observe(5)).WithHistogram("duration", duration.Observe(30))
+		child2   = report.MakeNode("child2").WithHistogram("latency", latency.Observe(50, 500))
+	)
+	group := report.MakeNode("group").WithChildren(report.MakeNodeSet(child1, child2))
+	want := report.Histograms{
+		"latency":  latency.Observe(5, 50, 500),
+		"duration": duration.Observe(30),
+	}
+	if have := detailed.FlattenHistograms(group); !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+
+	// The node's own histograms include those of its children
+	parent := group.WithHistogram("latency", latency.Observe(5, 50, 500, 1))
+	want["latency"] = latency.Observe(5, 50, 500, 1)
+	if have := detailed.FlattenHistograms(parent); !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+
+	if have := detailed.FlattenHistograms(report.MakeNode("none")); len(have) != 0 {
+		t.Errorf("expected no histograms, have %v", have)
+	}
+}
@@ -0,0 +1,89 @@
+package render
+
+import (
+	"github.com/weaveworks/scope/report"
+)
+
+// RawEdgeDirections keeps the edges between endpoints in the directions
+// the probes reported them, rather than turning them client→server
+// wherever a probe knows which end initiated the connection. Probes
+// guess the direction of connections they only saw in /proc, so the same
+// connection can otherwise show as an edge each way.
+var RawEdgeDirections = false
+
+// initiated tells if the endpoint is known to have initiated its
+// connections, or to have accepted them.
+func initiated(n report.Node) (initiator, known bool) {
+	value, ok := n.Latest.Lookup(report.Initiator)
+	if !ok {
+		return false, false
+	}
+	return value == "true", true
+}
+
+// orientEndpoints reverses the edges from endpoints known to have
+// accepted the connection, or to endpoints known to have initiated it,
+// so that the edges of a connection all go client→server.
+func orientEndpoints(endpoints Nodes) Nodes {
+	if RawEdgeDirections {
+		return endpoints
+	}
+	reversed := map[string][]string{} // by the endpoint gaining the edges
+	for _, n := range endpoints.Nodes {
+		srcInitiator, srcKnown := initiated(n)
+		if srcKnown && srcInitiator {
+			continue
+		}
+		for _, dst := range n.Adjacency {
+			m, ok := endpoints.Nodes[dst]
+			if !ok {
+				continue
+			}
+			if dstInitiator, dstKnown := initiated(m); srcKnown || (dstKnown && dstInitiator) {
+				reversed[dst] = append(reversed[dst], n.ID)
+			}
+		}
+	}
+	if len(reversed) == 0 {
+		return endpoints
+	}
+
+	removed := map[string]map[string]struct{}{} // by the endpoint losing the edges
+	for dst, srcs := range reversed {
+		for _, src := range srcs {
+			if removed[src] == nil {
+				removed[src] = map[string]struct{}{}
+			}
+			removed[src][dst] = struct{}{}
+		}
+	}
+	result := make(report.Nodes, len(endpoints.Nodes))
+	for id, n := range endpoints.Nodes {
+		result[id] = n
+	}
+	for src, dsts := range removed {
+		n := result[src]
+		adjacency := make([]string, 0, len(n.Adjacency))
+		for _, dst := range n.Adjacency {
+			if _, ok := dsts[dst]; !ok {
+				adjacency = append(adjacency, dst)
+			}
+		}
+		n.Adjacency = report.MakeIDList(adjacency...)
+		result[src] = n
+	}
+	for dst, srcs := range reversed {
+		n := result[dst]
+		n.Adjacency = report.MakeIDList(append(append([]string{}, n.Adjacency...), srcs...)...)
+		result[dst] = n
+	}
+	return Nodes{Nodes: result, Filtered: endpoints.Filtered}
+}
+
+// selectOrientedEndpoints selects the endpoint topology, with its edges
+// to virtual IPs resolved to their backends and oriented client→server.
+type selectOrientedEndpoints struct{}
+
+func (selectOrientedEndpoints) Render(rpt report.Report) Nodes {
+	return orientEndpoints(resolveVIPs(SelectEndpoint.Render(rpt)))
+}
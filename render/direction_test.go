@@ -0,0 +1,49 @@
+package render_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+func TestEndpointEdgeDirections(t *testing.T) {
+	var (
+		now    = time.Now()
+		client = report.MakeEndpointNodeID("", "", "10.0.0.1", "54321")
+		server = report.MakeEndpointNodeID("", "", "10.0.0.2", "80")
+		other  = report.MakeEndpointNodeID("", "", "10.0.0.3", "40000")
+		rpt    = report.MakeReport()
+	)
+	// The probe of the client saw the connection in conntrack, while the
+	// probe of the server guessed it the wrong way from the ports
+	rpt.Endpoint.AddNode(report.MakeNode(client).WithLatest(report.Initiator, now, "true").WithAdjacent(server))
+	rpt.Endpoint.AddNode(report.MakeNode(server).WithLatest(report.Initiator, now, "false").WithAdjacent(client))
+	// Nothing is known of this one, so it is kept as reported
+	rpt.Endpoint.AddNode(report.MakeNode(other).WithAdjacent(server))
+
+	adjacency := func() map[string]report.IDList {
+		result := map[string]report.IDList{}
+		for id, n := range render.EndpointRenderer.Render(rpt).Nodes {
+			result[id] = n.Adjacency
+		}
+		return result
+	}
+	want := map[string]report.IDList{
+		client: report.MakeIDList(server),
+		server: report.MakeIDList(),
+		other:  report.MakeIDList(server),
+	}
+	if have := adjacency(); !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+
+	render.RawEdgeDirections = true
+	defer func() { render.RawEdgeDirections = false }()
+	want[server] = report.MakeIDList(client)
+	if have := adjacency(); !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+}
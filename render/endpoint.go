@@ -11,7 +11,7 @@ import (
 const Pseudo = "pseudo"
 
 // EndpointRenderer is a Renderer which produces a renderable endpoint graph.
-var EndpointRenderer = SelectEndpoint
+var EndpointRenderer Renderer = collapseEphemeralPorts{selectOrientedEndpoints{}}
 
 type endpointMapFunc func(report.Node) string
 
@@ -29,7 +29,7 @@ func MapEndpoints(f endpointMapFunc, topology string) Renderer {
 
 func (e mapEndpoints) Render(rpt report.Report) Nodes {
 	local := LocalNetworks(rpt)
-	endpoints := SelectEndpoint.Render(rpt)
+	endpoints := selectOrientedEndpoints{}.Render(rpt)
 	ret := newJoinResults(TopologySelector(e.topology).Render(rpt).Nodes)
 
 	for _, n := range endpoints.Nodes {
@@ -0,0 +1,93 @@
+package render
+
+import (
+	"strconv"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// Ports from which client endpoints are assumed to be ephemeral, when
+// the probes don't know which end initiated the connection. This is the
+// lower bound of the default range of Linux.
+const ephemeralPortMin = 32768
+
+// collapseEphemeralPorts collapses the client endpoints of each address
+// connected to the same peer endpoint, which mostly differ in their
+// ephemeral ports, into a single endpoint. The collapsed endpoints
+// count how many they stand for, as report.CollapsedPorts. Endpoints
+// alone in their group are left as they are.
+type collapseEphemeralPorts struct {
+	Renderer
+}
+
+func (c collapseEphemeralPorts) Render(rpt report.Report) Nodes {
+	endpoints := c.Renderer.Render(rpt)
+	destinations := map[string]struct{}{}
+	for _, n := range endpoints.Nodes {
+		for _, dst := range n.Adjacency {
+			destinations[dst] = struct{}{}
+		}
+	}
+
+	groups := map[string][]report.Node{} // by collapsed ID
+	for id, n := range endpoints.Nodes {
+		if _, ok := destinations[id]; ok || len(n.Adjacency) != 1 {
+			continue
+		}
+		if collapsedID, ok := ephemeralGroup(n); ok {
+			groups[collapsedID] = append(groups[collapsedID], n)
+		}
+	}
+
+	var result report.Nodes
+	for collapsedID, members := range groups {
+		if len(members) < 2 {
+			continue
+		}
+		if result == nil {
+			result = make(report.Nodes, len(endpoints.Nodes))
+			for id, n := range endpoints.Nodes {
+				result[id] = n
+			}
+		}
+		collapsed := report.MakeNode(collapsedID)
+		for _, n := range members {
+			delete(result, n.ID)
+			n.ID = collapsedID
+			collapsed = collapsed.Merge(n.WithCounters(map[string]int{report.CollapsedPorts: 1}))
+		}
+		result[collapsedID] = collapsed
+	}
+	if result == nil {
+		return endpoints
+	}
+	return Nodes{Nodes: result, Filtered: endpoints.Filtered}
+}
+
+// ephemeralGroup returns the ID of the endpoint a client endpoint
+// collapses into: that of its address, with a port naming its peer.
+func ephemeralGroup(n report.Node) (string, bool) {
+	if _, ok := n.Latest.Lookup(report.Listening); ok {
+		return "", false
+	}
+	scope, address, port, ok := report.ParseEndpointNodeID(n.ID)
+	if !ok {
+		return "", false
+	}
+	if initiator, known := initiated(n); known && !initiator {
+		return "", false
+	} else if !known {
+		if p, err := strconv.Atoi(port); err != nil || p < ephemeralPortMin {
+			return "", false
+		}
+	}
+	_, peerAddress, peerPort, ok := report.ParseEndpointNodeID(n.Adjacency[0])
+	if !ok {
+		return "", false
+	}
+	group := "*->" + peerAddress + ":" + peerPort
+	if report.IsUDPEndpointNodeID(n.ID) {
+		group += report.UDPPortSuffix
+	}
+	return report.MakeScopedEndpointNodeID(scope, address, group), true
+}
@@ -0,0 +1,36 @@
+package render_test
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+)
+
+func TestCollapseEphemeralPorts(t *testing.T) {
+	var (
+		server = report.MakeEndpointNodeID("", "", "10.0.0.2", "80")
+		rpt    = report.MakeReport()
+	)
+	rpt.Endpoint.AddNode(report.MakeNode(server))
+	for _, port := range []string{"40001", "40002", "40003"} {
+		rpt.Endpoint.AddNode(report.MakeNode(report.MakeEndpointNodeID("", "", "10.0.0.1", port)).WithAdjacent(server))
+	}
+	// Not ephemeral, so left alone
+	rpt.Endpoint.AddNode(report.MakeNode(report.MakeEndpointNodeID("", "", "10.0.0.1", "1024")).WithAdjacent(server))
+
+	have := render.EndpointRenderer.Render(rpt).Nodes
+	if len(have) != 3 {
+		t.Fatalf("expected 3 endpoints, have %v", have)
+	}
+	collapsed, ok := have[report.MakeScopedEndpointNodeID("", "10.0.0.1", "*->10.0.0.2:80")]
+	if !ok {
+		t.Fatalf("expected the clients collapsed, have %v", have)
+	}
+	if count, _ := collapsed.Counters.Lookup(report.CollapsedPorts); count != 3 {
+		t.Errorf("expected 3 ports collapsed, have %d", count)
+	}
+	if !collapsed.Adjacency.Contains(server) {
+		t.Errorf("expected the collapsed clients adjacent to the server, have %v", collapsed.Adjacency)
+	}
+}
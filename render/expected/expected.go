@@ -85,6 +85,20 @@ var (
 		fixture.GoogleEndpointNodeID: endpoint(fixture.GoogleEndpointNodeID),
 	}
 
+	// The clients of the same address connected to the server collapse
+	collapsedClientNodeID        = report.MakeScopedEndpointNodeID("", fixture.ClientIP, "*->"+fixture.ServerIP+":"+fixture.ServerPort)
+	collapsedUnknownClientNodeID = report.MakeScopedEndpointNodeID("", fixture.UnknownClient1IP, "*->"+fixture.ServerIP+":"+fixture.ServerPort)
+
+	RenderedCollapsedEndpoints = report.Nodes{
+		collapsedClientNodeID:        endpoint(collapsedClientNodeID, fixture.Server80NodeID),
+		fixture.Server80NodeID:       endpoint(fixture.Server80NodeID),
+		collapsedUnknownClientNodeID: endpoint(collapsedUnknownClientNodeID, fixture.Server80NodeID),
+		fixture.UnknownClient3NodeID: endpoint(fixture.UnknownClient3NodeID, fixture.Server80NodeID),
+		fixture.RandomClientNodeID:   endpoint(fixture.RandomClientNodeID, fixture.Server80NodeID),
+		fixture.NonContainerNodeID:   endpoint(fixture.NonContainerNodeID, fixture.GoogleEndpointNodeID),
+		fixture.GoogleEndpointNodeID: endpoint(fixture.GoogleEndpointNodeID),
+	}
+
 	RenderedProcesses = report.Nodes{
 		fixture.ClientProcess1NodeID: processNode(fixture.ClientProcess1NodeID, fixture.ServerProcessNodeID).
 			WithLatests(map[string]string{
@@ -128,11 +128,12 @@ func IsConnected(node report.Node) bool {
 	return ok
 }
 
-// IsPodComponent check whether given node is everything but PV, PVC, SC
+// IsPodComponent check whether given node is everything but PV, PVC, SC,
+// and Docker volumes
 func IsPodComponent(node report.Node) bool {
 	var ok bool
 	ok = true
-	if node.Topology == "persistent_volume" || node.Topology == "persistent_volume_claim" || node.Topology == "storage_class" {
+	if node.Topology == "persistent_volume" || node.Topology == "persistent_volume_claim" || node.Topology == "storage_class" || node.Topology == report.DockerVolume {
 		ok = false
 	}
 	return ok
@@ -301,6 +302,15 @@ func IsNamespace(namespace string) FilterFunc {
 	}
 }
 
+// IsCluster checks if the node was reported from the specified cluster.
+// Nodes not known to be in any cluster, e.g. pseudo nodes, are kept.
+func IsCluster(cluster string) FilterFunc {
+	return func(n report.Node) bool {
+		gotCluster, ok := n.Latest.Lookup(kubernetes.Cluster)
+		return !ok || gotCluster == cluster
+	}
+}
+
 // IsTopology checks if the node is from a particular report topology
 func IsTopology(topology string) FilterFunc {
 	return func(n report.Node) bool {
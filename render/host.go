@@ -1,21 +1,55 @@
 package render
 
 import (
+	"github.com/weaveworks/scope/probe/kubernetes"
 	"github.com/weaveworks/scope/report"
 )
 
 // HostRenderer is a Renderer which produces a renderable host
-// graph from the host topology.
+// graph from the host topology, with the metrics of the host's processes
+// rolled up, and hosts reported under several IDs collapsed into one.
 //
 // not memoised
-var HostRenderer = MakeReduce(
+var HostRenderer = CollapseAliases(SumChildMetrics(report.Process, processMetrics, MakeReduce(
 	CustomRenderer{RenderFunc: nodes2Hosts, Renderer: ProcessRenderer},
 	CustomRenderer{RenderFunc: nodes2Hosts, Renderer: ContainerRenderer},
 	CustomRenderer{RenderFunc: nodes2Hosts, Renderer: ContainerImageRenderer},
 	CustomRenderer{RenderFunc: nodes2Hosts, Renderer: PodRenderer},
+	CustomRenderer{RenderFunc: nodes2Hosts, Renderer: SelectNetworkInterface},
 	MapEndpoints(endpoint2Host, report.Host),
+)))
+
+// HostClusterRenderer is a Renderer which produces a renderable graph
+// of the clusters of the hosts, by grouping hosts by cluster.
+//
+// not memoised
+var HostClusterRenderer = FilterEmpty(report.Host,
+	MakeMap(
+		MapHost2Cluster,
+		HostRenderer,
+	),
 )
 
+var hostClusterTopology = MakeGroupNodeTopology(report.Host, kubernetes.Cluster)
+
+// MapHost2Cluster maps host Nodes to 'cluster' renderable nodes.
+func MapHost2Cluster(n report.Node) report.Node {
+	// Propagate all pseudo nodes
+	if n.Topology == Pseudo {
+		return n
+	}
+
+	// Hosts not known to be in a cluster are dropped
+	id, ok := n.Latest.Lookup(kubernetes.Cluster)
+	if !ok {
+		return report.Node{}
+	}
+
+	node := NewDerivedNode(id, n).WithTopology(hostClusterTopology)
+	node.Counters = node.Counters.Add(n.Topology, 1)
+	return node
+}
+
 // nodes2Hosts maps any Nodes to host Nodes.
 //
 // If this function is given a node without a hostname
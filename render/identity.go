@@ -0,0 +1,157 @@
+package render
+
+import (
+	"sort"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// Entities have a single identity under these schemes, so nodes
+// disagreeing on one of them are of different entities, whatever else
+// they share: e.g. hosts sharing the IP of a docker bridge.
+var uniqueIdentitySchemes = map[string]bool{
+	report.IdentityHostname:   true,
+	report.IdentityInstanceID: true,
+	report.IdentityPodUID:     true,
+}
+
+// ResolveAliases links the nodes referring to the same entity under
+// different IDs, by the identities they share, returning the ID of the
+// node each alias is collapsed into: the lowest ID of each alias set.
+// Identities shared by nodes of different entities are ignored.
+func ResolveAliases(nodes report.Nodes) map[string]string {
+	holders := map[string][]string{}
+	unique := map[string]map[string]report.StringSet{} // unique identities of nodes, by scheme
+	ids := make([]string, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		identities, _ := nodes[id].Sets.Lookup(report.Identities)
+		for _, identity := range identities {
+			holders[identity] = append(holders[identity], id)
+			if scheme, _, ok := report.ParseIdentity(identity); ok && uniqueIdentitySchemes[scheme] {
+				if unique[id] == nil {
+					unique[id] = map[string]report.StringSet{}
+				}
+				unique[id][scheme] = unique[id][scheme].Add(identity)
+			}
+		}
+	}
+	if len(holders) == 0 {
+		return nil
+	}
+
+	sets := newAliasSets(unique)
+	identities := make([]string, 0, len(holders))
+	for identity, held := range holders {
+		if len(held) > 1 {
+			identities = append(identities, identity)
+		}
+	}
+	sort.Strings(identities)
+	for _, identity := range identities {
+		held := holders[identity]
+		if !sets.consistent(held) {
+			continue
+		}
+		for _, id := range held[1:] {
+			sets.union(held[0], id)
+		}
+	}
+
+	aliases := map[string]string{}
+	for _, id := range ids {
+		if root := sets.find(id); root != id {
+			aliases[id] = root
+		}
+	}
+	return aliases
+}
+
+// aliasSets are disjoint sets of node IDs, rooted at their lowest ID,
+// with the unique identities of their members.
+type aliasSets struct {
+	parent map[string]string
+	unique map[string]map[string]report.StringSet // by root
+}
+
+func newAliasSets(unique map[string]map[string]report.StringSet) *aliasSets {
+	return &aliasSets{parent: map[string]string{}, unique: unique}
+}
+
+func (s *aliasSets) find(id string) string {
+	parent, ok := s.parent[id]
+	if !ok || parent == id {
+		return id
+	}
+	root := s.find(parent)
+	s.parent[id] = root
+	return root
+}
+
+// consistent is whether the sets of the nodes agree on their unique
+// identities.
+func (s *aliasSets) consistent(ids []string) bool {
+	seen := map[string]report.StringSet{}
+	for _, id := range ids {
+		for scheme, identities := range s.unique[s.find(id)] {
+			seen[scheme], _ = seen[scheme].Merge(identities)
+			if len(seen[scheme]) > 1 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// union joins the sets of a and b, unless they disagree on their unique
+// identities.
+func (s *aliasSets) union(a, b string) {
+	ra, rb := s.find(a), s.find(b)
+	if ra == rb || !s.consistent([]string{ra, rb}) {
+		return
+	}
+	if rb < ra {
+		ra, rb = rb, ra
+	}
+	s.parent[rb] = ra
+	for scheme, identities := range s.unique[rb] {
+		if s.unique[ra] == nil {
+			s.unique[ra] = map[string]report.StringSet{}
+		}
+		s.unique[ra][scheme], _ = s.unique[ra][scheme].Merge(identities)
+	}
+	delete(s.unique, rb)
+}
+
+// CollapseAliases is a Renderer which collapses the nodes rendered by r
+// referring to the same entity, as resolved by ResolveAliases, into one,
+// with the IDs of the others as its aliases.
+func CollapseAliases(r Renderer) Renderer {
+	return collapseAliases{r}
+}
+
+type collapseAliases struct {
+	Renderer
+}
+
+func (c collapseAliases) Render(rpt report.Report) Nodes {
+	input := c.Renderer.Render(rpt)
+	aliases := ResolveAliases(input.Nodes)
+	if len(aliases) == 0 {
+		return input
+	}
+	output := newJoinResults(nil)
+	for id, n := range input.Nodes {
+		n.Adjacency = nil // rewritten by result()
+		if canonical, ok := aliases[id]; ok {
+			n = n.WithID(canonical).WithSet(report.Aliases, report.MakeStringSet(id))
+		}
+		output.add(id, n)
+	}
+	result := output.result(input)
+	result.Filtered = input.Filtered
+	return result
+}
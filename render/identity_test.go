@@ -0,0 +1,51 @@
+package render_test
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+func TestResolveAliases(t *testing.T) {
+	var (
+		byName     = report.MakeHostNodeID("foo")
+		byInstance = report.MakeHostNodeID("i-0123")
+		other      = report.MakeHostNodeID("bar")
+		client     = report.MakeHostNodeID("baz")
+	)
+	nodes := report.Nodes{
+		byName: report.MakeNode(byName).WithTopology(report.Host).
+			WithIdentities(report.IdentityHostname, "foo").
+			WithIdentities(report.IdentityIP, "10.0.0.1", "172.17.0.1"),
+		byInstance: report.MakeNode(byInstance).WithTopology(report.Host).
+			WithIdentities(report.IdentityInstanceID, "i-0123").
+			WithIdentities(report.IdentityIP, "10.0.0.1"),
+		// Shares the IP of the docker bridge, but is another host
+		other: report.MakeNode(other).WithTopology(report.Host).
+			WithIdentities(report.IdentityHostname, "bar").
+			WithIdentities(report.IdentityIP, "10.0.0.2", "172.17.0.1"),
+		client: report.MakeNode(client).WithTopology(report.Host).WithAdjacent(byInstance),
+	}
+
+	if want, have := map[string]string{byInstance: byName}, render.ResolveAliases(nodes); !reflect.DeepEqual(want, have) {
+		t.Errorf("want %v, have %v", want, have)
+	}
+
+	rpt := report.MakeReport()
+	rpt.Host.Nodes = nodes
+	have := render.CollapseAliases(render.SelectHost).Render(rpt).Nodes
+	if _, ok := have[byInstance]; ok || len(have) != 3 {
+		t.Fatalf("expected the aliases of a host to be collapsed, have %v", have)
+	}
+	if aliases, _ := have[byName].Sets.Lookup(report.Aliases); !reflect.DeepEqual(report.MakeStringSet(byInstance), aliases) {
+		t.Errorf("expected the aliases of the host, have %v", aliases)
+	}
+	if identities, _ := have[byName].Sets.Lookup(report.Identities); !identities.Contains(report.MakeIdentity(report.IdentityInstanceID, "i-0123")) {
+		t.Errorf("expected the identities of the aliases to be merged, have %v", identities)
+	}
+	if want := report.MakeIDList(byName); !reflect.DeepEqual(want, have[client].Adjacency) {
+		t.Errorf("want %v, have %v", want, have[client].Adjacency)
+	}
+}
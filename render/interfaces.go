@@ -0,0 +1,96 @@
+package render
+
+import (
+	"strings"
+
+	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/report"
+)
+
+// NetworkInterfaceRenderer is a Renderer which produces the layer 2
+// view of the network: network interfaces, adjacent to the interfaces
+// of their neighbours and to the switch ports they are attached to.
+// Neighbours which are not the interface of a probed host, and switch
+// ports of devices which are not polled, are rendered as pseudo nodes.
+//
+// not memoised
+var NetworkInterfaceRenderer = networkInterfaceRenderer{}
+
+type networkInterfaceRenderer struct{}
+
+// Render implements Renderer
+func (networkInterfaceRenderer) Render(rpt report.Report) Nodes {
+	// Index the interfaces by address, to resolve neighbours. Addresses
+	// found on several hosts (e.g. of default bridges) are ambiguous.
+	byAddress := map[string]string{}
+	for id, n := range rpt.NetworkInterface.Nodes {
+		addrs, _ := n.Sets.Lookup(host.InterfaceAddresses)
+		for _, addr := range addrs {
+			if i := strings.IndexByte(addr, '/'); i >= 0 {
+				addr = addr[:i]
+			}
+			if other, ok := byAddress[addr]; ok && other != id {
+				byAddress[addr] = ""
+			} else {
+				byAddress[addr] = id
+			}
+		}
+	}
+
+	nodes := report.Nodes{}
+	for id, n := range rpt.NetworkInterface.Nodes {
+		// Edges to switch ports come from LLDP
+		adjacency := report.MakeIDList()
+		for _, portID := range n.Adjacency {
+			if _, ok := rpt.NetworkInterface.Nodes[portID]; !ok {
+				chassis, port, _ := report.ParseNetworkInterfaceNodeID(portID)
+				portID = MakePseudoNodeID(chassis, port)
+				if _, ok := nodes[portID]; !ok {
+					nodes[portID] = report.MakeNode(portID).WithTopology(Pseudo)
+				}
+			}
+			adjacency = adjacency.Add(portID)
+		}
+		n.Adjacency = adjacency
+
+		neighbours, _ := n.Sets.Lookup(host.InterfaceNeighbours)
+		for _, addr := range neighbours {
+			peerID, ok := byAddress[addr]
+			if peerID == id {
+				continue
+			}
+			if !ok || peerID == "" {
+				peerID = MakePseudoNodeID(addr)
+				if _, ok := nodes[peerID]; !ok {
+					nodes[peerID] = report.MakeNode(peerID).WithTopology(Pseudo)
+				}
+			}
+			n = n.WithAdjacent(peerID)
+		}
+		nodes[id] = n
+	}
+	return Nodes{Nodes: nodes}
+}
+
+// NetworkDeviceRenderer is a Renderer which produces a renderable graph
+// of the network devices polled over SNMP, with their interfaces as
+// children.
+//
+// not memoised
+var NetworkDeviceRenderer = MakeReduce(
+	SelectNetworkDevice,
+	CustomRenderer{RenderFunc: interfaces2Devices, Renderer: SelectNetworkInterface},
+)
+
+// interfaces2Devices maps the interfaces of network devices to their
+// device, dropping the interfaces of hosts.
+func interfaces2Devices(nodes Nodes) Nodes {
+	ret := newJoinResults(nil)
+	for _, n := range nodes.Nodes {
+		deviceIDs, _ := n.Parents.Lookup(report.NetworkDevice)
+		for _, id := range deviceIDs {
+			ret.addChild(n, id, report.NetworkDevice)
+		}
+	}
+	return ret.result(nodes)
+}
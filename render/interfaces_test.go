@@ -0,0 +1,96 @@
+package render_test
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/probe/host"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+func TestNetworkInterfaceRenderer(t *testing.T) {
+	var (
+		eth0A    = report.MakeNetworkInterfaceNodeID("hostA", "eth0")
+		eth0B    = report.MakeNetworkInterfaceNodeID("hostB", "eth0")
+		docker0A = report.MakeNetworkInterfaceNodeID("hostA", "docker0")
+		docker0B = report.MakeNetworkInterfaceNodeID("hostB", "docker0")
+		gateway  = render.MakePseudoNodeID("10.0.0.254")
+		bridge   = render.MakePseudoNodeID("172.17.0.1")
+	)
+	iface := func(id string, addrs, neighbours []string) report.Node {
+		return report.MakeNode(id).WithTopology(report.NetworkInterface).
+			WithSet(host.InterfaceAddresses, report.MakeStringSet(addrs...)).
+			WithSet(host.InterfaceNeighbours, report.MakeStringSet(neighbours...))
+	}
+	rpt := report.MakeReport()
+	rpt.NetworkInterface.AddNode(iface(eth0A, []string{"10.0.0.1/24"}, []string{"10.0.0.2", "10.0.0.254"}))
+	rpt.NetworkInterface.AddNode(iface(eth0B, []string{"10.0.0.2/24"}, []string{"10.0.0.1"}))
+	// Both bridges have the same address, so it can't be resolved
+	rpt.NetworkInterface.AddNode(iface(docker0A, []string{"172.17.0.1/16"}, nil))
+	rpt.NetworkInterface.AddNode(iface(docker0B, []string{"172.17.0.1/16"}, nil))
+	rpt.NetworkInterface.AddNode(iface(report.MakeNetworkInterfaceNodeID("hostC", "eth0"), []string{"172.17.0.2/16"}, []string{"172.17.0.1"}))
+
+	have := render.NetworkInterfaceRenderer.Render(rpt).Nodes
+	for id, want := range map[string][]string{
+		eth0A: {eth0B, gateway},
+		eth0B: {eth0A},
+		report.MakeNetworkInterfaceNodeID("hostC", "eth0"): {bridge},
+	} {
+		if adjacency := have[id].Adjacency; !reflect.DeepEqual(report.MakeIDList(want...), adjacency) {
+			t.Errorf("%s: want %v, have %v", id, want, adjacency)
+		}
+	}
+	for _, id := range []string{gateway, bridge} {
+		if n, ok := have[id]; !ok || n.Topology != render.Pseudo {
+			t.Errorf("expected pseudo node %s, got %v", id, n)
+		}
+	}
+}
+
+func TestNetworkDeviceRenderer(t *testing.T) {
+	var (
+		device = report.MakeNetworkDeviceNodeID("switch1")
+		port   = report.MakeNetworkInterfaceNodeID("switch1", "Gi0/1")
+		eth0   = report.MakeNetworkInterfaceNodeID("hostA", "eth0")
+	)
+	rpt := report.MakeReport()
+	rpt.NetworkDevice.AddNode(report.MakeNode(device).WithTopology(report.NetworkDevice))
+	rpt.NetworkInterface.AddNode(report.MakeNode(port).WithTopology(report.NetworkInterface).
+		WithParent(report.NetworkDevice, device))
+	rpt.NetworkInterface.AddNode(report.MakeNode(eth0).WithTopology(report.NetworkInterface).
+		WithParent(report.Host, report.MakeHostNodeID("hostA")))
+
+	have := render.NetworkDeviceRenderer.Render(rpt).Nodes
+	if len(have) != 1 {
+		t.Fatalf("expected only the device, got %v", have)
+	}
+	if _, ok := have[device].Children.Lookup(port); !ok || have[device].Children.Size() != 1 {
+		t.Errorf("expected the port of the device as its only child, got %v", have[device].Children)
+	}
+}
+
+func TestNetworkInterfaceRendererSwitchPorts(t *testing.T) {
+	var (
+		eth0A    = report.MakeNetworkInterfaceNodeID("hostA", "eth0")
+		eth0B    = report.MakeNetworkInterfaceNodeID("hostB", "eth0")
+		polled   = report.MakeNetworkInterfaceNodeID("switch1", "Gi0/1")
+		unpolled = report.MakeNetworkInterfaceNodeID("switch2", "Gi0/2")
+	)
+	rpt := report.MakeReport()
+	rpt.NetworkInterface.AddNode(report.MakeNode(polled).WithTopology(report.NetworkInterface))
+	rpt.NetworkInterface.AddNode(report.MakeNode(eth0A).WithTopology(report.NetworkInterface).WithAdjacent(polled))
+	rpt.NetworkInterface.AddNode(report.MakeNode(eth0B).WithTopology(report.NetworkInterface).WithAdjacent(unpolled))
+
+	have := render.NetworkInterfaceRenderer.Render(rpt).Nodes
+	if !have[eth0A].Adjacency.Contains(polled) {
+		t.Errorf("expected an edge to the polled switch port, got %v", have[eth0A].Adjacency)
+	}
+	pseudo := render.MakePseudoNodeID("switch2", "Gi0/2")
+	if !have[eth0B].Adjacency.Contains(pseudo) || have[eth0B].Adjacency.Contains(unpolled) {
+		t.Errorf("expected an edge to the pseudo switch port, got %v", have[eth0B].Adjacency)
+	}
+	if n, ok := have[pseudo]; !ok || n.Topology != render.Pseudo {
+		t.Errorf("expected pseudo node %s, got %v", pseudo, n)
+	}
+}
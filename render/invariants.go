@@ -0,0 +1,40 @@
+package render
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// invariantViolated is called with the violations of the invariants of
+// rendered nodes, if they are checked.
+var invariantViolated func(error)
+
+// CheckInvariants makes Render check the invariants of the nodes it
+// renders, calling f with any violation. The checks are costly, and for
+// finding bugs of renderers, e.g. in staging. f nil disables them.
+func CheckInvariants(f func(error)) {
+	invariantViolated = f
+}
+
+// Invariants returns the violations of the invariants of rendered nodes,
+// if any: the nodes are under their IDs, and have no edges to nodes not
+// rendered, e.g. as they were filtered out.
+func (r Nodes) Invariants() error {
+	var errs []string
+	for id, n := range r.Nodes {
+		if n.ID != id {
+			errs = append(errs, fmt.Sprintf("node %q under ID %q", n.ID, id))
+		}
+		for _, dst := range n.Adjacency {
+			if _, ok := r.Nodes[dst]; !ok {
+				errs = append(errs, fmt.Sprintf("edge %q -> %q to a node not rendered", id, dst))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("%d error(s): %s", len(errs), strings.Join(errs, "; "))
+	}
+	return nil
+}
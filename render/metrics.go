@@ -1,6 +1,9 @@
 package render
 
 import (
+	"math"
+	"time"
+
 	"github.com/weaveworks/scope/report"
 )
 
@@ -40,3 +43,61 @@ func (p propagateSingleMetrics) Render(rpt report.Report) Nodes {
 	}
 	return Nodes{Nodes: outputs, Filtered: nodes.Filtered}
 }
+
+// SumChildMetrics creates a renderer which rolls the metrics of a
+// node's children in the specified topology up into the node, summing
+// their latest samples. metrics maps the keys of the children's metrics
+// to the keys of the summed ones; the summed metrics get the largest
+// maximum of the children.
+func SumChildMetrics(topology string, metrics map[string]string, r Renderer) Renderer {
+	return sumChildMetrics{topology: topology, metrics: metrics, r: r}
+}
+
+type sumChildMetrics struct {
+	topology string
+	metrics  map[string]string
+	r        Renderer
+}
+
+// rollUp accumulates the latest samples of a metric of several nodes.
+type rollUp struct {
+	timestamp time.Time
+	value     float64
+	max       float64
+}
+
+func (s sumChildMetrics) Render(rpt report.Report) Nodes {
+	nodes := s.r.Render(rpt)
+	outputs := make(report.Nodes, len(nodes.Nodes))
+	for id, n := range nodes.Nodes {
+		rollUps := map[string]rollUp{}
+		n.Children.ForEach(func(child report.Node) {
+			if child.Topology != s.topology {
+				return
+			}
+			for from, to := range s.metrics {
+				metric := child.Metrics[from]
+				sample, ok := metric.LastSample()
+				if !ok {
+					continue
+				}
+				r := rollUps[to]
+				if sample.Timestamp.After(r.timestamp) {
+					r.timestamp = sample.Timestamp
+				}
+				r.value += sample.Value
+				r.max = math.Max(r.max, metric.Max)
+				rollUps[to] = r
+			}
+		})
+		if len(rollUps) > 0 {
+			metrics := make(report.Metrics, len(rollUps))
+			for key, r := range rollUps {
+				metrics[key] = report.MakeSingletonMetric(r.timestamp, r.value).WithMax(r.max)
+			}
+			n = n.WithMetrics(metrics)
+		}
+		outputs[id] = n
+	}
+	return Nodes{Nodes: outputs, Filtered: nodes.Filtered}
+}
@@ -165,3 +165,30 @@ func TestPropagateSingleMetrics(t *testing.T) {
 		}
 	}
 }
+
+func TestSumChildMetrics(t *testing.T) {
+	now := time.Now()
+	process := func(id string, cpu float64, at time.Time) report.Node {
+		return report.MakeNode(id).
+			WithTopology(report.Process).
+			WithMetrics(report.Metrics{
+				"cpu": report.MakeSingletonMetric(at, cpu).WithMax(400),
+			})
+	}
+	input := report.MakeNode("container").WithChildren(report.MakeNodeSet(
+		process("p1", 10, now.Add(-time.Second)),
+		process("p2", 5, now),
+		// Children of other topologies are ignored
+		report.MakeNode("other").WithTopology(report.Container).WithMetrics(report.Metrics{
+			"cpu": report.MakeSingletonMetric(now, 100),
+		}),
+	))
+	have := render.SumChildMetrics(report.Process, map[string]string{"cpu": "processes_cpu"}, mockRenderer{Nodes: report.Nodes{"container": input}}).Render(report.MakeReport()).Nodes
+	want := report.MakeSingletonMetric(now, 15).WithMax(400)
+	if metric := have["container"].Metrics["processes_cpu"]; !reflect.DeepEqual(want, metric) {
+		t.Errorf("%s", test.Diff(want, metric))
+	}
+	if _, ok := have["container"].Metrics["cpu"]; ok {
+		t.Errorf("expected only the summed metric to be added")
+	}
+}
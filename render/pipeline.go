@@ -0,0 +1,41 @@
+package render
+
+import (
+	"github.com/weaveworks/scope/report"
+)
+
+// GroupBy is a Renderer which groups the nodes of another by the value
+// of a metadata key, as ProcessNameRenderer groups processes by name.
+// Nodes without the key are dropped, and pseudo nodes passed through.
+func GroupBy(key string, r Renderer) Renderer {
+	return groupBy{Renderer: r, key: key}
+}
+
+type groupBy struct {
+	Renderer
+	key string
+}
+
+func (g groupBy) Render(rpt report.Report) Nodes {
+	input := g.Renderer.Render(rpt)
+	ret := newJoinResults(nil)
+	for _, n := range input.Nodes {
+		if n.Topology == Pseudo {
+			ret.passThrough(n)
+		} else if value, ok := n.Latest.Lookup(g.key); ok && value != "" {
+			ret.addChildAndChildren(n, value, MakeGroupNodeTopology(n.Topology, g.key))
+		}
+	}
+	return ret.result(input)
+}
+
+// MapToParents is a Renderer which maps the nodes of another to their
+// parents in the given topologies, as the Kubernetes renderers map pods
+// to their services and controllers. Nodes without parents are dropped.
+func MapToParents(topologies []string, r Renderer) Renderer {
+	selectors := make([]Renderer, len(topologies))
+	for i, topology := range topologies {
+		selectors[i] = TopologySelector(topology)
+	}
+	return MakeReduce(append(selectors, Map2Parent{chainRenderer: r, topologies: topologies})...)
+}
@@ -29,6 +29,7 @@ func renderKubernetesTopologies(rpt report.Report) bool {
 		&rpt.PersistentVolume,
 		&rpt.PersistentVolumeClaim,
 		&rpt.StorageClass,
+		&rpt.CustomResource,
 	}
 	for _, t := range topologies {
 		if len(t.Nodes) > 0 {
@@ -45,7 +46,7 @@ func isPauseContainer(n report.Node) bool {
 
 // PodRenderer is a Renderer which produces a renderable kubernetes
 // graph by merging the container graph and the pods topology.
-var PodRenderer = Memoise(ConditionalRenderer(renderKubernetesTopologies,
+var PodRenderer = Memoise(CollapseAliases(ConditionalRenderer(renderKubernetesTopologies,
 	MakeFilter(
 		func(n report.Node) bool {
 			state, ok := n.Latest.Lookup(kubernetes.State)
@@ -66,7 +67,15 @@ var PodRenderer = Memoise(ConditionalRenderer(renderKubernetesTopologies,
 			KubernetesVolumesRenderer,
 		),
 	),
-))
+)))
+
+// CustomResourceRenderer is a Renderer which produces a renderable
+// kubernetes custom resources graph.
+//
+// not memoised
+var CustomResourceRenderer = ConditionalRenderer(renderKubernetesTopologies,
+	SelectCustomResource,
+)
 
 // PodServiceRenderer is a Renderer which produces a renderable kubernetes services
 // graph by merging the pods graph and the services topology.
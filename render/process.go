@@ -6,6 +6,25 @@ import (
 	"github.com/weaveworks/scope/report"
 )
 
+// processMetrics are the metrics of processes rolled up into the
+// containers and hosts they run in.
+var processMetrics = map[string]string{
+	process.CPUUsage:    report.ProcessesCPUUsage,
+	process.MemoryUsage: report.ProcessesMemoryUsage,
+}
+
+// containerProcessMetrics are the metrics of processes rolled up into
+// their containers. Hosts count their sockets themselves, including
+// those belonging to no process.
+var containerProcessMetrics = map[string]string{
+	process.CPUUsage:          report.ProcessesCPUUsage,
+	process.MemoryUsage:       report.ProcessesMemoryUsage,
+	report.SocketsEstablished: report.SocketsEstablished,
+	report.SocketsSynSent:     report.SocketsSynSent,
+	report.SocketsTimeWait:    report.SocketsTimeWait,
+	report.SocketsCloseWait:   report.SocketsCloseWait,
+}
+
 // Constants are used in the tests.
 const (
 	InboundMajor  = "The Internet"
@@ -47,7 +66,7 @@ func (e endpoints2Processes) Render(rpt report.Report) Nodes {
 	if len(rpt.Process.Nodes) == 0 {
 		return Nodes{}
 	}
-	endpoints := SelectEndpoint.Render(rpt).Nodes
+	endpoints := selectOrientedEndpoints{}.Render(rpt).Nodes
 	return MapEndpoints(
 		func(n report.Node) string {
 			pid, ok := n.Latest.Lookup(process.PID)
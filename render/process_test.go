@@ -13,7 +13,7 @@ import (
 
 func TestEndpointRenderer(t *testing.T) {
 	have := utils.Prune(render.EndpointRenderer.Render(fixture.Report).Nodes)
-	want := utils.Prune(expected.RenderedEndpoints)
+	want := utils.Prune(expected.RenderedCollapsedEndpoints)
 	if !reflect.DeepEqual(want, have) {
 		t.Error(test.Diff(want, have))
 	}
@@ -49,7 +49,13 @@ func (ts Transformers) Transform(nodes Nodes) Nodes {
 
 // Render renders the report and then transforms it
 func Render(rpt report.Report, renderer Renderer, transformer Transformer) Nodes {
-	return transformer.Transform(renderer.Render(rpt))
+	nodes := transformer.Transform(renderer.Render(rpt))
+	if invariantViolated != nil {
+		if err := nodes.Invariants(); err != nil {
+			invariantViolated(err)
+		}
+	}
+	return nodes
 }
 
 // Reduce renderer is a Renderer which merges together the output of several
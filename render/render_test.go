@@ -93,3 +93,28 @@ func TestMapRender3(t *testing.T) {
 }
 
 func newu64(value uint64) *uint64 { return &value }
+
+func TestRenderInvariants(t *testing.T) {
+	renderer := mockRenderer{Nodes: report.Nodes{
+		"foo": report.MakeNode("foo").WithAdjacent("bar"),
+		"bar": report.MakeNode("bar").WithAdjacent("baz"),
+		"qux": report.MakeNode("quux"),
+	}}
+	var violations []error
+	render.CheckInvariants(func(err error) { violations = append(violations, err) })
+	defer render.CheckInvariants(nil)
+
+	render.Render(report.MakeReport(), renderer, render.Transformers{})
+	want := `2 error(s): edge "bar" -> "baz" to a node not rendered; node "quux" under ID "qux"`
+	if len(violations) != 1 || violations[0].Error() != want {
+		t.Errorf("want %q, have %v", want, violations)
+	}
+
+	violations = nil
+	delete(renderer.Nodes, "qux")
+	renderer.Nodes["baz"] = report.MakeNode("baz")
+	render.Render(report.MakeReport(), renderer, render.Transformers{})
+	if len(violations) != 0 {
+		t.Errorf("expected no violations, have %v", violations)
+	}
+}
@@ -35,4 +35,8 @@ var (
 	SelectPersistentVolume      = TopologySelector(report.PersistentVolume)
 	SelectPersistentVolumeClaim = TopologySelector(report.PersistentVolumeClaim)
 	SelectStorageClass          = TopologySelector(report.StorageClass)
+	SelectNetworkInterface      = TopologySelector(report.NetworkInterface)
+	SelectCustomResource        = TopologySelector(report.CustomResource)
+	SelectNetworkDevice         = TopologySelector(report.NetworkDevice)
+	SelectDockerNetwork         = TopologySelector(report.DockerNetwork)
 )
@@ -0,0 +1,12 @@
+package render
+
+// ServiceMapRenderer is a Renderer which produces the services of every
+// orchestrator, Kubernetes, ECS and Swarm, as one graph. The
+// connections of their replicas add up to edges between the services.
+//
+// not memoised
+var ServiceMapRenderer = MakeReduce(
+	PodServiceRenderer,
+	ECSServiceRenderer,
+	SwarmServiceRenderer,
+)
@@ -0,0 +1,52 @@
+package render
+
+import (
+	"github.com/weaveworks/scope/report"
+)
+
+// Viewport keeps the nodes within Hops edges of the node Focus, following
+// edges in either direction, so that clients of giant views can render
+// only the neighbourhood of the node they look at. With no focus, or a
+// focus not rendered, every node is kept.
+type Viewport struct {
+	Focus string
+	Hops  int
+}
+
+// Transform implements Transformer.
+func (v Viewport) Transform(nodes Nodes) Nodes {
+	if _, ok := nodes.Nodes[v.Focus]; !ok {
+		return nodes
+	}
+
+	// Index incoming edges, for walking against them
+	neighbours := map[string][]string{}
+	for srcID, n := range nodes.Nodes {
+		for _, dstID := range n.Adjacency {
+			neighbours[srcID] = append(neighbours[srcID], dstID)
+			neighbours[dstID] = append(neighbours[dstID], srcID)
+		}
+	}
+
+	keep := map[string]struct{}{v.Focus: {}}
+	frontier := []string{v.Focus}
+	for hop := 0; hop < v.Hops && len(frontier) > 0; hop++ {
+		next := []string{}
+		for _, id := range frontier {
+			for _, neighbourID := range neighbours[id] {
+				if _, ok := keep[neighbourID]; ok {
+					continue
+				}
+				if _, ok := nodes.Nodes[neighbourID]; ok {
+					keep[neighbourID] = struct{}{}
+					next = append(next, neighbourID)
+				}
+			}
+		}
+		frontier = next
+	}
+	return FilterFunc(func(n report.Node) bool {
+		_, ok := keep[n.ID]
+		return ok
+	}).Transform(nodes)
+}
@@ -0,0 +1,48 @@
+package render_test
+
+import (
+	"testing"
+
+	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+func TestViewport(t *testing.T) {
+	// a -> b -> c -> d, and e connecting to b
+	nodes := render.Nodes{Nodes: report.Nodes{
+		"a": report.MakeNode("a").WithAdjacent("b"),
+		"b": report.MakeNode("b").WithAdjacent("c"),
+		"c": report.MakeNode("c").WithAdjacent("d"),
+		"d": report.MakeNode("d"),
+		"e": report.MakeNode("e").WithAdjacent("b"),
+	}}
+	for _, c := range []struct {
+		viewport render.Viewport
+		want     report.IDList
+	}{
+		{render.Viewport{}, report.MakeIDList("a", "b", "c", "d", "e")},
+		{render.Viewport{Focus: "nonesuch", Hops: 1}, report.MakeIDList("a", "b", "c", "d", "e")},
+		{render.Viewport{Focus: "b", Hops: 0}, report.MakeIDList("b")},
+		{render.Viewport{Focus: "b", Hops: 1}, report.MakeIDList("a", "b", "c", "e")},
+		{render.Viewport{Focus: "a", Hops: 2}, report.MakeIDList("a", "b", "c", "e")},
+	} {
+		result := c.viewport.Transform(nodes)
+		have := report.MakeIDList()
+		for id := range result.Nodes {
+			have = have.Add(id)
+		}
+		if !reflect.DeepEqual(c.want, have) {
+			t.Errorf("%v: %s", c.viewport, test.Diff(c.want, have))
+		}
+		if result.Filtered != 5-len(have) {
+			t.Errorf("%v: expected %d nodes filtered, got %d", c.viewport, 5-len(have), result.Filtered)
+		}
+	}
+
+	// Edges to nodes outside the viewport are cut
+	if adjacency := (render.Viewport{Focus: "b", Hops: 0}).Transform(nodes).Nodes["b"].Adjacency; len(adjacency) != 0 {
+		t.Errorf("expected no edges out of the viewport, got %v", adjacency)
+	}
+}
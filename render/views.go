@@ -0,0 +1,67 @@
+package render
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// views are the built-in views of reports, by the IDs the app serves them
+// under, so that other programs can render reports as the app does,
+// without going through its API.
+var views = map[string]Renderer{
+	"processes":                     ConnectedProcessRenderer,
+	"processes-by-name":             ProcessNameRenderer,
+	"containers":                    ContainerWithImageNameRenderer,
+	"containers-by-hostname":        ContainerHostnameRenderer,
+	"containers-by-image":           ContainerImageRenderer,
+	"containers-by-compose-project": ComposeProjectRenderer,
+	"docker-networks":               DockerNetworkRenderer,
+	"pods":                          PodRenderer,
+	"kube-controllers":              KubeControllerRenderer,
+	"services":                      PodServiceRenderer,
+	"custom-resources":              CustomResourceRenderer,
+	"ecs-tasks":                     ECSTaskRenderer,
+	"ecs-services":                  ECSServiceRenderer,
+	"swarm-services":                SwarmServiceRenderer,
+	"service-map":                   ServiceMapRenderer,
+	"hosts":                         HostRenderer,
+	"weave":                         WeaveRenderer,
+	"hosts-by-cluster":              HostClusterRenderer,
+	"interfaces":                    NetworkInterfaceRenderer,
+	"network-devices":               NetworkDeviceRenderer,
+}
+
+// ViewIDs returns the IDs of the built-in views, sorted.
+func ViewIDs() []string {
+	ids := make([]string, 0, len(views))
+	for id := range views {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// View returns the renderer of the built-in view with the ID.
+func View(id string) (Renderer, bool) {
+	r, ok := views[id]
+	return r, ok
+}
+
+// RenderView renders the built-in view of the report with the ID, keeping
+// only the nodes all the filters match, if any, and removing unconnected
+// pseudo nodes, as the app does. Pseudo nodes are kept regardless of the
+// filters. The nodes can then be summarised with the detailed package.
+func RenderView(rpt report.Report, id string, filters ...FilterFunc) (Nodes, error) {
+	r, ok := View(id)
+	if !ok {
+		return Nodes{}, fmt.Errorf("no such view: %s", id)
+	}
+	transformers := Transformers{}
+	if len(filters) > 0 {
+		transformers = append(transformers, AnyFilterFunc(IsPseudoTopology, ComposeFilterFuncs(filters...)))
+	}
+	transformers = append(transformers, FilterUnconnectedPseudo)
+	return Render(rpt, r, transformers), nil
+}
@@ -0,0 +1,36 @@
+package render_test
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/fixture"
+)
+
+func TestRenderView(t *testing.T) {
+	if _, err := render.RenderView(fixture.Report, "nonesuch"); err == nil {
+		t.Error("expected unknown views to be rejected")
+	}
+
+	have, err := render.RenderView(fixture.Report, "containers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := render.Render(fixture.Report, render.ContainerWithImageNameRenderer, render.FilterUnconnectedPseudo)
+	if len(have.Nodes) == 0 || len(have.Nodes) != len(want.Nodes) {
+		t.Errorf("expected the nodes the app renders, have %v", have.Nodes)
+	}
+
+	// Filters don't remove pseudo nodes
+	none := func(report.Node) bool { return false }
+	filtered, _ := render.RenderView(fixture.Report, "containers", none)
+	for id, n := range filtered.Nodes {
+		if n.Topology != render.Pseudo {
+			t.Errorf("expected %s to be filtered", id)
+		}
+	}
+	if filtered.Filtered == 0 {
+		t.Error("expected the filtered nodes to be counted")
+	}
+}
@@ -0,0 +1,72 @@
+package render
+
+import (
+	"strings"
+
+	"github.com/weaveworks/scope/report"
+)
+
+// resolveVIPs attributes the edges to the endpoints of virtual IPs, such
+// as the cluster IPs of services balanced by IPVS, to the backends they
+// balance between. Connections are attributed to the backend the IPVS
+// connection table says they went to, or, if it doesn't have them, to
+// all of the backends. Connections which conntrack saw rewritten are
+// already attributed to the real backend.
+func resolveVIPs(endpoints Nodes) Nodes {
+	var (
+		backends = map[string][]string{}            // by virtual IP endpoint
+		conns    = map[string]map[string][]string{} // backends by client, by virtual IP endpoint
+	)
+	for id, n := range endpoints.Nodes {
+		set, ok := n.Sets.Lookup(report.VIPBackends)
+		if !ok || len(set) == 0 {
+			continue
+		}
+		backends[id] = set
+		set, _ = n.Sets.Lookup(report.VIPConnections)
+		for _, conn := range set {
+			parts := strings.SplitN(conn, report.EdgeDelim, 2)
+			if len(parts) != 2 {
+				continue
+			}
+			if conns[id] == nil {
+				conns[id] = map[string][]string{}
+			}
+			conns[id][parts[0]] = append(conns[id][parts[0]], parts[1])
+		}
+	}
+	if len(backends) == 0 {
+		return endpoints
+	}
+
+	result := make(report.Nodes, len(endpoints.Nodes))
+	for id, n := range endpoints.Nodes {
+		result[id] = n
+	}
+	for id, n := range endpoints.Nodes {
+		var resolved bool
+		adjacency := make([]string, 0, len(n.Adjacency))
+		for _, dst := range n.Adjacency {
+			if b, ok := backends[dst]; ok {
+				if c, ok := conns[dst][id]; ok {
+					b = c
+				}
+				adjacency = append(adjacency, b...)
+				resolved = true
+			} else {
+				adjacency = append(adjacency, dst)
+			}
+		}
+		if !resolved {
+			continue
+		}
+		n.Adjacency = report.MakeIDList(adjacency...)
+		result[id] = n
+		for _, dst := range n.Adjacency {
+			if _, ok := result[dst]; !ok {
+				result[dst] = report.MakeNode(dst).WithTopology(report.Endpoint)
+			}
+		}
+	}
+	return Nodes{Nodes: result, Filtered: endpoints.Filtered}
+}
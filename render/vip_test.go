@@ -0,0 +1,56 @@
+package render_test
+
+import (
+	"testing"
+
+	"github.com/weaveworks/scope/render"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+func TestResolveVIPs(t *testing.T) {
+	var (
+		client   = report.MakeEndpointNodeID("", "", "10.0.0.1", "40001")
+		vip      = report.MakeEndpointNodeID("", "", "10.96.0.1", "443")
+		backend1 = report.MakeEndpointNodeID("", "", "172.17.0.2", "6443")
+		backend2 = report.MakeEndpointNodeID("", "", "172.17.0.3", "6443")
+		rpt      = report.MakeReport()
+	)
+	rpt.Endpoint.AddNode(report.MakeNode(client).WithAdjacent(vip))
+	rpt.Endpoint.AddNode(report.MakeNode(vip).WithSet(report.VIPBackends, report.MakeStringSet(backend1, backend2)))
+	rpt.Endpoint.AddNode(report.MakeNode(backend1))
+
+	have := render.EndpointRenderer.Render(rpt).Nodes
+	if want := report.MakeIDList(backend1, backend2); !reflect.DeepEqual(want, have[client].Adjacency) {
+		t.Errorf("want %v, have %v", want, have[client].Adjacency)
+	}
+	if _, ok := have[backend2]; !ok {
+		t.Errorf("expected an endpoint for the backend not in the report, have %v", have)
+	}
+}
+
+func TestResolveVIPsOfConnections(t *testing.T) {
+	var (
+		client   = report.MakeEndpointNodeID("", "", "10.0.0.1", "40001")
+		other    = report.MakeEndpointNodeID("", "", "10.0.0.1", "40002")
+		vip      = report.MakeEndpointNodeID("", "", "10.96.0.1", "443")
+		backend1 = report.MakeEndpointNodeID("", "", "172.17.0.2", "6443")
+		backend2 = report.MakeEndpointNodeID("", "", "172.17.0.3", "6443")
+		rpt      = report.MakeReport()
+	)
+	rpt.Endpoint.AddNode(report.MakeNode(client).WithAdjacent(vip))
+	rpt.Endpoint.AddNode(report.MakeNode(other).WithAdjacent(vip))
+	rpt.Endpoint.AddNode(report.MakeNode(vip).
+		WithSet(report.VIPBackends, report.MakeStringSet(backend1, backend2)).
+		WithSet(report.VIPConnections, report.MakeStringSet(client+report.EdgeDelim+backend2)))
+
+	// The connection IPVS has is attributed only to the backend it went
+	// to, and the one it doesn't have still to all of them
+	have := render.EndpointRenderer.Render(rpt).Nodes
+	if want := report.MakeIDList(backend2); !reflect.DeepEqual(want, have[client].Adjacency) {
+		t.Errorf("want %v, have %v", want, have[client].Adjacency)
+	}
+	if want := report.MakeIDList(backend1, backend2); !reflect.DeepEqual(want, have[other].Adjacency) {
+		t.Errorf("want %v, have %v", want, have[other].Adjacency)
+	}
+}
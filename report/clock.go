@@ -0,0 +1,37 @@
+package report
+
+import (
+	"time"
+)
+
+// ShiftTimestamps adds the offset to the timestamps of the report: of
+// the report itself, and of the latest values and metric samples of its
+// nodes. It's used to correct the skew of the clocks of probes. It
+// modifies the report in place, so the report must not be shared.
+func (r *Report) ShiftTimestamps(offset time.Duration) {
+	if offset == 0 {
+		return
+	}
+	if !r.Timestamp.IsZero() {
+		r.Timestamp = r.Timestamp.Add(offset)
+	}
+	r.WalkTopologies(func(t *Topology) {
+		for id, n := range t.Nodes {
+			for i := range n.Latest {
+				n.Latest[i].Timestamp = n.Latest[i].Timestamp.Add(offset)
+			}
+			for i := range n.LatestControls {
+				n.LatestControls[i].Timestamp = n.LatestControls[i].Timestamp.Add(offset)
+			}
+			for key, m := range n.Metrics {
+				samples := make([]Sample, len(m.Samples))
+				for i, s := range m.Samples {
+					samples[i] = Sample{Timestamp: s.Timestamp.Add(offset), Value: s.Value}
+				}
+				m.Samples = samples
+				n.Metrics[key] = m
+			}
+			t.Nodes[id] = n
+		}
+	})
+}
@@ -0,0 +1,34 @@
+package report_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/weaveworks/scope/report"
+)
+
+func TestShiftTimestamps(t *testing.T) {
+	var (
+		now    = time.Now()
+		offset = 5 * time.Second
+		rpt    = report.MakeReport()
+	)
+	rpt.Timestamp = now
+	rpt.Host.AddNode(report.MakeNode("host").
+		WithLatest("foo", now, "bar").
+		WithMetrics(report.Metrics{"load": report.MakeSingletonMetric(now, 1)}))
+
+	rpt.ShiftTimestamps(offset)
+
+	want := now.Add(offset)
+	if !rpt.Timestamp.Equal(want) {
+		t.Errorf("want report timestamp %v, have %v", want, rpt.Timestamp)
+	}
+	node := rpt.Host.Nodes["host"]
+	if _, ts, _ := node.Latest.LookupEntry("foo"); !ts.Equal(want) {
+		t.Errorf("want latest timestamp %v, have %v", want, ts)
+	}
+	if s, _ := node.Metrics["load"].LastSample(); !s.Timestamp.Equal(want) {
+		t.Errorf("want sample timestamp %v, have %v", want, s.Timestamp)
+	}
+}
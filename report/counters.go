@@ -53,6 +53,16 @@ func (c Counters) Size() int {
 	return c.psMap.Size()
 }
 
+// ForEach executes f on each key value pair in the Counters, in no
+// particular order.
+func (c Counters) ForEach(f func(key string, value int)) {
+	if c.psMap != nil {
+		c.psMap.ForEach(func(key string, value interface{}) {
+			f(key, value.(int))
+		})
+	}
+}
+
 // Merge produces a fresh Counters, container the keys from both inputs. When
 // both inputs container the same key, the latter value is used.
 func (c Counters) Merge(other Counters) Counters {
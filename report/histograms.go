@@ -0,0 +1,156 @@
+package report
+
+import (
+	"sort"
+)
+
+// Histograms is a string->histogram map.
+type Histograms map[string]Histogram
+
+// Lookup the histogram for the given key
+func (h Histograms) Lookup(key string) (Histogram, bool) {
+	v, ok := h[key]
+	return v, ok
+}
+
+// Merge merges two histograms maps into a fresh one, summing the buckets
+// of the histograms of both.
+func (h Histograms) Merge(other Histograms) Histograms {
+	if len(other) > len(h) {
+		h, other = other, h
+	}
+	if len(other) == 0 {
+		return h
+	}
+	result := h.Copy()
+	for k, v := range other {
+		if rv, ok := result[k]; ok {
+			result[k] = rv.Merge(v)
+		} else {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// Copy returns a value copy of the histograms map.
+func (h Histograms) Copy() Histograms {
+	result := make(Histograms, len(h))
+	for k, v := range h {
+		result[k] = v
+	}
+	return result
+}
+
+// Histogram is a distribution of observations, e.g. of the latencies of
+// requests, counted in buckets by their upper bounds: Counts[i] are the
+// observations up to Bounds[i], and above the bound before, and the last
+// of the counts those above every bound. Histograms are immutable.
+type Histogram struct {
+	Bounds []float64 `json:"bounds"`
+	Counts []int     `json:"counts"`
+	Sum    float64   `json:"sum"`
+}
+
+// MakeHistogram makes a histogram with no observations, of buckets with
+// the given upper bounds.
+func MakeHistogram(bounds ...float64) Histogram {
+	bounds = append([]float64{}, bounds...)
+	sort.Float64s(bounds)
+	return Histogram{
+		Bounds: bounds,
+		Counts: make([]int, len(bounds)+1),
+	}
+}
+
+// Observe returns a fresh copy of h, with the values counted in their
+// buckets.
+func (h Histogram) Observe(values ...float64) Histogram {
+	result := h.Copy()
+	for _, v := range values {
+		result.Counts[sort.SearchFloat64s(result.Bounds, v)]++
+		result.Sum += v
+	}
+	return result
+}
+
+// Count is the number of observations of the histogram.
+func (h Histogram) Count() int {
+	count := 0
+	for _, c := range h.Counts {
+		count += c
+	}
+	return count
+}
+
+// Copy returns a value copy of the histogram.
+func (h Histogram) Copy() Histogram {
+	counts := make([]int, len(h.Bounds)+1)
+	copy(counts, h.Counts)
+	return Histogram{
+		Bounds: append([]float64{}, h.Bounds...),
+		Counts: counts,
+		Sum:    h.Sum,
+	}
+}
+
+// Merge combines the two histograms, summing their buckets. Histograms of
+// different bounds are merged into buckets of the bounds of both, the
+// observations of each bucket being counted in that of its upper bound.
+func (h Histogram) Merge(other Histogram) Histogram {
+	switch {
+	case h.Count() == 0 && h.Sum == 0:
+		return other
+	case other.Count() == 0 && other.Sum == 0:
+		return h
+	}
+	bounds := h.Bounds
+	if !equalBounds(h.Bounds, other.Bounds) {
+		bounds = unionBounds(h.Bounds, other.Bounds)
+	}
+	result := Histogram{
+		Bounds: bounds,
+		Counts: make([]int, len(bounds)+1),
+		Sum:    h.Sum + other.Sum,
+	}
+	result.add(h)
+	result.add(other)
+	return result
+}
+
+// add sums the counts of other into those of the same upper bound of h,
+// whose bounds have to include those of other.
+func (h Histogram) add(other Histogram) {
+	for i, c := range other.Counts {
+		j := len(h.Bounds)
+		if i < len(other.Bounds) {
+			j = sort.SearchFloat64s(h.Bounds, other.Bounds[i])
+		}
+		h.Counts[j] += c
+	}
+}
+
+func equalBounds(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// unionBounds returns the sorted bounds of both, without repeats.
+func unionBounds(a, b []float64) []float64 {
+	bounds := append(append([]float64{}, a...), b...)
+	sort.Float64s(bounds)
+	result := bounds[:0]
+	for i, b := range bounds {
+		if i == 0 || b != bounds[i-1] {
+			result = append(result, b)
+		}
+	}
+	return result
+}
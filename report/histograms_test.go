@@ -0,0 +1,94 @@
+package report_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ugorji/go/codec"
+
+	"github.com/weaveworks/common/test"
+	"github.com/weaveworks/scope/report"
+	"github.com/weaveworks/scope/test/reflect"
+)
+
+func TestHistogramObserve(t *testing.T) {
+	h := report.MakeHistogram(100, 10, 50)
+	have := h.Observe(5, 10, 20, 75, 500)
+	want := report.Histogram{
+		Bounds: []float64{10, 50, 100},
+		Counts: []int{2, 1, 1, 1},
+		Sum:    610,
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+	if have.Count() != 5 {
+		t.Errorf("want 5 observations, have %d", have.Count())
+	}
+	if h.Count() != 0 {
+		t.Error("expected observing to leave the histogram unchanged")
+	}
+}
+
+func TestHistogramsMerge(t *testing.T) {
+	histograms1 := report.Histograms{
+		"latency":  report.MakeHistogram(10, 100).Observe(1, 20),
+		"duration": report.MakeHistogram(1).Observe(2),
+	}
+	histograms2 := report.Histograms{
+		"latency": report.MakeHistogram(10, 100).Observe(200),
+		"size":    report.MakeHistogram(1).Observe(0),
+	}
+	want := report.Histograms{
+		"latency":  report.MakeHistogram(10, 100).Observe(1, 20, 200),
+		"duration": report.MakeHistogram(1).Observe(2),
+		"size":     report.MakeHistogram(1).Observe(0),
+	}
+	if have := histograms1.Merge(histograms2); !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+	if have := histograms2.Merge(histograms1); !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+	if len(histograms1) != 2 || histograms1["latency"].Count() != 2 {
+		t.Error("expected merging to leave the histograms unchanged")
+	}
+}
+
+func TestHistogramMergeBounds(t *testing.T) {
+	h1 := report.MakeHistogram(10, 100).Observe(5, 50, 500)
+	h2 := report.MakeHistogram(50, 100).Observe(20, 60)
+	// The observation of h1 up to 100 is counted up to 100, though it
+	// is of 50 and under.
+	want := report.Histogram{
+		Bounds: []float64{10, 50, 100},
+		Counts: []int{1, 1, 2, 1},
+		Sum:    635,
+	}
+	if have := h1.Merge(h2); !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+	if have := h2.Merge(h1); !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+}
+
+func TestHistogramRoundtrip(t *testing.T) {
+	want := report.MakeNode("foo").WithHistogram("latency", report.MakeHistogram(10, 100).Observe(1, 20))
+	for _, h := range []codec.Handle{
+		codec.Handle(&codec.MsgpackHandle{}),
+		codec.Handle(&codec.JsonHandle{}),
+	} {
+		buf := &bytes.Buffer{}
+		if err := codec.NewEncoder(buf, h).Encode(want); err != nil {
+			t.Fatal(err)
+		}
+		var have report.Node
+		if err := codec.NewDecoder(buf, h).Decode(&have); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(want.Histograms, have.Histograms) {
+			t.Error(test.Diff(want.Histograms, have.Histograms))
+		}
+	}
+}
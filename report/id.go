@@ -25,6 +25,11 @@ const (
 
 	// DockerOverlayPeerPrefix is the prefix for docker peers in the overlay network
 	DockerOverlayPeerPrefix = "docker_peer_"
+
+	// UDPPortSuffix follows the port of UDP endpoint node IDs, telling
+	// them apart from the TCP endpoints on the same address and port,
+	// whose IDs have no suffix.
+	UDPPortSuffix = "/udp"
 )
 
 // MakeEndpointNodeID produces an endpoint node ID from its composite parts.
@@ -32,6 +37,17 @@ func MakeEndpointNodeID(hostID, namespaceID, address, port string) string {
 	return makeAddressID(hostID, namespaceID, address) + ScopeDelim + port
 }
 
+// MakeUDPEndpointNodeID is like MakeEndpointNodeID, for UDP endpoints.
+func MakeUDPEndpointNodeID(hostID, namespaceID, address, port string) string {
+	return MakeEndpointNodeID(hostID, namespaceID, address, port+UDPPortSuffix)
+}
+
+// IsUDPEndpointNodeID tells whether an endpoint node ID is of a UDP
+// endpoint.
+func IsUDPEndpointNodeID(endpointNodeID string) bool {
+	return strings.HasSuffix(endpointNodeID, UDPPortSuffix)
+}
+
 // MakeAddressNodeID produces an address node ID from its composite parts.
 func MakeAddressNodeID(hostID, address string) string {
 	return makeAddressID(hostID, "", address)
@@ -74,6 +90,11 @@ func MakeProcessNodeID(hostID, pid string) string {
 	return hostID + ScopeDelim + pid
 }
 
+// MakeNetworkInterfaceNodeID produces a network interface node ID from its composite parts.
+func MakeNetworkInterfaceNodeID(hostID, name string) string {
+	return hostID + ScopeDelim + name
+}
+
 // MakeECSServiceNodeID produces an ECS Service node ID from its composite parts.
 func MakeECSServiceNodeID(cluster, serviceName string) string {
 	return cluster + ScopeDelim + serviceName
@@ -175,6 +196,30 @@ var (
 
 	// ParseStorageClassNodeID parses a storage class node ID
 	ParseStorageClassNodeID = parseSingleComponentID("storage_class")
+
+	// MakeCustomResourceNodeID produces a custom resource node ID from its composite parts.
+	MakeCustomResourceNodeID = makeSingleComponentID("custom_resource")
+
+	// ParseCustomResourceNodeID parses a custom resource node ID
+	ParseCustomResourceNodeID = parseSingleComponentID("custom_resource")
+
+	// MakeNetworkDeviceNodeID produces a network device node ID from its composite parts.
+	MakeNetworkDeviceNodeID = makeSingleComponentID("network_device")
+
+	// ParseNetworkDeviceNodeID parses a network device node ID
+	ParseNetworkDeviceNodeID = parseSingleComponentID("network_device")
+
+	// MakeDockerNetworkNodeID produces a Docker network node ID from its composite parts.
+	MakeDockerNetworkNodeID = makeSingleComponentID("docker_network")
+
+	// ParseDockerNetworkNodeID parses a Docker network node ID
+	ParseDockerNetworkNodeID = parseSingleComponentID("docker_network")
+
+	// MakeDockerVolumeNodeID produces a Docker volume node ID from its composite parts.
+	MakeDockerVolumeNodeID = makeSingleComponentID("docker_volume")
+
+	// ParseDockerVolumeNodeID parses a Docker volume node ID
+	ParseDockerVolumeNodeID = parseSingleComponentID("docker_volume")
 )
 
 // makeSingleComponentID makes a single-component node id encoder
@@ -234,7 +279,8 @@ func ParseNodeID(nodeID string) (id string, tag string, ok bool) {
 }
 
 // ParseEndpointNodeID produces the scope, address, and port and remainder.
-// Note that scope may be blank.
+// Note that scope may be blank, and that the port of UDP endpoints is
+// returned without its suffix.
 func ParseEndpointNodeID(endpointNodeID string) (scope, address, port string, ok bool) {
 	// Not using strings.SplitN() to avoid a heap allocation
 	first := strings.Index(endpointNodeID, ScopeDelim)
@@ -245,7 +291,8 @@ func ParseEndpointNodeID(endpointNodeID string) (scope, address, port string, ok
 	if second == -1 {
 		return "", "", "", false
 	}
-	return endpointNodeID[:first], endpointNodeID[first+1 : first+1+second], endpointNodeID[first+1+second+1:], true
+	port = strings.TrimSuffix(endpointNodeID[first+1+second+1:], UDPPortSuffix)
+	return endpointNodeID[:first], endpointNodeID[first+1 : first+1+second], port, true
 }
 
 // ParseAddressNodeID produces the host ID, address from an address node ID.
@@ -258,6 +305,11 @@ func ParseProcessNodeID(processNodeID string) (hostID, pid string, ok bool) {
 	return split2(processNodeID, ScopeDelim)
 }
 
+// ParseNetworkInterfaceNodeID produces the host ID and interface name from a network interface node ID.
+func ParseNetworkInterfaceNodeID(networkInterfaceNodeID string) (hostID, name string, ok bool) {
+	return split2(networkInterfaceNodeID, ScopeDelim)
+}
+
 // ParseECSServiceNodeID produces the cluster, service name from an ECS Service node ID
 func ParseECSServiceNodeID(ecsServiceNodeID string) (cluster, serviceName string, ok bool) {
 	cluster, serviceName, ok = split2(ecsServiceNodeID, ScopeDelim)
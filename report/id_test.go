@@ -52,6 +52,7 @@ func TestEndpointNodeID(t *testing.T) {
 	for input, want := range map[string]struct{ name, address, port string }{
 		report.MakeEndpointNodeID("host.com", "namespaceid", "127.0.0.1", "c"): {"host.com-namespaceid", "127.0.0.1", "c"},
 		report.MakeEndpointNodeID("host.com", "", "1.2.3.4", "c"):              {"", "1.2.3.4", "c"},
+		report.MakeUDPEndpointNodeID("host.com", "", "1.2.3.4", "53"):          {"", "1.2.3.4", "53"},
 		"a;b;c": {"a", "b", "c"},
 	} {
 		haveName, haveAddress, havePort, ok := report.ParseEndpointNodeID(input)
@@ -67,6 +68,19 @@ func TestEndpointNodeID(t *testing.T) {
 	}
 }
 
+func TestUDPEndpointNodeID(t *testing.T) {
+	var (
+		tcp = report.MakeEndpointNodeID("host.com", "", "1.2.3.4", "53")
+		udp = report.MakeUDPEndpointNodeID("host.com", "", "1.2.3.4", "53")
+	)
+	if tcp == udp {
+		t.Errorf("expected the UDP endpoint to differ from the TCP one, both are %q", tcp)
+	}
+	if report.IsUDPEndpointNodeID(tcp) || !report.IsUDPEndpointNodeID(udp) {
+		t.Errorf("%q and %q: transports not told apart", tcp, udp)
+	}
+}
+
 func TestECSServiceNodeIDCompat(t *testing.T) {
 	testID := "my-service;<ecs_service>"
 	testName := "my-service"
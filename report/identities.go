@@ -0,0 +1,41 @@
+package report
+
+import "strings"
+
+// Schemes of the identities of entities, by which nodes referring to the
+// same entity under different IDs are resolved.
+const (
+	IdentityIP         = "ip"
+	IdentityHostname   = "hostname"
+	IdentityInstanceID = "instance-id"
+	IdentityPodUID     = "pod-uid"
+)
+
+// MakeIdentity makes the identity of an entity under a scheme.
+func MakeIdentity(scheme, value string) string {
+	return scheme + ":" + value
+}
+
+// ParseIdentity returns the scheme and value of an identity.
+func ParseIdentity(identity string) (string, string, bool) {
+	i := strings.Index(identity, ":")
+	if i <= 0 {
+		return "", "", false
+	}
+	return identity[:i], identity[i+1:], true
+}
+
+// WithIdentities returns a fresh copy of n, with the values added to its
+// identities under scheme. Empty values are ignored.
+func (n Node) WithIdentities(scheme string, values ...string) Node {
+	identities := make([]string, 0, len(values))
+	for _, value := range values {
+		if value != "" {
+			identities = append(identities, MakeIdentity(scheme, value))
+		}
+	}
+	if len(identities) == 0 {
+		return n
+	}
+	return n.WithSet(Identities, MakeStringSet(identities...))
+}
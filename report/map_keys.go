@@ -3,15 +3,54 @@ package report
 // node metadata keys
 const (
 	// probe/endpoint
-	ReverseDNSNames = "reverse_dns_names"
-	SnoopedDNSNames = "snooped_dns_names"
-	CopyOf          = "copy_of"
+	ReverseDNSNames    = "reverse_dns_names"
+	SnoopedDNSNames    = "snooped_dns_names"
+	CopyOf             = "copy_of"
+	ConnectionFailures = "connection_failures"
+	Listening          = "listening"
+	// "true" on endpoints known to have initiated their connections, and
+	// "false" on those known to have accepted them
+	Initiator = "initiator"
+	// render, the count of client endpoints an endpoint collapses
+	CollapsedPorts = "collapsed_ports"
+	// probe/endpoint, the endpoints of the backends of virtual IPs
+	VIPBackends = "vip_backends"
+	// probe/endpoint, which backend each connection to a virtual IP went
+	// to, as the endpoints of its client and backend separated by
+	// EdgeDelim
+	VIPConnections = "vip_connections"
+	// probe/endpoint, counts of the connections the eBPF tracker saw come
+	// and go since the previous report
+	ConnectionsOpened = "connections_opened"
+	ConnectionsClosed = "connections_closed"
+	// probe/endpoint, the round trip times of the connections of
+	// endpoints, as probes measuring them observed: their sum and count,
+	// counters adding up as merged, and a metric of their range, of the
+	// min and max
+	LatencySumMicros    = "latency_sum_micros"
+	LatencyObservations = "latency_observations"
+	LatencyMicros       = "latency_micros"
+	// probe/endpoint, counts of TCP sockets by state, of processes and
+	// hosts, and of containers rolled up from their processes
+	SocketsEstablished = "sockets_established"
+	SocketsSynSent     = "sockets_syn_sent"
+	SocketsTimeWait    = "sockets_time_wait"
+	SocketsCloseWait   = "sockets_close_wait"
+	// probe/stableid, identifying nodes across restarts
+	StableID = "stable_id"
+	// the identities of the entities of nodes, under several schemes, and
+	// render, the IDs of the nodes collapsed into one by them
+	Identities = "identities"
+	Aliases    = "aliases"
 	// probe/process
 	PID     = "pid"
 	Name    = "name" // also used by probe/docker
 	PPID    = "ppid"
 	Cmdline = "cmdline"
 	Threads = "threads"
+	// render, rolled up from the processes of a node
+	ProcessesCPUUsage    = "processes_cpu_usage_percent"
+	ProcessesMemoryUsage = "processes_memory_usage_bytes"
 	// probe/docker
 	DockerContainerID            = "docker_container_id"
 	DockerImageID                = "docker_image_id"
@@ -19,6 +58,7 @@ const (
 	DockerImageTag               = "docker_image_tag"
 	DockerImageSize              = "docker_image_size"
 	DockerImageVirtualSize       = "docker_image_virtual_size"
+	DockerImageDigest            = "docker_image_digest"
 	DockerIsInHostNetwork        = "docker_is_in_host_network"
 	DockerServiceName            = "service_name"
 	DockerStackNamespace         = "stack_namespace"
@@ -42,7 +82,17 @@ const (
 	DockerContainerStateHuman    = "docker_container_state_human"
 	DockerContainerUptime        = "docker_container_uptime"
 	DockerContainerRestartCount  = "docker_container_restart_count"
+	DockerContainerRestarts      = "docker_container_restarts"
+	DockerContainerOOMKills      = "docker_container_oom_kills"
 	DockerContainerNetworkMode   = "docker_container_network_mode"
+	DockerNetworkName            = "docker_network_name"
+	DockerNetworkDriver          = "docker_network_driver"
+	DockerNetworkScope           = "docker_network_scope"
+	DockerNetworkInternal        = "docker_network_internal"
+	DockerNetworkSubnets         = "docker_network_subnets"
+	DockerVolumeName             = "docker_volume_name"
+	DockerVolumeDriver           = "docker_volume_driver"
+	DockerVolumeMountpoint       = "docker_volume_mountpoint"
 	// probe/kubernetes
 	KubernetesName                 = "kubernetes_name"
 	KubernetesNamespace            = "kubernetes_namespace"
@@ -81,6 +131,22 @@ const (
 	KubernetesVolumeName           = "kubernetes_volume_name"
 	KubernetesProvisioner          = "kubernetes_provisioner"
 	KubernetesStorageDriver        = "kubernetes_storage_driver"
+	KubernetesCapacity             = "kubernetes_capacity"
+	KubernetesRequestedCapacity    = "kubernetes_requested_capacity"
+	KubernetesAPIVersion           = "kubernetes_api_version"
+	KubernetesCPURequest           = "kubernetes_cpu_request"
+	KubernetesCPULimit             = "kubernetes_cpu_limit"
+	KubernetesMemoryRequest        = "kubernetes_memory_request"
+	KubernetesMemoryLimit          = "kubernetes_memory_limit"
+	KubernetesRolloutStatus        = "kubernetes_rollout_status"
+	KubernetesCluster              = "kubernetes_cluster"
+	KubernetesHPA                  = "kubernetes_hpa"
+	KubernetesHPAMinReplicas       = "kubernetes_hpa_min_replicas"
+	KubernetesHPAMaxReplicas       = "kubernetes_hpa_max_replicas"
+	KubernetesHPACurrentReplicas   = "kubernetes_hpa_current_replicas"
+	KubernetesHPADesiredReplicas   = "kubernetes_hpa_desired_replicas"
+	KubernetesHPATargetCPUPercent  = "kubernetes_hpa_target_cpu_percent"
+	KubernetesHPACurrentCPUPercent = "kubernetes_hpa_current_cpu_percent"
 	// probe/awsecs
 	ECSCluster             = "ecs_cluster"
 	ECSCreatedAt           = "ecs_created_at"
@@ -116,14 +182,39 @@ var commonKeys = map[string]string{
 	PersistentVolume:      PersistentVolume,
 	PersistentVolumeClaim: PersistentVolumeClaim,
 	StorageClass:          StorageClass,
+	NetworkInterface:      NetworkInterface,
+	CustomResource:        CustomResource,
+	NetworkDevice:         NetworkDevice,
+	DockerNetwork:         DockerNetwork,
+	DockerVolume:          DockerVolume,
 
 	HostNodeID:             HostNodeID,
 	ControlProbeID:         ControlProbeID,
+	HostHeartbeat:          HostHeartbeat,
 	DoesNotMakeConnections: DoesNotMakeConnections,
 
-	ReverseDNSNames: ReverseDNSNames,
-	SnoopedDNSNames: SnoopedDNSNames,
-	CopyOf:          CopyOf,
+	ReverseDNSNames:     ReverseDNSNames,
+	SnoopedDNSNames:     SnoopedDNSNames,
+	CopyOf:              CopyOf,
+	ConnectionFailures:  ConnectionFailures,
+	ConnectionsOpened:   ConnectionsOpened,
+	ConnectionsClosed:   ConnectionsClosed,
+	LatencySumMicros:    LatencySumMicros,
+	LatencyObservations: LatencyObservations,
+	LatencyMicros:       LatencyMicros,
+	Listening:           Listening,
+	Initiator:           Initiator,
+	CollapsedPorts:      CollapsedPorts,
+	VIPBackends:         VIPBackends,
+	VIPConnections:      VIPConnections,
+	SocketsEstablished:  SocketsEstablished,
+	SocketsSynSent:      SocketsSynSent,
+	SocketsTimeWait:     SocketsTimeWait,
+	SocketsCloseWait:    SocketsCloseWait,
+
+	StableID:   StableID,
+	Identities: Identities,
+	Aliases:    Aliases,
 
 	PID:     PID,
 	Name:    Name,
@@ -131,12 +222,16 @@ var commonKeys = map[string]string{
 	Cmdline: Cmdline,
 	Threads: Threads,
 
+	ProcessesCPUUsage:    ProcessesCPUUsage,
+	ProcessesMemoryUsage: ProcessesMemoryUsage,
+
 	DockerContainerID:            DockerContainerID,
 	DockerImageID:                DockerImageID,
 	DockerImageName:              DockerImageName,
 	DockerImageTag:               DockerImageTag,
 	DockerImageSize:              DockerImageSize,
 	DockerImageVirtualSize:       DockerImageVirtualSize,
+	DockerImageDigest:            DockerImageDigest,
 	DockerIsInHostNetwork:        DockerIsInHostNetwork,
 	DockerServiceName:            DockerServiceName,
 	DockerStackNamespace:         DockerStackNamespace,
@@ -160,7 +255,17 @@ var commonKeys = map[string]string{
 	DockerContainerStateHuman:    DockerContainerStateHuman,
 	DockerContainerUptime:        DockerContainerUptime,
 	DockerContainerRestartCount:  DockerContainerRestartCount,
+	DockerContainerRestarts:      DockerContainerRestarts,
+	DockerContainerOOMKills:      DockerContainerOOMKills,
 	DockerContainerNetworkMode:   DockerContainerNetworkMode,
+	DockerNetworkName:            DockerNetworkName,
+	DockerNetworkDriver:          DockerNetworkDriver,
+	DockerNetworkScope:           DockerNetworkScope,
+	DockerNetworkInternal:        DockerNetworkInternal,
+	DockerNetworkSubnets:         DockerNetworkSubnets,
+	DockerVolumeName:             DockerVolumeName,
+	DockerVolumeDriver:           DockerVolumeDriver,
+	DockerVolumeMountpoint:       DockerVolumeMountpoint,
 
 	KubernetesName:                 KubernetesName,
 	KubernetesNamespace:            KubernetesNamespace,
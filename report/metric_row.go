@@ -38,6 +38,18 @@ func (m MetricRow) Summary() MetricRow {
 	return m
 }
 
+// Sparkline returns a copy of the MetricRow with only its last n samples,
+// the recent history of the metric to draw a sparkline of.
+func (m MetricRow) Sparkline(n int) MetricRow {
+	if m.Metric.Len() > n {
+		// shallow-copy
+		metricCopy := *m.Metric
+		metricCopy.Samples = metricCopy.Samples[len(metricCopy.Samples)-n:]
+		m.Metric = &metricCopy
+	}
+	return m
+}
+
 // MarshalJSON shouldn't be used, use CodecEncodeSelf instead
 func (MetricRow) MarshalJSON() ([]byte, error) {
 	panic("MarshalJSON shouldn't be used, use CodecEncodeSelf instead")
@@ -1,6 +1,7 @@
 package report
 
 import (
+	"math"
 	"time"
 
 	"github.com/weaveworks/common/mtime"
@@ -18,8 +19,12 @@ type Node struct {
 	LatestControls NodeControlDataLatestMap `json:"latestControls,omitempty"`
 	Latest         StringLatestMap          `json:"latest,omitempty"`
 	Metrics        Metrics                  `json:"metrics,omitempty" deepequal:"nil==empty"`
+	Histograms     Histograms               `json:"histograms,omitempty" deepequal:"nil==empty"`
 	Parents        Sets                     `json:"parents,omitempty"`
 	Children       NodeSet                  `json:"children,omitempty"`
+	// Sources are the sources of the metadata, by key, of nodes described
+	// by several reporters, for merging it by precedence
+	Sources StringLatestMap `json:"sources,omitempty"`
 }
 
 // MakeNode creates a new Node with no initial metadata.
@@ -106,6 +111,34 @@ func (n Node) WithMetric(key string, metric Metric) Node {
 	return n
 }
 
+// WithLatencies returns a fresh copy of n, with round trip times of its
+// connection observed at t added to its latencies: see LatencySumMicros.
+func (n Node) WithLatencies(t time.Time, rtts ...time.Duration) Node {
+	if len(rtts) == 0 {
+		return n
+	}
+	var (
+		sum    = 0
+		last   = float64(rtts[len(rtts)-1] / time.Microsecond)
+		metric = Metric{Samples: []Sample{{Timestamp: t, Value: last}}, Min: last, Max: last}
+	)
+	for _, rtt := range rtts {
+		micros := int(rtt / time.Microsecond)
+		sum += micros
+		metric.Min = math.Min(metric.Min, float64(micros))
+		metric.Max = math.Max(metric.Max, float64(micros))
+	}
+	n.Counters = n.Counters.Add(LatencySumMicros, sum).Add(LatencyObservations, len(rtts))
+	return n.WithMetric(LatencyMicros, metric)
+}
+
+// WithHistogram returns a fresh copy of n, with histogram merged in at key.
+func (n Node) WithHistogram(key string, histogram Histogram) Node {
+	n.Histograms = n.Histograms.Copy()
+	n.Histograms[key] = n.Histograms[key].Merge(histogram)
+	return n
+}
+
 // WithMetrics returns a fresh copy of n, with metrics merged in.
 func (n Node) WithMetrics(metrics Metrics) Node {
 	n.Metrics = n.Metrics.Merge(metrics)
@@ -185,6 +218,7 @@ func (n Node) Merge(other Node) Node {
 	} else if other.Topology != "" && topology != other.Topology {
 		panic("Cannot merge nodes with different topology types: " + topology + " != " + other.Topology)
 	}
+	latest, sources := mergeSources(n, other)
 	return Node{
 		ID:             id,
 		Topology:       topology,
@@ -192,9 +226,11 @@ func (n Node) Merge(other Node) Node {
 		Sets:           n.Sets.Merge(other.Sets),
 		Adjacency:      n.Adjacency.Merge(other.Adjacency),
 		LatestControls: n.LatestControls.Merge(other.LatestControls),
-		Latest:         n.Latest.Merge(other.Latest),
+		Latest:         latest,
 		Metrics:        n.Metrics.Merge(other.Metrics),
+		Histograms:     n.Histograms.Merge(other.Histograms),
 		Parents:        n.Parents.Merge(other.Parents),
 		Children:       n.Children.Merge(other.Children),
+		Sources:        sources,
 	}
 }
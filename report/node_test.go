@@ -33,6 +33,30 @@ func TestWithLatest(t *testing.T) {
 	assert.Equal(t, node3, node4)
 }
 
+func TestWithLatencies(t *testing.T) {
+	now := time.Now()
+	node := report.MakeNode("node").
+		WithLatencies(now, 3*time.Millisecond, time.Millisecond).
+		WithLatencies(now.Add(time.Second), 5*time.Millisecond)
+
+	if sum, _ := node.Counters.Lookup(report.LatencySumMicros); sum != 9000 {
+		t.Errorf("want a sum of 9000us, have %d", sum)
+	}
+	if observations, _ := node.Counters.Lookup(report.LatencyObservations); observations != 3 {
+		t.Errorf("want 3 observations, have %d", observations)
+	}
+	metric, ok := node.Metrics.Lookup(report.LatencyMicros)
+	if !ok {
+		t.Fatal("expected a latency metric")
+	}
+	if metric.Min != 1000 || metric.Max != 5000 || metric.Len() != 2 {
+		t.Errorf("want 2 samples from 1000us to 5000us, have %v", metric)
+	}
+	if node.WithLatencies(now).Counters.Size() != node.Counters.Size() {
+		t.Error("expected no latencies to change nothing")
+	}
+}
+
 func TestMergeNodes(t *testing.T) {
 	mtime.NowForce(time.Now())
 	defer mtime.NowReset()
@@ -176,3 +200,35 @@ func TestMergeNodes(t *testing.T) {
 		}
 	}
 }
+
+func TestMergeNodesBySource(t *testing.T) {
+	var (
+		now    = time.Now()
+		later  = now.Add(time.Second)
+		docker = report.MakeNode("c").
+			WithLatest(Name, later, "k8s_app_pod").
+			WithLatest("uptime", later, "10").
+			WithSource(report.SourceDocker).
+			WithLatest("untagged", now, "docker")
+		cri = report.MakeNode("c").
+			WithLatest(Name, now, "app").
+			WithSource(report.SourceCRI).
+			WithLatest("untagged", later, "cri")
+	)
+	for _, merged := range []report.Node{docker.Merge(cri), cri.Merge(docker)} {
+		// The higher ranked source wins, though older
+		if name, _ := merged.Latest.Lookup(Name); name != "app" {
+			t.Errorf("want the name of the CRI, have %q", name)
+		}
+		if source, _ := merged.Source(Name); source != report.SourceCRI {
+			t.Errorf("want the name from the CRI, have it from %q", source)
+		}
+		// Metadata of one source only, or untagged, is merged as ever
+		if uptime, _ := merged.Latest.Lookup("uptime"); uptime != "10" {
+			t.Errorf("want the uptime of docker, have %q", uptime)
+		}
+		if untagged, _ := merged.Latest.Lookup("untagged"); untagged != "cri" {
+			t.Errorf("want the newer untagged metadata, have %q", untagged)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package report
 import (
 	"fmt"
 	"math/rand"
+	"sort"
 	"strings"
 	"time"
 
@@ -31,6 +32,11 @@ const (
 	PersistentVolume      = "persistent_volume"
 	PersistentVolumeClaim = "persistent_volume_claim"
 	StorageClass          = "storage_class"
+	NetworkInterface      = "network_interface"
+	CustomResource        = "custom_resource"
+	NetworkDevice         = "network_device"
+	DockerNetwork         = "docker_network"
+	DockerVolume          = "docker_volume"
 
 	// Shapes used for different nodes
 	Circle         = "circle"
@@ -71,6 +77,11 @@ var topologyNames = []string{
 	PersistentVolume,
 	PersistentVolumeClaim,
 	StorageClass,
+	NetworkInterface,
+	CustomResource,
+	NetworkDevice,
+	DockerNetwork,
+	DockerVolume,
 }
 
 // Report is the core data type. It's produced by probes, and consumed and
@@ -171,6 +182,30 @@ type Report struct {
 	// Metadata is limited for now, more to come later.
 	StorageClass Topology
 
+	// NetworkInterface nodes are the network interfaces of hosts running
+	// probes. Metadata includes addresses and link speed, and metrics
+	// include traffic and errors. Edges are not present.
+	NetworkInterface Topology
+
+	// CustomResource nodes are the Kubernetes custom resources the probes
+	// are configured to report, e.g. those of operators. Metadata includes
+	// selected spec and status fields. Edges are not present.
+	CustomResource Topology
+
+	// NetworkDevice nodes are the switches and routers polled over SNMP.
+	// Their interfaces are in the NetworkInterface topology. Edges are
+	// not present.
+	NetworkDevice Topology
+
+	// DockerNetwork nodes are the networks of Docker, such as bridges and
+	// overlays. Containers have the networks they are attached to as
+	// parents. Edges are not present.
+	DockerNetwork Topology
+
+	// DockerVolume nodes are the named volumes of Docker. Containers have
+	// the volumes they mount as parents. Edges are not present.
+	DockerVolume Topology
+
 	DNS DNSRecords
 
 	// Sampling data for this report.
@@ -190,6 +225,30 @@ type Report struct {
 
 	Plugins xfer.PluginSpecs
 
+	// Sequence numbers the reports a probe publishes, from 1, so that
+	// apps can drop duplicates and count gaps. It is 0 in reports that
+	// are not published by a probe, such as merged reports.
+	Sequence uint64
+
+	// Run identifies the run of the probe which numbered the report, so
+	// that apps tell a probe restarted under the same ID, numbering its
+	// reports from 1 again, from duplicates. It is 0 from older probes.
+	Run uint64
+
+	// Timestamp is when the probe published the report. Apps order
+	// reports by it rather than by when they arrive.
+	Timestamp time.Time
+
+	// Backfill reports are published late, from a backlog, so their
+	// Timestamp is when they were captured. Apps store them with the
+	// reports of that time rather than with the latest ones.
+	Backfill bool
+
+	// Errors are why topologies of the report are missing or partial, by
+	// topology, e.g. as the reporter of containers failed, so that apps
+	// can tell empty topologies from those that couldn't be reported.
+	Errors map[string]string
+
 	// ID a random identifier for this report, used when caching
 	// rendered views of the report.  Reports with the same id
 	// must be equal, but we don't require that equal reports have
@@ -276,6 +335,26 @@ func MakeReport() Report {
 			WithShape(StorageSheet).
 			WithLabel("storage class", "storage classes"),
 
+		NetworkInterface: MakeTopology().
+			WithShape(Square).
+			WithLabel("interface", "interfaces"),
+
+		CustomResource: MakeTopology().
+			WithShape(Octagon).
+			WithLabel("custom resource", "custom resources"),
+
+		NetworkDevice: MakeTopology().
+			WithShape(Pentagon).
+			WithLabel("network device", "network devices"),
+
+		DockerNetwork: MakeTopology().
+			WithShape(Cloud).
+			WithLabel("network", "networks"),
+
+		DockerVolume: MakeTopology().
+			WithShape(Cylinder).
+			WithLabel("volume", "volumes"),
+
 		DNS: DNSRecords{},
 
 		Sampling: Sampling{},
@@ -288,12 +367,19 @@ func MakeReport() Report {
 // Copy returns a value copy of the report.
 func (r Report) Copy() Report {
 	newReport := Report{
-		DNS:      r.DNS.Copy(),
-		Sampling: r.Sampling,
-		Window:   r.Window,
-		Shortcut: r.Shortcut,
-		Plugins:  r.Plugins.Copy(),
-		ID:       fmt.Sprintf("%d", rand.Int63()),
+		DNS:       r.DNS.Copy(),
+		Sampling:  r.Sampling,
+		Window:    r.Window,
+		Shortcut:  r.Shortcut,
+		Plugins:   r.Plugins.Copy(),
+		Sequence:  r.Sequence,
+		Run:       r.Run,
+		Timestamp: r.Timestamp,
+		Backfill:  r.Backfill,
+		ID:        fmt.Sprintf("%d", rand.Int63()),
+	}
+	for topology, message := range r.Errors {
+		newReport.AddError(topology, message)
 	}
 	newReport.WalkPairedTopologies(&r, func(newTopology, oldTopology *Topology) {
 		*newTopology = oldTopology.Copy()
@@ -315,11 +401,37 @@ func (r *Report) UnsafeMerge(other Report) {
 	r.Sampling = r.Sampling.Merge(other.Sampling)
 	r.Window = r.Window + other.Window
 	r.Plugins = r.Plugins.Merge(other.Plugins)
+	for topology, message := range other.Errors {
+		r.AddError(topology, message)
+	}
 	r.WalkPairedTopologies(&other, func(ourTopology, theirTopology *Topology) {
 		ourTopology.UnsafeMerge(*theirTopology)
 	})
 }
 
+// AddError annotates a topology of the report with why it is missing or
+// partial. Messages of the same topology, e.g. of several probes, are
+// kept sorted and separated by semicolons.
+func (r *Report) AddError(topology, message string) {
+	if r.Errors == nil {
+		r.Errors = map[string]string{}
+	}
+	existing, ok := r.Errors[topology]
+	if !ok {
+		r.Errors[topology] = message
+		return
+	}
+	messages := strings.Split(existing, "; ")
+	for _, m := range messages {
+		if m == message {
+			return
+		}
+	}
+	messages = append(messages, message)
+	sort.Strings(messages)
+	r.Errors[topology] = strings.Join(messages, "; ")
+}
+
 // WalkTopologies iterates through the Topologies of the report,
 // potentially modifying them
 func (r *Report) WalkTopologies(f func(*Topology)) {
@@ -388,6 +500,16 @@ func (r *Report) topology(name string) *Topology {
 		return &r.PersistentVolumeClaim
 	case StorageClass:
 		return &r.StorageClass
+	case NetworkInterface:
+		return &r.NetworkInterface
+	case CustomResource:
+		return &r.CustomResource
+	case NetworkDevice:
+		return &r.NetworkDevice
+	case DockerNetwork:
+		return &r.DockerNetwork
+	case DockerVolume:
+		return &r.DockerVolume
 	}
 	return nil
 }
@@ -544,4 +666,9 @@ const (
 	HostNodeID = "host_node_id"
 	// ControlProbeID is the random ID of the probe which controls the specific node.
 	ControlProbeID = "control_probe_id"
+	// HostHeartbeat is the key the probe of a host stamps its node with on
+	// every publication, even of empty reports, so that apps can tell quiet
+	// hosts from hosts whose probes are gone. Its value is the sequence
+	// number of the report.
+	HostHeartbeat = "host_heartbeat"
 )
@@ -46,6 +46,25 @@ func TestReportTopology(t *testing.T) {
 	}
 }
 
+func TestReportErrors(t *testing.T) {
+	a, b := report.MakeReport(), report.MakeReport()
+	a.AddError(report.Container, "host b: docker: connection refused")
+	b.AddError(report.Container, "host a: docker: connection refused")
+	b.AddError(report.Pod, "host a: kubernetes: forbidden")
+
+	have := a.Merge(b).Merge(b).Errors
+	want := map[string]string{
+		report.Container: "host a: docker: connection refused; host b: docker: connection refused",
+		report.Pod:       "host a: kubernetes: forbidden",
+	}
+	if !reflect.DeepEqual(want, have) {
+		t.Error(test.Diff(want, have))
+	}
+	if len(a.Errors) != 1 {
+		t.Errorf("expected merging not to modify the report, got %v", a.Errors)
+	}
+}
+
 func TestNode(t *testing.T) {
 	{
 		node := report.MakeNodeWith("foo", map[string]string{
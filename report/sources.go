@@ -0,0 +1,71 @@
+package report
+
+import "time"
+
+// Sources of the metadata of nodes described by several reporters.
+const (
+	SourceDocker = "docker"
+	SourceCRI    = "cri"
+)
+
+// SourcePrecedence ranks the sources of metadata. When nodes from
+// different sources are merged, the metadata of the source ranked
+// highest wins, whichever is newer, so that conflicting metadata is
+// resolved deterministically. Sources ranked alike, or not ranked, are
+// ordered by name.
+var SourcePrecedence = map[string]int{
+	SourceCRI:    20,
+	SourceDocker: 10,
+}
+
+// WithSource returns a fresh copy of n, with its metadata tagged as from
+// source.
+func (n Node) WithSource(source string) Node {
+	sources := n.Sources
+	n.Latest.ForEach(func(key string, ts time.Time, _ string) {
+		sources = sources.Set(key, ts, source)
+	})
+	n.Sources = sources
+	return n
+}
+
+// Source returns the source of the metadata of key, if tagged.
+func (n Node) Source(key string) (string, bool) {
+	return n.Sources.Lookup(key)
+}
+
+// precedes is whether the metadata of source a wins over that of b.
+func precedes(a, b string) bool {
+	pa, pb := SourcePrecedence[a], SourcePrecedence[b]
+	if pa != pb {
+		return pa > pb
+	}
+	return a < b
+}
+
+// mergeSources merges the metadata of n and other by precedence, where
+// both tagged it with different sources, and by time otherwise.
+func mergeSources(n, other Node) (StringLatestMap, StringLatestMap) {
+	latest, sources := n.Latest.Merge(other.Latest), n.Sources.Merge(other.Sources)
+	if len(n.Sources) == 0 || len(other.Sources) == 0 {
+		return latest, sources
+	}
+	n.Sources.ForEach(func(key string, _ time.Time, source string) {
+		otherSource, ok := other.Sources.Lookup(key)
+		if !ok || otherSource == source {
+			return
+		}
+		winner := n
+		if precedes(otherSource, source) {
+			winner = other
+		}
+		value, ts, ok := winner.Latest.LookupEntry(key)
+		if !ok {
+			return
+		}
+		winnerSource, _, _ := winner.Sources.LookupEntry(key)
+		latest = latest.Set(key, ts, value)
+		sources = sources.Set(key, ts, winnerSource)
+	})
+	return latest, sources
+}
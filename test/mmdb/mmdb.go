@@ -0,0 +1,115 @@
+// Package mmdb builds MaxMind DBs for tests.
+package mmdb
+
+import (
+	"bytes"
+	"net"
+	"sort"
+)
+
+// encode encodes strings, unsigned integers and maps in the data format
+// of MaxMind DBs.
+func encode(buf *bytes.Buffer, value interface{}) {
+	switch v := value.(type) {
+	case string:
+		if len(v) < 29 {
+			buf.WriteByte(2<<5 | byte(len(v)))
+		} else {
+			buf.Write([]byte{2<<5 | 29, byte(len(v) - 29)})
+		}
+		buf.WriteString(v)
+	case uint:
+		var b []byte
+		for ; v > 0; v >>= 8 {
+			b = append([]byte{byte(v)}, b...)
+		}
+		buf.WriteByte(6<<5 | byte(len(b))) // uint32
+		buf.Write(b)
+	case map[string]interface{}:
+		buf.WriteByte(7<<5 | byte(len(v)))
+		keys := []string{}
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			encode(buf, key)
+			encode(buf, v[key])
+		}
+	}
+}
+
+type treeNode struct {
+	children [2]*treeNode
+	data     []byte
+}
+
+// Build makes a MaxMind DB of IPv4 networks, of an IPv6 search tree
+// with 24 bit records.
+func Build(dbType string, networks map[string]map[string]interface{}) []byte {
+	root := &treeNode{}
+	for cidr, record := range networks {
+		_, network, _ := net.ParseCIDR(cidr)
+		ones, _ := network.Mask.Size()
+		ip := append(make([]byte, 12), network.IP.To4()...)
+		ones += 96
+		data := &bytes.Buffer{}
+		encode(data, record)
+		node := root
+		for i := 0; i < ones; i++ {
+			bit := ip[i/8] >> (7 - uint(i%8)) & 1
+			if i == ones-1 {
+				node.children[bit] = &treeNode{data: data.Bytes()}
+				break
+			}
+			if node.children[bit] == nil {
+				node.children[bit] = &treeNode{}
+			}
+			node = node.children[bit]
+		}
+	}
+
+	// Number the nodes breadth first, and lay out the data
+	var (
+		nodes   = []*treeNode{root}
+		index   = map[*treeNode]int{root: 0}
+		section = &bytes.Buffer{}
+		offsets = map[*treeNode]int{}
+	)
+	for i := 0; i < len(nodes); i++ {
+		for _, child := range nodes[i].children {
+			if child == nil {
+				continue
+			}
+			if child.data != nil {
+				offsets[child] = section.Len()
+				section.Write(child.data)
+				continue
+			}
+			index[child] = len(nodes)
+			nodes = append(nodes, child)
+		}
+	}
+	out := &bytes.Buffer{}
+	for _, node := range nodes {
+		for _, child := range node.children {
+			record := len(nodes)
+			if child != nil && child.data != nil {
+				record = len(nodes) + 16 + offsets[child]
+			} else if child != nil {
+				record = index[child]
+			}
+			out.Write([]byte{byte(record >> 16), byte(record >> 8), byte(record)})
+		}
+	}
+	out.Write(make([]byte, 16))
+	out.Write(section.Bytes())
+	out.WriteString("\xab\xcd\xefMaxMind.com")
+	encode(out, map[string]interface{}{
+		"node_count":    uint(len(nodes)),
+		"record_size":   uint(24),
+		"ip_version":    uint(6),
+		"database_type": dbType,
+	})
+	return out.Bytes()
+}